@@ -260,12 +260,6 @@ def go_deps():
         sum = "h1:eCZ8ulSerjdAiaNpF7GxXIE7ZCMo1moN1qX+S609eVw=",
         version = "v3.8.0",
     )
-    go_repository(
-        name = "com_github_enriquebris_goconcurrentqueue",
-        importpath = "github.com/enriquebris/goconcurrentqueue",
-        sum = "h1:JYrDa45N3xo3Sr9mjvlRaWiBHvBEJIhAdLXO3VGVghA=",
-        version = "v0.7.0",
-    )
 
     go_repository(
         name = "com_github_envoyproxy_go_control_plane",
@@ -17,13 +17,23 @@ package main
 
 import (
 	"flag"
+	"net/http"
+	"os"
+	"strings"
 
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"google.golang.org/grpc"
+
+	grpcutils "github.com/tricorder/src/utils/grpc"
 	"github.com/tricorder/src/utils/log"
 
 	"github.com/tricorder/src/agent/deployer"
+	"github.com/tricorder/src/agent/driver"
 	proc_info "github.com/tricorder/src/agent/proc-info"
 	"github.com/tricorder/src/utils/pg"
 	"github.com/tricorder/src/utils/retry"
+	"github.com/tricorder/src/utils/signing"
 
 	linux_headers "github.com/tricorder/src/agent/ebpf/bcc/linux-headers"
 	"github.com/tricorder/src/agent/ebpf/bcc/utils"
@@ -42,6 +52,32 @@ var (
 	hostSysRootPath = flag.String("host_sys_root_path", "/sys", "The path to the host's /sys file system that "+
 		"can be accessed by agent, this is mounted by Kubernetes. Tricorder reads cgroup and BPF probes from files "+
 		"under this directory")
+	enrichRecords = flag.Bool("enrich_records", false, "Enrich the output of eBPF+WASM modules with the ingest time, "+
+		"node name, agent ID, and the pod and container of the output's 'pid' field")
+	spoolDir = flag.String("spool_dir", "", "The directory that spools the output of eBPF+WASM modules while "+
+		"Postgres or the OTLP endpoint is unavailable, the spooled output is written in order once it recovers, "+
+		"empty to disable spooling")
+	spoolMaxBytes = flag.Int64("spool_max_bytes", 256*1024*1024, "The maximal bytes of the spool of each module, "+
+		"the oldest output is dropped when exceeded")
+	sinkFileDir = flag.String("sink_file_dir", "", "The directory of the files that the FILE sinks of eBPF+WASM "+
+		"modules write, whose paths are file names in it; the modules with FILE sinks fail to deploy if empty")
+	apiServerTokenFile = flag.String("api_server_token_file", "", "The path to the file of the API token, whose role "+
+		"is 'agent', that authenticates the agent to API Server, empty if API Server does not require authentication")
+	// Mutual TLS with API Server is enabled if any of the files is set.
+	apiServerTLSCertFile = flag.String("api_server_tls_cert_file", "", "The path to the PEM certificate that the "+
+		"agent presents to API Server, which only accepts the certificates issued by its client CA")
+	apiServerTLSKeyFile = flag.String("api_server_tls_key_file", "", "The path to the PEM private key of "+
+		"--api_server_tls_cert_file")
+	apiServerTLSCAFile = flag.String("api_server_tls_ca_file", "", "The path to the PEM CA certificates that "+
+		"verify API Server's certificate")
+	apiServerTLSServerName = flag.String("api_server_tls_server_name", "", "The name that API Server's certificate "+
+		"is verified against, defaults to the host of --module_deployer_address")
+	moduleTrustedKeysFile = flag.String("module_trusted_keys_file", "", "The path to the PEM ed25519 public keys "+
+		"that verify the signatures of the modules before loading them, the unsigned or invalidly signed modules are "+
+		"rejected, empty to load modules without verification")
+	metricsAddr = flag.String("metrics_address", ":9464", "The address of the /metrics endpoint, which exposes the "+
+		"agent's operational metrics, and the metrics of the eBPF+WASM modules whose output is written to Prometheus, "+
+		"empty to disable the endpoint")
 )
 
 func main() {
@@ -60,16 +96,61 @@ func main() {
 		log.Errorf("Failed to initialize Linux headers for bcc, error: %v", err)
 	}
 
+	var dialOpts []grpc.DialOption
+	if len(*apiServerTokenFile) > 0 {
+		token, err := os.ReadFile(*apiServerTokenFile)
+		if err != nil {
+			log.Fatalf("Failed to read API token file '%s', error: %v", *apiServerTokenFile, err)
+		}
+		dialOpts = append(dialOpts, grpcutils.WithBearerToken(strings.TrimSpace(string(token))))
+	}
+	tlsFiles := grpcutils.TLSFiles{
+		CertFile: *apiServerTLSCertFile,
+		KeyFile:  *apiServerTLSKeyFile,
+		CAFile:   *apiServerTLSCAFile,
+	}
+	if tlsFiles.Enabled() {
+		creds, err := grpcutils.NewClientTLS(tlsFiles, *apiServerTLSServerName)
+		if err != nil {
+			log.Fatalf("Failed to load TLS certificates of API Server connections, error: %v", err)
+		}
+		dialOpts = append(dialOpts, grpc.WithTransportCredentials(creds))
+	}
+
 	deployer := deployer.New(*apiServerAddr, cfg.nodeName, cfg.podID)
+	deployer.DialOptions = dialOpts
+	if len(*moduleTrustedKeysFile) > 0 {
+		deployer.Verifier, err = signing.LoadVerifier(*moduleTrustedKeysFile)
+		if err != nil {
+			log.Fatalf("Failed to load module trusted keys, error: %v", err)
+		}
+	}
+	deployer.SpoolDir = *spoolDir
+	deployer.SpoolMaxBytes = *spoolMaxBytes
+	deployer.SinkFileDir = *sinkFileDir
+	if len(*metricsAddr) > 0 {
+		deployer.Metrics = driver.NewMetricsRegistry()
+		go serveMetrics(*metricsAddr, prometheus.Gatherers{prometheus.DefaultGatherer, deployer.Metrics})
+	}
 	for {
-		err := communicateWithNode(cfg.nodeName, deployer)
+		err := communicateWithNode(cfg.nodeName, deployer, dialOpts)
 		if err != nil {
 			log.Errorf("Failed to communicate with node[%v], error: %v", cfg.nodeName, err)
 		}
 	}
 }
 
-func communicateWithNode(nodeName string, deployer *deployer.Deployer) error {
+// serveMetrics serves the metrics gathered by the gatherer on the /metrics endpoint.
+func serveMetrics(addr string, gatherer prometheus.Gatherer) {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(gatherer, promhttp.HandlerOpts{}))
+	log.Infof("Serving metrics at %s/metrics", addr)
+	if err := http.ListenAndServe(addr, mux); err != nil {
+		log.Errorf("Failed to serve metrics at '%s', error: %v", addr, err)
+	}
+}
+
+func communicateWithNode(nodeName string, deployer *deployer.Deployer, dialOpts []grpc.DialOption) error {
 	err := retry.ExpBackOffWithLimit(func() error {
 		return deployer.ConnectToAPIServer()
 	})
@@ -86,12 +167,14 @@ func communicateWithNode(nodeName string, deployer *deployer.Deployer) error {
 	}
 	deployer.PGClient = pgClient
 
-	collector := proc_info.NewCollector(*hostSysRootPath, *apiServerAddr, nodeName)
+	collector := proc_info.NewCollector(*hostSysRootPath, *apiServerAddr, nodeName, dialOpts...)
 	err = collector.StartProcInfoReport()
 	if err != nil {
 		log.Errorf("Failed to ReportProcess, error: %v", err)
 		return err
 	}
+	deployer.EnrichRecords = *enrichRecords
+	deployer.PIDResolver = collector
 
 	return deployer.StartModuleDeployLoop()
 }
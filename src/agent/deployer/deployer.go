@@ -20,7 +20,10 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"path/filepath"
 
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
 	"google.golang.org/grpc"
 
 	"github.com/tricorder/src/utils/errors"
@@ -30,11 +33,18 @@ import (
 
 	"github.com/tricorder/src/agent/driver"
 	"github.com/tricorder/src/utils/pg"
+	"github.com/tricorder/src/utils/signing"
 	"github.com/tricorder/src/utils/uuid"
 
 	pb "github.com/tricorder/src/api-server/pb"
 )
 
+// Counts the streaming connections to the API Server that are opened after the first one.
+var streamReconnects = promauto.NewCounter(prometheus.CounterOpts{
+	Name: "starship_agent_grpc_stream_reconnects_total",
+	Help: "The number of times the agent re-opened the streaming connection with the API Server's ModuleDeployer.",
+})
+
 // Deployer manages the communication with API Server:
 // * Receive instructions to deploy modules
 // * Reply deployment status.
@@ -54,12 +64,45 @@ type Deployer struct {
 	// The Module object keeps track of the module's deployment state.
 	idDeployMap map[string]*driver.Module
 
+	// Key is the eBPF+WASM module's ID, value is the version of the deployed module.
+	idVersionMap map[string]int32
+
+	// Key is the eBPF+WASM module's ID, value is the module's spool, which is shared by the versions of the module.
+	idSpoolMap map[string]*driver.Spool
+
 	grpcConn *grpc.ClientConn
 	client   pb.ModuleDeployerClient
 	stream   pb.ModuleDeployer_DeployModuleClient
 
 	// The client to the database instance, which is used to write the output of eBPF+WASM module.
 	PGClient *pg.Client
+
+	// Whether to enrich the output of eBPF+WASM modules with the reserved columns, see pg.ReservedColumns.
+	EnrichRecords bool
+
+	// Optional, resolves the pods and containers of the enriched output.
+	PIDResolver driver.PIDResolver
+
+	// Optional, exposes the metrics of the modules whose output is written to Prometheus.
+	Metrics *driver.MetricsRegistry
+
+	// Optional, the directory of the spools that buffer the output of the modules while their sinks are unavailable.
+	// Each module is spooled in the subdirectory named by its ID.
+	SpoolDir string
+
+	// The maximal bytes of the spool of each module, used if SpoolDir is not empty.
+	SpoolMaxBytes int64
+
+	// Optional, the directory of the files of the modules' FILE sinks, the modules with FILE sinks fail to deploy if
+	// empty.
+	SinkFileDir string
+
+	// Optional, the options of the connection to API Server, like the bearer token.
+	DialOptions []grpc.DialOption
+
+	// Optional, verifies the modules' signatures before deploying them. The unsigned modules, or the modules not signed
+	// by the trusted keys, are rejected.
+	Verifier *signing.Verifier
 }
 
 // New returns a new Deployer instance or error if failed.
@@ -71,6 +114,8 @@ func New(apiServerAddr, nodeName, podId string) *Deployer {
 	d.nodeName = nodeName
 	d.podId = podId
 	d.idDeployMap = make(map[string]*driver.Module)
+	d.idVersionMap = make(map[string]int32)
+	d.idSpoolMap = make(map[string]*driver.Spool)
 
 	return d
 }
@@ -78,9 +123,9 @@ func New(apiServerAddr, nodeName, podId string) *Deployer {
 // ConnectToAPIServer connects this Deployer to API Server and inform its own identity to API Server.
 func (s *Deployer) ConnectToAPIServer() error {
 	log.Infof("Connecting to API Server at %s", s.apiServerAddr)
-	grpcConn, err := grpcutils.DialInsecure(s.apiServerAddr)
+	grpcConn, err := grpcutils.Dial(s.apiServerAddr, s.DialOptions...)
 	if err != nil {
-		return errors.Wrap("connecting to API Server", "dial insecure", err)
+		return errors.Wrap("connecting to API Server", "dial", err)
 	}
 	s.grpcConn = grpcConn
 	s.client = pb.NewModuleDeployerClient(grpcConn)
@@ -96,6 +141,9 @@ func (s *Deployer) initModuleDeployLink() error {
 	if err != nil {
 		return fmt.Errorf("could not open stream to DeplyModule RPC at %s, %v", s.apiServerAddr, err)
 	}
+	if s.stream != nil {
+		streamReconnects.Inc()
+	}
 	s.stream = deployModuleStream
 
 	resp := pb.DeployModuleResp{
@@ -165,21 +213,46 @@ func (s *Deployer) Stop() {
 
 // deployModule deploys the input module.
 func (s *Deployer) deployModule(in *pb.DeployModuleReq) error {
-	if _, found := s.idDeployMap[in.ModuleId]; found {
+	oldDeployment, found := s.idDeployMap[in.ModuleId]
+	if found && s.idVersionMap[in.ModuleId] == in.Version {
 		log.Warnf("Module '%s' was already deployed, skip ...", in.ModuleId)
 		// TODO(yzhao): Might consider returning an error value to distinguish from other errors.
 		return nil
 	}
+	if s.Verifier != nil {
+		if err := s.Verifier.Verify(in.Module); err != nil {
+			// Wraps the error, so that the rejection is reported to API Server, see createDeployModuleResp().
+			return fmt.Errorf("while deploying module '%s' version %d, %w", in.ModuleId, in.Version, err)
+		}
+	}
 	// deployer create a deployment and driver will start this deploys logical
-	deployment, err := driver.Deploy(in.Module, s.PGClient)
+	var enricher *driver.Enricher
+	if s.EnrichRecords {
+		enricher = driver.NewEnricher(s.nodeName, s.uuid, s.PIDResolver)
+	}
+	spool, err := s.spool(in.ModuleId)
+	if err != nil {
+		return fmt.Errorf("while deploying module '%s' version %d, failed to open spool, error: %v",
+			in.ModuleId, in.Version, err)
+	}
+	deployment, err := driver.Deploy(in.ModuleId, in.Module, s.PGClient, enricher, s.Metrics, spool, s.SinkFileDir)
 	if err != nil {
-		return fmt.Errorf("while deploying module '%s', failed to deploy, error: %v", in.ModuleId, err)
+		// If another version is deployed, it keeps running, so the API Server can roll back to it.
+		return fmt.Errorf("while deploying module '%s' version %d, failed to deploy, error: %v",
+			in.ModuleId, in.Version, err)
+	}
+	if found {
+		// The new version is up before the old version is undeployed, so the data flow is not interrupted.
+		log.Infof("Upgraded module '%s' from version %d to version %d, undeploying the old version",
+			in.ModuleId, s.idVersionMap[in.ModuleId], in.Version)
+		oldDeployment.Undeploy()
 	}
 	s.idDeployMap[in.ModuleId] = deployment
+	s.idVersionMap[in.ModuleId] = in.Version
 
-	// This will start a loop to continuously polling perf buffer and feeding data to WASM.
-	// And then write them into database.
-	go deployment.StartPoll()
+	// This will start the goroutines that continuously poll the perf buffer, feed the data to WASM, and then write the
+	// output to the sink.
+	deployment.StartPoll()
 	return nil
 }
 
@@ -191,9 +264,34 @@ func (s *Deployer) undeployModlue(in *pb.DeployModuleReq) error {
 	}
 	d.Undeploy()
 	delete(s.idDeployMap, in.ModuleId)
+	delete(s.idVersionMap, in.ModuleId)
+	if spool, found := s.idSpoolMap[in.ModuleId]; found {
+		// The spooled output is kept on disk, and replayed if the module is deployed again.
+		if err := spool.Close(); err != nil {
+			log.Warnf("While undeploying module ID '%s', failed to close spool, error: %v", in.ModuleId, err)
+		}
+		delete(s.idSpoolMap, in.ModuleId)
+	}
 	return nil
 }
 
+// spool returns the spool of the module, which is opened at the first deployment of the module.
+// Returns nil if spooling is disabled.
+func (s *Deployer) spool(moduleID string) (*driver.Spool, error) {
+	if len(s.SpoolDir) == 0 {
+		return nil, nil
+	}
+	if spool, found := s.idSpoolMap[moduleID]; found {
+		return spool, nil
+	}
+	spool, err := driver.OpenSpool(filepath.Join(s.SpoolDir, moduleID), s.SpoolMaxBytes)
+	if err != nil {
+		return nil, err
+	}
+	s.idSpoolMap[moduleID] = spool
+	return spool, nil
+}
+
 // createDeployModuleResp returns a response message to describe the results of a module deployment operation.
 func createDeployModuleResp(id string, err error) *pb.DeployModuleResp {
 	resp := pb.DeployModuleResp{
@@ -202,6 +300,9 @@ func createDeployModuleResp(id string, err error) *pb.DeployModuleResp {
 	}
 	if err != nil {
 		resp.State = pb.ModuleInstanceState_FAILED
+		if errors.Is(err, signing.ErrRejected) {
+			resp.State = pb.ModuleInstanceState_REJECTED
+		}
 		resp.Desc = err.Error()
 	}
 	return &resp
@@ -16,6 +16,9 @@
 package deployer
 
 import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -30,6 +33,7 @@ import (
 	"github.com/tricorder/src/pb/module/wasm"
 	testutils "github.com/tricorder/src/testing/bazel"
 	"github.com/tricorder/src/utils/log"
+	"github.com/tricorder/src/utils/signing"
 )
 
 const code string = `
@@ -122,3 +126,45 @@ func TestDeployAndRun(t *testing.T) {
 
 	d.Stop()
 }
+
+// Tests that the modules not signed by the trusted keys are rejected before being loaded, and the rejection is reported
+// to API Server as REJECTED, while other failures are reported as FAILED.
+func TestDeployModuleRejected(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	publicKey, key, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(err)
+	_, otherKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(err)
+
+	d := New("", "node_name", "pod_id")
+	d.Verifier = signing.NewVerifier(publicKey)
+	in := &pb.DeployModuleReq{
+		ModuleId: "module",
+		Module: &module.Module{
+			Ebpf: &ebpf.Program{Code: code},
+			Wasm: &wasm.Program{FnName: "copy_input_to_output"},
+		},
+		Deploy: pb.DeployModuleReq_DEPLOY,
+	}
+
+	err = d.deployModule(in)
+	assert.ErrorIs(err, signing.ErrRejected)
+	resp := createDeployModuleResp(in.ModuleId, err)
+	assert.Equal(pb.ModuleInstanceState_REJECTED, resp.State)
+	assert.Contains(resp.Desc, "not signed")
+
+	in.Module.Signature = signing.Sign(in.Module, otherKey)
+	err = d.deployModule(in)
+	assert.ErrorIs(err, signing.ErrRejected)
+
+	in.Module.Signature = signing.Sign(in.Module, key)
+	in.Module.Ebpf.Code += "\n"
+	err = d.deployModule(in)
+	assert.ErrorIs(err, signing.ErrRejected)
+	assert.Empty(d.idDeployMap)
+
+	resp = createDeployModuleResp(in.ModuleId, errors.New("failed to attach probes"))
+	assert.Equal(pb.ModuleInstanceState_FAILED, resp.State)
+}
@@ -15,31 +15,43 @@
 
 package driver
 
+// DataBuffer hands over the data between 2 stages of a module's pipeline, which run in separate goroutines, so a slow
+// stage does not stall the stage before it. See Module.StartPoll().
 type DataBuffer struct {
 	q *Queue
 }
 
 func NewDefaultDataBuffer() *DataBuffer {
-	return &DataBuffer{q: NewQueue(DefaultBufferSize)}
+	return NewDataBuffer(DefaultBufferSize, DropNewest)
 }
 
-func (d *DataBuffer) Produce(pollData map[string][]byte) error {
-	for _, data := range pollData {
-		err := d.q.Enqueue(data)
-		if err != nil {
+// NewDataBuffer returns a buffer of the capacity in bytes, which handles the data that does not fit with the policy.
+func NewDataBuffer(capacity int, policy DropPolicy) *DataBuffer {
+	return &DataBuffer{q: NewQueueWithPolicy(capacity, policy)}
+}
+
+// Produce appends the data items to the buffer, the items that do not fit are dropped or waited for according to the
+// drop policy, and counted in Stats(). Returns ErrClosed if the buffer is closed.
+func (d *DataBuffer) Produce(items [][]byte) error {
+	for _, item := range items {
+		if err := d.q.Enqueue(item); err == ErrClosed {
 			return err
 		}
 	}
 	return nil
 }
 
-func (d *DataBuffer) Consume() []byte {
-	if !d.q.HasData() {
-		return nil
-	}
-	e, err := d.q.Dequeue()
-	if err != nil {
-		return nil
-	}
-	return e
+// Consume waits until the buffer has data, then returns all the data items in the order they were produced.
+// Returns ErrClosed once the buffer is closed and all its data is consumed.
+func (d *DataBuffer) Consume() ([][]byte, error) {
+	return d.q.DequeueAll()
+}
+
+// Close stops Produce(), the consumer receives the remaining data before ErrClosed.
+func (d *DataBuffer) Close() {
+	d.q.Close()
+}
+
+func (d *DataBuffer) Stats() QueueStats {
+	return d.q.Stats()
 }
@@ -0,0 +1,54 @@
+// Copyright (C) 2023  Tricorder Observability
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package driver
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Tests that DataBuffer hands over the produced data to the consumer in order, and counts the dropped data.
+func TestDataBuffer(t *testing.T) {
+	assert := assert.New(t)
+
+	d := NewDataBuffer(8, DropNewest)
+	assert.Nil(d.Produce([][]byte{[]byte("0123"), []byte("4567"), []byte("89")}))
+	assert.Equal(uint64(1), d.Stats().Dropped)
+
+	consumed, err := d.Consume()
+	assert.Nil(err)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			items, err := d.Consume()
+			if err != nil {
+				assert.Equal(ErrClosed, err)
+				return
+			}
+			consumed = append(consumed, items...)
+		}
+	}()
+	assert.Nil(d.Produce([][]byte{[]byte("ab")}))
+	d.Close()
+	wg.Wait()
+
+	assert.Equal([][]byte{[]byte("0123"), []byte("4567"), []byte("ab")}, consumed)
+	assert.Equal(ErrClosed, d.Produce([][]byte{[]byte("cd")}))
+}
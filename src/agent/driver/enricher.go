@@ -0,0 +1,79 @@
+// Copyright (C) 2023  Tricorder Observability
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package driver
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// PIDResolver resolves a process ID to the pod and container that run the process.
+type PIDResolver interface {
+	// ResolvePID returns the pod name and container name of the process, and false if the process is unknown.
+	ResolvePID(pid int32) (pod string, container string, found bool)
+}
+
+// Enricher produces the values of the reserved columns, see pg.ReservedColumns, for the records produced by modules.
+type Enricher struct {
+	nodeName string
+	agentID  string
+
+	// Optional, resolves the pod and container of the records with a 'pid' field.
+	pidResolver PIDResolver
+
+	// Returns the current time, replaced in tests.
+	now func() time.Time
+}
+
+// NewEnricher returns an Enricher for the agent. pidResolver can be nil, then pods and containers are not resolved.
+func NewEnricher(nodeName, agentID string, pidResolver PIDResolver) *Enricher {
+	return &Enricher{
+		nodeName:    nodeName,
+		agentID:     agentID,
+		pidResolver: pidResolver,
+		now:         time.Now,
+	}
+}
+
+// The name of the field that records the ID of the process that produced a record.
+const pidField = "pid"
+
+// The field that records the ID of the process that produced a JSON record.
+type pidRecord struct {
+	PID *int32 `json:"pid"`
+}
+
+// Values returns the values of the reserved columns for the JSON record, in the order of pg.ReservedColumns.
+// The pod and container are nil if the record has no 'pid' field, or the process cannot be resolved.
+func (e *Enricher) Values(record []byte) []interface{} {
+	var r pidRecord
+	if e.pidResolver != nil && json.Unmarshal(record, &r) == nil {
+		return e.ValuesForPID(r.PID)
+	}
+	return e.ValuesForPID(nil)
+}
+
+// ValuesForPID returns the values of the reserved columns for the record produced by the process, in the order of
+// pg.ReservedColumns. pid is nil if the process is unknown.
+func (e *Enricher) ValuesForPID(pid *int32) []interface{} {
+	var pod, container interface{}
+	if e.pidResolver != nil && pid != nil {
+		if podName, containerName, found := e.pidResolver.ResolvePID(*pid); found {
+			pod, container = podName, containerName
+		}
+	}
+	return []interface{}{e.now(), e.nodeName, e.agentID, pod, container}
+}
@@ -0,0 +1,53 @@
+// Copyright (C) 2023  Tricorder Observability
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package driver
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/tricorder/src/utils/pg"
+)
+
+type fakePIDResolver map[int32][2]string
+
+func (r fakePIDResolver) ResolvePID(pid int32) (string, string, bool) {
+	v, found := r[pid]
+	return v[0], v[1], found
+}
+
+// Tests that Enricher returns the values of all reserved columns, and resolves pods and containers from 'pid'.
+func TestEnricherValues(t *testing.T) {
+	assert := assert.New(t)
+
+	now := time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC)
+	e := NewEnricher("node", "agent", fakePIDResolver{1234: {"pod", "container"}})
+	e.now = func() time.Time { return now }
+
+	values := e.Values([]byte(`{"pid":1234,"comm":"curl"}`))
+	assert.Len(values, len(pg.ReservedColumns))
+	assert.Equal([]interface{}{now, "node", "agent", "pod", "container"}, values)
+
+	assert.Equal([]interface{}{now, "node", "agent", nil, nil}, e.Values([]byte(`{"pid":1}`)))
+	assert.Equal([]interface{}{now, "node", "agent", nil, nil}, e.Values([]byte(`{"comm":"curl"}`)))
+	assert.Equal([]interface{}{now, "node", "agent", nil, nil}, e.Values([]byte(`not json`)))
+
+	e = NewEnricher("node", "agent", nil)
+	e.now = func() time.Time { return now }
+	assert.Equal([]interface{}{now, "node", "agent", nil, nil}, e.Values([]byte(`{"pid":1234}`)))
+}
@@ -0,0 +1,467 @@
+// Copyright (C) 2023  Tricorder Observability
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package driver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+
+	commonpb "github.com/tricorder/src/pb/module/common"
+	"github.com/tricorder/src/utils/log"
+	"github.com/tricorder/src/utils/pg"
+)
+
+// MetricsRegistry holds the metrics aggregated from the output of the modules with PROMETHEUS sinks.
+// It is the prometheus.Gatherer of the agent's /metrics endpoint.
+type MetricsRegistry struct {
+	mu sync.Mutex
+
+	// Key is the module ID, as modules of different tenants can have the same name. The new version of a module being
+	// upgraded replaces the registry of the old version.
+	registries map[string]*prometheus.Registry
+}
+
+// NewMetricsRegistry returns an empty MetricsRegistry.
+func NewMetricsRegistry() *MetricsRegistry {
+	return &MetricsRegistry{registries: make(map[string]*prometheus.Registry)}
+}
+
+// Gather implements prometheus.Gatherer.
+func (r *MetricsRegistry) Gather() ([]*dto.MetricFamily, error) {
+	r.mu.Lock()
+	gatherers := make(prometheus.Gatherers, 0, len(r.registries))
+	for _, registry := range r.registries {
+		gatherers = append(gatherers, registry)
+	}
+	r.mu.Unlock()
+	return gatherers.Gather()
+}
+
+func (r *MetricsRegistry) add(moduleID string, registry *prometheus.Registry) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.registries[moduleID] = registry
+}
+
+// remove removes the registry of the module, unless it was replaced by the registry of another version.
+func (r *MetricsRegistry) remove(moduleID string, registry *prometheus.Registry) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.registries[moduleID] == registry {
+		delete(r.registries, moduleID)
+	}
+}
+
+// metricsSink aggregates the records into the metrics declared by the fields of the output, see DataField.Metric.
+type metricsSink struct {
+	registry *prometheus.Registry
+
+	// The indexes of the label fields in the records, and the label names.
+	labelColumns []int
+	labelNames   []string
+
+	metrics []fieldMetric
+
+	// Optional, called by Close().
+	onClose func() error
+}
+
+// fieldMetric updates a metric with the value of a field.
+type fieldMetric struct {
+	column int
+	name   string
+	update func(labels []string, value float64) error
+}
+
+// metricPrefix returns the prefix of the names of the metrics of the module, which is the module name with the
+// characters that are invalid in metric names replaced by '_', and prefixed by '_' if it starts with a digit.
+func metricPrefix(moduleName string) string {
+	var b strings.Builder
+	for i, r := range strings.ToLower(moduleName) {
+		switch {
+		case r >= '0' && r <= '9':
+			if i == 0 {
+				b.WriteRune('_')
+			}
+			b.WriteRune(r)
+		case r >= 'a' && r <= 'z', r == '_':
+			b.WriteRune(r)
+		default:
+			b.WriteRune('_')
+		}
+	}
+	return b.String()
+}
+
+// moduleIDLabel labels the metrics of a module with its ID, so the metrics of the modules of different tenants with the
+// same name, which have the same prefix, are different series.
+const moduleIDLabel = "module_id"
+
+// newMetricsSink returns the sink that aggregates the output of the module into the metrics declared by the fields.
+// The metrics are labelled with the module ID if it is not empty.
+func newMetricsSink(moduleID, moduleName string, fields []*commonpb.DataField) (*metricsSink, error) {
+	s := &metricsSink{registry: prometheus.NewRegistry()}
+	registerer := prometheus.Registerer(s.registry)
+	if len(moduleID) > 0 {
+		registerer = prometheus.WrapRegistererWith(prometheus.Labels{moduleIDLabel: moduleID}, s.registry)
+	}
+	prefix := metricPrefix(moduleName)
+	for i, f := range fields {
+		if f.Metric == commonpb.DataField_LABEL {
+			s.labelColumns = append(s.labelColumns, i)
+			s.labelNames = append(s.labelNames, strings.ToLower(f.Name))
+		}
+	}
+	for i, f := range fields {
+		name := prefix + "_" + strings.ToLower(f.Name)
+		help := fmt.Sprintf("The field '%s' of the output of module '%s'.", f.Name, moduleName)
+		var collector prometheus.Collector
+		var update func(labels []string, value float64) error
+		switch f.Metric {
+		case commonpb.DataField_NOT_METRIC, commonpb.DataField_LABEL:
+			continue
+		case commonpb.DataField_COUNTER:
+			if !strings.HasSuffix(name, "_total") {
+				name += "_total"
+			}
+			vec := prometheus.NewCounterVec(prometheus.CounterOpts{Name: name, Help: help}, s.labelNames)
+			collector = vec
+			update = func(labels []string, value float64) error {
+				if value < 0 {
+					return fmt.Errorf("counter '%s' cannot be decreased by %v", name, value)
+				}
+				vec.WithLabelValues(labels...).Add(value)
+				return nil
+			}
+		case commonpb.DataField_GAUGE:
+			vec := prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: name, Help: help}, s.labelNames)
+			collector = vec
+			update = func(labels []string, value float64) error {
+				vec.WithLabelValues(labels...).Set(value)
+				return nil
+			}
+		case commonpb.DataField_HISTOGRAM:
+			opts := prometheus.HistogramOpts{Name: name, Help: help, Buckets: f.Buckets}
+			vec := prometheus.NewHistogramVec(opts, s.labelNames)
+			collector = vec
+			update = func(labels []string, value float64) error {
+				vec.WithLabelValues(labels...).Observe(value)
+				return nil
+			}
+		default:
+			return nil, fmt.Errorf("metric kind '%s' of field '%s' is not supported", f.Metric, f.Name)
+		}
+		if err := registerer.Register(collector); err != nil {
+			return nil, fmt.Errorf("failed to register metric '%s', error: %v", name, err)
+		}
+		s.metrics = append(s.metrics, fieldMetric{column: i, name: name, update: update})
+	}
+	if len(s.metrics) == 0 {
+		return nil, fmt.Errorf("no field of the output of module '%s' is a COUNTER, GAUGE or HISTOGRAM", moduleName)
+	}
+	return s, nil
+}
+
+// labelValue returns the text of a field value as a label value, NULL is an empty label value.
+func labelValue(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case []byte:
+		return string(v)
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	}
+	return fmt.Sprint(v)
+}
+
+// metricValue returns the field value as a sample value, false if the value is NULL.
+func metricValue(v interface{}) (float64, bool, error) {
+	switch v := v.(type) {
+	case nil:
+		return 0, false, nil
+	case int32:
+		return float64(v), true, nil
+	case int64:
+		return float64(v), true, nil
+	case float64:
+		return v, true, nil
+	case bool:
+		if v {
+			return 1, true, nil
+		}
+		return 0, true, nil
+	case []byte:
+		// A JSON number.
+		f, err := strconv.ParseFloat(string(v), 64)
+		return f, err == nil, err
+	case string:
+		f, err := strconv.ParseFloat(v, 64)
+		return f, err == nil, err
+	}
+	return 0, false, fmt.Errorf("value of type %T is not a number", v)
+}
+
+// Write updates the metrics with the records, the schema is not used, as the fields of the output come first in the
+// records, in the order they were declared.
+func (s *metricsSink) Write(records [][]interface{}, _ *pg.Schema) error {
+	labels := make([]string, len(s.labelColumns))
+	for _, record := range records {
+		for i, col := range s.labelColumns {
+			if col >= len(record) {
+				return fmt.Errorf("while updating metrics, record has %d fields, label '%s' is missing",
+					len(record), s.labelNames[i])
+			}
+			labels[i] = labelValue(record[col])
+		}
+		for _, m := range s.metrics {
+			if m.column >= len(record) {
+				return fmt.Errorf("while updating metrics, record has %d fields, metric '%s' is missing",
+					len(record), m.name)
+			}
+			value, ok, err := metricValue(record[m.column])
+			if err != nil {
+				return fmt.Errorf("while updating metric '%s', %v", m.name, err)
+			}
+			if !ok {
+				continue
+			}
+			if err := m.update(labels, value); err != nil {
+				return fmt.Errorf("while updating metrics, %v", err)
+			}
+		}
+	}
+	return nil
+}
+
+func (s *metricsSink) Close() error {
+	if s.onClose == nil {
+		return nil
+	}
+	return s.onClose()
+}
+
+// The default interval between the pushes of an OTLP_METRICS sink.
+const defaultOTLPPushInterval = time.Minute
+
+// otlpMetricsPusher periodically pushes the gathered metrics to an OTLP/HTTP metrics endpoint, as cumulative sums,
+// gauges and histograms.
+type otlpMetricsPusher struct {
+	endpoint   string
+	headers    map[string]string
+	moduleName string
+	gatherer   prometheus.Gatherer
+	client     *http.Client
+
+	// The start time of the cumulative metrics.
+	start time.Time
+
+	// Returns the current time, replaced in tests.
+	now func() time.Time
+
+	stopCh chan struct{}
+	doneCh chan struct{}
+}
+
+func newOTLPMetricsPusher(endpoint string, headers map[string]string, moduleName string,
+	gatherer prometheus.Gatherer,
+) *otlpMetricsPusher {
+	return &otlpMetricsPusher{
+		endpoint:   endpoint,
+		headers:    headers,
+		moduleName: moduleName,
+		gatherer:   gatherer,
+		client:     &http.Client{Timeout: 10 * time.Second},
+		start:      time.Now(),
+		now:        time.Now,
+		stopCh:     make(chan struct{}),
+		doneCh:     make(chan struct{}),
+	}
+}
+
+// run pushes the metrics every interval until stop() is called, which pushes the metrics for the last time.
+func (p *otlpMetricsPusher) run(interval time.Duration) {
+	defer close(p.doneCh)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := p.push(); err != nil {
+				log.Warnf("While pushing metrics of module '%s', %v", p.moduleName, err)
+			}
+		case <-p.stopCh:
+			if err := p.push(); err != nil {
+				log.Warnf("While pushing metrics of module '%s' for the last time, %v", p.moduleName, err)
+			}
+			return
+		}
+	}
+}
+
+func (p *otlpMetricsPusher) stop() error {
+	close(p.stopCh)
+	<-p.doneCh
+	return nil
+}
+
+// The OTLP/JSON encoding of the metrics, only the used fields are declared.
+// https://github.com/open-telemetry/opentelemetry-proto/blob/main/opentelemetry/proto/metrics/v1/metrics.proto
+type (
+	otlpMetricsData struct {
+		ResourceMetrics []otlpResourceMetrics `json:"resourceMetrics"`
+	}
+	otlpResourceMetrics struct {
+		Resource     otlpResource       `json:"resource"`
+		ScopeMetrics []otlpScopeMetrics `json:"scopeMetrics"`
+	}
+	otlpScopeMetrics struct {
+		Scope   otlpScope    `json:"scope"`
+		Metrics []otlpMetric `json:"metrics"`
+	}
+	otlpMetric struct {
+		Name        string         `json:"name"`
+		Description string         `json:"description,omitempty"`
+		Sum         *otlpSum       `json:"sum,omitempty"`
+		Gauge       *otlpGauge     `json:"gauge,omitempty"`
+		Histogram   *otlpHistogram `json:"histogram,omitempty"`
+	}
+	otlpSum struct {
+		DataPoints             []otlpNumberDataPoint `json:"dataPoints"`
+		AggregationTemporality int                   `json:"aggregationTemporality"`
+		IsMonotonic            bool                  `json:"isMonotonic"`
+	}
+	otlpGauge struct {
+		DataPoints []otlpNumberDataPoint `json:"dataPoints"`
+	}
+	otlpHistogram struct {
+		DataPoints             []otlpHistogramDataPoint `json:"dataPoints"`
+		AggregationTemporality int                      `json:"aggregationTemporality"`
+	}
+	otlpNumberDataPoint struct {
+		Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+		StartTimeUnixNano string         `json:"startTimeUnixNano,omitempty"`
+		TimeUnixNano      string         `json:"timeUnixNano"`
+		AsDouble          float64        `json:"asDouble"`
+	}
+	otlpHistogramDataPoint struct {
+		Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+		StartTimeUnixNano string         `json:"startTimeUnixNano"`
+		TimeUnixNano      string         `json:"timeUnixNano"`
+		// The uint64 values are encoded as decimal strings in OTLP/JSON.
+		Count string  `json:"count"`
+		Sum   float64 `json:"sum"`
+		// The counts of the buckets, not cumulative, the last one counts the values above the last bound.
+		BucketCounts   []string  `json:"bucketCounts"`
+		ExplicitBounds []float64 `json:"explicitBounds"`
+	}
+)
+
+// AGGREGATION_TEMPORALITY_CUMULATIVE of OTLP, Prometheus metrics are cumulative since the start of the process.
+const otlpCumulative = 2
+
+func (p *otlpMetricsPusher) push() error {
+	families, err := p.gatherer.Gather()
+	if err != nil {
+		return fmt.Errorf("failed to gather metrics, error: %v", err)
+	}
+	data, err := json.Marshal(otlpMetricsData{ResourceMetrics: []otlpResourceMetrics{{
+		Resource: otlpModuleResource(p.moduleName),
+		ScopeMetrics: []otlpScopeMetrics{{
+			Scope:   otlpScope{Name: otlpScopeName},
+			Metrics: otlpMetrics(families, p.start, p.now()),
+		}},
+	}}})
+	if err != nil {
+		return fmt.Errorf("failed to marshal request, error: %v", err)
+	}
+	return postOTLP(context.Background(), p.client, p.endpoint, p.headers, data)
+}
+
+// otlpMetrics converts the gathered Prometheus metrics to OTLP metrics, which are cumulative since start.
+func otlpMetrics(families []*dto.MetricFamily, start, now time.Time) []otlpMetric {
+	startNano := strconv.FormatInt(start.UnixNano(), 10)
+	nowNano := strconv.FormatInt(now.UnixNano(), 10)
+	metrics := make([]otlpMetric, 0, len(families))
+	for _, family := range families {
+		m := otlpMetric{Name: family.GetName(), Description: family.GetHelp()}
+		for _, metric := range family.Metric {
+			attrs := make([]otlpKeyValue, 0, len(metric.Label))
+			for _, label := range metric.Label {
+				attrs = append(attrs, otlpKeyValue{Key: label.GetName(), Value: otlpValue{StringValue: label.GetValue()}})
+			}
+			switch family.GetType() {
+			case dto.MetricType_COUNTER:
+				if m.Sum == nil {
+					m.Sum = &otlpSum{AggregationTemporality: otlpCumulative, IsMonotonic: true}
+				}
+				m.Sum.DataPoints = append(m.Sum.DataPoints, otlpNumberDataPoint{
+					Attributes: attrs, StartTimeUnixNano: startNano, TimeUnixNano: nowNano,
+					AsDouble: metric.GetCounter().GetValue(),
+				})
+			case dto.MetricType_GAUGE:
+				if m.Gauge == nil {
+					m.Gauge = &otlpGauge{}
+				}
+				m.Gauge.DataPoints = append(m.Gauge.DataPoints, otlpNumberDataPoint{
+					Attributes: attrs, TimeUnixNano: nowNano, AsDouble: metric.GetGauge().GetValue(),
+				})
+			case dto.MetricType_HISTOGRAM:
+				if m.Histogram == nil {
+					m.Histogram = &otlpHistogram{AggregationTemporality: otlpCumulative}
+				}
+				m.Histogram.DataPoints = append(m.Histogram.DataPoints,
+					otlpHistogramPoint(metric.GetHistogram(), attrs, startNano, nowNano))
+			}
+		}
+		metrics = append(metrics, m)
+	}
+	return metrics
+}
+
+// otlpHistogramPoint converts the cumulative buckets of a Prometheus histogram to the buckets of an OTLP histogram.
+func otlpHistogramPoint(h *dto.Histogram, attrs []otlpKeyValue, startNano, nowNano string) otlpHistogramDataPoint {
+	point := otlpHistogramDataPoint{
+		Attributes:        attrs,
+		StartTimeUnixNano: startNano,
+		TimeUnixNano:      nowNano,
+		Count:             strconv.FormatUint(h.GetSampleCount(), 10),
+		Sum:               h.GetSampleSum(),
+	}
+	var cumulative uint64
+	for _, bucket := range h.Bucket {
+		if math.IsInf(bucket.GetUpperBound(), 1) {
+			continue
+		}
+		point.ExplicitBounds = append(point.ExplicitBounds, bucket.GetUpperBound())
+		point.BucketCounts = append(point.BucketCounts, strconv.FormatUint(bucket.GetCumulativeCount()-cumulative, 10))
+		cumulative = bucket.GetCumulativeCount()
+	}
+	point.BucketCounts = append(point.BucketCounts, strconv.FormatUint(h.GetSampleCount()-cumulative, 10))
+	return point
+}
@@ -0,0 +1,240 @@
+// Copyright (C) 2023  Tricorder Observability
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package driver
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	commonpb "github.com/tricorder/src/pb/module/common"
+)
+
+var testMetricFields = []*commonpb.DataField{
+	{Name: "comm", Type: commonpb.DataField_TEXT, Metric: commonpb.DataField_LABEL},
+	{Name: "bytes", Type: commonpb.DataField_BIGINT, Metric: commonpb.DataField_COUNTER},
+	{Name: "note", Type: commonpb.DataField_TEXT},
+	{
+		Name: "latency", Type: commonpb.DataField_DOUBLE, Metric: commonpb.DataField_HISTOGRAM,
+		Buckets: []float64{0.1, 1},
+	},
+	{Name: "Inflight", Type: commonpb.DataField_INT, Metric: commonpb.DataField_GAUGE},
+}
+
+// Tests that metricPrefix() replaces the characters that are invalid in metric names.
+func TestMetricPrefix(t *testing.T) {
+	assert := assert.New(t)
+	assert.Equal("tcp_stats", metricPrefix("tcp_stats"))
+	assert.Equal("tcp_stats_v2", metricPrefix("TCP-stats.v2"))
+	assert.Equal("_1st", metricPrefix("1st"))
+}
+
+// Tests that metricsSink aggregates the records into the metrics declared by the fields.
+func TestMetricsSink(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	registry := NewMetricsRegistry()
+	s, err := NewSink(&commonpb.Sink{Type: commonpb.Sink_PROMETHEUS},
+		SinkEnv{ModuleName: "tcp-stats", Fields: testMetricFields, Metrics: registry})
+	require.Nil(err)
+
+	require.Nil(s.Write([][]interface{}{
+		{"curl", int64(100), "a", 0.05, int32(3)},
+		{"curl", int64(20), "b", 0.5, int32(1)},
+		{"bash", int64(1), "c", nil, nil},
+	}, nil))
+	expected := `
+# HELP tcp_stats_bytes_total The field 'bytes' of the output of module 'tcp-stats'.
+# TYPE tcp_stats_bytes_total counter
+tcp_stats_bytes_total{comm="bash"} 1
+tcp_stats_bytes_total{comm="curl"} 120
+# HELP tcp_stats_inflight The field 'Inflight' of the output of module 'tcp-stats'.
+# TYPE tcp_stats_inflight gauge
+tcp_stats_inflight{comm="curl"} 1
+# HELP tcp_stats_latency The field 'latency' of the output of module 'tcp-stats'.
+# TYPE tcp_stats_latency histogram
+tcp_stats_latency_bucket{comm="curl",le="0.1"} 1
+tcp_stats_latency_bucket{comm="curl",le="1"} 2
+tcp_stats_latency_bucket{comm="curl",le="+Inf"} 2
+tcp_stats_latency_sum{comm="curl"} 0.55
+tcp_stats_latency_count{comm="curl"} 2
+`
+	assert.Nil(testutil.GatherAndCompare(registry, strings.NewReader(expected)))
+
+	assert.ErrorContains(s.Write([][]interface{}{{"curl", int64(-1), "a", nil, nil}}, nil), "cannot be decreased")
+	assert.ErrorContains(s.Write([][]interface{}{{"curl", "many", "a", nil, nil}}, nil), "invalid syntax")
+	assert.ErrorContains(s.Write([][]interface{}{{"curl", []int{1}, "a", nil, nil}}, nil), "is not a number")
+	assert.ErrorContains(s.Write([][]interface{}{{"curl"}}, nil), "metric 'tcp_stats_bytes_total' is missing")
+
+	// Closing removes the metrics from the registry.
+	require.Nil(s.Close())
+	families, err := registry.Gather()
+	require.Nil(err)
+	assert.Empty(families)
+
+	_, err = NewSink(&commonpb.Sink{Type: commonpb.Sink_PROMETHEUS},
+		SinkEnv{ModuleName: "module", Fields: testMetricFields[:1], Metrics: registry})
+	assert.ErrorContains(err, "no field of the output of module 'module' is a COUNTER, GAUGE or HISTOGRAM")
+}
+
+// Tests that closing the sink of an upgraded module's old version keeps the metrics of the new version.
+func TestMetricsRegistryReplace(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	registry := NewMetricsRegistry()
+	env := SinkEnv{ModuleName: "module", Fields: testMetricFields, Metrics: registry}
+	oldSink, err := NewSink(&commonpb.Sink{Type: commonpb.Sink_PROMETHEUS}, env)
+	require.Nil(err)
+	newSink, err := NewSink(&commonpb.Sink{Type: commonpb.Sink_PROMETHEUS}, env)
+	require.Nil(err)
+	require.Nil(newSink.Write([][]interface{}{{"curl", int64(1), "a", nil, nil}}, nil))
+	require.Nil(oldSink.Close())
+
+	count, err := testutil.GatherAndCount(registry, "module_bytes_total")
+	require.Nil(err)
+	assert.Equal(1, count)
+}
+
+// Tests that the metrics of the modules of different tenants with the same name are labelled with their IDs, and
+// closing the sink of one keeps the metrics of the other.
+func TestMetricsRegistrySameName(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	registry := NewMetricsRegistry()
+	fields := testMetricFields[:2]
+	sinkA, err := NewSink(&commonpb.Sink{Type: commonpb.Sink_PROMETHEUS},
+		SinkEnv{ModuleID: "a", ModuleName: "module", Fields: fields, Metrics: registry})
+	require.Nil(err)
+	sinkB, err := NewSink(&commonpb.Sink{Type: commonpb.Sink_PROMETHEUS},
+		SinkEnv{ModuleID: "b", ModuleName: "module", Fields: fields, Metrics: registry})
+	require.Nil(err)
+	require.Nil(sinkA.Write([][]interface{}{{"curl", int64(1)}}, nil))
+	require.Nil(sinkB.Write([][]interface{}{{"curl", int64(2)}}, nil))
+	expected := `
+# HELP module_bytes_total The field 'bytes' of the output of module 'module'.
+# TYPE module_bytes_total counter
+module_bytes_total{comm="curl",module_id="a"} 1
+module_bytes_total{comm="curl",module_id="b"} 2
+`
+	assert.Nil(testutil.GatherAndCompare(registry, strings.NewReader(expected)))
+
+	require.Nil(sinkA.Close())
+	expected = `
+# HELP module_bytes_total The field 'bytes' of the output of module 'module'.
+# TYPE module_bytes_total counter
+module_bytes_total{comm="curl",module_id="b"} 2
+`
+	assert.Nil(testutil.GatherAndCompare(registry, strings.NewReader(expected)))
+}
+
+// Tests that otlpMetrics() converts the gathered metrics, and the cumulative buckets to per-bucket counts.
+func TestOTLPMetrics(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	registry := NewMetricsRegistry()
+	s, err := NewSink(&commonpb.Sink{Type: commonpb.Sink_PROMETHEUS},
+		SinkEnv{ModuleName: "module", Fields: testMetricFields, Metrics: registry})
+	require.Nil(err)
+	require.Nil(s.Write([][]interface{}{
+		{"curl", int64(100), "a", 0.05, int32(3)},
+		{"curl", int64(20), "b", 0.5, int32(1)},
+		{"curl", int64(20), "b", 5.0, int32(1)},
+	}, nil))
+	families, err := registry.Gather()
+	require.Nil(err)
+
+	start := time.Unix(1, 0)
+	now := time.Unix(2, 0)
+	metrics := otlpMetrics(families, start, now)
+	require.Len(metrics, 3)
+	curl := []otlpKeyValue{{Key: "comm", Value: otlpValue{StringValue: "curl"}}}
+
+	assert.Equal("module_bytes_total", metrics[0].Name)
+	assert.Equal(&otlpSum{
+		DataPoints: []otlpNumberDataPoint{{
+			Attributes: curl, StartTimeUnixNano: "1000000000", TimeUnixNano: "2000000000", AsDouble: 140,
+		}},
+		AggregationTemporality: otlpCumulative,
+		IsMonotonic:            true,
+	}, metrics[0].Sum)
+
+	assert.Equal("module_inflight", metrics[1].Name)
+	assert.Equal(&otlpGauge{DataPoints: []otlpNumberDataPoint{{
+		Attributes: curl, TimeUnixNano: "2000000000", AsDouble: 1,
+	}}}, metrics[1].Gauge)
+
+	assert.Equal("module_latency", metrics[2].Name)
+	assert.Equal(&otlpHistogram{
+		DataPoints: []otlpHistogramDataPoint{{
+			Attributes: curl, StartTimeUnixNano: "1000000000", TimeUnixNano: "2000000000",
+			Count: "3", Sum: 5.55, BucketCounts: []string{"1", "1", "1"}, ExplicitBounds: []float64{0.1, 1},
+		}},
+		AggregationTemporality: otlpCumulative,
+	}, metrics[2].Histogram)
+}
+
+// Tests that the OTLP_METRICS sink pushes the metrics to a stand-in collector when it is closed.
+func TestOTLPMetricsSink(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	var (
+		mu      sync.Mutex
+		gotPath string
+		gotBody otlpMetricsData
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		gotPath = r.URL.Path
+		body, _ := io.ReadAll(r.Body)
+		assert.Nil(json.Unmarshal(body, &gotBody))
+	}))
+	defer server.Close()
+
+	s, err := NewSink(&commonpb.Sink{
+		Type:                commonpb.Sink_OTLP_METRICS,
+		Endpoint:            server.URL + "/v1/metrics",
+		PushIntervalSeconds: 3600,
+	}, SinkEnv{ModuleName: "module", Fields: testMetricFields})
+	require.Nil(err)
+	require.Nil(s.Write([][]interface{}{{"curl", int64(100), "a", 0.05, int32(3)}}, nil))
+	require.Nil(s.Close())
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal("/v1/metrics", gotPath)
+	require.Len(gotBody.ResourceMetrics, 1)
+	assert.Equal(otlpModuleResource("module"), gotBody.ResourceMetrics[0].Resource)
+	require.Len(gotBody.ResourceMetrics[0].ScopeMetrics, 1)
+	metrics := gotBody.ResourceMetrics[0].ScopeMetrics[0].Metrics
+	require.Len(metrics, 3)
+	assert.Equal("module_bytes_total", metrics[0].Name)
+	assert.Equal(100.0, metrics[0].Sum.DataPoints[0].AsDouble)
+}
@@ -16,7 +16,10 @@
 package driver
 
 import (
+	"context"
 	"fmt"
+	"sync"
+	"time"
 
 	"github.com/tricorder/src/utils/log"
 
@@ -28,8 +31,16 @@ import (
 	"github.com/tricorder/src/utils/bytes"
 )
 
+// The interval of polling the perf buffer of the eBPF program.
+const pollInterval = 10 * time.Millisecond
+
+// How long Undeploy() waits for the sink to write the polled events, before it cancels the writes.
+const defaultDrainTimeout = 10 * time.Second
+
 // Module holds data about an eBPF+WASM module waiting for being deployed.
 type Module struct {
+	// The ID of the module, which is unique unlike its name, as modules of different tenants can have the same name.
+	id       string
 	modulePB *modulepb.Module
 
 	// An abstract of a BCC program, which provides interfaces to manage the whole lifetime
@@ -45,15 +56,49 @@ type Module struct {
 	// has to be decoded before writing into the data table.
 	outputSchema *pg.Schema
 
-	// The client to the database that stores Observability data.
-	pgClient *pg.Client
+	// Writes the output records, to Postgres by default, see NewSink().
+	sink Sink
+
+	// Optional, fills the reserved columns of the records written to the data table.
+	enricher *Enricher
+
+	// The output schema with the reserved columns appended, used if enricher is not nil.
+	enrichedSchema *pg.Schema
+
+	// The polled events waiting to be processed by WASM, and the WASM outputs waiting to be written to the sink.
+	events  *DataBuffer
+	outputs *DataBuffer
+
+	// Closed by Undeploy() to stop the pipeline started by StartPoll().
+	stop chan struct{}
+
+	// Waits for the goroutines of the pipeline.
+	pipeline sync.WaitGroup
+
+	// Cancelled by Undeploy() if the pipeline is not drained in drainTimeout, which aborts the writes to the sink.
+	writeCtx     context.Context
+	cancelWrites context.CancelFunc
+	drainTimeout time.Duration
 }
 
-// Deploy deploys eBPF+WASM module. Returns the Module object and error if failed.
-func Deploy(modPB *modulepb.Module, pgClient *pg.Client) (*Module, error) {
+// Deploy deploys eBPF+WASM module with the ID. Returns the Module object and error if failed.
+// The output is written to the module's sink, pgClient is used if the sink is Postgres, and metrics exposes the
+// metrics if the sink is Prometheus.
+// If enricher is not nil, the output records are enriched with the reserved columns, see pg.ReservedColumns.
+// If spool is not nil, the output is spooled while the sink is unavailable, see Spool.
+// sinkFileDir is the directory of the file if the sink is FILE, see SinkEnv.FileDir.
+func Deploy(moduleID string, modPB *modulepb.Module, pgClient *pg.Client, enricher *Enricher,
+	metrics *MetricsRegistry, spool *Spool, sinkFileDir string,
+) (*Module, error) {
 	m := new(Module)
 
+	m.id = moduleID
 	m.modulePB = modPB
+	m.events = NewDataBuffer(DefaultBufferSize, DropNewest)
+	m.outputs = NewDataBuffer(DefaultBufferSize, Block)
+	m.stop = make(chan struct{})
+	m.writeCtx, m.cancelWrites = context.WithCancel(context.Background())
+	m.drainTimeout = defaultDrainTimeout
 
 	ebpfProg, err := bcc.NewProgram(modPB.Ebpf)
 	if err != nil {
@@ -71,15 +116,96 @@ func Deploy(modPB *modulepb.Module, pgClient *pg.Client) (*Module, error) {
 	}
 	m.wasm = wasmModule
 	m.outputSchema = pg.SchemaFromPB(modPB.Wasm.OutputSchema)
-	m.pgClient = pgClient
+	sink, err := NewSink(modPB.Wasm.Sink, SinkEnv{
+		ModuleID:   moduleID,
+		ModuleName: modPB.Name,
+		Fields:     modPB.Wasm.OutputSchema.GetFields(),
+		PGClient:   pgClient,
+		Metrics:    metrics,
+		Spool:      spool,
+		FileDir:    sinkFileDir,
+		Context:    m.writeCtx,
+	})
+	if err != nil {
+		ebpfProg.Stop()
+		return nil, fmt.Errorf("while deploying, failed to create output sink, error: %v", err)
+	}
+	m.sink = sink
+	if enricher != nil {
+		m.enricher = enricher
+		m.enrichedSchema = pg.WithReservedColumns(m.outputSchema)
+	}
 	return m, nil
 }
 
+// StartPoll starts the pipeline of the module, which runs until Undeploy(). Each stage runs in its own goroutine:
+// polling events from the eBPF perf buffer, processing the events with WASM, and writing the output to the sink.
+// The stages are decoupled by DataBuffers, so a slow sink does not stall the polling. If WASM falls behind, the output
+// buffer blocks it, and the newest events are dropped once the event buffer is full.
 func (m *Module) StartPoll() {
+	m.pipeline.Add(3)
+	go m.pollLoop()
+	go m.processLoop()
+	go m.outputLoop()
+}
+
+func (m *Module) pollLoop() {
+	defer m.pipeline.Done()
+	defer m.events.Close()
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	var dropped uint64
 	for {
-		err := m.Poll()
+		select {
+		case <-m.stop:
+			return
+		case <-ticker.C:
+		}
+		items, err := m.pollEvents()
 		if err != nil {
 			log.Error(err)
+			continue
+		}
+		eventsPolled.WithLabelValues(m.Name(), m.id).Add(float64(len(items)))
+		_ = m.events.Produce(items)
+
+		stats := m.events.Stats()
+		eventsDropped.WithLabelValues(m.Name(), m.id).Add(float64(stats.Dropped - dropped))
+		dropped = stats.Dropped
+		queueBytes.WithLabelValues(m.Name(), m.id, "events").Set(float64(stats.Bytes))
+		queueBytes.WithLabelValues(m.Name(), m.id, "outputs").Set(float64(m.outputs.Stats().Bytes))
+	}
+}
+
+func (m *Module) processLoop() {
+	defer m.pipeline.Done()
+	defer m.outputs.Close()
+	for {
+		items, err := m.events.Consume()
+		if err != nil {
+			return
+		}
+		outputs, err := m.process(items)
+		if err != nil {
+			log.Errorf("While polling module '%s', %v", m.Name(), err)
+			eventsDropped.WithLabelValues(m.Name(), m.id).Add(float64(len(items)))
+			continue
+		}
+		// The output buffer blocks instead of dropping, so it only returns error after Undeploy().
+		_ = m.outputs.Produce(outputs)
+	}
+}
+
+func (m *Module) outputLoop() {
+	defer m.pipeline.Done()
+	for {
+		outputs, err := m.outputs.Consume()
+		if err != nil {
+			return
+		}
+		if err := m.output(outputs); err != nil {
+			log.Errorf("While polling module '%s', %v", m.Name(), err)
+			eventsDropped.WithLabelValues(m.Name(), m.id).Add(float64(len(outputs)))
 		}
 	}
 }
@@ -88,25 +214,73 @@ func (m *Module) Name() string {
 	return m.modulePB.Name
 }
 
+// Undeploy stops the pipeline after the polled events are processed and written, and releases the module's resources.
 func (m *Module) Undeploy() {
+	m.stopPipeline()
 	m.ebpf.Stop()
+	if err := m.sink.Close(); err != nil {
+		log.Warnf("While undeploying module '%s', failed to close output sink, error: %v", m.Name(), err)
+	}
+}
+
+// stopPipeline stops the pipeline, and waits for it to write the polled events. If the sink hangs instead of failing,
+// the writes are cancelled after drainTimeout, and the remaining outputs are spooled or dropped.
+func (m *Module) stopPipeline() {
+	close(m.stop)
+	drained := make(chan struct{})
+	go func() {
+		m.pipeline.Wait()
+		close(drained)
+	}()
+	select {
+	case <-drained:
+	case <-time.After(m.drainTimeout):
+		log.Warnf("While undeploying module '%s', the output was not written in %v, cancelling the writes",
+			m.Name(), m.drainTimeout)
+		m.cancelWrites()
+		<-drained
+	}
+	m.cancelWrites()
 }
 
-// Poll runs the whole process of polling data from eBPF, copying the data to WASM, reading the result from WASM.
+// Poll runs the whole process of polling data from eBPF, copying the data to WASM, reading the result from WASM, and
+// writing the result to the sink, in the calling goroutine.
 func (m *Module) Poll() error {
+	items, err := m.pollEvents()
+	if err != nil {
+		return err
+	}
+	eventsPolled.WithLabelValues(m.Name(), m.id).Add(float64(len(items)))
+	outputs, err := m.process(items)
+	if err == nil {
+		err = m.output(outputs)
+	}
+	if err != nil {
+		eventsDropped.WithLabelValues(m.Name(), m.id).Add(float64(len(items)))
+		return fmt.Errorf("while polling module '%s', %v", m.Name(), err)
+	}
+	return nil
+}
+
+// pollEvents returns the events in the perf buffer of the eBPF program.
+func (m *Module) pollEvents() ([][]byte, error) {
 	perfBufName := m.modulePB.Ebpf.PerfBufferName
 	namedData := m.ebpf.Poll()
 	dataItems, found := namedData[perfBufName]
 	if !found {
-		return fmt.Errorf("the only perf buffer '%s' is not found in polled data, %v", perfBufName, namedData)
+		return nil, fmt.Errorf("the only perf buffer '%s' is not found in polled data, %v", perfBufName, namedData)
 	}
+	return dataItems, nil
+}
 
+// process runs the WASM function with each event, returns the outputs in the order of the events.
+func (m *Module) process(dataItems [][]byte) ([][]byte, error) {
 	outputDataItems := make([][]byte, 0)
 
 	for _, data := range dataItems {
 		_, err := wasm.MallocInputBuf(m.wasm, int32(len(data)))
 		if err != nil {
-			return fmt.Errorf(
+			return nil, fmt.Errorf(
 				"while copying polled data from eBPF to WASM, failed to malloc input buffer in WASM, error: %v",
 				err,
 			)
@@ -120,14 +294,16 @@ func (m *Module) Poll() error {
 
 		err = wasm.CopyToInputBuf(m.wasm, data)
 		if err != nil {
-			return fmt.Errorf(
+			return nil, fmt.Errorf(
 				"while processing data in eBPF+WASM module, failed to copy data to WASM input buffer, error: %v",
 				err,
 			)
 		}
 		// The WASM function should have malloced the output buffer.
 		// So here we do not malloc output buffer.
+		start := time.Now()
 		_, err = m.wasm.Run(m.modulePB.Wasm.FnName)
+		wasmCallDuration.WithLabelValues(m.Name(), m.id).Observe(time.Since(start).Seconds())
 
 		// Ensure that we free the output buffer before returning.
 		// Assume the output buffer has already been allocated in the WASM function.
@@ -139,7 +315,7 @@ func (m *Module) Poll() error {
 		}()
 
 		if err != nil {
-			return fmt.Errorf(
+			return nil, fmt.Errorf(
 				"while processing data in eBPF+WASM module, failed to run WASM function '%s', error: %v",
 				m.modulePB.Wasm.FnName,
 				err,
@@ -148,26 +324,86 @@ func (m *Module) Poll() error {
 		// data encoding paradigm is in m.moduleDB.WasmOutputEncoding
 		data, err := wasm.ReadFromOutputBuf(m.wasm)
 		if err != nil {
-			return fmt.Errorf("while processing data in eBPF+WASM module, failed to read output, error: %v", err)
+			return nil, fmt.Errorf("while processing data in eBPF+WASM module, failed to read output, error: %v", err)
 		}
 		outputDataItems = append(outputDataItems, data)
 	}
-	err := m.outputJSON(outputDataItems)
-	if err != nil {
-		return fmt.Errorf("while polling module '%s', failed to write JSON to database, error: %v", m.Name(), err)
+	return outputDataItems, nil
+}
+
+// output decodes the WASM outputs into records, and writes them to the sink.
+func (m *Module) output(outputDataItems [][]byte) error {
+	if m.modulePB.WasmOutputEncoding == modulepb.Module_TLV {
+		if err := m.outputTLV(outputDataItems); err != nil {
+			return fmt.Errorf("failed to write TLV to sink, error: %v", err)
+		}
+		return nil
+	}
+	if err := m.outputJSON(outputDataItems); err != nil {
+		return fmt.Errorf("failed to write JSON to sink, error: %v", err)
 	}
 	return nil
 }
 
 func (m *Module) outputJSON(jsons [][]byte) error {
+	records := make([][]interface{}, 0, len(jsons))
+	schema := m.outputSchema
+	if m.enricher != nil {
+		schema = m.enrichedSchema
+	}
 	for _, json := range jsons {
 		// eBPF perf buffer might output data with trailing null characters.
 		// If the perf buffer output is treated as JSON directly, then the output with trailing null characters would
 		// fail to be inserted into the database.
 		json = bytes.TrimC(json)
-		err := m.pgClient.WriteRecord([]interface{}{json}, m.outputSchema)
+		record := []interface{}{json}
+		if m.enricher != nil {
+			record = append(record, m.enricher.Values(json)...)
+		}
+		records = append(records, record)
+	}
+	if err := m.sink.Write(records, schema); err != nil {
+		return fmt.Errorf("while outputing JSON data, failed to write records, error: %v", err)
+	}
+	recordsWritten.WithLabelValues(m.Name(), m.id).Add(float64(len(records)))
+	return nil
+}
+
+func (m *Module) outputTLV(items [][]byte) error {
+	records := make([][]interface{}, 0, len(items))
+	schema := m.outputSchema
+	if m.enricher != nil {
+		schema = m.enrichedSchema
+	}
+	for _, item := range items {
+		record, err := decodeTLV(item, m.outputSchema.Columns)
 		if err != nil {
-			return fmt.Errorf("while outputing JSON data, failed to write record to database, error: %v", err)
+			return fmt.Errorf("while outputing TLV data, failed to decode, error: %v", err)
+		}
+		if m.enricher != nil {
+			record = append(record, m.enricher.ValuesForPID(tlvPID(m.outputSchema.Columns, record))...)
+		}
+		records = append(records, record)
+	}
+	if err := m.sink.Write(records, schema); err != nil {
+		return fmt.Errorf("while outputing TLV data, failed to write records, error: %v", err)
+	}
+	recordsWritten.WithLabelValues(m.Name(), m.id).Add(float64(len(records)))
+	return nil
+}
+
+// tlvPID returns the value of the 'pid' column of the decoded TLV record, or nil if there is no such column.
+func tlvPID(columns []pg.Column, record []interface{}) *int32 {
+	for i, col := range columns {
+		if col.Name != pidField {
+			continue
+		}
+		switch v := record[i].(type) {
+		case int32:
+			return &v
+		case int64:
+			pid := int32(v)
+			return &pid
 		}
 	}
 	return nil
@@ -16,7 +16,9 @@
 package driver
 
 import (
+	"context"
 	"fmt"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -31,6 +33,7 @@ import (
 	ebpfpb "github.com/tricorder/src/pb/module/ebpf"
 	wasmpb "github.com/tricorder/src/pb/module/wasm"
 	testutils "github.com/tricorder/src/testing/bazel"
+	"github.com/tricorder/src/utils/pg"
 )
 
 // Tests that module is deployed and data can be polled from perf buffer and write to wasm runtime.
@@ -84,7 +87,7 @@ func TestModuleDeploymentAndPoll(t *testing.T) {
 	require.Nil(err)
 	defer func() { assert.Nil(cleaner()) }()
 
-	m, err := Deploy(modPB, pgClient)
+	m, err := Deploy("module", modPB, pgClient, nil, nil, nil, "")
 	require.Nil(err)
 
 	// Starship would create this table in the API server. We have to create table manually here in test.
@@ -155,7 +158,7 @@ func TestSampleEventModule(t *testing.T) {
 	require.Nil(err)
 	defer func() { assert.Nil(cleaner()) }()
 
-	m, err := Deploy(modPB, pgClient)
+	m, err := Deploy("module", modPB, pgClient, nil, nil, nil, "")
 	require.Nil(err)
 
 	// Starship would create this table in the API server. We have to create table manually here in test.
@@ -171,3 +174,65 @@ func TestSampleEventModule(t *testing.T) {
 	require.Greater(len(jsons), 0)
 	assert.Equal(`{"D": 0, "F": 0, "I": 0, "L": 0, "Comm": ""}`, jsons[0][0])
 }
+
+// blockingSink blocks the writes until ctx is done, like a sink whose destination hangs instead of failing.
+type blockingSink struct {
+	ctx    context.Context
+	writes int32
+}
+
+func (s *blockingSink) Write(_ [][]interface{}, _ *pg.Schema) error {
+	atomic.AddInt32(&s.writes, 1)
+	<-s.ctx.Done()
+	return s.ctx.Err()
+}
+
+func (s *blockingSink) Close() error {
+	return nil
+}
+
+// Tests that stopping the pipeline cancels the writes of a blocked sink after the drain timeout, which unblocks the
+// producer of the output buffer, instead of waiting forever.
+func TestStopPipelineWithBlockingSink(t *testing.T) {
+	assert := assert.New(t)
+
+	m := &Module{
+		modulePB:     &modulepb.Module{Name: "blocked"},
+		outputSchema: &pg.Schema{Name: "blocked", Columns: []pg.Column{{Name: "data", Type: pg.JSONB}}},
+		outputs:      NewDataBuffer(16, Block),
+		stop:         make(chan struct{}),
+		drainTimeout: 100 * time.Millisecond,
+	}
+	m.writeCtx, m.cancelWrites = context.WithCancel(context.Background())
+	sink := &blockingSink{ctx: m.writeCtx}
+	m.sink = sink
+
+	m.pipeline.Add(2)
+	// Fills the output buffer until the pipeline is stopped, as processLoop() does.
+	go func() {
+		defer m.pipeline.Done()
+		defer m.outputs.Close()
+		for {
+			select {
+			case <-m.stop:
+				return
+			default:
+			}
+			_ = m.outputs.Produce([][]byte{[]byte(`{"a":1}`)})
+		}
+	}()
+	go m.outputLoop()
+	assert.Eventually(func() bool { return atomic.LoadInt32(&sink.writes) > 0 }, time.Second, 10*time.Millisecond)
+
+	stopped := make(chan struct{})
+	go func() {
+		m.stopPipeline()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		assert.Fail("stopPipeline() did not return after the drain timeout")
+	}
+	assert.ErrorIs(m.writeCtx.Err(), context.Canceled)
+}
@@ -16,36 +16,87 @@
 package driver
 
 import (
-	"github.com/enriquebris/goconcurrentqueue"
+	"sync"
+
 	"github.com/pkg/errors"
 )
 
-const DefaultBufferSize = 1000
+// The default capacity in bytes of the queues between the stages of a module's pipeline.
+const DefaultBufferSize = 16 * 1024 * 1024
 
 var (
 	ErrEmpty  = errors.New("cannot read data when the queue is empty")
 	ErrFull   = errors.New("cannot write data when the queue is full")
 	ErrClosed = errors.New("cannot enqueue or dequeue when the queue is closed")
-	ErrData   = errors.New("cannot enqueue or dequeue when element types un-matched")
 )
 
+// DropPolicy decides what Enqueue() does if the new element does not fit into the queue's capacity.
+type DropPolicy int
+
+const (
+	// DropNewest rejects the new element with ErrFull.
+	DropNewest DropPolicy = iota
+
+	// DropOldest drops the oldest elements until the new element fits.
+	DropOldest
+
+	// Block waits until the new element fits, or the queue is closed.
+	Block
+)
+
+// QueueStats describes the content and the traffic of a queue.
+type QueueStats struct {
+	// The number and the total bytes of the elements in the queue.
+	Len   int
+	Bytes int
+
+	// The number of elements enqueued, dequeued, and dropped by the drop policy, since the queue was created.
+	Enqueued uint64
+	Dequeued uint64
+	Dropped  uint64
+}
+
+// Queue is a FIFO queue of byte slices, bounded by the total bytes of its elements. It is safe for concurrent use.
 type Queue struct {
-	// The maximum buffer event size.
+	// The maximum total bytes of the elements.
 	capacity int
 
-	// The size of the elements of this queue in bytes
+	policy DropPolicy
+
+	mu sync.Mutex
+
+	// Signaled when an element is enqueued, or the queue is closed.
+	notEmpty *sync.Cond
+
+	// Signaled when an element is dequeued, or the queue is closed.
+	notFull *sync.Cond
+
+	// Underlying buffer, the oldest element first.
+	buffer [][]byte
+
+	// The size of the elements of this queue in bytes.
 	sizeByte int
 
-	// Underlying buffer
-	buffer *goconcurrentqueue.FIFO
+	closed bool
+
+	enqueued, dequeued, dropped uint64
+}
+
+// NewQueue returns a queue of the capacity in bytes, which rejects the elements that do not fit.
+func NewQueue(capacity int) *Queue {
+	return NewQueueWithPolicy(capacity, DropNewest)
 }
 
-// NewQueue returns a sized queue and return an error if has errors during creation.
-func NewQueue(bufferSize int) *Queue {
-	return &Queue{
-		capacity: bufferSize,
-		buffer:   goconcurrentqueue.NewFIFO(),
+// NewQueueWithPolicy returns a queue of the capacity in bytes, which handles the elements that do not fit with the
+// policy.
+func NewQueueWithPolicy(capacity int, policy DropPolicy) *Queue {
+	q := &Queue{
+		capacity: capacity,
+		policy:   policy,
 	}
+	q.notEmpty = sync.NewCond(&q.mu)
+	q.notFull = sync.NewCond(&q.mu)
+	return q
 }
 
 type QueueElement struct {
@@ -53,29 +104,105 @@ type QueueElement struct {
 	Msg  []byte
 }
 
+// Enqueue appends data to the queue. Returns ErrFull if data is dropped, and ErrClosed if the queue is closed.
+// Data larger than the capacity is always dropped, as it never fits.
 func (q *Queue) Enqueue(data []byte) error {
-	newSize := q.sizeByte + len(data)
-	if newSize > q.capacity {
-		return ErrFull
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.closed {
+		return ErrClosed
 	}
-	err := q.buffer.Enqueue(data)
-	if err != nil {
+	if len(data) > q.capacity {
+		q.dropped++
 		return ErrFull
 	}
-	q.sizeByte = newSize
+	for q.sizeByte+len(data) > q.capacity {
+		switch q.policy {
+		case DropOldest:
+			q.pop()
+			q.dropped++
+		case Block:
+			q.notFull.Wait()
+			if q.closed {
+				return ErrClosed
+			}
+		default:
+			q.dropped++
+			return ErrFull
+		}
+	}
+	q.buffer = append(q.buffer, data)
+	q.sizeByte += len(data)
+	q.enqueued++
+	q.notEmpty.Signal()
 	return nil
 }
 
+// Dequeue removes and returns the oldest element. Returns ErrEmpty if the queue is empty, or ErrClosed if the queue is
+// closed and empty.
 func (q *Queue) Dequeue() ([]byte, error) {
-	e, err := q.buffer.Dequeue()
-	if err != nil {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if len(q.buffer) == 0 {
+		if q.closed {
+			return nil, ErrClosed
+		}
 		return nil, ErrEmpty
 	}
-	data, ok := e.([]byte)
-	if !ok {
-		return nil, ErrData
+	q.dequeued++
+	return q.pop(), nil
+}
+
+// DequeueAll waits until the queue is not empty, then removes and returns all elements, the oldest first.
+// Returns ErrClosed if the queue is closed and empty.
+func (q *Queue) DequeueAll() ([][]byte, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for len(q.buffer) == 0 {
+		if q.closed {
+			return nil, ErrClosed
+		}
+		q.notEmpty.Wait()
+	}
+	elems := q.buffer
+	q.buffer = nil
+	q.sizeByte = 0
+	q.dequeued += uint64(len(elems))
+	q.notFull.Broadcast()
+	return elems, nil
+}
+
+// pop removes and returns the oldest element, the caller must hold the lock and ensure the queue is not empty.
+func (q *Queue) pop() []byte {
+	data := q.buffer[0]
+	q.buffer[0] = nil
+	q.buffer = q.buffer[1:]
+	q.sizeByte -= len(data)
+	q.notFull.Broadcast()
+	return data
+}
+
+// Close closes the queue, the elements in the queue can still be dequeued, but no more can be enqueued.
+// The waiting Enqueue() and DequeueAll() return ErrClosed.
+func (q *Queue) Close() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.closed = true
+	q.notEmpty.Broadcast()
+	q.notFull.Broadcast()
+}
+
+// Stats returns the statistics of the queue.
+func (q *Queue) Stats() QueueStats {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return QueueStats{
+		Len:      len(q.buffer),
+		Bytes:    q.sizeByte,
+		Enqueued: q.enqueued,
+		Dequeued: q.dequeued,
+		Dropped:  q.dropped,
 	}
-	return data, nil
 }
 
 func (q *Queue) Capacity() int {
@@ -83,6 +210,8 @@ func (q *Queue) Capacity() int {
 }
 
 func (q *Queue) SizeByte() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
 	return q.sizeByte
 }
 
@@ -90,10 +219,13 @@ func (q *Queue) IsFull() bool {
 	return q.SizeByte() >= q.Capacity()
 }
 
+// IsEmpty returns true if the queue has no elements, the elements of 0 bytes count as well.
 func (q *Queue) IsEmpty() bool {
-	return q.SizeByte() == 0
+	return !q.HasData()
 }
 
 func (q *Queue) HasData() bool {
-	return q.SizeByte() >= 0
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.buffer) > 0
 }
@@ -16,9 +16,13 @@
 package driver
 
 import (
+	"fmt"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 // TestQueue tests Enqueue and Dequeue methods.
@@ -55,3 +59,135 @@ func TestQueue(t *testing.T) {
 	assert.Nil(err)
 	assert.Equal(data, []byte{})
 }
+
+// Tests that the size of the queue is released by Dequeue.
+func TestQueueSize(t *testing.T) {
+	assert := assert.New(t)
+
+	q := NewQueue(10)
+	assert.True(q.IsEmpty())
+	assert.False(q.HasData())
+	assert.Nil(q.Enqueue([]byte("0123456789")))
+	assert.True(q.IsFull())
+	assert.Equal(ErrFull, q.Enqueue([]byte("0")))
+
+	_, err := q.Dequeue()
+	assert.Nil(err)
+	assert.Equal(0, q.SizeByte())
+	assert.True(q.IsEmpty())
+	assert.Nil(q.Enqueue([]byte("0123456789")))
+
+	// An empty element is still an element.
+	q = NewQueue(10)
+	assert.Nil(q.Enqueue([]byte{}))
+	assert.True(q.HasData())
+
+	// An element larger than the capacity is always dropped.
+	assert.Equal(ErrFull, NewQueueWithPolicy(10, DropOldest).Enqueue(make([]byte, 11)))
+}
+
+// Tests that DropOldest drops the oldest elements to make room for the new one.
+func TestQueueDropOldest(t *testing.T) {
+	assert := assert.New(t)
+
+	q := NewQueueWithPolicy(10, DropOldest)
+	assert.Nil(q.Enqueue([]byte("0123")))
+	assert.Nil(q.Enqueue([]byte("4567")))
+	assert.Nil(q.Enqueue([]byte("89ab")))
+
+	elems, err := q.DequeueAll()
+	assert.Nil(err)
+	assert.Equal([][]byte{[]byte("4567"), []byte("89ab")}, elems)
+	assert.Equal(QueueStats{Enqueued: 3, Dequeued: 2, Dropped: 1}, q.Stats())
+}
+
+// Tests that Block waits for room, and that Close wakes up the waiting callers.
+func TestQueueBlockAndClose(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	q := NewQueueWithPolicy(4, Block)
+	require.Nil(q.Enqueue([]byte("0123")))
+	enqueued := make(chan error)
+	go func() {
+		enqueued <- q.Enqueue([]byte("4567"))
+	}()
+	select {
+	case <-enqueued:
+		require.Fail("Enqueue should wait until the queue has room")
+	case <-time.After(10 * time.Millisecond):
+	}
+	data, err := q.Dequeue()
+	assert.Nil(err)
+	assert.Equal([]byte("0123"), data)
+	assert.Nil(<-enqueued)
+
+	go func() {
+		enqueued <- q.Enqueue([]byte("89ab"))
+	}()
+	time.Sleep(10 * time.Millisecond)
+	q.Close()
+	assert.Equal(ErrClosed, <-enqueued)
+
+	// The remaining elements are still dequeued after Close.
+	elems, err := q.DequeueAll()
+	assert.Nil(err)
+	assert.Equal([][]byte{[]byte("4567")}, elems)
+	_, err = q.DequeueAll()
+	assert.Equal(ErrClosed, err)
+	_, err = q.Dequeue()
+	assert.Equal(ErrClosed, err)
+	assert.Equal(ErrClosed, q.Enqueue([]byte("c")))
+}
+
+// Tests that concurrent producers and consumers neither lose nor duplicate elements, run with -race.
+func TestQueueConcurrent(t *testing.T) {
+	assert := assert.New(t)
+
+	const producers, elemsPerProducer = 4, 1000
+	q := NewQueueWithPolicy(64, Block)
+
+	var wg sync.WaitGroup
+	for p := 0; p < producers; p++ {
+		wg.Add(1)
+		go func(p int) {
+			defer wg.Done()
+			for i := 0; i < elemsPerProducer; i++ {
+				assert.Nil(q.Enqueue([]byte(fmt.Sprintf("%d-%d", p, i))))
+			}
+		}(p)
+	}
+
+	var mu sync.Mutex
+	received := make(map[string]int)
+	var consumers sync.WaitGroup
+	for c := 0; c < 2; c++ {
+		consumers.Add(1)
+		go func() {
+			defer consumers.Done()
+			for {
+				elems, err := q.DequeueAll()
+				if err == ErrClosed {
+					return
+				}
+				mu.Lock()
+				for _, e := range elems {
+					received[string(e)]++
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	q.Close()
+	consumers.Wait()
+
+	assert.Len(received, producers*elemsPerProducer)
+	for elem, count := range received {
+		assert.Equal(1, count, elem)
+	}
+	stats := q.Stats()
+	assert.Equal(uint64(producers*elemsPerProducer), stats.Enqueued)
+	assert.Equal(stats.Enqueued, stats.Dequeued)
+	assert.Equal(0, stats.Bytes)
+}
@@ -0,0 +1,65 @@
+// Copyright (C) 2023  Tricorder Observability
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package driver
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// The operational metrics of the agent's modules, registered to prometheus.DefaultRegisterer, and exposed on the
+// agent's /metrics endpoint along with the modules' own metrics, see MetricsRegistry.
+// The metrics of a module are labelled with its name, and its ID, as modules of different tenants can have the same
+// name.
+var (
+	eventsPolled = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "starship_agent_module_events_polled_total",
+		Help: "The number of events polled from the perf buffer of the eBPF program of a module.",
+	}, []string{"module", "module_id"})
+	eventsDropped = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "starship_agent_module_events_dropped_total",
+		Help: "The number of polled events of a module that failed to be processed by WASM or written to the sink.",
+	}, []string{"module", "module_id"})
+	recordsWritten = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "starship_agent_module_records_written_total",
+		Help: "The number of output records of a module written to its sink.",
+	}, []string{"module", "module_id"})
+	wasmCallDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "starship_agent_wasm_call_duration_seconds",
+		Help:    "The latency of calling the WASM function of a module with one polled event.",
+		Buckets: prometheus.ExponentialBuckets(0.00001, 4, 10),
+	}, []string{"module", "module_id"})
+	pgWriteDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name: "starship_agent_pg_write_duration_seconds",
+		Help: "The latency of writing a batch of output records of a module to Postgres.",
+	}, []string{"module", "module_id"})
+	pgWriteErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "starship_agent_pg_write_errors_total",
+		Help: "The number of failed writes of a batch of output records of a module to Postgres.",
+	}, []string{"module", "module_id"})
+	queueBytes = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "starship_agent_module_queue_bytes",
+		Help: "The bytes of the events waiting to be processed by WASM, and of the WASM outputs waiting to be written.",
+	}, []string{"module", "module_id", "queue"})
+	spoolBytes = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "starship_agent_spool_bytes",
+		Help: "The bytes of the output spooled on disk while the sink is unavailable.",
+	}, []string{"spool"})
+	spoolDroppedBytes = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "starship_agent_spool_dropped_bytes_total",
+		Help: "The bytes of the spooled output dropped as the spool exceeded its limit, or could not be read.",
+	}, []string{"spool"})
+)
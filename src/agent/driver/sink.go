@@ -0,0 +1,480 @@
+// Copyright (C) 2023  Tricorder Observability
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package driver
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+
+	commonpb "github.com/tricorder/src/pb/module/common"
+	"github.com/tricorder/src/utils/pg"
+)
+
+// Sink writes the output records of a module.
+type Sink interface {
+	// Write writes the records polled at once, the values of each record are in the order of the schema's columns.
+	Write(records [][]interface{}, schema *pg.Schema) error
+
+	// Close releases the resources held by the sink, it is not used afterwards.
+	Close() error
+}
+
+// ErrSinkUnavailable is wrapped by the errors of Sink.Write if the destination cannot be reached, in which case none
+// of the records were written, and they can be written again later.
+var ErrSinkUnavailable = errors.New("the sink is unavailable")
+
+// The defaults of the rotation of the FILE sink.
+const (
+	defaultSinkMaxBytes = 100 * 1024 * 1024
+	defaultSinkMaxFiles = 5
+)
+
+// SinkEnv holds what the sinks of a module need besides their specs.
+type SinkEnv struct {
+	// The ID and the name of the module whose output is written. The ID identifies the module's metrics, as modules of
+	// different tenants can have the same name.
+	ModuleID   string
+	ModuleName string
+
+	// The fields of the module's output, which declare the metrics of the metrics sinks.
+	Fields []*commonpb.DataField
+
+	// Writes the output of the POSTGRES sink.
+	PGClient *pg.Client
+
+	// Exposes the metrics of the PROMETHEUS sink.
+	Metrics *MetricsRegistry
+
+	// Optional, buffers the output of the POSTGRES and OTLP sinks while they are unavailable.
+	Spool *Spool
+
+	// The directory of the files of the FILE sinks, whose paths are file names in it. FILE sinks are rejected if empty.
+	FileDir string
+
+	// Optional, cancels the writes of the POSTGRES and OTLP sinks, which wait for the network.
+	Context context.Context
+}
+
+// NewSink returns the sink described by spec. spec can be nil, then the records are written to Postgres.
+func NewSink(spec *commonpb.Sink, env SinkEnv) (Sink, error) {
+	ctx := env.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	switch spec.GetType() {
+	case commonpb.Sink_POSTGRES:
+		if env.PGClient == nil {
+			return nil, fmt.Errorf("while creating sink, Postgres client is not set")
+		}
+		sink := &pgSink{ctx: ctx, client: env.PGClient, moduleID: env.ModuleID, moduleName: env.ModuleName}
+		return spooled(sink, env.Spool), nil
+	case commonpb.Sink_FILE:
+		path, err := sinkFilePath(env.FileDir, spec.Path)
+		if err != nil {
+			return nil, fmt.Errorf("while creating sink, %v", err)
+		}
+		maxBytes, maxFiles := spec.MaxBytes, int(spec.MaxFiles)
+		if maxBytes <= 0 {
+			maxBytes = defaultSinkMaxBytes
+		}
+		if maxFiles <= 0 {
+			maxFiles = defaultSinkMaxFiles
+		}
+		w, err := newRotatingFile(path, maxBytes, maxFiles)
+		if err != nil {
+			return nil, fmt.Errorf("while creating sink, %v", err)
+		}
+		return &jsonLinesSink{w: w, closer: w}, nil
+	case commonpb.Sink_STDOUT:
+		return &jsonLinesSink{w: os.Stdout}, nil
+	case commonpb.Sink_OTLP:
+		if len(spec.Endpoint) == 0 {
+			return nil, fmt.Errorf("while creating sink, endpoint of OTLP sink is empty")
+		}
+		return spooled(newOTLPSink(ctx, spec.Endpoint, spec.Headers, env.ModuleName), env.Spool), nil
+	case commonpb.Sink_PROMETHEUS:
+		if env.Metrics == nil {
+			return nil, fmt.Errorf("while creating sink, metrics registry is not set")
+		}
+		s, err := newMetricsSink(env.ModuleID, env.ModuleName, env.Fields)
+		if err != nil {
+			return nil, fmt.Errorf("while creating sink, %v", err)
+		}
+		env.Metrics.add(env.ModuleID, s.registry)
+		s.onClose = func() error {
+			env.Metrics.remove(env.ModuleID, s.registry)
+			return nil
+		}
+		return s, nil
+	case commonpb.Sink_OTLP_METRICS:
+		if len(spec.Endpoint) == 0 {
+			return nil, fmt.Errorf("while creating sink, endpoint of OTLP_METRICS sink is empty")
+		}
+		s, err := newMetricsSink(env.ModuleID, env.ModuleName, env.Fields)
+		if err != nil {
+			return nil, fmt.Errorf("while creating sink, %v", err)
+		}
+		interval := defaultOTLPPushInterval
+		if spec.PushIntervalSeconds > 0 {
+			interval = time.Duration(spec.PushIntervalSeconds) * time.Second
+		}
+		pusher := newOTLPMetricsPusher(spec.Endpoint, spec.Headers, env.ModuleName, s.registry)
+		go pusher.run(interval)
+		s.onClose = pusher.stop
+		return s, nil
+	}
+	return nil, fmt.Errorf("while creating sink, sink type '%s' is not supported", spec.GetType())
+}
+
+// pgSink writes records to the data table of the module.
+type pgSink struct {
+	ctx    context.Context
+	client *pg.Client
+
+	// Label the latency and errors of the writes.
+	moduleID   string
+	moduleName string
+}
+
+func (s *pgSink) Write(records [][]interface{}, schema *pg.Schema) error {
+	start := time.Now()
+	defer func() {
+		pgWriteDuration.WithLabelValues(s.moduleName, s.moduleID).Observe(time.Since(start).Seconds())
+	}()
+	if err := s.client.WriteRecordsContext(s.ctx, records, schema); err != nil {
+		pgWriteErrors.WithLabelValues(s.moduleName, s.moduleID).Inc()
+		// Postgres did not respond before the writes were cancelled, pinging it would not respond either.
+		if s.ctx.Err() != nil || s.client.Ping() != nil {
+			return fmt.Errorf("while writing records to Postgres, %w, error: %v", ErrSinkUnavailable, err)
+		}
+		return fmt.Errorf("while writing records to Postgres, error: %v", err)
+	}
+	return nil
+}
+
+// Close does nothing, the client is shared by all modules.
+func (s *pgSink) Close() error {
+	return nil
+}
+
+// recordObject returns the record as a JSON object keyed by the column names.
+// The values of JSON columns are embedded as they are, if they are valid JSON.
+func recordObject(record []interface{}, schema *pg.Schema) (map[string]interface{}, error) {
+	if len(record) != len(schema.Columns) {
+		return nil, fmt.Errorf("the record's field count differs from the schema's column count, %d vs %d",
+			len(record), len(schema.Columns))
+	}
+	obj := make(map[string]interface{}, len(record))
+	for i, col := range schema.Columns {
+		value := record[i]
+		if col.Type == pg.JSON || col.Type == pg.JSONB {
+			if data, ok := value.([]byte); ok && json.Valid(data) {
+				value = json.RawMessage(data)
+			}
+		}
+		obj[col.Name] = value
+	}
+	return obj, nil
+}
+
+// jsonLinesSink writes each record as a JSON object in one line.
+type jsonLinesSink struct {
+	mu sync.Mutex
+	w  io.Writer
+
+	// Optional, closes w.
+	closer io.Closer
+}
+
+func (s *jsonLinesSink) Write(records [][]interface{}, schema *pg.Schema) error {
+	var buf bytes.Buffer
+	for _, record := range records {
+		obj, err := recordObject(record, schema)
+		if err != nil {
+			return fmt.Errorf("while writing JSON lines, %v", err)
+		}
+		line, err := json.Marshal(obj)
+		if err != nil {
+			return fmt.Errorf("while writing JSON lines, failed to marshal record, error: %v", err)
+		}
+		buf.Write(line)
+		buf.WriteByte('\n')
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := s.w.Write(buf.Bytes()); err != nil {
+		return fmt.Errorf("while writing JSON lines, error: %v", err)
+	}
+	return nil
+}
+
+func (s *jsonLinesSink) Close() error {
+	if s.closer == nil {
+		return nil
+	}
+	return s.closer.Close()
+}
+
+// rotatingFile is a file that is renamed to path.1 before it grows over maxBytes, and a new file is created.
+// The previously rotated files are renamed to path.2, path.3 and so on, up to maxFiles, the older ones are removed.
+type rotatingFile struct {
+	path     string
+	maxBytes int64
+	maxFiles int
+
+	file *os.File
+	size int64
+}
+
+// sinkFilePath returns the path of the FILE sink's file in dir. The name must be a plain file name, and the file cannot
+// be a symlink, so that the sink, which renames and removes the rotated files, only touches the files in dir.
+func sinkFilePath(dir, name string) (string, error) {
+	if len(dir) == 0 {
+		return "", fmt.Errorf("FILE sinks are disabled, the directory of their files is not set")
+	}
+	if len(name) == 0 {
+		return "", fmt.Errorf("path of FILE sink is empty")
+	}
+	if name != filepath.Base(name) || name == "." || name == ".." {
+		return "", fmt.Errorf("path '%s' of FILE sink is not a file name", name)
+	}
+	realDir, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve directory of FILE sinks '%s', error: %v", dir, err)
+	}
+	path := filepath.Join(realDir, name)
+	info, err := os.Lstat(path)
+	if err == nil && !info.Mode().IsRegular() {
+		return "", fmt.Errorf("path '%s' of FILE sink is not a regular file", name)
+	}
+	if err != nil && !os.IsNotExist(err) {
+		return "", fmt.Errorf("failed to stat file '%s', error: %v", path, err)
+	}
+	return path, nil
+}
+
+func newRotatingFile(path string, maxBytes int64, maxFiles int) (*rotatingFile, error) {
+	f := &rotatingFile{path: path, maxBytes: maxBytes, maxFiles: maxFiles}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (f *rotatingFile) open() error {
+	// A symlink created in place of the file is not followed.
+	file, err := os.OpenFile(f.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE|syscall.O_NOFOLLOW, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to open file '%s', error: %v", f.path, err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("failed to stat file '%s', error: %v", f.path, err)
+	}
+	f.file = file
+	f.size = info.Size()
+	return nil
+}
+
+// Write writes data to the file, after rotating the file if data would grow it over maxBytes.
+// A non-empty file is rotated, so data larger than maxBytes is written to a file of its own.
+func (f *rotatingFile) Write(data []byte) (int, error) {
+	if f.size > 0 && f.size+int64(len(data)) > f.maxBytes {
+		if err := f.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := f.file.Write(data)
+	f.size += int64(n)
+	return n, err
+}
+
+func (f *rotatingFile) rotate() error {
+	if err := f.file.Close(); err != nil {
+		return fmt.Errorf("failed to close file '%s', error: %v", f.path, err)
+	}
+	rotatedPath := func(i int) string {
+		return f.path + "." + strconv.Itoa(i)
+	}
+	if err := os.Remove(rotatedPath(f.maxFiles)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove the oldest rotated file, error: %v", err)
+	}
+	for i := f.maxFiles - 1; i >= 1; i-- {
+		if err := os.Rename(rotatedPath(i), rotatedPath(i+1)); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to rename rotated file, error: %v", err)
+		}
+	}
+	if err := os.Rename(f.path, rotatedPath(1)); err != nil {
+		return fmt.Errorf("failed to rotate file '%s', error: %v", f.path, err)
+	}
+	return f.open()
+}
+
+func (f *rotatingFile) Close() error {
+	return f.file.Close()
+}
+
+// otlpSink exports records as OTLP log records to an OTLP/HTTP logs endpoint, one request per Write().
+// The body of each log record is the record as a JSON object, see recordObject().
+// https://opentelemetry.io/docs/specs/otlp/#otlphttp-request
+type otlpSink struct {
+	ctx        context.Context
+	endpoint   string
+	headers    map[string]string
+	moduleName string
+	client     *http.Client
+
+	// Returns the current time, replaced in tests.
+	now func() time.Time
+}
+
+func newOTLPSink(ctx context.Context, endpoint string, headers map[string]string, moduleName string) *otlpSink {
+	return &otlpSink{
+		ctx:        ctx,
+		endpoint:   endpoint,
+		headers:    headers,
+		moduleName: moduleName,
+		client:     &http.Client{Timeout: 10 * time.Second},
+		now:        time.Now,
+	}
+}
+
+// The OTLP/JSON encoding of the logs, only the used fields are declared.
+// https://github.com/open-telemetry/opentelemetry-proto/blob/main/opentelemetry/proto/logs/v1/logs.proto
+type (
+	otlpLogsData struct {
+		ResourceLogs []otlpResourceLogs `json:"resourceLogs"`
+	}
+	otlpResourceLogs struct {
+		Resource  otlpResource    `json:"resource"`
+		ScopeLogs []otlpScopeLogs `json:"scopeLogs"`
+	}
+	otlpResource struct {
+		Attributes []otlpKeyValue `json:"attributes"`
+	}
+	otlpScopeLogs struct {
+		Scope      otlpScope       `json:"scope"`
+		LogRecords []otlpLogRecord `json:"logRecords"`
+	}
+	otlpScope struct {
+		Name string `json:"name"`
+	}
+	otlpLogRecord struct {
+		// A uint64 is encoded as a decimal string in OTLP/JSON.
+		TimeUnixNano string    `json:"timeUnixNano"`
+		Body         otlpValue `json:"body"`
+	}
+	otlpKeyValue struct {
+		Key   string    `json:"key"`
+		Value otlpValue `json:"value"`
+	}
+	otlpValue struct {
+		StringValue string `json:"stringValue"`
+	}
+)
+
+// The OTLP instrumentation scope of the exported log records.
+const otlpScopeName = "tricorder"
+
+func (s *otlpSink) Write(records [][]interface{}, schema *pg.Schema) error {
+	if len(records) == 0 {
+		return nil
+	}
+	logRecords := make([]otlpLogRecord, 0, len(records))
+	for _, record := range records {
+		obj, err := recordObject(record, schema)
+		if err != nil {
+			return fmt.Errorf("while exporting OTLP logs, %v", err)
+		}
+		body, err := json.Marshal(obj)
+		if err != nil {
+			return fmt.Errorf("while exporting OTLP logs, failed to marshal record, error: %v", err)
+		}
+		ts := s.now()
+		if ingestTime, ok := obj[pg.IngestTimeColumn].(time.Time); ok {
+			ts = ingestTime
+		}
+		logRecords = append(logRecords, otlpLogRecord{
+			TimeUnixNano: strconv.FormatInt(ts.UnixNano(), 10),
+			Body:         otlpValue{StringValue: string(body)},
+		})
+	}
+	data, err := json.Marshal(otlpLogsData{ResourceLogs: []otlpResourceLogs{{
+		Resource:  otlpModuleResource(s.moduleName),
+		ScopeLogs: []otlpScopeLogs{{Scope: otlpScope{Name: otlpScopeName}, LogRecords: logRecords}},
+	}}})
+	if err != nil {
+		return fmt.Errorf("while exporting OTLP logs, failed to marshal request, error: %v", err)
+	}
+	if err := postOTLP(s.ctx, s.client, s.endpoint, s.headers, data); err != nil {
+		return fmt.Errorf("while exporting OTLP logs, %w", err)
+	}
+	return nil
+}
+
+// otlpModuleResource returns the OTLP resource of the telemetry exported from the output of the module.
+func otlpModuleResource(moduleName string) otlpResource {
+	return otlpResource{Attributes: []otlpKeyValue{
+		{Key: "service.name", Value: otlpValue{StringValue: moduleName}},
+	}}
+}
+
+// postOTLP posts the OTLP/JSON request to the OTLP/HTTP endpoint, with the extra headers, until ctx is done.
+// The error wraps ErrSinkUnavailable if the endpoint cannot be reached, or asks to retry later.
+func postOTLP(ctx context.Context, client *http.Client, endpoint string, headers map[string]string, data []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("failed to create request, error: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("%w, failed to post to '%s', error: %v", ErrSinkUnavailable, endpoint, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		err := fmt.Errorf("posting to '%s' got status %d: %s", endpoint, resp.StatusCode, msg)
+		// The OTLP/HTTP specification allows to retry on these status codes.
+		switch resp.StatusCode {
+		case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable,
+			http.StatusGatewayTimeout:
+			return fmt.Errorf("%w, %v", ErrSinkUnavailable, err)
+		}
+		return err
+	}
+	return nil
+}
+
+// Close does nothing, every Write() is sent at once.
+func (s *otlpSink) Close() error {
+	return nil
+}
@@ -0,0 +1,199 @@
+// Copyright (C) 2023  Tricorder Observability
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package driver
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	commonpb "github.com/tricorder/src/pb/module/common"
+	"github.com/tricorder/src/utils/pg"
+)
+
+var testSinkSchema = &pg.Schema{
+	Name: "test_table",
+	Columns: []pg.Column{
+		{Name: "data", Type: pg.JSONB},
+		{Name: "comm", Type: pg.TEXT},
+		{Name: pg.IngestTimeColumn, Type: pg.TIMESTAMPTZ},
+	},
+}
+
+// Tests that NewSink() rejects incomplete specs.
+func TestNewSinkErrors(t *testing.T) {
+	assert := assert.New(t)
+
+	_, err := NewSink(nil, SinkEnv{ModuleName: "module"})
+	assert.ErrorContains(err, "Postgres client is not set")
+	_, err = NewSink(&commonpb.Sink{Type: commonpb.Sink_FILE}, SinkEnv{ModuleName: "module", FileDir: t.TempDir()})
+	assert.ErrorContains(err, "path of FILE sink is empty")
+	_, err = NewSink(&commonpb.Sink{Type: commonpb.Sink_OTLP}, SinkEnv{ModuleName: "module"})
+	assert.ErrorContains(err, "endpoint of OTLP sink is empty")
+	_, err = NewSink(&commonpb.Sink{Type: commonpb.Sink_PROMETHEUS}, SinkEnv{ModuleName: "module"})
+	assert.ErrorContains(err, "metrics registry is not set")
+	_, err = NewSink(&commonpb.Sink{Type: commonpb.Sink_OTLP_METRICS}, SinkEnv{ModuleName: "module"})
+	assert.ErrorContains(err, "endpoint of OTLP_METRICS sink is empty")
+	_, err = NewSink(&commonpb.Sink{Type: 100}, SinkEnv{ModuleName: "module"})
+	assert.ErrorContains(err, "sink type '100' is not supported")
+}
+
+// Tests that jsonLinesSink writes one JSON object per record, and embeds the JSON values.
+func TestJSONLinesSink(t *testing.T) {
+	assert := assert.New(t)
+
+	var buf bytes.Buffer
+	s := &jsonLinesSink{w: &buf}
+	ts := time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC)
+	err := s.Write([][]interface{}{
+		{[]byte(`{"pid":1}`), "curl", ts},
+		{nil, "bash", ts},
+	}, testSinkSchema)
+	assert.Nil(err)
+	assert.Equal(`{"_ingest_time":"2023-01-02T03:04:05Z","comm":"curl","data":{"pid":1}}`+"\n"+
+		`{"_ingest_time":"2023-01-02T03:04:05Z","comm":"bash","data":null}`+"\n", buf.String())
+
+	assert.ErrorContains(s.Write([][]interface{}{{"a"}}, testSinkSchema), "field count differs")
+	assert.Nil(s.Close())
+}
+
+// Tests that the FILE sink rotates the file, and keeps at most max_files rotated files.
+func TestFileSinkRotation(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "out.jsonl")
+	env := SinkEnv{ModuleName: "module", FileDir: dir}
+	spec := &commonpb.Sink{Type: commonpb.Sink_FILE, Path: "out.jsonl", MaxBytes: 100, MaxFiles: 2}
+	s, err := NewSink(spec, env)
+	require.Nil(err)
+
+	ts := time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC)
+	for _, comm := range []string{"a", "b", "c", "d"} {
+		// Each line is 64 bytes, so every line after the first one rotates the file.
+		require.Nil(s.Write([][]interface{}{{[]byte(`{"pid":1}`), comm, ts}}, testSinkSchema))
+	}
+	require.Nil(s.Close())
+
+	for suffix, comm := range map[string]string{"": "d", ".1": "c", ".2": "b"} {
+		data, err := os.ReadFile(path + suffix)
+		require.Nil(err)
+		assert.Contains(string(data), `"comm":"`+comm+`"`)
+	}
+	_, err = os.Stat(path + ".3")
+	assert.True(os.IsNotExist(err))
+
+	// Reopening appends to the existing file.
+	s, err = NewSink(&commonpb.Sink{Type: commonpb.Sink_FILE, Path: "out.jsonl"}, env)
+	require.Nil(err)
+	require.Nil(s.Write([][]interface{}{{nil, "e", ts}}, testSinkSchema))
+	require.Nil(s.Close())
+	data, err := os.ReadFile(path)
+	require.Nil(err)
+	assert.Equal(2, bytes.Count(data, []byte("\n")))
+}
+
+// Tests that the FILE sink only writes the files in the directory of the FILE sinks.
+func TestFileSinkDir(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	dir := t.TempDir()
+	outside := filepath.Join(t.TempDir(), "outside")
+	require.Nil(os.WriteFile(outside, []byte("keep"), 0o644))
+	require.Nil(os.Symlink(outside, filepath.Join(dir, "link.jsonl")))
+
+	newFileSink := func(dir, path string) error {
+		s, err := NewSink(&commonpb.Sink{Type: commonpb.Sink_FILE, Path: path}, SinkEnv{ModuleName: "module", FileDir: dir})
+		if err == nil {
+			assert.Nil(s.Close())
+		}
+		return err
+	}
+	assert.ErrorContains(newFileSink("", "out.jsonl"), "FILE sinks are disabled")
+	for _, path := range []string{outside, "../out.jsonl", "a/out.jsonl", ".."} {
+		assert.ErrorContains(newFileSink(dir, path), "is not a file name", path)
+	}
+	assert.ErrorContains(newFileSink(dir, "link.jsonl"), "is not a regular file")
+	data, err := os.ReadFile(outside)
+	require.Nil(err)
+	assert.Equal("keep", string(data))
+
+	// The directory itself can be a symlink.
+	link := filepath.Join(t.TempDir(), "dir")
+	require.Nil(os.Symlink(dir, link))
+	assert.Nil(newFileSink(link, "out.jsonl"))
+	_, err = os.Stat(filepath.Join(dir, "out.jsonl"))
+	assert.Nil(err)
+}
+
+// Tests that the OTLP sink posts the records as OTLP/JSON logs to a stand-in collector.
+func TestOTLPSink(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	var (
+		gotPath, gotContentType, gotAuth string
+		gotBody                          otlpLogsData
+		status                           = http.StatusOK
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		gotContentType = r.Header.Get("Content-Type")
+		gotAuth = r.Header.Get("Authorization")
+		body, _ := io.ReadAll(r.Body)
+		assert.Nil(json.Unmarshal(body, &gotBody))
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	s, err := NewSink(&commonpb.Sink{
+		Type:     commonpb.Sink_OTLP,
+		Endpoint: server.URL + "/v1/logs",
+		Headers:  map[string]string{"Authorization": "Bearer token"},
+	}, SinkEnv{ModuleName: "module"})
+	require.Nil(err)
+
+	ts := time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC)
+	require.Nil(s.Write([][]interface{}{{[]byte(`{"pid":1}`), "curl", ts}}, testSinkSchema))
+	assert.Equal("/v1/logs", gotPath)
+	assert.Equal("application/json", gotContentType)
+	assert.Equal("Bearer token", gotAuth)
+
+	require.Len(gotBody.ResourceLogs, 1)
+	assert.Equal([]otlpKeyValue{{Key: "service.name", Value: otlpValue{StringValue: "module"}}},
+		gotBody.ResourceLogs[0].Resource.Attributes)
+	require.Len(gotBody.ResourceLogs[0].ScopeLogs, 1)
+	assert.Equal(otlpScopeName, gotBody.ResourceLogs[0].ScopeLogs[0].Scope.Name)
+	assert.Equal([]otlpLogRecord{{
+		TimeUnixNano: "1672628645000000000",
+		Body:         otlpValue{StringValue: `{"_ingest_time":"2023-01-02T03:04:05Z","comm":"curl","data":{"pid":1}}`},
+	}}, gotBody.ResourceLogs[0].ScopeLogs[0].LogRecords)
+
+	status = http.StatusBadRequest
+	assert.ErrorContains(s.Write([][]interface{}{{nil, "curl", ts}}, testSinkSchema), "got status 400")
+	assert.Nil(s.Close())
+}
@@ -0,0 +1,499 @@
+// Copyright (C) 2023  Tricorder Observability
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package driver
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tricorder/src/utils/log"
+	"github.com/tricorder/src/utils/pg"
+)
+
+const (
+	// A spool is split into this many segments, the oldest segment is dropped when the spool exceeds its limit.
+	spoolSegments = 8
+
+	// Each batch in a segment file is a 4-byte length and a 4-byte CRC32 of the payload, followed by the payload.
+	spoolHeaderSize = 8
+
+	spoolSegmentExt  = ".seg"
+	spoolCursorFile  = "cursor"
+	spoolReplayEvery = 5 * time.Second
+)
+
+func init() {
+	// The types of the values of the records that are not registered by gob, see decodeTLVValue() and Enricher.
+	gob.Register([]interface{}{})
+	gob.Register(time.Time{})
+	gob.Register(netip.Addr{})
+}
+
+// spoolBatch is a batch of records written to the sink at once.
+type spoolBatch struct {
+	Schema  *pg.Schema
+	Records [][]interface{}
+}
+
+// Spool is a bounded on-disk write-ahead log of the output of a module, which buffers the records while the sink is
+// unavailable, and replays them in order once the sink recovers.
+//
+// The batches of records are appended to segment files named by their sequence numbers. If the spool exceeds its
+// limit, the oldest segments are dropped. The position of the next batch to replay is persisted, so the spool
+// survives agent restarts without replaying a batch twice.
+type Spool struct {
+	dir          string
+	maxBytes     int64
+	segmentBytes int64
+
+	// Guards the fields below, and serializes the writes to the sink, so the records are written in order.
+	mu sync.Mutex
+
+	// The sequence numbers of the segments, oldest first, the last one is appended to.
+	segments []uint64
+
+	// The size of each segment in bytes.
+	sizes map[uint64]int64
+
+	// The total size of the segments in bytes.
+	size int64
+
+	// The last segment, opened for appending, nil if it is not opened yet.
+	tail *os.File
+
+	// The offset of the next batch to replay in the oldest segment.
+	offset int64
+
+	// The sequence number of the next segment, which is never reused, so a stale cursor never points to it.
+	nextSeq uint64
+}
+
+// OpenSpool opens the spool in dir, which is created if it does not exist, and which holds at most maxBytes.
+// The batches left in dir by a previous agent are replayed.
+func OpenSpool(dir string, maxBytes int64) (*Spool, error) {
+	if maxBytes <= 0 {
+		return nil, fmt.Errorf("while opening spool at '%s', max bytes must be positive, got %d", dir, maxBytes)
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("while opening spool at '%s', failed to create directory, error: %v", dir, err)
+	}
+	s := &Spool{
+		dir:          dir,
+		maxBytes:     maxBytes,
+		segmentBytes: maxBytes / spoolSegments,
+		sizes:        make(map[uint64]int64),
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("while opening spool at '%s', failed to read directory, error: %v", dir, err)
+	}
+	for _, e := range entries {
+		seq, err := strconv.ParseUint(strings.TrimSuffix(e.Name(), spoolSegmentExt), 10, 64)
+		if err != nil || !strings.HasSuffix(e.Name(), spoolSegmentExt) {
+			continue
+		}
+		s.segments = append(s.segments, seq)
+	}
+	sort.Slice(s.segments, func(i, j int) bool { return s.segments[i] < s.segments[j] })
+
+	cursorSeq, cursorOffset := s.readCursor()
+	// The segments before the cursor were replayed, but not removed before the previous agent stopped.
+	for len(s.segments) > 0 && s.segments[0] < cursorSeq {
+		if err := os.Remove(s.segmentPath(s.segments[0])); err != nil {
+			return nil, fmt.Errorf("while opening spool at '%s', failed to remove replayed segment, error: %v", dir, err)
+		}
+		s.segments = s.segments[1:]
+	}
+	if len(s.segments) > 0 && s.segments[0] == cursorSeq {
+		s.offset = cursorOffset
+	}
+	s.nextSeq = cursorSeq + 1
+	if len(s.segments) > 0 && s.lastSegment() >= s.nextSeq {
+		s.nextSeq = s.lastSegment() + 1
+	}
+	for _, seq := range s.segments {
+		size, err := validSegmentSize(s.segmentPath(seq))
+		if err != nil {
+			return nil, fmt.Errorf("while opening spool at '%s', %v", dir, err)
+		}
+		s.sizes[seq] = size
+		s.size += size
+	}
+	if len(s.segments) > 0 {
+		// A batch torn by a crash is truncated, so the batches appended afterwards are readable.
+		last := s.segments[len(s.segments)-1]
+		tail, err := os.OpenFile(s.segmentPath(last), os.O_WRONLY, 0o644)
+		if err != nil {
+			return nil, fmt.Errorf("while opening spool at '%s', failed to open last segment, error: %v", dir, err)
+		}
+		if err := tail.Truncate(s.sizes[last]); err != nil {
+			tail.Close()
+			return nil, fmt.Errorf("while opening spool at '%s', failed to truncate last segment, error: %v", dir, err)
+		}
+		if _, err := tail.Seek(0, io.SeekEnd); err != nil {
+			tail.Close()
+			return nil, fmt.Errorf("while opening spool at '%s', failed to seek last segment, error: %v", dir, err)
+		}
+		s.tail = tail
+	}
+	spoolBytes.WithLabelValues(dir).Set(float64(s.size))
+	return s, nil
+}
+
+// Close closes the spool, the spooled batches are kept on disk for the next OpenSpool().
+func (s *Spool) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.tail == nil {
+		return nil
+	}
+	err := s.tail.Close()
+	s.tail = nil
+	return err
+}
+
+// Size returns the total bytes of the spooled batches.
+func (s *Spool) Size() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.size
+}
+
+// Write writes the records with write, if no batch is spooled. The records are appended to the spool instead, if
+// older batches are spooled, or if write fails with ErrSinkUnavailable.
+func (s *Spool) Write(records [][]interface{}, schema *pg.Schema,
+	write func([][]interface{}, *pg.Schema) error,
+) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.empty() {
+		err := write(records, schema)
+		if !errors.Is(err, ErrSinkUnavailable) {
+			return err
+		}
+		log.Warnf("Spooling output to '%s', error: %v", s.dir, err)
+	}
+	return s.append(&spoolBatch{Schema: schema, Records: records})
+}
+
+// Replay writes the spooled batches in order with write, until the spool is empty, or write fails with
+// ErrSinkUnavailable. A batch that fails with other errors is dropped, as writing it again would fail as well.
+func (s *Spool) Replay(write func([][]interface{}, *pg.Schema) error) error {
+	for {
+		done, err := s.replayOne(write)
+		if done || err != nil {
+			return err
+		}
+	}
+}
+
+// replayOne writes the oldest spooled batch with write, returns true if the spool is empty.
+func (s *Spool) replayOne(write func([][]interface{}, *pg.Schema) error) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	batch, next, err := s.peek()
+	if err != nil {
+		return false, err
+	}
+	if batch == nil {
+		return true, nil
+	}
+	if err := write(batch.Records, batch.Schema); err != nil {
+		if errors.Is(err, ErrSinkUnavailable) {
+			return false, err
+		}
+		log.Errorf("Dropping %d spooled records in '%s', error: %v", len(batch.Records), s.dir, err)
+	}
+	return false, s.advance(next)
+}
+
+func (s *Spool) segmentPath(seq uint64) string {
+	return filepath.Join(s.dir, fmt.Sprintf("%020d%s", seq, spoolSegmentExt))
+}
+
+func (s *Spool) empty() bool {
+	return len(s.segments) == 0 || (len(s.segments) == 1 && s.offset >= s.sizes[s.segments[0]])
+}
+
+// append appends the batch to the last segment, or to a new segment if the last one is full, and drops the oldest
+// segments if the spool exceeds its limit.
+func (s *Spool) append(batch *spoolBatch) error {
+	var payload bytes.Buffer
+	if err := gob.NewEncoder(&payload).Encode(batch); err != nil {
+		return fmt.Errorf("while spooling records, failed to encode, error: %v", err)
+	}
+	entry := make([]byte, spoolHeaderSize, spoolHeaderSize+payload.Len())
+	binary.LittleEndian.PutUint32(entry, uint32(payload.Len()))
+	binary.LittleEndian.PutUint32(entry[4:], crc32.ChecksumIEEE(payload.Bytes()))
+	entry = append(entry, payload.Bytes()...)
+	if int64(len(entry)) > s.maxBytes {
+		return fmt.Errorf("while spooling records, the batch of %d bytes exceeds the spool's limit %d bytes",
+			len(entry), s.maxBytes)
+	}
+
+	if s.tail == nil || s.full(int64(len(entry))) {
+		if err := s.roll(); err != nil {
+			return fmt.Errorf("while spooling records, %v", err)
+		}
+	}
+	if _, err := s.tail.Write(entry); err != nil {
+		return fmt.Errorf("while spooling records, failed to write segment, error: %v", err)
+	}
+	if err := s.tail.Sync(); err != nil {
+		return fmt.Errorf("while spooling records, failed to sync segment, error: %v", err)
+	}
+	s.sizes[s.lastSegment()] += int64(len(entry))
+	s.size += int64(len(entry))
+
+	for s.size > s.maxBytes && len(s.segments) > 1 {
+		oldest := s.segments[0]
+		log.Warnf("Spool '%s' exceeds %d bytes, dropping its oldest %d bytes", s.dir, s.maxBytes, s.sizes[oldest])
+		spoolDroppedBytes.WithLabelValues(s.dir).Add(float64(s.sizes[oldest] - s.offset))
+		if err := s.removeOldest(); err != nil {
+			return fmt.Errorf("while spooling records, %v", err)
+		}
+	}
+	spoolBytes.WithLabelValues(s.dir).Set(float64(s.size))
+	return nil
+}
+
+// full returns true if appending the bytes to the non-empty last segment exceeds the size of a segment.
+func (s *Spool) full(n int64) bool {
+	size := s.sizes[s.lastSegment()]
+	return size > 0 && size+n > s.segmentBytes
+}
+
+func (s *Spool) lastSegment() uint64 {
+	return s.segments[len(s.segments)-1]
+}
+
+// roll closes the last segment, and creates a new one to append to.
+func (s *Spool) roll() error {
+	seq := s.nextSeq
+	tail, err := os.OpenFile(s.segmentPath(seq), os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to create segment, error: %v", err)
+	}
+	if s.tail != nil {
+		if err := s.tail.Close(); err != nil {
+			log.Warnf("Failed to close segment of spool '%s', error: %v", s.dir, err)
+		}
+	}
+	s.tail = tail
+	s.nextSeq++
+	s.segments = append(s.segments, seq)
+	s.sizes[seq] = 0
+	return nil
+}
+
+// removeOldest removes the oldest segment, and moves the cursor to the start of the next segment.
+func (s *Spool) removeOldest() error {
+	oldest := s.segments[0]
+	if len(s.segments) == 1 {
+		if err := s.tail.Close(); err != nil {
+			log.Warnf("Failed to close segment of spool '%s', error: %v", s.dir, err)
+		}
+		s.tail = nil
+	}
+	if err := os.Remove(s.segmentPath(oldest)); err != nil {
+		return fmt.Errorf("failed to remove segment, error: %v", err)
+	}
+	s.size -= s.sizes[oldest]
+	delete(s.sizes, oldest)
+	s.segments = s.segments[1:]
+	s.offset = 0
+	return s.writeCursor()
+}
+
+// peek returns the oldest spooled batch and the offset after it, or nil if the spool is empty.
+// The segments that cannot be read any further are dropped.
+func (s *Spool) peek() (*spoolBatch, int64, error) {
+	for len(s.segments) > 0 {
+		oldest := s.segments[0]
+		if s.offset < s.sizes[oldest] {
+			batch, next, err := readSpoolBatch(s.segmentPath(oldest), s.offset)
+			if err == nil {
+				return batch, next, nil
+			}
+			log.Errorf("Dropping the unreadable rest of segment '%s', error: %v", s.segmentPath(oldest), err)
+			spoolDroppedBytes.WithLabelValues(s.dir).Add(float64(s.sizes[oldest] - s.offset))
+		}
+		if err := s.removeOldest(); err != nil {
+			return nil, 0, fmt.Errorf("while replaying spool '%s', %v", s.dir, err)
+		}
+		spoolBytes.WithLabelValues(s.dir).Set(float64(s.size))
+	}
+	return nil, 0, nil
+}
+
+// advance moves the cursor past the replayed batch, and removes the oldest segment once it is replayed.
+func (s *Spool) advance(next int64) error {
+	s.offset = next
+	if s.offset >= s.sizes[s.segments[0]] {
+		if err := s.removeOldest(); err != nil {
+			return fmt.Errorf("while replaying spool '%s', %v", s.dir, err)
+		}
+		spoolBytes.WithLabelValues(s.dir).Set(float64(s.size))
+		return nil
+	}
+	if err := s.writeCursor(); err != nil {
+		return fmt.Errorf("while replaying spool '%s', %v", s.dir, err)
+	}
+	return nil
+}
+
+// readCursor returns the segment and offset of the next batch to replay persisted by writeCursor(), or zeros if
+// there is none.
+func (s *Spool) readCursor() (uint64, int64) {
+	data, err := os.ReadFile(filepath.Join(s.dir, spoolCursorFile))
+	if err != nil {
+		return 0, 0
+	}
+	var seq uint64
+	var offset int64
+	if _, err := fmt.Sscanf(string(data), "%d %d", &seq, &offset); err != nil {
+		log.Warnf("Ignoring the invalid cursor of spool '%s', error: %v", s.dir, err)
+		return 0, 0
+	}
+	return seq, offset
+}
+
+// writeCursor persists the segment and offset of the next batch to replay.
+func (s *Spool) writeCursor() error {
+	var seq uint64
+	if len(s.segments) > 0 {
+		seq = s.segments[0]
+	}
+	path := filepath.Join(s.dir, spoolCursorFile)
+	// Renaming replaces the cursor atomically, so a crash leaves either the old or the new cursor.
+	if err := os.WriteFile(path+".tmp", []byte(fmt.Sprintf("%d %d", seq, s.offset)), 0o644); err != nil {
+		return fmt.Errorf("failed to write cursor, error: %v", err)
+	}
+	if err := os.Rename(path+".tmp", path); err != nil {
+		return fmt.Errorf("failed to rename cursor, error: %v", err)
+	}
+	return nil
+}
+
+// readSpoolBatch reads the batch at the offset of the segment file, returns the batch and the offset after it.
+func readSpoolBatch(path string, offset int64) (*spoolBatch, int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to open segment, error: %v", err)
+	}
+	defer f.Close()
+	header := make([]byte, spoolHeaderSize)
+	if _, err := f.ReadAt(header, offset); err != nil {
+		return nil, 0, fmt.Errorf("failed to read batch header at offset %d, error: %v", offset, err)
+	}
+	payload := make([]byte, binary.LittleEndian.Uint32(header))
+	if _, err := f.ReadAt(payload, offset+spoolHeaderSize); err != nil {
+		return nil, 0, fmt.Errorf("failed to read batch at offset %d, error: %v", offset, err)
+	}
+	if crc32.ChecksumIEEE(payload) != binary.LittleEndian.Uint32(header[4:]) {
+		return nil, 0, fmt.Errorf("batch at offset %d is corrupted", offset)
+	}
+	batch := new(spoolBatch)
+	if err := gob.NewDecoder(bytes.NewReader(payload)).Decode(batch); err != nil {
+		return nil, 0, fmt.Errorf("failed to decode batch at offset %d, error: %v", offset, err)
+	}
+	return batch, offset + spoolHeaderSize + int64(len(payload)), nil
+}
+
+// validSegmentSize returns the size of the complete batches at the start of the segment file, which excludes the
+// batch torn by a crash.
+func validSegmentSize(path string) (int64, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read segment, error: %v", err)
+	}
+	var size int64
+	for int64(len(data))-size >= spoolHeaderSize {
+		length := int64(binary.LittleEndian.Uint32(data[size:]))
+		end := size + spoolHeaderSize + length
+		if end > int64(len(data)) ||
+			crc32.ChecksumIEEE(data[size+spoolHeaderSize:end]) != binary.LittleEndian.Uint32(data[size+4:]) {
+			break
+		}
+		size = end
+	}
+	return size, nil
+}
+
+// spooledSink writes the records through the spool, and replays the spooled records periodically.
+type spooledSink struct {
+	sink  Sink
+	spool *Spool
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// spooled returns the sink that spools the records of sink while it is unavailable, or sink if spool is nil.
+func spooled(sink Sink, spool *Spool) Sink {
+	if spool == nil {
+		return sink
+	}
+	s := &spooledSink{
+		sink:  sink,
+		spool: spool,
+		stop:  make(chan struct{}),
+		done:  make(chan struct{}),
+	}
+	go s.run(spoolReplayEvery)
+	return s
+}
+
+func (s *spooledSink) run(interval time.Duration) {
+	defer close(s.done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+			if err := s.spool.Replay(s.sink.Write); err != nil {
+				log.Debugf("Failed to replay spool, error: %v", err)
+			}
+		}
+	}
+}
+
+func (s *spooledSink) Write(records [][]interface{}, schema *pg.Schema) error {
+	return s.spool.Write(records, schema, s.sink.Write)
+}
+
+// Close stops replaying, and closes the sink. The spool is not closed, as it outlives the deployment of a module
+// version, so the next version replays the records spooled by this one.
+func (s *spooledSink) Close() error {
+	close(s.stop)
+	<-s.done
+	return s.sink.Close()
+}
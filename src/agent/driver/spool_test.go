@@ -0,0 +1,233 @@
+// Copyright (C) 2023  Tricorder Observability
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package driver
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/tricorder/src/utils/pg"
+)
+
+// fakeSink records the written records, and fails with err if it is set.
+type fakeSink struct {
+	records [][]interface{}
+	err     error
+}
+
+func (s *fakeSink) Write(records [][]interface{}, _ *pg.Schema) error {
+	if s.err != nil {
+		return s.err
+	}
+	s.records = append(s.records, records...)
+	return nil
+}
+
+func (s *fakeSink) Close() error {
+	return nil
+}
+
+func textRecords(values ...string) [][]interface{} {
+	records := make([][]interface{}, 0, len(values))
+	for _, v := range values {
+		records = append(records, []interface{}{v})
+	}
+	return records
+}
+
+var testSpoolSchema = &pg.Schema{Name: "test_table", Columns: []pg.Column{{Name: "comm", Type: pg.TEXT}}}
+
+// Tests that the records are spooled while the sink is unavailable, and replayed in order once it recovers.
+func TestSpoolWriteAndReplay(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	spool, err := OpenSpool(t.TempDir(), 1024*1024)
+	require.Nil(err)
+	defer spool.Close()
+
+	sink := &fakeSink{}
+	require.Nil(spool.Write(textRecords("a"), testSpoolSchema, sink.Write))
+	assert.Equal(textRecords("a"), sink.records)
+	assert.Equal(int64(0), spool.Size())
+
+	sink.err = fmt.Errorf("%w, connection refused", ErrSinkUnavailable)
+	require.Nil(spool.Write(textRecords("b", "c"), testSpoolSchema, sink.Write))
+	require.Nil(spool.Write(textRecords("d"), testSpoolSchema, sink.Write))
+	assert.Greater(spool.Size(), int64(0))
+	assert.ErrorIs(spool.Replay(sink.Write), ErrSinkUnavailable)
+
+	sink.err = nil
+	// The newer records are spooled after the older ones, instead of being written before them.
+	require.Nil(spool.Write(textRecords("e"), testSpoolSchema, sink.Write))
+	assert.Equal(textRecords("a"), sink.records)
+
+	require.Nil(spool.Replay(sink.Write))
+	assert.Equal(textRecords("a", "b", "c", "d", "e"), sink.records)
+	assert.Equal(int64(0), spool.Size())
+
+	// Other errors are returned, as writing the records again would fail as well.
+	sink.err = errors.New("invalid record")
+	assert.ErrorContains(spool.Write(textRecords("f"), testSpoolSchema, sink.Write), "invalid record")
+	assert.Equal(int64(0), spool.Size())
+}
+
+// Tests that the spooled values keep their types.
+func TestSpoolValueTypes(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	spool, err := OpenSpool(t.TempDir(), 1024*1024)
+	require.Nil(err)
+	defer spool.Close()
+
+	ts := time.Unix(1700000000, 123).UTC()
+	record := []interface{}{
+		true, int32(1), int64(2), 3.5, "text", []byte(`{"a":1}`), ts, netip.MustParseAddr("10.0.0.1"), nil,
+		[]interface{}{int32(1), nil, int32(3)},
+	}
+	sink := &fakeSink{err: ErrSinkUnavailable}
+	require.Nil(spool.Write([][]interface{}{record}, testSpoolSchema, sink.Write))
+
+	var schema *pg.Schema
+	require.Nil(spool.Replay(func(records [][]interface{}, s *pg.Schema) error {
+		sink.records, schema = records, s
+		return nil
+	}))
+	assert.Equal([][]interface{}{record}, sink.records)
+	assert.Equal(testSpoolSchema, schema)
+}
+
+// Tests that a reopened spool replays the batches that were not replayed, and skips a batch torn by a crash.
+func TestSpoolReopen(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	dir := t.TempDir()
+	spool, err := OpenSpool(dir, 1024*1024)
+	require.Nil(err)
+	sink := &fakeSink{err: ErrSinkUnavailable}
+	for _, v := range []string{"a", "b", "c"} {
+		require.Nil(spool.Write(textRecords(v), testSpoolSchema, sink.Write))
+	}
+	replayed := 0
+	assert.ErrorIs(spool.Replay(func(records [][]interface{}, s *pg.Schema) error {
+		if replayed == 1 {
+			return ErrSinkUnavailable
+		}
+		replayed++
+		return nil
+	}), ErrSinkUnavailable)
+	require.Nil(spool.Close())
+
+	segments, err := filepath.Glob(filepath.Join(dir, "*"+spoolSegmentExt))
+	require.Nil(err)
+	require.Len(segments, 1)
+	f, err := os.OpenFile(segments[0], os.O_WRONLY|os.O_APPEND, 0o644)
+	require.Nil(err)
+	_, err = f.Write([]byte{100, 0, 0, 0, 1, 2})
+	require.Nil(err)
+	require.Nil(f.Close())
+
+	spool, err = OpenSpool(dir, 1024*1024)
+	require.Nil(err)
+	defer spool.Close()
+	require.Nil(spool.Write(textRecords("d"), testSpoolSchema, sink.Write))
+	sink.err = nil
+	require.Nil(spool.Replay(sink.Write))
+	assert.Equal(textRecords("b", "c", "d"), sink.records)
+}
+
+// Tests that the oldest batches are dropped when the spool exceeds its limit.
+func TestSpoolDropOldest(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	const maxBytes = 4096
+	spool, err := OpenSpool(t.TempDir(), maxBytes)
+	require.Nil(err)
+	defer spool.Close()
+
+	sink := &fakeSink{err: ErrSinkUnavailable}
+	for i := 0; i < 100; i++ {
+		require.Nil(spool.Write(textRecords(fmt.Sprint(i)), testSpoolSchema, sink.Write))
+		assert.LessOrEqual(spool.Size(), int64(maxBytes))
+	}
+	sink.err = nil
+	require.Nil(spool.Replay(sink.Write))
+	require.NotEmpty(sink.records)
+	assert.Less(len(sink.records), 100)
+	// The newest batches are kept, in order.
+	for i, record := range sink.records {
+		assert.Equal(fmt.Sprint(100-len(sink.records)+i), record[0])
+	}
+
+	_, err = OpenSpool(t.TempDir(), 0)
+	assert.ErrorContains(err, "max bytes must be positive")
+}
+
+// Tests that a spooled batch rejected by the sink is dropped, so it does not block the batches after it.
+func TestSpoolReplayDropsRejectedBatch(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	spool, err := OpenSpool(t.TempDir(), 1024*1024)
+	require.Nil(err)
+	defer spool.Close()
+
+	sink := &fakeSink{err: ErrSinkUnavailable}
+	require.Nil(spool.Write(textRecords("bad"), testSpoolSchema, sink.Write))
+	require.Nil(spool.Write(textRecords("good"), testSpoolSchema, sink.Write))
+	require.Nil(spool.Replay(func(records [][]interface{}, s *pg.Schema) error {
+		if records[0][0] == "bad" {
+			return errors.New("invalid record")
+		}
+		sink.records = append(sink.records, records...)
+		return nil
+	}))
+	assert.Equal(textRecords("good"), sink.records)
+}
+
+// Tests that spooled() replays the spooled records in the background.
+func TestSpooledSink(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	spool, err := OpenSpool(t.TempDir(), 1024*1024)
+	require.Nil(err)
+	defer spool.Close()
+
+	sink := &fakeSink{err: ErrSinkUnavailable}
+	s := &spooledSink{sink: sink, spool: spool, stop: make(chan struct{}), done: make(chan struct{})}
+	require.Nil(s.Write(textRecords("a"), testSpoolSchema))
+	spool.mu.Lock()
+	sink.err = nil
+	spool.mu.Unlock()
+	go s.run(time.Millisecond)
+	assert.Eventually(func() bool { return spool.Size() == 0 }, time.Second, time.Millisecond)
+	require.Nil(s.Close())
+	assert.Equal(textRecords("a"), sink.records)
+
+	assert.Same(sink, spooled(sink, nil))
+}
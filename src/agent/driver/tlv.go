@@ -0,0 +1,159 @@
+// Copyright (C) 2023  Tricorder Observability
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package driver
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"net/netip"
+	"time"
+
+	commonpb "github.com/tricorder/src/pb/module/common"
+	"github.com/tricorder/src/utils/pg"
+)
+
+// The bit set in the type of a TLV item, if the item is an array.
+const tlvArrayBit = 0x80
+
+// The size of the type and length of a TLV item, and the length of an array element.
+const (
+	tlvTypeSize   = 1
+	tlvLengthSize = 4
+)
+
+// The sizes of the fixed-size data types, a zero-length value of these types is NULL.
+var tlvFixedSizes = map[commonpb.DataField_Type]int{
+	pg.BOOL:        1,
+	pg.DATE:        4,
+	pg.INT:         4,
+	pg.INTEGER:     4,
+	pg.TIMESTAMPTZ: 8,
+	pg.BIGINT:      8,
+	pg.DOUBLE:      8,
+}
+
+// decodeTLV decodes a TLV-encoded record into one value per column, which can be written to the columns by pgx.
+// See README.md for the encoding.
+func decodeTLV(data []byte, columns []pg.Column) ([]interface{}, error) {
+	values := make([]interface{}, 0, len(columns))
+	for _, col := range columns {
+		if len(data) < tlvTypeSize+tlvLengthSize {
+			return nil, fmt.Errorf("while decoding TLV, column '%s' is missing", col.Name)
+		}
+		t := data[0]
+		length := binary.LittleEndian.Uint32(data[tlvTypeSize:])
+		data = data[tlvTypeSize+tlvLengthSize:]
+
+		wantType := byte(col.Type)
+		if col.Array {
+			wantType |= tlvArrayBit
+		}
+		if t != wantType {
+			return nil, fmt.Errorf("while decoding TLV, column '%s' expects type %d, got %d", col.Name, wantType, t)
+		}
+		if uint32(len(data)) < length {
+			return nil, fmt.Errorf("while decoding TLV, column '%s' has length %d, but only %d bytes are left",
+				col.Name, length, len(data))
+		}
+
+		var value interface{}
+		var err error
+		if col.Array {
+			value, err = decodeTLVArray(data[:length], col.Type)
+		} else {
+			value, err = decodeTLVValue(data[:length], col.Type)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("while decoding TLV, failed to decode column '%s', error: %v", col.Name, err)
+		}
+		values = append(values, value)
+		data = data[length:]
+	}
+	if len(data) > 0 {
+		return nil, fmt.Errorf("while decoding TLV, %d bytes are left after decoding all columns", len(data))
+	}
+	return values, nil
+}
+
+// decodeTLVArray decodes the elements of an array, each element is a 4-byte length followed by the value.
+func decodeTLVArray(data []byte, t commonpb.DataField_Type) ([]interface{}, error) {
+	elems := make([]interface{}, 0)
+	for len(data) > 0 {
+		if len(data) < tlvLengthSize {
+			return nil, fmt.Errorf("truncated array element length")
+		}
+		length := binary.LittleEndian.Uint32(data)
+		data = data[tlvLengthSize:]
+		if uint32(len(data)) < length {
+			return nil, fmt.Errorf("array element has length %d, but only %d bytes are left", length, len(data))
+		}
+		elem, err := decodeTLVValue(data[:length], t)
+		if err != nil {
+			return nil, err
+		}
+		elems = append(elems, elem)
+		data = data[length:]
+	}
+	return elems, nil
+}
+
+// decodeTLVValue decodes a single value of the data type.
+func decodeTLVValue(data []byte, t commonpb.DataField_Type) (interface{}, error) {
+	if size, ok := tlvFixedSizes[t]; ok {
+		if len(data) == 0 {
+			return nil, nil
+		}
+		if len(data) != size {
+			return nil, fmt.Errorf("%s value has %d bytes, expects %d", t, len(data), size)
+		}
+	}
+	switch t {
+	case pg.BOOL:
+		return data[0] != 0, nil
+	case pg.DATE:
+		days := int32(binary.LittleEndian.Uint32(data))
+		return time.Unix(0, 0).UTC().AddDate(0, 0, int(days)), nil
+	case pg.INT, pg.INTEGER:
+		return int32(binary.LittleEndian.Uint32(data)), nil
+	case pg.TIMESTAMPTZ:
+		return time.Unix(0, int64(binary.LittleEndian.Uint64(data))), nil
+	case pg.BIGINT:
+		return int64(binary.LittleEndian.Uint64(data)), nil
+	case pg.DOUBLE:
+		return math.Float64frombits(binary.LittleEndian.Uint64(data)), nil
+	case pg.TEXT:
+		return string(data), nil
+	case pg.JSON, pg.JSONB:
+		// An empty string is not a valid JSON value.
+		if len(data) == 0 {
+			return nil, nil
+		}
+		return data, nil
+	case pg.BYTEA:
+		return data, nil
+	case pg.INET:
+		if len(data) == 0 {
+			return nil, nil
+		}
+		addr, ok := netip.AddrFromSlice(data)
+		if !ok {
+			return nil, fmt.Errorf("INET value has %d bytes, expects 4 or 16", len(data))
+		}
+		return addr, nil
+	}
+	return nil, fmt.Errorf("data type '%s' is not supported", t)
+}
@@ -0,0 +1,130 @@
+// Copyright (C) 2023  Tricorder Observability
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package driver
+
+import (
+	"encoding/binary"
+	"math"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	commonpb "github.com/tricorder/src/pb/module/common"
+	"github.com/tricorder/src/utils/pg"
+)
+
+// Returns a TLV item with the type and value.
+func tlvItem(t commonpb.DataField_Type, array bool, value []byte) []byte {
+	typ := byte(t)
+	if array {
+		typ |= tlvArrayBit
+	}
+	item := []byte{typ}
+	item = binary.LittleEndian.AppendUint32(item, uint32(len(value)))
+	return append(item, value...)
+}
+
+// Returns the value of a TLV array item with the elements.
+func tlvArray(elems ...[]byte) []byte {
+	var value []byte
+	for _, elem := range elems {
+		value = binary.LittleEndian.AppendUint32(value, uint32(len(elem)))
+		value = append(value, elem...)
+	}
+	return value
+}
+
+func le32(v uint32) []byte { return binary.LittleEndian.AppendUint32(nil, v) }
+
+func le64(v uint64) []byte { return binary.LittleEndian.AppendUint64(nil, v) }
+
+// Tests that decodeTLV decodes all data types and arrays.
+func TestDecodeTLV(t *testing.T) {
+	assert := assert.New(t)
+
+	columns := []pg.Column{
+		{Name: "ok", Type: pg.BOOL},
+		{Name: "day", Type: pg.DATE},
+		{Name: "pid", Type: pg.INT},
+		{Name: "ts", Type: pg.TIMESTAMPTZ},
+		{Name: "bytes", Type: pg.BIGINT},
+		{Name: "latency", Type: pg.DOUBLE},
+		{Name: "comm", Type: pg.TEXT},
+		{Name: "data", Type: pg.JSONB},
+		{Name: "raw", Type: pg.BYTEA},
+		{Name: "addr", Type: pg.INET},
+		{Name: "ports", Type: pg.INTEGER, Array: true},
+		{Name: "missing", Type: pg.BIGINT},
+	}
+	var data []byte
+	data = append(data, tlvItem(pg.BOOL, false, []byte{1})...)
+	data = append(data, tlvItem(pg.DATE, false, le32(1))...)
+	data = append(data, tlvItem(pg.INT, false, le32(1234))...)
+	data = append(data, tlvItem(pg.TIMESTAMPTZ, false, le64(1500))...)
+	data = append(data, tlvItem(pg.BIGINT, false, le64(1<<40))...)
+	data = append(data, tlvItem(pg.DOUBLE, false, le64(math.Float64bits(0.5)))...)
+	data = append(data, tlvItem(pg.TEXT, false, []byte("curl"))...)
+	data = append(data, tlvItem(pg.JSONB, false, []byte(`{"a":1}`))...)
+	data = append(data, tlvItem(pg.BYTEA, false, []byte{0, 1})...)
+	data = append(data, tlvItem(pg.INET, false, []byte{10, 0, 0, 1})...)
+	data = append(data, tlvItem(pg.INTEGER, true, tlvArray(le32(80), le32(443)))...)
+	data = append(data, tlvItem(pg.BIGINT, false, nil)...)
+
+	values, err := decodeTLV(data, columns)
+	assert.Nil(err)
+	assert.Equal([]interface{}{
+		true,
+		time.Date(1970, 1, 2, 0, 0, 0, 0, time.UTC),
+		int32(1234),
+		time.Unix(0, 1500),
+		int64(1 << 40),
+		0.5,
+		"curl",
+		[]byte(`{"a":1}`),
+		[]byte{0, 1},
+		netip.AddrFrom4([4]byte{10, 0, 0, 1}),
+		[]interface{}{int32(80), int32(443)},
+		nil,
+	}, values)
+	assert.Equal(int32(1234), *tlvPID(columns, values))
+}
+
+// Tests that decodeTLV returns errors for the malformed records.
+func TestDecodeTLVErrors(t *testing.T) {
+	assert := assert.New(t)
+
+	columns := []pg.Column{{Name: "pid", Type: pg.INT}}
+
+	_, err := decodeTLV(nil, columns)
+	assert.ErrorContains(err, "column 'pid' is missing")
+
+	_, err = decodeTLV(tlvItem(pg.BIGINT, false, le64(1)), columns)
+	assert.ErrorContains(err, "column 'pid' expects type 2, got 8")
+
+	_, err = decodeTLV(tlvItem(pg.INT, false, le32(1))[:7], columns)
+	assert.ErrorContains(err, "column 'pid' has length 4, but only 2 bytes are left")
+
+	_, err = decodeTLV(tlvItem(pg.INT, false, []byte{1, 2}), columns)
+	assert.ErrorContains(err, "INT value has 2 bytes, expects 4")
+
+	_, err = decodeTLV(append(tlvItem(pg.INT, false, le32(1)), 0), columns)
+	assert.ErrorContains(err, "1 bytes are left after decoding all columns")
+
+	_, err = decodeTLV(tlvItem(pg.INET, false, []byte{1, 2, 3}), []pg.Column{{Name: "addr", Type: pg.INET}})
+	assert.ErrorContains(err, "INET value has 3 bytes, expects 4 or 16")
+}
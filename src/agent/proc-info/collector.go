@@ -23,15 +23,16 @@ import (
 	"path/filepath"
 	"strconv"
 	"strings"
+	"sync"
 
 	p "github.com/shirou/gopsutil/process"
 	"google.golang.org/grpc"
-	"google.golang.org/grpc/credentials/insecure"
 
 	"github.com/tricorder/src/utils/log"
 
 	pb "github.com/tricorder/src/api-server/pb"
 	"github.com/tricorder/src/utils/file"
+	grpcutils "github.com/tricorder/src/utils/grpc"
 	"github.com/tricorder/src/utils/retry"
 )
 
@@ -45,24 +46,69 @@ type Collector struct {
 	// The address to the API server.
 	apiServerAddr string
 
+	// The options of the connection to the API server, like the bearer token.
+	dialOpts []grpc.DialOption
+
 	// The name of the node runs this agent.
 	nodeName string
 
 	// Connects to API server's process info collector server, and reports process information.
 	procCollectorClient pb.ProcessCollectorClient
+
+	// Guards the maps below, which are updated by the reporting goroutine, and read by eBPF+WASM modules.
+	mu sync.RWMutex
+
+	// Key is the PID, value is the container that runs the process.
+	pidContainers map[int32]*pb.ContainerInfo
+
+	// Key is the container ID, value is the PIDs of the processes in the container.
+	containerPIDs map[string][]int32
 }
 
-func NewCollector(hostSysRootPath, apiServerAddr, nodeName string) *Collector {
+func NewCollector(hostSysRootPath, apiServerAddr, nodeName string, dialOpts ...grpc.DialOption) *Collector {
 	return &Collector{
 		hostSysRootPath: hostSysRootPath,
 		apiServerAddr:   apiServerAddr,
+		dialOpts:        dialOpts,
 		nodeName:        nodeName,
+		pidContainers:   make(map[int32]*pb.ContainerInfo),
+		containerPIDs:   make(map[string][]int32),
+	}
+}
+
+// ResolvePID returns the pod name and container name of the process, as recorded in the latest process info of the
+// containers on this node. Implements driver.PIDResolver.
+func (c *Collector) ResolvePID(pid int32) (string, string, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	ci, found := c.pidContainers[pid]
+	if !found {
+		return "", "", false
+	}
+	return ci.PodName, ci.Name, true
+}
+
+// updateProcessInfo replaces the processes of the container with the ones in the process info.
+func (c *Collector) updateProcessInfo(processInfo *pb.ProcessInfo) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	containerID := processInfo.Container.Id
+	for _, pid := range c.containerPIDs[containerID] {
+		// The PID might have been reused by a process in another container.
+		if ci, found := c.pidContainers[pid]; found && ci.Id == containerID {
+			delete(c.pidContainers, pid)
+		}
+	}
+	pids := make([]int32, 0, len(processInfo.ProcList))
+	for _, proc := range processInfo.ProcList {
+		c.pidContainers[proc.Id] = processInfo.Container
+		pids = append(pids, proc.Id)
 	}
+	c.containerPIDs[containerID] = pids
 }
 
 func (c *Collector) connect() error {
-	grpcConn, err := grpc.Dial(c.apiServerAddr,
-		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	grpcConn, err := grpcutils.Dial(c.apiServerAddr, c.dialOpts...)
 	if err != nil {
 		return fmt.Errorf("failed to connect to API server at '%s', error: %v", c.apiServerAddr, err)
 	}
@@ -102,6 +148,7 @@ func (c *Collector) StartProcInfoReport() error {
 					"failed to grab process info, error: %v", containerInfo, err)
 				continue
 			}
+			c.updateProcessInfo(processInfo)
 
 			if err = stream.Send(&pb.ProcessWrapper{Msg: &pb.ProcessWrapper_Process{Process: processInfo}}); err != nil {
 				log.Errorf("stream.Send error: %v", err)
@@ -151,3 +151,23 @@ func TestBareMetalFormat(t *testing.T) {
 	assert.Equal(int32(os.Getpid()), procInfo.ProcList[0].Id)
 	assert.Greater(procInfo.ProcList[0].CreateTime, int64(0))
 }
+
+// Tests that ResolvePID returns the pod and container from the latest process info of the container.
+func TestResolvePID(t *testing.T) {
+	assert := assert.New(t)
+
+	c := NewCollector("", "", "")
+	ci := &pb.ContainerInfo{Id: "docker://1234", Name: "container", PodName: "pod"}
+	c.updateProcessInfo(&pb.ProcessInfo{Container: ci, ProcList: []*pb.Process{{Id: 1}, {Id: 2}}})
+
+	pod, container, found := c.ResolvePID(1)
+	assert.True(found)
+	assert.Equal("pod", pod)
+	assert.Equal("container", container)
+
+	c.updateProcessInfo(&pb.ProcessInfo{Container: ci, ProcList: []*pb.Process{{Id: 2}, {Id: 3}}})
+	_, _, found = c.ResolvePID(1)
+	assert.False(found)
+	_, _, found = c.ResolvePID(3)
+	assert.True(found)
+}
@@ -0,0 +1,150 @@
+// Copyright (C) 2023  Tricorder Observability
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+// Package auth authenticates the requests to API Server with API tokens, or with JWTs like OIDC ID tokens, and
+// authorizes them by the role of the authenticated principal.
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ErrUnauthenticated is returned if a request has no valid credentials.
+var ErrUnauthenticated = errors.New("unauthenticated")
+
+// The default claim of JWTs with the role.
+const DefaultRoleClaim = "role"
+
+// The default claim of JWTs with the tenant.
+const DefaultTenantClaim = "tenant"
+
+// Config configures the credentials accepted by Authenticator, authentication is disabled if neither TokensFile nor
+// JWKSFile is set.
+type Config struct {
+	// The path to the YAML file of the API tokens and their roles.
+	TokensFile string
+
+	// The path to the JWKS file, whose keys verify the JWT bearer tokens, like the OIDC ID tokens of an identity
+	// provider. The file is configured locally, API Server does not fetch it from the identity provider.
+	JWKSFile string
+	// The JWT's issuer and audience, not checked if empty.
+	Issuer   string
+	Audience string
+	// The JWT's claim with the role, DefaultRoleClaim if empty.
+	RoleClaim string
+	// The JWT's claim with the tenant, DefaultTenantClaim if empty.
+	TenantClaim string
+}
+
+// Principal is the authenticated user or agent of a request.
+type Principal struct {
+	// The name of the API token, or the subject of the JWT.
+	Name string
+	Role Role
+	// The tenant whose modules the principal manages, empty if the principal manages the modules of all tenants.
+	Tenant string
+}
+
+// Authenticator authenticates the bearer tokens of requests.
+type Authenticator struct {
+	tokens []apiToken
+	jwt    *jwtVerifier
+}
+
+// NewAuthenticator returns an Authenticator that accepts the credentials configured by cfg.
+func NewAuthenticator(cfg Config) (*Authenticator, error) {
+	a := &Authenticator{}
+	if len(cfg.TokensFile) > 0 {
+		tokens, err := loadTokens(cfg.TokensFile)
+		if err != nil {
+			return nil, err
+		}
+		a.tokens = tokens
+	}
+	if len(cfg.JWKSFile) > 0 {
+		keys, err := loadJWKS(cfg.JWKSFile)
+		if err != nil {
+			return nil, err
+		}
+		roleClaim := cfg.RoleClaim
+		if len(roleClaim) == 0 {
+			roleClaim = DefaultRoleClaim
+		}
+		tenantClaim := cfg.TenantClaim
+		if len(tenantClaim) == 0 {
+			tenantClaim = DefaultTenantClaim
+		}
+		a.jwt = &jwtVerifier{
+			keys:        keys,
+			issuer:      cfg.Issuer,
+			audience:    cfg.Audience,
+			roleClaim:   roleClaim,
+			tenantClaim: tenantClaim,
+			now:         time.Now,
+		}
+	}
+	return a, nil
+}
+
+// Enabled returns true if requests need to be authenticated. A nil Authenticator is disabled.
+func (a *Authenticator) Enabled() bool {
+	return a != nil && (len(a.tokens) > 0 || a.jwt != nil)
+}
+
+// Authenticate returns the principal of the bearer token, either an API token or a JWT.
+// The returned error wraps ErrUnauthenticated if the token is invalid.
+func (a *Authenticator) Authenticate(token string) (*Principal, error) {
+	if len(token) == 0 {
+		return nil, fmt.Errorf("%w: missing bearer token", ErrUnauthenticated)
+	}
+	if p := findToken(a.tokens, token); p != nil {
+		return p, nil
+	}
+	if a.jwt != nil && isJWT(token) {
+		p, err := a.jwt.verify(token)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrUnauthenticated, err)
+		}
+		return p, nil
+	}
+	return nil, fmt.Errorf("%w: invalid bearer token", ErrUnauthenticated)
+}
+
+// Authorize returns the principal of the bearer token if its role allows the required role.
+// The returned error wraps ErrUnauthenticated if the token is invalid, otherwise the principal is returned along with
+// the error if the role is not allowed.
+func (a *Authenticator) Authorize(token string, required Role) (*Principal, error) {
+	p, err := a.Authenticate(token)
+	if err != nil {
+		return nil, err
+	}
+	if !p.Role.Allows(required) {
+		return p, fmt.Errorf("the role '%s' of '%s' is not allowed to use the APIs of role '%s'",
+			p.Role, p.Name, required)
+	}
+	return p, nil
+}
+
+// BearerToken returns the token of the value of an Authorization header, like "Bearer <token>".
+func BearerToken(header string) (string, bool) {
+	const prefix = "bearer "
+	if len(header) <= len(prefix) || !strings.EqualFold(header[:len(prefix)], prefix) {
+		return "", false
+	}
+	return strings.TrimSpace(header[len(prefix):]), true
+}
@@ -0,0 +1,178 @@
+// Copyright (C) 2023  Tricorder Observability
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func writeFile(t *testing.T, name, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	require.Nil(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+// Tests the order of the roles.
+func TestRoleAllows(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.True(RoleViewer.Allows(RoleViewer))
+	assert.False(RoleViewer.Allows(RoleOperator))
+	assert.True(RoleOperator.Allows(RoleViewer))
+	assert.False(RoleOperator.Allows(RoleAdmin))
+	assert.True(RoleAdmin.Allows(RoleOperator))
+	assert.True(RoleAdmin.Allows(RoleAgent))
+	assert.True(RoleAgent.Allows(RoleAgent))
+	assert.False(RoleAgent.Allows(RoleViewer))
+	assert.False(RoleOperator.Allows(RoleAgent))
+
+	role, err := ParseRole("Operator")
+	assert.Nil(err)
+	assert.Equal(RoleOperator, role)
+	_, err = ParseRole("root")
+	assert.ErrorContains(err, "unknown role 'root'")
+}
+
+// Tests that the API tokens are authenticated and authorized by their roles.
+func TestAPITokens(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	a, err := NewAuthenticator(Config{})
+	require.Nil(err)
+	assert.False(a.Enabled())
+
+	path := writeFile(t, "tokens.yaml", `
+tokens:
+  - name: viewer
+    role: viewer
+    token: viewer-token
+  - name: admin
+    role: admin
+    sha256: `+sha256Hex("admin-token")+`
+  - name: team-a
+    role: operator
+    tenant: team_a
+    token: team-a-token
+`)
+	a, err = NewAuthenticator(Config{TokensFile: path})
+	require.Nil(err)
+	assert.True(a.Enabled())
+
+	p, err := a.Authorize("viewer-token", RoleViewer)
+	require.Nil(err)
+	assert.Equal(Principal{Name: "viewer", Role: RoleViewer}, *p)
+
+	p, err = a.Authorize("admin-token", RoleAdmin)
+	require.Nil(err)
+	assert.Equal("admin", p.Name)
+	assert.Empty(p.Tenant)
+
+	p, err = a.Authorize("team-a-token", RoleOperator)
+	require.Nil(err)
+	assert.Equal(Principal{Name: "team-a", Role: RoleOperator, Tenant: "team_a"}, *p)
+
+	p, err = a.Authorize("viewer-token", RoleOperator)
+	assert.ErrorContains(err, "not allowed")
+	assert.False(errors.Is(err, ErrUnauthenticated))
+	assert.Equal("viewer", p.Name)
+
+	_, err = a.Authorize("wrong-token", RoleViewer)
+	assert.ErrorIs(err, ErrUnauthenticated)
+	_, err = a.Authorize("", RoleViewer)
+	assert.ErrorIs(err, ErrUnauthenticated)
+
+	for _, content := range []string{
+		"tokens:\n  - name: a\n    role: viewer\n",
+		"tokens:\n  - name: a\n    role: root\n    token: t\n",
+		"tokens:\n  - name: a\n    role: viewer\n    sha256: abc\n",
+		"tokens:\n  - name: a\n    role: viewer\n    token: t\n  - name: a\n    role: viewer\n    token: u\n",
+		"tokens:\n  - name: a\n    role: viewer\n    token: t\n    secret: s\n",
+		"tokens:\n  - name: a\n    role: viewer\n    token: t\n    tenant: Team-A\n",
+	} {
+		_, err := NewAuthenticator(Config{TokensFile: writeFile(t, "tokens.yaml", content)})
+		assert.Error(err, content)
+	}
+}
+
+func sha256Hex(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func TestBearerToken(t *testing.T) {
+	assert := assert.New(t)
+
+	token, ok := BearerToken("Bearer abc")
+	assert.True(ok)
+	assert.Equal("abc", token)
+	token, ok = BearerToken("bearer abc")
+	assert.True(ok)
+	assert.Equal("abc", token)
+	_, ok = BearerToken("Basic abc")
+	assert.False(ok)
+	_, ok = BearerToken("Bearer ")
+	assert.False(ok)
+}
+
+// Tests that the gRPC interceptors reject the calls without a token of the required role.
+func TestServerInterceptors(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	path := writeFile(t, "tokens.yaml", `
+tokens:
+  - name: agent
+    role: agent
+    token: agent-token
+  - name: operator
+    role: operator
+    token: operator-token
+`)
+	a, err := NewAuthenticator(Config{TokensFile: path})
+	require.Nil(err)
+	interceptor := a.UnaryServerInterceptor(RoleAgent)
+	handler := func(ctx context.Context, req any) (any, error) { return "ok", nil }
+	call := func(ctx context.Context) error {
+		_, err := interceptor(ctx, nil, &grpc.UnaryServerInfo{}, handler)
+		return err
+	}
+	withToken := func(token string) context.Context {
+		return metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "Bearer "+token))
+	}
+
+	assert.Nil(call(withToken("agent-token")))
+	assert.Equal(codes.PermissionDenied, status.Code(call(withToken("operator-token"))))
+	assert.Equal(codes.Unauthenticated, status.Code(call(withToken("wrong-token"))))
+	assert.Equal(codes.Unauthenticated, status.Code(call(context.Background())))
+
+	disabled, err := NewAuthenticator(Config{})
+	require.Nil(err)
+	_, err = disabled.UnaryServerInterceptor(RoleAgent)(context.Background(), nil, &grpc.UnaryServerInfo{}, handler)
+	assert.Nil(err)
+}
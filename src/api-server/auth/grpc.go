@@ -0,0 +1,70 @@
+// Copyright (C) 2023  Tricorder Observability
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+package auth
+
+import (
+	"context"
+	"errors"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// The gRPC metadata key of the bearer token, which is the lower case Authorization header.
+const authorizationMetadataKey = "authorization"
+
+// authorizeContext authorizes the bearer token in the incoming metadata of the gRPC call.
+func (a *Authenticator) authorizeContext(ctx context.Context, required Role) error {
+	if !a.Enabled() {
+		return nil
+	}
+	var token string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get(authorizationMetadataKey); len(values) > 0 {
+			token, _ = BearerToken(values[0])
+		}
+	}
+	_, err := a.Authorize(token, required)
+	if errors.Is(err, ErrUnauthenticated) {
+		return status.Error(codes.Unauthenticated, err.Error())
+	}
+	if err != nil {
+		return status.Error(codes.PermissionDenied, err.Error())
+	}
+	return nil
+}
+
+// UnaryServerInterceptor returns an interceptor that rejects the unary calls without a bearer token whose role allows
+// the required role.
+func (a *Authenticator) UnaryServerInterceptor(required Role) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if err := a.authorizeContext(ctx, required); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor is like UnaryServerInterceptor() but for the streaming calls.
+func (a *Authenticator) StreamServerInterceptor(required Role) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if err := a.authorizeContext(ss.Context(), required); err != nil {
+			return err
+		}
+		return handler(srv, ss)
+	}
+}
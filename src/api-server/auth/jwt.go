@@ -0,0 +1,333 @@
+// Copyright (C) 2023  Tricorder Observability
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	_ "crypto/sha256" // Registers crypto.SHA256.
+	_ "crypto/sha512" // Registers crypto.SHA384 and crypto.SHA512.
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/tricorder/src/api-server/tenant"
+)
+
+// The allowed difference between the clocks of the issuer and API Server when checking the expiration of JWTs.
+const jwtLeeway = time.Minute
+
+// The hash of each supported JWS algorithm, and whether it is an ECDSA algorithm, or an RSA PKCS #1 v1.5 one.
+var jwsAlgs = map[string]struct {
+	hash  crypto.Hash
+	ecdsa bool
+}{
+	"RS256": {crypto.SHA256, false},
+	"RS384": {crypto.SHA384, false},
+	"RS512": {crypto.SHA512, false},
+	"ES256": {crypto.SHA256, true},
+	"ES384": {crypto.SHA384, true},
+}
+
+// jwk is a JSON Web Key of RSA or EC public key, see RFC 7517.
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+	// RSA
+	N string `json:"n"`
+	E string `json:"e"`
+	// EC
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+type jwksKey struct {
+	kid string
+	alg string
+	key crypto.PublicKey
+}
+
+// jwtVerifier verifies the JWT bearer tokens, like OIDC ID tokens, with the keys of a locally configured JWKS.
+type jwtVerifier struct {
+	keys []jwksKey
+	// Checked against the 'iss' and 'aud' claims if not empty.
+	issuer   string
+	audience string
+	// The claim with the role of the token's principal, either a role name or a list of names.
+	roleClaim   string
+	tenantClaim string
+	now         func() time.Time
+}
+
+// loadJWKS returns the signing keys of the JWKS file at path, the keys of other types are ignored.
+func loadJWKS(path string) ([]jwksKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("while loading JWKS, failed to read '%s', error: %v", path, err)
+	}
+	var jwks struct {
+		Keys []jwk `json:"keys"`
+	}
+	err = json.Unmarshal(data, &jwks)
+	if err != nil {
+		return nil, fmt.Errorf("while loading JWKS, failed to parse '%s', error: %v", path, err)
+	}
+	var keys []jwksKey
+	for i, k := range jwks.Keys {
+		if len(k.Use) > 0 && k.Use != "sig" {
+			continue
+		}
+		key, err := k.publicKey()
+		if err != nil {
+			return nil, fmt.Errorf("while loading JWKS, invalid key #%d in '%s', error: %v", i, path, err)
+		}
+		if key != nil {
+			keys = append(keys, jwksKey{kid: k.Kid, alg: k.Alg, key: key})
+		}
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("while loading JWKS, '%s' has no RSA or EC signing keys", path)
+	}
+	return keys, nil
+}
+
+// publicKey returns the RSA or EC public key, or nil if the key is of other types.
+func (k *jwk) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, fmt.Errorf("invalid n, %v", err)
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil || !e.IsInt64() || e.Int64() > 1<<31-1 {
+			return nil, fmt.Errorf("invalid e '%s'", k.E)
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		default:
+			return nil, fmt.Errorf("curve '%s' is not supported", k.Crv)
+		}
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, fmt.Errorf("invalid x, %v", err)
+		}
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, fmt.Errorf("invalid y, %v", err)
+		}
+		if !curve.IsOnCurve(x, y) {
+			return nil, errors.New("the point is not on the curve")
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	}
+	return nil, nil
+}
+
+func decodeBigInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	if len(b) == 0 {
+		return nil, errors.New("empty value")
+	}
+	return new(big.Int).SetBytes(b), nil
+}
+
+// isJWT returns true if the token has the form of a JWS compact serialization.
+func isJWT(token string) bool {
+	return strings.Count(token, ".") == 2
+}
+
+// verify returns the principal of the JWT, whose name is the 'sub' claim, if the token's signature and claims are
+// valid.
+func (v *jwtVerifier) verify(token string) (*Principal, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("malformed JWT")
+	}
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeJWTPart(parts[0], &header); err != nil {
+		return nil, fmt.Errorf("invalid JWT header, %v", err)
+	}
+	alg, ok := jwsAlgs[header.Alg]
+	if !ok {
+		return nil, fmt.Errorf("JWT algorithm '%s' is not supported", header.Alg)
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("invalid JWT signature, %v", err)
+	}
+	h := alg.hash.New()
+	h.Write([]byte(parts[0] + "." + parts[1]))
+	digest := h.Sum(nil)
+	if !v.verifySignature(header.Kid, header.Alg, alg.ecdsa, alg.hash, digest, sig) {
+		return nil, errors.New("invalid JWT signature")
+	}
+
+	var claims map[string]any
+	if err := decodeJWTPart(parts[1], &claims); err != nil {
+		return nil, fmt.Errorf("invalid JWT claims, %v", err)
+	}
+	if err := v.checkClaims(claims); err != nil {
+		return nil, err
+	}
+	role, err := v.role(claims)
+	if err != nil {
+		return nil, err
+	}
+	tenantName, err := v.tenant(claims)
+	if err != nil {
+		return nil, err
+	}
+	sub, _ := claims["sub"].(string)
+	return &Principal{Name: sub, Role: role, Tenant: tenantName}, nil
+}
+
+func decodeJWTPart(part string, v any) error {
+	data, err := base64.RawURLEncoding.DecodeString(part)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// verifySignature returns true if one of the keys, with the kid if not empty, verifies the signature.
+func (v *jwtVerifier) verifySignature(kid, algName string, isECDSA bool, hash crypto.Hash, digest, sig []byte) bool {
+	for _, k := range v.keys {
+		if len(kid) > 0 && k.kid != kid || len(k.alg) > 0 && k.alg != algName {
+			continue
+		}
+		switch key := k.key.(type) {
+		case *rsa.PublicKey:
+			if !isECDSA && rsa.VerifyPKCS1v15(key, hash, digest, sig) == nil {
+				return true
+			}
+		case *ecdsa.PublicKey:
+			size := (key.Curve.Params().BitSize + 7) / 8
+			if isECDSA && len(sig) == 2*size {
+				r := new(big.Int).SetBytes(sig[:size])
+				s := new(big.Int).SetBytes(sig[size:])
+				if ecdsa.Verify(key, digest, r, s) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// checkClaims returns an error if the token has expired, is not valid yet, or has another issuer or audience.
+func (v *jwtVerifier) checkClaims(claims map[string]any) error {
+	now := v.now()
+	exp, ok := claims["exp"].(float64)
+	if !ok {
+		return errors.New("JWT has no exp claim")
+	}
+	if now.After(time.Unix(int64(exp), 0).Add(jwtLeeway)) {
+		return errors.New("JWT has expired")
+	}
+	if nbf, ok := claims["nbf"].(float64); ok && now.Add(jwtLeeway).Before(time.Unix(int64(nbf), 0)) {
+		return errors.New("JWT is not valid yet")
+	}
+	if len(v.issuer) > 0 && claims["iss"] != v.issuer {
+		return fmt.Errorf("JWT issuer '%v' is not '%s'", claims["iss"], v.issuer)
+	}
+	if len(v.audience) > 0 && !hasAudience(claims["aud"], v.audience) {
+		return fmt.Errorf("JWT audience '%v' does not include '%s'", claims["aud"], v.audience)
+	}
+	return nil
+}
+
+// hasAudience returns true if the 'aud' claim, either a string or a list of strings, includes the audience.
+func hasAudience(aud any, audience string) bool {
+	switch aud := aud.(type) {
+	case string:
+		return aud == audience
+	case []any:
+		for _, a := range aud {
+			if a == audience {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// role returns the role in the role claim, or the role that allows the most APIs if the claim is a list, like
+// the groups of the user. The names that are not roles are ignored.
+func (v *jwtVerifier) role(claims map[string]any) (Role, error) {
+	var names []any
+	switch claim := claims[v.roleClaim].(type) {
+	case string:
+		names = []any{claim}
+	case []any:
+		names = claim
+	}
+	var role Role
+	for _, n := range names {
+		name, ok := n.(string)
+		if !ok {
+			continue
+		}
+		r, err := ParseRole(name)
+		if err != nil {
+			continue
+		}
+		if role == 0 || r.Allows(role) {
+			role = r
+		}
+	}
+	if role == 0 {
+		return 0, fmt.Errorf("JWT claim '%s' has no role", v.roleClaim)
+	}
+	return role, nil
+}
+
+// tenant returns the tenant in the tenant claim, or empty if the JWT has no tenant claim.
+func (v *jwtVerifier) tenant(claims map[string]any) (string, error) {
+	claim, ok := claims[v.tenantClaim]
+	if !ok {
+		return "", nil
+	}
+	name, ok := claim.(string)
+	if !ok {
+		return "", fmt.Errorf("JWT claim '%s' is not a string", v.tenantClaim)
+	}
+	if err := tenant.ValidateName(name); err != nil {
+		return "", fmt.Errorf("JWT claim '%s' is not a tenant, %v", v.tenantClaim, err)
+	}
+	return name, nil
+}
@@ -0,0 +1,197 @@
+// Copyright (C) 2023  Tricorder Observability
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func b64(data []byte) string {
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func b64JSON(t *testing.T, v any) string {
+	data, err := json.Marshal(v)
+	require.Nil(t, err)
+	return b64(data)
+}
+
+// signRS256 returns a JWT with the claims signed by the RSA key.
+func signRS256(t *testing.T, key *rsa.PrivateKey, kid string, claims map[string]any) string {
+	input := b64JSON(t, map[string]string{"alg": "RS256", "kid": kid}) + "." + b64JSON(t, claims)
+	digest := sha256.Sum256([]byte(input))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	require.Nil(t, err)
+	return input + "." + b64(sig)
+}
+
+// signES256 returns a JWT with the claims signed by the P-256 key.
+func signES256(t *testing.T, key *ecdsa.PrivateKey, kid string, claims map[string]any) string {
+	input := b64JSON(t, map[string]string{"alg": "ES256", "kid": kid}) + "." + b64JSON(t, claims)
+	digest := sha256.Sum256([]byte(input))
+	r, s, err := ecdsa.Sign(rand.Reader, key, digest[:])
+	require.Nil(t, err)
+	sig := make([]byte, 64)
+	r.FillBytes(sig[:32])
+	s.FillBytes(sig[32:])
+	return input + "." + b64(sig)
+}
+
+// Tests that the JWTs signed by the keys of the JWKS are verified, and their claims are checked.
+func TestJWT(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.Nil(err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.Nil(err)
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.Nil(err)
+
+	jwks := map[string]any{"keys": []map[string]string{
+		{
+			"kty": "RSA", "kid": "rsa", "use": "sig", "alg": "RS256",
+			"n": b64(rsaKey.N.Bytes()), "e": b64(big.NewInt(int64(rsaKey.E)).Bytes()),
+		},
+		{
+			"kty": "EC", "kid": "ec", "crv": "P-256",
+			"x": b64(ecKey.X.FillBytes(make([]byte, 32))), "y": b64(ecKey.Y.FillBytes(make([]byte, 32))),
+		},
+		// Ignored, as it's not a signing key.
+		{"kty": "RSA", "kid": "enc", "use": "enc"},
+	}}
+	data, err := json.Marshal(jwks)
+	require.Nil(err)
+	a, err := NewAuthenticator(Config{
+		JWKSFile:  writeFile(t, "jwks.json", string(data)),
+		Issuer:    "https://idp.example.com",
+		Audience:  "starship",
+		RoleClaim: "groups",
+	})
+	require.Nil(err)
+	assert.True(a.Enabled())
+
+	now := time.Now()
+	claims := func() map[string]any {
+		return map[string]any{
+			"sub":    "alice",
+			"iss":    "https://idp.example.com",
+			"aud":    []string{"starship", "grafana"},
+			"exp":    now.Add(time.Hour).Unix(),
+			"groups": []string{"developers", "viewer", "operator"},
+		}
+	}
+
+	p, err := a.Authenticate(signRS256(t, rsaKey, "rsa", claims()))
+	require.Nil(err)
+	assert.Equal(Principal{Name: "alice", Role: RoleOperator}, *p)
+
+	p, err = a.Authenticate(signES256(t, ecKey, "ec", claims()))
+	require.Nil(err)
+	assert.Equal(RoleOperator, p.Role)
+
+	// The key is found without kid.
+	p, err = a.Authenticate(signRS256(t, rsaKey, "", claims()))
+	require.Nil(err)
+	assert.Equal("alice", p.Name)
+
+	_, err = a.Authenticate(signRS256(t, otherKey, "rsa", claims()))
+	assert.ErrorContains(err, "invalid JWT signature")
+	assert.ErrorIs(err, ErrUnauthenticated)
+
+	// The RSA key is only for RS256.
+	_, err = a.Authenticate(signES256(t, ecKey, "rsa", claims()))
+	assert.ErrorContains(err, "invalid JWT signature")
+
+	expired := claims()
+	expired["exp"] = now.Add(-time.Hour).Unix()
+	_, err = a.Authenticate(signRS256(t, rsaKey, "rsa", expired))
+	assert.ErrorContains(err, "JWT has expired")
+
+	notYet := claims()
+	notYet["nbf"] = now.Add(time.Hour).Unix()
+	_, err = a.Authenticate(signRS256(t, rsaKey, "rsa", notYet))
+	assert.ErrorContains(err, "JWT is not valid yet")
+
+	otherIssuer := claims()
+	otherIssuer["iss"] = "https://evil.example.com"
+	_, err = a.Authenticate(signRS256(t, rsaKey, "rsa", otherIssuer))
+	assert.ErrorContains(err, "JWT issuer")
+
+	otherAudience := claims()
+	otherAudience["aud"] = "grafana"
+	_, err = a.Authenticate(signRS256(t, rsaKey, "rsa", otherAudience))
+	assert.ErrorContains(err, "JWT audience")
+
+	noRole := claims()
+	noRole["groups"] = []string{"developers"}
+	_, err = a.Authenticate(signRS256(t, rsaKey, "rsa", noRole))
+	assert.ErrorContains(err, "JWT claim 'groups' has no role")
+
+	withTenant := claims()
+	withTenant["tenant"] = "team_a"
+	p, err = a.Authenticate(signRS256(t, rsaKey, "rsa", withTenant))
+	require.Nil(err)
+	assert.Equal(Principal{Name: "alice", Role: RoleOperator, Tenant: "team_a"}, *p)
+
+	badTenant := claims()
+	badTenant["tenant"] = "../team_a"
+	_, err = a.Authenticate(signRS256(t, rsaKey, "rsa", badTenant))
+	assert.ErrorContains(err, "JWT claim 'tenant' is not a tenant")
+
+	// Tampered claims.
+	parts := strings.Split(signRS256(t, rsaKey, "rsa", claims()), ".")
+	admin := claims()
+	admin["groups"] = "admin"
+	tampered := parts[0] + "." + b64JSON(t, admin) + "." + parts[2]
+	_, err = a.Authenticate(tampered)
+	assert.ErrorContains(err, "invalid JWT signature")
+
+	unsigned := b64JSON(t, map[string]string{"alg": "none"}) + "." + b64JSON(t, admin) + "."
+	_, err = a.Authenticate(unsigned)
+	assert.ErrorContains(err, "JWT algorithm 'none' is not supported")
+}
+
+// Tests that invalid JWKS files are rejected.
+func TestLoadJWKS(t *testing.T) {
+	assert := assert.New(t)
+
+	for _, content := range []string{
+		`not json`,
+		`{"keys": []}`,
+		`{"keys": [{"kty": "oct", "k": "c2VjcmV0"}]}`,
+		`{"keys": [{"kty": "RSA", "n": "", "e": "AQAB"}]}`,
+		`{"keys": [{"kty": "EC", "crv": "P-521", "x": "AQ", "y": "AQ"}]}`,
+		`{"keys": [{"kty": "EC", "crv": "P-256", "x": "AQ", "y": "AQ"}]}`,
+	} {
+		_, err := NewAuthenticator(Config{JWKSFile: writeFile(t, "jwks.json", content)})
+		assert.Error(err, content)
+	}
+}
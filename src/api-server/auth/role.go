@@ -0,0 +1,71 @@
+// Copyright (C) 2023  Tricorder Observability
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+package auth
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Role determines the APIs that a principal is allowed to use.
+type Role int
+
+const (
+	// RoleViewer can only read modules, agents and their states.
+	RoleViewer Role = iota + 1
+	// RoleOperator can also create, deploy, undeploy and upgrade modules.
+	RoleOperator
+	// RoleAdmin can use all APIs, including the ones that delete data, like deleting modules.
+	RoleAdmin
+	// RoleAgent can only use the gRPC services that agents connect to.
+	RoleAgent
+)
+
+var roleNames = map[Role]string{
+	RoleViewer:   "viewer",
+	RoleOperator: "operator",
+	RoleAdmin:    "admin",
+	RoleAgent:    "agent",
+}
+
+func (r Role) String() string {
+	if name, ok := roleNames[r]; ok {
+		return name
+	}
+	return fmt.Sprintf("Role(%d)", int(r))
+}
+
+// ParseRole returns the role with the case-insensitive name, like "operator".
+func ParseRole(name string) (Role, error) {
+	for r, n := range roleNames {
+		if strings.EqualFold(n, name) {
+			return r, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown role '%s'", name)
+}
+
+// Allows returns true if r is allowed to use the APIs that require the required role.
+// Viewer, operator and admin are ordered, each allows the APIs of the previous ones. Agent is separate, only admin
+// allows its APIs as well.
+func (r Role) Allows(required Role) bool {
+	if r == RoleAdmin {
+		return true
+	}
+	if r == RoleAgent || required == RoleAgent {
+		return r == required
+	}
+	return r >= required
+}
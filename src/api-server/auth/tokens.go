@@ -0,0 +1,123 @@
+// Copyright (C) 2023  Tricorder Observability
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+package auth
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"os"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/tricorder/src/api-server/tenant"
+)
+
+// tokensFile is the format of the API tokens file, for example:
+//
+//	tokens:
+//	  - name: ci
+//	    role: operator
+//	    # The hex SHA-256 digest of the token, printed by `echo -n <token> | sha256sum`.
+//	    sha256: 9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08
+//	  - name: team-a
+//	    role: operator
+//	    # The token only manages the modules of the tenant.
+//	    tenant: team_a
+//	    token: <token>
+//	  - name: agent
+//	    role: agent
+//	    token: <token>
+type tokensFile struct {
+	Tokens []struct {
+		// Identifies the token's user in logs.
+		Name string `yaml:"name"`
+		Role string `yaml:"role"`
+		// The tenant whose modules the token manages, all tenants' if empty.
+		Tenant string `yaml:"tenant"`
+		// Either the plain token, or its SHA-256 digest, which keeps the token secret from the readers of the file.
+		Token  string `yaml:"token"`
+		SHA256 string `yaml:"sha256"`
+	} `yaml:"tokens"`
+}
+
+// apiToken is an API token loaded from the tokens file.
+type apiToken struct {
+	principal Principal
+	digest    []byte
+}
+
+// loadTokens returns the API tokens in the YAML file at path.
+func loadTokens(path string) ([]apiToken, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("while loading API tokens, failed to read '%s', error: %v", path, err)
+	}
+	var file tokensFile
+	err = yaml.UnmarshalStrict(data, &file)
+	if err != nil {
+		return nil, fmt.Errorf("while loading API tokens, failed to parse '%s', error: %v", path, err)
+	}
+	tokens := make([]apiToken, 0, len(file.Tokens))
+	names := make(map[string]bool, len(file.Tokens))
+	for i, t := range file.Tokens {
+		if len(t.Name) == 0 {
+			return nil, fmt.Errorf("while loading API tokens, the name of token #%d is empty", i)
+		}
+		if names[t.Name] {
+			return nil, fmt.Errorf("while loading API tokens, token name '%s' is duplicated", t.Name)
+		}
+		names[t.Name] = true
+		role, err := ParseRole(t.Role)
+		if err != nil {
+			return nil, fmt.Errorf("while loading API tokens, invalid role of token '%s', error: %v", t.Name, err)
+		}
+		if len(t.Tenant) > 0 {
+			if err := tenant.ValidateName(t.Tenant); err != nil {
+				return nil, fmt.Errorf("while loading API tokens, invalid tenant of token '%s', error: %v", t.Name, err)
+			}
+		}
+		if (len(t.Token) == 0) == (len(t.SHA256) == 0) {
+			return nil, fmt.Errorf("while loading API tokens, token '%s' must have either token or sha256", t.Name)
+		}
+		var digest []byte
+		if len(t.Token) > 0 {
+			sum := sha256.Sum256([]byte(t.Token))
+			digest = sum[:]
+		} else {
+			digest, err = hex.DecodeString(t.SHA256)
+			if err != nil || len(digest) != sha256.Size {
+				return nil, fmt.Errorf("while loading API tokens, sha256 of token '%s' is not a hex SHA-256 digest",
+					t.Name)
+			}
+		}
+		tokens = append(tokens, apiToken{principal: Principal{Name: t.Name, Role: role, Tenant: t.Tenant}, digest: digest})
+	}
+	return tokens, nil
+}
+
+// findToken returns the principal of the API token, or nil if it is not one of the tokens.
+func findToken(tokens []apiToken, token string) *Principal {
+	sum := sha256.Sum256([]byte(token))
+	var found *Principal
+	for i := range tokens {
+		// Compares all tokens in constant time, so the timing does not reveal how much of a digest matches.
+		if subtle.ConstantTimeCompare(tokens[i].digest, sum[:]) == 1 {
+			found = &tokens[i].principal
+		}
+	}
+	return found
+}
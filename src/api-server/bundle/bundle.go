@@ -0,0 +1,123 @@
+// Copyright (C) 2023  Tricorder Observability
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+// Package bundle reads and writes modules as single *.tar.gz files, which have the module's manifest, BCC code, WASM
+// binary code, and optionally the WASM source code. The bundles version modules in git, and move them between
+// clusters.
+package bundle
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"google.golang.org/protobuf/proto"
+
+	modulepb "github.com/tricorder/src/pb/module"
+	commonpb "github.com/tricorder/src/pb/module/common"
+	ebpfpb "github.com/tricorder/src/pb/module/ebpf"
+	wasmpb "github.com/tricorder/src/pb/module/wasm"
+	"github.com/tricorder/src/utils/tar"
+)
+
+// The files of a bundle.
+const (
+	ManifestFile   = "manifest.json"
+	BCCFile        = "module.bcc.c"
+	WASMFile       = "module.wasm"
+	WASMSourceFile = "module.wasm.c"
+)
+
+// MaxSize is the maximal total size of the files of a bundle.
+const MaxSize = 64 << 20
+
+// Module is the module in a bundle. The manifest is the module without its code, in the format of the module JSON
+// files like modules/sample_json/manifest.json, and the code is in the other files.
+type Module struct {
+	Name string `json:"name"`
+	// The eBPF program, whose code is the BCC code.
+	Ebpf *ebpfpb.Program `json:"ebpf"`
+	// The WASM program, whose code is the WASM binary code.
+	Wasm *wasmpb.Program `json:"wasm"`
+	// Optional, the signature of the eBPF code and the WASM binary code.
+	Signature *modulepb.Signature `json:"signature,omitempty"`
+	// Optional, the C source code that was compiled into the WASM binary code.
+	WasmSource []byte `json:"-"`
+}
+
+// Write writes the module to w as a bundle.
+func Write(w io.Writer, m *Module) error {
+	if m.Ebpf == nil || m.Wasm == nil {
+		return fmt.Errorf("while writing bundle, module '%s' has no eBPF or WASM program", m.Name)
+	}
+	manifest := *m
+	manifest.Ebpf = proto.Clone(m.Ebpf).(*ebpfpb.Program)
+	manifest.Ebpf.Code = ""
+	manifest.Wasm = proto.Clone(m.Wasm).(*wasmpb.Program)
+	manifest.Wasm.Code = nil
+	manifest.Wasm.Fmt = commonpb.Format_BINARY
+	manifestJSON, err := json.MarshalIndent(&manifest, "", "  ")
+	if err != nil {
+		return fmt.Errorf("while writing bundle, failed to marshal manifest, error: %v", err)
+	}
+
+	files := []tar.File{
+		{Name: ManifestFile, Content: append(manifestJSON, '\n')},
+		{Name: BCCFile, Content: []byte(m.Ebpf.Code)},
+		{Name: WASMFile, Content: m.Wasm.Code},
+	}
+	if len(m.WasmSource) > 0 {
+		files = append(files, tar.File{Name: WASMSourceFile, Content: m.WasmSource})
+	}
+	if err := tar.GZWrite(w, files); err != nil {
+		return fmt.Errorf("while writing bundle, error: %v", err)
+	}
+	return nil
+}
+
+// Read reads the module from the bundle in r. The module's WASM code is the WASM binary code.
+func Read(r io.Reader) (*Module, error) {
+	files, err := tar.GZRead(r, MaxSize)
+	if err != nil {
+		return nil, fmt.Errorf("while reading bundle, error: %v", err)
+	}
+	contents := make(map[string][]byte, len(files))
+	for _, f := range files {
+		switch f.Name {
+		case ManifestFile, BCCFile, WASMFile, WASMSourceFile:
+			contents[f.Name] = f.Content
+		default:
+			return nil, fmt.Errorf("while reading bundle, unknown file '%s'", f.Name)
+		}
+	}
+	for _, name := range []string{ManifestFile, BCCFile, WASMFile} {
+		if _, ok := contents[name]; !ok {
+			return nil, fmt.Errorf("while reading bundle, missing file '%s'", name)
+		}
+	}
+
+	m := new(Module)
+	if err := json.Unmarshal(contents[ManifestFile], m); err != nil {
+		return nil, fmt.Errorf("while reading bundle, failed to unmarshal %s, error: %v", ManifestFile, err)
+	}
+	if m.Ebpf == nil || m.Wasm == nil {
+		return nil, fmt.Errorf("while reading bundle, %s has no ebpf or wasm", ManifestFile)
+	}
+	m.Ebpf.Code = string(contents[BCCFile])
+	m.Wasm.Code = contents[WASMFile]
+	m.Wasm.Fmt = commonpb.Format_BINARY
+	m.WasmSource = contents[WASMSourceFile]
+	return m, nil
+}
@@ -0,0 +1,106 @@
+// Copyright (C) 2023  Tricorder Observability
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package bundle
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+
+	modulepb "github.com/tricorder/src/pb/module"
+	commonpb "github.com/tricorder/src/pb/module/common"
+	ebpfpb "github.com/tricorder/src/pb/module/ebpf"
+	wasmpb "github.com/tricorder/src/pb/module/wasm"
+	"github.com/tricorder/src/utils/tar"
+)
+
+func newModule() *Module {
+	return &Module{
+		Name: "sample",
+		Ebpf: &ebpfpb.Program{
+			Code:           "BPF_PERF_OUTPUT(events);",
+			PerfBufferName: "events",
+			Probes:         []*ebpfpb.ProbeSpec{{Target: "do_sys_open", Entry: "sample_entry"}},
+		},
+		Wasm: &wasmpb.Program{
+			Fmt:    commonpb.Format_BINARY,
+			Code:   []byte("\x00asm"),
+			FnName: "write",
+			OutputSchema: &commonpb.Schema{
+				Fields: []*commonpb.DataField{{Name: "data", Type: commonpb.DataField_JSONB}},
+			},
+		},
+		Signature: &modulepb.Signature{KeyId: "key", Value: []byte("signature")},
+	}
+}
+
+// Tests that Read() reads the module written by Write(), whose manifest has no code.
+func TestWriteRead(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	m := newModule()
+	m.WasmSource = []byte("int write() { return 0; }")
+	var buf bytes.Buffer
+	require.Nil(Write(&buf, m))
+	// The module is not changed.
+	assert.Equal("BPF_PERF_OUTPUT(events);", m.Ebpf.Code)
+
+	files, err := tar.GZRead(bytes.NewReader(buf.Bytes()), MaxSize)
+	require.Nil(err)
+	require.Len(files, 4)
+	assert.Equal(ManifestFile, files[0].Name)
+	assert.NotContains(string(files[0].Content), "BPF_PERF_OUTPUT")
+	assert.Contains(string(files[0].Content), `"fn_name": "write"`)
+
+	read, err := Read(bytes.NewReader(buf.Bytes()))
+	require.Nil(err)
+	assert.Equal(m.Name, read.Name)
+	assert.True(proto.Equal(m.Ebpf, read.Ebpf))
+	assert.True(proto.Equal(m.Wasm, read.Wasm))
+	assert.True(proto.Equal(m.Signature, read.Signature))
+	assert.Equal(m.WasmSource, read.WasmSource)
+
+	buf.Reset()
+	require.Nil(Write(&buf, newModule()))
+	read, err = Read(bytes.NewReader(buf.Bytes()))
+	require.Nil(err)
+	assert.Nil(read.WasmSource)
+}
+
+// Tests that Read() rejects the bundles with missing or unknown files.
+func TestReadInvalid(t *testing.T) {
+	assert := assert.New(t)
+
+	read := func(files ...tar.File) error {
+		var buf bytes.Buffer
+		assert.Nil(tar.GZWrite(&buf, files))
+		_, err := Read(&buf)
+		return err
+	}
+	manifest := tar.File{Name: ManifestFile, Content: []byte(`{"name": "sample", "ebpf": {}, "wasm": {}}`)}
+	assert.Nil(read(manifest, tar.File{Name: BCCFile}, tar.File{Name: WASMFile}))
+	assert.ErrorContains(read(manifest, tar.File{Name: BCCFile}), "missing file 'module.wasm'")
+	assert.ErrorContains(read(manifest, tar.File{Name: BCCFile}, tar.File{Name: WASMFile}, tar.File{Name: "x"}),
+		"unknown file 'x'")
+	assert.ErrorContains(read(tar.File{Name: ManifestFile, Content: []byte(`{"name": "sample"}`)},
+		tar.File{Name: BCCFile}, tar.File{Name: WASMFile}), "has no ebpf or wasm")
+	_, err := Read(bytes.NewReader([]byte("not a bundle")))
+	assert.ErrorContains(err, "while reading bundle")
+}
@@ -0,0 +1,320 @@
+// Copyright (C) 2023  Tricorder Observability
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+// Package catalog loads the catalog of the modules bundled with API Server, like the modules in modules/, which are
+// created with POST /api/catalog/{name} or `starship-cli module create --from-catalog`.
+package catalog
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+
+	"google.golang.org/protobuf/proto"
+	"gopkg.in/yaml.v2"
+
+	"github.com/tricorder/src/api-server/bundle"
+	commonpb "github.com/tricorder/src/pb/module/common"
+	ebpfpb "github.com/tricorder/src/pb/module/ebpf"
+	wasmpb "github.com/tricorder/src/pb/module/wasm"
+)
+
+// EntryFile is the file in each directory of the catalog directory that describes a catalog entry.
+const EntryFile = "catalog.yaml"
+
+// The types of the parameters.
+const (
+	TypeInt    = "int"
+	TypeBool   = "bool"
+	TypeString = "string"
+)
+
+var (
+	// The entry names are the default names of the created modules.
+	nameRegexp = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+	// The parameters are C macros.
+	paramRegexp = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+)
+
+// Parameter is a C macro defined in the BCC code of a catalog entry, whose value is set when creating the module.
+type Parameter struct {
+	Name        string `yaml:"name" json:"name"`
+	Description string `yaml:"description" json:"description,omitempty"`
+	// One of "int", "bool" and "string".
+	Type string `yaml:"type" json:"type"`
+	// The value used if none is set, the parameter is required if it has no default value.
+	Default *string `yaml:"default" json:"default,omitempty"`
+}
+
+// Entry is a module in the catalog.
+type Entry struct {
+	Name        string `yaml:"name" json:"name"`
+	Description string `yaml:"description" json:"description"`
+	// The kernel features that the module's probes need, like kprobe or perf_event, for the users to pick modules
+	// that work on their nodes.
+	KernelFeatures []string    `yaml:"kernel_features" json:"kernel_features"`
+	Parameters     []Parameter `yaml:"parameters" json:"parameters"`
+
+	// The path of the bundle, relative to the entry's directory; or the paths of the module JSON file, the BCC code
+	// and the WASM binary code, if the module is not bundled.
+	Bundle   string `yaml:"bundle" json:"-"`
+	Manifest string `yaml:"manifest" json:"-"`
+	BCC      string `yaml:"bcc" json:"-"`
+	WASM     string `yaml:"wasm" json:"-"`
+
+	module *bundle.Module
+}
+
+// load returns an error if the entry is invalid, and loads its module from the files in dir.
+func (e *Entry) load(dir string) error {
+	if !nameRegexp.MatchString(e.Name) {
+		return fmt.Errorf("invalid name '%s', must be lower case letters, digits and underscores, "+
+			"and start with a letter", e.Name)
+	}
+	params := make(map[string]bool, len(e.Parameters))
+	for _, p := range e.Parameters {
+		if !paramRegexp.MatchString(p.Name) {
+			return fmt.Errorf("invalid parameter name '%s', must be a C identifier", p.Name)
+		}
+		if params[p.Name] {
+			return fmt.Errorf("duplicate parameter '%s'", p.Name)
+		}
+		params[p.Name] = true
+		switch p.Type {
+		case TypeInt, TypeBool, TypeString:
+		default:
+			return fmt.Errorf("parameter '%s' has unknown type '%s', must be int, bool or string", p.Name, p.Type)
+		}
+		if p.Default != nil {
+			if _, err := p.literal(*p.Default); err != nil {
+				return fmt.Errorf("invalid default value of parameter '%s', error: %v", p.Name, err)
+			}
+		}
+	}
+
+	m, err := e.loadModule(dir)
+	if err != nil {
+		return err
+	}
+	if m.Wasm.OutputSchema == nil {
+		return fmt.Errorf("module has no wasm.output_schema")
+	}
+	e.module = m
+	return nil
+}
+
+// loadModule returns the entry's module from the bundle or the module files in dir.
+func (e *Entry) loadModule(dir string) (*bundle.Module, error) {
+	if e.Bundle != "" {
+		if e.Manifest != "" || e.BCC != "" || e.WASM != "" {
+			return nil, fmt.Errorf("bundle and manifest, bcc, wasm are mutually exclusive")
+		}
+		f, err := os.Open(filepath.Join(dir, e.Bundle))
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		m, err := bundle.Read(f)
+		if err != nil {
+			return nil, err
+		}
+		// The catalog modules are created from the WASM binary code, which API Server does not compile.
+		m.WasmSource = nil
+		return m, nil
+	}
+
+	if e.Manifest == "" || e.BCC == "" || e.WASM == "" {
+		return nil, fmt.Errorf("either bundle, or manifest, bcc and wasm are required")
+	}
+	manifest, err := os.ReadFile(filepath.Join(dir, e.Manifest))
+	if err != nil {
+		return nil, err
+	}
+	m := new(bundle.Module)
+	if err := json.Unmarshal(manifest, m); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal %s, error: %v", e.Manifest, err)
+	}
+	if m.Ebpf == nil || m.Wasm == nil {
+		return nil, fmt.Errorf("%s has no ebpf or wasm", e.Manifest)
+	}
+	bcc, err := os.ReadFile(filepath.Join(dir, e.BCC))
+	if err != nil {
+		return nil, err
+	}
+	m.Ebpf.Code = string(bcc)
+	m.Wasm.Code, err = os.ReadFile(filepath.Join(dir, e.WASM))
+	if err != nil {
+		return nil, err
+	}
+	m.Wasm.Fmt = commonpb.Format_BINARY
+	return m, nil
+}
+
+// literal returns the C literal of the value of the parameter.
+func (p *Parameter) literal(value string) (string, error) {
+	switch p.Type {
+	case TypeInt:
+		n, err := strconv.ParseInt(value, 0, 64)
+		if err != nil {
+			return "", fmt.Errorf("'%s' is not an int", value)
+		}
+		// Go's spellings like 1_000 and 0o17 are not valid in C.
+		return strconv.FormatInt(n, 10), nil
+	case TypeBool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return "", fmt.Errorf("'%s' is not a bool", value)
+		}
+		if b {
+			return "1", nil
+		}
+		return "0", nil
+	case TypeString:
+		return strconv.Quote(value), nil
+	default:
+		return "", fmt.Errorf("unknown type '%s', must be int, bool or string", p.Type)
+	}
+}
+
+// Instantiate returns the module of the entry, whose BCC code defines the parameters with the values, or with their
+// default values if not in values. The signature of the module is dropped if the BCC code is changed.
+func (e *Entry) Instantiate(values map[string]string) (*bundle.Module, error) {
+	params := make(map[string]bool, len(e.Parameters))
+	for _, p := range e.Parameters {
+		params[p.Name] = true
+	}
+	for name := range values {
+		if !params[name] {
+			return nil, fmt.Errorf("catalog module '%s' has no parameter '%s'", e.Name, name)
+		}
+	}
+
+	code := e.module.Ebpf.Code
+	for _, p := range e.Parameters {
+		value, ok := values[p.Name]
+		if !ok {
+			if p.Default == nil {
+				return nil, fmt.Errorf("parameter '%s' of catalog module '%s' is required", p.Name, e.Name)
+			}
+			value = *p.Default
+		}
+		literal, err := p.literal(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value of parameter '%s' of catalog module '%s', error: %v",
+				p.Name, e.Name, err)
+		}
+		code = define(code, p.Name, literal)
+	}
+
+	m := *e.module
+	m.Ebpf = proto.Clone(e.module.Ebpf).(*ebpfpb.Program)
+	m.Ebpf.Code = code
+	m.Wasm = proto.Clone(e.module.Wasm).(*wasmpb.Program)
+	if code != e.module.Ebpf.Code {
+		m.Signature = nil
+	}
+	return &m, nil
+}
+
+// define returns the code with the macro defined as the value. The existing definition of the macro is replaced,
+// otherwise the definition is prepended to the code.
+func define(code, name, value string) string {
+	definition := "#define " + name + " " + value
+	re := regexp.MustCompile(`(?m)^[ \t]*#[ \t]*define[ \t]+` + name + `([ \t].*)?$`)
+	if re.MatchString(code) {
+		return re.ReplaceAllLiteralString(code, definition)
+	}
+	return definition + "\n" + code
+}
+
+// Catalog is the entries loaded from a catalog directory.
+type Catalog struct {
+	entries []*Entry
+	byName  map[string]*Entry
+}
+
+// Load returns the catalog of the entries in the subdirectories of dir, each described by its catalog.yaml, for
+// example:
+//
+//	name: ddos_event
+//	description: Detects DDoS attacks from the rate of the received packets.
+//	kernel_features: [kprobe]
+//	manifest: module.json
+//	bcc: ddos_event.bcc
+//	wasm: write_events_to_output.wasm
+//	parameters:
+//	  - name: MAX_NB_PACKETS
+//	    type: int
+//	    default: 1000
+//
+// The subdirectories without catalog.yaml are ignored. The catalog is empty if dir does not exist.
+func Load(dir string) (*Catalog, error) {
+	c := &Catalog{byName: make(map[string]*Entry)}
+	dirEntries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("while loading catalog, failed to read directory '%s', error: %v", dir, err)
+	}
+	for _, d := range dirEntries {
+		if !d.IsDir() {
+			continue
+		}
+		entryDir := filepath.Join(dir, d.Name())
+		entryFile := filepath.Join(entryDir, EntryFile)
+		content, err := os.ReadFile(entryFile)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("while loading catalog, failed to read '%s', error: %v", entryFile, err)
+		}
+		e := new(Entry)
+		if err := yaml.UnmarshalStrict(content, e); err != nil {
+			return nil, fmt.Errorf("while loading catalog, failed to parse '%s', error: %v", entryFile, err)
+		}
+		if err := e.load(entryDir); err != nil {
+			return nil, fmt.Errorf("while loading catalog, invalid entry '%s', error: %v", entryFile, err)
+		}
+		if _, ok := c.byName[e.Name]; ok {
+			return nil, fmt.Errorf("while loading catalog, duplicate entry '%s' in '%s'", e.Name, entryFile)
+		}
+		c.byName[e.Name] = e
+		c.entries = append(c.entries, e)
+	}
+	sort.Slice(c.entries, func(i, j int) bool { return c.entries[i].Name < c.entries[j].Name })
+	return c, nil
+}
+
+// List returns the entries sorted by name. A nil Catalog has no entries.
+func (c *Catalog) List() []*Entry {
+	if c == nil {
+		return nil
+	}
+	return c.entries
+}
+
+// Get returns the entry of the name, or nil if there is none.
+func (c *Catalog) Get(name string) *Entry {
+	if c == nil {
+		return nil
+	}
+	return c.byName[name]
+}
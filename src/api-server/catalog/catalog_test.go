@@ -0,0 +1,159 @@
+// Copyright (C) 2023  Tricorder Observability
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package catalog
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/tricorder/src/api-server/bundle"
+	modulepb "github.com/tricorder/src/pb/module"
+	commonpb "github.com/tricorder/src/pb/module/common"
+	ebpfpb "github.com/tricorder/src/pb/module/ebpf"
+	wasmpb "github.com/tricorder/src/pb/module/wasm"
+)
+
+// writeFiles writes the files into the subdirectory of dir.
+func writeFiles(t *testing.T, dir, subdir string, files map[string]string) {
+	require.Nil(t, os.MkdirAll(filepath.Join(dir, subdir), 0o755))
+	for name, content := range files {
+		require.Nil(t, os.WriteFile(filepath.Join(dir, subdir, name), []byte(content), 0o644))
+	}
+}
+
+// Tests that Load() loads the entries from the module files and the bundles, and Instantiate() sets the parameters.
+func TestLoadInstantiate(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	dir := t.TempDir()
+	writeFiles(t, dir, "files", map[string]string{
+		EntryFile: `
+name: files
+description: From files.
+kernel_features: [kprobe]
+manifest: module.json
+bcc: module.bcc
+wasm: module.wasm
+parameters:
+  - name: LIMIT
+    type: int
+    default: 10
+  - name: ENABLED
+    type: bool
+    default: true
+  - name: PREFIX
+    type: string
+`,
+		"module.json": `{"name": "m", "ebpf": {"perf_buffer_name": "events"},
+			"wasm": {"fn_name": "fn", "output_schema": {"fields": [{"name": "data", "type": 5}]}}}`,
+		"module.bcc":  "#include <linux/ptrace.h>\n#define LIMIT 1\nint f() { return LIMIT; }\n",
+		"module.wasm": "wasm",
+	})
+	var b bytes.Buffer
+	require.Nil(bundle.Write(&b, &bundle.Module{
+		Name:       "b",
+		Ebpf:       &ebpfpb.Program{Code: "ebpf"},
+		Wasm:       &wasmpb.Program{Code: []byte("wasm"), FnName: "fn", OutputSchema: &commonpb.Schema{}},
+		Signature:  &modulepb.Signature{KeyId: "key"},
+		WasmSource: []byte("source"),
+	}))
+	writeFiles(t, dir, "bundled", map[string]string{
+		EntryFile:       "name: bundled\nbundle: b.tar.gz\n",
+		"b.tar.gz":      b.String(),
+		"unrelated.txt": "",
+	})
+	writeFiles(t, dir, "other", map[string]string{"README.md": ""})
+
+	c, err := Load(dir)
+	require.Nil(err)
+	require.Len(c.List(), 2)
+	assert.Equal("bundled", c.List()[0].Name)
+	assert.Equal("files", c.List()[1].Name)
+	assert.Nil(c.Get("other"))
+
+	e := c.Get("files")
+	require.NotNil(e)
+	assert.Equal([]string{"kprobe"}, e.KernelFeatures)
+	_, err = e.Instantiate(nil)
+	assert.ErrorContains(err, "parameter 'PREFIX' of catalog module 'files' is required")
+	_, err = e.Instantiate(map[string]string{"PREFIX": "p", "UNKNOWN": "1"})
+	assert.ErrorContains(err, "has no parameter 'UNKNOWN'")
+	_, err = e.Instantiate(map[string]string{"PREFIX": "p", "LIMIT": "x"})
+	assert.ErrorContains(err, "'x' is not an int")
+
+	m, err := e.Instantiate(map[string]string{"PREFIX": `a"b`, "ENABLED": "false"})
+	require.Nil(err)
+	assert.Equal("#define PREFIX \"a\\\"b\"\n#define ENABLED 0\n#include <linux/ptrace.h>\n#define LIMIT 10\n"+
+		"int f() { return LIMIT; }\n", m.Ebpf.Code)
+	assert.Equal("events", m.Ebpf.PerfBufferName)
+	assert.Equal("fn", m.Wasm.FnName)
+	assert.Equal([]byte("wasm"), m.Wasm.Code)
+	m, err = e.Instantiate(map[string]string{"PREFIX": "p", "LIMIT": "0o17"})
+	require.Nil(err)
+	assert.Contains(m.Ebpf.Code, "#define LIMIT 15\n")
+	m, err = e.Instantiate(map[string]string{"PREFIX": "p", "LIMIT": "1_000"})
+	require.Nil(err)
+	assert.Contains(m.Ebpf.Code, "#define LIMIT 1000\n")
+	// The entry's module is not changed.
+	assert.Contains(e.module.Ebpf.Code, "#define LIMIT 1\n")
+
+	// The signature is kept if the code is not changed, the WASM source code is dropped.
+	m, err = c.Get("bundled").Instantiate(nil)
+	require.Nil(err)
+	assert.Equal("ebpf", m.Ebpf.Code)
+	assert.Equal("key", m.Signature.KeyId)
+	assert.Nil(m.WasmSource)
+}
+
+// Tests that Load() returns errors for the invalid entries.
+func TestLoadErrors(t *testing.T) {
+	assert := assert.New(t)
+
+	c, err := Load(filepath.Join(t.TempDir(), "missing"))
+	assert.Nil(err)
+	assert.Empty(c.List())
+
+	for _, tc := range []struct {
+		entry string
+		err   string
+	}{
+		{"name: Bad\nbundle: b.tar.gz\n", "invalid name 'Bad'"},
+		{"name: e\n", "either bundle, or manifest, bcc and wasm are required"},
+		{"name: e\nbundle: b.tar.gz\nbcc: m.bcc\n", "mutually exclusive"},
+		{"name: e\nunknown: 1\n", "failed to parse"},
+		{"name: e\nparameters: [{name: 1X, type: int}]\n", "must be a C identifier"},
+		{"name: e\nparameters: [{name: X, type: float}]\n", "unknown type 'float'"},
+		{"name: e\nparameters: [{name: X, type: int, default: x}]\n", "invalid default value"},
+		{"name: e\nparameters: [{name: X, type: int}, {name: X, type: int}]\n", "duplicate parameter 'X'"},
+		{"name: e\nbundle: b.tar.gz\n", "no such file"},
+		{"name: e\nmanifest: e.json\nbcc: " + EntryFile + "\nwasm: " + EntryFile + "\n", "no ebpf or wasm"},
+		{"name: e\nmanifest: s.json\nbcc: " + EntryFile + "\nwasm: " + EntryFile + "\n", "no wasm.output_schema"},
+	} {
+		dir := t.TempDir()
+		writeFiles(t, dir, "e", map[string]string{EntryFile: tc.entry,
+			"e.json": `{"name": "e"}`,
+			"s.json": `{"name": "e", "ebpf": {}, "wasm": {}}`,
+		})
+		_, err := Load(dir)
+		assert.ErrorContains(err, tc.err, tc.entry)
+	}
+}
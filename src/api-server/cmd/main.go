@@ -16,25 +16,31 @@
 package main
 
 import (
+	"crypto/ed25519"
 	"flag"
 
 	"golang.org/x/sync/errgroup"
+	"google.golang.org/grpc"
 	"k8s.io/client-go/kubernetes"
 	ctrl "sigs.k8s.io/controller-runtime"
 
+	"github.com/tricorder/src/api-server/auth"
+	"github.com/tricorder/src/api-server/catalog"
 	sg "github.com/tricorder/src/api-server/grpc"
 	"github.com/tricorder/src/api-server/http"
 	"github.com/tricorder/src/api-server/http/dao"
 	"github.com/tricorder/src/api-server/http/docs"
 	"github.com/tricorder/src/api-server/meta"
+	"github.com/tricorder/src/api-server/tenant"
+	"github.com/tricorder/src/api-server/utils/channel"
 	"github.com/tricorder/src/api-server/wasm"
-	"github.com/tricorder/src/utils/cond"
 	"github.com/tricorder/src/utils/errors"
 	grpcutils "github.com/tricorder/src/utils/grpc"
 	"github.com/tricorder/src/utils/lock"
 	"github.com/tricorder/src/utils/log"
 	"github.com/tricorder/src/utils/pg"
 	"github.com/tricorder/src/utils/retry"
+	"github.com/tricorder/src/utils/signing"
 	"github.com/tricorder/src/utils/sys"
 )
 
@@ -85,6 +91,38 @@ var (
 		"timescaledb_tricorder",
 		"The uid of datasource in grafana to postgres database",
 	)
+
+	// Authentication is disabled if neither --auth_tokens_file nor --auth_jwks_file is set.
+	authTokensFile = flag.String("auth_tokens_file", "", "The path to the YAML file of the API tokens and their "+
+		"roles (viewer, operator, admin or agent), which authenticate the requests to the HTTP API and gRPC services")
+	authJWKSFile = flag.String("auth_jwks_file", "", "The path to the JWKS file, whose keys verify the JWT bearer "+
+		"tokens, like the OIDC ID tokens of an identity provider")
+	authJWTIssuer   = flag.String("auth_jwt_issuer", "", "The issuer of the JWT bearer tokens, not checked if empty")
+	authJWTAudience = flag.String("auth_jwt_audience", "", "The audience of the JWT bearer tokens, not checked if "+
+		"empty")
+	authJWTRoleClaim = flag.String("auth_jwt_role_claim", auth.DefaultRoleClaim, "The claim of the JWT bearer "+
+		"tokens with the role, or a list that includes the role, like the groups of the user")
+	authJWTTenantClaim = flag.String("auth_jwt_tenant_claim", auth.DefaultTenantClaim, "The claim of the JWT "+
+		"bearer tokens with the tenant, the tokens without the claim manage the modules of all tenants")
+
+	tenantsFile = flag.String("tenants_file", "", "The path to the YAML file of the tenants and the nodes they own, "+
+		"the modules of a tenant are only deployed onto its nodes; every tenant owns all nodes if empty")
+
+	// The gRPC services serve mutual TLS if any of the files is set.
+	grpcTLSCertFile     = flag.String("grpc_tls_cert_file", "", "The path to the PEM certificate of the gRPC services")
+	grpcTLSKeyFile      = flag.String("grpc_tls_key_file", "", "The path to the PEM private key of --grpc_tls_cert_file")
+	grpcTLSClientCAFile = flag.String("grpc_tls_client_ca_file", "", "The path to the PEM CA certificates that "+
+		"issue the agents' certificates, the agents without such certificates are rejected")
+
+	moduleSigningKeyFile = flag.String("module_signing_key_file", "", "The path to the PEM ed25519 private key that "+
+		"signs the modules created without signatures, the agents with the matching public key load the modules")
+	moduleTrustedKeysFile = flag.String("module_trusted_keys_file", "", "The path to the PEM ed25519 public keys "+
+		"that verify the signatures of the created modules, the unsigned or invalidly signed modules are rejected")
+	validateModules = flag.Bool("validate_modules", false, "If true, the created and updated modules are rejected "+
+		"if their WASM code does not export the function and the io.h functions, or their probes attach functions "+
+		"not defined in the BCC code, like POST /api/module:validate")
+	catalogDir = flag.String("catalog_dir", "/opt/tricorder/catalog", "The path to the directory of the modules "+
+		"bundled with API Server, each in a subdirectory with a catalog.yaml; the catalog is empty if it does not exist")
 )
 
 func setupSwaggerInfo() {
@@ -118,8 +156,48 @@ func main() {
 		log.Fatalf("While starting API Server, failed to initialize SQLite database, error: %v", err)
 	}
 
+	authenticator, err := auth.NewAuthenticator(auth.Config{
+		TokensFile:  *authTokensFile,
+		JWKSFile:    *authJWKSFile,
+		Issuer:      *authJWTIssuer,
+		Audience:    *authJWTAudience,
+		RoleClaim:   *authJWTRoleClaim,
+		TenantClaim: *authJWTTenantClaim,
+	})
+	if err != nil {
+		log.Fatalf("While starting API Server, failed to initialize authentication, error: %v", err)
+	}
+
+	var moduleSigningKey ed25519.PrivateKey
+	if len(*moduleSigningKeyFile) > 0 {
+		moduleSigningKey, err = signing.LoadPrivateKey(*moduleSigningKeyFile)
+		if err != nil {
+			log.Fatalf("While starting API Server, failed to load module signing key, error: %v", err)
+		}
+	}
+	var moduleVerifier *signing.Verifier
+	if len(*moduleTrustedKeysFile) > 0 {
+		moduleVerifier, err = signing.LoadVerifier(*moduleTrustedKeysFile)
+		if err != nil {
+			log.Fatalf("While starting API Server, failed to load module trusted keys, error: %v", err)
+		}
+	}
+	var tenants *tenant.Tenants
+	if len(*tenantsFile) > 0 {
+		tenants, err = tenant.Load(*tenantsFile)
+		if err != nil {
+			log.Fatalf("While starting API Server, failed to load tenants, error: %v", err)
+		}
+	}
+
+	moduleCatalog, err := catalog.Load(*catalogDir)
+	if err != nil {
+		log.Fatalf("While starting API Server, failed to load module catalog, error: %v", err)
+	}
+	log.Infof("Loaded %d modules from catalog %s", len(moduleCatalog.List()), *catalogDir)
+
 	dao := dao.NewDao(sqliteClient)
-	waitCond := cond.NewCond()
+	dispatcher := channel.NewDispatcher()
 	gLock := lock.NewLock()
 
 	wasiCompiler := wasm.NewWASICompilerWithDefaults()
@@ -139,11 +217,30 @@ func main() {
 
 	if *enableGRPC {
 		srvErrGroup.Go(func() error {
-			f, err := grpcutils.NewServerFixture(*agentServicePort)
+			// Only agents connect to the gRPC services.
+			opts := []grpc.ServerOption{
+				grpc.ChainUnaryInterceptor(authenticator.UnaryServerInterceptor(auth.RoleAgent)),
+				grpc.ChainStreamInterceptor(authenticator.StreamServerInterceptor(auth.RoleAgent)),
+			}
+			tlsFiles := grpcutils.TLSFiles{
+				CertFile: *grpcTLSCertFile,
+				KeyFile:  *grpcTLSKeyFile,
+				CAFile:   *grpcTLSClientCAFile,
+			}
+			if tlsFiles.Enabled() {
+				creds, err := grpcutils.NewServerTLS(tlsFiles)
+				if err != nil {
+					return errors.Wrap("starting gRPC server", "load TLS certificates", err)
+				}
+				opts = append(opts, grpc.Creds(creds))
+			} else {
+				log.Warnf("gRPC services serve plaintext, anyone who can reach them can register as an agent")
+			}
+			f, err := grpcutils.NewServerFixture(*agentServicePort, opts...)
 			if err != nil {
 				return errors.Wrap("starting gRPC server", "create server fixture", err)
 			}
-			sg.RegisterModuleDeployerServer(f, sqliteClient, gLock, waitCond)
+			sg.RegisterModuleDeployerServer(f, sqliteClient, gLock, dispatcher)
 			if *enableMetadataService {
 				sg.RegisterProcessCollectorServer(f, clientset, pgClient)
 			}
@@ -171,9 +268,18 @@ func main() {
 				Module:          dao.Module,
 				NodeAgent:       dao.NodeAgent,
 				ModuleInstance:  dao.ModuleInstance,
-				WaitCond:        waitCond,
+				ModuleVersion:   dao.ModuleVersion,
+				Audit:           dao.Audit,
+				Dispatcher:      dispatcher,
 				GLock:           gLock,
 				Standalone:      *standalone,
+				Auth:            authenticator,
+
+				ModuleSigningKey: moduleSigningKey,
+				ModuleVerifier:   moduleVerifier,
+				Tenants:          tenants,
+				ValidateModules:  *validateModules,
+				Catalog:          moduleCatalog,
 			}
 			return http.StartHTTPService(config, pgClient, wasiCompiler)
 		})
@@ -181,7 +287,7 @@ func main() {
 
 	if *enableMetadataService {
 		srvErrGroup.Go(func() error {
-			err := meta.StartWatchingResources(clientset, pgClient, &dao.NodeAgent, waitCond)
+			err := meta.StartWatchingResources(clientset, pgClient, &dao.NodeAgent)
 			if err != nil {
 				log.Fatalf("Could not start metadata service, error: %v", err)
 			}
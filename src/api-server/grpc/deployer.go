@@ -18,10 +18,12 @@ package grpc
 import (
 	"encoding/json"
 	"io"
+	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
 	"golang.org/x/sync/errgroup"
 
-	"github.com/tricorder/src/utils/cond"
 	"github.com/tricorder/src/utils/errors"
 	"github.com/tricorder/src/utils/lock"
 	"github.com/tricorder/src/utils/log"
@@ -29,6 +31,7 @@ import (
 
 	"github.com/tricorder/src/api-server/http/dao"
 	servicepb "github.com/tricorder/src/api-server/pb"
+	"github.com/tricorder/src/api-server/utils/channel"
 	modulepb "github.com/tricorder/src/pb/module"
 	"github.com/tricorder/src/pb/module/common"
 	ebpfpb "github.com/tricorder/src/pb/module/ebpf"
@@ -36,19 +39,34 @@ import (
 	grpcutils "github.com/tricorder/src/utils/grpc"
 )
 
+// The interval of re-listing all module instances of an agent.
+// Resync catches changes that were not dispatched to the agent's queue, for example when the queue was full.
+const defaultResyncPeriod = 30 * time.Second
+
+var connectedAgents = promauto.NewGauge(prometheus.GaugeOpts{
+	Name: "starship_api_server_connected_agents",
+	Help: "The number of agents that have an open streaming channel with the ModuleDeployer service.",
+})
+
 // Manages the deployment of eBPF+WASM modules.
 type Deployer struct {
 	// The DAO object that proxies with SQLite for writing and reading the serialized data.
 	Module         dao.ModuleDao
 	NodeAgent      dao.NodeAgentDao
 	ModuleInstance dao.ModuleInstanceDao
-	gLock          *lock.Lock
-	waitCond       *cond.Cond
-	// The list of agents connected with this Deployer.
+	ModuleVersion  dao.ModuleVersionDao
+	// Records the state transitions of agents and module instances.
+	Audit dao.AuditDao
+	gLock *lock.Lock
+
+	// Routes module instance changes made by the HTTP service to the connected agent that needs to act on them.
 	//
 	// Each agent and this Deployer maintains a gRPC streaming channel with DeployModuleReq & DeployModuleResp
-	// flow back-and-forth.
-	agents []*servicepb.Agent
+	// flow back-and-forth, the handler of the channel only wakes up for the changes of its own agent.
+	dispatcher *channel.Dispatcher
+
+	// The interval of re-listing all module instances of an agent.
+	ResyncPeriod time.Duration
 }
 
 func getDeployReqForModule(module *dao.ModuleGORM) (*servicepb.DeployModuleReq, error) {
@@ -76,6 +94,15 @@ func getDeployReqForModule(module *dao.ModuleGORM) (*servicepb.DeployModuleReq,
 		}
 	}
 
+	var sink *common.Sink
+	if len(module.Sink) > 0 {
+		sink = new(common.Sink)
+		err := json.Unmarshal([]byte(module.Sink), sink)
+		if err != nil {
+			return nil, errors.Wrap("creating DeployModuleReq for module", "unmarshal sink", err)
+		}
+	}
+
 	wasm := &wasmpb.Program{
 		Fmt:    common.Format(module.WasmFmt),
 		Lang:   common.Lang(module.WasmLang),
@@ -85,15 +112,24 @@ func getDeployReqForModule(module *dao.ModuleGORM) (*servicepb.DeployModuleReq,
 			Fields: fields,
 		},
 		Code: module.Wasm,
+		Sink: sink,
+	}
+
+	var signature *modulepb.Signature
+	if len(module.Signature) > 0 {
+		signature = &modulepb.Signature{KeyId: module.SignatureKeyID, Value: module.Signature}
 	}
 
 	codeReq := servicepb.DeployModuleReq{
 		ModuleId: module.ID,
 		Module: &modulepb.Module{
-			Ebpf: ebpf,
-			Wasm: wasm,
+			Name:      module.Name,
+			Ebpf:      ebpf,
+			Wasm:      wasm,
+			Signature: signature,
 		},
-		Deploy: servicepb.DeployModuleReq_DEPLOY,
+		Deploy:  servicepb.DeployModuleReq_DEPLOY,
+		Version: int32(module.Version),
 	}
 	return &codeReq, nil
 }
@@ -116,7 +152,11 @@ func (s *Deployer) DeployModule(stream servicepb.ModuleDeployer_DeployModuleServ
 	agentNodeName := in.Agent.NodeName
 	agentID := in.Agent.Id
 	agentPodId := in.Agent.PodId
+	var queue <-chan string
 	err = s.gLock.ExecWithLock(func() error {
+		// Registers while holding the lock, as unregisterAgent does, so that the old streaming channel of a
+		// reconnecting agent either sets it OFFLINE before it's set ONLINE below, or not at all.
+		queue = s.dispatcher.Register(agentID)
 		nodeAgentList, err := s.NodeAgent.ListByNodeName(agentNodeName)
 		if err != nil {
 			// TODO(jun): Need to distinguish between query failure and getting no results.
@@ -135,6 +175,7 @@ func (s *Deployer) DeployModule(stream servicepb.ModuleDeployer_DeployModuleServ
 			if err != nil {
 				return errors.Wrap("handling Agent grpc request", "save new online agent", err)
 			}
+			s.auditAgent(agentID, agentID, dao.AuditAgentOnline, "", servicepb.AgentState_ONLINE)
 			return nil
 		}
 
@@ -151,6 +192,8 @@ func (s *Deployer) DeployModule(stream servicepb.ModuleDeployer_DeployModuleServ
 				if err != nil {
 					return errors.Wrap("handling Agent grpc request", "set node agent state to TERMINATED", err)
 				}
+				s.auditAgent(agentID, node.AgentID, dao.AuditAgentTerminated,
+					servicepb.AgentState(node.State).String(), servicepb.AgentState_TERMINATED)
 			} else {
 				// because node.State is not ONLINE, we can assume it is TERMINATED.
 				// So we can update the state to ONLINE if the agent pod ID matches.
@@ -160,139 +203,236 @@ func (s *Deployer) DeployModule(stream servicepb.ModuleDeployer_DeployModuleServ
 					if err != nil {
 						return errors.Wrap("while handling Agent grpc request", "set node agent state to ONLINE", err)
 					}
+					s.auditAgent(agentID, agentID, dao.AuditAgentOnline,
+						servicepb.AgentState(node.State).String(), servicepb.AgentState_ONLINE)
 				}
 			}
 		}
 		return nil
 	})
+	defer s.unregisterAgent(agentID, queue)
 
 	if err != nil {
 		return errors.Wrap("handling agent grpc request", "update node agent state", err)
 	}
 
-	s.agents = append(s.agents, in.Agent)
+	connectedAgents.Inc()
+	defer connectedAgents.Dec()
 
 	// TODO(jun): handle the case where the node is not new, but the agent is restarted.
 
 	var eg errgroup.Group
+	// Closed when the agent stops sending responses, which means the streaming channel is no longer usable.
+	recvDone := make(chan struct{})
 	// Create a goroutine to check the response from the connected agent.
 	eg.Go(func() error {
+		defer close(recvDone)
 		for {
 			result, err := stream.Recv()
 			if err == io.EOF {
-				serr := s.gLock.ExecWithLock(func() error {
-					err = s.NodeAgent.UpdateStateByID(agentID, int(servicepb.AgentState_OFFLINE))
-					if err != nil {
-						return errors.Wrap("handling Agent grpc request", "set node agent state to OFFLINE", err)
-					}
-					return nil
-				})
-				if serr != nil {
-					log.Errorf("Failed to set agent state to OFFLINE, error: %v", serr)
-				}
+				s.unregisterAgent(agentID, queue)
 				log.Warnf("Agent closed connection, this should **only** happens during testing; stopping ...")
 				return nil
 			}
 			if err != nil {
-				serr := s.gLock.ExecWithLock(func() error {
-					err = s.NodeAgent.UpdateStateByID(agentID, int(servicepb.AgentState_OFFLINE))
-					if err != nil {
-						return errors.Wrap("handling Agent grpc request", "set node agent state to OFFLINE", err)
-					}
-					return nil
-				})
-				if serr != nil {
-					log.Errorf("Failed to set agent state to OFFLINE, error: %v", serr)
-				}
+				s.unregisterAgent(agentID, queue)
 				// If this happens, agent should re-initiate connection with API Server.
 				// API Server just close the handling function and wait for reconnection.
 				return errors.Wrap("handling DeployModule request", "receive mssage", err)
 			}
 			// TODO(yzhao): Should cache this result to an internal slice, and repeatively retry updating state.
 			// The current logic will drop this state and causes redeployment of the same module.
-			_ = s.gLock.ExecWithLock(func() error {
-				module, err := s.ModuleInstance.QueryByAgentIDAndModuleID(agentID, result.ModuleId)
-				if err != nil {
-					log.Errorf("locate module instance module error:%s", err.Error())
-					return nil
-				}
-				err = s.ModuleInstance.UpdateStatusByID(module.ID, int(result.State))
-				if err != nil {
-					log.Errorf("update code status error:%s", err.Error())
-				}
-				return nil
-			})
-		}
-	})
-
-	for {
-		s.waitCond.Wait()
-		var undeployList []dao.ModuleInstanceGORM
-		// TODO(jun): Should not ignore returned error.
-		_ = s.gLock.ExecWithLock(func() error {
-			// TODO(jun): Need to add error handling here.
-			undeployList, _ = s.ModuleInstance.ListByAgentID(agentID)
-			return nil
-		})
-
-		for _, moduleInstance := range undeployList {
-			var module *dao.ModuleGORM
-			if moduleInstance.State != int(servicepb.ModuleInstanceState_INIT) {
+			module, err := s.ModuleInstance.QueryByAgentIDAndModuleID(agentID, result.ModuleId)
+			if err != nil {
+				log.Errorf("locate module instance module error:%s", err.Error())
 				continue
 			}
-			err := s.gLock.ExecWithLock(func() error {
-				// TODO(jun): Need to add error handling here.
-				module, err = s.Module.QueryByID(moduleInstance.ModuleID)
-				if err != nil {
-					return errors.Wrap("handling DeployModule request", "query module", err)
-				}
-				return nil
-			})
+			err = s.ModuleInstance.UpdateStatusAndDescByID(module.ID, int(result.State), result.Desc)
 			if err != nil {
-				return err
-			}
-
-			moduleReq, err := getDeployReqForModule(module)
-			if moduleInstance.DesireState == int(servicepb.ModuleState_UNDEPLOYED) {
-				moduleReq.Deploy = servicepb.DeployModuleReq_UNDEPLOY
+				log.Errorf("update code status error:%s", err.Error())
+				continue
 			}
-			if err != nil {
-				log.Fatalf("Failed to create DeployModuleReq for module ID=%s, this should not happen, "+
-					"as module creation should validate module, error: %v", module.ID, err)
-				return err
+			var desc string
+			if result.State == servicepb.ModuleInstanceState_FAILED || result.State == servicepb.ModuleInstanceState_REJECTED {
+				desc = result.Desc
 			}
+			s.auditModuleInstance(dao.AuditAgentActor(agentID), module, result.State, desc)
+		}
+	})
 
-			err = stream.Send(moduleReq)
-			if err != nil {
-				serr := s.gLock.ExecWithLock(func() error {
-					err = s.NodeAgent.UpdateStateByID(agentID, int(servicepb.AgentState_OFFLINE))
-					if err != nil {
-						return errors.Wrap("handling Agent grpc request", "update node agent state", err)
-					}
-					return nil
-				})
-				if serr != nil {
-					log.Errorf("Failed to set agent state to OFFLINE, error: %v", serr)
-				}
-				return errors.Wrap("handling module deployment", "send message over gRCP streaming channel", err)
+	resyncTicker := time.NewTicker(s.ResyncPeriod)
+	defer resyncTicker.Stop()
+
+	// Module instances created before this agent connected were not dispatched to it.
+	err = s.resync(agentID, stream)
+	for err == nil {
+		select {
+		case moduleInstanceID, ok := <-queue:
+			if !ok {
+				// The agent reconnected with another streaming channel, or this one is broken. Either way, stop
+				// sending, and wait for the agent to close this one.
+				log.Warnf("Queue of agent '%s' was closed, stopping its streaming channel ...", agentID)
+				return eg.Wait()
 			}
+			err = s.deployModuleInstanceByID(agentID, moduleInstanceID, stream)
+		case <-resyncTicker.C:
+			err = s.resync(agentID, stream)
+		case <-recvDone:
+			return eg.Wait()
+		}
+	}
+	// Sending fails only if the streaming channel is broken, which also stops receiving.
+	_ = eg.Wait()
+	return err
+}
 
-			// TODO(yzhao): This should set the state to PENDING, or something indicating the request is sent.
-			// Probably should update the IN_PROGRESS state in module_instance table.
-			_ = s.gLock.ExecWithLock(func() error {
-				err = s.ModuleInstance.UpdateStatusByID(moduleInstance.ID, int(servicepb.ModuleInstanceState_IN_PROGRESS))
-				if err != nil {
-					// If this happens, this module's deployment will be retried next time.
-					log.Errorf("Failed to update module (ID=%s) state, error: %v", module.ID, err)
-				}
-				return nil
-			})
+// unregisterAgent unregisters the queue of the agent's streaming channel, and sets the agent OFFLINE if the queue
+// was still registered. An agent that reconnected with another streaming channel has replaced the queue, and stays
+// ONLINE.
+func (s *Deployer) unregisterAgent(agentID string, queue <-chan string) {
+	_ = s.gLock.ExecWithLock(func() error {
+		if s.dispatcher.Unregister(agentID, queue) {
+			s.setAgentOffline(agentID)
 		}
+		return nil
+	})
+}
+
+func (s *Deployer) setAgentOffline(agentID string) {
+	err := s.NodeAgent.UpdateStateByID(agentID, int(servicepb.AgentState_OFFLINE))
+	if err != nil {
+		log.Errorf("Failed to set agent state to OFFLINE, error: %v", err)
+		return
 	}
+	s.auditAgent(agentID, agentID, dao.AuditAgentOffline, servicepb.AgentState_ONLINE.String(),
+		servicepb.AgentState_OFFLINE)
+}
+
+// auditAgent appends an audit record of the state transition of the agent with targetID, observed on the streaming
+// channel of the agent with agentID.
+func (s *Deployer) auditAgent(agentID, targetID, action, before string, after servicepb.AgentState) {
+	s.appendAudit(&dao.AuditGORM{
+		Actor:  dao.AuditAgentActor(agentID),
+		Action: action,
+		Target: dao.AuditTarget(dao.AuditTargetAgent, targetID),
+		Before: before,
+		After:  after.String(),
+	})
+}
+
+// auditModuleInstance appends an audit record of the module instance's transition to the state, if it changes the
+// state. moduleInstance holds the state before the transition. desc is the reason of a failed transition.
+func (s *Deployer) auditModuleInstance(actor string, moduleInstance *dao.ModuleInstanceGORM,
+	state servicepb.ModuleInstanceState, desc string,
+) {
+	if moduleInstance.State == int(state) {
+		return
+	}
+	s.appendAudit(&dao.AuditGORM{
+		Actor:  actor,
+		Action: dao.AuditModuleInstanceState,
+		Target: dao.AuditTarget(dao.AuditTargetModuleInstance, moduleInstance.ID),
+		Before: servicepb.ModuleInstanceState(moduleInstance.State).String(),
+		After:  state.String(),
+		Error:  desc,
+	})
+}
+
+// appendAudit appends the record to the audit log. Failures are only logged, as the transition has happened.
+func (s *Deployer) appendAudit(record *dao.AuditGORM) {
+	err := s.Audit.Append(record)
+	if err != nil {
+		log.Errorf("Failed to append audit record of '%s' on '%s', error: %v", record.Action, record.Target, err)
+	}
+}
+
+// resync lists all module instances of the agent, and sends the ones that have not been processed.
+func (s *Deployer) resync(agentID string, stream servicepb.ModuleDeployer_DeployModuleServer) error {
+	moduleInstances, err := s.ModuleInstance.ListByAgentID(agentID)
+	if err != nil {
+		log.Errorf("Failed to list module instances of agent '%s', error: %v", agentID, err)
+		return nil
+	}
+	for i := range moduleInstances {
+		err := s.deployModuleInstance(agentID, &moduleInstances[i], stream)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Deployer) deployModuleInstanceByID(agentID, moduleInstanceID string,
+	stream servicepb.ModuleDeployer_DeployModuleServer,
+) error {
+	moduleInstance, err := s.ModuleInstance.QueryByID(moduleInstanceID)
+	if err != nil {
+		// The module instance might have been deleted after it was dispatched.
+		log.Warnf("Failed to query module instance '%s', error: %v", moduleInstanceID, err)
+		return nil
+	}
+	return s.deployModuleInstance(agentID, moduleInstance, stream)
+}
+
+// deployModuleInstance sends the request of the module instance to the agent, if it has not been processed.
+// Returns error only if the streaming channel is broken.
+func (s *Deployer) deployModuleInstance(agentID string, moduleInstance *dao.ModuleInstanceGORM,
+	stream servicepb.ModuleDeployer_DeployModuleServer,
+) error {
+	if moduleInstance.State != int(servicepb.ModuleInstanceState_INIT) {
+		return nil
+	}
+	module, err := s.Module.QueryByID(moduleInstance.ModuleID)
+	if err != nil {
+		log.Errorf("Failed to query module '%s' of module instance '%s', error: %v",
+			moduleInstance.ModuleID, moduleInstance.ID, err)
+		return nil
+	}
+	if module == nil {
+		log.Warnf("Module '%s' of module instance '%s' does not exist, skip ...", moduleInstance.ModuleID, moduleInstance.ID)
+		return nil
+	}
+
+	// During a rolling upgrade, or its rollback, the instance is deployed with a version other than the module's.
+	if moduleInstance.Version != 0 && moduleInstance.Version != module.Version {
+		moduleVersion, err := s.ModuleVersion.QueryByModuleIDAndVersion(module.ID, moduleInstance.Version)
+		if err != nil || moduleVersion == nil {
+			log.Errorf("Failed to query version %d of module '%s' of module instance '%s', error: %v",
+				moduleInstance.Version, module.ID, moduleInstance.ID, err)
+			return nil
+		}
+		moduleVersion.ApplyTo(module)
+	}
+
+	moduleReq, err := getDeployReqForModule(module)
+	if err != nil {
+		log.Fatalf("Failed to create DeployModuleReq for module ID=%s, this should not happen, "+
+			"as module creation should validate module, error: %v", module.ID, err)
+		return err
+	}
+	if moduleInstance.DesireState == int(servicepb.ModuleState_UNDEPLOYED) {
+		moduleReq.Deploy = servicepb.DeployModuleReq_UNDEPLOY
+	}
+
+	err = stream.Send(moduleReq)
+	if err != nil {
+		return errors.Wrap("handling module deployment", "send message over gRCP streaming channel", err)
+	}
+
+	// TODO(yzhao): This should set the state to PENDING, or something indicating the request is sent.
+	// Probably should update the IN_PROGRESS state in module_instance table.
+	err = s.ModuleInstance.UpdateStatusByID(moduleInstance.ID, int(servicepb.ModuleInstanceState_IN_PROGRESS))
+	if err != nil {
+		// If this happens, this module's deployment will be retried next time.
+		log.Errorf("Failed to update module (ID=%s) state, error: %v", module.ID, err)
+		return nil
+	}
+	s.auditModuleInstance(dao.AuditActorAPIServer, moduleInstance, servicepb.ModuleInstanceState_IN_PROGRESS, "")
+	return nil
 }
 
 // NewDeployer returns a Deployer object with the input SQLite ORM client.
-func NewDeployer(orm *sqlite.ORM, gLock *lock.Lock, waitCond *cond.Cond) *Deployer {
+func NewDeployer(orm *sqlite.ORM, gLock *lock.Lock, dispatcher *channel.Dispatcher) *Deployer {
 	return &Deployer{
 		Module: dao.ModuleDao{
 			Client: orm,
@@ -303,14 +443,21 @@ func NewDeployer(orm *sqlite.ORM, gLock *lock.Lock, waitCond *cond.Cond) *Deploy
 		ModuleInstance: dao.ModuleInstanceDao{
 			Client: orm,
 		},
-		waitCond: waitCond,
-		gLock:    gLock,
+		ModuleVersion: dao.ModuleVersionDao{
+			Client: orm,
+		},
+		Audit: dao.AuditDao{
+			Client: orm,
+		},
+		gLock:        gLock,
+		dispatcher:   dispatcher,
+		ResyncPeriod: defaultResyncPeriod,
 	}
 }
 
 // RegisterDeployerService registers Deployer server instance with the gRPC fixture.
 func RegisterModuleDeployerServer(f *grpcutils.ServerFixture, sqliteClient *sqlite.ORM, gLock *lock.Lock,
-	waitCond *cond.Cond,
+	dispatcher *channel.Dispatcher,
 ) {
-	servicepb.RegisterModuleDeployerServer(f.Server, NewDeployer(sqliteClient, gLock, waitCond))
+	servicepb.RegisterModuleDeployerServer(f.Server, NewDeployer(sqliteClient, gLock, dispatcher))
 }
@@ -17,6 +17,8 @@ package grpc
 
 import (
 	"context"
+	"fmt"
+	"net"
 	"testing"
 	"time"
 
@@ -28,13 +30,15 @@ import (
 	"google.golang.org/grpc/credentials/insecure"
 
 	"github.com/tricorder/src/testing/bazel"
-	"github.com/tricorder/src/utils/cond"
+	"github.com/tricorder/src/testing/certs"
 	"github.com/tricorder/src/utils/lock"
 	"github.com/tricorder/src/utils/log"
 
 	"github.com/tricorder/src/api-server/http/dao"
 	pb "github.com/tricorder/src/api-server/pb"
 	testutil "github.com/tricorder/src/api-server/testing"
+	"github.com/tricorder/src/api-server/utils/channel"
+	"github.com/tricorder/src/pb/module/common"
 	grpcutils "github.com/tricorder/src/utils/grpc"
 )
 
@@ -51,6 +55,28 @@ func TestGetDeployReqForModule(t *testing.T) {
 	req, err := getDeployReqForModule(&moduleGORM)
 	assert.Nil(err)
 	assert.Equal("test", req.ModuleId)
+	assert.Nil(req.Module.Wasm.Sink)
+
+	moduleGORM.Name = "module"
+	moduleGORM.Sink = `{"type":1,"path":"module.jsonl"}`
+	req, err = getDeployReqForModule(&moduleGORM)
+	assert.Nil(err)
+	assert.Equal("module", req.Module.Name)
+	assert.Equal(common.Sink_FILE, req.Module.Wasm.Sink.Type)
+	assert.Equal("module.jsonl", req.Module.Wasm.Sink.Path)
+
+	assert.Nil(req.Module.Signature)
+
+	moduleGORM.SignatureKeyID = "0123456789abcdef"
+	moduleGORM.Signature = []byte("signature")
+	req, err = getDeployReqForModule(&moduleGORM)
+	assert.Nil(err)
+	assert.Equal("0123456789abcdef", req.Module.Signature.KeyId)
+	assert.Equal([]byte("signature"), req.Module.Signature.Value)
+
+	moduleGORM.Sink = "not json"
+	_, err = getDeployReqForModule(&moduleGORM)
+	assert.ErrorContains(err, "unmarshal sink")
 }
 
 // Tests that the grpc service can handle request.
@@ -64,7 +90,7 @@ func TestDeployModule(t *testing.T) {
 	testutil.PrepareTricorderDBData(moduleID, agentID, moduleInstanceID, sqliteClient)
 
 	gLock := lock.NewLock()
-	waitCond := cond.NewCond()
+	dispatcher := channel.NewDispatcher()
 
 	moduleDao := dao.ModuleDao{
 		Client: sqliteClient,
@@ -83,7 +109,7 @@ func TestDeployModule(t *testing.T) {
 		log.Fatalf("Failed to create gRPC server fixture on :0")
 	}
 
-	RegisterModuleDeployerServer(f, sqliteClient, gLock, waitCond)
+	RegisterModuleDeployerServer(f, sqliteClient, gLock, dispatcher)
 	go func() {
 		err := f.Serve()
 		if err != nil {
@@ -97,11 +123,7 @@ func TestDeployModule(t *testing.T) {
 	defer f.Server.Stop()
 	defer c.conn.Close()
 
-	// This is completely broken, but needed to unblock API Server's internal conditional waiting.
-	// Ideally we should send a request to API Server and let API Server's internal logic triggers conditional variable's
-	// broadcasting.
-	waitCond.Broadcast()
-
+	// The module instance was created before the agent connects, so it is sent when the agent connects.
 	in, err := c.stream.Recv()
 	require.NoError(err)
 
@@ -153,11 +175,6 @@ func TestDeployModule(t *testing.T) {
 	// test undeploy module
 	c = newGRPCClient(f.Addr.String())
 
-	// This is completely broken, but needed to unblock API Server's internal conditional waiting.
-	// Ideally we should send a request to API Server and let API Server's internal logic triggers conditional variable's
-	// broadcasting.
-	waitCond.Broadcast()
-
 	in, err = c.stream.Recv()
 	require.NoError(err)
 
@@ -168,6 +185,7 @@ func TestDeployModule(t *testing.T) {
 	resp = pb.DeployModuleResp{
 		ModuleId: moduleID,
 		State:    pb.ModuleInstanceState_FAILED,
+		Desc:     "failed to detach probes",
 	}
 
 	err = c.stream.Send(&resp)
@@ -179,6 +197,195 @@ func TestDeployModule(t *testing.T) {
 	require.NoError(err)
 	assert.Equal(moduleInstanceID, moduleInstance.ID)
 	assert.Equal(int(pb.ModuleInstanceState_FAILED), moduleInstance.State)
+	assert.Equal("failed to detach probes", moduleInstance.StateDesc)
+
+	// test module instance dispatched after the agent connects
+	err = moduleInstanceDao.UpdateStatusByID(moduleInstanceID, int(pb.ModuleInstanceState_INIT))
+	require.NoError(err)
+	require.Eventually(func() bool { return dispatcher.IsRegistered(agentID) }, 2*time.Second, 100*time.Millisecond)
+	assert.True(dispatcher.Dispatch(agentID, moduleInstanceID))
+
+	in, err = c.stream.Recv()
+	require.NoError(err)
+	assert.Equal(moduleID, in.ModuleId)
+	assert.Equal(pb.DeployModuleReq_UNDEPLOY, in.Deploy)
+
+	// The state is updated after the request is sent.
+	assert.Eventually(func() bool {
+		moduleInstance, err = moduleInstanceDao.QueryByID(moduleInstanceID)
+		require.NoError(err)
+		return moduleInstance.State == int(pb.ModuleInstanceState_IN_PROGRESS)
+	}, 2*time.Second, 100*time.Millisecond)
+
+	// test the state transitions are recorded to the audit log
+	auditDao := dao.AuditDao{
+		Client: sqliteClient,
+	}
+	records, _, err := auditDao.ListPage(dao.AuditFilter{Action: dao.AuditAgentOffline}, 10, 0)
+	require.NoError(err)
+	require.Len(records, 1)
+	assert.Equal(dao.AuditAgentActor(agentID), records[0].Actor)
+	assert.Equal(dao.AuditTarget(dao.AuditTargetAgent, agentID), records[0].Target)
+	assert.Equal(pb.AgentState_OFFLINE.String(), records[0].After)
+
+	records, total, err := auditDao.ListPage(dao.AuditFilter{
+		Actor:  dao.AuditAgentActor(agentID),
+		Target: dao.AuditTarget(dao.AuditTargetModuleInstance, moduleInstanceID),
+	}, 10, 0)
+	require.NoError(err)
+	assert.Equal(int64(2), total)
+	require.Len(records, 2)
+	assert.Equal(pb.ModuleInstanceState_IN_PROGRESS.String(), records[0].Before)
+	assert.Equal(pb.ModuleInstanceState_FAILED.String(), records[0].After)
+	assert.Equal("failed to detach probes", records[0].Error)
+	assert.Equal(pb.ModuleInstanceState_SUCCEEDED.String(), records[1].After)
+	assert.Empty(records[1].Error)
+
+	records, _, err = auditDao.ListPage(dao.AuditFilter{Actor: dao.AuditActorAPIServer}, 10, 0)
+	require.NoError(err)
+	assert.Len(records, 3)
+	assert.Equal(pb.ModuleInstanceState_IN_PROGRESS.String(), records[0].After)
+}
+
+// Tests that an agent reconnecting with another streaming channel stays ONLINE after its old channel is closed,
+// and keeps receiving module instances on the new one.
+func TestDeployModuleReconnect(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	sqliteClient, err := dao.InitSqlite(bazel.CreateTmpDir())
+	require.NoError(err)
+	testutil.PrepareTricorderDBData(moduleID, agentID, moduleInstanceID, sqliteClient)
+	nodeAgentDao := dao.NodeAgentDao{
+		Client: sqliteClient,
+	}
+	moduleInstanceDao := dao.ModuleInstanceDao{
+		Client: sqliteClient,
+	}
+
+	f, err := grpcutils.NewServerFixture(0)
+	require.NoError(err)
+	dispatcher := channel.NewDispatcher()
+	RegisterModuleDeployerServer(f, sqliteClient, lock.NewLock(), dispatcher)
+	go func() { _ = f.Serve() }()
+	defer f.Server.Stop()
+
+	agentState := func() int {
+		nodes, err := nodeAgentDao.List([]string{})
+		require.NoError(err)
+		require.Equal(1, len(nodes))
+		return nodes[0].State
+	}
+
+	oldClient := newGRPCClient(f.Addr.String())
+	defer oldClient.conn.Close()
+	_, err = oldClient.stream.Recv()
+	require.NoError(err)
+	assert.Equal(int(pb.AgentState_ONLINE), agentState())
+
+	// The new channel receives the module instance when it resyncs, which happens after it registers.
+	require.NoError(moduleInstanceDao.UpdateStatusByID(moduleInstanceID, int(pb.ModuleInstanceState_INIT)))
+	newClient := newGRPCClient(f.Addr.String())
+	defer newClient.conn.Close()
+	in, err := newClient.stream.Recv()
+	require.NoError(err)
+	assert.Equal(moduleID, in.ModuleId)
+
+	oldClient.conn.Close()
+	// wait for 2 seconds to make sure the api server handles the closed channel
+	time.Sleep(2 * time.Second)
+	assert.Equal(int(pb.AgentState_ONLINE), agentState())
+	require.True(dispatcher.IsRegistered(agentID))
+
+	require.NoError(moduleInstanceDao.UpdateStatusByID(moduleInstanceID, int(pb.ModuleInstanceState_INIT)))
+	assert.True(dispatcher.Dispatch(agentID, moduleInstanceID))
+	in, err = newClient.stream.Recv()
+	require.NoError(err)
+	assert.Equal(moduleID, in.ModuleId)
+
+	newClient.conn.Close()
+	assert.Eventually(func() bool { return agentState() == int(pb.AgentState_OFFLINE) }, 2*time.Second,
+		100*time.Millisecond)
+	assert.False(dispatcher.IsRegistered(agentID))
+}
+
+// Tests that only the agents presenting the certificates issued by the client CA of the mutual TLS gRPC server can
+// register, and receive the modules.
+func TestDeployModuleMTLS(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	sqliteClient, err := dao.InitSqlite(bazel.CreateTmpDir())
+	require.NoError(err)
+	testutil.PrepareTricorderDBData(moduleID, agentID, moduleInstanceID, sqliteClient)
+	nodeAgentDao := dao.NodeAgentDao{
+		Client: sqliteClient,
+	}
+
+	ca, err := certs.NewCA("ca")
+	require.NoError(err)
+	serverPair, err := ca.Issue("api-server", "localhost")
+	require.NoError(err)
+	serverFiles, err := serverPair.WriteFiles(bazel.CreateTmpDir(), ca)
+	require.NoError(err)
+
+	serverCreds, err := grpcutils.NewServerTLS(grpcutils.TLSFiles(serverFiles))
+	require.NoError(err)
+	f, err := grpcutils.NewServerFixture(0, grpc.Creds(serverCreds))
+	require.NoError(err)
+	RegisterModuleDeployerServer(f, sqliteClient, lock.NewLock(), channel.NewDispatcher())
+	go func() { _ = f.Serve() }()
+	defer f.Server.Stop()
+	addr := fmt.Sprintf("localhost:%d", f.Addr.(*net.TCPAddr).Port)
+
+	// connect opens the DeployModule stream, and sends the agent's identity.
+	connect := func(files certs.Files) (pb.ModuleDeployer_DeployModuleClient, error) {
+		creds, err := grpcutils.NewClientTLS(grpcutils.TLSFiles(files), "")
+		require.NoError(err)
+		conn, err := grpcutils.Dial(addr, grpc.WithTransportCredentials(creds))
+		require.NoError(err)
+		t.Cleanup(func() { conn.Close() })
+
+		stream, err := pb.NewModuleDeployerClient(conn).DeployModule(context.Background())
+		if err != nil {
+			return nil, err
+		}
+		err = stream.Send(&pb.DeployModuleResp{Agent: &pb.Agent{Id: agentID, NodeName: "node", PodId: "pod"}})
+		if err != nil {
+			return nil, err
+		}
+		return stream, nil
+	}
+
+	otherCA, err := certs.NewCA("other-ca")
+	require.NoError(err)
+	roguePair, err := otherCA.Issue("agent")
+	require.NoError(err)
+	rogueFiles, err := roguePair.WriteFiles(bazel.CreateTmpDir(), ca)
+	require.NoError(err)
+	_, err = connect(rogueFiles)
+	assert.Error(err)
+
+	nodes, err := nodeAgentDao.List([]string{})
+	require.NoError(err)
+	assert.Empty(nodes)
+
+	agentPair, err := ca.Issue("agent")
+	require.NoError(err)
+	agentFiles, err := agentPair.WriteFiles(bazel.CreateTmpDir(), ca)
+	require.NoError(err)
+	stream, err := connect(agentFiles)
+	require.NoError(err)
+
+	in, err := stream.Recv()
+	require.NoError(err)
+	assert.Equal(moduleID, in.ModuleId)
+	assert.Equal(pb.DeployModuleReq_DEPLOY, in.Deploy)
+
+	nodes, err = nodeAgentDao.List([]string{})
+	require.NoError(err)
+	require.Equal(1, len(nodes))
+	assert.Equal(agentID, nodes[0].AgentID)
 }
 
 type deployerClient struct {
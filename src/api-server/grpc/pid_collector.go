@@ -57,7 +57,7 @@ func NewPIDCollector(clientset kubernetes.Interface, pgClient *pg.Client) *PIDCo
 	c.podInformerQuitChan = make(chan struct{})
 
 	err := retry.ExpBackOffWithLimit(func() error {
-		return pgClient.CreateTable(pg.GetJSONBTableSchema(procInfoTableName))
+		return pgClient.CreateJSONBObjectTable(procInfoTableName, idPath...)
 	})
 	if err != nil {
 		log.Fatalf("While preparing to start process info collector , failed to create table, error: %v", err)
@@ -93,7 +93,7 @@ func (s *PIDCollector) ReportProcess(stream pb.ProcessCollector_ReportProcessSer
 		case *pb.ProcessWrapper_Process:
 			// Receive process info update from agents, and write the info as JSON blob into data table process_info
 			value, _ := protojson.Marshal(msg.Process)
-			if err = s.pgClient.JSON().Upsert(procInfoTableName, msg.Process.Container.Id, value, idPath...); err != nil {
+			if err = s.pgClient.JSON().Upsert(procInfoTableName, value); err != nil {
 				log.Errorf("While reporting process info, failed to Upsert, error: %v", err)
 			}
 		default:
@@ -138,10 +138,12 @@ func (s *PIDCollector) podWatch(nodeName string, stream pb.ProcessCollector_Repo
 		DeleteFunc: func(obj interface{}) {
 			pod, ok := obj.(*corev1.Pod)
 			if ok {
+				containerIDs := make([]string, 0, len(pod.Status.ContainerStatuses))
 				for _, c := range pod.Status.ContainerStatuses {
-					if err := s.pgClient.JSON().Delete(procInfoTableName, c.ContainerID, idPath...); err != nil {
-						log.Errorf("While watching Pod update, failed to delete container, error: %v", err)
-					}
+					containerIDs = append(containerIDs, c.ContainerID)
+				}
+				if err := s.pgClient.JSON().DeleteBatch(procInfoTableName, containerIDs); err != nil {
+					log.Errorf("While watching Pod update, failed to delete containers, error: %v", err)
 				}
 			}
 		},
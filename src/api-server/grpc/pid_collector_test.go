@@ -18,7 +18,6 @@ package grpc
 import (
 	"context"
 	"encoding/json"
-	"fmt"
 	"testing"
 	"time"
 
@@ -123,18 +122,18 @@ func TestIdPath(t *testing.T) {
 		assert.Nil(cleaner())
 	}()
 
-	err = pgClient.CreateTable(upg.GetJSONBTableSchema(procInfoTableName))
+	err = pgClient.CreateJSONBObjectTable(procInfoTableName, idPath...)
 	assert.Nil(err)
 
 	id := "abcdefg"
 	pi := &pb.ProcessInfo{Container: &pb.ContainerInfo{Id: id}}
 	pi.Container.Name = "@#$%^&*()_+|"
 	value, _ := json.Marshal(pi)
-	err = pgClient.JSON().Upsert(procInfoTableName, id, value, idPath...)
+	err = pgClient.JSON().Upsert(procInfoTableName, value)
 	assert.Nil(err)
 
 	result1 := pb.ProcessInfo{}
-	err = pgClient.JSON().Get(procInfoTableName, &result1, fmt.Sprintf("WHERE data->'container'->>'id'='%s'", id))
+	err = pgClient.JSON().Get(procInfoTableName, &result1, upg.Where(idPath, id))
 	assert.Nil(err)
 	// Check result upserted
 	assert.Equal(pi.Container.Name, result1.Container.Name)
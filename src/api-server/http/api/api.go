@@ -30,14 +30,110 @@ const (
 	UNDEPLOY_MODULE = "/undeployModule"
 	DELETE_MODULE   = "/deleteModule"
 
+	MODULE_ID_PARAM  = "id"
+	MODULE_INSTANCES = "/module/:" + MODULE_ID_PARAM + "/instances"
+	MODULE_VERSIONS  = "/module/:" + MODULE_ID_PARAM + "/versions"
+	UPGRADE_MODULE   = "/module/:" + MODULE_ID_PARAM + "/upgrade"
+
+	MODULE_HYPERTABLE = "/module/:" + MODULE_ID_PARAM + "/hypertable"
+
+	// The custom methods on modules, like POST /api/module:validate. The router cannot match a path segment partially,
+	// so MODULE_ACTION matches any suffix of /module, which is checked by the handler.
+	MODULE_ACTION_PARAM = "action"
+	MODULE_ACTION       = "/module:" + MODULE_ACTION_PARAM
+	VALIDATE_ACTION     = "validate"
+
+	// The modules bundled with API Server, see src/api-server/catalog.
+	CATALOG            = "/catalog"
+	CATALOG_NAME_PARAM = "name"
+	CATALOG_ENTRY      = CATALOG + "/:" + CATALOG_NAME_PARAM
+
 	LIST_MODULE_PATH     = ROOT + LIST_MODULE
 	LIST_AGENT_PATH      = ROOT + LIST_AGENT
 	CREATE_MODULE_PATH   = ROOT + CREATE_MODULE
 	DEPLOY_MODULE_PATH   = ROOT + DEPLOY_MODULE
 	UNDEPLOY_MODULE_PATH = ROOT + UNDEPLOY_MODULE
 	DELETE_MODULE_PATH   = ROOT + DELETE_MODULE
+
+	VALIDATE_MODULE_PATH = ROOT + "/module" + ACTION_OP + VALIDATE_ACTION
+	CATALOG_PATH         = ROOT + CATALOG
+
+	// The resource-oriented API, which responds with proper HTTP status codes.
+	// Actions on a resource are custom methods of the form POST /<resource>/<id>:<action>.
+	V2_ROOT   = ROOT + "/v2"
+	MODULES   = "/modules"
+	MODULE    = MODULES + "/:" + MODULE_ID_PARAM
+	AGENTS    = "/agents"
+	AGENT_ID  = "id"
+	AGENT     = AGENTS + "/:" + AGENT_ID
+	ACTION_OP = ":"
+	AUDIT     = "/audit"
+
+	// The bundle of a module, see src/api-server/bundle.
+	MODULE_BUNDLE = MODULE + "/bundle"
+	// The custom methods on the module collection, like POST /api/v2/modules:import, see MODULE_ACTION.
+	MODULES_ACTION = MODULES + ":" + MODULE_ACTION_PARAM
+	IMPORT_ACTION  = "import"
+
+	DEPLOY_ACTION   = "deploy"
+	UNDEPLOY_ACTION = "undeploy"
+
+	MODULES_V2_PATH = V2_ROOT + MODULES
+	AGENTS_V2_PATH  = V2_ROOT + AGENTS
+	AUDIT_V2_PATH   = V2_ROOT + AUDIT
+
+	IMPORT_MODULE_V2_PATH = MODULES_V2_PATH + ACTION_OP + IMPORT_ACTION
 )
 
+// GetModuleInstancesPath returns the path to list the instances of the module with the given ID.
+func GetModuleInstancesPath(id string) string {
+	return getModulePath(MODULE_INSTANCES, id)
+}
+
+// GetModuleVersionsPath returns the path to create and list the versions of the module with the given ID.
+func GetModuleVersionsPath(id string) string {
+	return getModulePath(MODULE_VERSIONS, id)
+}
+
+// GetUpgradeModulePath returns the path to upgrade the module with the given ID.
+func GetUpgradeModulePath(id string) string {
+	return getModulePath(UPGRADE_MODULE, id)
+}
+
+// GetModuleHypertablePath returns the path to get and update the hypertable spec of the module with the given ID.
+func GetModuleHypertablePath(id string) string {
+	return getModulePath(MODULE_HYPERTABLE, id)
+}
+
+// GetCatalogEntryPath returns the path to create a module from the catalog entry with the given name.
+func GetCatalogEntryPath(name string) string {
+	return CATALOG_PATH + "/" + name
+}
+
+// GetModuleV2Path returns the v2 path of the module with the given ID.
+func GetModuleV2Path(id string) string {
+	return MODULES_V2_PATH + "/" + id
+}
+
+// GetModuleActionV2Path returns the v2 path to perform the action, like DEPLOY_ACTION, on the module with the given ID.
+func GetModuleActionV2Path(id, action string) string {
+	return GetModuleV2Path(id) + ACTION_OP + action
+}
+
+// GetModuleBundleV2Path returns the v2 path to export the module with the given ID as a bundle.
+func GetModuleBundleV2Path(id string) string {
+	return GetModuleV2Path(id) + "/bundle"
+}
+
+// GetAgentV2Path returns the v2 path of the agent with the given ID.
+func GetAgentV2Path(id string) string {
+	return AGENTS_V2_PATH + "/" + id
+}
+
+func getModulePath(route, id string) string {
+	return strings.Replace(ROOT+route, ":"+MODULE_ID_PARAM, id, 1)
+}
+
 // GetURL returns a http URL that corresponds to the requested path.
 // The path has to start with '/'.
 func GetURL(addr, path string) string {
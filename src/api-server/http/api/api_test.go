@@ -12,3 +12,12 @@ func TestGetURL(t *testing.T) {
 	assert.Equal("http://localhost:8080/api/test", GetURL("localhost:8080", "/api/test"))
 	assert.Equal("http://localhost:8080/api/test", GetURL("http://localhost:8080", "/api/test"))
 }
+
+// Tests that the paths of module sub-resources fill in the module ID.
+func TestGetModulePaths(t *testing.T) {
+	assert := assert.New(t)
+	assert.Equal("/api/module/abc_123/instances", GetModuleInstancesPath("abc_123"))
+	assert.Equal("/api/module/abc_123/versions", GetModuleVersionsPath("abc_123"))
+	assert.Equal("/api/module/abc_123/upgrade", GetUpgradeModulePath("abc_123"))
+	assert.Equal("/api/module/abc_123/hypertable", GetModuleHypertablePath("abc_123"))
+}
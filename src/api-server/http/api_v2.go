@@ -0,0 +1,339 @@
+// Copyright (C) 2023  Tricorder Observability
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+package http
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/tricorder/src/api-server/auth"
+	"github.com/tricorder/src/api-server/http/api"
+	"github.com/tricorder/src/api-server/http/dao"
+	pb "github.com/tricorder/src/api-server/pb"
+)
+
+// The number of items returned by the v2 list APIs if the request has no limit, and the maximal number allowed.
+const (
+	defaultPageLimit = 100
+	maxPageLimit     = 1000
+)
+
+// registerV2 registers the handlers of the v2 API under api.V2_ROOT.
+// Unlike the v1 API, which always responds with 200 and sets the status code in the body, these handlers respond
+// with the actual status code, and with an ErrorResp body on failures.
+func (mgr *ModuleManager) registerV2(router *gin.Engine) {
+	viewer := mgr.requireRole(auth.RoleViewer)
+	operator := mgr.requireRole(auth.RoleOperator)
+	admin := mgr.requireRole(auth.RoleAdmin)
+	ofTenant := mgr.requireModuleOfTenant()
+
+	v2 := router.Group(api.V2_ROOT)
+	v2.GET(api.MODULES, viewer, mgr.listModulesV2)
+	v2.POST(api.MODULES, operator, mgr.createModuleV2)
+	v2.POST(api.MODULES_ACTION, operator, mgr.importModuleV2)
+	v2.GET(api.MODULE, viewer, ofTenant, mgr.getModuleV2)
+	v2.GET(api.MODULE_BUNDLE, viewer, ofTenant, mgr.exportModuleV2)
+	v2.DELETE(api.MODULE, admin, ofTenant, mgr.deleteModuleV2)
+	v2.PUT(api.MODULE, operator, ofTenant, mgr.updateModuleV2)
+	v2.PATCH(api.MODULE, operator, ofTenant, mgr.patchModuleV2)
+	// The router cannot match a path segment partially, so the custom methods are dispatched by moduleActionV2().
+	v2.POST(api.MODULE, operator, ofTenant, mgr.moduleActionV2)
+	v2.GET(api.AGENTS, viewer, mgr.listAgentsV2)
+	v2.GET(api.AGENT, viewer, mgr.getAgentV2)
+	// The audit log reveals who operates the cluster, including the other tenants.
+	v2.GET(api.AUDIT, admin, mgr.requireNoTenant(), mgr.listAuditV2)
+}
+
+// abortWithError responds with the status code and an ErrorResp body.
+func abortWithError(c *gin.Context, code int, msg string) {
+	c.AbortWithStatusJSON(code, ErrorResp{Error: ErrorDetail{Code: code, Message: msg}})
+}
+
+// abortIfFailed responds with an ErrorResp body if resp, returned by the v1 API's implementation, is a failure.
+// Returns true if it did.
+func abortIfFailed(c *gin.Context, resp HTTPResp) bool {
+	if resp.Code == http.StatusOK {
+		return false
+	}
+	abortWithError(c, resp.Code, resp.Message)
+	return true
+}
+
+// getPage returns the limit and offset query parameters, or responds with 400 if they are invalid.
+func getPage(c *gin.Context) (int, int, bool) {
+	limit, offset := defaultPageLimit, 0
+	var err error
+	if val, ok := c.GetQuery("limit"); ok {
+		limit, err = strconv.Atoi(val)
+		if err != nil || limit <= 0 || limit > maxPageLimit {
+			abortWithError(c, http.StatusBadRequest,
+				fmt.Sprintf("invalid limit '%s', must be an integer in [1, %d]", val, maxPageLimit))
+			return 0, 0, false
+		}
+	}
+	if val, ok := c.GetQuery("offset"); ok {
+		offset, err = strconv.Atoi(val)
+		if err != nil || offset < 0 {
+			abortWithError(c, http.StatusBadRequest, fmt.Sprintf("invalid offset '%s', must be a non-negative integer", val))
+			return 0, 0, false
+		}
+	}
+	return limit, offset, true
+}
+
+// getStateFilter returns the value of the enum named by the query parameter key, case-insensitively, nil if the
+// request has no such parameter, or responds with 400 if the name is invalid.
+func getStateFilter(c *gin.Context, key string, values map[string]int32) (*int, bool) {
+	name, ok := c.GetQuery(key)
+	if !ok {
+		return nil, true
+	}
+	name = strings.ToUpper(name)
+	val, ok := values[name]
+	if !ok {
+		// pb.ModuleState_CREATED_ has a trailing '_'.
+		val, ok = values[name+"_"]
+	}
+	if !ok {
+		abortWithError(c, http.StatusBadRequest, fmt.Sprintf("invalid %s '%s'", key, name))
+		return nil, false
+	}
+	state := int(val)
+	return &state, true
+}
+
+// listModulesV2 godoc
+// @Summary      List modules
+// @Description  List the modules matching the filters, the latest created module first
+// @Tags         module-v2
+// @Produce      json
+// @Param        fields  query  string  false  "the returned fields like 'id,name,desire_state'"
+// @Param        name    query  string  false  "only return the module with this name"
+// @Param        state   query  string  false  "only return the modules in this desired state, like 'deployed'"
+// @Param        tenant  query  string  false  "only return the modules of this tenant, ignored for tenants' tokens"
+// @Param        limit   query  int     false  "the maximal number of returned modules, 100 by default"
+// @Param        offset  query  int     false  "the number of matching modules skipped"
+// @Success      200  {object}  ModulePage
+// @Failure      400  {object}  ErrorResp
+// @Failure      500  {object}  ErrorResp
+// @Router       /api/v2/modules [get].
+func (mgr *ModuleManager) listModulesV2(c *gin.Context) {
+	const defaultFields = "id,name,tenant,desire_state,create_time,schema_attr,fn,ebpf,version"
+	limit, offset, ok := getPage(c)
+	if !ok {
+		return
+	}
+	state, ok := getStateFilter(c, "state", pb.ModuleState_value)
+	if !ok {
+		return
+	}
+	filter := dao.ModuleFilter{Name: c.Query("name"), DesireState: state, Tenant: tenantFilter(c)}
+	fields := strings.Split(c.DefaultQuery("fields", defaultFields), ",")
+	modules, total, err := mgr.Module.ListModulePage(fields, filter, limit, offset)
+	if err != nil {
+		abortWithError(c, http.StatusInternalServerError, "Query Error: "+err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, ModulePage{Page{Total: total, Limit: limit, Offset: offset}, modules})
+}
+
+// createModuleV2 godoc
+// @Summary      Create module
+// @Description  Create a module, the response's Location header is the path of the created module
+// @Tags         module-v2
+// @Accept       json
+// @Produce      json
+// @Param        module  body  CreateModuleReq  true  "the module"
+// @Success      201  {object}  ModuleIDResp
+// @Failure      400  {object}  ErrorResp
+// @Failure      409  {object}  ErrorResp
+// @Failure      500  {object}  ErrorResp
+// @Router       /api/v2/modules [post].
+func (mgr *ModuleManager) createModuleV2(c *gin.Context) {
+	var body CreateModuleReq
+	err := c.ShouldBindJSON(&body)
+	if err != nil {
+		abortWithError(c, http.StatusBadRequest, "Request Error: "+err.Error())
+		return
+	}
+	if len(body.Name) == 0 || body.Wasm == nil || body.Wasm.OutputSchema == nil || body.Ebpf == nil {
+		abortWithError(c, http.StatusBadRequest, "Request Error: name, wasm.output_schema and ebpf are required")
+		return
+	}
+	if err := resolveTenant(c, &body); err != nil {
+		abortWithError(c, statusCode(err), err.Error())
+		return
+	}
+	resp := mgr.createModule(body)
+	mgr.auditModule(c, dao.AuditModuleCreate, resp.ID, "", resp.HTTPResp)
+	if abortIfFailed(c, resp.HTTPResp) {
+		return
+	}
+	c.Header("Location", api.GetModuleV2Path(resp.ID))
+	c.JSON(http.StatusCreated, ModuleIDResp{ID: resp.ID})
+}
+
+// getModuleV2 godoc
+// @Summary      Get module
+// @Description  Get the module with the ID
+// @Tags         module-v2
+// @Produce      json
+// @Param        id  path  string  true  "module id"
+// @Success      200  {object}  dao.ModuleGORM
+// @Failure      404  {object}  ErrorResp
+// @Failure      500  {object}  ErrorResp
+// @Router       /api/v2/modules/{id} [get].
+func (mgr *ModuleManager) getModuleV2(c *gin.Context) {
+	id := c.Param(api.MODULE_ID_PARAM)
+	module, err := mgr.Module.QueryByID(id)
+	if err != nil {
+		abortWithError(c, http.StatusInternalServerError, "Query Error: "+err.Error())
+		return
+	}
+	if module == nil {
+		abortWithError(c, http.StatusNotFound, "module "+id+" does not exist")
+		return
+	}
+	c.JSON(http.StatusOK, module)
+}
+
+// deleteModuleV2 godoc
+// @Summary      Delete module
+// @Description  Delete the module with the ID, the module must not be deployed
+// @Tags         module-v2
+// @Produce      json
+// @Param        id  path  string  true  "module id"
+// @Success      204
+// @Failure      404  {object}  ErrorResp
+// @Failure      409  {object}  ErrorResp
+// @Failure      500  {object}  ErrorResp
+// @Router       /api/v2/modules/{id} [delete].
+func (mgr *ModuleManager) deleteModuleV2(c *gin.Context) {
+	id := c.Param(api.MODULE_ID_PARAM)
+	before := mgr.moduleAuditState(id)
+	resp := mgr.deleteModule(id)
+	mgr.auditModule(c, dao.AuditModuleDelete, id, before, resp.HTTPResp)
+	if abortIfFailed(c, resp.HTTPResp) {
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// moduleActionV2 godoc
+// @Summary      Deploy or undeploy module
+// @Description  Deploy the module onto, or undeploy it from, every agent in the cluster, with the path
+// @Description  /api/v2/modules/{id}:deploy or /api/v2/modules/{id}:undeploy. The agents apply the change
+// @Description  asynchronously, see /api/module/{id}/instances for their progress.
+// @Tags         module-v2
+// @Produce      json
+// @Param        id     path   string  true   "module id, followed by ':deploy' or ':undeploy'"
+// @Param        force  query  bool    false  "apply destructive changes to the module's data table when deploying"
+// @Success      202  {object}  ModuleActionResp
+// @Failure      404  {object}  ErrorResp
+// @Failure      409  {object}  ErrorResp
+// @Failure      500  {object}  ErrorResp
+// @Router       /api/v2/modules/{id} [post].
+func (mgr *ModuleManager) moduleActionV2(c *gin.Context) {
+	param := c.Param(api.MODULE_ID_PARAM)
+	i := strings.LastIndex(param, api.ACTION_OP)
+	if i < 0 {
+		abortWithError(c, http.StatusNotFound, fmt.Sprintf("missing action, use '%s' or '%s'",
+			api.GetModuleActionV2Path(param, api.DEPLOY_ACTION), api.GetModuleActionV2Path(param, api.UNDEPLOY_ACTION)))
+		return
+	}
+	id, action := param[:i], param[i+1:]
+	switch action {
+	case api.DEPLOY_ACTION:
+		before := mgr.moduleAuditState(id)
+		resp := mgr.deployModule(id, c.Query("force") == "true")
+		mgr.auditModule(c, dao.AuditModuleDeploy, id, before, resp.HTTPResp)
+		if abortIfFailed(c, resp.HTTPResp) {
+			return
+		}
+		c.JSON(http.StatusAccepted, ModuleActionResp{ID: id, UID: resp.UID})
+	case api.UNDEPLOY_ACTION:
+		before := mgr.moduleAuditState(id)
+		resp := mgr.undeployModule(id)
+		mgr.auditModule(c, dao.AuditModuleUndeploy, id, before, resp.HTTPResp)
+		if abortIfFailed(c, resp.HTTPResp) {
+			return
+		}
+		c.JSON(http.StatusAccepted, ModuleActionResp{ID: id})
+	default:
+		abortWithError(c, http.StatusNotFound, fmt.Sprintf("unknown action '%s'", action))
+	}
+}
+
+// listAgentsV2 godoc
+// @Summary      List agents
+// @Description  List the agents matching the filters, the latest updated agent first
+// @Tags         agent-v2
+// @Produce      json
+// @Param        fields  query  string  false  "the returned fields like 'agent_id,node_name,state'"
+// @Param        state   query  string  false  "only return the agents in this state, like 'online'"
+// @Param        limit   query  int     false  "the maximal number of returned agents, 100 by default"
+// @Param        offset  query  int     false  "the number of matching agents skipped"
+// @Success      200  {object}  AgentPage
+// @Failure      400  {object}  ErrorResp
+// @Failure      500  {object}  ErrorResp
+// @Router       /api/v2/agents [get].
+func (mgr *ModuleManager) listAgentsV2(c *gin.Context) {
+	limit, offset, ok := getPage(c)
+	if !ok {
+		return
+	}
+	state, ok := getStateFilter(c, "state", pb.AgentState_value)
+	if !ok {
+		return
+	}
+	var fields []string
+	if val, ok := c.GetQuery("fields"); ok {
+		fields = strings.Split(val, ",")
+	}
+	agents, total, err := mgr.NodeAgent.ListPage(fields, state, limit, offset)
+	if err != nil {
+		abortWithError(c, http.StatusInternalServerError, "Query Error: "+err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, AgentPage{Page{Total: total, Limit: limit, Offset: offset}, agents})
+}
+
+// getAgentV2 godoc
+// @Summary      Get agent
+// @Description  Get the agent with the ID
+// @Tags         agent-v2
+// @Produce      json
+// @Param        id  path  string  true  "agent id"
+// @Success      200  {object}  dao.NodeAgentGORM
+// @Failure      404  {object}  ErrorResp
+// @Failure      500  {object}  ErrorResp
+// @Router       /api/v2/agents/{id} [get].
+func (mgr *ModuleManager) getAgentV2(c *gin.Context) {
+	id := c.Param(api.AGENT_ID)
+	agent, err := mgr.NodeAgent.FindByID(id)
+	if err != nil {
+		abortWithError(c, http.StatusInternalServerError, "Query Error: "+err.Error())
+		return
+	}
+	if agent == nil {
+		abortWithError(c, http.StatusNotFound, "agent "+id+" does not exist")
+		return
+	}
+	c.JSON(http.StatusOK, agent)
+}
@@ -0,0 +1,123 @@
+// Copyright (C) 2023  Tricorder Observability
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+package http
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/tricorder/src/api-server/http/api"
+	"github.com/tricorder/src/api-server/http/dao"
+	pb "github.com/tricorder/src/api-server/pb"
+)
+
+// serveV2 sends the request to the router, and decodes the response body into resp if it's not nil.
+func serveV2(t *testing.T, router *gin.Engine, method, path string, body any, resp any) int {
+	var reqBody bytes.Buffer
+	if body != nil {
+		require.Nil(t, json.NewEncoder(&reqBody).Encode(body))
+	}
+	req := httptest.NewRequest(method, path, &reqBody)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if resp != nil {
+		require.Nil(t, json.Unmarshal(w.Body.Bytes(), resp), w.Body.String())
+	}
+	return w.Code
+}
+
+// Tests the v2 API's status codes and error bodies through the lifecycle of a module that writes to STDOUT,
+// which needs neither Postgres nor Grafana.
+func TestAPIV2(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	mgr := newTestModuleManager(t)
+	require.Nil(mgr.NodeAgent.SaveAgent(&dao.NodeAgentGORM{AgentID: "agent", NodeName: "node"}))
+	router := gin.New()
+	mgr.registerV2(router)
+
+	var errResp ErrorResp
+	assert.Equal(http.StatusBadRequest, serveV2(t, router, "POST", api.MODULES_V2_PATH, CreateModuleReq{}, &errResp))
+	assert.Equal(http.StatusBadRequest, errResp.Error.Code)
+	assert.Contains(errResp.Error.Message, "are required")
+
+	moduleReq := stdoutModuleReq()
+	var idResp ModuleIDResp
+	assert.Equal(http.StatusCreated, serveV2(t, router, "POST", api.MODULES_V2_PATH, moduleReq, &idResp))
+	id := idResp.ID
+	require.NotEmpty(id)
+	assert.Equal(http.StatusConflict, serveV2(t, router, "POST", api.MODULES_V2_PATH, moduleReq, &errResp))
+	assert.Equal(http.StatusConflict, errResp.Error.Code)
+
+	var module dao.ModuleGORM
+	assert.Equal(http.StatusOK, serveV2(t, router, "GET", api.GetModuleV2Path(id), nil, &module))
+	assert.Equal("stdout", module.Name)
+	assert.Equal(http.StatusNotFound, serveV2(t, router, "GET", api.GetModuleV2Path("unknown"), nil, &errResp))
+	assert.Equal(http.StatusNotFound, errResp.Error.Code)
+
+	var modules ModulePage
+	assert.Equal(http.StatusOK, serveV2(t, router, "GET", api.MODULES_V2_PATH+"?state=created&limit=1", nil, &modules))
+	assert.Equal(int64(1), modules.Total)
+	assert.Equal(1, modules.Limit)
+	require.Len(modules.Data, 1)
+	assert.Equal(id, modules.Data[0].ID)
+	assert.Equal(http.StatusBadRequest, serveV2(t, router, "GET", api.MODULES_V2_PATH+"?limit=0", nil, &errResp))
+	assert.Equal(http.StatusBadRequest, serveV2(t, router, "GET", api.MODULES_V2_PATH+"?state=x", nil, &errResp))
+
+	var actionResp ModuleActionResp
+	deployPath := api.GetModuleActionV2Path(id, api.DEPLOY_ACTION)
+	assert.Equal(http.StatusAccepted, serveV2(t, router, "POST", deployPath, nil, &actionResp))
+	assert.Equal(id, actionResp.ID)
+	assert.Equal(http.StatusConflict, serveV2(t, router, "POST", deployPath, nil, &errResp))
+	assert.Equal(http.StatusConflict, serveV2(t, router, "DELETE", api.GetModuleV2Path(id), nil, &errResp))
+	assert.Contains(errResp.Error.Message, "please undeploy first")
+	assert.Equal(http.StatusNotFound,
+		serveV2(t, router, "POST", api.GetModuleActionV2Path("unknown", api.DEPLOY_ACTION), nil, &errResp))
+	assert.Equal(http.StatusNotFound, serveV2(t, router, "POST", api.GetModuleActionV2Path(id, "stop"), nil, &errResp))
+
+	modules = ModulePage{}
+	assert.Equal(http.StatusOK, serveV2(t, router, "GET", api.MODULES_V2_PATH+"?state=deployed", nil, &modules))
+	assert.Equal(int64(1), modules.Total)
+
+	// The module instance has to finish deploying before undeploying.
+	instances, err := mgr.ModuleInstance.ListByModuleID(id)
+	require.Nil(err)
+	require.Len(instances, 1)
+	require.Nil(mgr.ModuleInstance.UpdateStatusByID(instances[0].ID, int(pb.ModuleInstanceState_SUCCEEDED)))
+	undeployPath := api.GetModuleActionV2Path(id, api.UNDEPLOY_ACTION)
+	assert.Equal(http.StatusAccepted, serveV2(t, router, "POST", undeployPath, nil, &actionResp))
+
+	assert.Equal(http.StatusNoContent, serveV2(t, router, "DELETE", api.GetModuleV2Path(id), nil, nil))
+	assert.Equal(http.StatusNotFound, serveV2(t, router, "DELETE", api.GetModuleV2Path(id), nil, &errResp))
+
+	var agents AgentPage
+	assert.Equal(http.StatusOK, serveV2(t, router, "GET", api.AGENTS_V2_PATH+"?state=online", nil, &agents))
+	assert.Equal(int64(1), agents.Total)
+	require.Len(agents.Data, 1)
+	assert.Equal("agent", agents.Data[0].AgentID)
+
+	var agent dao.NodeAgentGORM
+	assert.Equal(http.StatusOK, serveV2(t, router, "GET", api.GetAgentV2Path("agent"), nil, &agent))
+	assert.Equal("node", agent.NodeName)
+	assert.Equal(http.StatusNotFound, serveV2(t, router, "GET", api.GetAgentV2Path("unknown"), nil, &errResp))
+}
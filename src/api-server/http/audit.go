@@ -0,0 +1,106 @@
+// Copyright (C) 2023  Tricorder Observability
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package http
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/tricorder/src/api-server/auth"
+	"github.com/tricorder/src/api-server/http/dao"
+	pb "github.com/tricorder/src/api-server/pb"
+	"github.com/tricorder/src/utils/log"
+)
+
+// auditActor returns the name of the principal who sent the request, or dao.AuditActorAnonymous if authentication
+// is disabled.
+func auditActor(c *gin.Context) string {
+	if val, ok := c.Get(principalKey); ok {
+		if principal, ok := val.(*auth.Principal); ok {
+			return principal.Name
+		}
+	}
+	return dao.AuditActorAnonymous
+}
+
+// auditModule appends an audit record of the action performed on the module by the request, with the module's
+// states before and after the action, see moduleAuditState(). resp is the result of the action.
+// Failing to append the record is logged, but does not fail the action, which has already been performed.
+func (mgr *ModuleManager) auditModule(c *gin.Context, action, id, before string, resp HTTPResp) {
+	record := &dao.AuditGORM{
+		Actor:  auditActor(c),
+		Action: action,
+		Target: dao.AuditTarget(dao.AuditTargetModule, id),
+		Before: before,
+		After:  mgr.moduleAuditState(id),
+	}
+	if resp.Code != http.StatusOK {
+		record.Error = resp.Message
+	}
+	err := mgr.Audit.Append(record)
+	if err != nil {
+		log.Errorf("Failed to append audit record of '%s' on module '%s', error: %v", action, id, err)
+	}
+}
+
+// moduleAuditState returns the module's state recorded in the audit log, or an empty string if the module does not
+// exist.
+func (mgr *ModuleManager) moduleAuditState(id string) string {
+	if len(id) == 0 {
+		return ""
+	}
+	module, err := mgr.Module.QueryByID(id)
+	if err != nil || module == nil {
+		return ""
+	}
+	state := fmt.Sprintf("name=%s desire_state=%s version=%d", module.Name, pb.ModuleState(module.DesireState),
+		module.Version)
+	if len(module.Hypertable) > 0 {
+		state += " hypertable=" + module.Hypertable
+	}
+	return state
+}
+
+// listAuditV2 godoc
+// @Summary      List audit records
+// @Description  List the audit records of management operations and state transitions matching the filters,
+// @Description  the latest record first
+// @Tags         audit-v2
+// @Produce      json
+// @Param        actor   query  string  false  "only return the records of this actor, like 'alice' or 'agent:<id>'"
+// @Param        action  query  string  false  "only return the records of this action, like 'module.deploy'"
+// @Param        target  query  string  false  "only return the records of this target, like 'module:<id>'"
+// @Param        limit   query  int     false  "the maximal number of returned records, 100 by default"
+// @Param        offset  query  int     false  "the number of matching records skipped"
+// @Success      200  {object}  AuditPage
+// @Failure      400  {object}  ErrorResp
+// @Failure      500  {object}  ErrorResp
+// @Router       /api/v2/audit [get].
+func (mgr *ModuleManager) listAuditV2(c *gin.Context) {
+	limit, offset, ok := getPage(c)
+	if !ok {
+		return
+	}
+	filter := dao.AuditFilter{Actor: c.Query("actor"), Action: c.Query("action"), Target: c.Query("target")}
+	records, total, err := mgr.Audit.ListPage(filter, limit, offset)
+	if err != nil {
+		abortWithError(c, http.StatusInternalServerError, "Query Error: "+err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, AuditPage{Page{Total: total, Limit: limit, Offset: offset}, records})
+}
@@ -0,0 +1,116 @@
+// Copyright (C) 2023  Tricorder Observability
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package http
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/tricorder/src/api-server/auth"
+	"github.com/tricorder/src/api-server/http/api"
+	"github.com/tricorder/src/api-server/http/dao"
+)
+
+// Tests that the mutating calls are recorded to the audit log with the authenticated principal,
+// and that only admins can list the audit log.
+func TestAudit(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	tokensFile := filepath.Join(t.TempDir(), "tokens.yaml")
+	require.Nil(os.WriteFile(tokensFile, []byte(`
+tokens:
+  - name: viewer
+    role: viewer
+    token: viewer-token
+  - name: alice
+    role: admin
+    token: admin-token
+`), 0o600))
+	authenticator, err := auth.NewAuthenticator(auth.Config{TokensFile: tokensFile})
+	require.Nil(err)
+
+	mgr := newTestModuleManager(t)
+	mgr.authenticator = authenticator
+	require.Nil(mgr.NodeAgent.SaveAgent(&dao.NodeAgentGORM{AgentID: "agent", NodeName: "node"}))
+	router := gin.New()
+	mgr.registerV2(router)
+
+	serve := func(method, path, token string, body any, resp any) int {
+		var reqBody bytes.Buffer
+		if body != nil {
+			require.Nil(json.NewEncoder(&reqBody).Encode(body))
+		}
+		req := httptest.NewRequest(method, path, &reqBody)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		if resp != nil {
+			require.Nil(json.Unmarshal(w.Body.Bytes(), resp), w.Body.String())
+		}
+		return w.Code
+	}
+
+	moduleReq := stdoutModuleReq()
+	var idResp ModuleIDResp
+	require.Equal(http.StatusCreated, serve("POST", api.MODULES_V2_PATH, "admin-token", moduleReq, &idResp))
+	id := idResp.ID
+	require.Equal(http.StatusAccepted,
+		serve("POST", api.GetModuleActionV2Path(id, api.DEPLOY_ACTION), "admin-token", nil, nil))
+	require.Equal(http.StatusConflict, serve("DELETE", api.GetModuleV2Path(id), "admin-token", nil, nil))
+
+	assert.Equal(http.StatusForbidden, serve("GET", api.AUDIT_V2_PATH, "viewer-token", nil, nil))
+
+	var page AuditPage
+	require.Equal(http.StatusOK, serve("GET", api.AUDIT_V2_PATH, "admin-token", nil, &page))
+	assert.Equal(int64(3), page.Total)
+	require.Len(page.Data, 3)
+	for _, record := range page.Data {
+		assert.Equal("alice", record.Actor)
+		assert.Equal(dao.AuditTarget(dao.AuditTargetModule, id), record.Target)
+	}
+
+	deleteRecord := page.Data[0]
+	assert.Equal(dao.AuditModuleDelete, deleteRecord.Action)
+	assert.Contains(deleteRecord.Error, "please undeploy first")
+	assert.Equal(deleteRecord.Before, deleteRecord.After)
+
+	deployRecord := page.Data[1]
+	assert.Equal(dao.AuditModuleDeploy, deployRecord.Action)
+	assert.Contains(deployRecord.Before, "desire_state=CREATED_")
+	assert.Contains(deployRecord.After, "desire_state=DEPLOYED")
+	assert.Empty(deployRecord.Error)
+
+	createRecord := page.Data[2]
+	assert.Equal(dao.AuditModuleCreate, createRecord.Action)
+	assert.Empty(createRecord.Before)
+	assert.Contains(createRecord.After, "name=stdout")
+
+	page = AuditPage{}
+	require.Equal(http.StatusOK,
+		serve("GET", api.AUDIT_V2_PATH+"?action="+dao.AuditModuleDeploy+"&limit=1", "admin-token", nil, &page))
+	assert.Equal(int64(1), page.Total)
+	assert.Equal(1, page.Limit)
+}
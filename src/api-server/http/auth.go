@@ -0,0 +1,53 @@
+// Copyright (C) 2023  Tricorder Observability
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+package http
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/tricorder/src/api-server/auth"
+	"github.com/tricorder/src/utils/log"
+)
+
+// The key of the authenticated principal in the gin context, see requireRole().
+const principalKey = "principal"
+
+// requireRole returns a handler that rejects the requests whose bearer token's role does not allow the required role,
+// with 401 if the request has no valid token, or 403. All requests are allowed if authentication is disabled.
+// The authenticated auth.Principal is set to the context with principalKey.
+func (mgr *ModuleManager) requireRole(required auth.Role) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !mgr.authenticator.Enabled() {
+			return
+		}
+		token, _ := auth.BearerToken(c.GetHeader("Authorization"))
+		principal, err := mgr.authenticator.Authorize(token, required)
+		if errors.Is(err, auth.ErrUnauthenticated) {
+			log.Debugf("Rejected unauthenticated request %s %s, error: %v", c.Request.Method, c.Request.URL.Path, err)
+			c.Header("WWW-Authenticate", `Bearer realm="starship"`)
+			abortWithError(c, http.StatusUnauthorized, err.Error())
+			return
+		}
+		if err != nil {
+			log.Debugf("Rejected unauthorized request %s %s, error: %v", c.Request.Method, c.Request.URL.Path, err)
+			abortWithError(c, http.StatusForbidden, err.Error())
+			return
+		}
+		c.Set(principalKey, principal)
+	}
+}
@@ -0,0 +1,76 @@
+// Copyright (C) 2023  Tricorder Observability
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/tricorder/src/api-server/auth"
+	"github.com/tricorder/src/api-server/http/api"
+)
+
+// Tests that the routes reject the requests without a bearer token whose role is allowed.
+func TestRequireRole(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	tokensFile := filepath.Join(t.TempDir(), "tokens.yaml")
+	require.Nil(os.WriteFile(tokensFile, []byte(`
+tokens:
+  - name: viewer
+    role: viewer
+    token: viewer-token
+  - name: admin
+    role: admin
+    token: admin-token
+`), 0o600))
+	authenticator, err := auth.NewAuthenticator(auth.Config{TokensFile: tokensFile})
+	require.Nil(err)
+
+	mgr := newTestModuleManager(t)
+	mgr.authenticator = authenticator
+	router := gin.New()
+	mgr.registerV2(router)
+
+	serve := func(method, path, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		if len(token) > 0 {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := serve("GET", api.MODULES_V2_PATH, "")
+	assert.Equal(http.StatusUnauthorized, w.Code)
+	assert.Contains(w.Header().Get("WWW-Authenticate"), "Bearer")
+	assert.Equal(http.StatusUnauthorized, serve("GET", api.MODULES_V2_PATH, "wrong-token").Code)
+	assert.Equal(http.StatusOK, serve("GET", api.MODULES_V2_PATH, "viewer-token").Code)
+
+	w = serve("DELETE", api.GetModuleV2Path("id"), "viewer-token")
+	assert.Equal(http.StatusForbidden, w.Code)
+	assert.Contains(w.Body.String(), "not allowed")
+	// Passes the authorization, the module does not exist.
+	assert.Equal(http.StatusNotFound, serve("DELETE", api.GetModuleV2Path("id"), "admin-token").Code)
+}
@@ -0,0 +1,83 @@
+// Copyright (C) 2023  Tricorder Observability
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package http
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/tricorder/src/api-server/http/api"
+	"github.com/tricorder/src/api-server/http/dao"
+)
+
+// listCatalogHttp godoc
+// @Summary      List catalog
+// @Description  List the modules bundled with API Server, with their descriptions, the kernel features their probes
+// @Description  need, and their parameters. See /api/catalog/{name}.
+// @Tags         catalog
+// @Produce      json
+// @Success      200  {object}  ListCatalogResp
+// @Router       /api/catalog [get].
+func (mgr *ModuleManager) listCatalogHttp(c *gin.Context) {
+	c.JSON(http.StatusOK, ListCatalogResp{
+		HTTPResp: HTTPResp{Code: http.StatusOK, Message: "Success"},
+		Data:     mgr.catalog.List(),
+	})
+}
+
+// createFromCatalogHttp godoc
+// @Summary      Create module from catalog
+// @Description  Create a module from the catalog entry, whose BCC code defines the parameters with the values in the
+// @Description  request, or their default values.
+// @Tags         catalog
+// @Accept       json
+// @Produce      json
+// @Param        name    path  string                true  "catalog entry name"
+// @Param        module  body  CreateFromCatalogReq  true  "The name, parameters and tenant of the created module"
+// @Success      200  {object}  CreateModuleResp
+// @Router       /api/catalog/{name} [post].
+func (mgr *ModuleManager) createFromCatalogHttp(c *gin.Context) {
+	var req CreateFromCatalogReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusOK, HTTPResp{Code: http.StatusBadRequest, Message: "Request Error: " + err.Error()})
+		return
+	}
+	name := c.Param(api.CATALOG_NAME_PARAM)
+	entry := mgr.catalog.Get(name)
+	if entry == nil {
+		c.JSON(http.StatusOK, HTTPResp{Code: http.StatusNotFound, Message: "catalog module " + name + " does not exist"})
+		return
+	}
+	m, err := entry.Instantiate(req.Parameters)
+	if err != nil {
+		c.JSON(http.StatusOK, HTTPResp{Code: http.StatusBadRequest, Message: err.Error()})
+		return
+	}
+	body := importRequest(m)
+	body.Name = entry.Name
+	if len(req.Name) > 0 {
+		body.Name = req.Name
+	}
+	body.Tenant = req.Tenant
+	if err := resolveTenant(c, &body); err != nil {
+		c.JSON(http.StatusOK, HTTPResp{Code: statusCode(err), Message: err.Error()})
+		return
+	}
+	result := mgr.createModule(body)
+	mgr.auditModule(c, dao.AuditModuleCreate, result.ID, "", result.HTTPResp)
+	c.JSON(http.StatusOK, result)
+}
@@ -0,0 +1,108 @@
+// Copyright (C) 2023  Tricorder Observability
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package http
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/tricorder/src/api-server/catalog"
+	"github.com/tricorder/src/api-server/http/api"
+)
+
+// Tests that the catalog is listed, and modules are created from its entries with the parameters.
+func TestCatalog(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	dir := filepath.Join(t.TempDir(), "stdout")
+	require.Nil(os.MkdirAll(dir, 0o755))
+	for name, content := range map[string]string{
+		catalog.EntryFile: `
+name: stdout
+description: Writes to STDOUT.
+kernel_features: [kprobe]
+manifest: module.json
+bcc: module.bcc
+wasm: module.wasm
+parameters:
+  - name: LIMIT
+    type: int
+    default: 10
+`,
+		"module.json": `{"name": "m", "ebpf": {"perf_buffer_name": "events"}, "wasm": {"fn_name": "fn",
+			"output_schema": {"fields": [{"name": "data", "type": 5}]}, "sink": {"type": 2}}}`,
+		"module.bcc":  "#define LIMIT 1\n",
+		"module.wasm": "wasm",
+	} {
+		require.Nil(os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644))
+	}
+	c, err := catalog.Load(filepath.Dir(dir))
+	require.Nil(err)
+
+	mgr := newTestModuleManager(t)
+	mgr.catalog = c
+	router := gin.New()
+	router.GET(api.ROOT+api.CATALOG, mgr.listCatalogHttp)
+	router.POST(api.ROOT+api.CATALOG_ENTRY, mgr.createFromCatalogHttp)
+
+	var listResp ListCatalogResp
+	assert.Equal(http.StatusOK, serveV2(t, router, "GET", api.CATALOG_PATH, nil, &listResp))
+	require.Len(listResp.Data, 1)
+	assert.Equal("stdout", listResp.Data[0].Name)
+	assert.Equal("Writes to STDOUT.", listResp.Data[0].Description)
+	assert.Equal([]string{"kprobe"}, listResp.Data[0].KernelFeatures)
+	require.Len(listResp.Data[0].Parameters, 1)
+	assert.Equal("10", *listResp.Data[0].Parameters[0].Default)
+
+	var resp CreateModuleResp
+	serveV2(t, router, "POST", api.GetCatalogEntryPath("stdout"), CreateFromCatalogReq{}, &resp)
+	require.Equal(http.StatusOK, resp.Code, resp.Message)
+	module, err := mgr.Module.QueryByID(resp.ID)
+	require.Nil(err)
+	assert.Equal("stdout", module.Name)
+	assert.Equal("#define LIMIT 10\n", module.Ebpf)
+	assert.Equal("events", module.EbpfPerfBufferName)
+	assert.Equal([]byte("wasm"), module.Wasm)
+
+	resp = CreateModuleResp{}
+	serveV2(t, router, "POST", api.GetCatalogEntryPath("stdout"),
+		CreateFromCatalogReq{Name: "limit", Parameters: map[string]string{"LIMIT": "5"}}, &resp)
+	require.Equal(http.StatusOK, resp.Code, resp.Message)
+	module, err = mgr.Module.QueryByID(resp.ID)
+	require.Nil(err)
+	assert.Equal("limit", module.Name)
+	assert.Equal("#define LIMIT 5\n", module.Ebpf)
+
+	// The name is taken.
+	resp = CreateModuleResp{}
+	serveV2(t, router, "POST", api.GetCatalogEntryPath("stdout"), CreateFromCatalogReq{}, &resp)
+	assert.Equal(http.StatusConflict, resp.Code)
+	resp = CreateModuleResp{}
+	serveV2(t, router, "POST", api.GetCatalogEntryPath("stdout"),
+		CreateFromCatalogReq{Name: "x", Parameters: map[string]string{"LIMIT": "x"}}, &resp)
+	assert.Equal(http.StatusBadRequest, resp.Code)
+	assert.Contains(resp.Message, "'x' is not an int")
+	resp = CreateModuleResp{}
+	serveV2(t, router, "POST", api.GetCatalogEntryPath("unknown"), CreateFromCatalogReq{}, &resp)
+	assert.Equal(http.StatusNotFound, resp.Code)
+}
@@ -6,59 +6,135 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
+	"strconv"
 	"time"
 
 	apiserver "github.com/tricorder/src/api-server/http"
 	"github.com/tricorder/src/api-server/http/api"
+	"github.com/tricorder/src/api-server/http/dao"
+	commonpb "github.com/tricorder/src/pb/module/common"
 	"github.com/tricorder/src/utils/errors"
 )
 
 // Client provides APIs to API Server's HTTP server.
+// The modules and agents are managed with the v2 API, the module versions, instances and hypertables with the v1 API.
 type Client struct {
 	// The URL to the API Server.
 	url string
 
-	listModuleURL     string
-	listAgentURL      string
-	createModuleURL   string
-	deployModuleURL   string
-	undeployModuleURL string
-	deleteModuleURL   string
+	modulesURL string
+	agentsURL  string
+
+	// The bearer token sent with the requests, not sent if empty.
+	token string
 }
 
 // NewClient returns a new Client instance.
 func NewClient(url string) *Client {
+	return NewClientWithToken(url, "")
+}
+
+// NewClientWithToken returns a new Client instance that authenticates to the API Server with the API token or JWT.
+func NewClientWithToken(url, token string) *Client {
 	return &Client{
 		url: url,
 
-		listModuleURL:     api.GetURL(url, api.LIST_MODULE_PATH),
-		listAgentURL:      api.GetURL(url, api.LIST_AGENT_PATH),
-		createModuleURL:   api.GetURL(url, api.CREATE_MODULE_PATH),
-		deployModuleURL:   api.GetURL(url, api.DEPLOY_MODULE_PATH),
-		undeployModuleURL: api.GetURL(url, api.UNDEPLOY_MODULE_PATH),
-		deleteModuleURL:   api.GetURL(url, api.DELETE_MODULE_PATH),
+		modulesURL: api.GetURL(url, api.MODULES_V2_PATH),
+		agentsURL:  api.GetURL(url, api.AGENTS_V2_PATH),
+		token:      token,
 	}
 }
 
-func executeHTTPReq(req *http.Request, resp any) error {
+func (c *Client) doHTTPReq(req *http.Request) (*http.Response, []byte, error) {
+	if len(c.token) > 0 {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
 	httpClient := http.Client{Timeout: time.Duration(3) * time.Second}
 	httpResp, err := httpClient.Do(req)
 	if err != nil {
-		return errors.Wrap("execute http request", "do request", err)
+		return nil, nil, errors.Wrap("execute http request", "do request", err)
 	}
 
 	defer httpResp.Body.Close()
 	body, err := io.ReadAll(httpResp.Body)
 	if err != nil {
-		return errors.Wrap("execute http request", "read response body", err)
+		return nil, nil, errors.Wrap("execute http request", "read response body", err)
+	}
+	return httpResp, body, nil
+}
+
+func (c *Client) executeHTTPReq(req *http.Request, resp any) error {
+	httpResp, body, err := c.doHTTPReq(req)
+	if err != nil {
+		return err
+	}
+	// The v1 API responds with 200, except when the request is rejected by authentication or authorization.
+	if httpResp.StatusCode >= http.StatusBadRequest {
+		errResp := apiserver.ErrorResp{}
+		_ = json.Unmarshal(body, &errResp)
+		return fmt.Errorf("request failed with status %d, error: %s", httpResp.StatusCode, errResp.Error.Message)
 	}
 	err = json.Unmarshal(body, resp)
 	if err != nil {
-		return errors.Wrap("listing agents", "decode response body", err)
+		return errors.Wrap("execute http request", "decode response body", err)
 	}
 	return nil
 }
 
+// executeV2Req executes a request to the v2 API, and decodes the response body into resp if the request succeeded and
+// resp is not nil. Returns the status code of the response, with the error message from the body if it failed.
+func (c *Client) executeV2Req(req *http.Request, resp any) (apiserver.HTTPResp, error) {
+	httpResp, body, err := c.doHTTPReq(req)
+	if err != nil {
+		return apiserver.HTTPResp{}, err
+	}
+	if httpResp.StatusCode >= http.StatusBadRequest {
+		errResp := apiserver.ErrorResp{}
+		err = json.Unmarshal(body, &errResp)
+		if err != nil {
+			return apiserver.HTTPResp{}, errors.Wrap("execute http request",
+				fmt.Sprintf("decode error response body with status %d", httpResp.StatusCode), err)
+		}
+		return apiserver.HTTPResp{Code: httpResp.StatusCode, Message: errResp.Error.Message}, nil
+	}
+	if resp != nil {
+		err = json.Unmarshal(body, resp)
+		if err != nil {
+			return apiserver.HTTPResp{}, errors.Wrap("execute http request", "decode response body", err)
+		}
+	}
+	return apiserver.HTTPResp{Code: httpResp.StatusCode, Message: http.StatusText(httpResp.StatusCode)}, nil
+}
+
+// maxPageLimit is the maximal number of items that the API Server returns in one page.
+const maxPageLimit = 1000
+
+// listAll requests all pages of the list at the URL, and passes each page to collect, which decodes the page and
+// returns the total number of items and the number of items in the page.
+func (c *Client) listAll(url string, collect func(body []byte) (int64, int, error)) (apiserver.HTTPResp, error) {
+	var listed int64
+	for {
+		req, err := http.NewRequest("GET", fmt.Sprintf("%s&limit=%d&offset=%d", url, maxPageLimit, listed), nil)
+		if err != nil {
+			return apiserver.HTTPResp{}, errors.Wrap("listing", "create request", err)
+		}
+		var page json.RawMessage
+		resp, err := c.executeV2Req(req, &page)
+		if err != nil || resp.Code != http.StatusOK {
+			return resp, err
+		}
+		total, n, err := collect(page)
+		if err != nil {
+			return apiserver.HTTPResp{}, errors.Wrap("listing", "decode page", err)
+		}
+		listed += int64(n)
+		if n == 0 || listed >= total {
+			return resp, nil
+		}
+	}
+}
+
 // ListAgents returns the list of agents stored on the API Server.
 // agentReq is the request data structure, it will be converted to JSON and sent to the API Server.
 func (c *Client) ListAgents(agentReq *apiserver.ListAgentReq) (*apiserver.ListAgentResp, error) {
@@ -67,117 +143,450 @@ func (c *Client) ListAgents(agentReq *apiserver.ListAgentReq) (*apiserver.ListAg
 		field = agentReq.Fields
 	}
 
-	req, err := http.NewRequest("GET", fmt.Sprintf("%s?fields=%s", c.listAgentURL, field), nil)
+	resp := &apiserver.ListAgentResp{Data: []dao.NodeAgentGORM{}}
+	httpResp, err := c.listAll(fmt.Sprintf("%s?fields=%s", c.agentsURL, field), func(body []byte) (int64, int, error) {
+		page := apiserver.AgentPage{}
+		err := json.Unmarshal(body, &page)
+		resp.Data = append(resp.Data, page.Data...)
+		return page.Total, len(page.Data), err
+	})
 	if err != nil {
-		return nil, errors.Wrap("listing agents", "create request", err)
+		return nil, errors.Wrap("listing agents", "execute http request", err)
 	}
+	resp.HTTPResp = httpResp
+	return resp, nil
+}
 
-	resp := &apiserver.ListAgentResp{}
-	err = executeHTTPReq(req, resp)
+// GetAgent returns the agent with the ID, the response's code is 404 if the agent does not exist.
+func (c *Client) GetAgent(agentID string) (*dao.NodeAgentGORM, *apiserver.HTTPResp, error) {
+	req, err := http.NewRequest("GET", api.GetURL(c.url, api.GetAgentV2Path(agentID)), nil)
 	if err != nil {
-		return nil, errors.Wrap("listing agents", "execute http request", err)
+		return nil, nil, errors.Wrap("getting agent", "create request", err)
 	}
 
-	return resp, nil
+	agent := &dao.NodeAgentGORM{}
+	resp, err := c.executeV2Req(req, agent)
+	if err != nil {
+		return nil, nil, errors.Wrap("getting agent", "execute http request", err)
+	}
+	if resp.Code != http.StatusOK {
+		agent = nil
+	}
+	return agent, &resp, nil
+}
+
+// ListAudit returns at most limit audit records matching the filter, skipping the first offset ones, the latest first.
+// The response's code is 403 if the token's role is not admin.
+func (c *Client) ListAudit(filter dao.AuditFilter, limit, offset int) (
+	*apiserver.AuditPage, *apiserver.HTTPResp, error,
+) {
+	query := url.Values{}
+	query.Set("limit", strconv.Itoa(limit))
+	query.Set("offset", strconv.Itoa(offset))
+	if len(filter.Actor) > 0 {
+		query.Set("actor", filter.Actor)
+	}
+	if len(filter.Action) > 0 {
+		query.Set("action", filter.Action)
+	}
+	if len(filter.Target) > 0 {
+		query.Set("target", filter.Target)
+	}
+	req, err := http.NewRequest("GET", api.GetURL(c.url, api.AUDIT_V2_PATH)+"?"+query.Encode(), nil)
+	if err != nil {
+		return nil, nil, errors.Wrap("listing audit records", "create request", err)
+	}
+
+	page := &apiserver.AuditPage{}
+	resp, err := c.executeV2Req(req, page)
+	if err != nil {
+		return nil, nil, errors.Wrap("listing audit records", "execute http request", err)
+	}
+	if resp.Code != http.StatusOK {
+		page = nil
+	}
+	return page, &resp, nil
 }
 
 // CreateModule creates a new module on the API Server.
 // moduleReq is the request data structure, it will be converted to JSON and sent to the API Server.
+// The response's ID is the ID of the created module.
 func (c *Client) CreateModule(moduleReq *apiserver.CreateModuleReq) (*apiserver.CreateModuleResp, error) {
 	bodyBytes, err := json.Marshal(moduleReq)
 	if err != nil {
 		return nil, errors.Wrap("creating module", "encode req body", err)
 	}
 
-	req, err := http.NewRequest("POST", c.createModuleURL, bytes.NewBuffer(bodyBytes))
+	req, err := http.NewRequest("POST", c.modulesURL, bytes.NewBuffer(bodyBytes))
 	if err != nil {
 		return nil, errors.Wrap("creating module", "create request", err)
 	}
 
 	req.Header.Set("Content-Type", "application/json")
 
-	resp := &apiserver.CreateModuleResp{}
-	err = executeHTTPReq(req, resp)
+	idResp := &apiserver.ModuleIDResp{}
+	httpResp, err := c.executeV2Req(req, idResp)
 	if err != nil {
 		return nil, errors.Wrap("creating module", "execute http request", err)
 	}
 
+	return &apiserver.CreateModuleResp{HTTPResp: httpResp, ID: idResp.ID}, nil
+}
+
+// ValidateModule checks the module like creating and deploying it, without creating it.
+func (c *Client) ValidateModule(moduleReq *apiserver.CreateModuleReq) (*apiserver.ValidateModuleResp, error) {
+	bodyBytes, err := json.Marshal(moduleReq)
+	if err != nil {
+		return nil, errors.Wrap("validating module", "encode req body", err)
+	}
+
+	req, err := http.NewRequest("POST", api.GetURL(c.url, api.VALIDATE_MODULE_PATH), bytes.NewBuffer(bodyBytes))
+	if err != nil {
+		return nil, errors.Wrap("validating module", "create request", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	resp := &apiserver.ValidateModuleResp{}
+	err = c.executeHTTPReq(req, resp)
+	if err != nil {
+		return nil, errors.Wrap("validating module", "execute http request", err)
+	}
+
+	return resp, nil
+}
+
+// ListCatalog returns the modules bundled with API Server, see src/api-server/catalog.
+func (c *Client) ListCatalog() (*apiserver.ListCatalogResp, error) {
+	req, err := http.NewRequest("GET", api.GetURL(c.url, api.CATALOG_PATH), nil)
+	if err != nil {
+		return nil, errors.Wrap("listing catalog", "create request", err)
+	}
+
+	resp := &apiserver.ListCatalogResp{}
+	err = c.executeHTTPReq(req, resp)
+	if err != nil {
+		return nil, errors.Wrap("listing catalog", "execute http request", err)
+	}
+
+	return resp, nil
+}
+
+// CreateModuleFromCatalog creates a module from the catalog entry with the name, the request sets the created module's
+// name, tenant and parameters.
+func (c *Client) CreateModuleFromCatalog(name string, catalogReq *apiserver.CreateFromCatalogReq) (
+	*apiserver.CreateModuleResp, error,
+) {
+	bodyBytes, err := json.Marshal(catalogReq)
+	if err != nil {
+		return nil, errors.Wrap("creating module from catalog", "encode req body", err)
+	}
+
+	req, err := http.NewRequest("POST", api.GetURL(c.url, api.GetCatalogEntryPath(url.PathEscape(name))),
+		bytes.NewBuffer(bodyBytes))
+	if err != nil {
+		return nil, errors.Wrap("creating module from catalog", "create request", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	resp := &apiserver.CreateModuleResp{}
+	err = c.executeHTTPReq(req, resp)
+	if err != nil {
+		return nil, errors.Wrap("creating module from catalog", "execute http request", err)
+	}
+
 	return resp, nil
 }
 
+// ExportModule returns the *.tar.gz bundle of the module, see src/api-server/bundle.
+func (c *Client) ExportModule(moduleId string) ([]byte, error) {
+	req, err := http.NewRequest("GET", api.GetURL(c.url, api.GetModuleBundleV2Path(moduleId)), nil)
+	if err != nil {
+		return nil, errors.Wrap("exporting module", "create request", err)
+	}
+
+	httpResp, body, err := c.doHTTPReq(req)
+	if err != nil {
+		return nil, errors.Wrap("exporting module", "execute http request", err)
+	}
+	if httpResp.StatusCode != http.StatusOK {
+		errResp := apiserver.ErrorResp{}
+		_ = json.Unmarshal(body, &errResp)
+		return nil, fmt.Errorf("exporting module failed with status %d, error: %s",
+			httpResp.StatusCode, errResp.Error.Message)
+	}
+	return body, nil
+}
+
+// ImportModule creates a module from the *.tar.gz bundle, named by name and in the tenant if they are not empty.
+func (c *Client) ImportModule(bundle []byte, name, tenant string) (*apiserver.CreateModuleResp, error) {
+	query := url.Values{}
+	if len(name) > 0 {
+		query.Set("name", name)
+	}
+	if len(tenant) > 0 {
+		query.Set("tenant", tenant)
+	}
+	path := api.IMPORT_MODULE_V2_PATH
+	if len(query) > 0 {
+		path += "?" + query.Encode()
+	}
+	req, err := http.NewRequest("POST", api.GetURL(c.url, path), bytes.NewReader(bundle))
+	if err != nil {
+		return nil, errors.Wrap("importing module", "create request", err)
+	}
+
+	req.Header.Set("Content-Type", "application/gzip")
+
+	idResp := &apiserver.ModuleIDResp{}
+	httpResp, err := c.executeV2Req(req, idResp)
+	if err != nil {
+		return nil, errors.Wrap("importing module", "execute http request", err)
+	}
+
+	return &apiserver.CreateModuleResp{HTTPResp: httpResp, ID: idResp.ID}, nil
+}
+
+// UpdateModule updates the fields of the module that are set in moduleReq, the module must not be deployed.
+func (c *Client) UpdateModule(moduleId string, moduleReq *apiserver.UpdateModuleReq) (*apiserver.HTTPResp, error) {
+	bodyBytes, err := json.Marshal(moduleReq)
+	if err != nil {
+		return nil, errors.Wrap("updating module", "encode req body", err)
+	}
+
+	req, err := http.NewRequest("PATCH", api.GetURL(c.url, api.GetModuleV2Path(moduleId)), bytes.NewBuffer(bodyBytes))
+	if err != nil {
+		return nil, errors.Wrap("updating module", "create request", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.executeV2Req(req, &apiserver.ModuleIDResp{})
+	if err != nil {
+		return nil, errors.Wrap("updating module", "execute http request", err)
+	}
+	return &resp, nil
+}
+
+// GetModule returns the module with the ID, the response's code is 404 if the module does not exist.
+func (c *Client) GetModule(moduleId string) (*dao.ModuleGORM, *apiserver.HTTPResp, error) {
+	req, err := http.NewRequest("GET", api.GetURL(c.url, api.GetModuleV2Path(moduleId)), nil)
+	if err != nil {
+		return nil, nil, errors.Wrap("getting module", "create request", err)
+	}
+
+	module := &dao.ModuleGORM{}
+	resp, err := c.executeV2Req(req, module)
+	if err != nil {
+		return nil, nil, errors.Wrap("getting module", "execute http request", err)
+	}
+	if resp.Code != http.StatusOK {
+		module = nil
+	}
+	return module, &resp, nil
+}
+
 // DeployModule deploys a module on the API Server.
 // moduleId is the ID of the module to be deployed.
-func (c *Client) DeployModule(moduleId string) (*apiserver.DeployModuleResp, error) {
-	url := fmt.Sprintf("%s?id=%s", c.deployModuleURL, moduleId)
+// force allows the changes that might lose data when migrating the module's data table.
+func (c *Client) DeployModule(moduleId string, force bool) (*apiserver.DeployModuleResp, error) {
+	url := fmt.Sprintf("%s?force=%t", api.GetURL(c.url, api.GetModuleActionV2Path(moduleId, api.DEPLOY_ACTION)), force)
 	req, err := http.NewRequest("POST", url, nil)
 	if err != nil {
 		return nil, errors.Wrap("deploying module", "create request", err)
 	}
 
-	resp := &apiserver.DeployModuleResp{}
-	err = executeHTTPReq(req, resp)
+	actionResp := &apiserver.ModuleActionResp{}
+	httpResp, err := c.executeV2Req(req, actionResp)
 	if err != nil {
 		return nil, errors.Wrap("deploying module", "execute http request", err)
 	}
 
-	return resp, nil
+	return &apiserver.DeployModuleResp{HTTPResp: httpResp, UID: actionResp.UID}, nil
 }
 
 // UndeployModule undeploys a module on the API Server.
 // moduleId is the ID of the module to be undeployed.
 func (c *Client) UndeployModule(moduleId string) (*apiserver.UndeployModuleResp, error) {
-	url := fmt.Sprintf("%s?id=%s", c.undeployModuleURL, moduleId)
+	url := api.GetURL(c.url, api.GetModuleActionV2Path(moduleId, api.UNDEPLOY_ACTION))
 	req, err := http.NewRequest("POST", url, nil)
 	if err != nil {
 		return nil, errors.Wrap("undeploying module", "create request", err)
 	}
 
-	resp := &apiserver.UndeployModuleResp{}
-	err = executeHTTPReq(req, resp)
+	httpResp, err := c.executeV2Req(req, nil)
 	if err != nil {
 		return nil, errors.Wrap("undeploying module", "execute http request", err)
 	}
 
-	return resp, nil
+	return &apiserver.UndeployModuleResp{HTTPResp: httpResp}, nil
 }
 
 // DeleteModule deletes a module on the API Server.
 // moduleId is the ID of the module to be deleted.
 func (c *Client) DeleteModule(moduleId string) (*apiserver.DeleteModuleResp, error) {
-	url := fmt.Sprintf("%s?id=%s", c.deleteModuleURL, moduleId)
-	req, err := http.NewRequest("GET", url, nil)
+	req, err := http.NewRequest("DELETE", api.GetURL(c.url, api.GetModuleV2Path(moduleId)), nil)
 	if err != nil {
 		return nil, errors.Wrap("deleting module", "create request", err)
 	}
 
-	resp := &apiserver.DeleteModuleResp{}
-	err = executeHTTPReq(req, resp)
+	httpResp, err := c.executeV2Req(req, nil)
 	if err != nil {
 		return nil, errors.Wrap("deleting module", "execute http request", err)
 	}
 
+	return &apiserver.DeleteModuleResp{HTTPResp: httpResp}, nil
+}
+
+// ListModuleInstances lists the instances of a module on the API Server, one per agent.
+// moduleId is the ID of the module whose instances are listed.
+func (c *Client) ListModuleInstances(moduleId string) (*apiserver.ListModuleInstanceResp, error) {
+	url := api.GetURL(c.url, api.GetModuleInstancesPath(moduleId))
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, errors.Wrap("listing module instances", "create request", err)
+	}
+
+	resp := &apiserver.ListModuleInstanceResp{}
+	err = c.executeHTTPReq(req, resp)
+	if err != nil {
+		return nil, errors.Wrap("listing module instances", "execute http request", err)
+	}
+
+	return resp, nil
+}
+
+// CreateModuleVersion creates a new version of an existing module on the API Server.
+// moduleReq is the new version of the module, its name is ignored.
+func (c *Client) CreateModuleVersion(moduleId string, moduleReq *apiserver.CreateModuleReq,
+) (*apiserver.CreateModuleVersionResp, error) {
+	bodyBytes, err := json.Marshal(moduleReq)
+	if err != nil {
+		return nil, errors.Wrap("creating module version", "encode req body", err)
+	}
+
+	url := api.GetURL(c.url, api.GetModuleVersionsPath(moduleId))
+	req, err := http.NewRequest("POST", url, bytes.NewBuffer(bodyBytes))
+	if err != nil {
+		return nil, errors.Wrap("creating module version", "create request", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	resp := &apiserver.CreateModuleVersionResp{}
+	err = c.executeHTTPReq(req, resp)
+	if err != nil {
+		return nil, errors.Wrap("creating module version", "execute http request", err)
+	}
+
+	return resp, nil
+}
+
+// ListModuleVersions lists the versions of a module on the API Server, the latest version first.
+func (c *Client) ListModuleVersions(moduleId string) (*apiserver.ListModuleVersionResp, error) {
+	url := api.GetURL(c.url, api.GetModuleVersionsPath(moduleId))
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, errors.Wrap("listing module versions", "create request", err)
+	}
+
+	resp := &apiserver.ListModuleVersionResp{}
+	err = c.executeHTTPReq(req, resp)
+	if err != nil {
+		return nil, errors.Wrap("listing module versions", "execute http request", err)
+	}
+
+	return resp, nil
+}
+
+// UpgradeModule upgrades a module on the API Server to the specified version.
+// force allows the changes that might lose data when migrating the module's data table.
+// skipDisconnected upgrades the module even if some of its agents are not connected, their instances are upgraded
+// when they connect.
+func (c *Client) UpgradeModule(moduleId string, version int, force, skipDisconnected bool) (
+	*apiserver.UpgradeModuleResp, error,
+) {
+	url := fmt.Sprintf("%s?version=%d&force=%t&skip_disconnected=%t",
+		api.GetURL(c.url, api.GetUpgradeModulePath(moduleId)), version, force, skipDisconnected)
+	req, err := http.NewRequest("POST", url, nil)
+	if err != nil {
+		return nil, errors.Wrap("upgrading module", "create request", err)
+	}
+
+	resp := &apiserver.UpgradeModuleResp{}
+	err = c.executeHTTPReq(req, resp)
+	if err != nil {
+		return nil, errors.Wrap("upgrading module", "execute http request", err)
+	}
+
+	return resp, nil
+}
+
+// GetModuleHypertable returns the hypertable spec of the module's data table.
+func (c *Client) GetModuleHypertable(moduleId string) (*apiserver.ModuleHypertableResp, error) {
+	req, err := http.NewRequest("GET", api.GetURL(c.url, api.GetModuleHypertablePath(moduleId)), nil)
+	if err != nil {
+		return nil, errors.Wrap("getting module hypertable", "create request", err)
+	}
+
+	resp := &apiserver.ModuleHypertableResp{}
+	err = c.executeHTTPReq(req, resp)
+	if err != nil {
+		return nil, errors.Wrap("getting module hypertable", "execute http request", err)
+	}
+
+	return resp, nil
+}
+
+// UpdateModuleHypertable sets the hypertable spec of the module's data table, including the retention and
+// compression policies.
+func (c *Client) UpdateModuleHypertable(moduleId string, spec *commonpb.Hypertable) (*apiserver.HTTPResp, error) {
+	bodyBytes, err := json.Marshal(spec)
+	if err != nil {
+		return nil, errors.Wrap("updating module hypertable", "encode req body", err)
+	}
+
+	url := api.GetURL(c.url, api.GetModuleHypertablePath(moduleId))
+	req, err := http.NewRequest("POST", url, bytes.NewBuffer(bodyBytes))
+	if err != nil {
+		return nil, errors.Wrap("updating module hypertable", "create request", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	resp := &apiserver.HTTPResp{}
+	err = c.executeHTTPReq(req, resp)
+	if err != nil {
+		return nil, errors.Wrap("updating module hypertable", "execute http request", err)
+	}
+
 	return resp, nil
 }
 
 // ListModules lists all modules on the API Server.
 // moduleReq is the request data structure, it will be converted to JSON and sent to the API Server.
 func (c *Client) ListModules(moduleReq *apiserver.ListModuleReq) (*apiserver.ListModuleResp, error) {
-	field := "id,name,desire_state,create_time," +
+	field := "id,name,tenant,desire_state,create_time," +
 		"ebpf_fmt,ebpf_lang,schema_name,fn,schema_attr"
 	if moduleReq != nil && len(moduleReq.Fields) > 0 {
 		field = moduleReq.Fields
 	}
-
-	req, err := http.NewRequest("GET", fmt.Sprintf("%s?fields=%s", c.listModuleURL, field), nil)
-	if err != nil {
-		return nil, errors.Wrap("listing modules", "create request", err)
+	listURL := fmt.Sprintf("%s?fields=%s", c.modulesURL, field)
+	if moduleReq != nil && len(moduleReq.Tenant) > 0 {
+		listURL += "&tenant=" + url.QueryEscape(moduleReq.Tenant)
 	}
 
-	resp := &apiserver.ListModuleResp{}
-	err = executeHTTPReq(req, resp)
+	resp := &apiserver.ListModuleResp{Data: []dao.ModuleGORM{}}
+	httpResp, err := c.listAll(listURL, func(body []byte) (int64, int, error) {
+		page := apiserver.ModulePage{}
+		err := json.Unmarshal(body, &page)
+		resp.Data = append(resp.Data, page.Data...)
+		return page.Total, len(page.Data), err
+	})
 	if err != nil {
-		return nil, errors.Wrap("listing module", "execute http request", err)
+		return nil, errors.Wrap("listing modules", "execute http request", err)
 	}
-
+	resp.HTTPResp = httpResp
 	return resp, nil
 }
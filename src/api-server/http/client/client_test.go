@@ -13,13 +13,13 @@ import (
 	"github.com/tricorder/src/api-server/http/dao"
 	"github.com/tricorder/src/api-server/http/fake"
 	pb "github.com/tricorder/src/api-server/pb"
+	"github.com/tricorder/src/api-server/utils/channel"
 	common "github.com/tricorder/src/pb/module/common"
 	"github.com/tricorder/src/pb/module/ebpf"
 	"github.com/tricorder/src/pb/module/wasm"
 	testutils "github.com/tricorder/src/testing/bazel"
 	grafanatest "github.com/tricorder/src/testing/grafana"
 	pgclienttest "github.com/tricorder/src/testing/pg"
-	"github.com/tricorder/src/utils/cond"
 	"github.com/tricorder/src/utils/lock"
 	"github.com/tricorder/src/utils/uuid"
 )
@@ -119,12 +119,12 @@ func TestListAgent(t *testing.T) {
 	defer func() { assert.Nil(pgClientCleanerFn()) }()
 
 	gLock := lock.NewLock()
-	waitCond := cond.NewCond()
+	dispatcher := channel.NewDispatcher()
 
 	wasiSDK, wasiStarshipIncludePath, wasiBuildTmpPath, err := initWasiSDK()
 	require.Nil(err)
 
-	fakeServer := fake.StartFakeNewServer(sqliteClient, gLock, waitCond, pgClient,
+	fakeServer := fake.StartFakeNewServer(sqliteClient, gLock, dispatcher, pgClient,
 		grafanaURL, wasiSDK, wasiStarshipIncludePath, wasiBuildTmpPath)
 
 	// test list agent
@@ -171,12 +171,12 @@ func TestCreateModule(t *testing.T) {
 	defer func() { assert.Nil(pgClientCleanerFn()) }()
 
 	gLock := lock.NewLock()
-	waitCond := cond.NewCond()
+	dispatcher := channel.NewDispatcher()
 
 	wasiSDK, wasiStarshipIncludePath, wasiBuildTmpPath, err := initWasiSDK()
 	require.Nil(err)
 
-	fakeServer := fake.StartFakeNewServer(sqliteClient, gLock, waitCond, pgClient,
+	fakeServer := fake.StartFakeNewServer(sqliteClient, gLock, dispatcher, pgClient,
 		grafanaURL, wasiSDK, wasiStarshipIncludePath, wasiBuildTmpPath)
 
 	moduleDao := dao.ModuleDao{
@@ -222,13 +222,14 @@ func TestCreateModule(t *testing.T) {
 
 	res, err := client.CreateModule(moduleReq)
 	require.NoError(err)
-	assert.Equal(200, res.Code)
+	assert.Equal(201, res.Code)
 
 	// after create module, list module
 	module, err = moduleDao.ListModule([]string{})
 	require.NoError(err)
 	assert.Equal(1, len(module))
 	assert.Equal("test_module", module[0].Name)
+	assert.Equal(module[0].ID, res.ID)
 
 	err = moduleDao.DeleteByID(module[0].ID)
 	assert.NoError(err)
@@ -266,7 +267,7 @@ func TestCreateModule(t *testing.T) {
 
 	res, err = client.CreateModule(moduleReq)
 	require.NoError(err)
-	assert.Equal(200, res.Code)
+	assert.Equal(201, res.Code)
 
 	// after create module, list module
 	module, err = moduleDao.ListModule([]string{})
@@ -299,12 +300,12 @@ func TestListModule(t *testing.T) {
 	defer func() { assert.Nil(pgClientCleanerFn()) }()
 
 	gLock := lock.NewLock()
-	waitCond := cond.NewCond()
+	dispatcher := channel.NewDispatcher()
 
 	wasiSDK, wasiStarshipIncludePath, wasiBuildTmpPath, err := initWasiSDK()
 	require.Nil(err)
 
-	fakeServer := fake.StartFakeNewServer(sqliteClient, gLock, waitCond,
+	fakeServer := fake.StartFakeNewServer(sqliteClient, gLock, dispatcher,
 		pgClient, grafanaURL, wasiSDK, wasiStarshipIncludePath, wasiBuildTmpPath)
 
 	moduleDao := dao.ModuleDao{
@@ -356,12 +357,12 @@ func TestDeleteModule(t *testing.T) {
 	defer func() { assert.Nil(pgClientCleanerFn()) }()
 
 	gLock := lock.NewLock()
-	waitCond := cond.NewCond()
+	dispatcher := channel.NewDispatcher()
 
 	wasiSDK, wasiStarshipIncludePath, wasiBuildTmpPath, err := initWasiSDK()
 	require.Nil(err)
 
-	fakeServer := fake.StartFakeNewServer(sqliteClient, gLock, waitCond, pgClient,
+	fakeServer := fake.StartFakeNewServer(sqliteClient, gLock, dispatcher, pgClient,
 		grafanaURL, wasiSDK, wasiStarshipIncludePath, wasiBuildTmpPath)
 
 	moduleDao := dao.ModuleDao{
@@ -388,8 +389,7 @@ func TestDeleteModule(t *testing.T) {
 	// test list module
 	res, err := client.DeleteModule(id)
 	require.NoError(err)
-	assert.Equal(200, res.Code)
-	assert.Contains(res.Message, "Success")
+	assert.Equal(204, res.Code)
 
 	// after delete module, list module
 	moduleRes, err := moduleDao.ListModule([]string{})
@@ -416,12 +416,12 @@ func TestDeployModule(t *testing.T) {
 	defer func() { assert.Nil(pgClientCleanerFn()) }()
 
 	gLock := lock.NewLock()
-	waitCond := cond.NewCond()
+	dispatcher := channel.NewDispatcher()
 
 	wasiSDK, wasiStarshipIncludePath, wasiBuildTmpPath, err := initWasiSDK()
 	require.Nil(err)
 
-	fakeServer := fake.StartFakeNewServer(sqliteClient, gLock, waitCond, pgClient,
+	fakeServer := fake.StartFakeNewServer(sqliteClient, gLock, dispatcher, pgClient,
 		grafanaURL, wasiSDK, wasiStarshipIncludePath, wasiBuildTmpPath)
 
 	moduleDao := dao.ModuleDao{
@@ -461,10 +461,9 @@ func TestDeployModule(t *testing.T) {
 	client := NewClient("http://" + fakeServer.String())
 
 	// test deploy module
-	res, err := client.DeployModule(moduleID)
+	res, err := client.DeployModule(moduleID, false)
 	require.NoError(err)
-	assert.Equal(200, res.Code)
-	assert.Contains(res.Message, "prepare to deploy module")
+	assert.Equal(202, res.Code)
 
 	// check module state
 	moduleRes, err = moduleDao.ListModule([]string{})
@@ -493,12 +492,12 @@ func TestUndeployModule(t *testing.T) {
 	defer func() { assert.Nil(pgClientCleanerFn()) }()
 
 	gLock := lock.NewLock()
-	waitCond := cond.NewCond()
+	dispatcher := channel.NewDispatcher()
 
 	wasiSDK, wasiStarshipIncludePath, wasiBuildTmpPath, err := initWasiSDK()
 	require.Nil(err)
 
-	fakeServer := fake.StartFakeNewServer(sqliteClient, gLock, waitCond, pgClient,
+	fakeServer := fake.StartFakeNewServer(sqliteClient, gLock, dispatcher, pgClient,
 		grafanaURL, wasiSDK, wasiStarshipIncludePath, wasiBuildTmpPath)
 
 	moduleDao := dao.ModuleDao{
@@ -540,8 +539,7 @@ func TestUndeployModule(t *testing.T) {
 	// test deploy module
 	res, err := client.UndeployModule(moduleID)
 	require.NoError(err)
-	assert.Equal(200, res.Code)
-	assert.Contains(res.Message, "success")
+	assert.Equal(202, res.Code)
 
 	// check module state
 	moduleRes, err = moduleDao.ListModule([]string{})
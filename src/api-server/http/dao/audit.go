@@ -0,0 +1,135 @@
+// Copyright (C) 2023  Tricorder Observability
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package dao
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/tricorder/src/utils/sqlite"
+)
+
+// The actions recorded in the audit log.
+const (
+	AuditModuleCreate        = "module.create"
+	AuditModuleDelete        = "module.delete"
+	AuditModuleDeploy        = "module.deploy"
+	AuditModuleUndeploy      = "module.undeploy"
+	AuditModuleCreateVersion = "module.create_version"
+	AuditModuleUpgrade       = "module.upgrade"
+	AuditModuleHypertable    = "module.update_hypertable"
+	AuditModuleUpdate        = "module.update"
+	AuditAgentOnline         = "agent.online"
+	AuditAgentOffline        = "agent.offline"
+	AuditAgentTerminated     = "agent.terminated"
+	AuditModuleInstanceState = "module_instance.state"
+)
+
+// The types of the objects recorded in the audit log, see AuditTarget().
+const (
+	AuditTargetModule         = "module"
+	AuditTargetAgent          = "agent"
+	AuditTargetModuleInstance = "module_instance"
+)
+
+// The actors of the operations not performed by an authenticated principal.
+const (
+	// The HTTP API's requests when authentication is disabled.
+	AuditActorAnonymous = "anonymous"
+	// The state transitions made by API Server itself.
+	AuditActorAPIServer = "api-server"
+)
+
+// AuditTarget returns the Target of the audit records of the object of the type, like AuditTargetModule, and ID.
+func AuditTarget(kind, id string) string {
+	return kind + ":" + id
+}
+
+// AuditAgentActor returns the Actor of the audit records of the state transitions reported by the agent.
+func AuditAgentActor(agentID string) string {
+	return AuditTarget(AuditTargetAgent, agentID)
+}
+
+// AuditGORM records one management operation, or one state transition of an agent or a module instance.
+// Audit records are only ever appended, never updated or deleted.
+type AuditGORM struct {
+	// tag schema https://gorm.io/docs/models.html#Fields-Tags
+	ID int64 `gorm:"column:id;primaryKey;autoIncrement" json:"id,omitempty"`
+	// The time when the operation happened.
+	Time string `gorm:"column:time" json:"time,omitempty"`
+	// Who performed the operation: the name of the authenticated principal, "anonymous" if authentication is
+	// disabled, or "agent:<agent ID>" for the state transitions reported by agents.
+	Actor string `gorm:"column:actor" json:"actor,omitempty"`
+	// The performed operation, for example "module.create" or "agent.offline".
+	Action string `gorm:"column:action" json:"action,omitempty"`
+	// The type and ID of the object being operated on, for example "module:<ID>".
+	Target string `gorm:"column:target" json:"target,omitempty"`
+	// The state of the target before and after the operation.
+	Before string `gorm:"column:before_state" json:"before,omitempty"`
+	After  string `gorm:"column:after_state" json:"after,omitempty"`
+	// The error message if the operation failed, empty if it succeeded.
+	Error string `gorm:"column:error" json:"error,omitempty"`
+}
+
+func (AuditGORM) TableName() string {
+	return "audit"
+}
+
+// AuditFilter selects audit records, empty fields match everything.
+type AuditFilter struct {
+	Actor  string
+	Action string
+	Target string
+}
+
+// AuditDao appends and queries audit records. It deliberately has no methods to update or delete records.
+type AuditDao struct {
+	Client *sqlite.ORM
+}
+
+// Append appends the record, setting its ID and Time.
+func (g *AuditDao) Append(record *AuditGORM) error {
+	record.ID = 0
+	record.Time = time.Now().Format("2006-01-02 15:04:05")
+	result := g.Client.Engine.Create(record)
+	return result.Error
+}
+
+// ListPage returns at most limit records matching the filter, skipping the first offset ones, the latest first.
+// It also returns the total number of records matching the filter.
+func (g *AuditDao) ListPage(filter AuditFilter, limit, offset int) ([]AuditGORM, int64, error) {
+	tx := g.Client.Engine.Model(&AuditGORM{})
+	if len(filter.Actor) > 0 {
+		tx = tx.Where("actor = ?", filter.Actor)
+	}
+	if len(filter.Action) > 0 {
+		tx = tx.Where("action = ?", filter.Action)
+	}
+	if len(filter.Target) > 0 {
+		tx = tx.Where("target = ?", filter.Target)
+	}
+	var total int64
+	result := tx.Count(&total)
+	if result.Error != nil {
+		return nil, 0, fmt.Errorf("count audit records error:%v", result.Error)
+	}
+	records := make([]AuditGORM, 0)
+	result = tx.Order("id desc").Limit(limit).Offset(offset).Find(&records)
+	if result.Error != nil {
+		return nil, 0, fmt.Errorf("query audit record page error:%v", result.Error)
+	}
+	return records, total, nil
+}
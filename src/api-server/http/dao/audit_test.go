@@ -0,0 +1,76 @@
+// Copyright (C) 2023  Tricorder Observability
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package dao
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	bazelutils "github.com/tricorder/src/testing/bazel"
+)
+
+// Tests that audit records can be appended, and listed with filters and pagination, the latest first.
+func TestAudit(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	dirPath := bazelutils.CreateTmpDir()
+	defer func() {
+		assert.Nil(os.RemoveAll(dirPath))
+	}()
+
+	sqliteClient, err := InitSqlite(dirPath)
+	require.NoError(err)
+
+	auditDao := AuditDao{
+		Client: sqliteClient,
+	}
+
+	records := []AuditGORM{
+		{Actor: "alice", Action: "module.create", Target: "module:m1", After: "CREATED"},
+		{Actor: "alice", Action: "module.deploy", Target: "module:m1", Before: "CREATED", After: "DEPLOYED"},
+		{Actor: "agent:a1", Action: "agent.online", Target: "agent:a1", After: "ONLINE"},
+		{Actor: "bob", Action: "module.delete", Target: "module:m2", Error: "not found"},
+	}
+	for i := range records {
+		require.NoError(auditDao.Append(&records[i]))
+		assert.NotZero(records[i].ID)
+		assert.NotEmpty(records[i].Time)
+	}
+
+	all, total, err := auditDao.ListPage(AuditFilter{}, 10, 0)
+	require.NoError(err)
+	assert.Equal(int64(4), total)
+	require.Len(all, 4)
+	assert.Equal("module.delete", all[0].Action)
+	assert.Equal("module.create", all[3].Action)
+
+	page, total, err := auditDao.ListPage(AuditFilter{Actor: "alice"}, 1, 1)
+	require.NoError(err)
+	assert.Equal(int64(2), total)
+	require.Len(page, 1)
+	assert.Equal("module.create", page[0].Action)
+
+	page, total, err = auditDao.ListPage(AuditFilter{Target: "module:m1", Action: "module.deploy"}, 10, 0)
+	require.NoError(err)
+	assert.Equal(int64(1), total)
+	require.Len(page, 1)
+	assert.Equal("CREATED", page[0].Before)
+	assert.Equal("DEPLOYED", page[0].After)
+}
@@ -10,6 +10,10 @@ type Dao struct {
 	NodeAgent NodeAgentDao
 	// Stores the module instances should be deployed on each and every agent.
 	ModuleInstance ModuleInstanceDao
+	// Stores the versions of the eBPF+WASM modules.
+	ModuleVersion ModuleVersionDao
+	// Stores the append-only audit log of management operations and state transitions.
+	Audit AuditDao
 }
 
 // NewDao returns the Dao object for accessing the data.
@@ -18,5 +22,7 @@ func NewDao(sqliteClient *sqlite.ORM) Dao {
 		Module:         ModuleDao{Client: sqliteClient},
 		NodeAgent:      NodeAgentDao{Client: sqliteClient},
 		ModuleInstance: ModuleInstanceDao{Client: sqliteClient},
+		ModuleVersion:  ModuleVersionDao{Client: sqliteClient},
+		Audit:          AuditDao{Client: sqliteClient},
 	}
 }
@@ -41,6 +41,20 @@ type ModuleGORM struct {
 	Fn         string `gorm:"column:fn" json:"fn,omitempty"`
 	WasmFmt    int    `gorm:"column:wasm_fmt" json:"wasm_fmt,omitempty"`
 	WasmLang   int    `gorm:"column:wasm_lang" json:"wasm_lang,omitempty"`
+	// The version of the code above, the versions are stored in the module_version table.
+	Version int `gorm:"column:version" json:"version,omitempty"`
+	// The JSON of the data table's hypertable spec, empty if the data table is a plain table.
+	Hypertable string `gorm:"column:hypertable" json:"hypertable,omitempty"`
+	// The JSON of the data table's primary key columns and secondary indexes, empty if none.
+	PrimaryKey string `gorm:"column:primary_key" json:"primary_key,omitempty"`
+	Indexes    string `gorm:"column:indexes" json:"indexes,omitempty"`
+	// The JSON of the sink of the output, empty if the output is written to the data table in Postgres.
+	Sink string `gorm:"column:sink" json:"sink,omitempty"`
+	// The signature of the code above, empty if the module is not signed.
+	SignatureKeyID string `gorm:"column:signature_key_id" json:"signature_key_id,omitempty"`
+	Signature      []byte `gorm:"column:signature" json:"signature,omitempty"`
+	// The tenant owning the module, empty if the module is not owned by a tenant. The name is unique in the tenant.
+	Tenant string `gorm:"column:tenant" json:"tenant,omitempty"`
 }
 
 func (ModuleGORM) TableName() string {
@@ -68,6 +82,12 @@ func (g *ModuleDao) UpdateStatusByID(id string, status int) error {
 	return result.Error
 }
 
+// UpdateHypertableByID updates the hypertable spec of the module.
+func (g *ModuleDao) UpdateHypertableByID(id string, hypertable string) error {
+	result := g.Client.Engine.Model(&ModuleGORM{ID: id}).Select("hypertable").Updates(ModuleGORM{Hypertable: hypertable})
+	return result.Error
+}
+
 func (g *ModuleDao) DeleteByID(id string) error {
 	result := g.Client.Engine.Delete(&ModuleGORM{ID: id})
 	return result.Error
@@ -85,6 +105,56 @@ func (g *ModuleDao) ListModule(fields []string) ([]ModuleGORM, error) {
 	return moduleList, nil
 }
 
+// ListModuleOfTenant returns the modules of the tenant, like ListModule().
+func (g *ModuleDao) ListModuleOfTenant(fields []string, tenant string) ([]ModuleGORM, error) {
+	moduleList := make([]ModuleGORM, 0)
+	result := g.Client.Engine.
+		Select(fields).Where("name is not null and name != '' ").Where("coalesce(tenant, '') = ?", tenant).
+		Order("create_time desc").
+		Find(&moduleList)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return moduleList, nil
+}
+
+// ModuleFilter selects the modules returned by ListModulePage(), the zero value selects all modules.
+type ModuleFilter struct {
+	// Only the module with this name is returned if not empty.
+	Name string
+	// Only the modules in this desired state are returned if not nil.
+	DesireState *int
+	// Only the modules of this tenant are returned if not nil, the empty tenant selects the modules without tenant.
+	Tenant *string
+}
+
+// ListModulePage returns at most limit modules matching filter, skipping the first offset ones, the latest created
+// module first. It also returns the total number of modules matching filter.
+func (g *ModuleDao) ListModulePage(fields []string, filter ModuleFilter, limit, offset int,
+) ([]ModuleGORM, int64, error) {
+	query := g.Client.Engine.Model(&ModuleGORM{}).Where("name is not null and name != '' ")
+	if len(filter.Name) > 0 {
+		query = query.Where("name = ?", filter.Name)
+	}
+	if filter.DesireState != nil {
+		query = query.Where("desire_state = ?", *filter.DesireState)
+	}
+	if filter.Tenant != nil {
+		query = query.Where("coalesce(tenant, '') = ?", *filter.Tenant)
+	}
+	var total int64
+	result := query.Count(&total)
+	if result.Error != nil {
+		return nil, 0, result.Error
+	}
+	moduleList := make([]ModuleGORM, 0)
+	result = query.Select(fields).Order("create_time desc").Limit(limit).Offset(offset).Find(&moduleList)
+	if result.Error != nil {
+		return nil, 0, result.Error
+	}
+	return moduleList, total, nil
+}
+
 func (g *ModuleDao) ListModuleByStatus(status int) ([]ModuleGORM, error) {
 	moduleList := make([]ModuleGORM, 0)
 	result := g.Client.Engine.Where(&ModuleGORM{DesireState: status}).Order("create_time desc").Find(&moduleList)
@@ -108,6 +178,16 @@ func (g *ModuleDao) QueryByName(name string) (*ModuleGORM, error) {
 	return g.queryAtMostOneRecord(&ModuleGORM{Name: name})
 }
 
+// QueryByTenantAndName returns the module with the name in the tenant, or nil if there is none.
+func (g *ModuleDao) QueryByTenantAndName(tenant, name string) (*ModuleGORM, error) {
+	module := &ModuleGORM{}
+	result := g.Client.Engine.Where("coalesce(tenant, '') = ? AND name = ?", tenant, name).Limit(1).Find(module)
+	if result.RowsAffected == 0 {
+		return nil, result.Error
+	}
+	return module, result.Error
+}
+
 func (g *ModuleDao) QueryByID(id string) (*ModuleGORM, error) {
 	return g.queryAtMostOneRecord(&ModuleGORM{ID: id})
 }
@@ -63,6 +63,8 @@ type ModuleInstanceGORM struct {
 	AgentID        string     `gorm:"column:agent_id" json:"agent_id,omitempty"`
 	State          int        `gorm:"column:state" json:"state,omitempty"`
 	DesireState    int        `gorm:"column:desire_state" json:"desire_state,omitempty"`
+	StateDesc      string     `gorm:"column:state_desc" json:"state_desc,omitempty"` // desc of the agent's last DeployModuleResp
+	Version        int        `gorm:"column:version" json:"version,omitempty"`       // version of the module to deploy
 	CreateTime     *time.Time `gorm:"column:create_time" json:"create_time,omitempty"`
 	LastUpdateTime *time.Time `gorm:"column:last_update_time" json:"last_update_time,omitempty"`
 }
@@ -110,6 +112,37 @@ func (g *ModuleInstanceDao) UpdateStatusByID(ID string, state int) error {
 	return result.Error
 }
 
+// UpdateStatusAndDescByID updates the state and its description reported by the agent.
+func (g *ModuleInstanceDao) UpdateStatusAndDescByID(ID string, state int, desc string) error {
+	module := ModuleInstanceGORM{}
+
+	module.LastUpdateTime = &time.Time{}
+	*module.LastUpdateTime = time.Now()
+	module.State = state
+	module.StateDesc = desc
+
+	// use Select() to avoid update other fields and force update state 0 and empty desc fields
+	result := g.Client.Engine.Model(&ModuleInstanceGORM{}).Where("id", ID).
+		Select("last_update_time", "state", "state_desc").Updates(module)
+	return result.Error
+}
+
+// UpdateVersionByID updates the version of the module instance, and resets its state to INIT,
+// so that the instance is deployed again with the new version.
+func (g *ModuleInstanceDao) UpdateVersionByID(ID string, version int) error {
+	module := ModuleInstanceGORM{}
+
+	module.LastUpdateTime = &time.Time{}
+	*module.LastUpdateTime = time.Now()
+	module.Version = version
+	module.State = int(pb.ModuleInstanceState_INIT)
+	module.StateDesc = ""
+
+	result := g.Client.Engine.Model(&ModuleInstanceGORM{}).Where("id", ID).
+		Select("last_update_time", "version", "state", "state_desc").Updates(module)
+	return result.Error
+}
+
 func (g *ModuleInstanceDao) UpdateDesireStateByID(ID string, desireState int) error {
 	module := ModuleInstanceGORM{}
 
@@ -133,7 +166,7 @@ func (g *ModuleInstanceDao) List(query ...string) ([]ModuleInstanceGORM, error)
 	if len(query) == 0 {
 		query = []string{
 			"id", "module_id", "module_name", "node_name", "agent_id", "state",
-			"desire_state", "create_time", "last_update_time",
+			"desire_state", "state_desc", "version", "create_time", "last_update_time",
 		}
 	}
 	result := g.Client.Engine.
@@ -185,6 +185,30 @@ func TestModuleInstance(t *testing.T) {
 	assert.Nil(err)
 	assert.Equal(moduleRes.ID, moduleInstance.ID)
 	assert.Equal(moduleRes.NodeName, moduleInstance.NodeName)
+
+	// test update module status with the agent's description
+	err = ModuleInstanceDao.UpdateStatusAndDescByID(moduleInstance.ID, int(pb.ModuleInstanceState_FAILED), "attach failed")
+	assert.Nil(err)
+	moduleRes, err = ModuleInstanceDao.QueryByID(moduleInstance.ID)
+	assert.Nil(err)
+	assert.Equal(int(pb.ModuleInstanceState_FAILED), moduleRes.State)
+	assert.Equal("attach failed", moduleRes.StateDesc)
+
+	// the description is cleared when the agent reports no description
+	err = ModuleInstanceDao.UpdateStatusAndDescByID(moduleInstance.ID, int(pb.ModuleInstanceState_SUCCEEDED), "")
+	assert.Nil(err)
+	moduleRes, err = ModuleInstanceDao.QueryByID(moduleInstance.ID)
+	assert.Nil(err)
+	assert.Equal(int(pb.ModuleInstanceState_SUCCEEDED), moduleRes.State)
+	assert.Equal("", moduleRes.StateDesc)
+
+	// test update version resets the state, so that the instance is deployed again
+	err = ModuleInstanceDao.UpdateVersionByID(moduleInstance.ID, 2)
+	assert.Nil(err)
+	moduleRes, err = ModuleInstanceDao.QueryByID(moduleInstance.ID)
+	assert.Nil(err)
+	assert.Equal(2, moduleRes.Version)
+	assert.Equal(int(pb.ModuleInstanceState_INIT), moduleRes.State)
 }
 
 // Tests that CheckModuleDesiredState returns expected values.
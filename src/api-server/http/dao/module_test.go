@@ -16,12 +16,14 @@
 package dao
 
 import (
+	"fmt"
 	"os"
 	"strings"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
 	pb "github.com/tricorder/src/api-server/pb"
 	bazelutils "github.com/tricorder/src/testing/bazel"
@@ -109,6 +111,12 @@ func TestModule(t *testing.T) {
 	assert.Equal(module.DesireState, int(pb.ModuleState_DEPLOYED),
 		"change module status error: not change module status")
 
+	hypertable := `{"time_column":"_ingest_time","retention":"7 days"}`
+	assert.Nil(moduleDao.UpdateHypertableByID(module.ID, hypertable))
+	module, err = moduleDao.QueryByID(module.ID)
+	assert.Nil(err)
+	assert.Equal(hypertable, module.Hypertable)
+
 	// get module list *
 	list, err := moduleDao.ListModule([]string{"*"})
 	assert.Nil(err, "query module list error: %v", err)
@@ -129,3 +137,100 @@ func TestModule(t *testing.T) {
 	assert.NotEqual(len(list[0].Name), 0, "query module list erro default: Name is empty")
 	assert.Equal(len(list[0].Wasm), 0, "query module list erro default: Wasm is not empty")
 }
+
+// Tests that ListModulePage() filters and paginates the modules.
+func TestListModulePage(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	dirPath := bazelutils.CreateTmpDir()
+	defer func() {
+		assert.Nil(os.RemoveAll(dirPath))
+	}()
+
+	sqliteClient, err := InitSqlite(dirPath)
+	require.Nil(err)
+	moduleDao := ModuleDao{Client: sqliteClient}
+
+	for i, state := range []pb.ModuleState{pb.ModuleState_CREATED_, pb.ModuleState_DEPLOYED, pb.ModuleState_DEPLOYED} {
+		require.Nil(moduleDao.SaveModule(&ModuleGORM{
+			ID:          uuid.NewWithUnderscoreSeparator(),
+			Name:        fmt.Sprintf("module%d", i),
+			DesireState: int(state),
+			CreateTime:  fmt.Sprintf("2023-01-0%d 00:00:00", i+1),
+		}))
+	}
+
+	fields := []string{"id", "name", "desire_state"}
+	list, total, err := moduleDao.ListModulePage(fields, ModuleFilter{}, 2, 0)
+	require.Nil(err)
+	assert.Equal(int64(3), total)
+	require.Len(list, 2)
+	assert.Equal("module2", list[0].Name)
+	assert.Equal("module1", list[1].Name)
+
+	list, total, err = moduleDao.ListModulePage(fields, ModuleFilter{}, 2, 2)
+	require.Nil(err)
+	assert.Equal(int64(3), total)
+	require.Len(list, 1)
+	assert.Equal("module0", list[0].Name)
+
+	created := int(pb.ModuleState_CREATED_)
+	list, total, err = moduleDao.ListModulePage(fields, ModuleFilter{DesireState: &created}, 10, 0)
+	require.Nil(err)
+	assert.Equal(int64(1), total)
+	require.Len(list, 1)
+	assert.Equal("module0", list[0].Name)
+
+	list, total, err = moduleDao.ListModulePage(fields, ModuleFilter{Name: "module1"}, 10, 0)
+	require.Nil(err)
+	assert.Equal(int64(1), total)
+	require.Len(list, 1)
+	assert.Equal("module1", list[0].Name)
+}
+
+// Tests that the modules with the same name in different tenants are queried and listed by their tenants.
+func TestModuleTenant(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	dirPath := bazelutils.CreateTmpDir()
+	defer func() {
+		assert.Nil(os.RemoveAll(dirPath))
+	}()
+
+	sqliteClient, err := InitSqlite(dirPath)
+	require.Nil(err)
+	moduleDao := ModuleDao{Client: sqliteClient}
+
+	for i, tenant := range []string{"", "team_a", "team_b"} {
+		require.Nil(moduleDao.SaveModule(&ModuleGORM{
+			ID:         uuid.NewWithUnderscoreSeparator(),
+			Name:       "module",
+			Tenant:     tenant,
+			CreateTime: fmt.Sprintf("2023-01-0%d 00:00:00", i+1),
+		}))
+	}
+
+	for _, tenant := range []string{"", "team_a", "team_b"} {
+		m, err := moduleDao.QueryByTenantAndName(tenant, "module")
+		require.Nil(err)
+		require.NotNil(m)
+		assert.Equal(tenant, m.Tenant)
+	}
+	m, err := moduleDao.QueryByTenantAndName("team_c", "module")
+	require.Nil(err)
+	assert.Nil(m)
+
+	list, err := moduleDao.ListModuleOfTenant([]string{"id", "name", "tenant"}, "team_a")
+	require.Nil(err)
+	require.Len(list, 1)
+	assert.Equal("team_a", list[0].Tenant)
+
+	noTenant := ""
+	list, total, err := moduleDao.ListModulePage(nil, ModuleFilter{Tenant: &noTenant}, 10, 0)
+	require.Nil(err)
+	assert.Equal(int64(1), total)
+	require.Len(list, 1)
+	assert.Empty(list[0].Tenant)
+}
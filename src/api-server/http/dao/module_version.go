@@ -0,0 +1,159 @@
+// Copyright (C) 2023  Tricorder Observability
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package dao
+
+import (
+	"fmt"
+	"time"
+
+	"gorm.io/gorm/clause"
+
+	"github.com/tricorder/src/utils/sqlite"
+)
+
+// ModuleVersionGORM stores the code of one version of a module.
+// The module's name, ID, data table and dashboard are shared by all of its versions.
+type ModuleVersionGORM struct {
+	// tag schema https://gorm.io/docs/models.html#Fields-Tags
+	ID                 string `gorm:"column:id;primaryKey" json:"id,omitempty"`
+	ModuleID           string `gorm:"column:module_id" json:"module_id,omitempty"`
+	Version            int    `gorm:"column:version" json:"version,omitempty"`
+	CreateTime         string `gorm:"column:create_time" json:"create_time,omitempty"`
+	Ebpf               string `gorm:"column:ebpf" json:"ebpf,omitempty"`
+	EbpfFmt            int    `gorm:"column:ebpf_fmt" json:"ebpf_fmt,omitempty"`
+	EbpfLang           int    `gorm:"column:ebpf_lang" json:"ebpf_lang,omitempty"`
+	EbpfPerfBufferName string `gorm:"column:ebpf_perf_name" json:"ebpf_perf_name,omitempty"`
+	EbpfProbes         string `gorm:"column:ebpf_probes" json:"ebpf_probes,omitempty"`
+	WasmCode           string `gorm:"column:wasm_code" json:"wasm_code,omitempty"`
+	Wasm               []byte `gorm:"column:wasm" json:"wasm,omitempty"`
+	SchemaAttr         string `gorm:"column:schema_attr" json:"schema_attr,omitempty"`
+	Fn                 string `gorm:"column:fn" json:"fn,omitempty"`
+	WasmFmt            int    `gorm:"column:wasm_fmt" json:"wasm_fmt,omitempty"`
+	WasmLang           int    `gorm:"column:wasm_lang" json:"wasm_lang,omitempty"`
+	PrimaryKey         string `gorm:"column:primary_key" json:"primary_key,omitempty"`
+	Indexes            string `gorm:"column:indexes" json:"indexes,omitempty"`
+	Sink               string `gorm:"column:sink" json:"sink,omitempty"`
+	SignatureKeyID     string `gorm:"column:signature_key_id" json:"signature_key_id,omitempty"`
+	Signature          []byte `gorm:"column:signature" json:"signature,omitempty"`
+}
+
+func (ModuleVersionGORM) TableName() string {
+	return "module_version"
+}
+
+// ModuleVersionID returns the ID of the specified version of the module.
+func ModuleVersionID(moduleID string, version int) string {
+	return fmt.Sprintf("%s_v%d", moduleID, version)
+}
+
+// NewModuleVersion returns a snapshot of the code of the input module, as its current version.
+func NewModuleVersion(mod *ModuleGORM) *ModuleVersionGORM {
+	return &ModuleVersionGORM{
+		ID:                 ModuleVersionID(mod.ID, mod.Version),
+		ModuleID:           mod.ID,
+		Version:            mod.Version,
+		CreateTime:         time.Now().Format("2006-01-02 15:04:05"),
+		Ebpf:               mod.Ebpf,
+		EbpfFmt:            mod.EbpfFmt,
+		EbpfLang:           mod.EbpfLang,
+		EbpfPerfBufferName: mod.EbpfPerfBufferName,
+		EbpfProbes:         mod.EbpfProbes,
+		WasmCode:           mod.WasmCode,
+		Wasm:               mod.Wasm,
+		SchemaAttr:         mod.SchemaAttr,
+		Fn:                 mod.Fn,
+		WasmFmt:            mod.WasmFmt,
+		WasmLang:           mod.WasmLang,
+		PrimaryKey:         mod.PrimaryKey,
+		Indexes:            mod.Indexes,
+		Sink:               mod.Sink,
+		SignatureKeyID:     mod.SignatureKeyID,
+		Signature:          mod.Signature,
+	}
+}
+
+// ApplyTo overwrites the code of the input module with this version.
+func (v *ModuleVersionGORM) ApplyTo(mod *ModuleGORM) {
+	mod.Version = v.Version
+	mod.Ebpf = v.Ebpf
+	mod.EbpfFmt = v.EbpfFmt
+	mod.EbpfLang = v.EbpfLang
+	mod.EbpfPerfBufferName = v.EbpfPerfBufferName
+	mod.EbpfProbes = v.EbpfProbes
+	mod.WasmCode = v.WasmCode
+	mod.Wasm = v.Wasm
+	mod.SchemaAttr = v.SchemaAttr
+	mod.Fn = v.Fn
+	mod.WasmFmt = v.WasmFmt
+	mod.WasmLang = v.WasmLang
+	mod.PrimaryKey = v.PrimaryKey
+	mod.Indexes = v.Indexes
+	mod.Sink = v.Sink
+	mod.SignatureKeyID = v.SignatureKeyID
+	mod.Signature = v.Signature
+}
+
+type ModuleVersionDao struct {
+	Client *sqlite.ORM
+}
+
+func (g *ModuleVersionDao) SaveModuleVersion(version *ModuleVersionGORM) error {
+	result := g.Client.Engine.Clauses(clause.OnConflict{
+		UpdateAll: true,
+	}).Create(version)
+	return result.Error
+}
+
+// QueryByModuleIDAndVersion returns nil if the version does not exist.
+func (g *ModuleVersionDao) QueryByModuleIDAndVersion(moduleID string, version int) (*ModuleVersionGORM, error) {
+	moduleVersion := &ModuleVersionGORM{}
+	result := g.Client.Engine.Where(&ModuleVersionGORM{ID: ModuleVersionID(moduleID, version)}).Find(moduleVersion)
+	if result.RowsAffected == 0 {
+		return nil, result.Error
+	}
+	return moduleVersion, result.Error
+}
+
+// ListByModuleID returns all versions of the module, with the latest version first.
+func (g *ModuleVersionDao) ListByModuleID(moduleID string, fields ...string) ([]ModuleVersionGORM, error) {
+	versionList := make([]ModuleVersionGORM, 0)
+	if len(fields) == 0 {
+		fields = []string{"id", "module_id", "version", "create_time", "schema_attr", "fn"}
+	}
+	result := g.Client.Engine.Select(fields).Where(&ModuleVersionGORM{ModuleID: moduleID}).
+		Order("version desc").Find(&versionList)
+	if result.Error != nil {
+		return nil, fmt.Errorf("query module version list by moduleID error:%v", result.Error)
+	}
+	return versionList, nil
+}
+
+// LatestVersion returns the largest version number of the module, or 0 if the module has no versions.
+func (g *ModuleVersionDao) LatestVersion(moduleID string) (int, error) {
+	versions, err := g.ListByModuleID(moduleID, "version")
+	if err != nil {
+		return 0, err
+	}
+	if len(versions) == 0 {
+		return 0, nil
+	}
+	return versions[0].Version, nil
+}
+
+func (g *ModuleVersionDao) DeleteByModuleID(moduleID string) error {
+	result := g.Client.Engine.Where(&ModuleVersionGORM{ModuleID: moduleID}).Delete(&ModuleVersionGORM{})
+	return result.Error
+}
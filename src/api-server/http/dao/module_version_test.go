@@ -0,0 +1,98 @@
+// Copyright (C) 2023  Tricorder Observability
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package dao
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	bazelutils "github.com/tricorder/src/testing/bazel"
+	"github.com/tricorder/src/utils/uuid"
+)
+
+// Tests that the versions of a module can be saved, queried, listed, and deleted.
+func TestModuleVersion(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	dirPath := bazelutils.CreateTmpDir()
+	defer func() {
+		assert.Nil(os.RemoveAll(dirPath))
+	}()
+
+	sqliteClient, err := InitSqlite(dirPath)
+	require.NoError(err)
+
+	moduleVersionDao := ModuleVersionDao{
+		Client: sqliteClient,
+	}
+
+	moduleID := uuid.NewWithUnderscoreSeparator()
+	latest, err := moduleVersionDao.LatestVersion(moduleID)
+	require.NoError(err)
+	assert.Equal(0, latest)
+
+	module := &ModuleGORM{
+		ID:         moduleID,
+		Name:       "TestModule",
+		Ebpf:       "ebpf_v1",
+		SchemaAttr: `[{"name":"data","type":5}]`,
+		Fn:         "fn_v1",
+		Version:    1,
+	}
+	require.NoError(moduleVersionDao.SaveModuleVersion(NewModuleVersion(module)))
+
+	module.Ebpf = "ebpf_v2"
+	module.Fn = "fn_v2"
+	module.Version = 2
+	require.NoError(moduleVersionDao.SaveModuleVersion(NewModuleVersion(module)))
+
+	latest, err = moduleVersionDao.LatestVersion(moduleID)
+	require.NoError(err)
+	assert.Equal(2, latest)
+
+	versions, err := moduleVersionDao.ListByModuleID(moduleID)
+	require.NoError(err)
+	require.Equal(2, len(versions))
+	assert.Equal(2, versions[0].Version)
+	assert.Equal(1, versions[1].Version)
+	assert.Equal("fn_v1", versions[1].Fn)
+
+	version, err := moduleVersionDao.QueryByModuleIDAndVersion(moduleID, 1)
+	require.NoError(err)
+	require.NotNil(version)
+	assert.Equal("ebpf_v1", version.Ebpf)
+
+	// Applying version 1 restores the code of version 1 and keeps the module's identity.
+	version.ApplyTo(module)
+	assert.Equal(moduleID, module.ID)
+	assert.Equal("TestModule", module.Name)
+	assert.Equal(1, module.Version)
+	assert.Equal("ebpf_v1", module.Ebpf)
+	assert.Equal("fn_v1", module.Fn)
+
+	version, err = moduleVersionDao.QueryByModuleIDAndVersion(moduleID, 3)
+	require.NoError(err)
+	assert.Nil(version)
+
+	require.NoError(moduleVersionDao.DeleteByModuleID(moduleID))
+	versions, err = moduleVersionDao.ListByModuleID(moduleID)
+	require.NoError(err)
+	assert.Empty(versions)
+}
@@ -110,6 +110,30 @@ func (g *NodeAgentDao) List(query []string) ([]NodeAgentGORM, error) {
 	return nodeList, nil
 }
 
+// ListPage returns at most limit agents, skipping the first offset ones, the latest updated agent first.
+// Only the agents in the specified state are returned if state is not nil.
+// It also returns the total number of agents in the specified state.
+func (g *NodeAgentDao) ListPage(query []string, state *int, limit, offset int) ([]NodeAgentGORM, int64, error) {
+	if len(query) == 0 {
+		query = []string{"agent_id", "node_name", "agent_pod_id", "state", "create_time", "last_update_time"}
+	}
+	tx := g.Client.Engine.Model(&NodeAgentGORM{}).Where("node_name is not null and node_name != '' ")
+	if state != nil {
+		tx = tx.Where("state = ?", *state)
+	}
+	var total int64
+	result := tx.Count(&total)
+	if result.Error != nil {
+		return nil, 0, fmt.Errorf("count node agents error:%v", result.Error)
+	}
+	nodeList := make([]NodeAgentGORM, 0)
+	result = tx.Select(query).Order("last_update_time desc").Limit(limit).Offset(offset).Find(&nodeList)
+	if result.Error != nil {
+		return nil, 0, fmt.Errorf("query node agent page error:%v", result.Error)
+	}
+	return nodeList, total, nil
+}
+
 func (g *NodeAgentDao) ListByState(state int) ([]NodeAgentGORM, error) {
 	nodeList := make([]NodeAgentGORM, 0)
 	result := g.Client.Engine.Where(&NodeAgentGORM{State: state}).Order("create_time desc").Find(&nodeList)
@@ -137,6 +161,19 @@ func (g *NodeAgentDao) QueryByID(agentID string) (*NodeAgentGORM, error) {
 	return node, nil
 }
 
+// FindByID is like QueryByID(), but returns nil without error if the agent does not exist.
+func (g *NodeAgentDao) FindByID(agentID string) (*NodeAgentGORM, error) {
+	node := &NodeAgentGORM{}
+	result := g.Client.Engine.Where(&NodeAgentGORM{AgentID: agentID}).Find(node)
+	if result.Error != nil {
+		return nil, fmt.Errorf("query node agent by id error:%v", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return nil, nil
+	}
+	return node, nil
+}
+
 func (g *NodeAgentDao) QueryByPodID(agentPodID string) (*NodeAgentGORM, error) {
 	node := &NodeAgentGORM{}
 	result := g.Client.Engine.Where(&NodeAgentGORM{AgentPodID: agentPodID}).First(node)
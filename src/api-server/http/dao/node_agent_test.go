@@ -145,3 +145,49 @@ func TestNodeAgent(t *testing.T) {
 	assert.NotEqual(len(list[0].AgentID), 0, "query node ListByName error: AgentID is not empty")
 	assert.NotEqual(len(list[0].NodeName), 0, "query node ListByName error: NodeName is not empty")
 }
+
+// Tests that ListPage() filters the agents by state and paginates them, and FindByID() returns nil for unknown agents.
+func TestNodeAgentListPage(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	dirPath := bazelutils.CreateTmpDir()
+	defer func() {
+		assert.Nil(os.RemoveAll(dirPath))
+	}()
+
+	sqliteClient, err := InitSqlite(dirPath)
+	require.Nil(err)
+	nodeAgentDao := NodeAgentDao{Client: sqliteClient}
+
+	for _, id := range []string{"agent0", "agent1", "agent2"} {
+		require.Nil(nodeAgentDao.SaveAgent(&NodeAgentGORM{AgentID: id, NodeName: "node_" + id}))
+	}
+	require.Nil(nodeAgentDao.UpdateStateByID("agent1", int(pb.AgentState_OFFLINE)))
+
+	list, total, err := nodeAgentDao.ListPage(nil, nil, 2, 0)
+	require.Nil(err)
+	assert.Equal(int64(3), total)
+	assert.Len(list, 2)
+
+	list, total, err = nodeAgentDao.ListPage(nil, nil, 2, 2)
+	require.Nil(err)
+	assert.Equal(int64(3), total)
+	assert.Len(list, 1)
+
+	offline := int(pb.AgentState_OFFLINE)
+	list, total, err = nodeAgentDao.ListPage(nil, &offline, 10, 0)
+	require.Nil(err)
+	assert.Equal(int64(1), total)
+	require.Len(list, 1)
+	assert.Equal("agent1", list[0].AgentID)
+
+	agent, err := nodeAgentDao.FindByID("agent2")
+	require.Nil(err)
+	require.NotNil(agent)
+	assert.Equal("node_agent2", agent.NodeName)
+
+	agent, err = nodeAgentDao.FindByID("unknown")
+	require.Nil(err)
+	assert.Nil(agent)
+}
@@ -47,5 +47,13 @@ func InitSqlite(dbPath string) (*sqlite.ORM, error) {
 	if err != nil {
 		return nil, fmt.Errorf("create module instance table error %v", err)
 	}
+	err = engine.CreateTable(&ModuleVersionGORM{})
+	if err != nil {
+		return nil, fmt.Errorf("create module version table error %v", err)
+	}
+	err = engine.CreateTable(&AuditGORM{})
+	if err != nil {
+		return nil, fmt.Errorf("create audit table error %v", err)
+	}
 	return engine, nil
 }
@@ -15,6 +15,67 @@ const docTemplate = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
+        "/api/catalog": {
+            "get": {
+                "description": "List the modules bundled with API Server, with their descriptions, the kernel features their probes\nneed, and their parameters. See /api/catalog/{name}.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "catalog"
+                ],
+                "summary": "List catalog",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/http.ListCatalogResp"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/catalog/{name}": {
+            "post": {
+                "description": "Create a module from the catalog entry, whose BCC code defines the parameters with the values in the\nrequest, or their default values.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "catalog"
+                ],
+                "summary": "Create module from catalog",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "catalog entry name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "The name, parameters and tenant of the created module",
+                        "name": "module",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/http.CreateFromCatalogReq"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/http.CreateModuleResp"
+                        }
+                    }
+                }
+            }
+        },
         "/api/createModule": {
             "post": {
                 "description": "Store module data into SQLite database",
@@ -101,6 +162,12 @@ const docTemplate = `{
                         "name": "id",
                         "in": "query",
                         "required": true
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "apply destructive changes to the module's data table, like dropping columns",
+                        "name": "force",
+                        "in": "query"
                     }
                 ],
                 "responses": {
@@ -113,6 +180,37 @@ const docTemplate = `{
                 }
             }
         },
+        "/api/listAgent": {
+            "get": {
+                "description": "List all agent",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "agent"
+                ],
+                "summary": "List all agent",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "query field search like 'agent_id,node_name,agent_pod_id'",
+                        "name": "fields",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/http.ListModuleResp"
+                        }
+                    }
+                }
+            }
+        },
         "/api/listModule": {
             "get": {
                 "description": "List all moudle",
@@ -132,6 +230,12 @@ const docTemplate = `{
                         "description": "query field search like 'id,name,createTime'",
                         "name": "fields",
                         "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "only list the modules of this tenant, ignored for tenants' tokens",
+                        "name": "tenant",
+                        "in": "query"
                     }
                 ],
                 "responses": {
@@ -144,9 +248,39 @@ const docTemplate = `{
                 }
             }
         },
-        "/api/undeployModule": {
+        "/api/module/{id}/hypertable": {
+            "get": {
+                "description": "Get the hypertable spec of the module's data table, data is null if the data table is a plain table",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "module"
+                ],
+                "summary": "Get module hypertable",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "module id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/http.ModuleHypertableResp"
+                        }
+                    }
+                }
+            },
             "post": {
-                "description": "Undeploy the specified module from all agents in the cluster",
+                "description": "Set the hypertable spec of the module's data table, which is applied immediately if the module is\ndeployed, or when the module is deployed. The time column cannot be changed once the hypertable is\ncreated.",
                 "consumes": [
                     "application/json"
                 ],
@@ -156,14 +290,23 @@ const docTemplate = `{
                 "tags": [
                     "module"
                 ],
-                "summary": "Undeploy module",
+                "summary": "Update module hypertable",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "undeploy module id",
+                        "description": "module id",
                         "name": "id",
-                        "in": "query",
+                        "in": "path",
                         "required": true
+                    },
+                    {
+                        "description": "The hypertable spec",
+                        "name": "hypertable",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/common.Hypertable"
+                        }
                     }
                 ],
                 "responses": {
@@ -175,128 +318,1387 @@ const docTemplate = `{
                     }
                 }
             }
-        }
-    },
-    "definitions": {
-        "common.DataField": {
-            "type": "object",
-            "properties": {
-                "name": {
-                    "type": "string"
-                },
-                "type": {
-                    "$ref": "#/definitions/common.DataField_Type"
+        },
+        "/api/module/{id}/instances": {
+            "get": {
+                "description": "List the instances of the specified module, one per agent, with their deployment states",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "module"
+                ],
+                "summary": "List module instances",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "module id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/http.ListModuleInstanceResp"
+                        }
+                    }
                 }
             }
         },
-        "common.DataField_Type": {
-            "type": "integer",
-            "enum": [
-                0,
-                1,
-                2,
-                3,
-                4,
-                5,
-                6
-            ],
-            "x-enum-varnames": [
-                "DataField_BOOL",
-                "DataField_DATE",
-                "DataField_INT",
-                "DataField_INTEGER",
-                "DataField_JSON",
-                "DataField_JSONB",
-                "DataField_TEXT"
-            ]
-        },
-        "common.Format": {
-            "type": "integer",
-            "enum": [
-                0,
-                1
-            ],
-            "x-enum-varnames": [
-                "Format_TEXT",
-                "Format_BINARY"
-            ]
-        },
-        "common.Lang": {
-            "type": "integer",
-            "enum": [
-                0,
-                1
-            ],
-            "x-enum-varnames": [
-                "Lang_C",
-                "Lang_WAT"
-            ]
-        },
-        "common.Schema": {
-            "type": "object",
-            "properties": {
-                "fields": {
-                    "type": "array",
-                    "items": {
-                        "$ref": "#/definitions/common.DataField"
+        "/api/module/{id}/upgrade": {
+            "post": {
+                "description": "Switch the module to the specified version. A deployed module is upgraded agent by agent, and is\nrolled back to the previous version if any agent fails to deploy the new version. The upgrade fails\nif any agent of the module is not connected, unless skip_disconnected is set, then those agents are\nreturned as pending, and deploy the new version when they connect.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "module"
+                ],
+                "summary": "Upgrade module",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "module id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "the version to upgrade to",
+                        "name": "version",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "apply destructive changes to the data table",
+                        "name": "force",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "upgrade the agents that are not connected when they connect",
+                        "name": "skip_disconnected",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/http.UpgradeModuleResp"
+                        }
                     }
-                },
-                "name": {
-                    "type": "string"
                 }
             }
         },
-        "dao.ModuleGORM": {
-            "type": "object",
-            "properties": {
-                "create_time": {
-                    "type": "string"
-                },
-                "desire_state": {
-                    "type": "integer"
-                },
-                "ebpf": {
-                    "type": "string"
-                },
-                "ebpf_fmt": {
-                    "type": "integer"
-                },
-                "ebpf_lang": {
-                    "type": "integer"
-                },
-                "ebpf_perf_name": {
-                    "type": "string"
-                },
-                "ebpf_probes": {
-                    "type": "string"
-                },
-                "fn": {
-                    "type": "string"
-                },
-                "id": {
+        "/api/module/{id}/versions": {
+            "get": {
+                "description": "List the versions of the module, the latest version first",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "module"
+                ],
+                "summary": "List module versions",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "module id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/http.ListModuleVersionResp"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Store a new version of the module's code, the module keeps its name, data table, and dashboard",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "module"
+                ],
+                "summary": "Create module version",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "module id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "The new version of the module, name is ignored",
+                        "name": "module",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/http.CreateModuleReq"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/http.CreateModuleVersionResp"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/module:validate": {
+            "post": {
+                "description": "Check the module like creating and deploying it, without creating it. The WASM text code is compiled,\nthe WASM code must export the function and the io.h functions, and the probes must attach the\nfunctions defined in the BCC code. The response lists the problems, and the nodes whose agents\nwould run the module.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "module"
+                ],
+                "summary": "Validate module",
+                "parameters": [
+                    {
+                        "description": "The module to validate",
+                        "name": "module",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/http.CreateModuleReq"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/http.ValidateModuleResp"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/undeployModule": {
+            "post": {
+                "description": "Undeploy the specified module from all agents in the cluster",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "module"
+                ],
+                "summary": "Undeploy module",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "undeploy module id",
+                        "name": "id",
+                        "in": "query",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/http.HTTPResp"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v2/agents": {
+            "get": {
+                "description": "List the agents matching the filters, the latest updated agent first",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "agent-v2"
+                ],
+                "summary": "List agents",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "the returned fields like 'agent_id,node_name,state'",
+                        "name": "fields",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "only return the agents in this state, like 'online'",
+                        "name": "state",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "the maximal number of returned agents, 100 by default",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "the number of matching agents skipped",
+                        "name": "offset",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/http.AgentPage"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/http.ErrorResp"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/http.ErrorResp"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v2/agents/{id}": {
+            "get": {
+                "description": "Get the agent with the ID",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "agent-v2"
+                ],
+                "summary": "Get agent",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "agent id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/dao.NodeAgentGORM"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/http.ErrorResp"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/http.ErrorResp"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v2/audit": {
+            "get": {
+                "description": "List the audit records of management operations and state transitions matching the filters,\nthe latest record first",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "audit-v2"
+                ],
+                "summary": "List audit records",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "only return the records of this actor, like 'alice' or 'agent:\u003cid\u003e'",
+                        "name": "actor",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "only return the records of this action, like 'module.deploy'",
+                        "name": "action",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "only return the records of this target, like 'module:\u003cid\u003e'",
+                        "name": "target",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "the maximal number of returned records, 100 by default",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "the number of matching records skipped",
+                        "name": "offset",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/http.AuditPage"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/http.ErrorResp"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/http.ErrorResp"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v2/modules": {
+            "get": {
+                "description": "List the modules matching the filters, the latest created module first",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "module-v2"
+                ],
+                "summary": "List modules",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "the returned fields like 'id,name,desire_state'",
+                        "name": "fields",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "only return the module with this name",
+                        "name": "name",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "only return the modules in this desired state, like 'deployed'",
+                        "name": "state",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "only return the modules of this tenant, ignored for tenants' tokens",
+                        "name": "tenant",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "the maximal number of returned modules, 100 by default",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "the number of matching modules skipped",
+                        "name": "offset",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/http.ModulePage"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/http.ErrorResp"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/http.ErrorResp"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Create a module, the response's Location header is the path of the created module",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "module-v2"
+                ],
+                "summary": "Create module",
+                "parameters": [
+                    {
+                        "description": "the module",
+                        "name": "module",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/http.CreateModuleReq"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/http.ModuleIDResp"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/http.ErrorResp"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/http.ErrorResp"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/http.ErrorResp"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v2/modules/{id}": {
+            "get": {
+                "description": "Get the module with the ID",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "module-v2"
+                ],
+                "summary": "Get module",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "module id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/dao.ModuleGORM"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/http.ErrorResp"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/http.ErrorResp"
+                        }
+                    }
+                }
+            },
+            "put": {
+                "description": "Replace the name and code of the module, which must not be deployed. The code is validated, and\ncompiled if it is WASM text code, like when creating a module. The tenant cannot be changed.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "module-v2"
+                ],
+                "summary": "Update module",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "module id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "The module's new name and code",
+                        "name": "module",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/http.CreateModuleReq"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/http.ModuleIDResp"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/http.ErrorResp"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/http.ErrorResp"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/http.ErrorResp"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/http.ErrorResp"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Deploy the module onto, or undeploy it from, every agent in the cluster, with the path\n/api/v2/modules/{id}:deploy or /api/v2/modules/{id}:undeploy. The agents apply the change\nasynchronously, see /api/module/{id}/instances for their progress.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "module-v2"
+                ],
+                "summary": "Deploy or undeploy module",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "module id, followed by ':deploy' or ':undeploy'",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "apply destructive changes to the module's data table when deploying",
+                        "name": "force",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "202": {
+                        "description": "Accepted",
+                        "schema": {
+                            "$ref": "#/definitions/http.ModuleActionResp"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/http.ErrorResp"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/http.ErrorResp"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/http.ErrorResp"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "description": "Delete the module with the ID, the module must not be deployed",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "module-v2"
+                ],
+                "summary": "Delete module",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "module id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/http.ErrorResp"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/http.ErrorResp"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/http.ErrorResp"
+                        }
+                    }
+                }
+            },
+            "patch": {
+                "description": "Update the name, probes, code, output schema, or function name of the module, which must not be\ndeployed. The omitted fields keep their current values, see UpdateModuleReq. The updated module is\nvalidated, and its WASM text code is compiled, like when creating a module.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "module-v2"
+                ],
+                "summary": "Patch module",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "module id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "The updated fields of the module",
+                        "name": "module",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/http.UpdateModuleReq"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/http.ModuleIDResp"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/http.ErrorResp"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/http.ErrorResp"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/http.ErrorResp"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/http.ErrorResp"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v2/modules/{id}/bundle": {
+            "get": {
+                "description": "Export the module as a *.tar.gz bundle, which has the module's manifest, BCC code, WASM binary code,\nand the WASM source code if the module was created with it. See /api/v2/modules:import.",
+                "produces": [
+                    "application/gzip"
+                ],
+                "tags": [
+                    "module-v2"
+                ],
+                "summary": "Export module",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "module id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "file"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/http.ErrorResp"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/http.ErrorResp"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v2/modules:import": {
+            "post": {
+                "description": "Create a module from the *.tar.gz bundle in the request body, like the ones exported by\n/api/v2/modules/{id}/bundle. The WASM source code, if any, is compiled again instead of using the\nWASM binary code. The response's Location header is the path of the created module.",
+                "consumes": [
+                    "application/gzip"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "module-v2"
+                ],
+                "summary": "Import module",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "the name of the created module, the bundle's name if empty",
+                        "name": "name",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "the tenant of the created module, the token's tenant if empty",
+                        "name": "tenant",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/http.ModuleIDResp"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/http.ErrorResp"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/http.ErrorResp"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/http.ErrorResp"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/http.ErrorResp"
+                        }
+                    }
+                }
+            }
+        }
+    },
+    "definitions": {
+        "catalog.Entry": {
+            "type": "object",
+            "properties": {
+                "description": {
+                    "type": "string"
+                },
+                "kernel_features": {
+                    "description": "The kernel features that the module's probes need, like kprobe or perf_event, for the users to pick modules\nthat work on their nodes.",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "name": {
+                    "type": "string"
+                },
+                "parameters": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/catalog.Parameter"
+                    }
+                }
+            }
+        },
+        "catalog.Parameter": {
+            "type": "object",
+            "properties": {
+                "default": {
+                    "description": "The value used if none is set, the parameter is required if it has no default value.",
+                    "type": "string"
+                },
+                "description": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "type": {
+                    "description": "One of \"int\", \"bool\" and \"string\".",
+                    "type": "string"
+                }
+            }
+        },
+        "common.DataField": {
+            "type": "object",
+            "properties": {
+                "array": {
+                    "type": "boolean"
+                },
+                "buckets": {
+                    "type": "array",
+                    "items": {
+                        "type": "number"
+                    }
+                },
+                "default": {
+                    "type": "string"
+                },
+                "metric": {
+                    "$ref": "#/definitions/common.DataField_Metric"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "not_null": {
+                    "type": "boolean"
+                },
+                "type": {
+                    "$ref": "#/definitions/common.DataField_Type"
+                }
+            }
+        },
+        "common.DataField_Metric": {
+            "type": "integer",
+            "enum": [
+                0,
+                1,
+                2,
+                3,
+                4
+            ],
+            "x-enum-varnames": [
+                "DataField_NOT_METRIC",
+                "DataField_LABEL",
+                "DataField_COUNTER",
+                "DataField_GAUGE",
+                "DataField_HISTOGRAM"
+            ]
+        },
+        "common.DataField_Type": {
+            "type": "integer",
+            "enum": [
+                0,
+                1,
+                2,
+                3,
+                4,
+                5,
+                6,
+                7,
+                8,
+                9,
+                10,
+                11
+            ],
+            "x-enum-varnames": [
+                "DataField_BOOL",
+                "DataField_DATE",
+                "DataField_INT",
+                "DataField_INTEGER",
+                "DataField_JSON",
+                "DataField_JSONB",
+                "DataField_TEXT",
+                "DataField_TIMESTAMPTZ",
+                "DataField_BIGINT",
+                "DataField_DOUBLE",
+                "DataField_BYTEA",
+                "DataField_INET"
+            ]
+        },
+        "common.Format": {
+            "type": "integer",
+            "enum": [
+                0,
+                1
+            ],
+            "x-enum-varnames": [
+                "Format_TEXT",
+                "Format_BINARY"
+            ]
+        },
+        "common.Hypertable": {
+            "type": "object",
+            "properties": {
+                "compress_after": {
+                    "type": "string"
+                },
+                "retention": {
+                    "type": "string"
+                },
+                "time_column": {
+                    "type": "string"
+                }
+            }
+        },
+        "common.Index": {
+            "type": "object",
+            "properties": {
+                "columns": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "method": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "unique": {
+                    "type": "boolean"
+                }
+            }
+        },
+        "common.Lang": {
+            "type": "integer",
+            "enum": [
+                0,
+                1
+            ],
+            "x-enum-varnames": [
+                "Lang_C",
+                "Lang_WAT"
+            ]
+        },
+        "common.Schema": {
+            "type": "object",
+            "properties": {
+                "fields": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/common.DataField"
+                    }
+                },
+                "hypertable": {
+                    "$ref": "#/definitions/common.Hypertable"
+                },
+                "indexes": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/common.Index"
+                    }
+                },
+                "name": {
+                    "type": "string"
+                },
+                "primary_key": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "common.Sink": {
+            "type": "object",
+            "properties": {
+                "endpoint": {
+                    "type": "string"
+                },
+                "headers": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                },
+                "max_bytes": {
+                    "type": "integer"
+                },
+                "max_files": {
+                    "type": "integer"
+                },
+                "path": {
+                    "type": "string"
+                },
+                "push_interval_seconds": {
+                    "type": "integer"
+                },
+                "type": {
+                    "$ref": "#/definitions/common.Sink_Type"
+                }
+            }
+        },
+        "common.Sink_Type": {
+            "type": "integer",
+            "enum": [
+                0,
+                1,
+                2,
+                3,
+                4,
+                5
+            ],
+            "x-enum-varnames": [
+                "Sink_POSTGRES",
+                "Sink_FILE",
+                "Sink_STDOUT",
+                "Sink_OTLP",
+                "Sink_PROMETHEUS",
+                "Sink_OTLP_METRICS"
+            ]
+        },
+        "dao.AuditGORM": {
+            "type": "object",
+            "properties": {
+                "action": {
+                    "description": "The performed operation, for example \"module.create\" or \"agent.offline\".",
+                    "type": "string"
+                },
+                "actor": {
+                    "description": "Who performed the operation: the name of the authenticated principal, \"anonymous\" if authentication is\ndisabled, or \"agent:\u003cagent ID\u003e\" for the state transitions reported by agents.",
+                    "type": "string"
+                },
+                "after": {
+                    "type": "string"
+                },
+                "before": {
+                    "description": "The state of the target before and after the operation.",
+                    "type": "string"
+                },
+                "error": {
+                    "description": "The error message if the operation failed, empty if it succeeded.",
+                    "type": "string"
+                },
+                "id": {
+                    "description": "tag schema https://gorm.io/docs/models.html#Fields-Tags",
+                    "type": "integer"
+                },
+                "target": {
+                    "description": "The type and ID of the object being operated on, for example \"module:\u003cID\u003e\".",
+                    "type": "string"
+                },
+                "time": {
+                    "description": "The time when the operation happened.",
+                    "type": "string"
+                }
+            }
+        },
+        "dao.ModuleGORM": {
+            "type": "object",
+            "properties": {
+                "create_time": {
+                    "type": "string"
+                },
+                "desire_state": {
+                    "type": "integer"
+                },
+                "ebpf": {
+                    "type": "string"
+                },
+                "ebpf_fmt": {
+                    "type": "integer"
+                },
+                "ebpf_lang": {
+                    "type": "integer"
+                },
+                "ebpf_perf_name": {
+                    "type": "string"
+                },
+                "ebpf_probes": {
+                    "type": "string"
+                },
+                "fn": {
+                    "type": "string"
+                },
+                "hypertable": {
+                    "description": "The JSON of the data table's hypertable spec, empty if the data table is a plain table.",
+                    "type": "string"
+                },
+                "id": {
+                    "description": "tag schema https://gorm.io/docs/models.html#Fields-Tags",
+                    "type": "string"
+                },
+                "indexes": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "primary_key": {
+                    "description": "The JSON of the data table's primary key columns and secondary indexes, empty if none.",
+                    "type": "string"
+                },
+                "schema_attr": {
+                    "type": "string"
+                },
+                "schema_name": {
+                    "type": "string"
+                },
+                "signature": {
+                    "type": "array",
+                    "items": {
+                        "type": "integer"
+                    }
+                },
+                "signature_key_id": {
+                    "description": "The signature of the code above, empty if the module is not signed.",
+                    "type": "string"
+                },
+                "sink": {
+                    "description": "The JSON of the sink of the output, empty if the output is written to the data table in Postgres.",
+                    "type": "string"
+                },
+                "tenant": {
+                    "description": "The tenant owning the module, empty if the module is not owned by a tenant. The name is unique in the tenant.",
+                    "type": "string"
+                },
+                "version": {
+                    "description": "The version of the code above, the versions are stored in the module_version table.",
+                    "type": "integer"
+                },
+                "wasm": {
+                    "type": "array",
+                    "items": {
+                        "type": "integer"
+                    }
+                },
+                "wasm_code": {
+                    "description": "wasm store the whole wasm file content",
+                    "type": "string"
+                },
+                "wasm_fmt": {
+                    "type": "integer"
+                },
+                "wasm_lang": {
+                    "type": "integer"
+                }
+            }
+        },
+        "dao.ModuleInstanceGORM": {
+            "type": "object",
+            "properties": {
+                "agent_id": {
+                    "type": "string"
+                },
+                "create_time": {
+                    "type": "string"
+                },
+                "desire_state": {
+                    "type": "integer"
+                },
+                "id": {
+                    "description": "tag schema https://gorm.io/docs/models.html#Fields-Tags",
+                    "type": "string"
+                },
+                "last_update_time": {
+                    "type": "string"
+                },
+                "module_id": {
+                    "type": "string"
+                },
+                "module_name": {
+                    "type": "string"
+                },
+                "node_name": {
+                    "type": "string"
+                },
+                "state": {
+                    "type": "integer"
+                },
+                "state_desc": {
+                    "description": "desc of the agent's last DeployModuleResp",
+                    "type": "string"
+                },
+                "version": {
+                    "description": "version of the module to deploy",
+                    "type": "integer"
+                }
+            }
+        },
+        "dao.ModuleVersionGORM": {
+            "type": "object",
+            "properties": {
+                "create_time": {
+                    "type": "string"
+                },
+                "ebpf": {
+                    "type": "string"
+                },
+                "ebpf_fmt": {
+                    "type": "integer"
+                },
+                "ebpf_lang": {
+                    "type": "integer"
+                },
+                "ebpf_perf_name": {
+                    "type": "string"
+                },
+                "ebpf_probes": {
+                    "type": "string"
+                },
+                "fn": {
+                    "type": "string"
+                },
+                "id": {
+                    "description": "tag schema https://gorm.io/docs/models.html#Fields-Tags",
+                    "type": "string"
+                },
+                "indexes": {
+                    "type": "string"
+                },
+                "module_id": {
+                    "type": "string"
+                },
+                "primary_key": {
+                    "type": "string"
+                },
+                "schema_attr": {
+                    "type": "string"
+                },
+                "signature": {
+                    "type": "array",
+                    "items": {
+                        "type": "integer"
+                    }
+                },
+                "signature_key_id": {
+                    "type": "string"
+                },
+                "sink": {
+                    "type": "string"
+                },
+                "version": {
+                    "type": "integer"
+                },
+                "wasm": {
+                    "type": "array",
+                    "items": {
+                        "type": "integer"
+                    }
+                },
+                "wasm_code": {
+                    "type": "string"
+                },
+                "wasm_fmt": {
+                    "type": "integer"
+                },
+                "wasm_lang": {
+                    "type": "integer"
+                }
+            }
+        },
+        "dao.NodeAgentGORM": {
+            "type": "object",
+            "properties": {
+                "agent_id": {
                     "description": "tag schema https://gorm.io/docs/models.html#Fields-Tags",
                     "type": "string"
                 },
-                "name": {
+                "agent_pod_id": {
                     "type": "string"
                 },
-                "schema_attr": {
+                "create_time": {
                     "type": "string"
                 },
-                "schema_name": {
+                "last_update_time": {
                     "type": "string"
                 },
-                "wasm": {
-                    "description": "wasm store the whole wasm file content",
-                    "type": "array",
-                    "items": {
-                        "type": "integer"
-                    }
-                },
-                "wasm_fmt": {
-                    "type": "integer"
+                "node_name": {
+                    "type": "string"
                 },
-                "wasm_lang": {
+                "state": {
                     "type": "integer"
                 }
             }
@@ -368,6 +1770,70 @@ const docTemplate = `{
                 }
             }
         },
+        "http.AgentPage": {
+            "type": "object",
+            "properties": {
+                "data": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/dao.NodeAgentGORM"
+                    }
+                },
+                "limit": {
+                    "description": "The maximal number of items returned, and the number of matching items skipped before them.",
+                    "type": "integer"
+                },
+                "offset": {
+                    "type": "integer"
+                },
+                "total": {
+                    "description": "The total number of items matching the filters.",
+                    "type": "integer"
+                }
+            }
+        },
+        "http.AuditPage": {
+            "type": "object",
+            "properties": {
+                "data": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/dao.AuditGORM"
+                    }
+                },
+                "limit": {
+                    "description": "The maximal number of items returned, and the number of matching items skipped before them.",
+                    "type": "integer"
+                },
+                "offset": {
+                    "type": "integer"
+                },
+                "total": {
+                    "description": "The total number of items matching the filters.",
+                    "type": "integer"
+                }
+            }
+        },
+        "http.CreateFromCatalogReq": {
+            "type": "object",
+            "properties": {
+                "name": {
+                    "description": "Optional, the name of the created module, the name of the catalog entry if empty.",
+                    "type": "string"
+                },
+                "parameters": {
+                    "description": "Optional, the values of the entry's parameters, the parameters not set have their default values.",
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                },
+                "tenant": {
+                    "description": "Optional, the tenant of the created module, the tenant of the request's token if empty.",
+                    "type": "string"
+                }
+            }
+        },
         "http.CreateModuleReq": {
             "type": "object",
             "properties": {
@@ -380,12 +1846,41 @@ const docTemplate = `{
                 "name": {
                     "type": "string"
                 },
+                "signature": {
+                    "description": "Optional, the signature of the module made by the client, see signing.Sign().",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/module.Signature"
+                        }
+                    ]
+                },
+                "tenant": {
+                    "description": "Optional, the tenant of the module, the tenant of the request's token if empty. The name is unique in the tenant.",
+                    "type": "string"
+                },
                 "wasm": {
                     "$ref": "#/definitions/wasm.Program"
                 }
             }
         },
         "http.CreateModuleResp": {
+            "type": "object",
+            "properties": {
+                "code": {
+                    "description": "Semantic and usage follow HTTP statues code convention.\nhttps://developer.mozilla.org/en-US/docs/Web/HTTP/Status",
+                    "type": "integer"
+                },
+                "id": {
+                    "description": "The ID of the created module.",
+                    "type": "string"
+                },
+                "message": {
+                    "description": "A human readable message explain the details of the status.",
+                    "type": "string"
+                }
+            }
+        },
+        "http.CreateModuleVersionResp": {
             "type": "object",
             "properties": {
                 "code": {
@@ -395,6 +1890,9 @@ const docTemplate = `{
                 "message": {
                     "description": "A human readable message explain the details of the status.",
                     "type": "string"
+                },
+                "version": {
+                    "type": "integer"
                 }
             }
         },
@@ -414,6 +1912,27 @@ const docTemplate = `{
                 }
             }
         },
+        "http.ErrorDetail": {
+            "type": "object",
+            "properties": {
+                "code": {
+                    "description": "The HTTP status code of the response.",
+                    "type": "integer"
+                },
+                "message": {
+                    "description": "A human readable message explain the failure.",
+                    "type": "string"
+                }
+            }
+        },
+        "http.ErrorResp": {
+            "type": "object",
+            "properties": {
+                "error": {
+                    "$ref": "#/definitions/http.ErrorDetail"
+                }
+            }
+        },
         "http.HTTPResp": {
             "type": "object",
             "properties": {
@@ -427,6 +1946,44 @@ const docTemplate = `{
                 }
             }
         },
+        "http.ListCatalogResp": {
+            "type": "object",
+            "properties": {
+                "code": {
+                    "description": "Semantic and usage follow HTTP statues code convention.\nhttps://developer.mozilla.org/en-US/docs/Web/HTTP/Status",
+                    "type": "integer"
+                },
+                "data": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/catalog.Entry"
+                    }
+                },
+                "message": {
+                    "description": "A human readable message explain the details of the status.",
+                    "type": "string"
+                }
+            }
+        },
+        "http.ListModuleInstanceResp": {
+            "type": "object",
+            "properties": {
+                "code": {
+                    "description": "Semantic and usage follow HTTP statues code convention.\nhttps://developer.mozilla.org/en-US/docs/Web/HTTP/Status",
+                    "type": "integer"
+                },
+                "data": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/dao.ModuleInstanceGORM"
+                    }
+                },
+                "message": {
+                    "description": "A human readable message explain the details of the status.",
+                    "type": "string"
+                }
+            }
+        },
         "http.ListModuleResp": {
             "type": "object",
             "properties": {
@@ -446,6 +2003,176 @@ const docTemplate = `{
                 }
             }
         },
+        "http.ListModuleVersionResp": {
+            "type": "object",
+            "properties": {
+                "code": {
+                    "description": "Semantic and usage follow HTTP statues code convention.\nhttps://developer.mozilla.org/en-US/docs/Web/HTTP/Status",
+                    "type": "integer"
+                },
+                "data": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/dao.ModuleVersionGORM"
+                    }
+                },
+                "message": {
+                    "description": "A human readable message explain the details of the status.",
+                    "type": "string"
+                }
+            }
+        },
+        "http.ModuleActionResp": {
+            "type": "object",
+            "properties": {
+                "id": {
+                    "type": "string"
+                },
+                "uid": {
+                    "description": "The UID of the module's Grafana dashboard, set after deploying a module that writes to Postgres.",
+                    "type": "string"
+                }
+            }
+        },
+        "http.ModuleHypertableResp": {
+            "type": "object",
+            "properties": {
+                "code": {
+                    "description": "Semantic and usage follow HTTP statues code convention.\nhttps://developer.mozilla.org/en-US/docs/Web/HTTP/Status",
+                    "type": "integer"
+                },
+                "data": {
+                    "$ref": "#/definitions/common.Hypertable"
+                },
+                "message": {
+                    "description": "A human readable message explain the details of the status.",
+                    "type": "string"
+                }
+            }
+        },
+        "http.ModuleIDResp": {
+            "type": "object",
+            "properties": {
+                "id": {
+                    "type": "string"
+                }
+            }
+        },
+        "http.ModulePage": {
+            "type": "object",
+            "properties": {
+                "data": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/dao.ModuleGORM"
+                    }
+                },
+                "limit": {
+                    "description": "The maximal number of items returned, and the number of matching items skipped before them.",
+                    "type": "integer"
+                },
+                "offset": {
+                    "type": "integer"
+                },
+                "total": {
+                    "description": "The total number of items matching the filters.",
+                    "type": "integer"
+                }
+            }
+        },
+        "http.UpdateModuleReq": {
+            "type": "object",
+            "properties": {
+                "ebpf": {
+                    "description": "The code, with its fmt and lang, the perf buffer name, and the probes each replace the current ones if not empty.",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/ebpf.Program"
+                        }
+                    ]
+                },
+                "name": {
+                    "type": "string"
+                },
+                "signature": {
+                    "description": "Optional, the signature of the updated code. The current signature is dropped if the code changes.",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/module.Signature"
+                        }
+                    ]
+                },
+                "wasm": {
+                    "description": "The code, with its fmt and lang, the function name, the output schema and the sink each replace the current ones\nif not empty.",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/wasm.Program"
+                        }
+                    ]
+                }
+            }
+        },
+        "http.UpgradeModuleResp": {
+            "type": "object",
+            "properties": {
+                "code": {
+                    "description": "Semantic and usage follow HTTP statues code convention.\nhttps://developer.mozilla.org/en-US/docs/Web/HTTP/Status",
+                    "type": "integer"
+                },
+                "message": {
+                    "description": "A human readable message explain the details of the status.",
+                    "type": "string"
+                },
+                "pending": {
+                    "description": "The agents that are not connected, whose instances of the module are upgraded when they connect.\nOnly set if the upgrade skips the disconnected agents.",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "http.ValidateModuleResp": {
+            "type": "object",
+            "properties": {
+                "code": {
+                    "description": "Semantic and usage follow HTTP statues code convention.\nhttps://developer.mozilla.org/en-US/docs/Web/HTTP/Status",
+                    "type": "integer"
+                },
+                "errors": {
+                    "description": "The problems that would fail creating or deploying the module, empty if the module is valid.",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "message": {
+                    "description": "A human readable message explain the details of the status.",
+                    "type": "string"
+                },
+                "nodes": {
+                    "description": "The nodes whose agents would run the module if it were deployed now.",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "module.Signature": {
+            "type": "object",
+            "properties": {
+                "key_id": {
+                    "type": "string"
+                },
+                "value": {
+                    "type": "array",
+                    "items": {
+                        "type": "integer"
+                    }
+                }
+            }
+        },
         "wasm.Program": {
             "type": "object",
             "properties": {
@@ -466,6 +2193,9 @@ const docTemplate = `{
                 },
                 "output_schema": {
                     "$ref": "#/definitions/common.Schema"
+                },
+                "sink": {
+                    "$ref": "#/definitions/common.Sink"
                 }
             }
         }
@@ -6,8 +6,8 @@ import (
 
 	"github.com/tricorder/src/api-server/http"
 	"github.com/tricorder/src/api-server/http/dao"
+	"github.com/tricorder/src/api-server/utils/channel"
 	"github.com/tricorder/src/api-server/wasm"
-	"github.com/tricorder/src/utils/cond"
 	"github.com/tricorder/src/utils/lock"
 	"github.com/tricorder/src/utils/pg"
 	"github.com/tricorder/src/utils/sqlite"
@@ -38,7 +38,7 @@ func (srv *Server) Start(cfg http.Config, pgClient *pg.Client, wasiCompiler *was
 // StartFakeNewServer creates a Server and start the server.
 func StartFakeNewServer(
 	sqliteClient *sqlite.ORM, gLock *lock.Lock,
-	waitCond *cond.Cond, pgClient *pg.Client, grafanaURL string,
+	dispatcher *channel.Dispatcher, pgClient *pg.Client, grafanaURL string,
 	wasiSDKPath string, wasiStarshipIncludePath string,
 	wasiBuildTmpPath string,
 ) net.Addr {
@@ -55,8 +55,10 @@ func StartFakeNewServer(
 		Module:          dao.Module,
 		NodeAgent:       dao.NodeAgent,
 		ModuleInstance:  dao.ModuleInstance,
+		ModuleVersion:   dao.ModuleVersion,
+		Audit:           dao.Audit,
 		GLock:           gLock,
-		WaitCond:        waitCond,
+		Dispatcher:      dispatcher,
 		Standalone:      false,
 	}
 	wasiCompiler := wasm.NewWASICompiler(wasiSDKPath, wasiStarshipIncludePath, wasiBuildTmpPath)
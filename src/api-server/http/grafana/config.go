@@ -21,6 +21,7 @@ type Config struct {
 	CreateDashBoardURI string
 	CreateDatabaseURI  string
 	GetDashboardURI    string
+	FoldersURI         string
 	BasicAuth          string
 }
 
@@ -33,6 +34,7 @@ func NewConfig(baseURL, userName, password string) Config {
 		CreateDashBoardURI: baseURL + "/api/dashboards/db",
 		CreateDatabaseURI:  baseURL + "/api/datasources",
 		GetDashboardURI:    baseURL + "/api/dashboards/uid/",
+		FoldersURI:         baseURL + "/api/folders",
 		BasicAuth:          userName + ":" + password,
 	}
 }
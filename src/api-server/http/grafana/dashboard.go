@@ -24,6 +24,7 @@ import (
 	"strings"
 
 	"github.com/tricorder/src/utils/errors"
+	"github.com/tricorder/src/utils/pg"
 )
 
 // TODO(zhihui): Add tests with a running Grafana instance in docker container
@@ -42,34 +43,21 @@ func NewDashboard(config Config) *Dashboard {
 	}
 }
 
-func (g *Dashboard) CreateDashboard(createDashBoardAuthKey, title, datasourceUID string) (*DashboardResult, error) {
-	sql := "SELECT * FROM " + title + " LIMIT 50"
-	targetList := [1]DashboardTargetData{{
-		Format:       "table",
-		MetricColumn: "none",
-		RawQuery:     true,
-		RawSQL:       sql,
-		RefID:        "A",
-		Table:        title,
-		Hide:         false,
-		Datasource: DashboardTargetDatasourceData{
-			Type: "postgres",
-			UID:  datasourceUID,
-		},
-	}}
-	panelsObj := make([]DashboardPanelData, 1)
-	panelsObj[0] = DashboardPanelData{
-		Type:          "table",
-		Title:         title,
-		PluginVersion: "8.3.3",
-		Targets:       targetList,
-		GridPos: GridPos{
-			X: 0,
-			Y: 0,
-			H: 15,
-			W: 21,
-		},
-	}
+// CreateDashboard creates a dashboard for the data table with the columns, see newPanels() for the panels.
+func (g *Dashboard) CreateDashboard(
+	createDashBoardAuthKey, title, datasourceUID string,
+	columns []pg.Column,
+) (*DashboardResult, error) {
+	return g.CreateDashboardInFolder(createDashBoardAuthKey, title, datasourceUID, "", columns)
+}
+
+// CreateDashboardInFolder is like CreateDashboard(), but creates the dashboard in the folder with the UID, which must
+// exist, see Folder.EnsureFolder(). The dashboard is created in the General folder if folderUID is empty.
+func (g *Dashboard) CreateDashboardInFolder(
+	createDashBoardAuthKey, title, datasourceUID, folderUID string,
+	columns []pg.Column,
+) (*DashboardResult, error) {
+	panelsObj := newPanels(title, datasourceUID, columns)
 
 	bodyReq := BodyData{
 		Dashboard: DashboardData{
@@ -78,6 +66,7 @@ func (g *Dashboard) CreateDashboard(createDashBoardAuthKey, title, datasourceUID
 			Panels:  panelsObj,
 			Refresh: "5s",
 		},
+		FolderUID: folderUID,
 	}
 
 	bytesData, _ := json.Marshal(bodyReq)
@@ -229,6 +218,8 @@ type DashboardData struct {
 
 type BodyData struct {
 	Dashboard DashboardData `json:"dashboard"`
+	// The UID of the folder of the dashboard, the General folder if empty.
+	FolderUID string `json:"folderUid,omitempty"`
 }
 
 type DashboardDetailResult struct {
@@ -0,0 +1,106 @@
+// Copyright (C) 2023  Tricorder Observability
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package grafana
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+
+	"github.com/tricorder/src/utils/errors"
+)
+
+// Folder manages the folders that group the dashboards, like the dashboards of the modules of a tenant.
+type Folder struct {
+	config Config
+	client http.Client
+}
+
+func NewFolder(config Config) *Folder {
+	return &Folder{
+		config: config,
+		client: http.Client{},
+	}
+}
+
+type folderReq struct {
+	UID   string `json:"uid"`
+	Title string `json:"title"`
+}
+
+// EnsureFolder creates the folder with the UID and title, if it does not exist.
+func (f *Folder) EnsureFolder(authKey, uid, title string) error {
+	exists, err := f.exists(authKey, uid)
+	if err != nil {
+		return errors.Wrap("ensuring folder "+uid, "check existence", err)
+	}
+	if exists {
+		return nil
+	}
+
+	bytesData, err := json.Marshal(folderReq{UID: uid, Title: title})
+	if err != nil {
+		return errors.Wrap("ensuring folder "+uid, "marshal request", err)
+	}
+	req, err := http.NewRequest("POST", f.config.FoldersURI, bytes.NewReader(bytesData))
+	if err != nil {
+		return errors.Wrap("ensuring folder "+uid, "create HTTP request", err)
+	}
+	req.Header.Set("Content-Type", "application/json; charset=UTF-8")
+	req.Header.Set("Authorization", "Bearer "+authKey)
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return errors.Wrap("ensuring folder "+uid, "send HTTP request", err)
+	}
+	defer resp.Body.Close()
+
+	// Another request might have created the folder after the check, Grafana responds with 409 or 412 then.
+	if resp.StatusCode == http.StatusConflict || resp.StatusCode == http.StatusPreconditionFailed {
+		return nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("while ensuring folder %s, failed to create folder, status: %d, body: %s",
+			uid, resp.StatusCode, body)
+	}
+	return nil
+}
+
+// exists returns true if the folder with the UID exists.
+func (f *Folder) exists(authKey, uid string) (bool, error) {
+	req, err := http.NewRequest("GET", f.config.FoldersURI+"/"+url.PathEscape(uid), nil)
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Authorization", "Bearer "+authKey)
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return true, nil
+	case http.StatusNotFound:
+		return false, nil
+	default:
+		return false, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+}
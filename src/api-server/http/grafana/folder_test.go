@@ -0,0 +1,69 @@
+// Copyright (C) 2023  Tricorder Observability
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package grafana
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Tests that EnsureFolder creates the folder only if it does not exist.
+func TestEnsureFolder(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	var mu sync.Mutex
+	folders := map[string]string{}
+	creates := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		assert.Equal("Bearer key", r.Header.Get("Authorization"))
+		switch {
+		case r.Method == "GET" && strings.HasPrefix(r.URL.Path, "/api/folders/"):
+			if _, ok := folders[strings.TrimPrefix(r.URL.Path, "/api/folders/")]; ok {
+				w.WriteHeader(http.StatusOK)
+			} else {
+				w.WriteHeader(http.StatusNotFound)
+			}
+		case r.Method == "POST" && r.URL.Path == "/api/folders":
+			var req folderReq
+			assert.Nil(json.NewDecoder(r.Body).Decode(&req))
+			creates++
+			folders[req.UID] = req.Title
+			w.WriteHeader(http.StatusOK)
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	defer srv.Close()
+
+	folder := NewFolder(NewConfig(srv.URL, "admin", "admin"))
+	require.Nil(folder.EnsureFolder("key", "starship-tenant-a", "a"))
+	require.Nil(folder.EnsureFolder("key", "starship-tenant-a", "a"))
+	assert.Equal(1, creates)
+	assert.Equal(map[string]string{"starship-tenant-a": "a"}, folders)
+
+	srv.Close()
+	assert.NotNil(folder.EnsureFolder("key", "starship-tenant-b", "b"))
+}
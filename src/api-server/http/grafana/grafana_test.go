@@ -24,6 +24,7 @@ import (
 	"github.com/stretchr/testify/require"
 
 	grafanaTest "github.com/tricorder/src/testing/grafana"
+	"github.com/tricorder/src/utils/pg"
 )
 
 // Tests that auth token can be created on Grafana.
@@ -51,7 +52,7 @@ func TestAuthToken(t *testing.T) {
 	dashboard := NewDashboard(config)
 	assert.NotNil(dashboard)
 
-	result, err := dashboard.CreateDashboard(token.Key, "APIServer1", "uid")
+	result, err := dashboard.CreateDashboard(token.Key, "APIServer1", "uid", []pg.Column{{Name: "pid", Type: pg.INT}})
 	assert.Nil(err)
 
 	assert.Equal("success", result.Status)
@@ -0,0 +1,133 @@
+// Copyright (C) 2023  Tricorder Observability
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package grafana
+
+import (
+	"fmt"
+	"strings"
+
+	commonpb "github.com/tricorder/src/pb/module/common"
+	"github.com/tricorder/src/utils/pg"
+)
+
+// The layout of the panels on the dashboard, which is 24 columns wide.
+const (
+	tablePanelHeight      = 15
+	tablePanelWidth       = 24
+	timeseriesPanelHeight = 8
+	timeseriesPanelWidth  = 12
+	dashboardWidth        = 24
+)
+
+// The data types that are plotted as time series.
+var numericTypes = map[commonpb.DataField_Type]bool{
+	pg.INT:     true,
+	pg.INTEGER: true,
+	pg.BIGINT:  true,
+	pg.DOUBLE:  true,
+}
+
+// timeColumn returns the column used as the time of the records, which is the first TIMESTAMPTZ column,
+// or the reserved ingest time column if there is none.
+func timeColumn(columns []pg.Column) string {
+	for _, col := range columns {
+		if col.Type == pg.TIMESTAMPTZ && !col.Array {
+			return col.Name
+		}
+	}
+	return pg.IngestTimeColumn
+}
+
+// selectExpr returns the expression that selects the column in a form that Grafana can display.
+func selectExpr(col pg.Column) string {
+	name := pg.QuoteIdentifier(col.Name)
+	switch {
+	case col.Array, col.Type == pg.INET:
+		return fmt.Sprintf("%s::text AS %s", name, name)
+	case col.Type == pg.BYTEA:
+		return fmt.Sprintf("encode(%s, 'hex') AS %s", name, name)
+	default:
+		return name
+	}
+}
+
+func newTarget(table, datasourceUID, format, refID, sql string) DashboardTargetData {
+	return DashboardTargetData{
+		Format:       format,
+		MetricColumn: "none",
+		RawQuery:     true,
+		RawSQL:       sql,
+		RefID:        refID,
+		Table:        table,
+		Hide:         false,
+		Datasource: DashboardTargetDatasourceData{
+			Type: "postgres",
+			UID:  datasourceUID,
+		},
+	}
+}
+
+// newPanels returns the panels of the dashboard of a module's data table with the columns:
+// a table panel with the latest records, and a time series panel for each numeric column.
+func newPanels(table, datasourceUID string, columns []pg.Column) []DashboardPanelData {
+	timeCol := pg.QuoteIdentifier(timeColumn(columns))
+	quotedTable := pg.QuoteTableName(table)
+
+	exprs := make([]string, 0, len(columns)+len(pg.ReservedColumns))
+	for _, col := range columns {
+		exprs = append(exprs, selectExpr(col))
+	}
+	for _, col := range pg.ReservedColumns {
+		exprs = append(exprs, selectExpr(col))
+	}
+	sql := fmt.Sprintf("SELECT %s FROM %s ORDER BY %s DESC LIMIT 50", strings.Join(exprs, ", "), quotedTable, timeCol)
+
+	panels := []DashboardPanelData{{
+		Type:          "table",
+		Title:         table,
+		PluginVersion: "8.3.3",
+		Targets:       []DashboardTargetData{newTarget(table, datasourceUID, "table", "A", sql)},
+		GridPos: GridPos{
+			X: 0,
+			Y: 0,
+			H: tablePanelHeight,
+			W: tablePanelWidth,
+		},
+	}}
+
+	i := 0
+	for _, col := range columns {
+		if col.Array || !numericTypes[col.Type] {
+			continue
+		}
+		sql := fmt.Sprintf(`SELECT %s AS "time", %s FROM %s WHERE $__timeFilter(%s) ORDER BY 1`,
+			timeCol, pg.QuoteIdentifier(col.Name), quotedTable, timeCol)
+		panels = append(panels, DashboardPanelData{
+			Type:          "timeseries",
+			Title:         col.Name,
+			PluginVersion: "8.3.3",
+			Targets:       []DashboardTargetData{newTarget(table, datasourceUID, "time_series", "A", sql)},
+			GridPos: GridPos{
+				X: (i * timeseriesPanelWidth) % dashboardWidth,
+				Y: tablePanelHeight + (i*timeseriesPanelWidth)/dashboardWidth*timeseriesPanelHeight,
+				H: timeseriesPanelHeight,
+				W: timeseriesPanelWidth,
+			},
+		})
+		i++
+	}
+	return panels
+}
@@ -0,0 +1,72 @@
+// Copyright (C) 2023  Tricorder Observability
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package grafana
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/tricorder/src/utils/pg"
+)
+
+// Tests that newPanels creates a table panel, and a time series panel for each numeric column.
+func TestNewPanels(t *testing.T) {
+	assert := assert.New(t)
+
+	columns := []pg.Column{
+		{Name: "ts", Type: pg.TIMESTAMPTZ},
+		{Name: "pid", Type: pg.INT},
+		{Name: "latency", Type: pg.DOUBLE},
+		{Name: "bytes", Type: pg.BIGINT},
+		{Name: "payload", Type: pg.BYTEA},
+		{Name: "addr", Type: pg.INET},
+		{Name: "ports", Type: pg.INTEGER, Array: true},
+	}
+	panels := newPanels("t", "uid", columns)
+	assert.Len(panels, 4)
+
+	assert.Equal("table", panels[0].Type)
+	assert.Equal(`SELECT "ts", "pid", "latency", "bytes", encode("payload", 'hex') AS "payload", `+
+		`"addr"::text AS "addr", "ports"::text AS "ports", `+
+		`"_ingest_time", "_node_name", "_agent_id", "_pod", "_container" `+
+		`FROM "t" ORDER BY "ts" DESC LIMIT 50`,
+		panels[0].Targets.([]DashboardTargetData)[0].RawSQL)
+
+	var titles []string
+	for _, panel := range panels[1:] {
+		assert.Equal("timeseries", panel.Type)
+		titles = append(titles, panel.Title)
+	}
+	assert.Equal([]string{"pid", "latency", "bytes"}, titles)
+	target := panels[1].Targets.([]DashboardTargetData)[0]
+	assert.Equal("time_series", target.Format)
+	assert.Equal(`SELECT "ts" AS "time", "pid" FROM "t" WHERE $__timeFilter("ts") ORDER BY 1`, target.RawSQL)
+	assert.Equal(GridPos{X: 0, Y: 15, H: 8, W: 12}, panels[1].GridPos)
+	assert.Equal(GridPos{X: 12, Y: 15, H: 8, W: 12}, panels[2].GridPos)
+	assert.Equal(GridPos{X: 0, Y: 23, H: 8, W: 12}, panels[3].GridPos)
+}
+
+// Tests that newPanels uses the ingest time column, if there is no TIMESTAMPTZ column.
+func TestNewPanelsIngestTime(t *testing.T) {
+	assert := assert.New(t)
+
+	panels := newPanels("t", "uid", []pg.Column{{Name: "comm", Type: pg.TEXT}})
+	assert.Len(panels, 1)
+	assert.Equal(`SELECT "comm", "_ingest_time", "_node_name", "_agent_id", "_pod", "_container" `+
+		`FROM "t" ORDER BY "_ingest_time" DESC LIMIT 50`,
+		panels[0].Targets.([]DashboardTargetData)[0].RawSQL)
+}
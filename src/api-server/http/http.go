@@ -16,10 +16,13 @@
 package http
 
 import (
+	"crypto/ed25519"
 	"fmt"
 	"net"
 
 	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 
 	"github.com/tricorder/src/utils/errors"
 	"github.com/tricorder/src/utils/log"
@@ -27,13 +30,17 @@ import (
 	swagfiles "github.com/swaggo/files"
 	ginswag "github.com/swaggo/gin-swagger"
 
+	"github.com/tricorder/src/api-server/auth"
+	"github.com/tricorder/src/api-server/catalog"
 	"github.com/tricorder/src/api-server/http/api"
 	"github.com/tricorder/src/api-server/http/dao"
 	"github.com/tricorder/src/api-server/http/grafana"
+	"github.com/tricorder/src/api-server/tenant"
+	"github.com/tricorder/src/api-server/utils/channel"
 	"github.com/tricorder/src/api-server/wasm"
-	"github.com/tricorder/src/utils/cond"
 	"github.com/tricorder/src/utils/lock"
 	"github.com/tricorder/src/utils/pg"
+	"github.com/tricorder/src/utils/signing"
 )
 
 type Config struct {
@@ -47,9 +54,23 @@ type Config struct {
 	Module          dao.ModuleDao
 	NodeAgent       dao.NodeAgentDao
 	ModuleInstance  dao.ModuleInstanceDao
+	ModuleVersion   dao.ModuleVersionDao
+	Audit           dao.AuditDao
 	GLock           *lock.Lock
-	WaitCond        *cond.Cond
+	Dispatcher      *channel.Dispatcher
 	Standalone      bool
+	// Authenticates the requests and authorizes them by the roles of the routes, all requests are allowed if nil.
+	Auth *auth.Authenticator
+	// Optional, signs the modules created without signatures.
+	ModuleSigningKey ed25519.PrivateKey
+	// Optional, verifies the signatures of the created modules, and rejects the unsigned modules.
+	ModuleVerifier *signing.Verifier
+	// Optional, the nodes owned by the tenants, the modules of every tenant are deployed onto all nodes if nil.
+	Tenants *tenant.Tenants
+	// If true, creating and updating modules also runs the checks of validating their code, see validateModuleHttp().
+	ValidateModules bool
+	// Optional, the modules bundled with API Server, listed by GET /api/catalog.
+	Catalog *catalog.Catalog
 }
 
 // StartHTTPService launches long-running HTTP Server to support API Server's HTTP APIs, accessible from
@@ -82,25 +103,62 @@ func StartHTTPService(cfg Config, pgClient *pg.Client, wasiCompiler *wasm.WASICo
 		Module:         cfg.Module,
 		NodeAgent:      cfg.NodeAgent,
 		ModuleInstance: cfg.ModuleInstance,
+		ModuleVersion:  cfg.ModuleVersion,
+		Audit:          cfg.Audit,
 		PGClient:       pgClient,
 		gLock:          cfg.GLock,
-		waitCond:       cfg.WaitCond,
+		dispatcher:     cfg.Dispatcher,
 		wasiCompiler:   wasiCompiler,
+		authenticator:  cfg.Auth,
+
+		moduleSigningKey: cfg.ModuleSigningKey,
+		moduleVerifier:   cfg.ModuleVerifier,
+		tenants:          cfg.Tenants,
+		validateModules:  cfg.ValidateModules,
+		catalog:          cfg.Catalog,
 	}
 	router := gin.Default()
 
 	router.Use(Cors()).Use(GlobalExceptionWare)
 
+	if !cfg.Auth.Enabled() {
+		log.Warnf("Authentication is disabled, anyone who can reach the HTTP API can deploy modules")
+	}
+	viewer := mgr.requireRole(auth.RoleViewer)
+	operator := mgr.requireRole(auth.RoleOperator)
+	admin := mgr.requireRole(auth.RoleAdmin)
+	// The principals of a tenant only access the modules of their tenant.
+	ofTenant := mgr.requireModuleOfTenant()
+
 	apiRoot := router.Group(api.ROOT)
-	apiRoot.POST(api.CREATE_MODULE, mgr.createModuleHttp)
-	apiRoot.GET(api.DELETE_MODULE, mgr.deleteModuleHttp)
-	apiRoot.GET(api.LIST_AGENT, mgr.listAgentHttp)
-	apiRoot.GET(api.LIST_MODULE, mgr.listModuleHttp)
-	apiRoot.POST(api.DEPLOY_MODULE, mgr.deployModuleHttp)
-	apiRoot.POST(api.UNDEPLOY_MODULE, mgr.undeployModuleHttp)
+	apiRoot.POST(api.CREATE_MODULE, operator, mgr.createModuleHttp)
+	apiRoot.POST(api.MODULE_ACTION, operator, mgr.validateModuleHttp)
+	apiRoot.GET(api.CATALOG, viewer, mgr.listCatalogHttp)
+	apiRoot.POST(api.CATALOG_ENTRY, operator, mgr.createFromCatalogHttp)
+	apiRoot.GET(api.DELETE_MODULE, admin, ofTenant, mgr.deleteModuleHttp)
+	apiRoot.GET(api.LIST_AGENT, viewer, mgr.listAgentHttp)
+	apiRoot.GET(api.LIST_MODULE, viewer, mgr.listModuleHttp)
+	apiRoot.POST(api.DEPLOY_MODULE, operator, ofTenant, mgr.deployModuleHttp)
+	apiRoot.POST(api.UNDEPLOY_MODULE, operator, ofTenant, mgr.undeployModuleHttp)
+	apiRoot.GET(api.MODULE_INSTANCES, viewer, ofTenant, mgr.listModuleInstancesHttp)
+	apiRoot.GET(api.MODULE_VERSIONS, viewer, ofTenant, mgr.listModuleVersionsHttp)
+	apiRoot.POST(api.MODULE_VERSIONS, operator, ofTenant, mgr.createModuleVersionHttp)
+	apiRoot.POST(api.UPGRADE_MODULE, operator, ofTenant, mgr.upgradeModuleHttp)
+	apiRoot.GET(api.MODULE_HYPERTABLE, viewer, ofTenant, mgr.getModuleHypertableHttp)
+	// The retention policy drops data.
+	apiRoot.POST(api.MODULE_HYPERTABLE, admin, ofTenant, mgr.updateModuleHypertableHttp)
+
+	mgr.registerV2(router)
 
 	router.GET("/swagger/*any", ginswag.WrapHandler(swagfiles.Handler))
 
+	// Exposes the metrics registered to prometheus.DefaultRegisterer, including the ones of the gRPC service and
+	// metadata service running in the same process.
+	if err := prometheus.Register(newModuleInstanceCollector(cfg.ModuleInstance)); err != nil {
+		log.Warnf("Failed to register the collector of module instance metrics, error: %v", err)
+	}
+	router.GET("/metrics", gin.WrapH(promhttp.Handler()))
+
 	log.Infof("Listening on %s ...", cfg.Listen.Addr().String())
 	err = router.RunListener(cfg.Listen)
 	if err != nil {
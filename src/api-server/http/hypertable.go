@@ -0,0 +1,227 @@
+// Copyright (C) 2023  Tricorder Observability
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/tricorder/src/api-server/http/api"
+	"github.com/tricorder/src/api-server/http/dao"
+	pb "github.com/tricorder/src/api-server/pb"
+	"github.com/tricorder/src/pb/module/common"
+	"github.com/tricorder/src/utils/log"
+	"github.com/tricorder/src/utils/pg"
+)
+
+type ModuleHypertableResp struct {
+	HTTPResp
+	Data *common.Hypertable `json:"data"`
+}
+
+// checkHypertable returns an error if the hypertable spec cannot be applied to the data table of the schema.
+// The intervals are checked by the database when the spec is applied.
+func checkHypertable(schemaAttr string, spec *common.Hypertable) error {
+	if len(spec.TimeColumn) == 0 {
+		return errors.New("invalid hypertable, time_column is empty")
+	}
+	if spec.TimeColumn == pg.IngestTimeColumn {
+		return nil
+	}
+	var fields []*common.DataField
+	err := json.Unmarshal([]byte(schemaAttr), &fields)
+	if err != nil {
+		return fmt.Errorf("while checking hypertable, failed to unmarshal schema, error: %v", err)
+	}
+	for _, f := range fields {
+		if f.Name != spec.TimeColumn {
+			continue
+		}
+		if f.Array || f.Type != common.DataField_TIMESTAMPTZ && f.Type != common.DataField_DATE {
+			return fmt.Errorf("invalid hypertable, time_column '%s' has type %s, needs TIMESTAMPTZ or DATE",
+				f.Name, f.Type)
+		}
+		return nil
+	}
+	return fmt.Errorf("invalid hypertable, time_column '%s' is neither a field nor '%s'",
+		spec.TimeColumn, pg.IngestTimeColumn)
+}
+
+// moduleHypertable returns the JSON of the hypertable spec of the module's output schema, or empty if the schema has
+// no hypertable. The returned error has 400 if the spec cannot be applied to the module's data table.
+func moduleHypertable(mod *dao.ModuleGORM, schema *common.Schema) (string, error) {
+	spec := schema.GetHypertable()
+	if spec == nil {
+		return "", nil
+	}
+	err := checkHypertable(mod.SchemaAttr, spec)
+	if err == nil && !writesToPostgres(mod) {
+		err = errors.New("invalid hypertable, the output is not written to Postgres")
+	}
+	if err == nil {
+		err = checkHypertableKeys(schema.PrimaryKey, schema.Indexes, spec)
+	}
+	if err != nil {
+		return "", newStatusError(http.StatusBadRequest, "%v", err)
+	}
+	hypertable, err := json.Marshal(spec)
+	if err != nil {
+		return "", fmt.Errorf("while checking hypertable, failed to marshal hypertable, error: %v", err)
+	}
+	return string(hypertable), nil
+}
+
+// applyHypertable applies the module's hypertable spec, if any, to its data table.
+func (mgr *ModuleManager) applyHypertable(module *dao.ModuleGORM) error {
+	if len(module.Hypertable) == 0 {
+		return nil
+	}
+	spec := new(common.Hypertable)
+	err := json.Unmarshal([]byte(module.Hypertable), spec)
+	if err != nil {
+		return fmt.Errorf("while applying hypertable for module '%s', failed to unmarshal spec, error: %v",
+			module.ID, err)
+	}
+	return mgr.PGClient.ApplyHypertable(getModuleDataTableName(module), spec)
+}
+
+// getModuleHypertableHttp godoc
+// @Summary      Get module hypertable
+// @Description  Get the hypertable spec of the module's data table, data is null if the data table is a plain table
+// @Tags         module
+// @Accept       json
+// @Produce      json
+// @Param			   id	  path		  string	true	"module id"
+// @Success      200  {object}  ModuleHypertableResp
+// @Router       /api/module/{id}/hypertable [get].
+func (mgr *ModuleManager) getModuleHypertableHttp(c *gin.Context) {
+	c.JSON(http.StatusOK, mgr.getModuleHypertable(c.Param(api.MODULE_ID_PARAM)))
+}
+
+func (mgr *ModuleManager) getModuleHypertable(id string) ModuleHypertableResp {
+	module, err := mgr.Module.QueryByID(id)
+	if err != nil {
+		return ModuleHypertableResp{HTTPResp{
+			Code:    500,
+			Message: "Query Error: " + err.Error(),
+		}, nil}
+	}
+	if module == nil {
+		return ModuleHypertableResp{HTTPResp{
+			Code:    404,
+			Message: "module " + id + " does not exist",
+		}, nil}
+	}
+	if len(module.Hypertable) == 0 {
+		return ModuleHypertableResp{HTTPResp{
+			Code:    200,
+			Message: "Success",
+		}, nil}
+	}
+	spec := new(common.Hypertable)
+	err = json.Unmarshal([]byte(module.Hypertable), spec)
+	if err != nil {
+		return ModuleHypertableResp{HTTPResp{
+			Code:    500,
+			Message: "failed to unmarshal hypertable: " + err.Error(),
+		}, nil}
+	}
+	return ModuleHypertableResp{HTTPResp{
+		Code:    200,
+		Message: "Success",
+	}, spec}
+}
+
+// updateModuleHypertableHttp godoc
+// @Summary      Update module hypertable
+// @Description  Set the hypertable spec of the module's data table, which is applied immediately if the module is
+// @Description  deployed, or when the module is deployed. The time column cannot be changed once the hypertable is
+// @Description  created.
+// @Tags         module
+// @Accept       json
+// @Produce      json
+// @Param			   id	  path		  string	true	"module id"
+// @Param			   hypertable	body	common.Hypertable	true	"The hypertable spec"
+// @Success      200  {object}  HTTPResp
+// @Router       /api/module/{id}/hypertable [post].
+func (mgr *ModuleManager) updateModuleHypertableHttp(c *gin.Context) {
+	var body common.Hypertable
+	err := c.ShouldBind(&body)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{"code": "500", "message": "Request Error: " + err.Error()})
+		return
+	}
+	id := c.Param(api.MODULE_ID_PARAM)
+	before := mgr.moduleAuditState(id)
+	result := mgr.updateModuleHypertable(id, &body)
+	mgr.auditModule(c, dao.AuditModuleHypertable, id, before, result)
+	c.JSON(http.StatusOK, result)
+}
+
+func (mgr *ModuleManager) updateModuleHypertable(id string, spec *common.Hypertable) HTTPResp {
+	err := mgr.gLock.ExecWithLock(func() error {
+		module, err := mgr.Module.QueryByID(id)
+		if err != nil {
+			return errors.New("query module error: " + err.Error())
+		}
+		if module == nil {
+			return errors.New("module " + id + " does not exist")
+		}
+		err = checkHypertable(module.SchemaAttr, spec)
+		if err != nil {
+			return err
+		}
+		if !writesToPostgres(module) {
+			return errors.New("invalid hypertable, the output is not written to Postgres")
+		}
+		primaryKey, indexes, err := unmarshalTableKeys(module)
+		if err != nil {
+			return err
+		}
+		err = checkHypertableKeys(primaryKey, indexes, spec)
+		if err != nil {
+			return err
+		}
+		hypertable, err := json.Marshal(spec)
+		if err != nil {
+			return fmt.Errorf("failed to marshal hypertable, error: %v", err)
+		}
+		module.Hypertable = string(hypertable)
+		// The data table of a module that is not deployed might not exist, the spec is applied when it is deployed.
+		if module.DesireState == int(pb.ModuleState_DEPLOYED) {
+			err = mgr.applyHypertable(module)
+			if err != nil {
+				return err
+			}
+		}
+		return mgr.Module.UpdateHypertableByID(id, module.Hypertable)
+	})
+	if err != nil {
+		log.Errorf("Failed to update hypertable of module '%s', error: %v", id, err)
+		return HTTPResp{
+			Code:    500,
+			Message: err.Error(),
+		}
+	}
+	return HTTPResp{
+		Code:    200,
+		Message: "Success",
+	}
+}
@@ -0,0 +1,90 @@
+// Copyright (C) 2023  Tricorder Observability
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package http
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	commonpb "github.com/tricorder/src/pb/module/common"
+)
+
+// Tests that checkHypertable accepts time columns of the time types, and the reserved ingest time column.
+func TestCheckHypertable(t *testing.T) {
+	assert := assert.New(t)
+
+	schema := `[{"name":"data","type":5},{"name":"ts","type":7},{"name":"day","type":1}]`
+	assert.Nil(checkHypertable(schema, &commonpb.Hypertable{TimeColumn: "ts", Retention: "7 days"}))
+	assert.Nil(checkHypertable(schema, &commonpb.Hypertable{TimeColumn: "day"}))
+	assert.Nil(checkHypertable(schema, &commonpb.Hypertable{TimeColumn: "_ingest_time"}))
+	assert.ErrorContains(checkHypertable(schema, &commonpb.Hypertable{}), "time_column is empty")
+	assert.ErrorContains(checkHypertable(schema, &commonpb.Hypertable{TimeColumn: "data"}),
+		"time_column 'data' has type JSONB")
+	assert.ErrorContains(checkHypertable(schema, &commonpb.Hypertable{TimeColumn: "x"}),
+		"time_column 'x' is neither a field nor '_ingest_time'")
+}
+
+// Tests updating and getting the hypertable spec of a module that is not deployed.
+func TestModuleHypertable(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	r := SetUpRouter("")
+	r.GET("/api/module/:id/hypertable", mgr.getModuleHypertableHttp)
+	r.POST("/api/module/:id/hypertable", mgr.updateModuleHypertableHttp)
+
+	moduleID := AddModule(t, "test_wasm_uid", r)
+	path := "/api/module/" + moduleID + "/hypertable"
+
+	get := func() ModuleHypertableResp {
+		req, err := http.NewRequest("GET", path, nil)
+		require.NoError(err)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		var resp ModuleHypertableResp
+		require.NoError(json.Unmarshal(w.Body.Bytes(), &resp))
+		return resp
+	}
+	post := func(body string) string {
+		req, err := http.NewRequest("POST", path, bytes.NewBufferString(body))
+		require.NoError(err)
+		req.Header.Set("Content-Type", "application/json; charset=utf-8")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w.Body.String()
+	}
+
+	resp := get()
+	assert.Equal(200, resp.Code)
+	assert.Nil(resp.Data)
+
+	assert.Contains(post(`{"time_column":"data"}`), "time_column 'data' has type JSONB")
+
+	assert.Equal(`{"code":200,"message":"Success"}`,
+		post(`{"time_column":"_ingest_time","retention":"7 days","compress_after":"1 day"}`))
+	resp = get()
+	assert.Equal(200, resp.Code)
+	require.NotNil(resp.Data)
+	assert.Equal("_ingest_time", resp.Data.TimeColumn)
+	assert.Equal("7 days", resp.Data.Retention)
+	assert.Equal("1 day", resp.Data.CompressAfter)
+}
@@ -0,0 +1,63 @@
+// Copyright (C) 2023  Tricorder Observability
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package http
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/tricorder/src/api-server/http/dao"
+	pb "github.com/tricorder/src/api-server/pb"
+	"github.com/tricorder/src/utils/log"
+)
+
+// moduleInstanceCollector exposes the number of module instances in each state, which are counted from SQLite
+// when the metrics are scraped, so the counts never drift from the stored states.
+type moduleInstanceCollector struct {
+	moduleInstance dao.ModuleInstanceDao
+	desc           *prometheus.Desc
+}
+
+func newModuleInstanceCollector(moduleInstance dao.ModuleInstanceDao) *moduleInstanceCollector {
+	return &moduleInstanceCollector{
+		moduleInstance: moduleInstance,
+		desc: prometheus.NewDesc("starship_api_server_module_instances",
+			"The number of module instances in each state.", []string{"state"}, nil),
+	}
+}
+
+func (c *moduleInstanceCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.desc
+}
+
+func (c *moduleInstanceCollector) Collect(ch chan<- prometheus.Metric) {
+	moduleInstances, err := c.moduleInstance.List("state")
+	if err != nil {
+		log.Errorf("While collecting metrics, failed to list module instances, error: %v", err)
+		ch <- prometheus.NewInvalidMetric(c.desc, err)
+		return
+	}
+	counts := make(map[pb.ModuleInstanceState]int)
+	// All states are exposed, so that a state without module instances is reported as 0, instead of absent.
+	for state := range pb.ModuleInstanceState_name {
+		counts[pb.ModuleInstanceState(state)] = 0
+	}
+	for _, moduleInstance := range moduleInstances {
+		counts[pb.ModuleInstanceState(moduleInstance.State)]++
+	}
+	for state, count := range counts {
+		ch <- prometheus.MustNewConstMetric(c.desc, prometheus.GaugeValue, float64(count), state.String())
+	}
+}
@@ -0,0 +1,56 @@
+// Copyright (C) 2023  Tricorder Observability
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package http
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/tricorder/src/api-server/http/dao"
+	pb "github.com/tricorder/src/api-server/pb"
+	testutils "github.com/tricorder/src/testing/bazel"
+	"github.com/tricorder/src/utils/uuid"
+)
+
+// Tests that moduleInstanceCollector counts the module instances in each state.
+func TestModuleInstanceCollector(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	sqliteClient, err := dao.InitSqlite(testutils.GetTmpFile())
+	require.Nil(err)
+	moduleInstance := dao.ModuleInstanceDao{Client: sqliteClient}
+	for _, state := range []pb.ModuleInstanceState{
+		pb.ModuleInstanceState_SUCCEEDED, pb.ModuleInstanceState_SUCCEEDED, pb.ModuleInstanceState_FAILED,
+	} {
+		require.Nil(moduleInstance.SaveModuleInstance(&dao.ModuleInstanceGORM{ID: uuid.New(), State: int(state)}))
+	}
+
+	expected := `
+# HELP starship_api_server_module_instances The number of module instances in each state.
+# TYPE starship_api_server_module_instances gauge
+starship_api_server_module_instances{state="FAILED"} 1
+starship_api_server_module_instances{state="INIT"} 0
+starship_api_server_module_instances{state="IN_PROGRESS"} 0
+starship_api_server_module_instances{state="REJECTED"} 0
+starship_api_server_module_instances{state="SUCCEEDED"} 2
+`
+	assert.Nil(testutil.CollectAndCompare(newModuleInstanceCollector(moduleInstance), strings.NewReader(expected)))
+}
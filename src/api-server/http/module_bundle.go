@@ -0,0 +1,145 @@
+// Copyright (C) 2023  Tricorder Observability
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package http
+
+import (
+	"bytes"
+	"fmt"
+	"mime"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/tricorder/src/api-server/bundle"
+	"github.com/tricorder/src/api-server/http/api"
+	"github.com/tricorder/src/api-server/http/dao"
+	modulepb "github.com/tricorder/src/pb/module"
+	commonpb "github.com/tricorder/src/pb/module/common"
+)
+
+// exportModuleV2 godoc
+// @Summary      Export module
+// @Description  Export the module as a *.tar.gz bundle, which has the module's manifest, BCC code, WASM binary code,
+// @Description  and the WASM source code if the module was created with it. See /api/v2/modules:import.
+// @Tags         module-v2
+// @Produce      application/gzip
+// @Param        id  path  string  true  "module id"
+// @Success      200  {file}    file
+// @Failure      404  {object}  ErrorResp
+// @Failure      500  {object}  ErrorResp
+// @Router       /api/v2/modules/{id}/bundle [get].
+func (mgr *ModuleManager) exportModuleV2(c *gin.Context) {
+	id := c.Param(api.MODULE_ID_PARAM)
+	module, err := mgr.Module.QueryByID(id)
+	if err != nil {
+		abortWithError(c, http.StatusInternalServerError, "Query Error: "+err.Error())
+		return
+	}
+	if module == nil {
+		abortWithError(c, http.StatusNotFound, "module "+id+" does not exist")
+		return
+	}
+	m, err := moduleBundle(module)
+	if err != nil {
+		abortWithError(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+	var buf bytes.Buffer
+	if err := bundle.Write(&buf, m); err != nil {
+		abortWithError(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+	c.Header("Content-Disposition",
+		mime.FormatMediaType("attachment", map[string]string{"filename": module.Name + ".tar.gz"}))
+	c.Data(http.StatusOK, "application/gzip", buf.Bytes())
+}
+
+// importModuleV2 godoc
+// @Summary      Import module
+// @Description  Create a module from the *.tar.gz bundle in the request body, like the ones exported by
+// @Description  /api/v2/modules/{id}/bundle. The WASM source code, if any, is compiled again instead of using the
+// @Description  WASM binary code. The response's Location header is the path of the created module.
+// @Tags         module-v2
+// @Accept       application/gzip
+// @Produce      json
+// @Param        name    query  string  false  "the name of the created module, the bundle's name if empty"
+// @Param        tenant  query  string  false  "the tenant of the created module, the token's tenant if empty"
+// @Success      201  {object}  ModuleIDResp
+// @Failure      400  {object}  ErrorResp
+// @Failure      404  {object}  ErrorResp
+// @Failure      409  {object}  ErrorResp
+// @Failure      500  {object}  ErrorResp
+// @Router       /api/v2/modules:import [post].
+func (mgr *ModuleManager) importModuleV2(c *gin.Context) {
+	if action := c.Param(api.MODULE_ACTION_PARAM); action != api.ACTION_OP+api.IMPORT_ACTION {
+		abortWithError(c, http.StatusNotFound, fmt.Sprintf("unknown action '%s'", action))
+		return
+	}
+	m, err := bundle.Read(http.MaxBytesReader(c.Writer, c.Request.Body, bundle.MaxSize))
+	if err != nil {
+		abortWithError(c, http.StatusBadRequest, "Request Error: "+err.Error())
+		return
+	}
+	body := importRequest(m)
+	if name := c.Query("name"); len(name) > 0 {
+		body.Name = name
+	}
+	body.Tenant = c.Query("tenant")
+	if len(body.Name) == 0 || body.Wasm.OutputSchema == nil {
+		abortWithError(c, http.StatusBadRequest, "Request Error: name and wasm.output_schema are required")
+		return
+	}
+	if err := resolveTenant(c, &body); err != nil {
+		abortWithError(c, statusCode(err), err.Error())
+		return
+	}
+	resp := mgr.createModule(body)
+	mgr.auditModule(c, dao.AuditModuleCreate, resp.ID, "", resp.HTTPResp)
+	if abortIfFailed(c, resp.HTTPResp) {
+		return
+	}
+	c.Header("Location", api.GetModuleV2Path(resp.ID))
+	c.JSON(http.StatusCreated, ModuleIDResp{ID: resp.ID})
+}
+
+// moduleBundle returns the bundle of the module's current version.
+func moduleBundle(module *dao.ModuleGORM) (*bundle.Module, error) {
+	req, err := moduleRequest(module)
+	if err != nil {
+		return nil, err
+	}
+	m := &bundle.Module{Name: module.Name, Ebpf: req.Ebpf, Wasm: req.Wasm}
+	if commonpb.Format(module.WasmFmt) == commonpb.Format_TEXT {
+		m.WasmSource = req.Wasm.Code
+		m.Wasm.Code = module.Wasm
+	}
+	if len(module.Signature) > 0 {
+		m.Signature = &modulepb.Signature{KeyId: module.SignatureKeyID, Value: module.Signature}
+	}
+	return m, nil
+}
+
+// importRequest returns the request that creates the module in the bundle.
+func importRequest(m *bundle.Module) CreateModuleReq {
+	body := CreateModuleReq{Name: m.Name, Ebpf: m.Ebpf, Wasm: m.Wasm, Signature: m.Signature}
+	if len(m.WasmSource) > 0 {
+		// The source code is kept by the created module, whose compiled code is signed by this API Server.
+		body.Wasm.Code = m.WasmSource
+		body.Wasm.Fmt = commonpb.Format_TEXT
+		body.Signature = nil
+	}
+	return body
+}
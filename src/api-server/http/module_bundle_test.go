@@ -0,0 +1,119 @@
+// Copyright (C) 2023  Tricorder Observability
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package http
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/tricorder/src/api-server/bundle"
+	"github.com/tricorder/src/api-server/http/api"
+	"github.com/tricorder/src/api-server/http/dao"
+	modulepb "github.com/tricorder/src/pb/module"
+	commonpb "github.com/tricorder/src/pb/module/common"
+)
+
+// Tests that the modules exported as bundles are imported as new modules with the same code.
+func TestExportImportModule(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	mgr := newTestModuleManager(t)
+	router := gin.New()
+	mgr.registerV2(router)
+
+	moduleReq := stdoutModuleReq()
+	moduleReq.Wasm.OutputSchema.PrimaryKey = []string{"data"}
+	moduleReq.Signature = &modulepb.Signature{KeyId: "key", Value: []byte("signature")}
+	var idResp ModuleIDResp
+	require.Equal(http.StatusCreated, serveV2(t, router, "POST", api.MODULES_V2_PATH, moduleReq, &idResp))
+	id := idResp.ID
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", api.GetModuleBundleV2Path(id), nil))
+	require.Equal(http.StatusOK, w.Code, w.Body.String())
+	assert.Equal("application/gzip", w.Header().Get("Content-Type"))
+	assert.Equal(`attachment; filename=stdout.tar.gz`, w.Header().Get("Content-Disposition"))
+	exported := w.Body.Bytes()
+	m, err := bundle.Read(bytes.NewReader(exported))
+	require.Nil(err)
+	assert.Equal("stdout", m.Name)
+	assert.Equal("ebpf", m.Ebpf.Code)
+	assert.Equal([]byte("wasm"), m.Wasm.Code)
+	assert.Equal([]string{"data"}, m.Wasm.OutputSchema.PrimaryKey)
+	assert.Equal("key", m.Signature.KeyId)
+	assert.Nil(m.WasmSource)
+
+	var errResp ErrorResp
+	assert.Equal(http.StatusNotFound, serveV2(t, router, "GET", api.GetModuleBundleV2Path("unknown"), nil, &errResp))
+
+	importBundle := func(query string, body []byte, resp any) int {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("POST", api.IMPORT_MODULE_V2_PATH+query, bytes.NewReader(body)))
+		require.Nil(json.Unmarshal(w.Body.Bytes(), resp), w.Body.String())
+		return w.Code
+	}
+	assert.Equal(http.StatusConflict, importBundle("", exported, &errResp))
+	assert.Contains(errResp.Error.Message, "already exists")
+	assert.Equal(http.StatusCreated, importBundle("?name=imported", exported, &idResp))
+	assert.NotEqual(id, idResp.ID)
+	module, err := mgr.Module.QueryByID(idResp.ID)
+	require.Nil(err)
+	assert.Equal("imported", module.Name)
+	assert.Equal("ebpf", module.Ebpf)
+	assert.Equal([]byte("wasm"), module.Wasm)
+	assert.Equal("key", module.SignatureKeyID)
+	assert.Contains(module.EbpfProbes, "do_sys_open")
+
+	assert.Equal(http.StatusBadRequest, importBundle("", []byte("not a bundle"), &errResp))
+	assert.Contains(errResp.Error.Message, "while reading bundle")
+	assert.Equal(http.StatusNotFound,
+		serveV2(t, router, "POST", api.MODULES_V2_PATH+api.ACTION_OP+"unknown", nil, &errResp))
+
+	// The modules created from WASM source code export the source code with the compiled code.
+	textID := "text_module"
+	require.Nil(mgr.Module.SaveModule(&dao.ModuleGORM{
+		ID:         textID,
+		Name:       "text",
+		Ebpf:       "ebpf",
+		EbpfProbes: "[]",
+		WasmCode:   "int fn() { return 0; }",
+		Wasm:       []byte("compiled"),
+		WasmFmt:    int(commonpb.Format_TEXT),
+		WasmLang:   int(commonpb.Lang_C),
+		SchemaAttr: `[{"name":"data","type":6}]`,
+		Fn:         "fn",
+	}))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", api.GetModuleBundleV2Path(textID), nil))
+	require.Equal(http.StatusOK, w.Code, w.Body.String())
+	m, err = bundle.Read(bytes.NewReader(w.Body.Bytes()))
+	require.Nil(err)
+	assert.Equal([]byte("compiled"), m.Wasm.Code)
+	assert.Equal([]byte("int fn() { return 0; }"), m.WasmSource)
+	assert.Equal(commonpb.Lang_C, m.Wasm.Lang)
+	body := importRequest(m)
+	assert.Equal(commonpb.Format_TEXT, body.Wasm.Fmt)
+	assert.Equal([]byte("int fn() { return 0; }"), body.Wasm.Code)
+	assert.Nil(body.Signature)
+}
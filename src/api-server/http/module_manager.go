@@ -16,25 +16,33 @@
 package http
 
 import (
+	"crypto/ed25519"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/gin-gonic/gin"
 
-	"github.com/tricorder/src/utils/cond"
 	"github.com/tricorder/src/utils/lock"
 	"github.com/tricorder/src/utils/log"
 
+	"github.com/tricorder/src/api-server/auth"
+	"github.com/tricorder/src/api-server/catalog"
+	"github.com/tricorder/src/api-server/http/api"
 	"github.com/tricorder/src/api-server/http/dao"
 	"github.com/tricorder/src/api-server/http/grafana"
 	pb "github.com/tricorder/src/api-server/pb"
+	"github.com/tricorder/src/api-server/tenant"
+	"github.com/tricorder/src/api-server/utils/channel"
 	"github.com/tricorder/src/api-server/wasm"
+	modulepb "github.com/tricorder/src/pb/module"
 	commonpb "github.com/tricorder/src/pb/module/common"
 	"github.com/tricorder/src/utils/pg"
+	"github.com/tricorder/src/utils/signing"
 	"github.com/tricorder/src/utils/uuid"
 )
 
@@ -45,11 +53,35 @@ type ModuleManager struct {
 	Module         dao.ModuleDao
 	NodeAgent      dao.NodeAgentDao
 	ModuleInstance dao.ModuleInstanceDao
+	ModuleVersion  dao.ModuleVersionDao
+	Audit          dao.AuditDao
 	GrafanaClient  grafana.GrafanaManagement
 	gLock          *lock.Lock
-	waitCond       *cond.Cond
+	dispatcher     *channel.Dispatcher
 	PGClient       *pg.Client
 	wasiCompiler   *wasm.WASICompiler
+
+	// The IDs of the modules that are being upgraded, see rollingUpgrade().
+	upgrading sync.Map
+	// How long to wait for an agent to report the result of upgrading a module instance, 0 means the default.
+	upgradeInstanceTimeout time.Duration
+
+	// Authenticates the requests, all requests are allowed if nil.
+	authenticator *auth.Authenticator
+
+	// Optional, signs the modules created without signatures.
+	moduleSigningKey ed25519.PrivateKey
+	// Optional, verifies the signatures of the created modules, and rejects the unsigned modules.
+	moduleVerifier *signing.Verifier
+
+	// Optional, the nodes owned by the tenants, the modules of every tenant are deployed onto all nodes if nil.
+	tenants *tenant.Tenants
+
+	// If true, the modules are rejected when their code fails the checks of validating modules, see checkModuleCode().
+	validateModules bool
+
+	// Optional, the modules bundled with API Server, the catalog is empty if nil.
+	catalog *catalog.Catalog
 }
 
 // createModuleHttp  godoc
@@ -68,83 +100,179 @@ func (mgr *ModuleManager) createModuleHttp(c *gin.Context) {
 		c.JSON(http.StatusOK, gin.H{"code": "500", "message": "Request Error: " + err.Error()})
 		return
 	}
+	if err := resolveTenant(c, &body); err != nil {
+		c.JSON(http.StatusOK, HTTPResp{Code: statusCode(err), Message: err.Error()})
+		return
+	}
 	result := mgr.createModule(body)
+	mgr.auditModule(c, dao.AuditModuleCreate, result.ID, "", result.HTTPResp)
 	c.JSON(http.StatusOK, result)
 }
 
 func (mgr *ModuleManager) createModule(body CreateModuleReq) CreateModuleResp {
 	var m *dao.ModuleGORM
-	var err error
+	err := mgr.checkTenant(body.Tenant)
+	if err == nil {
+		// The names are unique in each tenant.
+		err = mgr.gLock.ExecWithLock(func() error {
+			m, _ = mgr.Module.QueryByTenantAndName(body.Tenant, body.Name)
+			if m != nil && len(m.Name) > 0 {
+				return newStatusError(http.StatusConflict, "name '%s' already exists", body.Name)
+			}
+			return nil
+		})
+	}
+
+	if err != nil {
+		return CreateModuleResp{HTTPResp: HTTPResp{
+			Code:    statusCode(err),
+			Message: err.Error(),
+		}}
+	}
+
+	mod, err := mgr.newModuleCode(body)
+	if err != nil {
+		return CreateModuleResp{HTTPResp: HTTPResp{
+			Code:    http.StatusBadRequest,
+			Message: err.Error(),
+		}}
+	}
+
+	// This ID is used in other names like PG table name, 'tricorder-<ID>', to avoid mixing UUID parts with other texts
+	// changes - to _.
+	mod.ID = uuid.NewWithUnderscoreSeparator()
+	mod.Name = body.Name
+	mod.Tenant = body.Tenant
+	mod.CreateTime = time.Now().Format("2006-01-02 15:04:05")
+	mod.DesireState = int(pb.ModuleState_CREATED_)
+	mod.Version = 1
+
+	mod.SchemaName = getModuleDataTableName(mod)
+
+	mod.Hypertable, err = moduleHypertable(mod, body.Wasm.OutputSchema)
+	if err != nil {
+		return CreateModuleResp{HTTPResp: HTTPResp{
+			Code:    statusCode(err),
+			Message: err.Error(),
+		}}
+	}
+
 	err = mgr.gLock.ExecWithLock(func() error {
-		m, _ = mgr.Module.QueryByName(body.Name)
-		if m != nil && len(m.Name) > 0 {
-			return fmt.Errorf("name '%s' already exists", body.Name)
+		err := mgr.Module.SaveModule(mod)
+		if err != nil {
+			return err
 		}
-		return nil
+		return mgr.ModuleVersion.SaveModuleVersion(dao.NewModuleVersion(mod))
 	})
 
 	if err != nil {
-		return CreateModuleResp{HTTPResp{
+		msg := fmt.Sprintf("while creating module, failed to save module ORM object, error: %v", err)
+		log.Errorf(msg)
+		return CreateModuleResp{HTTPResp: HTTPResp{
 			Code:    500,
-			Message: err.Error(),
+			Message: msg,
 		}}
 	}
 
+	return CreateModuleResp{HTTPResp{
+		Code:    200,
+		Message: "create success, module id: " + mod.ID,
+	}, mod.ID}
+}
+
+// newModuleCode returns a module object with the eBPF and WASM code of the request, compiling the WASM code if needed.
+// The returned error message is meant to be returned to the client.
+func (mgr *ModuleManager) newModuleCode(body CreateModuleReq) (*dao.ModuleGORM, error) {
 	ebpfProbes, err := json.Marshal(body.Ebpf.Probes)
 	if err != nil {
 		msg := fmt.Sprintf("while creating module, failed to marshal ebpf probespecs, error: %v", err)
 		log.Errorf(msg)
-		return CreateModuleResp{HTTPResp{
-			Code:    500,
-			Message: msg,
-		}}
+		return nil, errors.New(msg)
 	}
 
 	if len(body.Wasm.OutputSchema.Fields) == 0 {
-		return CreateModuleResp{HTTPResp{
-			Code:    500,
-			Message: "input data fields cannot be empty",
-		}}
+		return nil, errors.New("input data fields cannot be empty")
+	}
+	names := make(map[string]bool, len(body.Wasm.OutputSchema.Fields))
+	for _, f := range body.Wasm.OutputSchema.Fields {
+		// The field names become column names of the data table.
+		err := pg.ValidateIdentifier(f.Name)
+		if err != nil {
+			return nil, fmt.Errorf("invalid input data field name, %v", err)
+		}
+		if pg.IsReservedColumn(f.Name) {
+			return nil, fmt.Errorf("input data field name '%s' is reserved", f.Name)
+		}
+		if names[pg.NormalizeIdentifier(f.Name)] {
+			return nil, fmt.Errorf("input data field name '%s' is duplicated", f.Name)
+		}
+		names[pg.NormalizeIdentifier(f.Name)] = true
+	}
+	err = checkTableKeys(body.Wasm.OutputSchema.Fields, body.Wasm.OutputSchema.PrimaryKey,
+		body.Wasm.OutputSchema.Indexes)
+	if err != nil {
+		return nil, err
+	}
+	var primaryKey, indexes []byte
+	if len(body.Wasm.OutputSchema.PrimaryKey) > 0 {
+		primaryKey, err = json.Marshal(body.Wasm.OutputSchema.PrimaryKey)
+		if err != nil {
+			return nil, fmt.Errorf("while creating module, failed to marshal primary key, error: %v", err)
+		}
+	}
+	if len(body.Wasm.OutputSchema.Indexes) > 0 {
+		indexes, err = json.Marshal(body.Wasm.OutputSchema.Indexes)
+		if err != nil {
+			return nil, fmt.Errorf("while creating module, failed to marshal indexes, error: %v", err)
+		}
+	}
+
+	var sink []byte
+	if err := checkSink(body.Wasm.Sink); err != nil {
+		return nil, err
+	}
+	if err := checkMetricFields(body.Wasm.OutputSchema.Fields, body.Wasm.Sink); err != nil {
+		return nil, err
+	}
+	if body.Wasm.Sink != nil {
+		sink, err = json.Marshal(body.Wasm.Sink)
+		if err != nil {
+			return nil, fmt.Errorf("while creating module, failed to marshal sink, error: %v", err)
+		}
 	}
 
 	schemaAttr, err := json.Marshal(body.Wasm.OutputSchema.Fields)
 	if err != nil {
 		msg := fmt.Sprintf("while creating module, failed to marshal WASM output schema, error: %v", err)
 		log.Errorf(msg)
-		return CreateModuleResp{HTTPResp{
-			Code:    500,
-			Message: msg,
-		}}
+		return nil, errors.New(msg)
 	}
 
 	var wasmCode string
 	if body.Wasm.Fmt == commonpb.Format_TEXT {
+		if body.Signature != nil {
+			// The signature would not match the compiled code.
+			return nil, errors.New("signed module needs binary WASM code, as text code is compiled by API Server")
+		}
 		if body.Wasm.Lang != commonpb.Lang_C {
-			return CreateModuleResp{HTTPResp{
-				Code:    500,
-				Message: "only C language is supported for text format",
-			}}
+			return nil, errors.New("only C language is supported for text format")
 		}
 
 		// Compile WASM module
 		wasmCode = string(body.Wasm.Code)
 		wasmModule, err := mgr.wasiCompiler.BuildC(string(body.Wasm.Code))
 		if err != nil {
-			return CreateModuleResp{HTTPResp{
-				Code:    500,
-				Message: "request error: " + err.Error(),
-			}}
+			return nil, errors.New("request error: " + err.Error())
 		}
 		body.Wasm.Code = wasmModule
 	}
 
+	signature, err := mgr.signModuleCode(body)
+	if err != nil {
+		return nil, err
+	}
+
 	mod := &dao.ModuleGORM{
-		// This ID is used in other names like PG table name, 'tricorder-<ID>', to avoid mixing UUID parts with other texts
-		// changes - to _.
-		ID:                 uuid.NewWithUnderscoreSeparator(),
-		Name:               body.Name,
-		CreateTime:         time.Now().Format("2006-01-02 15:04:05"),
-		DesireState:        int(pb.ModuleState_CREATED_),
 		Ebpf:               body.Ebpf.Code,
 		EbpfFmt:            int(body.Ebpf.Fmt),
 		EbpfLang:           int(body.Ebpf.Lang),
@@ -156,27 +284,34 @@ func (mgr *ModuleManager) createModule(body CreateModuleReq) CreateModuleResp {
 		Fn:                 body.Wasm.FnName,
 		WasmFmt:            int(body.Wasm.Fmt),
 		WasmLang:           int(body.Wasm.Lang),
+		PrimaryKey:         string(primaryKey),
+		Indexes:            string(indexes),
+		Sink:               string(sink),
+		SignatureKeyID:     signature.GetKeyId(),
+		Signature:          signature.GetValue(),
 	}
-
-	mod.SchemaName = fmt.Sprintf("%s_%s", "tricorder_module", mod.ID)
-
-	err = mgr.gLock.ExecWithLock(func() error {
-		return mgr.Module.SaveModule(mod)
-	})
-
-	if err != nil {
-		msg := fmt.Sprintf("while creating module, failed to save module ORM object, error: %v", err)
-		log.Errorf(msg)
-		return CreateModuleResp{HTTPResp{
-			Code:    500,
-			Message: msg,
-		}}
+	if mgr.validateModules {
+		if err := checkModuleCode(body.Ebpf, mod); err != nil {
+			return nil, err
+		}
 	}
+	return mod, nil
+}
 
-	return CreateModuleResp{HTTPResp{
-		Code:    200,
-		Message: "create success, module id: " + mod.ID,
-	}}
+// signModuleCode returns the signature of the module's code, which is the request's signature, or signed by API
+// Server's signing key if the request has no signature. The signature is verified if API Server has trusted keys.
+// Returns nil if the module is not signed, and is allowed to be unsigned.
+func (mgr *ModuleManager) signModuleCode(body CreateModuleReq) (*modulepb.Signature, error) {
+	m := &modulepb.Module{Ebpf: body.Ebpf, Wasm: body.Wasm, Signature: body.Signature}
+	if m.Signature == nil && mgr.moduleSigningKey != nil {
+		m.Signature = signing.Sign(m, mgr.moduleSigningKey)
+	}
+	if mgr.moduleVerifier != nil {
+		if err := mgr.moduleVerifier.Verify(m); err != nil {
+			return nil, err
+		}
+	}
+	return m.Signature, nil
 }
 
 // listAgentHttp godoc
@@ -224,24 +359,32 @@ func (mgr *ModuleManager) listAgent(req ListAgentReq) ListAgentResp {
 // @Accept       json
 // @Produce      json
 // @Param			   fields	 query	string	false  "query field search like 'id,name,createTime'"
+// @Param			   tenant	 query	string	false  "only list the modules of this tenant, ignored for tenants' tokens"
 // @Success      200  {object}  ListModuleResp
 // @Router       /api/listModule [get].
 func (mgr *ModuleManager) listModuleHttp(c *gin.Context) {
 	// Allow fields to be omitted.
 	const fieldsKey = "fields"
-	const defaultFields = "id,name,desire_state,create_time,schema_attr,fn,ebpf"
+	const defaultFields = "id,name,tenant,desire_state,create_time,schema_attr,fn,ebpf"
 	fields, exists := c.GetQuery(fieldsKey)
 	if !exists {
 		log.Debugf("listModule request has no 'fields', use default fields: %s", defaultFields)
 		fields = defaultFields
 	}
-	result := mgr.listModule(ListModuleReq{Fields: fields})
+	result := mgr.listModule(ListModuleReq{Fields: fields}, tenantFilter(c))
 	c.JSON(http.StatusOK, result)
 }
 
-func (mgr *ModuleManager) listModule(req ListModuleReq) ListModuleResp {
+// listModule returns the modules of the tenant, or of all tenants if tenantName is nil.
+func (mgr *ModuleManager) listModule(req ListModuleReq, tenantName *string) ListModuleResp {
 	fields := strings.Split(req.Fields, ",")
-	resultList, err := mgr.Module.ListModule(fields)
+	var resultList []dao.ModuleGORM
+	var err error
+	if tenantName != nil {
+		resultList, err = mgr.Module.ListModuleOfTenant(fields, *tenantName)
+	} else {
+		resultList, err = mgr.Module.ListModule(fields)
+	}
 	if err != nil {
 		return ListModuleResp{HTTPResp{
 			Code:    500,
@@ -255,6 +398,49 @@ func (mgr *ModuleManager) listModule(req ListModuleReq) ListModuleResp {
 	}, resultList}
 }
 
+// listModuleInstancesHttp godoc
+// @Summary      List module instances
+// @Description  List the instances of the specified module, one per agent, with their deployment states
+// @Tags         module
+// @Accept       json
+// @Produce      json
+// @Param			   id	  path		  string	true	"module id"
+// @Success      200  {object}  ListModuleInstanceResp
+// @Router       /api/module/{id}/instances [get].
+func (mgr *ModuleManager) listModuleInstancesHttp(c *gin.Context) {
+	id := c.Param(api.MODULE_ID_PARAM)
+	c.JSON(http.StatusOK, mgr.listModuleInstances(id))
+}
+
+func (mgr *ModuleManager) listModuleInstances(id string) ListModuleInstanceResp {
+	module, err := mgr.Module.QueryByID(id)
+	if err != nil {
+		return ListModuleInstanceResp{HTTPResp{
+			Code:    500,
+			Message: "Query Error: " + err.Error(),
+		}, nil}
+	}
+	if module == nil {
+		return ListModuleInstanceResp{HTTPResp{
+			Code:    404,
+			Message: "module " + id + " does not exist",
+		}, nil}
+	}
+
+	resultList, err := mgr.ModuleInstance.ListByModuleID(id)
+	if err != nil {
+		return ListModuleInstanceResp{HTTPResp{
+			Code:    500,
+			Message: "Query Error: " + err.Error(),
+		}, nil}
+	}
+
+	return ListModuleInstanceResp{HTTPResp{
+		Code:    200,
+		Message: "Success",
+	}, resultList}
+}
+
 // deleteModuleHttp  godoc
 // @Summary      Delete module
 // @Description  Delete module by id
@@ -269,7 +455,10 @@ func (mgr *ModuleManager) deleteModuleHttp(c *gin.Context) {
 	if err != nil {
 		return
 	}
-	c.JSON(http.StatusOK, mgr.deleteModule(id))
+	before := mgr.moduleAuditState(id)
+	result := mgr.deleteModule(id)
+	mgr.auditModule(c, dao.AuditModuleDelete, id, before, result.HTTPResp)
+	c.JSON(http.StatusOK, result)
 }
 
 func (mgr *ModuleManager) deleteModule(id string) DeleteModuleResp {
@@ -278,13 +467,20 @@ func (mgr *ModuleManager) deleteModule(id string) DeleteModuleResp {
 		if err != nil {
 			return errors.New("query module: " + id + "failed: " + err.Error())
 		}
+		if module == nil {
+			return newStatusError(http.StatusNotFound, "module %s does not exist", id)
+		}
 		if module.DesireState == int(pb.ModuleState_DEPLOYED) {
-			return errors.New("module: " + id + "is deployed, please undeploy first")
+			return newStatusError(http.StatusConflict, "module %s is deployed, please undeploy first", id)
 		}
 		err = mgr.Module.DeleteByID(id)
 		if err != nil {
 			return errors.New("delete module: " + id + "failed: " + err.Error())
 		}
+		err = mgr.ModuleVersion.DeleteByModuleID(id)
+		if err != nil {
+			return errors.New("delete versions of module: " + id + "failed: " + err.Error())
+		}
 		moduleInstances, err := mgr.ModuleInstance.ListByModuleID(id)
 		if err != nil {
 			return errors.New("list module instance by module id: " + id + "failed: " + err.Error())
@@ -300,7 +496,7 @@ func (mgr *ModuleManager) deleteModule(id string) DeleteModuleResp {
 	})
 	if err != nil {
 		return DeleteModuleResp{HTTPResp{
-			Code:    500,
+			Code:    statusCode(err),
 			Message: err.Error(),
 		}}
 	}
@@ -318,6 +514,7 @@ func (mgr *ModuleManager) deleteModule(id string) DeleteModuleResp {
 // @Accept       json
 // @Produce      json
 // @Param			   id	  query		  string	true	"deploy module id"
+// @Param			   force	  query		  bool	false	"apply destructive changes to the module's data table, like dropping columns"
 // @Success      200  {object}  DeployModuleResp
 // @Router       /api/deployModule [post].
 func (mgr *ModuleManager) deployModuleHttp(c *gin.Context) {
@@ -325,11 +522,13 @@ func (mgr *ModuleManager) deployModuleHttp(c *gin.Context) {
 	if err != nil {
 		return
 	}
-	result := mgr.deployModule(id)
+	before := mgr.moduleAuditState(id)
+	result := mgr.deployModule(id, c.Query("force") == "true")
+	mgr.auditModule(c, dao.AuditModuleDeploy, id, before, result.HTTPResp)
 	c.JSON(http.StatusOK, result)
 }
 
-func (mgr *ModuleManager) deployModule(id string) DeployModuleResp {
+func (mgr *ModuleManager) deployModule(id string, force bool) DeployModuleResp {
 	var module *dao.ModuleGORM
 	var err error
 	// Check whether the module exists
@@ -338,16 +537,25 @@ func (mgr *ModuleManager) deployModule(id string) DeployModuleResp {
 		if err != nil {
 			return errors.New("query module error: " + err.Error())
 		}
+		if module == nil {
+			return newStatusError(http.StatusNotFound, "module %s does not exist", id)
+		}
 		if module.DesireState == int(pb.ModuleState_DEPLOYED) {
 			log.Infof("module %s already deployed", id)
-			return errors.New("module " + id + " already deployed")
+			return newStatusError(http.StatusConflict, "module %s already deployed", id)
+		}
+		if !mgr.tenants.OwnsAnyNode(module.Tenant) {
+			return newStatusError(http.StatusConflict, "tenant '%s' of module %s owns no nodes", module.Tenant, id)
+		}
+		if mgr.isUpgrading(id) {
+			return newStatusError(http.StatusConflict, "module %s is being upgraded", id)
 		}
 		isProgress, err := mgr.ModuleInstance.CheckModuleInProgress(id)
 		if err != nil {
 			return errors.New("check module " + id + " in progress state error: " + err.Error())
 		}
 		if isProgress {
-			return errors.New("module " + id + " is in progress state")
+			return newStatusError(http.StatusConflict, "module %s is in progress state", id)
 		}
 		return nil
 	})
@@ -355,41 +563,58 @@ func (mgr *ModuleManager) deployModule(id string) DeployModuleResp {
 	if err != nil {
 		return DeployModuleResp{
 			HTTPResp{
-				Code:    500,
+				Code:    statusCode(err),
 				Message: err.Error(),
 			},
 			id,
 		}
 	}
 
-	err = mgr.createPGTable(module)
-	if err != nil {
-		log.Error("Failed to create PG table")
-		return DeployModuleResp{
-			HTTPResp{
-				Code:    500,
-				Message: "create schema error: " + err.Error(),
-			},
-			"",
+	// The output written to other sinks has no data table, nor a dashboard on it.
+	var uid string
+	if writesToPostgres(module) {
+		err = mgr.createPGTable(module, force)
+		if err != nil {
+			log.Error("Failed to create PG table")
+			return DeployModuleResp{
+				HTTPResp{
+					Code:    500,
+					Message: "create schema error: " + err.Error(),
+				},
+				"",
+			}
 		}
-	}
-	log.Info("Created postgres table")
+		log.Info("Created postgres table")
 
-	uid, err := mgr.createGrafanaDashboard(module.ID)
-	if err != nil {
-		log.Error("Failed to create Grafana dashboard")
+		err = mgr.applyHypertable(module)
+		if err != nil {
+			log.Error("Failed to apply hypertable spec")
+			return DeployModuleResp{
+				HTTPResp{
+					Code:    500,
+					Message: "create schema error: " + err.Error(),
+				},
+				"",
+			}
+		}
 
-		return DeployModuleResp{
-			HTTPResp{
-				Code:    500,
-				Message: fmt.Sprintf("failed to create dashboard, error: %v", err),
-			},
-			uid,
+		uid, err = mgr.createGrafanaDashboard(module)
+		if err != nil {
+			log.Error("Failed to create Grafana dashboard")
+
+			return DeployModuleResp{
+				HTTPResp{
+					Code:    500,
+					Message: fmt.Sprintf("failed to create dashboard, error: %v", err),
+				},
+				uid,
+			}
 		}
-	}
 
-	log.Infof("Created Grafana dashboard with UID: %s", uid)
+		log.Infof("Created Grafana dashboard with UID: %s", uid)
+	}
 
+	var moduleInstances []*dao.ModuleInstanceGORM
 	err = mgr.gLock.ExecWithLock(func() error {
 		err = mgr.Module.UpdateStatusByID(module.ID, int(pb.ModuleState_DEPLOYED))
 		if err != nil {
@@ -402,11 +627,11 @@ func (mgr *ModuleManager) deployModule(id string) DeployModuleResp {
 		}
 
 		for _, agent := range nodeAgents {
-			if agent.State != int(pb.AgentState_ONLINE) {
+			if !mgr.deploysOnto(module.Tenant, &agent) {
 				continue
 			}
 
-			err = mgr.ModuleInstance.SaveModuleInstance(&dao.ModuleInstanceGORM{
+			moduleInstance := &dao.ModuleInstanceGORM{
 				ID:          fmt.Sprintf("tricorder_%s_%s", module.ID, agent.AgentID),
 				ModuleID:    module.ID,
 				ModuleName:  module.Name,
@@ -414,10 +639,13 @@ func (mgr *ModuleManager) deployModule(id string) DeployModuleResp {
 				NodeName:    agent.NodeName,
 				DesireState: int(pb.ModuleState_DEPLOYED),
 				State:       int(pb.ModuleInstanceState_INIT),
-			})
+				Version:     module.Version,
+			}
+			err = mgr.ModuleInstance.SaveModuleInstance(moduleInstance)
 			if err != nil {
 				log.Fatalf("insert module %s instance to agent %s failed: %s", module.ID, agent.AgentID, err.Error())
 			}
+			moduleInstances = append(moduleInstances, moduleInstance)
 		}
 		return nil
 	})
@@ -432,7 +660,7 @@ func (mgr *ModuleManager) deployModule(id string) DeployModuleResp {
 		}
 	}
 
-	mgr.waitCond.Broadcast()
+	mgr.dispatchModuleInstances(moduleInstances)
 
 	return DeployModuleResp{
 		HTTPResp{
@@ -457,27 +685,42 @@ func (mgr *ModuleManager) undeployModuleHttp(c *gin.Context) {
 	if err != nil {
 		return
 	}
-	c.JSON(http.StatusOK, mgr.undeployModule(id))
+	before := mgr.moduleAuditState(id)
+	result := mgr.undeployModule(id)
+	mgr.auditModule(c, dao.AuditModuleUndeploy, id, before, result.HTTPResp)
+	c.JSON(http.StatusOK, result)
 }
 
 func (mgr *ModuleManager) undeployModule(id string) UndeployModuleResp {
+	var moduleInstances []*dao.ModuleInstanceGORM
 	err := mgr.gLock.ExecWithLock(func() error {
+		module, err := mgr.Module.QueryByID(id)
+		if err != nil {
+			return errors.New("query module error: " + err.Error())
+		}
+		if module == nil {
+			return newStatusError(http.StatusNotFound, "module %s does not exist", id)
+		}
+		if mgr.isUpgrading(id) {
+			return newStatusError(http.StatusConflict, "module %s is being upgraded", id)
+		}
 		isProgress, err := mgr.ModuleInstance.CheckModuleInProgress(id)
 		if err != nil {
 			return errors.New("check module " + id + " in progress state error: " + err.Error())
 		}
 		if isProgress {
-			return errors.New("module " + id + " is in progress state")
+			return newStatusError(http.StatusConflict, "module %s is in progress state", id)
 		}
 		err = mgr.Module.UpdateStatusByID(id, int(pb.ModuleState_UNDEPLOYED))
 		if err != nil {
 			return errors.New("un-deploy module: " + id + "failed: " + err.Error())
 		}
-		moduleInstances, err := mgr.ModuleInstance.ListByModuleID(id)
+		instances, err := mgr.ModuleInstance.ListByModuleID(id)
 		if err != nil {
 			return errors.New("list module instance module id " + id + " error: " + err.Error())
 		}
-		for _, moduleInstance := range moduleInstances {
+		for i := range instances {
+			moduleInstance := &instances[i]
 			err = mgr.ModuleInstance.UpdateDesireStateByID(moduleInstance.ID, int(pb.ModuleState_UNDEPLOYED))
 			if err != nil {
 				return errors.New("update module instance module id " + id + " desire state error: " + err.Error())
@@ -487,65 +730,98 @@ func (mgr *ModuleManager) undeployModule(id string) UndeployModuleResp {
 			if err != nil {
 				return errors.New("update module instance module id " + id + " state error: " + err.Error())
 			}
+			moduleInstances = append(moduleInstances, moduleInstance)
 		}
 		return nil
 	})
 	if err != nil {
 		return UndeployModuleResp{HTTPResp{
-			Code:    500,
+			Code:    statusCode(err),
 			Message: err.Error(),
 		}}
 	}
-	mgr.waitCond.Broadcast()
+	mgr.dispatchModuleInstances(moduleInstances)
 	return UndeployModuleResp{HTTPResp{
 		Code:    200,
 		Message: "un-deploy success",
 	}}
 }
 
-// Generate schema name tricorder_module_{moduleID}.
-func getModuleDataTableName(id string) string {
-	const moduleDataTableNamePrefix = "tricorder_module_"
-	return moduleDataTableNamePrefix + id
+// dispatchModuleInstances notifies the agents of the module instances that they need to process.
+// Agents that are not connected pick up their module instances when they connect.
+func (mgr *ModuleManager) dispatchModuleInstances(moduleInstances []*dao.ModuleInstanceGORM) {
+	for _, moduleInstance := range moduleInstances {
+		if !mgr.dispatcher.Dispatch(moduleInstance.AgentID, moduleInstance.ID) {
+			log.Debugf("Module instance '%s' was not dispatched to agent '%s', it will be processed on next resync",
+				moduleInstance.ID, moduleInstance.AgentID)
+		}
+	}
 }
 
 // createPGTable creates a data table on the database that stores observability data.
 // Agents can then write the data produced by the deployed eBPF+WASM module to this table.
-func (mgr *ModuleManager) createPGTable(module *dao.ModuleGORM) error {
-	var fields []*commonpb.DataField
-	err := json.Unmarshal([]byte(module.SchemaAttr), &fields)
+// If the table already exists, it is migrated to match the module's schema, the changes that might lose data,
+// like dropping columns, are applied only if force is true.
+func (mgr *ModuleManager) createPGTable(module *dao.ModuleGORM, force bool) error {
+	return mgr.migratePGTable(module, pg.MigrateOptions{Force: force})
+}
+
+// migratePGTable creates or migrates the data table of the module to match the module's schema, primary key and
+// indexes.
+func (mgr *ModuleManager) migratePGTable(module *dao.ModuleGORM, opts pg.MigrateOptions) error {
+	schema, err := moduleDataSchema(module)
 	if err != nil {
-		return fmt.Errorf("while creating output data table for module '%s', "+
-			"failed to unmarshal column schemas, error: %v", module.Name, err)
+		return fmt.Errorf("while migrating output data table for module '%s', error: %v", module.ID, err)
 	}
-	if len(fields) == 0 {
-		return fmt.Errorf("module data fields cannot be empty")
-	}
-	columns, err := DataFieldsToPGColumns(fields)
+	migration, err := mgr.PGClient.MigrateTable(schema, opts)
 	if err != nil {
-		return err
+		return fmt.Errorf("while migrating output data table for module '%s', error: %v", module.ID, err)
 	}
-	schema := pg.Schema{
-		Name:    getModuleDataTableName(module.ID),
-		Columns: columns,
+	for _, stmt := range migration.Statements {
+		log.Infof("Migrated output data table for module '%s': %s", module.ID, stmt)
 	}
-	err = mgr.PGClient.CreateTable(&schema)
+	return nil
+}
+
+// moduleDataColumns returns the columns of the module's data table, as declared by the module's output schema.
+func moduleDataColumns(schemaAttr string) ([]pg.Column, error) {
+	var fields []*commonpb.DataField
+	err := json.Unmarshal([]byte(schemaAttr), &fields)
 	if err != nil {
-		return fmt.Errorf("while creating output data table for module '%s', "+
-			"failed to create the table, error: %v", schema.Name, err)
+		return nil, fmt.Errorf("failed to unmarshal column schemas, error: %v", err)
 	}
-	return nil
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("module data fields cannot be empty")
+	}
+	return DataFieldsToPGColumns(fields)
 }
 
-func (mgr *ModuleManager) createGrafanaDashboard(moduleID string) (string, error) {
+// createGrafanaDashboard creates a dashboard with panels chosen by the types of the module's output fields.
+func (mgr *ModuleManager) createGrafanaDashboard(module *dao.ModuleGORM) (string, error) {
+	columns, err := moduleDataColumns(module.SchemaAttr)
+	if err != nil {
+		return "", err
+	}
+
 	grafanaAPIKey, err := mgr.GrafanaClient.GetGrafanaKey(grafana.DashboardAPIURL)
 	if err != nil {
 		log.Println("deploy error, auth dashboary error", err)
 		return "", err
 	}
 
+	// The dashboards of a tenant's modules are grouped in the tenant's folder.
+	var folderUID string
+	if len(module.Tenant) > 0 {
+		folderUID = tenantFolderUID(module.Tenant)
+		err = grafana.NewFolder(mgr.grafanaConfig).EnsureFolder(grafanaAPIKey, folderUID, module.Tenant)
+		if err != nil {
+			return "", err
+		}
+	}
+
 	ds := grafana.NewDashboard(mgr.grafanaConfig)
-	result, err := ds.CreateDashboard(grafanaAPIKey, getModuleDataTableName(moduleID), mgr.DatasourceUID)
+	result, err := ds.CreateDashboardInFolder(grafanaAPIKey, getModuleDataTableName(module), mgr.DatasourceUID, folderUID,
+		columns)
 	if err != nil {
 		log.Println("Create dashboard", err)
 		return "", err
@@ -17,6 +17,8 @@ package http
 
 import (
 	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
 	"encoding/json"
 	"fmt"
 	"net/http"
@@ -31,11 +33,16 @@ import (
 	"github.com/tricorder/src/api-server/http/dao"
 	"github.com/tricorder/src/api-server/http/grafana"
 	pb "github.com/tricorder/src/api-server/pb"
+	"github.com/tricorder/src/api-server/utils/channel"
+	modulepb "github.com/tricorder/src/pb/module"
+	commonpb "github.com/tricorder/src/pb/module/common"
+	ebpfpb "github.com/tricorder/src/pb/module/ebpf"
+	wasmpb "github.com/tricorder/src/pb/module/wasm"
 	testutils "github.com/tricorder/src/testing/bazel"
 	grafanatest "github.com/tricorder/src/testing/grafana"
 	pgclienttest "github.com/tricorder/src/testing/pg"
-	"github.com/tricorder/src/utils/cond"
 	"github.com/tricorder/src/utils/lock"
+	"github.com/tricorder/src/utils/signing"
 	"github.com/tricorder/src/utils/uuid"
 )
 
@@ -66,7 +73,15 @@ func SetUpRouter(grafanaURL string) *gin.Engine {
 		Client: sqliteClient,
 	}
 
-	mgr.waitCond = cond.NewCond()
+	mgr.ModuleVersion = dao.ModuleVersionDao{
+		Client: sqliteClient,
+	}
+
+	mgr.Audit = dao.AuditDao{
+		Client: sqliteClient,
+	}
+
+	mgr.dispatcher = channel.NewDispatcher()
 	mgr.gLock = lock.NewLock()
 
 	mgr.GrafanaClient = grafana.NewGrafanaManagement(config)
@@ -74,6 +89,43 @@ func SetUpRouter(grafanaURL string) *gin.Engine {
 	return router
 }
 
+// newTestModuleManager returns a ModuleManager backed by a new SQLite database, without Postgres or Grafana.
+func newTestModuleManager(t *testing.T) *ModuleManager {
+	sqliteClient, err := dao.InitSqlite(testutils.GetTmpFile())
+	require.Nil(t, err)
+	daos := dao.NewDao(sqliteClient)
+	return &ModuleManager{
+		Module:         daos.Module,
+		NodeAgent:      daos.NodeAgent,
+		ModuleInstance: daos.ModuleInstance,
+		ModuleVersion:  daos.ModuleVersion,
+		Audit:          daos.Audit,
+		gLock:          lock.NewLock(),
+		dispatcher:     channel.NewDispatcher(),
+	}
+}
+
+// stdoutModuleReq returns the request to create a module that writes to STDOUT.
+func stdoutModuleReq() CreateModuleReq {
+	return CreateModuleReq{
+		Name: "stdout",
+		Wasm: &wasmpb.Program{
+			Fmt:    commonpb.Format_BINARY,
+			Code:   []byte("wasm"),
+			FnName: "fn",
+			OutputSchema: &commonpb.Schema{
+				Fields: []*commonpb.DataField{{Name: "data", Type: commonpb.DataField_JSONB}},
+			},
+			Sink: &commonpb.Sink{Type: commonpb.Sink_STDOUT},
+		},
+		Ebpf: &ebpfpb.Program{
+			Code:           "ebpf",
+			PerfBufferName: "events",
+			Probes:         []*ebpfpb.ProbeSpec{{Type: ebpfpb.ProbeSpec_KPROBE, Target: "do_sys_open", Entry: "entry"}},
+		},
+	}
+}
+
 // test upload wasm file and create wasm uid.
 func TestModuleManager(t *testing.T) {
 	assert := assert.New(t)
@@ -255,7 +307,137 @@ func TestCreateModuleEmptyDataFields(t *testing.T) {
 	w := httptest.NewRecorder()
 	r.ServeHTTP(w, req)
 
-	assert.Equal(`{"code":500,"message":"input data fields cannot be empty"}`, w.Body.String())
+	assert.Equal(`{"code":400,"message":"input data fields cannot be empty"}`, w.Body.String())
+}
+
+// Tests that createModuleHttp failed if an input data field uses a reserved column name.
+func TestCreateModuleReservedDataFields(t *testing.T) {
+	assert := assert.New(t)
+
+	moduleBody := `{
+		"name": "test_module",
+		"wasm":{
+			"code": "",
+			"fn_name":"copy_input_to_output",
+			"fmt":    1,
+			"output_schema":{
+				"name":"test_tabel_name",
+				"fields":[{"name":"_node_name","type":6}]
+			}
+		},
+		"ebpf":{
+			"code": "",
+			"perf_buffer_name":"events",
+			"probes":[{"target":"","entry":"sample_json","return":""}]
+		}
+	}`
+
+	r := SetUpRouter("")
+
+	r.POST("/api/createModule", mgr.createModuleHttp)
+	req, _ := http.NewRequest("POST", "/api/createModule", bytes.NewBufferString(moduleBody))
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(`{"code":400,"message":"input data field name '_node_name' is reserved"}`, w.Body.String())
+}
+
+// Tests that the field names that are not safe as column names are rejected.
+func TestNewModuleCodeHostileFieldNames(t *testing.T) {
+	assert := assert.New(t)
+
+	newReq := func(names ...string) CreateModuleReq {
+		var fields []*commonpb.DataField
+		for _, name := range names {
+			fields = append(fields, &commonpb.DataField{Name: name, Type: commonpb.DataField_TEXT})
+		}
+		return CreateModuleReq{
+			Name: "test_module",
+			Wasm: &wasmpb.Program{Fmt: commonpb.Format_BINARY, OutputSchema: &commonpb.Schema{Fields: fields}},
+			Ebpf: &ebpfpb.Program{},
+		}
+	}
+
+	_, err := mgr.newModuleCode(newReq("pid", "User", "order"))
+	assert.Nil(err)
+
+	for _, name := range []string{
+		"data TEXT); DROP TABLE module; --",
+		`a"b`,
+		"a'b",
+		"a b",
+		"1st",
+		"",
+		strings.Repeat("a", 64),
+	} {
+		_, err := mgr.newModuleCode(newReq(name))
+		assert.ErrorContains(err, "invalid input data field name", name)
+	}
+
+	_, err = mgr.newModuleCode(newReq("pid", "PID"))
+	assert.ErrorContains(err, "input data field name 'PID' is duplicated")
+}
+
+// Tests that the modules are signed by API Server's signing key if the requests are not signed, and the signatures
+// are verified by API Server's trusted keys.
+func TestNewModuleCodeSignature(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	serverPublicKey, serverKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(err)
+	clientPublicKey, clientKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(err)
+
+	newReq := func() CreateModuleReq {
+		return CreateModuleReq{
+			Name: "test_module",
+			Wasm: &wasmpb.Program{
+				Fmt:          commonpb.Format_BINARY,
+				Code:         []byte{0x00, 0x61, 0x73, 0x6d},
+				OutputSchema: &commonpb.Schema{Fields: []*commonpb.DataField{{Name: "data", Type: commonpb.DataField_TEXT}}},
+			},
+			Ebpf: &ebpfpb.Program{Code: "int probe() { return 0; }"},
+		}
+	}
+	signedReq := func() CreateModuleReq {
+		req := newReq()
+		req.Signature = signing.Sign(&modulepb.Module{Ebpf: req.Ebpf, Wasm: req.Wasm}, clientKey)
+		return req
+	}
+
+	code, err := mgr.newModuleCode(newReq())
+	require.NoError(err)
+	assert.Empty(code.SignatureKeyID)
+	assert.Empty(code.Signature)
+
+	signingMgr := ModuleManager{moduleSigningKey: serverKey}
+	code, err = signingMgr.newModuleCode(newReq())
+	require.NoError(err)
+	assert.Equal(signing.KeyID(serverPublicKey), code.SignatureKeyID)
+	// The client's signature is kept.
+	code, err = signingMgr.newModuleCode(signedReq())
+	require.NoError(err)
+	assert.Equal(signing.KeyID(clientPublicKey), code.SignatureKeyID)
+
+	verifyingMgr := ModuleManager{moduleVerifier: signing.NewVerifier(clientPublicKey)}
+	code, err = verifyingMgr.newModuleCode(signedReq())
+	require.NoError(err)
+	assert.Equal(signing.KeyID(clientPublicKey), code.SignatureKeyID)
+	_, err = verifyingMgr.newModuleCode(newReq())
+	assert.ErrorContains(err, "not signed")
+	tampered := signedReq()
+	tampered.Ebpf.Code += " "
+	_, err = verifyingMgr.newModuleCode(tampered)
+	assert.ErrorContains(err, "does not match")
+
+	text := signedReq()
+	text.Wasm.Fmt = commonpb.Format_TEXT
+	text.Wasm.Lang = commonpb.Lang_C
+	_, err = mgr.newModuleCode(text)
+	assert.ErrorContains(err, "signed module needs binary WASM code")
 }
 
 func deleteModule(t *testing.T, moduleID string, r *gin.Engine) {
@@ -315,3 +497,53 @@ func listAgent(t *testing.T, agentID string, r *gin.Engine) {
 	// TODO(jun): do not using t *testing.T in test helper, need to refactor this test for better readability
 	assert.Contains(resultStr, agentID)
 }
+
+// Tests that listModuleInstancesHttp returns the instances of the module, and 404 for unknown modules.
+func TestListModuleInstances(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	r := SetUpRouter("")
+	r.GET("/api/module/:id/instances", mgr.listModuleInstancesHttp)
+
+	moduleID := AddModule(t, "test_wasm_uid", r)
+	agentID, err := AddAgent(t, r)
+	require.NoError(err)
+
+	moduleInstance := &dao.ModuleInstanceGORM{
+		ID:          fmt.Sprintf("tricorder_%s_%s", moduleID, agentID),
+		ModuleID:    moduleID,
+		AgentID:     agentID,
+		NodeName:    "test_node_agent",
+		DesireState: int(pb.ModuleState_DEPLOYED),
+		State:       int(pb.ModuleInstanceState_INIT),
+	}
+	require.NoError(mgr.ModuleInstance.SaveModuleInstance(moduleInstance))
+	require.NoError(mgr.ModuleInstance.UpdateStatusAndDescByID(moduleInstance.ID,
+		int(pb.ModuleInstanceState_FAILED), "failed to attach probe"))
+
+	req, err := http.NewRequest("GET", "/api/module/"+moduleID+"/instances", nil)
+	require.NoError(err)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	var resp ListModuleInstanceResp
+	require.NoError(json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(200, resp.Code)
+	require.Equal(1, len(resp.Data))
+	assert.Equal(agentID, resp.Data[0].AgentID)
+	assert.Equal("test_node_agent", resp.Data[0].NodeName)
+	assert.Equal(int(pb.ModuleInstanceState_FAILED), resp.Data[0].State)
+	assert.Equal(int(pb.ModuleState_DEPLOYED), resp.Data[0].DesireState)
+	assert.Equal("failed to attach probe", resp.Data[0].StateDesc)
+	assert.NotNil(resp.Data[0].LastUpdateTime)
+
+	req, err = http.NewRequest("GET", "/api/module/non_existent/instances", nil)
+	require.NoError(err)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	require.NoError(json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(404, resp.Code)
+	assert.Empty(resp.Data)
+}
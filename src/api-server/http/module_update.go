@@ -0,0 +1,312 @@
+// Copyright (C) 2023  Tricorder Observability
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package http
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/tricorder/src/api-server/http/api"
+	"github.com/tricorder/src/api-server/http/dao"
+	pb "github.com/tricorder/src/api-server/pb"
+	modulepb "github.com/tricorder/src/pb/module"
+	commonpb "github.com/tricorder/src/pb/module/common"
+	ebpfpb "github.com/tricorder/src/pb/module/ebpf"
+	wasmpb "github.com/tricorder/src/pb/module/wasm"
+	"github.com/tricorder/src/utils/log"
+	"github.com/tricorder/src/utils/signing"
+)
+
+// updateModuleV2 godoc
+// @Summary      Update module
+// @Description  Replace the name and code of the module, which must not be deployed. The code is validated, and
+// @Description  compiled if it is WASM text code, like when creating a module. The tenant cannot be changed.
+// @Tags         module-v2
+// @Accept       json
+// @Produce      json
+// @Param        id      path  string           true  "module id"
+// @Param        module  body  CreateModuleReq  true  "The module's new name and code"
+// @Success      200  {object}  ModuleIDResp
+// @Failure      400  {object}  ErrorResp
+// @Failure      404  {object}  ErrorResp
+// @Failure      409  {object}  ErrorResp
+// @Failure      500  {object}  ErrorResp
+// @Router       /api/v2/modules/{id} [put].
+func (mgr *ModuleManager) updateModuleV2(c *gin.Context) {
+	var body CreateModuleReq
+	err := c.ShouldBindJSON(&body)
+	if err != nil {
+		abortWithError(c, http.StatusBadRequest, "Request Error: "+err.Error())
+		return
+	}
+	if len(body.Name) == 0 || body.Wasm == nil || body.Wasm.OutputSchema == nil || body.Ebpf == nil {
+		abortWithError(c, http.StatusBadRequest, "Request Error: name, wasm.output_schema and ebpf are required")
+		return
+	}
+	id := c.Param(api.MODULE_ID_PARAM)
+	before := mgr.moduleAuditState(id)
+	resp := mgr.updateModule(id, body)
+	mgr.auditModule(c, dao.AuditModuleUpdate, id, before, resp)
+	if abortIfFailed(c, resp) {
+		return
+	}
+	c.JSON(http.StatusOK, ModuleIDResp{ID: id})
+}
+
+// patchModuleV2 godoc
+// @Summary      Patch module
+// @Description  Update the name, probes, code, output schema, or function name of the module, which must not be
+// @Description  deployed. The omitted fields keep their current values, see UpdateModuleReq. The updated module is
+// @Description  validated, and its WASM text code is compiled, like when creating a module.
+// @Tags         module-v2
+// @Accept       json
+// @Produce      json
+// @Param        id      path  string           true  "module id"
+// @Param        module  body  UpdateModuleReq  true  "The updated fields of the module"
+// @Success      200  {object}  ModuleIDResp
+// @Failure      400  {object}  ErrorResp
+// @Failure      404  {object}  ErrorResp
+// @Failure      409  {object}  ErrorResp
+// @Failure      500  {object}  ErrorResp
+// @Router       /api/v2/modules/{id} [patch].
+func (mgr *ModuleManager) patchModuleV2(c *gin.Context) {
+	var patch UpdateModuleReq
+	err := c.ShouldBindJSON(&patch)
+	if err != nil {
+		abortWithError(c, http.StatusBadRequest, "Request Error: "+err.Error())
+		return
+	}
+	id := c.Param(api.MODULE_ID_PARAM)
+	before := mgr.moduleAuditState(id)
+	resp := mgr.patchModule(id, patch)
+	mgr.auditModule(c, dao.AuditModuleUpdate, id, before, resp)
+	if abortIfFailed(c, resp) {
+		return
+	}
+	c.JSON(http.StatusOK, ModuleIDResp{ID: id})
+}
+
+// checkUpdatable returns an error if the module cannot be updated, because it does not exist, or its instances might
+// be running its code.
+func (mgr *ModuleManager) checkUpdatable(id string, module *dao.ModuleGORM) error {
+	if module == nil {
+		return newStatusError(http.StatusNotFound, "module %s does not exist", id)
+	}
+	if module.DesireState == int(pb.ModuleState_DEPLOYED) {
+		return newStatusError(http.StatusConflict, "module %s is deployed, please undeploy first", id)
+	}
+	if mgr.isUpgrading(id) {
+		return newStatusError(http.StatusConflict, "module %s is being upgraded", id)
+	}
+	isProgress, err := mgr.ModuleInstance.CheckModuleInProgress(id)
+	if err != nil {
+		return fmt.Errorf("check module %s in progress state error: %v", id, err)
+	}
+	if isProgress {
+		return newStatusError(http.StatusConflict, "module %s is in progress state", id)
+	}
+	return nil
+}
+
+// queryUpdatableModule returns the module if it can be updated, see checkUpdatable().
+func (mgr *ModuleManager) queryUpdatableModule(id string) (*dao.ModuleGORM, error) {
+	var module *dao.ModuleGORM
+	err := mgr.gLock.ExecWithLock(func() error {
+		var err error
+		module, err = mgr.Module.QueryByID(id)
+		if err != nil {
+			return fmt.Errorf("query module error: %v", err)
+		}
+		return mgr.checkUpdatable(id, module)
+	})
+	return module, err
+}
+
+// updateModule replaces the name and code of the module's current version with the request's, the module keeps its
+// ID, tenant, version number and data table. The module is validated like a created one.
+func (mgr *ModuleManager) updateModule(id string, body CreateModuleReq) HTTPResp {
+	if _, err := mgr.queryUpdatableModule(id); err != nil {
+		return HTTPResp{Code: statusCode(err), Message: err.Error()}
+	}
+
+	code, err := mgr.newModuleCode(body)
+	if err != nil {
+		return HTTPResp{Code: http.StatusBadRequest, Message: err.Error()}
+	}
+	hypertable, err := moduleHypertable(code, body.Wasm.OutputSchema)
+	if err != nil {
+		return HTTPResp{Code: statusCode(err), Message: err.Error()}
+	}
+
+	err = mgr.gLock.ExecWithLock(func() error {
+		// The module might have been deployed, or renamed, while compiling its code.
+		module, err := mgr.Module.QueryByID(id)
+		if err != nil {
+			return fmt.Errorf("query module error: %v", err)
+		}
+		if err := mgr.checkUpdatable(id, module); err != nil {
+			return err
+		}
+		if body.Name != module.Name {
+			m, _ := mgr.Module.QueryByTenantAndName(module.Tenant, body.Name)
+			if m != nil && len(m.Name) > 0 {
+				return newStatusError(http.StatusConflict, "name '%s' already exists", body.Name)
+			}
+		}
+		// Modules created before versioning have no version records, their code becomes version 1.
+		if module.Version == 0 {
+			module.Version = 1
+		}
+		code.ID = module.ID
+		code.Version = module.Version
+		dao.NewModuleVersion(code).ApplyTo(module)
+		module.Name = body.Name
+		module.Hypertable = hypertable
+		err = mgr.Module.SaveModule(module)
+		if err != nil {
+			return err
+		}
+		return mgr.ModuleVersion.SaveModuleVersion(dao.NewModuleVersion(module))
+	})
+	if err != nil {
+		log.Errorf("While updating module '%s', error: %v", id, err)
+		return HTTPResp{Code: statusCode(err), Message: err.Error()}
+	}
+	return HTTPResp{Code: http.StatusOK, Message: "update success, module id: " + id}
+}
+
+// patchModule updates the fields of the module that are set in the patch, see updateModule().
+func (mgr *ModuleManager) patchModule(id string, patch UpdateModuleReq) HTTPResp {
+	module, err := mgr.queryUpdatableModule(id)
+	if err != nil {
+		return HTTPResp{Code: statusCode(err), Message: err.Error()}
+	}
+	body, err := moduleRequest(module)
+	if err != nil {
+		return HTTPResp{Code: http.StatusInternalServerError, Message: err.Error()}
+	}
+	mergeModuleReq(body, patch)
+	return mgr.updateModule(id, *body)
+}
+
+// moduleRequest returns the request that creates the module's name and code. The WASM code is the text code if the
+// module was created with it, which is compiled again when updating the module.
+func moduleRequest(module *dao.ModuleGORM) (*CreateModuleReq, error) {
+	var probes []*ebpfpb.ProbeSpec
+	var fields []*commonpb.DataField
+	var primaryKey []string
+	var indexes []*commonpb.Index
+	var hypertable *commonpb.Hypertable
+	var sink *commonpb.Sink
+	for _, attr := range []struct {
+		name  string
+		value string
+		out   any
+	}{
+		{"ebpf probes", module.EbpfProbes, &probes},
+		{"output schema", module.SchemaAttr, &fields},
+		{"primary key", module.PrimaryKey, &primaryKey},
+		{"indexes", module.Indexes, &indexes},
+		{"hypertable", module.Hypertable, &hypertable},
+		{"sink", module.Sink, &sink},
+	} {
+		if len(attr.value) == 0 {
+			continue
+		}
+		if err := json.Unmarshal([]byte(attr.value), attr.out); err != nil {
+			return nil, fmt.Errorf("while reading module '%s', failed to unmarshal %s, error: %v",
+				module.ID, attr.name, err)
+		}
+	}
+
+	wasmCode := module.Wasm
+	var signature *modulepb.Signature
+	if commonpb.Format(module.WasmFmt) == commonpb.Format_TEXT {
+		// The compiled code might have been signed by API Server, which signs it again after compiling.
+		wasmCode = []byte(module.WasmCode)
+	} else if len(module.Signature) > 0 {
+		signature = &modulepb.Signature{KeyId: module.SignatureKeyID, Value: module.Signature}
+	}
+	return &CreateModuleReq{
+		ID:   module.ID,
+		Name: module.Name,
+		Ebpf: &ebpfpb.Program{
+			Fmt:            commonpb.Format(module.EbpfFmt),
+			Lang:           commonpb.Lang(module.EbpfLang),
+			Code:           module.Ebpf,
+			PerfBufferName: module.EbpfPerfBufferName,
+			Probes:         probes,
+		},
+		Wasm: &wasmpb.Program{
+			Fmt:    commonpb.Format(module.WasmFmt),
+			Lang:   commonpb.Lang(module.WasmLang),
+			Code:   wasmCode,
+			FnName: module.Fn,
+			OutputSchema: &commonpb.Schema{
+				Fields:     fields,
+				Hypertable: hypertable,
+				PrimaryKey: primaryKey,
+				Indexes:    indexes,
+			},
+			Sink: sink,
+		},
+		Signature: signature,
+		Tenant:    module.Tenant,
+	}, nil
+}
+
+// mergeModuleReq overwrites the fields of the request with the fields set in the patch. The request's signature is
+// replaced with the patch's, or dropped if the signed code changes, as it would not match the code.
+func mergeModuleReq(body *CreateModuleReq, patch UpdateModuleReq) {
+	before := signing.Digest(&modulepb.Module{Ebpf: body.Ebpf, Wasm: body.Wasm})
+	if len(patch.Name) > 0 {
+		body.Name = patch.Name
+	}
+	if e := patch.Ebpf; e != nil {
+		if len(e.Code) > 0 {
+			body.Ebpf.Code, body.Ebpf.Fmt, body.Ebpf.Lang = e.Code, e.Fmt, e.Lang
+		}
+		if len(e.PerfBufferName) > 0 {
+			body.Ebpf.PerfBufferName = e.PerfBufferName
+		}
+		if len(e.Probes) > 0 {
+			body.Ebpf.Probes = e.Probes
+		}
+	}
+	if w := patch.Wasm; w != nil {
+		if len(w.Code) > 0 {
+			body.Wasm.Code, body.Wasm.Fmt, body.Wasm.Lang = w.Code, w.Fmt, w.Lang
+		}
+		if len(w.FnName) > 0 {
+			body.Wasm.FnName = w.FnName
+		}
+		if w.OutputSchema != nil {
+			body.Wasm.OutputSchema = w.OutputSchema
+		}
+		if w.Sink != nil {
+			body.Wasm.Sink = w.Sink
+		}
+	}
+	if patch.Signature != nil {
+		body.Signature = patch.Signature
+	} else if !bytes.Equal(before, signing.Digest(&modulepb.Module{Ebpf: body.Ebpf, Wasm: body.Wasm})) {
+		body.Signature = nil
+	}
+}
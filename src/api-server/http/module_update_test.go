@@ -0,0 +1,110 @@
+// Copyright (C) 2023  Tricorder Observability
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package http
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/tricorder/src/api-server/http/api"
+	"github.com/tricorder/src/api-server/http/dao"
+	commonpb "github.com/tricorder/src/pb/module/common"
+	"github.com/tricorder/src/pb/module/ebpf"
+	"github.com/tricorder/src/pb/module/wasm"
+)
+
+// Tests that PUT and PATCH update the name and code of the modules that are not deployed, and keep their IDs.
+func TestUpdateModule(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	mgr := newTestModuleManager(t)
+	require.Nil(mgr.NodeAgent.SaveAgent(&dao.NodeAgentGORM{AgentID: "agent", NodeName: "node"}))
+	router := gin.New()
+	mgr.registerV2(router)
+
+	moduleReq := stdoutModuleReq()
+	var idResp ModuleIDResp
+	require.Equal(http.StatusCreated, serveV2(t, router, "POST", api.MODULES_V2_PATH, moduleReq, &idResp))
+	id := idResp.ID
+	modulePath := api.GetModuleV2Path(id)
+
+	patch := UpdateModuleReq{
+		Name: "renamed",
+		Ebpf: &ebpf.Program{
+			Probes: []*ebpf.ProbeSpec{{Type: ebpf.ProbeSpec_KPROBE, Target: "do_sys_openat2", Entry: "entry"}},
+		},
+		Wasm: &wasm.Program{FnName: "write"},
+	}
+	assert.Equal(http.StatusOK, serveV2(t, router, "PATCH", modulePath, patch, &idResp))
+	assert.Equal(id, idResp.ID)
+
+	module, err := mgr.Module.QueryByID(id)
+	require.Nil(err)
+	assert.Equal("renamed", module.Name)
+	assert.Equal("write", module.Fn)
+	assert.Equal("ebpf", module.Ebpf)
+	assert.Equal("events", module.EbpfPerfBufferName)
+	assert.Contains(module.EbpfProbes, "do_sys_openat2")
+	assert.Equal([]byte("wasm"), module.Wasm)
+	assert.Equal(1, module.Version)
+	version, err := mgr.ModuleVersion.QueryByModuleIDAndVersion(id, 1)
+	require.Nil(err)
+	assert.Equal("write", version.Fn)
+
+	moduleReq.Name = "replaced"
+	moduleReq.Wasm.OutputSchema = &commonpb.Schema{
+		Fields: []*commonpb.DataField{{Name: "count", Type: commonpb.DataField_INT}},
+	}
+	assert.Equal(http.StatusOK, serveV2(t, router, "PUT", modulePath, moduleReq, &idResp))
+	module, err = mgr.Module.QueryByID(id)
+	require.Nil(err)
+	assert.Equal("replaced", module.Name)
+	assert.Equal("fn", module.Fn)
+	assert.Contains(module.SchemaAttr, "count")
+	assert.NotContains(module.EbpfProbes, "do_sys_openat2")
+
+	var errResp ErrorResp
+	assert.Equal(http.StatusBadRequest, serveV2(t, router, "PUT", modulePath, CreateModuleReq{}, &errResp))
+	assert.Contains(errResp.Error.Message, "are required")
+	assert.Equal(http.StatusBadRequest, serveV2(t, router, "PATCH", modulePath,
+		UpdateModuleReq{Wasm: &wasm.Program{OutputSchema: &commonpb.Schema{
+			Fields:     []*commonpb.DataField{{Name: "count", Type: commonpb.DataField_INT}},
+			Hypertable: &commonpb.Hypertable{TimeColumn: "count"},
+		}}}, &errResp))
+	assert.Contains(errResp.Error.Message, "invalid hypertable")
+	assert.Equal(http.StatusNotFound,
+		serveV2(t, router, "PATCH", api.GetModuleV2Path("unknown"), UpdateModuleReq{Name: "x"}, &errResp))
+
+	moduleReq.Name = "other"
+	require.Equal(http.StatusCreated, serveV2(t, router, "POST", api.MODULES_V2_PATH, moduleReq, &idResp))
+	assert.Equal(http.StatusConflict, serveV2(t, router, "PATCH", modulePath, UpdateModuleReq{Name: "other"}, &errResp))
+	assert.Contains(errResp.Error.Message, "already exists")
+
+	var actionResp ModuleActionResp
+	deployPath := api.GetModuleActionV2Path(id, api.DEPLOY_ACTION)
+	require.Equal(http.StatusAccepted, serveV2(t, router, "POST", deployPath, nil, &actionResp))
+	assert.Equal(http.StatusConflict, serveV2(t, router, "PATCH", modulePath, UpdateModuleReq{Name: "x"}, &errResp))
+	assert.Contains(errResp.Error.Message, "please undeploy first")
+
+	audits, _, err := mgr.Audit.ListPage(dao.AuditFilter{Action: dao.AuditModuleUpdate}, 10, 0)
+	require.Nil(err)
+	assert.NotEmpty(audits)
+}
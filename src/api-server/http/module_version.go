@@ -0,0 +1,486 @@
+// Copyright (C) 2023  Tricorder Observability
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/tricorder/src/api-server/http/api"
+	"github.com/tricorder/src/api-server/http/dao"
+	pb "github.com/tricorder/src/api-server/pb"
+	commonpb "github.com/tricorder/src/pb/module/common"
+	"github.com/tricorder/src/utils/log"
+	"github.com/tricorder/src/utils/pg"
+)
+
+const (
+	// How long to wait for an agent to report the result of upgrading a module instance.
+	defaultUpgradeInstanceTimeout = 2 * time.Minute
+
+	// The interval of checking the state of a module instance being upgraded.
+	upgradeInstancePollPeriod = 500 * time.Millisecond
+)
+
+// createModuleVersionHttp godoc
+// @Summary      Create module version
+// @Description  Store a new version of the module's code, the module keeps its name, data table, and dashboard
+// @Tags         module
+// @Accept       json
+// @Produce      json
+// @Param			   id	  path		  string	true	"module id"
+// @Param			   module	body	CreateModuleReq	true	"The new version of the module, name is ignored"
+// @Success      200  {object}  CreateModuleVersionResp
+// @Router       /api/module/{id}/versions [post].
+func (mgr *ModuleManager) createModuleVersionHttp(c *gin.Context) {
+	var body CreateModuleReq
+	err := c.ShouldBind(&body)
+	if err != nil {
+		c.JSON(http.StatusOK, HTTPResp{Code: http.StatusBadRequest, Message: "Request Error: " + err.Error()})
+		return
+	}
+	id := c.Param(api.MODULE_ID_PARAM)
+	before := mgr.moduleAuditState(id)
+	result := mgr.createModuleVersion(id, body)
+	mgr.auditModule(c, dao.AuditModuleCreateVersion, id, before, result.HTTPResp)
+	c.JSON(http.StatusOK, result)
+}
+
+func (mgr *ModuleManager) createModuleVersion(id string, body CreateModuleReq) CreateModuleVersionResp {
+	var module *dao.ModuleGORM
+	err := mgr.gLock.ExecWithLock(func() error {
+		var err error
+		module, err = mgr.Module.QueryByID(id)
+		return err
+	})
+	if err != nil {
+		return CreateModuleVersionResp{HTTPResp{
+			Code:    500,
+			Message: "Query Error: " + err.Error(),
+		}, 0}
+	}
+	if module == nil {
+		return CreateModuleVersionResp{HTTPResp{
+			Code:    http.StatusNotFound,
+			Message: "module " + id + " does not exist",
+		}, 0}
+	}
+
+	code, err := mgr.newModuleCode(body)
+	if err != nil {
+		return CreateModuleVersionResp{HTTPResp{
+			Code:    http.StatusBadRequest,
+			Message: err.Error(),
+		}, 0}
+	}
+
+	// The data table might have columns added by other versions, which have to keep their types.
+	versions, err := mgr.ModuleVersion.ListByModuleID(module.ID, "schema_attr")
+	if err != nil {
+		return CreateModuleVersionResp{HTTPResp{
+			Code:    http.StatusInternalServerError,
+			Message: "Query Error: " + err.Error(),
+		}, 0}
+	}
+	err = checkSchemaCompatible(module.SchemaAttr, code.SchemaAttr, false)
+	if err == nil && len(module.Hypertable) > 0 {
+		err = checkVersionHypertableKeys(module.Hypertable, body.Wasm.OutputSchema)
+		if err == nil && !writesToPostgres(code) {
+			err = errors.New("invalid sink, the module's hypertable needs the output written to Postgres")
+		}
+	}
+	for i := 0; err == nil && i < len(versions); i++ {
+		err = checkSchemaCompatible(versions[i].SchemaAttr, code.SchemaAttr, true)
+	}
+	if err != nil {
+		return CreateModuleVersionResp{HTTPResp{
+			Code:    http.StatusBadRequest,
+			Message: err.Error(),
+		}, 0}
+	}
+
+	err = mgr.gLock.ExecWithLock(func() error {
+		// Modules created before versioning have no version records, their current code becomes version 1.
+		if module.Version == 0 {
+			module.Version = 1
+			err := mgr.ModuleVersion.SaveModuleVersion(dao.NewModuleVersion(module))
+			if err != nil {
+				return err
+			}
+			err = mgr.Module.UpdateByID(&dao.ModuleGORM{ID: module.ID, Version: module.Version})
+			if err != nil {
+				return err
+			}
+		}
+		latestVersion, err := mgr.ModuleVersion.LatestVersion(module.ID)
+		if err != nil {
+			return err
+		}
+		code.ID = module.ID
+		code.Version = latestVersion + 1
+		return mgr.ModuleVersion.SaveModuleVersion(dao.NewModuleVersion(code))
+	})
+	if err != nil {
+		msg := fmt.Sprintf("while creating module version, failed to save module version ORM object, error: %v", err)
+		log.Errorf(msg)
+		return CreateModuleVersionResp{HTTPResp{
+			Code:    500,
+			Message: msg,
+		}, 0}
+	}
+
+	return CreateModuleVersionResp{HTTPResp{
+		Code:    200,
+		Message: fmt.Sprintf("create success, module id: %s, version: %d", module.ID, code.Version),
+	}, code.Version}
+}
+
+// checkSchemaCompatible returns an error if the data table of the old schema cannot store the data of the new
+// schema. New fields can be added, the existing fields can only change to wider types, see pg.IsWideningType(),
+// and cannot be removed unless allowRemoval is true.
+func checkSchemaCompatible(oldSchemaAttr, newSchemaAttr string, allowRemoval bool) error {
+	var oldFields, newFields []*commonpb.DataField
+	err := json.Unmarshal([]byte(oldSchemaAttr), &oldFields)
+	if err != nil {
+		return fmt.Errorf("while checking schema compatibility, failed to unmarshal old schema, error: %v", err)
+	}
+	err = json.Unmarshal([]byte(newSchemaAttr), &newFields)
+	if err != nil {
+		return fmt.Errorf("while checking schema compatibility, failed to unmarshal new schema, error: %v", err)
+	}
+	newColumns := make(map[string]pg.Column, len(newFields))
+	for _, f := range newFields {
+		newColumns[f.Name] = pg.Column{Type: f.Type, Array: f.Array}
+	}
+	for _, f := range oldFields {
+		newColumn, ok := newColumns[f.Name]
+		if !ok && allowRemoval {
+			continue
+		}
+		if !ok {
+			return fmt.Errorf("incompatible schema, field '%s' is removed", f.Name)
+		}
+		oldColumn := pg.Column{Type: f.Type, Array: f.Array}
+		if !pg.IsWideningType(oldColumn, newColumn) {
+			oldType, _ := pg.TypeName(oldColumn)
+			newType, _ := pg.TypeName(newColumn)
+			return fmt.Errorf("incompatible schema, field '%s' changes type from %s to %s", f.Name, oldType, newType)
+		}
+	}
+	return nil
+}
+
+// listModuleVersionsHttp godoc
+// @Summary      List module versions
+// @Description  List the versions of the module, the latest version first
+// @Tags         module
+// @Accept       json
+// @Produce      json
+// @Param			   id	  path		  string	true	"module id"
+// @Success      200  {object}  ListModuleVersionResp
+// @Router       /api/module/{id}/versions [get].
+func (mgr *ModuleManager) listModuleVersionsHttp(c *gin.Context) {
+	c.JSON(http.StatusOK, mgr.listModuleVersions(c.Param(api.MODULE_ID_PARAM)))
+}
+
+func (mgr *ModuleManager) listModuleVersions(id string) ListModuleVersionResp {
+	module, err := mgr.Module.QueryByID(id)
+	if err != nil {
+		return ListModuleVersionResp{HTTPResp{
+			Code:    500,
+			Message: "Query Error: " + err.Error(),
+		}, nil}
+	}
+	if module == nil {
+		return ListModuleVersionResp{HTTPResp{
+			Code:    404,
+			Message: "module " + id + " does not exist",
+		}, nil}
+	}
+
+	resultList, err := mgr.ModuleVersion.ListByModuleID(id)
+	if err != nil {
+		return ListModuleVersionResp{HTTPResp{
+			Code:    500,
+			Message: "Query Error: " + err.Error(),
+		}, nil}
+	}
+
+	return ListModuleVersionResp{HTTPResp{
+		Code:    200,
+		Message: "Success",
+	}, resultList}
+}
+
+// upgradeModuleHttp godoc
+// @Summary      Upgrade module
+// @Description  Switch the module to the specified version. A deployed module is upgraded agent by agent, and is
+// @Description  rolled back to the previous version if any agent fails to deploy the new version. The upgrade fails
+// @Description  if any agent of the module is not connected, unless skip_disconnected is set, then those agents are
+// @Description  returned as pending, and deploy the new version when they connect.
+// @Tags         module
+// @Accept       json
+// @Produce      json
+// @Param			   id	  path		  string	true	"module id"
+// @Param			   version	  query		  int	true	"the version to upgrade to"
+// @Param			   force	  query		  bool	false	"apply destructive changes to the data table"
+// @Param			   skip_disconnected	  query		  bool	false	"upgrade the agents that are not connected when they connect"
+// @Success      200  {object}  UpgradeModuleResp
+// @Router       /api/module/{id}/upgrade [post].
+func (mgr *ModuleManager) upgradeModuleHttp(c *gin.Context) {
+	versionStr, err := checkQuery(c, "version")
+	if err != nil {
+		return
+	}
+	version, err := strconv.Atoi(versionStr)
+	if err != nil {
+		c.JSON(http.StatusOK, HTTPResp{Code: http.StatusBadRequest, Message: "Request Error: invalid version " + versionStr})
+		return
+	}
+	id := c.Param(api.MODULE_ID_PARAM)
+	before := mgr.moduleAuditState(id)
+	result := mgr.upgradeModule(id, version, c.Query("force") == "true", c.Query("skip_disconnected") == "true")
+	mgr.auditModule(c, dao.AuditModuleUpgrade, id, before, result.HTTPResp)
+	c.JSON(http.StatusOK, result)
+}
+
+// upgradeModule switches the module to the version. A deployed module is upgraded by rollingUpgrade(), which fails if
+// any agent of the module is not connected, unless skipDisconnected is true.
+func (mgr *ModuleManager) upgradeModule(id string, version int, force, skipDisconnected bool) UpgradeModuleResp {
+	var module *dao.ModuleGORM
+	var target *dao.ModuleVersionGORM
+	// The agents that are not connected, which deploy the target version when they connect.
+	var pending []string
+	// Set by the locked function below, if the upgrade needs to be rolled out to agents.
+	var rollout bool
+	err := mgr.gLock.ExecWithLock(func() error {
+		var err error
+		module, err = mgr.Module.QueryByID(id)
+		if err != nil {
+			return errors.New("query module error: " + err.Error())
+		}
+		if module == nil {
+			return newStatusError(http.StatusNotFound, "module %s does not exist", id)
+		}
+		if module.Version == version {
+			return newStatusError(http.StatusConflict, "module %s is already at version %d", id, version)
+		}
+		target, err = mgr.ModuleVersion.QueryByModuleIDAndVersion(id, version)
+		if err != nil {
+			return errors.New("query module version error: " + err.Error())
+		}
+		if target == nil {
+			return newStatusError(http.StatusNotFound, "version %d of module %s does not exist", version, id)
+		}
+		isProgress, err := mgr.ModuleInstance.CheckModuleInProgress(id)
+		if err != nil {
+			return errors.New("check module " + id + " in progress state error: " + err.Error())
+		}
+		if isProgress {
+			return newStatusError(http.StatusConflict, "module %s is in progress state", id)
+		}
+		if module.DesireState != int(pb.ModuleState_DEPLOYED) {
+			// No agents are running this module, the new version takes effect when the module is deployed.
+			target.ApplyTo(module)
+			return mgr.Module.SaveModule(module)
+		}
+		pending, err = mgr.disconnectedAgents(id)
+		if err != nil {
+			return errors.New("list agents of module " + id + " error: " + err.Error())
+		}
+		if len(pending) > 0 && !skipDisconnected {
+			return newStatusError(http.StatusConflict, "agents %s of module %s are not connected, "+
+				"set skip_disconnected to upgrade them when they connect", strings.Join(pending, ", "), id)
+		}
+		if _, loaded := mgr.upgrading.LoadOrStore(id, true); loaded {
+			return newStatusError(http.StatusConflict, "module %s is being upgraded", id)
+		}
+		rollout = true
+		return nil
+	})
+	if err != nil {
+		return UpgradeModuleResp{HTTPResp: HTTPResp{
+			Code:    statusCode(err),
+			Message: err.Error(),
+		}}
+	}
+	if !rollout {
+		return UpgradeModuleResp{HTTPResp: HTTPResp{
+			Code:    200,
+			Message: fmt.Sprintf("upgraded module %s to version %d", id, version),
+		}}
+	}
+
+	// The columns removed by the target version are kept, they are still written by the agents not yet upgraded,
+	// and are needed if the upgrade is rolled back. The data table is not needed if the output goes to other sinks.
+	targetModule := *module
+	target.ApplyTo(&targetModule)
+	if writesToPostgres(&targetModule) {
+		err = mgr.migratePGTable(&targetModule, pg.MigrateOptions{Force: force, KeepExtraColumns: true})
+	}
+	if err != nil {
+		mgr.upgrading.Delete(id)
+		return UpgradeModuleResp{HTTPResp: HTTPResp{
+			Code:    500,
+			Message: "update schema error: " + err.Error(),
+		}}
+	}
+
+	go mgr.rollingUpgrade(module, target, skipDisconnected)
+
+	return UpgradeModuleResp{HTTPResp: HTTPResp{
+		Code:    200,
+		Message: fmt.Sprintf("prepare to upgrade module %s from version %d to version %d", id, module.Version, version),
+	}, Pending: pending}
+}
+
+// disconnectedAgents returns the agents of the deployed instances of the module that are not connected.
+func (mgr *ModuleManager) disconnectedAgents(moduleID string) ([]string, error) {
+	instances, err := mgr.ModuleInstance.ListByModuleID(moduleID)
+	if err != nil {
+		return nil, err
+	}
+	var agents []string
+	for _, moduleInstance := range instances {
+		deployed := moduleInstance.DesireState == int(pb.ModuleState_DEPLOYED)
+		if deployed && !mgr.dispatcher.IsRegistered(moduleInstance.AgentID) {
+			agents = append(agents, moduleInstance.AgentID)
+		}
+	}
+	return agents, nil
+}
+
+func (mgr *ModuleManager) isUpgrading(id string) bool {
+	_, ok := mgr.upgrading.Load(id)
+	return ok
+}
+
+// rollingUpgrade upgrades the deployed instances of the module to the target version, one agent at a time.
+// If any agent fails to deploy the target version, or is not connected unless skipDisconnected is true, all upgraded
+// instances are rolled back to the module's current version. The module switches to the target version after all
+// instances are upgraded.
+func (mgr *ModuleManager) rollingUpgrade(module *dao.ModuleGORM, target *dao.ModuleVersionGORM, skipDisconnected bool) {
+	defer mgr.upgrading.Delete(module.ID)
+
+	log.Infof("Upgrading module '%s' from version %d to version %d", module.ID, module.Version, target.Version)
+
+	instances, err := mgr.ModuleInstance.ListByModuleID(module.ID)
+	if err != nil {
+		log.Errorf("Failed to list instances of module '%s', upgrade is aborted, error: %v", module.ID, err)
+		return
+	}
+
+	var upgraded []*dao.ModuleInstanceGORM
+	for i := range instances {
+		moduleInstance := &instances[i]
+		if moduleInstance.DesireState != int(pb.ModuleState_DEPLOYED) {
+			continue
+		}
+		// The instance that failed to upgrade is also rolled back, to make sure it ends up running the current version.
+		upgraded = append(upgraded, moduleInstance)
+		err := mgr.upgradeModuleInstance(moduleInstance, target.Version, skipDisconnected)
+		if err != nil {
+			log.Errorf("Failed to upgrade module '%s' to version %d on agent '%s', rolling back to version %d, error: %v",
+				module.ID, target.Version, moduleInstance.AgentID, module.Version, err)
+			mgr.rollbackModuleInstances(upgraded, module.Version)
+			return
+		}
+	}
+
+	err = mgr.gLock.ExecWithLock(func() error {
+		m, err := mgr.Module.QueryByID(module.ID)
+		if err != nil {
+			return err
+		}
+		if m == nil {
+			return errors.New("module is deleted")
+		}
+		target.ApplyTo(m)
+		return mgr.Module.SaveModule(m)
+	})
+	if err != nil {
+		log.Errorf("Failed to switch module '%s' to version %d, error: %v", module.ID, target.Version, err)
+		return
+	}
+	log.Infof("Upgraded module '%s' to version %d", module.ID, target.Version)
+}
+
+// upgradeModuleInstance sends the specified version to the agent of the module instance, and waits for the result.
+// Returns error if the agent is not connected, unless skipDisconnected is true, then the instance is deployed with the
+// specified version when the agent connects.
+func (mgr *ModuleManager) upgradeModuleInstance(moduleInstance *dao.ModuleInstanceGORM, version int,
+	skipDisconnected bool,
+) error {
+	err := mgr.gLock.ExecWithLock(func() error {
+		return mgr.ModuleInstance.UpdateVersionByID(moduleInstance.ID, version)
+	})
+	if err != nil {
+		return err
+	}
+	if !mgr.dispatcher.Dispatch(moduleInstance.AgentID, moduleInstance.ID) {
+		if !skipDisconnected {
+			return fmt.Errorf("agent '%s' is not connected, or its queue is full", moduleInstance.AgentID)
+		}
+		log.Infof("Agent '%s' is not connected, module instance '%s' is upgraded when it connects",
+			moduleInstance.AgentID, moduleInstance.ID)
+		return nil
+	}
+	return mgr.waitModuleInstance(moduleInstance.ID)
+}
+
+// waitModuleInstance waits until the agent reports the result of the module instance's deployment.
+func (mgr *ModuleManager) waitModuleInstance(id string) error {
+	timeout := mgr.upgradeInstanceTimeout
+	if timeout == 0 {
+		timeout = defaultUpgradeInstanceTimeout
+	}
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		time.Sleep(upgradeInstancePollPeriod)
+		moduleInstance, err := mgr.ModuleInstance.QueryByID(id)
+		if err != nil {
+			return err
+		}
+		switch moduleInstance.State {
+		case int(pb.ModuleInstanceState_SUCCEEDED):
+			return nil
+		case int(pb.ModuleInstanceState_FAILED):
+			return errors.New("agent failed to deploy the module instance: " + moduleInstance.StateDesc)
+		case int(pb.ModuleInstanceState_REJECTED):
+			return errors.New("agent rejected the module instance: " + moduleInstance.StateDesc)
+		}
+	}
+	return fmt.Errorf("agent did not report the result in %v", timeout)
+}
+
+// rollbackModuleInstances deploys the specified version to the module instances. The instances of the agents that are
+// not connected are rolled back when they connect.
+func (mgr *ModuleManager) rollbackModuleInstances(moduleInstances []*dao.ModuleInstanceGORM, version int) {
+	for _, moduleInstance := range moduleInstances {
+		err := mgr.upgradeModuleInstance(moduleInstance, version, true)
+		if err != nil {
+			log.Errorf("Failed to roll back module instance '%s' to version %d, error: %v",
+				moduleInstance.ID, version, err)
+		}
+	}
+}
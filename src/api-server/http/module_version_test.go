@@ -0,0 +1,279 @@
+// Copyright (C) 2023  Tricorder Observability
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package http
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/tricorder/src/api-server/http/dao"
+	pb "github.com/tricorder/src/api-server/pb"
+)
+
+// Tests that checkSchemaCompatible only allows adding fields.
+func TestCheckSchemaCompatible(t *testing.T) {
+	assert := assert.New(t)
+
+	oldSchema := `[{"name":"a","type":5},{"name":"b","type":6}]`
+	assert.Nil(checkSchemaCompatible(oldSchema, oldSchema, false))
+	assert.Nil(checkSchemaCompatible(oldSchema, `[{"name":"a","type":5},{"name":"b","type":6},{"name":"c","type":2}]`,
+		false))
+	assert.ErrorContains(checkSchemaCompatible(oldSchema, `[{"name":"a","type":5}]`, false), "field 'b' is removed")
+	assert.Nil(checkSchemaCompatible(oldSchema, `[{"name":"a","type":5}]`, true))
+	assert.ErrorContains(checkSchemaCompatible(oldSchema, `[{"name":"a","type":5},{"name":"b","type":5}]`, true),
+		"field 'b' changes type")
+	// JSONB can be widened to TEXT.
+	assert.Nil(checkSchemaCompatible(oldSchema, `[{"name":"a","type":6},{"name":"b","type":6}]`, false))
+	assert.ErrorContains(checkSchemaCompatible(oldSchema, `[{"name":"a","type":5},{"name":"b","type":6,"array":true}]`,
+		false), "field 'b' changes type from TEXT to TEXT[]")
+}
+
+const moduleVersionBody = `{
+	"wasm":{
+		"code": "",
+		"fn_name":"copy_input_to_output_v2",
+		"fmt":    1,
+		"output_schema":{
+			"name":"test_tabel_name",
+			"fields":%s
+		}
+	},
+	"ebpf":{
+		"code": "",
+		"perf_buffer_name":"events",
+		"probes":[{"target":"","entry":"sample_json","return":""}]
+	}
+}`
+
+// Tests creating module versions, and upgrading a module that is not deployed.
+func TestModuleVersions(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	r := SetUpRouter("")
+	r.GET("/api/module/:id/versions", mgr.listModuleVersionsHttp)
+	r.POST("/api/module/:id/versions", mgr.createModuleVersionHttp)
+	r.POST("/api/module/:id/upgrade", mgr.upgradeModuleHttp)
+
+	moduleID := AddModule(t, "test_wasm_uid", r)
+
+	post := func(path, body string) string {
+		req, err := http.NewRequest("POST", path, bytes.NewBufferString(body))
+		require.NoError(err)
+		req.Header.Set("Content-Type", "application/json; charset=utf-8")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w.Body.String()
+	}
+
+	// Removing the existing field is refused.
+	resultStr := post("/api/module/"+moduleID+"/versions", fmt.Sprintf(moduleVersionBody, `[{"name":"x","type":5}]`))
+	assert.Contains(resultStr, "field 'data' is removed")
+	assert.Contains(resultStr, `"code":400`)
+
+	resultStr = post("/api/module/"+moduleID+"/versions",
+		fmt.Sprintf(moduleVersionBody, `[{"name":"data","type":5},{"name":"extra","type":6}]`))
+	var createResp CreateModuleVersionResp
+	require.NoError(json.Unmarshal([]byte(resultStr), &createResp))
+	assert.Equal(200, createResp.Code)
+	assert.Equal(2, createResp.Version)
+
+	req, err := http.NewRequest("GET", "/api/module/"+moduleID+"/versions", nil)
+	require.NoError(err)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	var listResp ListModuleVersionResp
+	require.NoError(json.Unmarshal(w.Body.Bytes(), &listResp))
+	assert.Equal(200, listResp.Code)
+	require.Equal(2, len(listResp.Data))
+	assert.Equal(2, listResp.Data[0].Version)
+	assert.Equal(1, listResp.Data[1].Version)
+
+	resultStr = post("/api/module/"+moduleID+"/upgrade?version=3", "")
+	assert.Contains(resultStr, "version 3 of module "+moduleID+" does not exist")
+	assert.Contains(resultStr, `"code":404`)
+
+	// The module is not deployed, so it switches to the new version right away, keeping its name and ID.
+	resultStr = post("/api/module/"+moduleID+"/upgrade?version=2", "")
+	assert.Contains(resultStr, "upgraded module "+moduleID+" to version 2")
+	module, err := mgr.Module.QueryByID(moduleID)
+	require.NoError(err)
+	assert.Equal(2, module.Version)
+	assert.Equal("test_module", module.Name)
+	assert.Equal("copy_input_to_output_v2", module.Fn)
+
+	resultStr = post("/api/module/"+moduleID+"/upgrade?version=2", "")
+	assert.Contains(resultStr, "already at version 2")
+	assert.Contains(resultStr, `"code":409`)
+
+	resultStr = post("/api/module/"+moduleID+"/upgrade?version=1", "")
+	assert.Contains(resultStr, "upgraded module "+moduleID+" to version 1")
+	module, err = mgr.Module.QueryByID(moduleID)
+	require.NoError(err)
+	assert.Equal(1, module.Version)
+	assert.Equal("copy_input_to_output", module.Fn)
+}
+
+// Tests that rollingUpgrade upgrades all instances, and rolls back all instances if any agent fails.
+func TestRollingUpgrade(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	r := SetUpRouter("")
+	mgr.upgradeInstanceTimeout = 10 * time.Second
+	defer func() { mgr.upgradeInstanceTimeout = 0 }()
+
+	moduleID := AddModule(t, "test_wasm_uid", r)
+	require.NoError(mgr.Module.UpdateStatusByID(moduleID, int(pb.ModuleState_DEPLOYED)))
+	module, err := mgr.Module.QueryByID(moduleID)
+	require.NoError(err)
+
+	code := *module
+	code.Fn = "copy_input_to_output_v2"
+	code.Version = 2
+	require.NoError(mgr.ModuleVersion.SaveModuleVersion(dao.NewModuleVersion(&code)))
+	target, err := mgr.ModuleVersion.QueryByModuleIDAndVersion(moduleID, 2)
+	require.NoError(err)
+
+	// Key is the agent ID, value is the version that the agent fails to deploy.
+	failVersion := map[string]int{}
+	startAgent := func() string {
+		agentID, err := AddAgent(t, r)
+		require.NoError(err)
+		require.NoError(mgr.ModuleInstance.SaveModuleInstance(&dao.ModuleInstanceGORM{
+			ID:          fmt.Sprintf("tricorder_%s_%s", moduleID, agentID),
+			ModuleID:    moduleID,
+			AgentID:     agentID,
+			DesireState: int(pb.ModuleState_DEPLOYED),
+			State:       int(pb.ModuleInstanceState_SUCCEEDED),
+			Version:     1,
+		}))
+		queue := mgr.dispatcher.Register(agentID)
+		t.Cleanup(func() { mgr.dispatcher.Unregister(agentID, queue) })
+		// Simulates the agent, which reports the result of each dispatched module instance.
+		go func() {
+			for id := range queue {
+				moduleInstance, err := mgr.ModuleInstance.QueryByID(id)
+				if err != nil {
+					continue
+				}
+				if moduleInstance.Version == failVersion[agentID] {
+					_ = mgr.ModuleInstance.UpdateStatusAndDescByID(id, int(pb.ModuleInstanceState_FAILED), "bad version")
+				} else {
+					_ = mgr.ModuleInstance.UpdateStatusAndDescByID(id, int(pb.ModuleInstanceState_SUCCEEDED), "")
+				}
+			}
+		}()
+		return agentID
+	}
+	startAgent()
+	failingAgent := startAgent()
+	failVersion[failingAgent] = 2
+
+	checkInstances := func(version int) {
+		moduleInstances, err := mgr.ModuleInstance.ListByModuleID(moduleID)
+		require.NoError(err)
+		require.Equal(2, len(moduleInstances))
+		for _, moduleInstance := range moduleInstances {
+			assert.Equal(version, moduleInstance.Version)
+			assert.Equal(int(pb.ModuleInstanceState_SUCCEEDED), moduleInstance.State)
+		}
+	}
+
+	mgr.upgrading.Store(moduleID, true)
+	mgr.rollingUpgrade(module, target, false)
+	assert.False(mgr.isUpgrading(moduleID))
+
+	// One agent failed, so all instances are rolled back to version 1.
+	module, err = mgr.Module.QueryByID(moduleID)
+	require.NoError(err)
+	assert.Equal(1, module.Version)
+	checkInstances(1)
+
+	delete(failVersion, failingAgent)
+	mgr.upgrading.Store(moduleID, true)
+	mgr.rollingUpgrade(module, target, false)
+	assert.False(mgr.isUpgrading(moduleID))
+
+	module, err = mgr.Module.QueryByID(moduleID)
+	require.NoError(err)
+	assert.Equal(2, module.Version)
+	assert.Equal("copy_input_to_output_v2", module.Fn)
+	checkInstances(2)
+}
+
+// Tests that upgrading a module fails if any of its agents is not connected, unless the disconnected agents are
+// skipped, then their instances are upgraded when they connect.
+func TestRollingUpgradeDisconnectedAgent(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	r := SetUpRouter("")
+	moduleID := AddModule(t, "test_wasm_uid", r)
+	require.NoError(mgr.Module.UpdateStatusByID(moduleID, int(pb.ModuleState_DEPLOYED)))
+	module, err := mgr.Module.QueryByID(moduleID)
+	require.NoError(err)
+
+	code := *module
+	code.Version = 2
+	require.NoError(mgr.ModuleVersion.SaveModuleVersion(dao.NewModuleVersion(&code)))
+	target, err := mgr.ModuleVersion.QueryByModuleIDAndVersion(moduleID, 2)
+	require.NoError(err)
+
+	agentID, err := AddAgent(t, r)
+	require.NoError(err)
+	instanceID := fmt.Sprintf("tricorder_%s_%s", moduleID, agentID)
+	require.NoError(mgr.ModuleInstance.SaveModuleInstance(&dao.ModuleInstanceGORM{
+		ID:          instanceID,
+		ModuleID:    moduleID,
+		AgentID:     agentID,
+		DesireState: int(pb.ModuleState_DEPLOYED),
+		State:       int(pb.ModuleInstanceState_SUCCEEDED),
+		Version:     1,
+	}))
+
+	resp := mgr.upgradeModule(moduleID, 2, false, false)
+	assert.Equal(http.StatusConflict, resp.Code)
+	assert.Contains(resp.Message, "agents "+agentID+" of module "+moduleID+" are not connected")
+	assert.False(mgr.isUpgrading(moduleID))
+
+	// The agent disconnected after the upgrade started, so the upgrade is rolled back.
+	mgr.upgrading.Store(moduleID, true)
+	mgr.rollingUpgrade(module, target, false)
+	module, err = mgr.Module.QueryByID(moduleID)
+	require.NoError(err)
+	assert.Equal(1, module.Version)
+	moduleInstance, err := mgr.ModuleInstance.QueryByID(instanceID)
+	require.NoError(err)
+	assert.Equal(1, moduleInstance.Version)
+
+	mgr.upgrading.Store(moduleID, true)
+	mgr.rollingUpgrade(module, target, true)
+	module, err = mgr.Module.QueryByID(moduleID)
+	require.NoError(err)
+	assert.Equal(2, module.Version)
+	moduleInstance, err = mgr.ModuleInstance.QueryByID(instanceID)
+	require.NoError(err)
+	assert.Equal(2, moduleInstance.Version)
+}
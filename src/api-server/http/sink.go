@@ -0,0 +1,123 @@
+// Copyright (C) 2023  Tricorder Observability
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"path/filepath"
+
+	"github.com/tricorder/src/api-server/http/dao"
+	commonpb "github.com/tricorder/src/pb/module/common"
+)
+
+// checkSink returns an error if the sink spec is invalid or incomplete. A nil spec is the default Postgres sink.
+func checkSink(spec *commonpb.Sink) error {
+	if spec == nil {
+		return nil
+	}
+	switch spec.Type {
+	case commonpb.Sink_POSTGRES, commonpb.Sink_STDOUT, commonpb.Sink_PROMETHEUS:
+		return nil
+	case commonpb.Sink_FILE:
+		// The agents write the file in their directory of FILE sinks, see --sink_file_dir.
+		if len(spec.Path) == 0 || spec.Path != filepath.Base(spec.Path) || spec.Path == "." || spec.Path == ".." {
+			return fmt.Errorf("invalid sink, path '%s' of FILE sink is not a file name", spec.Path)
+		}
+		if spec.MaxBytes < 0 || spec.MaxFiles < 0 {
+			return errors.New("invalid sink, max_bytes and max_files of FILE sink cannot be negative")
+		}
+		return nil
+	case commonpb.Sink_OTLP, commonpb.Sink_OTLP_METRICS:
+		u, err := url.Parse(spec.Endpoint)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || len(u.Host) == 0 {
+			return fmt.Errorf("invalid sink, endpoint '%s' of %s sink is not a HTTP URL", spec.Endpoint, spec.Type)
+		}
+		if spec.PushIntervalSeconds < 0 {
+			return errors.New("invalid sink, push_interval_seconds cannot be negative")
+		}
+		return nil
+	}
+	return fmt.Errorf("invalid sink, type '%s' is not supported", spec.Type)
+}
+
+// The types of the fields that can be the values of metrics.
+var metricValueTypes = map[commonpb.DataField_Type]bool{
+	commonpb.DataField_BOOL:    true,
+	commonpb.DataField_INT:     true,
+	commonpb.DataField_INTEGER: true,
+	commonpb.DataField_BIGINT:  true,
+	commonpb.DataField_DOUBLE:  true,
+}
+
+// checkMetricFields returns an error if the fields declare invalid metrics, or if the output is written to a metrics
+// sink but no field is a metric value.
+func checkMetricFields(fields []*commonpb.DataField, spec *commonpb.Sink) error {
+	values := 0
+	for _, f := range fields {
+		if f.Metric != commonpb.DataField_HISTOGRAM && len(f.Buckets) > 0 {
+			return fmt.Errorf("invalid metric, field '%s' has buckets but is not a HISTOGRAM", f.Name)
+		}
+		switch f.Metric {
+		case commonpb.DataField_NOT_METRIC:
+			continue
+		case commonpb.DataField_LABEL:
+			if f.Array {
+				return fmt.Errorf("invalid metric, LABEL field '%s' cannot be an array", f.Name)
+			}
+			continue
+		case commonpb.DataField_COUNTER, commonpb.DataField_GAUGE, commonpb.DataField_HISTOGRAM:
+		default:
+			return fmt.Errorf("invalid metric, metric '%s' of field '%s' is not supported", f.Metric, f.Name)
+		}
+		if f.Array || !metricValueTypes[f.Type] {
+			return fmt.Errorf("invalid metric, %s field '%s' has type %s, needs BOOL, INT, INTEGER, BIGINT or DOUBLE",
+				f.Metric, f.Name, f.Type)
+		}
+		for i := 1; i < len(f.Buckets); i++ {
+			if f.Buckets[i] <= f.Buckets[i-1] {
+				return fmt.Errorf("invalid metric, buckets of HISTOGRAM field '%s' are not increasing", f.Name)
+			}
+		}
+		values++
+	}
+	isMetricsSink := spec.GetType() == commonpb.Sink_PROMETHEUS || spec.GetType() == commonpb.Sink_OTLP_METRICS
+	if isMetricsSink && values == 0 {
+		return fmt.Errorf("invalid sink, %s sink needs at least one COUNTER, GAUGE or HISTOGRAM field", spec.Type)
+	}
+	return nil
+}
+
+// moduleSink returns the sink spec of the module, nil if the module writes to the default Postgres sink.
+func moduleSink(module *dao.ModuleGORM) (*commonpb.Sink, error) {
+	if len(module.Sink) == 0 {
+		return nil, nil
+	}
+	spec := new(commonpb.Sink)
+	if err := json.Unmarshal([]byte(module.Sink), spec); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal sink of module '%s', error: %v", module.ID, err)
+	}
+	return spec, nil
+}
+
+// writesToPostgres returns true if the module writes its output to its data table, which then has to be created.
+// A module with a malformed sink is treated as writing to Postgres, it fails to be deployed to agents anyway.
+func writesToPostgres(module *dao.ModuleGORM) bool {
+	spec, err := moduleSink(module)
+	return err != nil || spec.GetType() == commonpb.Sink_POSTGRES
+}
@@ -0,0 +1,122 @@
+// Copyright (C) 2023  Tricorder Observability
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package http
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/tricorder/src/api-server/http/dao"
+	commonpb "github.com/tricorder/src/pb/module/common"
+)
+
+// Tests that checkSink() accepts complete specs and rejects the others.
+func TestCheckSink(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Nil(checkSink(nil))
+	assert.Nil(checkSink(&commonpb.Sink{}))
+	assert.Nil(checkSink(&commonpb.Sink{Type: commonpb.Sink_STDOUT}))
+	assert.Nil(checkSink(&commonpb.Sink{Type: commonpb.Sink_FILE, Path: "out.jsonl", MaxFiles: 3}))
+	assert.Nil(checkSink(&commonpb.Sink{Type: commonpb.Sink_OTLP, Endpoint: "http://collector:4318/v1/logs"}))
+
+	for _, path := range []string{"", "/var/log/out.jsonl", "../out.jsonl", "a/out.jsonl", ".", ".."} {
+		assert.ErrorContains(checkSink(&commonpb.Sink{Type: commonpb.Sink_FILE, Path: path}), "not a file name", path)
+	}
+	assert.ErrorContains(checkSink(&commonpb.Sink{Type: commonpb.Sink_FILE, Path: "out.jsonl", MaxBytes: -1}),
+		"cannot be negative")
+	assert.ErrorContains(checkSink(&commonpb.Sink{Type: commonpb.Sink_OTLP}), "not a HTTP URL")
+	assert.ErrorContains(checkSink(&commonpb.Sink{Type: commonpb.Sink_OTLP, Endpoint: "collector:4318"}),
+		"not a HTTP URL")
+	assert.ErrorContains(checkSink(&commonpb.Sink{Type: 100}), "type '100' is not supported")
+
+	assert.Nil(checkSink(&commonpb.Sink{Type: commonpb.Sink_PROMETHEUS}))
+	assert.Nil(checkSink(&commonpb.Sink{Type: commonpb.Sink_OTLP_METRICS, Endpoint: "https://collector/v1/metrics"}))
+	assert.ErrorContains(checkSink(&commonpb.Sink{Type: commonpb.Sink_OTLP_METRICS}),
+		"of OTLP_METRICS sink is not a HTTP URL")
+	assert.ErrorContains(checkSink(&commonpb.Sink{
+		Type: commonpb.Sink_OTLP_METRICS, Endpoint: "https://collector/v1/metrics", PushIntervalSeconds: -1,
+	}), "push_interval_seconds cannot be negative")
+}
+
+// Tests that checkMetricFields() accepts valid metrics, and rejects the invalid ones.
+func TestCheckMetricFields(t *testing.T) {
+	assert := assert.New(t)
+
+	label := &commonpb.DataField{Name: "comm", Type: commonpb.DataField_TEXT, Metric: commonpb.DataField_LABEL}
+	counter := &commonpb.DataField{Name: "bytes", Type: commonpb.DataField_BIGINT, Metric: commonpb.DataField_COUNTER}
+	histogram := &commonpb.DataField{
+		Name: "latency", Type: commonpb.DataField_DOUBLE, Metric: commonpb.DataField_HISTOGRAM,
+		Buckets: []float64{0.1, 1},
+	}
+	prometheus := &commonpb.Sink{Type: commonpb.Sink_PROMETHEUS}
+
+	assert.Nil(checkMetricFields([]*commonpb.DataField{label, counter, histogram}, prometheus))
+	assert.Nil(checkMetricFields([]*commonpb.DataField{label}, nil))
+	assert.ErrorContains(checkMetricFields([]*commonpb.DataField{label}, prometheus),
+		"PROMETHEUS sink needs at least one COUNTER, GAUGE or HISTOGRAM field")
+
+	cases := []struct {
+		field *commonpb.DataField
+		err   string
+	}{
+		{
+			&commonpb.DataField{Name: "f", Type: commonpb.DataField_TEXT, Metric: commonpb.DataField_GAUGE},
+			"GAUGE field 'f' has type TEXT",
+		},
+		{
+			&commonpb.DataField{Name: "f", Type: commonpb.DataField_INT, Array: true, Metric: commonpb.DataField_COUNTER},
+			"COUNTER field 'f' has type INT",
+		},
+		{
+			&commonpb.DataField{Name: "f", Type: commonpb.DataField_TEXT, Array: true, Metric: commonpb.DataField_LABEL},
+			"LABEL field 'f' cannot be an array",
+		},
+		{
+			&commonpb.DataField{Name: "f", Type: commonpb.DataField_INT, Buckets: []float64{1}},
+			"field 'f' has buckets but is not a HISTOGRAM",
+		},
+		{
+			&commonpb.DataField{
+				Name: "f", Type: commonpb.DataField_INT, Metric: commonpb.DataField_HISTOGRAM, Buckets: []float64{1, 1},
+			},
+			"buckets of HISTOGRAM field 'f' are not increasing",
+		},
+		{
+			&commonpb.DataField{Name: "f", Type: commonpb.DataField_INT, Metric: 100},
+			"metric '100' of field 'f' is not supported",
+		},
+	}
+	for _, c := range cases {
+		assert.ErrorContains(checkMetricFields([]*commonpb.DataField{label, counter, c.field}, prometheus), c.err)
+	}
+}
+
+// Tests that writesToPostgres() returns false only for the modules with other sinks.
+func TestWritesToPostgres(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.True(writesToPostgres(&dao.ModuleGORM{}))
+	assert.True(writesToPostgres(&dao.ModuleGORM{Sink: `{}`}))
+	assert.True(writesToPostgres(&dao.ModuleGORM{Sink: `not json`}))
+	assert.False(writesToPostgres(&dao.ModuleGORM{Sink: `{"type":2}`}))
+
+	spec, err := moduleSink(&dao.ModuleGORM{Sink: `{"type":3,"endpoint":"http://collector:4318/v1/logs"}`})
+	assert.Nil(err)
+	assert.Equal(commonpb.Sink_OTLP, spec.Type)
+	assert.Equal("http://collector:4318/v1/logs", spec.Endpoint)
+}
@@ -0,0 +1,170 @@
+// Copyright (C) 2023  Tricorder Observability
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package http
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/tricorder/src/api-server/http/dao"
+	commonpb "github.com/tricorder/src/pb/module/common"
+	"github.com/tricorder/src/utils/pg"
+)
+
+// checkTableKeys returns an error if the primary key or the indexes refer to unknown or duplicated columns of the
+// data table of the fields, or if the indexes are invalid.
+func checkTableKeys(fields []*commonpb.DataField, primaryKey []string, indexes []*commonpb.Index) error {
+	columns := make(map[string]*commonpb.DataField, len(fields)+len(pg.ReservedColumns))
+	for _, f := range fields {
+		columns[pg.NormalizeIdentifier(f.Name)] = f
+	}
+	for _, col := range pg.ReservedColumns {
+		columns[col.Name] = &commonpb.DataField{Name: col.Name, Type: col.Type}
+	}
+
+	checkColumns := func(what string, names []string, equality bool) error {
+		if len(names) == 0 {
+			return fmt.Errorf("invalid %s, columns are empty", what)
+		}
+		seen := make(map[string]bool, len(names))
+		for _, name := range names {
+			lower := pg.NormalizeIdentifier(name)
+			f, ok := columns[lower]
+			if !ok {
+				return fmt.Errorf("invalid %s, column '%s' is neither a field nor a reserved column", what, name)
+			}
+			if seen[lower] {
+				return fmt.Errorf("invalid %s, column '%s' is duplicated", what, name)
+			}
+			seen[lower] = true
+			// JSON values cannot be compared for equality, so they can be indexed only by gin and gist.
+			if equality && f.Type == commonpb.DataField_JSON {
+				return fmt.Errorf("invalid %s, column '%s' has type JSON, which cannot be compared, use JSONB instead",
+					what, name)
+			}
+		}
+		return nil
+	}
+
+	if len(primaryKey) > 0 {
+		err := checkColumns("primary key", primaryKey, true)
+		if err != nil {
+			return err
+		}
+	}
+	names := make(map[string]bool, len(indexes))
+	for i, index := range indexes {
+		what := fmt.Sprintf("index #%d", i)
+		if len(index.Name) > 0 {
+			if err := pg.ValidateIdentifier(index.Name); err != nil {
+				return fmt.Errorf("invalid %s name, %v", what, err)
+			}
+			what = fmt.Sprintf("index '%s'", index.Name)
+			if names[pg.NormalizeIdentifier(index.Name)] {
+				return fmt.Errorf("invalid %s, the name is duplicated", what)
+			}
+			names[pg.NormalizeIdentifier(index.Name)] = true
+		}
+		method := strings.ToLower(index.Method)
+		if len(method) > 0 && !pg.IndexMethods[method] {
+			return fmt.Errorf("invalid %s, method '%s' is not supported", what, index.Method)
+		}
+		if index.Unique && len(method) > 0 && method != "btree" {
+			return fmt.Errorf("invalid %s, only btree indexes can be unique", what)
+		}
+		err := checkColumns(what, index.Columns, method == "" || method == "btree" || method == "hash")
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkHypertableKeys returns an error if the primary key or a unique index does not include the hypertable's time
+// column, which TimescaleDB requires, as it enforces uniqueness within each time partition.
+func checkHypertableKeys(primaryKey []string, indexes []*commonpb.Index, spec *commonpb.Hypertable) error {
+	includesTimeColumn := func(columns []string) bool {
+		for _, col := range columns {
+			if strings.EqualFold(col, spec.TimeColumn) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(primaryKey) > 0 && !includesTimeColumn(primaryKey) {
+		return fmt.Errorf("invalid hypertable, primary key does not include time_column '%s'", spec.TimeColumn)
+	}
+	for i, index := range indexes {
+		if index.Unique && !includesTimeColumn(index.Columns) {
+			return fmt.Errorf("invalid hypertable, unique index #%d does not include time_column '%s'",
+				i, spec.TimeColumn)
+		}
+	}
+	return nil
+}
+
+// checkVersionHypertableKeys returns an error if the primary key or indexes of a new version's schema are invalid for
+// the module's hypertable spec.
+func checkVersionHypertableKeys(hypertable string, schema *commonpb.Schema) error {
+	spec := new(commonpb.Hypertable)
+	err := json.Unmarshal([]byte(hypertable), spec)
+	if err != nil {
+		return fmt.Errorf("failed to unmarshal hypertable, error: %v", err)
+	}
+	return checkHypertableKeys(schema.PrimaryKey, schema.Indexes, spec)
+}
+
+// unmarshalTableKeys returns the primary key and indexes stored in the module.
+func unmarshalTableKeys(module *dao.ModuleGORM) ([]string, []*commonpb.Index, error) {
+	var primaryKey []string
+	var indexes []*commonpb.Index
+	if len(module.PrimaryKey) > 0 {
+		err := json.Unmarshal([]byte(module.PrimaryKey), &primaryKey)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to unmarshal primary key, error: %v", err)
+		}
+	}
+	if len(module.Indexes) > 0 {
+		err := json.Unmarshal([]byte(module.Indexes), &indexes)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to unmarshal indexes, error: %v", err)
+		}
+	}
+	return primaryKey, indexes, nil
+}
+
+// moduleDataSchema returns the schema of the module's data table, with the reserved columns.
+func moduleDataSchema(module *dao.ModuleGORM) (*pg.Schema, error) {
+	columns, err := moduleDataColumns(module.SchemaAttr)
+	if err != nil {
+		return nil, err
+	}
+	primaryKey, indexes, err := unmarshalTableKeys(module)
+	if err != nil {
+		return nil, err
+	}
+	schema := &pg.Schema{
+		Name:       getModuleDataTableName(module),
+		Columns:    columns,
+		PrimaryKey: primaryKey,
+	}
+	for _, index := range indexes {
+		schema.Indexes = append(schema.Indexes, pg.IndexFromPB(index))
+	}
+	// Agents might enrich the records with the reserved columns.
+	return pg.WithReservedColumns(schema), nil
+}
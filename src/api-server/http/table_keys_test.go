@@ -0,0 +1,117 @@
+// Copyright (C) 2023  Tricorder Observability
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package http
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	commonpb "github.com/tricorder/src/pb/module/common"
+)
+
+// Tests that checkTableKeys accepts the keys on the fields and reserved columns, and rejects invalid ones.
+func TestCheckTableKeys(t *testing.T) {
+	assert := assert.New(t)
+
+	fields := []*commonpb.DataField{
+		{Name: "ID", Type: commonpb.DataField_TEXT},
+		{Name: "data", Type: commonpb.DataField_JSON},
+		{Name: "tags", Type: commonpb.DataField_TEXT, Array: true},
+	}
+	index := func(method string, unique bool, columns ...string) *commonpb.Index {
+		return &commonpb.Index{Columns: columns, Unique: unique, Method: method}
+	}
+
+	assert.Nil(checkTableKeys(fields, nil, nil))
+	assert.Nil(checkTableKeys(fields, []string{"id", "_ingest_time"}, []*commonpb.Index{
+		index("", false, "_node_name"),
+		index("gin", false, "tags"),
+		index("gist", false, "data"),
+		index("btree", true, "ID", "_agent_id"),
+	}))
+
+	assert.ErrorContains(checkTableKeys(fields, []string{"pid"}, nil),
+		"invalid primary key, column 'pid' is neither a field nor a reserved column")
+	assert.ErrorContains(checkTableKeys(fields, []string{"id", "ID"}, nil),
+		"invalid primary key, column 'ID' is duplicated")
+	assert.ErrorContains(checkTableKeys(fields, []string{"data"}, nil),
+		"invalid primary key, column 'data' has type JSON")
+	assert.ErrorContains(checkTableKeys(fields, nil, []*commonpb.Index{index("", false)}),
+		"invalid index #0, columns are empty")
+	assert.ErrorContains(checkTableKeys(fields, nil, []*commonpb.Index{index("rtree", false, "id")}),
+		"invalid index #0, method 'rtree' is not supported")
+	assert.ErrorContains(checkTableKeys(fields, nil, []*commonpb.Index{index("gin", true, "tags")}),
+		"invalid index #0, only btree indexes can be unique")
+	assert.ErrorContains(checkTableKeys(fields, nil, []*commonpb.Index{
+		{Name: "i", Columns: []string{"id"}},
+		{Name: "I", Columns: []string{"tags"}},
+	}), "invalid index 'I', the name is duplicated")
+}
+
+// Tests that checkHypertableKeys requires the primary key and unique indexes to include the time column.
+func TestCheckHypertableKeys(t *testing.T) {
+	assert := assert.New(t)
+
+	spec := &commonpb.Hypertable{TimeColumn: "_ingest_time"}
+	assert.Nil(checkHypertableKeys([]string{"id", "_INGEST_TIME"}, []*commonpb.Index{
+		{Columns: []string{"id"}},
+		{Columns: []string{"id", "_ingest_time"}, Unique: true},
+	}, spec))
+	assert.ErrorContains(checkHypertableKeys([]string{"id"}, nil, spec),
+		"invalid hypertable, primary key does not include time_column '_ingest_time'")
+	assert.ErrorContains(checkHypertableKeys(nil, []*commonpb.Index{{Columns: []string{"id"}, Unique: true}}, spec),
+		"invalid hypertable, unique index #0 does not include time_column '_ingest_time'")
+}
+
+// Tests that creating a module with a primary key on an unknown column fails.
+func TestCreateModuleInvalidPrimaryKey(t *testing.T) {
+	assert := assert.New(t)
+
+	moduleBody := `{
+		"name": "test_module",
+		"wasm":{
+			"code": "",
+			"fn_name":"copy_input_to_output",
+			"fmt":    1,
+			"output_schema":{
+				"name":"test_tabel_name",
+				"fields":[{"name":"id","type":6,"not_null":true}],
+				"primary_key":["pid"]
+			}
+		},
+		"ebpf":{
+			"code": "",
+			"perf_buffer_name":"events",
+			"probes":[{"target":"","entry":"sample_json","return":""}]
+		}
+	}`
+
+	r := SetUpRouter("")
+
+	r.POST("/api/createModule", mgr.createModuleHttp)
+	req, _ := http.NewRequest("POST", "/api/createModule", bytes.NewBufferString(moduleBody))
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(`{"code":400,"message":"invalid primary key, column 'pid' is neither a field nor a reserved column"}`,
+		w.Body.String())
+}
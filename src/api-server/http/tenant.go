@@ -0,0 +1,133 @@
+// Copyright (C) 2023  Tricorder Observability
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package http
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/tricorder/src/utils/pg"
+
+	"github.com/tricorder/src/api-server/auth"
+	"github.com/tricorder/src/api-server/http/api"
+	"github.com/tricorder/src/api-server/http/dao"
+	"github.com/tricorder/src/api-server/tenant"
+)
+
+// principalTenant returns the tenant of the request's principal, or empty if the principal manages the modules of all
+// tenants, which includes the requests when authentication is disabled.
+func principalTenant(c *gin.Context) string {
+	if p, ok := c.Get(principalKey); ok {
+		return p.(*auth.Principal).Tenant
+	}
+	return ""
+}
+
+// tenantFilter returns the tenant whose modules are listed by the request, or nil to list the modules of all
+// tenants. The principals of a tenant only list the modules of their tenant, the others list the modules of the
+// tenant in the 'tenant' query parameter, if present.
+func tenantFilter(c *gin.Context) *string {
+	if t := principalTenant(c); len(t) > 0 {
+		return &t
+	}
+	if t, ok := c.GetQuery("tenant"); ok {
+		return &t
+	}
+	return nil
+}
+
+// requireModuleOfTenant returns a handler that responds with 404 to the requests on a module of another tenant than
+// the principal's, as if the module did not exist. The module ID is the path parameter, without the custom method,
+// or the 'id' query parameter of the v1 APIs. The requests on the modules that do not exist are handled as usual.
+func (mgr *ModuleManager) requireModuleOfTenant() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal := principalTenant(c)
+		if len(principal) == 0 {
+			return
+		}
+		id := c.Param(api.MODULE_ID_PARAM)
+		if len(id) == 0 {
+			id = c.Query("id")
+		}
+		if i := strings.LastIndex(id, api.ACTION_OP); i >= 0 {
+			id = id[:i]
+		}
+		module, err := mgr.Module.QueryByID(id)
+		if err != nil {
+			abortWithError(c, http.StatusInternalServerError, "Query Error: "+err.Error())
+			return
+		}
+		if module != nil && module.Tenant != principal {
+			abortWithError(c, http.StatusNotFound, "module "+id+" does not exist")
+		}
+	}
+}
+
+// requireNoTenant returns a handler that responds with 403 to the requests of the principals of a tenant.
+func (mgr *ModuleManager) requireNoTenant() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if t := principalTenant(c); len(t) > 0 {
+			abortWithError(c, http.StatusForbidden, "not allowed for the tokens of tenant '"+t+"'")
+		}
+	}
+}
+
+// resolveTenant sets the tenant of the module created by the request to the principal's tenant, if the principal
+// belongs to one. Returns an error with 403 if the request names another tenant.
+func resolveTenant(c *gin.Context, body *CreateModuleReq) error {
+	principal := principalTenant(c)
+	if len(principal) == 0 {
+		return nil
+	}
+	if len(body.Tenant) > 0 && body.Tenant != principal {
+		return newStatusError(http.StatusForbidden, "cannot create modules in tenant '%s', the token belongs to "+
+			"tenant '%s'", body.Tenant, principal)
+	}
+	body.Tenant = principal
+	return nil
+}
+
+// checkTenant returns an error with 400 if the modules cannot be created in the tenant.
+func (mgr *ModuleManager) checkTenant(name string) error {
+	if len(name) == 0 {
+		return nil
+	}
+	if err := tenant.ValidateName(name); err != nil {
+		return newStatusError(http.StatusBadRequest, "%v", err)
+	}
+	if !mgr.tenants.Exists(name) {
+		return newStatusError(http.StatusBadRequest, "tenant '%s' is not configured", name)
+	}
+	return nil
+}
+
+// getModuleDataTableName returns the name of the module's data table, tricorder_module_{moduleID}, in the Postgres
+// schema of the module's tenant, if any.
+func getModuleDataTableName(module *dao.ModuleGORM) string {
+	const moduleDataTableNamePrefix = "tricorder_module_"
+	table := moduleDataTableNamePrefix + module.ID
+	if len(module.Tenant) == 0 {
+		return table
+	}
+	return pg.QualifyTableName(tenant.SchemaName(module.Tenant), table)
+}
+
+// tenantFolderUID returns the UID of the Grafana folder of the dashboards of the tenant's modules.
+func tenantFolderUID(name string) string {
+	return "starship-tenant-" + name
+}
@@ -0,0 +1,160 @@
+// Copyright (C) 2023  Tricorder Observability
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package http
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/tricorder/src/api-server/auth"
+	"github.com/tricorder/src/api-server/http/api"
+	"github.com/tricorder/src/api-server/http/dao"
+	"github.com/tricorder/src/api-server/tenant"
+	commonpb "github.com/tricorder/src/pb/module/common"
+	"github.com/tricorder/src/pb/module/ebpf"
+	"github.com/tricorder/src/pb/module/wasm"
+)
+
+// Tests that the tokens of a tenant only see and manage the modules of their tenant, and that the modules of a tenant
+// are only deployed onto the tenant's nodes.
+func TestTenants(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	tokensFile := filepath.Join(t.TempDir(), "tokens.yaml")
+	require.Nil(os.WriteFile(tokensFile, []byte(`
+tokens:
+  - name: admin
+    role: admin
+    token: admin-token
+  - name: team-a
+    role: admin
+    tenant: team_a
+    token: team-a-token
+  - name: team-b
+    role: operator
+    tenant: team_b
+    token: team-b-token
+`), 0o600))
+	authenticator, err := auth.NewAuthenticator(auth.Config{TokensFile: tokensFile})
+	require.Nil(err)
+	tenants, err := tenant.New(
+		tenant.Tenant{Name: "team_a", Nodes: []string{"team-a-*"}},
+		tenant.Tenant{Name: "team_b"},
+	)
+	require.Nil(err)
+
+	mgr := newTestModuleManager(t)
+	mgr.authenticator = authenticator
+	mgr.tenants = tenants
+	require.Nil(mgr.NodeAgent.SaveAgent(&dao.NodeAgentGORM{AgentID: "a1", NodeName: "team-a-1"}))
+	require.Nil(mgr.NodeAgent.SaveAgent(&dao.NodeAgentGORM{AgentID: "b1", NodeName: "team-b-1"}))
+	router := gin.New()
+	mgr.registerV2(router)
+
+	serve := func(method, path, token string, body any, resp any) int {
+		var reqBody bytes.Buffer
+		if body != nil {
+			require.Nil(json.NewEncoder(&reqBody).Encode(body))
+		}
+		req := httptest.NewRequest(method, path, &reqBody)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		if resp != nil {
+			require.Nil(json.Unmarshal(w.Body.Bytes(), resp), w.Body.String())
+		}
+		return w.Code
+	}
+	moduleReq := func(tenant string) CreateModuleReq {
+		return CreateModuleReq{
+			Name:   "stdout",
+			Tenant: tenant,
+			Wasm: &wasm.Program{
+				Fmt:    commonpb.Format_BINARY,
+				Code:   []byte("wasm"),
+				FnName: "fn",
+				OutputSchema: &commonpb.Schema{
+					Fields: []*commonpb.DataField{{Name: "data", Type: commonpb.DataField_JSONB}},
+				},
+				Sink: &commonpb.Sink{Type: commonpb.Sink_STDOUT},
+			},
+			Ebpf: &ebpf.Program{Code: "ebpf"},
+		}
+	}
+
+	// The same name is created once in each tenant, the team-a token creates its modules in its tenant.
+	var idResp ModuleIDResp
+	require.Equal(http.StatusCreated, serve("POST", api.MODULES_V2_PATH, "team-a-token", moduleReq(""), &idResp))
+	idA := idResp.ID
+	require.Equal(http.StatusCreated, serve("POST", api.MODULES_V2_PATH, "admin-token", moduleReq("team_b"), &idResp))
+	idB := idResp.ID
+	require.Equal(http.StatusCreated, serve("POST", api.MODULES_V2_PATH, "admin-token", moduleReq(""), &idResp))
+	idNone := idResp.ID
+	assert.Equal(http.StatusConflict, serve("POST", api.MODULES_V2_PATH, "team-a-token", moduleReq(""), nil))
+	assert.Equal(http.StatusForbidden, serve("POST", api.MODULES_V2_PATH, "team-a-token", moduleReq("team_b"), nil))
+	assert.Equal(http.StatusBadRequest, serve("POST", api.MODULES_V2_PATH, "admin-token", moduleReq("team_c"), nil))
+	assert.Equal(http.StatusBadRequest, serve("POST", api.MODULES_V2_PATH, "admin-token", moduleReq("Team-A"), nil))
+
+	var module dao.ModuleGORM
+	require.Equal(http.StatusOK, serve("GET", api.GetModuleV2Path(idA), "team-a-token", nil, &module))
+	assert.Equal("team_a", module.Tenant)
+	assert.Equal("tenant_team_a.tricorder_module_"+idA, module.SchemaName)
+	// The modules of other tenants look like they do not exist.
+	assert.Equal(http.StatusNotFound, serve("GET", api.GetModuleV2Path(idB), "team-a-token", nil, nil))
+	assert.Equal(http.StatusNotFound, serve("GET", api.GetModuleV2Path(idNone), "team-a-token", nil, nil))
+	assert.Equal(http.StatusNotFound, serve("DELETE", api.GetModuleV2Path(idB), "team-a-token", nil, nil))
+	assert.Equal(http.StatusNotFound,
+		serve("POST", api.GetModuleActionV2Path(idB, api.DEPLOY_ACTION), "team-a-token", nil, nil))
+	require.Equal(http.StatusOK, serve("GET", api.GetModuleV2Path(idB), "admin-token", nil, &module))
+	assert.Equal("tenant_team_b.tricorder_module_"+idB, module.SchemaName)
+
+	var modules ModulePage
+	require.Equal(http.StatusOK, serve("GET", api.MODULES_V2_PATH+"?tenant=team_b", "team-a-token", nil, &modules))
+	require.Len(modules.Data, 1)
+	assert.Equal(idA, modules.Data[0].ID)
+	modules = ModulePage{}
+	require.Equal(http.StatusOK, serve("GET", api.MODULES_V2_PATH+"?tenant=team_b", "admin-token", nil, &modules))
+	require.Len(modules.Data, 1)
+	assert.Equal(idB, modules.Data[0].ID)
+	modules = ModulePage{}
+	require.Equal(http.StatusOK, serve("GET", api.MODULES_V2_PATH, "admin-token", nil, &modules))
+	assert.Equal(int64(3), modules.Total)
+
+	// The module of team_a is only deployed onto the nodes of team_a, team_b owns no nodes.
+	require.Equal(http.StatusAccepted,
+		serve("POST", api.GetModuleActionV2Path(idA, api.DEPLOY_ACTION), "team-a-token", nil, nil))
+	instances, err := mgr.ModuleInstance.ListByModuleID(idA)
+	require.Nil(err)
+	require.Len(instances, 1)
+	assert.Equal("a1", instances[0].AgentID)
+	var errResp ErrorResp
+	assert.Equal(http.StatusConflict,
+		serve("POST", api.GetModuleActionV2Path(idB, api.DEPLOY_ACTION), "team-b-token", nil, &errResp))
+	assert.Contains(errResp.Error.Message, "owns no nodes")
+
+	assert.Equal(http.StatusForbidden, serve("GET", api.AUDIT_V2_PATH, "team-a-token", nil, nil))
+	assert.Equal(http.StatusOK, serve("GET", api.AUDIT_V2_PATH, "admin-token", nil, nil))
+}
@@ -16,12 +16,15 @@
 package http
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
 
+	"github.com/tricorder/src/api-server/catalog"
 	"github.com/tricorder/src/api-server/http/dao"
+	"github.com/tricorder/src/pb/module"
 	commonpb "github.com/tricorder/src/pb/module/common"
 	"github.com/tricorder/src/pb/module/ebpf"
 	"github.com/tricorder/src/pb/module/wasm"
@@ -43,10 +46,71 @@ type CreateModuleReq struct {
 	Name string        `json:"name"`
 	Wasm *wasm.Program `json:"wasm"`
 	Ebpf *ebpf.Program `json:"ebpf"`
+	// Optional, the signature of the module made by the client, see signing.Sign().
+	Signature *module.Signature `json:"signature,omitempty"`
+	// Optional, the tenant of the module, the tenant of the request's token if empty. The name is unique in the tenant.
+	Tenant string `json:"tenant,omitempty"`
+}
+
+// UpdateModuleReq is the body of PATCH /api/v2/modules/{id}, the omitted fields keep their current values.
+type UpdateModuleReq struct {
+	Name string `json:"name,omitempty"`
+	// The code, with its fmt and lang, the perf buffer name, and the probes each replace the current ones if not empty.
+	Ebpf *ebpf.Program `json:"ebpf,omitempty"`
+	// The code, with its fmt and lang, the function name, the output schema and the sink each replace the current ones
+	// if not empty.
+	Wasm *wasm.Program `json:"wasm,omitempty"`
+	// Optional, the signature of the updated code. The current signature is dropped if the code changes.
+	Signature *module.Signature `json:"signature,omitempty"`
 }
 
 type CreateModuleResp struct {
 	HTTPResp
+	// The ID of the created module.
+	ID string `json:"id,omitempty"`
+}
+
+// ValidateModuleResp is the result of validating a module, whose Code is 400 if the module is invalid.
+type ValidateModuleResp struct {
+	HTTPResp
+	// The problems that would fail creating or deploying the module, empty if the module is valid.
+	Errors []string `json:"errors,omitempty"`
+	// The nodes whose agents would run the module if it were deployed now.
+	Nodes []string `json:"nodes"`
+}
+
+// ListCatalogResp is the modules bundled with API Server.
+type ListCatalogResp struct {
+	HTTPResp
+	Data []*catalog.Entry `json:"data"`
+}
+
+// CreateFromCatalogReq creates a module from a catalog entry.
+type CreateFromCatalogReq struct {
+	// Optional, the name of the created module, the name of the catalog entry if empty.
+	Name string `json:"name"`
+	// Optional, the values of the entry's parameters, the parameters not set have their default values.
+	Parameters map[string]string `json:"parameters,omitempty"`
+	// Optional, the tenant of the created module, the tenant of the request's token if empty.
+	Tenant string `json:"tenant,omitempty"`
+}
+
+type CreateModuleVersionResp struct {
+	HTTPResp
+	Version int `json:"version"`
+}
+
+type ListModuleVersionResp struct {
+	HTTPResp
+	Data []dao.ModuleVersionGORM `json:"data"`
+}
+
+type UpgradeModuleResp struct {
+	HTTPResp
+
+	// The agents that are not connected, whose instances of the module are upgraded when they connect.
+	// Only set if the upgrade skips the disconnected agents.
+	Pending []string `json:"pending,omitempty"`
 }
 
 type ListModuleReq struct {
@@ -56,6 +120,8 @@ type ListModuleReq struct {
 	// returned information.
 	// TODO(yzhao): Change to string slice.
 	Fields string
+	// Only the modules of this tenant are returned if not empty, see the 'tenant' query parameter.
+	Tenant string
 }
 
 type ListModuleResp struct {
@@ -77,6 +143,11 @@ type ListAgentResp struct {
 	Data []dao.NodeAgentGORM `json:"data"`
 }
 
+type ListModuleInstanceResp struct {
+	HTTPResp
+	Data []dao.ModuleInstanceGORM `json:"data"`
+}
+
 type DeployModuleResp struct {
 	HTTPResp
 	UID string `json:"uid"`
@@ -90,6 +161,77 @@ type DeleteModuleResp struct {
 	HTTPResp
 }
 
+// ErrorResp is the body of the v2 API's responses with a 4xx or 5xx status code.
+type ErrorResp struct {
+	Error ErrorDetail `json:"error"`
+}
+
+type ErrorDetail struct {
+	// The HTTP status code of the response.
+	Code int `json:"code"`
+
+	// A human readable message explain the failure.
+	Message string `json:"message"`
+}
+
+// Page describes which part of the matching items is returned by the v2 API's list responses.
+type Page struct {
+	// The total number of items matching the filters.
+	Total int64 `json:"total"`
+	// The maximal number of items returned, and the number of matching items skipped before them.
+	Limit  int `json:"limit"`
+	Offset int `json:"offset"`
+}
+
+type ModulePage struct {
+	Page
+	Data []dao.ModuleGORM `json:"data"`
+}
+
+type AgentPage struct {
+	Page
+	Data []dao.NodeAgentGORM `json:"data"`
+}
+
+type AuditPage struct {
+	Page
+	Data []dao.AuditGORM `json:"data"`
+}
+
+type ModuleIDResp struct {
+	ID string `json:"id"`
+}
+
+type ModuleActionResp struct {
+	ID string `json:"id"`
+	// The UID of the module's Grafana dashboard, set after deploying a module that writes to Postgres.
+	UID string `json:"uid,omitempty"`
+}
+
+// statusError is an error that carries the HTTP status code of the failure, like 404 if the module does not exist.
+type statusError struct {
+	code int
+	msg  string
+}
+
+func (e *statusError) Error() string {
+	return e.msg
+}
+
+// newStatusError returns an error with the HTTP status code and the formatted message.
+func newStatusError(code int, format string, args ...any) error {
+	return &statusError{code: code, msg: fmt.Sprintf(format, args...)}
+}
+
+// statusCode returns the HTTP status code carried by err, or 500 if err does not carry one.
+func statusCode(err error) int {
+	var e *statusError
+	if errors.As(err, &e) {
+		return e.code
+	}
+	return http.StatusInternalServerError
+}
+
 func checkQuery(c *gin.Context, key string) (string, error) {
 	val, exist := c.GetQuery(key)
 	if !exist {
@@ -102,10 +244,7 @@ func checkQuery(c *gin.Context, key string) (string, error) {
 
 // DataFieldToPGColumn returns Column from a DataField protobuf message.
 func DataFieldToPGColumn(dataField *commonpb.DataField) (pg.Column, error) {
-	return pg.Column{
-		Name: dataField.Name,
-		Type: dataField.Type,
-	}, nil
+	return pg.ColumnFromPB(dataField), nil
 }
 
 // DataFieldsToPGColumns returns a slice of pg.Column for the input DataField slice.
@@ -0,0 +1,204 @@
+// Copyright (C) 2023  Tricorder Observability
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package http
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/tricorder/src/api-server/http/api"
+	"github.com/tricorder/src/api-server/http/dao"
+	pb "github.com/tricorder/src/api-server/pb"
+	"github.com/tricorder/src/api-server/wasm"
+	commonpb "github.com/tricorder/src/pb/module/common"
+	ebpfpb "github.com/tricorder/src/pb/module/ebpf"
+)
+
+var (
+	// cCommentRegexp matches the comments of C code.
+	cCommentRegexp = regexp.MustCompile(`(?s)//[^\n]*|/\*.*?\*/`)
+	// bccProbeMacroRegexp matches the BCC macros that define probe functions, like TRACEPOINT_PROBE(sched, sched_switch)
+	// which defines tracepoint__sched__sched_switch().
+	bccProbeMacroRegexp = regexp.MustCompile(
+		`\b(TRACEPOINT_PROBE|RAW_TRACEPOINT_PROBE|KFUNC_PROBE|KRETFUNC_PROBE|LSM_PROBE)\s*\(\s*(\w+)\s*(?:,\s*(\w+))?`)
+	bccProbeMacroPrefixes = map[string]string{
+		"TRACEPOINT_PROBE":     "tracepoint__",
+		"RAW_TRACEPOINT_PROBE": "raw_tracepoint__",
+		"KFUNC_PROBE":          "kfunc__",
+		"KRETFUNC_PROBE":       "kretfunc__",
+		"LSM_PROBE":            "lsm__",
+	}
+)
+
+// validateModuleHttp godoc
+// @Summary      Validate module
+// @Description  Check the module like creating and deploying it, without creating it. The WASM text code is compiled,
+// @Description  the WASM code must export the function and the io.h functions, and the probes must attach the
+// @Description  functions defined in the BCC code. The response lists the problems, and the nodes whose agents
+// @Description  would run the module.
+// @Tags         module
+// @Accept       json
+// @Produce      json
+// @Param        module  body  CreateModuleReq  true  "The module to validate"
+// @Success      200  {object}  ValidateModuleResp
+// @Router       /api/module:validate [post].
+func (mgr *ModuleManager) validateModuleHttp(c *gin.Context) {
+	if c.Param(api.MODULE_ACTION_PARAM) != api.ACTION_OP+api.VALIDATE_ACTION {
+		c.JSON(http.StatusNotFound, HTTPResp{Code: http.StatusNotFound, Message: "page not found"})
+		return
+	}
+	var body CreateModuleReq
+	err := c.ShouldBindJSON(&body)
+	if err != nil {
+		c.JSON(http.StatusOK, HTTPResp{Code: http.StatusBadRequest, Message: "Request Error: " + err.Error()})
+		return
+	}
+	if err := resolveTenant(c, &body); err != nil {
+		c.JSON(http.StatusOK, HTTPResp{Code: statusCode(err), Message: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, mgr.validateModule(body))
+}
+
+// validateModule returns the problems that would fail creating the module of the request, or deploying it.
+func (mgr *ModuleManager) validateModule(body CreateModuleReq) ValidateModuleResp {
+	if len(body.Name) == 0 || body.Wasm == nil || body.Wasm.OutputSchema == nil || body.Ebpf == nil {
+		return ValidateModuleResp{
+			HTTPResp: HTTPResp{Code: http.StatusBadRequest, Message: "module is invalid"},
+			Errors:   []string{"name, wasm.output_schema and ebpf are required"},
+		}
+	}
+
+	var errs []string
+	addErr := func(err error) {
+		if err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	tenantErr := mgr.checkTenant(body.Tenant)
+	addErr(tenantErr)
+	addErr(mgr.gLock.ExecWithLock(func() error {
+		m, _ := mgr.Module.QueryByTenantAndName(body.Tenant, body.Name)
+		if m != nil && len(m.Name) > 0 {
+			return fmt.Errorf("name '%s' already exists", body.Name)
+		}
+		return nil
+	}))
+
+	mod, err := mgr.newModuleCode(body)
+	if err == nil {
+		_, err = moduleHypertable(mod, body.Wasm.OutputSchema)
+	}
+	if err == nil {
+		// Already checked when creating modules if API Server validates modules.
+		err = checkModuleCode(body.Ebpf, mod)
+	}
+	addErr(err)
+
+	var nodes []string
+	if tenantErr == nil {
+		nodes, err = mgr.deployNodes(body.Tenant)
+		addErr(err)
+	}
+
+	if len(errs) > 0 {
+		return ValidateModuleResp{
+			HTTPResp: HTTPResp{Code: http.StatusBadRequest, Message: "module is invalid"},
+			Errors:   errs,
+			Nodes:    nodes,
+		}
+	}
+	return ValidateModuleResp{HTTPResp: HTTPResp{Code: http.StatusOK, Message: "module is valid"}, Nodes: nodes}
+}
+
+// deployNodes returns the sorted names of the nodes whose agents would run the tenant's module if it were deployed.
+func (mgr *ModuleManager) deployNodes(tenantName string) ([]string, error) {
+	if !mgr.tenants.OwnsAnyNode(tenantName) {
+		return nil, fmt.Errorf("tenant '%s' owns no nodes", tenantName)
+	}
+	agents, err := mgr.NodeAgent.List([]string{})
+	if err != nil {
+		return nil, fmt.Errorf("while listing agents, error: %v", err)
+	}
+	names := make(map[string]bool)
+	nodes := make([]string, 0)
+	for i := range agents {
+		if mgr.deploysOnto(tenantName, &agents[i]) && !names[agents[i].NodeName] {
+			names[agents[i].NodeName] = true
+			nodes = append(nodes, agents[i].NodeName)
+		}
+	}
+	sort.Strings(nodes)
+	return nodes, nil
+}
+
+// deploysOnto returns true if the tenant's modules are deployed onto the agent.
+func (mgr *ModuleManager) deploysOnto(tenantName string, agent *dao.NodeAgentGORM) bool {
+	// The tenant's modules are only deployed onto the tenant's nodes.
+	return agent.State == int(pb.AgentState_ONLINE) && mgr.tenants.OwnsNode(tenantName, agent.NodeName)
+}
+
+// checkModuleCode returns an error if agents would fail to load the module's code, see checkBCCCode() and
+// wasm.CheckExports().
+func checkModuleCode(ebpf *ebpfpb.Program, mod *dao.ModuleGORM) error {
+	if err := checkBCCCode(ebpf); err != nil {
+		return err
+	}
+	return wasm.CheckExports(mod.Wasm, mod.Fn)
+}
+
+// checkBCCCode returns an error if the BCC code does not define the functions attached by the probes, or does not
+// mention the perf buffer. The check only looks for the names, the code is compiled by agents.
+func checkBCCCode(ebpf *ebpfpb.Program) error {
+	if ebpf.Fmt != commonpb.Format_TEXT || ebpf.Lang != commonpb.Lang_C {
+		return nil
+	}
+	code := cCommentRegexp.ReplaceAllString(ebpf.Code, "")
+	defined := make(map[string]bool)
+	for _, m := range bccProbeMacroRegexp.FindAllStringSubmatch(code, -1) {
+		fn := bccProbeMacroPrefixes[m[1]] + m[2]
+		if len(m[3]) > 0 {
+			fn += "__" + m[3]
+		}
+		defined[fn] = true
+	}
+	var missing []string
+	for _, probe := range ebpf.Probes {
+		for _, fn := range []string{probe.Entry, probe.Return} {
+			if len(fn) == 0 || defined[fn] {
+				continue
+			}
+			if !regexp.MustCompile(`\b` + regexp.QuoteMeta(fn) + `\s*\(`).MatchString(code) {
+				missing = append(missing, fn)
+			}
+			// Only reports the missing functions once.
+			defined[fn] = true
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("the BCC code does not define the probe functions '%s'", strings.Join(missing, "', '"))
+	}
+	if len(ebpf.PerfBufferName) > 0 &&
+		!regexp.MustCompile(`\b`+regexp.QuoteMeta(ebpf.PerfBufferName)+`\b`).MatchString(code) {
+		return fmt.Errorf("the BCC code does not define the perf buffer '%s'", ebpf.PerfBufferName)
+	}
+	return nil
+}
@@ -0,0 +1,136 @@
+// Copyright (C) 2023  Tricorder Observability
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package http
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/tricorder/src/api-server/http/api"
+	"github.com/tricorder/src/api-server/http/dao"
+	pb "github.com/tricorder/src/api-server/pb"
+	"github.com/tricorder/src/api-server/tenant"
+	commonpb "github.com/tricorder/src/pb/module/common"
+	"github.com/tricorder/src/pb/module/ebpf"
+	testwasm "github.com/tricorder/src/testing/wasm"
+)
+
+// Tests that checkBCCCode() finds the probe functions and the perf buffer defined in the BCC code.
+func TestCheckBCCCode(t *testing.T) {
+	assert := assert.New(t)
+
+	code := `
+BPF_PERF_OUTPUT(events);
+// int commented_out(void *ctx) { return 0; }
+int sample_entry(struct pt_regs *ctx) { return 0; }
+TRACEPOINT_PROBE(sched, sched_switch) { return 0; }
+`
+	program := func(probes ...*ebpf.ProbeSpec) *ebpf.Program {
+		return &ebpf.Program{Code: code, PerfBufferName: "events", Probes: probes}
+	}
+	assert.Nil(checkBCCCode(program(
+		&ebpf.ProbeSpec{Type: ebpf.ProbeSpec_KPROBE, Target: "do_sys_open", Entry: "sample_entry"},
+		&ebpf.ProbeSpec{Type: ebpf.ProbeSpec_TRACEPOINT, Target: "sched:sched_switch",
+			Entry: "tracepoint__sched__sched_switch"},
+	)))
+	assert.EqualError(checkBCCCode(program(
+		&ebpf.ProbeSpec{Entry: "sample_entry", Return: "sample_return"},
+		&ebpf.ProbeSpec{Entry: "commented_out", Return: "sample_return"},
+	)), "the BCC code does not define the probe functions 'sample_return', 'commented_out'")
+
+	noBuffer := program()
+	noBuffer.PerfBufferName = "output"
+	assert.EqualError(checkBCCCode(noBuffer), "the BCC code does not define the perf buffer 'output'")
+	// Only the BCC code is checked.
+	binary := program(&ebpf.ProbeSpec{Entry: "undefined"})
+	binary.Fmt = commonpb.Format_BINARY
+	assert.Nil(checkBCCCode(binary))
+}
+
+// Tests that POST /api/module:validate reports the problems of the module without creating it, and the nodes that
+// would run it, and that creating modules runs the same checks if API Server validates modules.
+func TestValidateModule(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	tenants, err := tenant.New(tenant.Tenant{Name: "team_a", Nodes: []string{"team-a-*"}}, tenant.Tenant{Name: "team_b"})
+	require.Nil(err)
+	mgr := newTestModuleManager(t)
+	mgr.tenants = tenants
+	for _, agent := range []*dao.NodeAgentGORM{
+		{AgentID: "a1", NodeName: "team-a-1", State: int(pb.AgentState_ONLINE)},
+		{AgentID: "a2", NodeName: "team-a-2", State: int(pb.AgentState_OFFLINE)},
+		{AgentID: "b1", NodeName: "node-b", State: int(pb.AgentState_ONLINE)},
+	} {
+		require.Nil(mgr.NodeAgent.SaveAgent(agent))
+	}
+	router := gin.New()
+	router.POST(api.ROOT+api.MODULE_ACTION, mgr.validateModuleHttp)
+
+	moduleReq := stdoutModuleReq()
+	moduleReq.Wasm.Code = testwasm.NewIOModule("fn")
+	moduleReq.Ebpf.Code = "int entry(void *ctx) { return 0; }"
+	moduleReq.Ebpf.PerfBufferName = ""
+	moduleReq.Tenant = "team_a"
+	var resp ValidateModuleResp
+	assert.Equal(http.StatusOK, serveV2(t, router, "POST", api.VALIDATE_MODULE_PATH, moduleReq, &resp))
+	assert.Equal(http.StatusOK, resp.Code)
+	assert.Empty(resp.Errors)
+	assert.Equal([]string{"team-a-1"}, resp.Nodes)
+	modules, err := mgr.Module.ListModule([]string{})
+	require.Nil(err)
+	assert.Empty(modules)
+
+	moduleReq.Wasm.FnName = "write"
+	moduleReq.Ebpf.Probes[0].Return = "exit"
+	moduleReq.Tenant = "team_b"
+	resp = ValidateModuleResp{}
+	serveV2(t, router, "POST", api.VALIDATE_MODULE_PATH, moduleReq, &resp)
+	assert.Equal(http.StatusBadRequest, resp.Code)
+	assert.Equal([]string{
+		"the BCC code does not define the probe functions 'exit'",
+		"tenant 'team_b' owns no nodes",
+	}, resp.Errors)
+
+	moduleReq.Ebpf.Probes[0].Return = ""
+	moduleReq.Tenant = ""
+	resp = ValidateModuleResp{}
+	serveV2(t, router, "POST", api.VALIDATE_MODULE_PATH, moduleReq, &resp)
+	assert.Equal([]string{"WASM code does not export the function 'write'"}, resp.Errors)
+	assert.Equal([]string{"node-b", "team-a-1"}, resp.Nodes)
+
+	var httpResp HTTPResp
+	assert.Equal(http.StatusNotFound, serveV2(t, router, "POST", api.ROOT+"/module:unknown", moduleReq, &httpResp))
+
+	// The invalid modules are only rejected when creating them if API Server validates modules.
+	assert.Equal(http.StatusOK, mgr.createModule(moduleReq).Code)
+	moduleReq.Name = "validated"
+	mgr.validateModules = true
+	createResp := mgr.createModule(moduleReq)
+	assert.Equal(http.StatusBadRequest, createResp.Code)
+	assert.Equal("WASM code does not export the function 'write'", createResp.Message)
+	moduleReq.Wasm.FnName = "fn"
+	assert.Equal(http.StatusOK, mgr.createModule(moduleReq).Code)
+
+	// The created modules' names are taken.
+	resp = ValidateModuleResp{}
+	serveV2(t, router, "POST", api.VALIDATE_MODULE_PATH, moduleReq, &resp)
+	assert.Equal([]string{"name 'validated' already exists"}, resp.Errors)
+}
@@ -21,7 +21,6 @@ import (
 	"k8s.io/client-go/kubernetes"
 
 	"github.com/tricorder/src/api-server/http/dao"
-	"github.com/tricorder/src/utils/cond"
 	"github.com/tricorder/src/utils/pg"
 )
 
@@ -48,7 +47,7 @@ var pgTables = []string{
 // InitResourceTables creates data tables for each and every type of resources.
 func initResourceTables(pgClient *pg.Client) error {
 	for _, table := range pgTables {
-		if err := pgClient.CreateTable(pg.GetJSONBTableSchema(table)); err != nil {
+		if err := pgClient.CreateJSONBObjectTable(table); err != nil {
 			return fmt.Errorf("while initializing resource table '%s', failed to create table, error: %v", table, err)
 		}
 	}
@@ -56,7 +55,7 @@ func initResourceTables(pgClient *pg.Client) error {
 }
 
 func StartWatchingResources(clientset kubernetes.Interface, pgClient *pg.Client,
-	nodeAgent *dao.NodeAgentDao, waitCond *cond.Cond,
+	nodeAgent *dao.NodeAgentDao,
 ) error {
-	return NewResourceWatcher(clientset, pgClient, nodeAgent, waitCond).StartWatching()
+	return NewResourceWatcher(clientset, pgClient, nodeAgent).StartWatching()
 }
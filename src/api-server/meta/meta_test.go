@@ -19,7 +19,6 @@ import (
 	"context"
 	"encoding/json"
 	"flag"
-	"fmt"
 	"testing"
 	"time"
 
@@ -37,9 +36,9 @@ import (
 
 	"github.com/tricorder/src/api-server/http/dao"
 	"github.com/tricorder/src/testing/bazel"
-	"github.com/tricorder/src/utils/cond"
 
 	"github.com/tricorder/src/testing/pg"
+	upg "github.com/tricorder/src/utils/pg"
 )
 
 var testAgainstK8s = flag.Bool("test_against_k8s", false, "If true, test against Kubernetes pointed to "+
@@ -98,14 +97,13 @@ func TestCompareResourcesBetweenK8sAndDB(t *testing.T) {
 	testDir := bazel.CreateTmpDir()
 	sqliteClient, err := dao.InitSqlite(testDir)
 	assert.Nil(err)
-	waitCond := cond.NewCond()
 
 	// TODO(yzhao): Tests node_agent table gets updated.
 	// This was added for detecting terminated agent pod.
 	nodeAgentDao := dao.NodeAgentDao{
 		Client: sqliteClient,
 	}
-	watcher := NewResourceWatcher(clientset, pgClient, &nodeAgentDao, waitCond)
+	watcher := NewResourceWatcher(clientset, pgClient, &nodeAgentDao)
 	go func() {
 		err = watcher.StartWatching()
 		assert.Nil(err)
@@ -129,7 +127,7 @@ func TestCompareResourcesBetweenK8sAndDB(t *testing.T) {
 	assert.Nil(err)
 	// Query from DB
 	nsInDB := corev1.Namespace{}
-	err = pgClient.JSON().Get("namespaces", &nsInDB, fmt.Sprintf("WHERE data#>>'{metadata,name}'='%s'", testNS.Name))
+	err = pgClient.JSON().Get("namespaces", &nsInDB, upg.Where([]string{"metadata", "name"}, testNS.Name))
 	assert.Nil(err)
 	// Compare K8s and DB for correctness
 	assert.Equal(nsInK8s.Name, nsInDB.Name)
@@ -149,7 +147,7 @@ func TestCompareResourcesBetweenK8sAndDB(t *testing.T) {
 	podInK8s, err := clientset.CoreV1().Pods(testNS.Name).Get(context.TODO(), pod1.Name, metav1.GetOptions{})
 	// Query from DB
 	podInDB := corev1.Pod{}
-	err = pgClient.JSON().Get("pods", &podInDB, fmt.Sprintf("WHERE data#>>'{metadata,name}'='%s'", pod1.Name))
+	err = pgClient.JSON().Get("pods", &podInDB, upg.Where([]string{"metadata", "name"}, pod1.Name))
 	assert.Nil(err)
 	// Compare K8s and DB for correctness
 	assert.Equal(podInK8s.Name, podInDB.Name)
@@ -168,7 +166,7 @@ func TestCompareResourcesBetweenK8sAndDB(t *testing.T) {
 	assert.Nil(err)
 	// Query from DB
 	epInDB := corev1.Endpoints{}
-	err = pgClient.JSON().Get("endpoints", &epInDB, fmt.Sprintf("WHERE data#>>'{metadata,name}'='%s'", endpoints.Name))
+	err = pgClient.JSON().Get("endpoints", &epInDB, upg.Where([]string{"metadata", "name"}, endpoints.Name))
 	assert.Nil(err)
 	// Compare K8s and DB for correctness
 	assert.Equal(epInK8s.Name, epInDB.Name)
@@ -189,7 +187,7 @@ func TestCompareResourcesBetweenK8sAndDB(t *testing.T) {
 	assert.Nil(err)
 	// Query from DB
 	svcInDB := corev1.Service{}
-	err = pgClient.JSON().Get("services", &svcInDB, fmt.Sprintf("WHERE data#>>'{metadata,name}'='%s'", service.Name))
+	err = pgClient.JSON().Get("services", &svcInDB, upg.Where([]string{"metadata", "name"}, service.Name))
 	assert.Nil(err)
 	// Compare K8s and DB for correctness
 	assert.Equal(svcInK8s.Name, svcInDB.Name)
@@ -213,7 +211,7 @@ func TestCompareResourcesBetweenK8sAndDB(t *testing.T) {
 	assert.Nil(err)
 	// Query from DB
 	rsInDB := appsv1.ReplicaSet{}
-	err = pgClient.JSON().Get("replicasets", &rsInDB, fmt.Sprintf("WHERE data#>>'{metadata,name}'='%s'", replicaSet.Name))
+	err = pgClient.JSON().Get("replicasets", &rsInDB, upg.Where([]string{"metadata", "name"}, replicaSet.Name))
 	assert.Nil(err)
 	// Compare K8s and DB for correctness
 	assert.Equal(rsInK8s.Name, rsInDB.Name)
@@ -239,7 +237,7 @@ func TestCompareResourcesBetweenK8sAndDB(t *testing.T) {
 	// Query from DB
 	deploymentInDB := appsv1.Deployment{}
 	err = pgClient.JSON().Get("deployments", &deploymentInDB,
-		fmt.Sprintf("WHERE data#>>'{metadata,name}'='%s'", deployment.Name))
+		upg.Where([]string{"metadata", "name"}, deployment.Name))
 	assert.Nil(err)
 	// Compare K8s and DB for correctness
 	assert.Equal(deploymentInK8s.Name, deploymentInDB.Name)
@@ -261,10 +259,41 @@ func TestInitResourceTable(t *testing.T) {
 	pod1.Name = "pod1"
 	pod1.UID = types.UID("uid1")
 	value, _ := json.Marshal(pod1)
-	require.Nil(pgClient.JSON().Upsert(PodTable, string(pod1.UID), value))
+	require.Nil(pgClient.JSON().Upsert(PodTable, value))
 
 	assert.Nil(initResourceTables(pgClient))
 	pod := &corev1.Pod{}
 	require.Nil(pgClient.JSON().Get(PodTable, pod))
 	assert.Equal(pod1.Name, pod.Name)
 }
+
+// Tests that resync() writes the listed objects and deletes the objects that are no longer listed.
+func TestResync(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	cleaner, pgClient, err := pg.LaunchContainer()
+	require.Nil(err)
+	defer func() {
+		assert.Nil(cleaner())
+	}()
+	require.Nil(initResourceTables(pgClient))
+
+	stalePod := &corev1.Pod{}
+	stalePod.Name = "stale"
+	stalePod.UID = types.UID("stale")
+	value, _ := json.Marshal(stalePod)
+	require.Nil(pgClient.JSON().Upsert(PodTable, value))
+
+	list := &corev1.PodList{}
+	for _, name := range []string{"pod1", "pod2"} {
+		pod := corev1.Pod{}
+		pod.Name = name
+		pod.UID = types.UID("uid-" + name)
+		list.Items = append(list.Items, pod)
+	}
+	resync(pgClient, PodTable, list)
+
+	ids, err := pgClient.JSON().ListIDs(PodTable)
+	require.Nil(err)
+	assert.ElementsMatch([]string{"uid-pod1", "uid-pod2"}, ids)
+}
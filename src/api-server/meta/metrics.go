@@ -0,0 +1,39 @@
+// Copyright (C) 2023  Tricorder Observability
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package meta
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"k8s.io/client-go/tools/cache"
+)
+
+var informerEvents = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "starship_api_server_informer_events_total",
+	Help: "The number of events received by the informers of ResourceWatcher, by resource table and event type.",
+}, []string{"resource", "event"})
+
+// countEvents returns the handler that counts the informer events of the resource written to the table.
+func countEvents(table string) cache.ResourceEventHandler {
+	added := informerEvents.WithLabelValues(table, "add")
+	updated := informerEvents.WithLabelValues(table, "update")
+	deleted := informerEvents.WithLabelValues(table, "delete")
+	return cache.ResourceEventHandlerFuncs{
+		AddFunc:    func(interface{}) { added.Inc() },
+		UpdateFunc: func(interface{}, interface{}) { updated.Inc() },
+		DeleteFunc: func(interface{}) { deleted.Inc() },
+	}
+}
@@ -29,7 +29,6 @@ import (
 
 	"github.com/tricorder/src/api-server/http/dao"
 	pb "github.com/tricorder/src/api-server/pb"
-	"github.com/tricorder/src/utils/cond"
 	"github.com/tricorder/src/utils/log"
 
 	"github.com/tricorder/src/utils/pg"
@@ -43,17 +42,15 @@ type ResourceWatcher struct {
 	clientset kubernetes.Interface
 	pgClient  *pg.Client
 	nodeAgent *dao.NodeAgentDao
-	waitCond  *cond.Cond
 }
 
 func NewResourceWatcher(clientset kubernetes.Interface, pgClient *pg.Client,
-	nodeAgent *dao.NodeAgentDao, waitCond *cond.Cond,
+	nodeAgent *dao.NodeAgentDao,
 ) *ResourceWatcher {
 	watcher := new(ResourceWatcher)
 	watcher.clientset = clientset
 	watcher.pgClient = pgClient
 	watcher.nodeAgent = nodeAgent
-	watcher.waitCond = waitCond
 	err := retry.ExpBackOffWithLimit(func() error {
 		return initResourceTables(pgClient)
 	})
@@ -83,9 +80,7 @@ func (w *ResourceWatcher) node() error {
 		log.Errorf("clientset.CoreV1().Nodes().List error %s", err)
 		return err
 	}
-	for _, obj := range list.Items {
-		upsert(w.pgClient, NodeTable, &obj, obj.UID)
-	}
+	resync(w.pgClient, NodeTable, list)
 
 	factory := informers.NewSharedInformerFactory(w.clientset, 12*time.Hour)
 	informer := factory.Core().V1().Nodes().Informer()
@@ -93,13 +88,13 @@ func (w *ResourceWatcher) node() error {
 		AddFunc: func(obj interface{}) {
 			node, ok := obj.(*corev1.Node)
 			if ok {
-				upsert(w.pgClient, NodeTable, node, node.UID)
+				upsert(w.pgClient, NodeTable, node)
 			}
 		},
 		UpdateFunc: func(oldObj, newObj interface{}) {
 			node, ok := newObj.(*corev1.Node)
 			if ok {
-				upsert(w.pgClient, NodeTable, node, node.UID)
+				upsert(w.pgClient, NodeTable, node)
 			}
 		},
 		DeleteFunc: func(obj interface{}) {
@@ -110,6 +105,8 @@ func (w *ResourceWatcher) node() error {
 		},
 	})
 
+	informer.AddEventHandler(countEvents(NodeTable))
+
 	informer.Run(quitCh)
 	return nil
 }
@@ -120,9 +117,7 @@ func (w *ResourceWatcher) namespace() error {
 		log.Errorf("clientset.CoreV1().Namespaces().List error %s", err)
 		return err
 	}
-	for _, obj := range list.Items {
-		upsert(w.pgClient, NameSpaceTable, &obj, obj.UID)
-	}
+	resync(w.pgClient, NameSpaceTable, list)
 
 	factory := informers.NewSharedInformerFactory(w.clientset, 12*time.Hour)
 	informer := factory.Core().V1().Namespaces().Informer()
@@ -130,13 +125,13 @@ func (w *ResourceWatcher) namespace() error {
 		AddFunc: func(obj interface{}) {
 			ns, ok := obj.(*corev1.Namespace)
 			if ok {
-				upsert(w.pgClient, NameSpaceTable, ns, ns.UID)
+				upsert(w.pgClient, NameSpaceTable, ns)
 			}
 		},
 		UpdateFunc: func(oldObj, newObj interface{}) {
 			ns, ok := newObj.(*corev1.Namespace)
 			if ok {
-				upsert(w.pgClient, NameSpaceTable, ns, ns.UID)
+				upsert(w.pgClient, NameSpaceTable, ns)
 			}
 		},
 		DeleteFunc: func(obj interface{}) {
@@ -147,6 +142,8 @@ func (w *ResourceWatcher) namespace() error {
 		},
 	})
 
+	informer.AddEventHandler(countEvents(NameSpaceTable))
+
 	informer.Run(quitCh)
 	return nil
 }
@@ -157,9 +154,7 @@ func (w *ResourceWatcher) pod() error {
 		log.Errorf("clientset.CoreV1().Pods().List error %s", err)
 		return err
 	}
-	for _, obj := range list.Items {
-		upsert(w.pgClient, PodTable, &obj, obj.UID)
-	}
+	resync(w.pgClient, PodTable, list)
 
 	factory := informers.NewSharedInformerFactory(w.clientset, 12*time.Hour)
 	informer := factory.Core().V1().Pods().Informer()
@@ -167,13 +162,13 @@ func (w *ResourceWatcher) pod() error {
 		AddFunc: func(obj interface{}) {
 			pod, ok := obj.(*corev1.Pod)
 			if ok {
-				upsert(w.pgClient, PodTable, pod, pod.UID)
+				upsert(w.pgClient, PodTable, pod)
 			}
 		},
 		UpdateFunc: func(oldObj, newObj interface{}) {
 			pod, ok := newObj.(*corev1.Pod)
 			if ok {
-				upsert(w.pgClient, PodTable, pod, pod.UID)
+				upsert(w.pgClient, PodTable, pod)
 			}
 		},
 		DeleteFunc: func(obj interface{}) {
@@ -181,18 +176,17 @@ func (w *ResourceWatcher) pod() error {
 			if ok {
 				deleteByID(w.pgClient, PodTable, pod.UID)
 				if na, err := w.nodeAgent.QueryByPodID(string(pod.UID)); err == nil {
+					// The module instances of a terminated agent need no further action, so nothing is dispatched.
 					if err = w.nodeAgent.UpdateStateByID(na.AgentID, int(pb.AgentState_TERMINATED)); err != nil {
 						log.Errorf("while deleting pod, failed to nodeAgent UpdateStateByID %s, error %s", na.AgentID, err)
-					} else {
-						// TODO(yzhao): We seem needing a more structured way of communicating needed actions between API Server's
-						// HTTP and gRPC components. See https://github.com/tricorder-observability/starship/issues/150.
-						w.waitCond.Broadcast()
 					}
 				}
 			}
 		},
 	})
 
+	informer.AddEventHandler(countEvents(PodTable))
+
 	informer.Run(quitCh)
 	return nil
 }
@@ -203,9 +197,7 @@ func (w *ResourceWatcher) endpoints() error {
 		log.Errorf("clientset.CoreV1().Endpoints().List error %s", err)
 		return err
 	}
-	for _, obj := range list.Items {
-		upsert(w.pgClient, EndPointTable, &obj, obj.UID)
-	}
+	resync(w.pgClient, EndPointTable, list)
 
 	factory := informers.NewSharedInformerFactory(w.clientset, 12*time.Hour)
 	informer := factory.Core().V1().Endpoints().Informer()
@@ -213,13 +205,13 @@ func (w *ResourceWatcher) endpoints() error {
 		AddFunc: func(obj interface{}) {
 			ep, ok := obj.(*corev1.Endpoints)
 			if ok {
-				upsert(w.pgClient, EndPointTable, ep, ep.UID)
+				upsert(w.pgClient, EndPointTable, ep)
 			}
 		},
 		UpdateFunc: func(oldObj, newObj interface{}) {
 			ep, ok := newObj.(*corev1.Endpoints)
 			if ok {
-				upsert(w.pgClient, EndPointTable, ep, ep.UID)
+				upsert(w.pgClient, EndPointTable, ep)
 			}
 		},
 		DeleteFunc: func(obj interface{}) {
@@ -230,6 +222,8 @@ func (w *ResourceWatcher) endpoints() error {
 		},
 	})
 
+	informer.AddEventHandler(countEvents(EndPointTable))
+
 	informer.Run(quitCh)
 	return nil
 }
@@ -240,9 +234,7 @@ func (w *ResourceWatcher) service() error {
 		log.Errorf("clientset.CoreV1().Services().List error %s", err)
 		return err
 	}
-	for _, obj := range list.Items {
-		upsert(w.pgClient, ServiceTable, &obj, obj.UID)
-	}
+	resync(w.pgClient, ServiceTable, list)
 
 	factory := informers.NewSharedInformerFactory(w.clientset, 12*time.Hour)
 	informer := factory.Core().V1().Services().Informer()
@@ -250,13 +242,13 @@ func (w *ResourceWatcher) service() error {
 		AddFunc: func(obj interface{}) {
 			svc, ok := obj.(*corev1.Service)
 			if ok {
-				upsert(w.pgClient, ServiceTable, svc, svc.UID)
+				upsert(w.pgClient, ServiceTable, svc)
 			}
 		},
 		UpdateFunc: func(oldObj, newObj interface{}) {
 			svc, ok := newObj.(*corev1.Service)
 			if ok {
-				upsert(w.pgClient, ServiceTable, svc, svc.UID)
+				upsert(w.pgClient, ServiceTable, svc)
 			}
 		},
 		DeleteFunc: func(obj interface{}) {
@@ -267,6 +259,8 @@ func (w *ResourceWatcher) service() error {
 		},
 	})
 
+	informer.AddEventHandler(countEvents(ServiceTable))
+
 	informer.Run(quitCh)
 	return nil
 }
@@ -277,9 +271,7 @@ func (w *ResourceWatcher) replicaSet() error {
 		log.Errorf("clientset.AppsV1().ReplicaSets().List error %s", err)
 		return err
 	}
-	for _, obj := range list.Items {
-		upsert(w.pgClient, ReplicSetTable, &obj, obj.UID)
-	}
+	resync(w.pgClient, ReplicSetTable, list)
 
 	factory := informers.NewSharedInformerFactory(w.clientset, 12*time.Hour)
 	informer := factory.Apps().V1().ReplicaSets().Informer()
@@ -287,13 +279,13 @@ func (w *ResourceWatcher) replicaSet() error {
 		AddFunc: func(obj interface{}) {
 			rs, ok := obj.(*appsv1.ReplicaSet)
 			if ok {
-				upsert(w.pgClient, ReplicSetTable, rs, rs.UID)
+				upsert(w.pgClient, ReplicSetTable, rs)
 			}
 		},
 		UpdateFunc: func(oldObj, newObj interface{}) {
 			rs, ok := newObj.(*appsv1.ReplicaSet)
 			if ok {
-				upsert(w.pgClient, ReplicSetTable, rs, rs.UID)
+				upsert(w.pgClient, ReplicSetTable, rs)
 			}
 		},
 		DeleteFunc: func(obj interface{}) {
@@ -304,6 +296,8 @@ func (w *ResourceWatcher) replicaSet() error {
 		},
 	})
 
+	informer.AddEventHandler(countEvents(ReplicSetTable))
+
 	informer.Run(quitCh)
 	return nil
 }
@@ -314,9 +308,7 @@ func (w *ResourceWatcher) deployment() error {
 		log.Errorf("clientset.AppsV1().Deployments().List error %s", err)
 		return err
 	}
-	for _, obj := range list.Items {
-		upsert(w.pgClient, DeploymentTable, &obj, obj.UID)
-	}
+	resync(w.pgClient, DeploymentTable, list)
 
 	factory := informers.NewSharedInformerFactory(w.clientset, 12*time.Hour)
 	informer := factory.Apps().V1().Deployments().Informer()
@@ -324,13 +316,13 @@ func (w *ResourceWatcher) deployment() error {
 		AddFunc: func(obj interface{}) {
 			deployment, ok := obj.(*appsv1.Deployment)
 			if ok {
-				upsert(w.pgClient, DeploymentTable, deployment, deployment.UID)
+				upsert(w.pgClient, DeploymentTable, deployment)
 			}
 		},
 		UpdateFunc: func(oldObj, newObj interface{}) {
 			deployment, ok := newObj.(*appsv1.Deployment)
 			if ok {
-				upsert(w.pgClient, DeploymentTable, deployment, deployment.UID)
+				upsert(w.pgClient, DeploymentTable, deployment)
 			}
 		},
 		DeleteFunc: func(obj interface{}) {
@@ -341,6 +333,8 @@ func (w *ResourceWatcher) deployment() error {
 		},
 	})
 
+	informer.AddEventHandler(countEvents(DeploymentTable))
+
 	informer.Run(quitCh)
 	return nil
 }
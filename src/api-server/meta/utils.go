@@ -18,6 +18,7 @@ package meta
 import (
 	"encoding/json"
 
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 
@@ -26,13 +27,54 @@ import (
 	"github.com/tricorder/src/utils/pg"
 )
 
-func upsert(pgClient *pg.Client, table string, obj runtime.Object, uid types.UID) {
+func upsert(pgClient *pg.Client, table string, obj runtime.Object) {
 	value, _ := json.Marshal(obj)
-	if err := pgClient.JSON().Upsert(table, string(uid), value); err != nil {
+	if err := pgClient.JSON().Upsert(table, value); err != nil {
 		log.Errorf("Upsert error %s", err)
 	}
 }
 
+// resync writes the listed objects into the table in a batch, and deletes the objects in the table that are not
+// listed, which were deleted while not being watched.
+func resync(pgClient *pg.Client, table string, list runtime.Object) {
+	objs, err := apimeta.ExtractList(list)
+	if err != nil {
+		log.Errorf("While resyncing table '%s', failed to extract list, error: %v", table, err)
+		return
+	}
+	values := make([][]byte, 0, len(objs))
+	listed := make(map[string]bool, len(objs))
+	for _, obj := range objs {
+		accessor, err := apimeta.Accessor(obj)
+		if err != nil {
+			log.Errorf("While resyncing table '%s', failed to access object metadata, error: %v", table, err)
+			return
+		}
+		listed[string(accessor.GetUID())] = true
+		value, _ := json.Marshal(obj)
+		values = append(values, value)
+	}
+	if err := pgClient.JSON().UpsertBatch(table, values); err != nil {
+		log.Errorf("While resyncing table '%s', failed to upsert objects, error: %v", table, err)
+		return
+	}
+
+	ids, err := pgClient.JSON().ListIDs(table)
+	if err != nil {
+		log.Errorf("While resyncing table '%s', error: %v", table, err)
+		return
+	}
+	stale := make([]string, 0)
+	for _, id := range ids {
+		if !listed[id] {
+			stale = append(stale, id)
+		}
+	}
+	if err := pgClient.JSON().DeleteBatch(table, stale); err != nil {
+		log.Errorf("While resyncing table '%s', failed to delete stale objects, error: %v", table, err)
+	}
+}
+
 func deleteByID(pgClient *pg.Client, table string, uid types.UID) {
 	if err := pgClient.JSON().Delete(table, string(uid)); err != nil {
 		log.Errorf("DeleteData %s error %s", table, uid)
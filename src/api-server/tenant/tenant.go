@@ -0,0 +1,145 @@
+// Copyright (C) 2023  Tricorder Observability
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+// Package tenant scopes the modules, their data tables and dashboards, and their deployments, to tenants.
+// The modules created by the principals without a tenant are in the default tenant, whose name is empty.
+package tenant
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"regexp"
+
+	"gopkg.in/yaml.v2"
+)
+
+// The tenant names are used in the names of postgres schemas and Grafana folder UIDs, so they are limited to the
+// characters allowed by both, and to the length of a Grafana folder UID with a prefix.
+var nameRegexp = regexp.MustCompile(`^[a-z][a-z0-9_]{0,23}$`)
+
+// ValidateName returns an error if the name cannot be the name of a tenant.
+func ValidateName(name string) error {
+	if !nameRegexp.MatchString(name) {
+		return fmt.Errorf("invalid tenant name '%s', must be lower case letters, digits and underscores, "+
+			"start with a letter, and be at most 24 characters long", name)
+	}
+	return nil
+}
+
+// SchemaName returns the name of the Postgres schema of the data tables of the tenant's modules.
+func SchemaName(tenant string) string {
+	return "tenant_" + tenant
+}
+
+// Tenant describes the resources owned by a tenant.
+type Tenant struct {
+	Name string `yaml:"name"`
+	// The glob patterns of the names of the nodes owned by the tenant, see path.Match().
+	// The tenant's modules are only deployed onto these nodes.
+	Nodes []string `yaml:"nodes"`
+}
+
+// tenantsFile is the format of the tenants file, for example:
+//
+//	tenants:
+//	  - name: team_a
+//	    nodes: ["team-a-*", "shared-1"]
+//	  - name: team_b
+//	    nodes: ["*"]
+type tenantsFile struct {
+	Tenants []Tenant `yaml:"tenants"`
+}
+
+// Tenants looks up the nodes owned by tenants.
+// A nil Tenants means that no tenants are configured, every tenant owns all nodes.
+type Tenants struct {
+	byName map[string]*Tenant
+}
+
+// New returns the Tenants of the input tenants.
+func New(tenants ...Tenant) (*Tenants, error) {
+	t := &Tenants{byName: make(map[string]*Tenant, len(tenants))}
+	for i := range tenants {
+		tenant := &tenants[i]
+		if err := ValidateName(tenant.Name); err != nil {
+			return nil, err
+		}
+		if _, ok := t.byName[tenant.Name]; ok {
+			return nil, fmt.Errorf("tenant '%s' is duplicated", tenant.Name)
+		}
+		for _, pattern := range tenant.Nodes {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return nil, fmt.Errorf("invalid node pattern '%s' of tenant '%s', error: %v", pattern, tenant.Name, err)
+			}
+		}
+		t.byName[tenant.Name] = tenant
+	}
+	return t, nil
+}
+
+// Load returns the Tenants in the YAML file at filePath.
+func Load(filePath string) (*Tenants, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("while loading tenants, failed to read '%s', error: %v", filePath, err)
+	}
+	var file tenantsFile
+	err = yaml.UnmarshalStrict(data, &file)
+	if err != nil {
+		return nil, fmt.Errorf("while loading tenants, failed to parse '%s', error: %v", filePath, err)
+	}
+	t, err := New(file.Tenants...)
+	if err != nil {
+		return nil, fmt.Errorf("while loading tenants from '%s', %v", filePath, err)
+	}
+	return t, nil
+}
+
+// Exists returns true if the tenant is configured, or no tenants are configured.
+func (t *Tenants) Exists(tenant string) bool {
+	if t == nil {
+		return true
+	}
+	_, ok := t.byName[tenant]
+	return ok
+}
+
+// OwnsNode returns true if the tenant owns the node. The default tenant owns all nodes, and the tenants that are not
+// configured own none.
+func (t *Tenants) OwnsNode(tenant, node string) bool {
+	if t == nil || len(tenant) == 0 {
+		return true
+	}
+	cfg, ok := t.byName[tenant]
+	if !ok {
+		return false
+	}
+	for _, pattern := range cfg.Nodes {
+		if matched, _ := path.Match(pattern, node); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// OwnsAnyNode returns true if the tenant might own some nodes, false if it is not configured or has no node patterns.
+func (t *Tenants) OwnsAnyNode(tenant string) bool {
+	if t == nil || len(tenant) == 0 {
+		return true
+	}
+	cfg, ok := t.byName[tenant]
+	return ok && len(cfg.Nodes) > 0
+}
@@ -0,0 +1,82 @@
+// Copyright (C) 2023  Tricorder Observability
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package tenant
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Tests that only the lower case identifiers are valid tenant names.
+func TestValidateName(t *testing.T) {
+	assert := assert.New(t)
+
+	for _, name := range []string{"a", "team_a", "t1", "abcdefghijklmnopqrstuvwx"} {
+		assert.Nil(ValidateName(name), name)
+	}
+	for _, name := range []string{"", "1a", "_a", "Team", "team-a", "a.b", "a b", "abcdefghijklmnopqrstuvwxy"} {
+		assert.NotNil(ValidateName(name), name)
+	}
+}
+
+// Tests that the tenants own the nodes matching their patterns.
+func TestOwnsNode(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	file := filepath.Join(t.TempDir(), "tenants.yaml")
+	require.Nil(os.WriteFile(file, []byte(`
+tenants:
+  - name: team_a
+    nodes: ["team-a-*", "shared"]
+  - name: team_b
+    nodes: []
+`), 0o600))
+	tenants, err := Load(file)
+	require.Nil(err)
+
+	assert.True(tenants.OwnsNode("team_a", "team-a-1"))
+	assert.True(tenants.OwnsNode("team_a", "shared"))
+	assert.False(tenants.OwnsNode("team_a", "team-b-1"))
+	assert.False(tenants.OwnsNode("team_b", "team-a-1"))
+	assert.False(tenants.OwnsNode("unknown", "team-a-1"))
+	assert.True(tenants.OwnsNode("", "team-b-1"))
+
+	assert.True(tenants.OwnsAnyNode("team_a"))
+	assert.False(tenants.OwnsAnyNode("team_b"))
+	assert.False(tenants.OwnsAnyNode("unknown"))
+	assert.True(tenants.OwnsAnyNode(""))
+
+	assert.True(tenants.Exists("team_b"))
+	assert.False(tenants.Exists("unknown"))
+
+	// Without tenants file, every tenant owns all nodes.
+	var none *Tenants
+	assert.True(none.OwnsNode("team_b", "team-a-1"))
+	assert.True(none.OwnsAnyNode("unknown"))
+	assert.True(none.Exists("unknown"))
+
+	_, err = New(Tenant{Name: "a"}, Tenant{Name: "a"})
+	assert.ErrorContains(err, "duplicated")
+	_, err = New(Tenant{Name: "A"})
+	assert.ErrorContains(err, "invalid tenant name")
+	_, err = New(Tenant{Name: "a", Nodes: []string{"["}})
+	assert.ErrorContains(err, "invalid node pattern")
+}
@@ -0,0 +1,104 @@
+// Copyright (C) 2023  Tricorder Observability
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package channel
+
+import (
+	"sync"
+
+	"github.com/tricorder/src/utils/log"
+)
+
+// DefaultAgentQueueSize is the number of pending module instance changes buffered for each agent.
+// Changes that do not fit are dropped, and picked up by the agent's periodic resync instead.
+const DefaultAgentQueueSize = 128
+
+// Dispatcher routes module instance changes to the agent that should act on them.
+// Each connected agent owns a queue keyed by its agent ID, so a change only wakes up the agent it concerns.
+type Dispatcher struct {
+	mu sync.Mutex
+
+	// Key is agent ID, value is the queue of module instance IDs to be processed by that agent.
+	queues map[string]chan string
+
+	queueSize int
+}
+
+func NewDispatcher() *Dispatcher {
+	return NewDispatcherWithQueueSize(DefaultAgentQueueSize)
+}
+
+func NewDispatcherWithQueueSize(queueSize int) *Dispatcher {
+	return &Dispatcher{
+		queues:    make(map[string]chan string),
+		queueSize: queueSize,
+	}
+}
+
+// Register creates the queue for the agent, and returns it for the agent to receive module instance IDs.
+// If the agent was already registered, for example it reconnects before the old connection is cleaned up,
+// the old queue is replaced; the old handler will then observe its queue being closed.
+func (d *Dispatcher) Register(agentID string) <-chan string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if old, found := d.queues[agentID]; found {
+		close(old)
+	}
+	queue := make(chan string, d.queueSize)
+	d.queues[agentID] = queue
+	return queue
+}
+
+// Unregister removes the agent's queue, only if it is still the queue returned by Register.
+// Returns true if the queue was removed, false if it was already replaced or removed.
+func (d *Dispatcher) Unregister(agentID string, queue <-chan string) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	current, found := d.queues[agentID]
+	if !found || (<-chan string)(current) != queue {
+		return false
+	}
+	close(current)
+	delete(d.queues, agentID)
+	return true
+}
+
+// Dispatch notifies the agent that the module instance needs to be processed.
+// Returns false if the agent is not connected, or its queue is full; in both cases the change will be picked up
+// when the agent (re)connects or resyncs.
+func (d *Dispatcher) Dispatch(agentID, moduleInstanceID string) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	queue, found := d.queues[agentID]
+	if !found {
+		return false
+	}
+	select {
+	case queue <- moduleInstanceID:
+		return true
+	default:
+		log.Warnf("Queue of agent '%s' is full, module instance '%s' is deferred to next resync",
+			agentID, moduleInstanceID)
+		return false
+	}
+}
+
+// IsRegistered returns true if the agent has a queue.
+func (d *Dispatcher) IsRegistered(agentID string) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	_, found := d.queues[agentID]
+	return found
+}
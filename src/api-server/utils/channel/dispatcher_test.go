@@ -0,0 +1,55 @@
+// Copyright (C) 2023  Tricorder Observability
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package channel
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDispatcher(t *testing.T) {
+	assert := assert.New(t)
+
+	d := NewDispatcherWithQueueSize(1)
+	assert.False(d.Dispatch("agent1", "instance1"), "unregistered agent should not receive anything")
+
+	queue1 := d.Register("agent1")
+	queue2 := d.Register("agent2")
+	assert.True(d.IsRegistered("agent1"))
+
+	assert.True(d.Dispatch("agent1", "instance1"))
+	// The queue is full.
+	assert.False(d.Dispatch("agent1", "instance2"))
+	assert.Equal("instance1", <-queue1)
+	// Other agents' queues are not affected.
+	assert.Equal(0, len(queue2))
+
+	// Re-register closes the previous queue.
+	newQueue1 := d.Register("agent1")
+	_, ok := <-queue1
+	assert.False(ok)
+
+	// Unregister with a stale queue is no-op.
+	assert.False(d.Unregister("agent1", queue1))
+	assert.True(d.IsRegistered("agent1"))
+
+	assert.True(d.Unregister("agent1", newQueue1))
+	assert.False(d.IsRegistered("agent1"))
+	_, ok = <-newQueue1
+	assert.False(ok)
+	assert.False(d.Dispatch("agent1", "instance1"))
+}
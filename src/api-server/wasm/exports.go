@@ -0,0 +1,174 @@
+// Copyright (C) 2023  Tricorder Observability
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package wasm
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ExportKind is the kind of an entity exported by a WASM module.
+type ExportKind byte
+
+// See https://webassembly.github.io/spec/core/binary/modules.html#export-section.
+const (
+	ExportFunc ExportKind = iota
+	ExportTable
+	ExportMemory
+	ExportGlobal
+)
+
+const (
+	exportSectionID = 7
+	// The agents read and write the buffers through this memory.
+	memoryExport = "memory"
+)
+
+var wasmHeader = []byte{0x00, 'a', 's', 'm', 0x01, 0x00, 0x00, 0x00}
+
+// IOFuncs are the functions of modules/common/io.h that agents call to pass data into and out of the WASM function,
+// see src/agent/wasm/memory.go.
+var IOFuncs = []string{
+	"malloc_input_buf",
+	"free_input_buf",
+	"free_output_buf",
+	"get_input_buf",
+	"get_input_buf_cap",
+	"set_input_buf_len",
+	"get_output_buf",
+	"get_output_buf_len",
+}
+
+// Exports returns the kinds of the entities exported by the binary WASM module, keyed by their names.
+func Exports(code []byte) (map[string]ExportKind, error) {
+	if !bytes.HasPrefix(code, wasmHeader) {
+		return nil, errors.New("not a WASM binary module of version 1")
+	}
+	r := &reader{buf: code[len(wasmHeader):]}
+	exports := make(map[string]ExportKind)
+	for len(r.buf) > 0 {
+		id, err := r.byte()
+		if err != nil {
+			return nil, err
+		}
+		size, err := r.u32()
+		if err != nil {
+			return nil, err
+		}
+		section, err := r.bytes(size)
+		if err != nil {
+			return nil, fmt.Errorf("section %d is truncated", id)
+		}
+		if id != exportSectionID {
+			continue
+		}
+		if err := readExports(&reader{buf: section}, exports); err != nil {
+			return nil, fmt.Errorf("invalid export section, %v", err)
+		}
+	}
+	return exports, nil
+}
+
+func readExports(r *reader, exports map[string]ExportKind) error {
+	count, err := r.u32()
+	if err != nil {
+		return err
+	}
+	for i := uint32(0); i < count; i++ {
+		size, err := r.u32()
+		if err != nil {
+			return err
+		}
+		name, err := r.bytes(size)
+		if err != nil {
+			return err
+		}
+		kind, err := r.byte()
+		if err != nil {
+			return err
+		}
+		// The index of the exported entity.
+		if _, err := r.u32(); err != nil {
+			return err
+		}
+		exports[string(name)] = ExportKind(kind)
+	}
+	return nil
+}
+
+// CheckExports returns an error if the binary WASM module does not export the function fnName, the functions of
+// io.h, or its memory, which agents need to run the module.
+func CheckExports(code []byte, fnName string) error {
+	exports, err := Exports(code)
+	if err != nil {
+		return fmt.Errorf("while reading WASM exports, %v", err)
+	}
+	if kind, ok := exports[fnName]; !ok || kind != ExportFunc {
+		return fmt.Errorf("WASM code does not export the function '%s'", fnName)
+	}
+	var missing []string
+	for _, fn := range IOFuncs {
+		if kind, ok := exports[fn]; !ok || kind != ExportFunc {
+			missing = append(missing, fn)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("WASM code does not export the io.h functions '%s', include modules/common/io.h and link "+
+			"with --export-all", strings.Join(missing, "', '"))
+	}
+	if kind, ok := exports[memoryExport]; !ok || kind != ExportMemory {
+		return fmt.Errorf("WASM code does not export its memory as '%s'", memoryExport)
+	}
+	return nil
+}
+
+// reader reads the values encoded in the WASM binary format.
+type reader struct {
+	buf []byte
+}
+
+var errMalformed = errors.New("truncated or malformed WASM code")
+
+func (r *reader) byte() (byte, error) {
+	if len(r.buf) == 0 {
+		return 0, errMalformed
+	}
+	b := r.buf[0]
+	r.buf = r.buf[1:]
+	return b, nil
+}
+
+// u32 reads an unsigned 32-bit integer encoded in LEB128.
+func (r *reader) u32() (uint32, error) {
+	v, n := binary.Uvarint(r.buf)
+	if n <= 0 || n > 5 || v > 1<<32-1 {
+		return 0, errMalformed
+	}
+	r.buf = r.buf[n:]
+	return uint32(v), nil
+}
+
+func (r *reader) bytes(n uint32) ([]byte, error) {
+	if uint64(len(r.buf)) < uint64(n) {
+		return nil, errMalformed
+	}
+	b := r.buf[:n]
+	r.buf = r.buf[n:]
+	return b, nil
+}
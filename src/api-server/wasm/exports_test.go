@@ -0,0 +1,55 @@
+// Copyright (C) 2023  Tricorder Observability
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package wasm
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	testwasm "github.com/tricorder/src/testing/wasm"
+)
+
+// Tests that Exports() reads the names and kinds of the exports of WASM binary modules, and rejects malformed ones.
+func TestExports(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	exports, err := Exports(testwasm.NewModule("fn", "write"))
+	require.Nil(err)
+	assert.Equal(map[string]ExportKind{"fn": ExportFunc, "write": ExportFunc, "memory": ExportMemory}, exports)
+
+	_, err = Exports([]byte("wasm"))
+	assert.ErrorContains(err, "not a WASM binary module")
+	code := testwasm.NewModule("fn")
+	_, err = Exports(code[:len(code)-2])
+	assert.ErrorContains(err, "truncated")
+}
+
+// Tests that CheckExports() requires the WASM function, the io.h functions and the memory.
+func TestCheckExports(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Nil(CheckExports(testwasm.NewIOModule("fn"), "fn"))
+	assert.ErrorContains(CheckExports(testwasm.NewIOModule("fn"), "write"), "export the function 'write'")
+	assert.ErrorContains(CheckExports(testwasm.NewModule("fn", "get_input_buf"), "fn"),
+		"io.h functions 'malloc_input_buf', 'free_input_buf'")
+	assert.ErrorContains(CheckExports(testwasm.NewModule("fn"), "fn"), "io.h functions")
+	assert.ErrorContains(CheckExports([]byte("wasm"), "fn"), "while reading WASM exports")
+	// The test module's and io.h's functions are the same.
+	assert.Equal(IOFuncs, testwasm.IOFuncs)
+}
@@ -18,6 +18,7 @@ package agent
 import (
 	"github.com/spf13/cobra"
 
+	"github.com/tricorder/src/cli/pkg/config"
 	"github.com/tricorder/src/cli/pkg/kubernetes"
 	"github.com/tricorder/src/utils/log"
 )
@@ -36,11 +37,18 @@ var AgentCmd = &cobra.Command{
 			}
 			apiServerAddress = newApiAddress
 		}
+		resolved, err := config.ResolveToken(token)
+		if err != nil {
+			log.Fatalf("Failed to read the API token, error: %v", err)
+		}
+		token = resolved
 	},
 }
 
 var (
 	apiServerAddress string
+	// API token or JWT used to authenticate to API Server, specified from --token flag.
+	token string
 	// The format of the output.
 	outputFormat string
 )
@@ -48,6 +56,8 @@ var (
 func init() {
 	// Here you will define your flags and configuration settings.
 	AgentCmd.PersistentFlags().StringVar(&apiServerAddress, "api-server", "", "address of the Starship API Server.")
+	AgentCmd.PersistentFlags().StringVar(&token, "token", "",
+		"API token or JWT of the Starship API Server, defaults to $"+config.TokenEnv+" or the config file.")
 	AgentCmd.PersistentFlags().StringVarP(&outputFormat, "output", "o", "yaml", "the style (json,yaml,table) of output.")
 
 	AgentCmd.AddCommand(listCmd)
@@ -32,7 +32,7 @@ var listCmd = &cobra.Command{
 	Long: "List agents. For example:\n" +
 		"$ starship-cli agent list --api-server=<address>",
 	Run: func(cmd *cobra.Command, args []string) {
-		client := client.NewClient(apiServerAddress)
+		client := client.NewClientWithToken(apiServerAddress, token)
 		resp, err := client.ListAgents(nil)
 		if err != nil {
 			log.Error(err)
@@ -0,0 +1,63 @@
+// Copyright (C) 2023  Tricorder Observability
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package audit
+
+import (
+	"github.com/spf13/cobra"
+
+	"github.com/tricorder/src/cli/pkg/config"
+	"github.com/tricorder/src/cli/pkg/kubernetes"
+	"github.com/tricorder/src/utils/log"
+)
+
+var AuditCmd = &cobra.Command{
+	Use:   "audit",
+	Short: "Inspect the audit log",
+	Long:  "list the audit records of management operations and state transitions, requires an admin token",
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		// If Starship apiServerAddress is not set, try to get it from kubernetes
+		if apiServerAddress == "" {
+			newApiAddress, err := kubernetes.GetStarshipAPIAddress()
+			if err != nil {
+				log.Fatal("Failed to connect to Kubernetes API Server, " +
+					"please manually set --api-server to the correct API Server address.")
+			}
+			apiServerAddress = newApiAddress
+		}
+		resolved, err := config.ResolveToken(token)
+		if err != nil {
+			log.Fatalf("Failed to read the API token, error: %v", err)
+		}
+		token = resolved
+	},
+}
+
+var (
+	apiServerAddress string
+	// API token or JWT used to authenticate to API Server, specified from --token flag.
+	token string
+	// The format of the output.
+	outputFormat string
+)
+
+func init() {
+	AuditCmd.PersistentFlags().StringVar(&apiServerAddress, "api-server", "", "address of the Starship API Server.")
+	AuditCmd.PersistentFlags().StringVar(&token, "token", "",
+		"API token or JWT of the Starship API Server, defaults to $"+config.TokenEnv+" or the config file.")
+	AuditCmd.PersistentFlags().StringVarP(&outputFormat, "output", "o", "yaml", "the style (json,yaml,table) of output.")
+
+	AuditCmd.AddCommand(listCmd)
+}
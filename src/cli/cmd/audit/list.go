@@ -0,0 +1,73 @@
+// Copyright (C) 2023  Tricorder Observability
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package audit
+
+import (
+	"encoding/json"
+
+	"github.com/spf13/cobra"
+
+	"github.com/tricorder/src/api-server/http/client"
+	"github.com/tricorder/src/api-server/http/dao"
+	"github.com/tricorder/src/cli/pkg/output"
+	"github.com/tricorder/src/utils/log"
+)
+
+var listCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List audit records",
+	Long: "List audit records, the latest first. For example:\n" +
+		"$ starship-cli audit list --api-server=<address>\n" +
+		"$ starship-cli audit list --target module:<module id> --limit 10 --api-server=<address>",
+	Run: func(cmd *cobra.Command, args []string) {
+		client := client.NewClientWithToken(apiServerAddress, token)
+		page, resp, err := client.ListAudit(filter, limit, offset)
+		if err != nil {
+			log.Error(err)
+			return
+		}
+		if page == nil {
+			log.Errorf("Failed to list audit records, code: %d, message: %s", resp.Code, resp.Message)
+			return
+		}
+
+		respByte, err := json.Marshal(page)
+		if err != nil {
+			log.Error(err)
+			return
+		}
+		if err := output.Print(outputFormat, respByte); err != nil {
+			log.Fatalf("Failed to write output, error: %v", err)
+		}
+	},
+}
+
+var (
+	filter dao.AuditFilter
+	limit  int
+	offset int
+)
+
+func init() {
+	listCmd.Flags().StringVar(&filter.Actor, "actor", "",
+		"only list the records of this actor, like the name of an API token, or 'agent:<agent id>'.")
+	listCmd.Flags().StringVar(&filter.Action, "action", "",
+		"only list the records of this action, like 'module.deploy' or 'agent.offline'.")
+	listCmd.Flags().StringVar(&filter.Target, "target", "",
+		"only list the records of this target, like 'module:<module id>' or 'agent:<agent id>'.")
+	listCmd.Flags().IntVar(&limit, "limit", 100, "the maximal number of listed records, at most 1000.")
+	listCmd.Flags().IntVar(&offset, "offset", 0, "the number of the latest matching records skipped.")
+}
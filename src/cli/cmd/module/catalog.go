@@ -0,0 +1,51 @@
+// Copyright (C) 2023  Tricorder Observability
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package module
+
+import (
+	"encoding/json"
+
+	"github.com/spf13/cobra"
+
+	"github.com/tricorder/src/api-server/http/client"
+	"github.com/tricorder/src/cli/pkg/output"
+	"github.com/tricorder/src/utils/log"
+)
+
+var catalogCmd = &cobra.Command{
+	Use:   "catalog",
+	Short: "List the eBPF+WASM modules bundled with API Server",
+	Long: "List the eBPF+WASM modules bundled with API Server, with their descriptions, required kernel features and " +
+		"parameters, which are created with 'starship-cli module create --from-catalog'. For example:\n" +
+		"$ starship-cli module catalog --api-server=<address>",
+	Run: func(cmd *cobra.Command, args []string) {
+		client := client.NewClientWithToken(apiServerAddress, token)
+		resp, err := client.ListCatalog()
+		if err != nil {
+			log.Error(err)
+			return
+		}
+
+		respByte, err := json.Marshal(resp)
+		if err != nil {
+			log.Error(err)
+			return
+		}
+		if err := output.Print(outputFormat, respByte); err != nil {
+			log.Error(err)
+		}
+	},
+}
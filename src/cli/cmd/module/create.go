@@ -21,12 +21,14 @@ import (
 	"github.com/spf13/cobra"
 
 	"github.com/tricorder/src/cli/pkg/output"
+	modulepb "github.com/tricorder/src/pb/module"
 	"github.com/tricorder/src/pb/module/common"
 
 	apiserver "github.com/tricorder/src/api-server/http"
 	"github.com/tricorder/src/api-server/http/client"
 	"github.com/tricorder/src/utils/file"
 	"github.com/tricorder/src/utils/log"
+	"github.com/tricorder/src/utils/signing"
 )
 
 var createCmd = &cobra.Command{
@@ -34,64 +36,32 @@ var createCmd = &cobra.Command{
 	Short: "Create an eBPF+WASM module",
 	Long: "Create an eBPF+WASM module with BCC source file and WASM binary file. For example:\n" +
 		"$ starship-cli module create --api-server=<address> -m <module_json_file> -b <bcc_source_file> " +
-		"-w <wasm_binary_file>",
+		"-w <wasm_binary_file>\n" +
+		"Or create a module bundled with API Server, listed by 'starship-cli module catalog'. For example:\n" +
+		"$ starship-cli module create --api-server=<address> --from-catalog ddos_event --param MAX_NB_PACKETS=500",
 	PersistentPreRun: func(cmd *cobra.Command, args []string) {
-		if wasmFileBinPath != "" {
-			if !file.IsWasmELF(wasmFileBinPath) {
-				log.Fatalf("Failed to read --wasm-bin-path='%s', error: it is not wasm elf", wasmFileBinPath)
-			}
-		}
-		if wasmFileTextPath != "" {
-			fileType := file.GetFileType(wasmFileTextPath)
-			switch fileType {
-			case file.C:
-				wasmFileTextLanguage = int(common.Lang_C)
-			case file.WAT:
-				wasmFileTextLanguage = int(common.Lang_WAT)
-			default:
-				log.Fatalf("Failed to read --wasm-text-path='%s', error: suffix is not .c or .wat", wasmFileTextPath)
-			}
-		}
-		if bccFilePath != "" {
-			fileType := file.GetFileType(bccFilePath)
-			if fileType != file.C {
-				log.Fatalf("Failed to read --bcc-file-path='%s', error: suffix is not '%s'", bccFilePath, file.C)
-			}
-		}
+		checkModuleFiles()
 	},
 	Run: func(cmd *cobra.Command, args []string) {
-		bccStr, err := file.Read(bccFilePath)
-		if err != nil {
-			log.Fatalf("Failed to read --bcc-file-path='%s', error: %v", bccFilePath, err)
-		}
-
-		moduleReq, err := parseModuleJsonFile(moduleFilePath)
-		if err != nil {
-			log.Fatalf("Failed to read --module-json-path='%s', error: %v", moduleFilePath, err)
-		}
-
-		if wasmFileBinPath != "" {
-			wasmBytes, err := file.ReadBin(wasmFileBinPath)
-			if err != nil {
-				log.Fatalf("Failed to read --wasm-bin-path='%s', error: %v", wasmFileBinPath, err)
-			}
-			moduleReq.Wasm.Code = wasmBytes
-			moduleReq.Wasm.Fmt = common.Format_BINARY
-			moduleReq.Wasm.Lang = common.Lang(wasmFileTextLanguage)
+		client := client.NewClientWithToken(apiServerAddress, token)
+		var resp *apiserver.CreateModuleResp
+		var err error
+		if catalogName != "" {
+			resp, err = client.CreateModuleFromCatalog(catalogName, &apiserver.CreateFromCatalogReq{
+				Name:       createName,
+				Parameters: catalogParams,
+				Tenant:     tenantName,
+			})
 		} else {
-			wasmBytes, err := file.ReadBin(wasmFileTextPath)
-			if err != nil {
-				log.Fatalf("Failed to read --wasm-code-path='%s', error: %v", wasmFileBinPath, err)
+			moduleReq := readModuleFiles()
+			if createName != "" {
+				moduleReq.Name = createName
+			}
+			if tenantName != "" {
+				moduleReq.Tenant = tenantName
 			}
-			moduleReq.Wasm.Code = wasmBytes
-			moduleReq.Wasm.Fmt = common.Format_TEXT
-			moduleReq.Wasm.Lang = common.Lang(wasmFileTextLanguage)
+			resp, err = client.CreateModule(moduleReq)
 		}
-
-		// override bcc code contet by bcc file
-		moduleReq.Ebpf.Code = bccStr
-		client := client.NewClient(apiServerAddress)
-		resp, err := client.CreateModule(moduleReq)
 		if err != nil {
 			log.Error(err)
 			return
@@ -120,6 +90,16 @@ var (
 	wasmFileBinPath      string
 	wasmFileTextPath     string
 	wasmFileTextLanguage int
+	// The path of the ed25519 private key that signs the module, specified from --signing-key flag.
+	signingKeyPath string
+	// The tenant of the created module, specified from --tenant flag.
+	tenantName string
+	// The name of the created module, specified from --name flag.
+	createName string
+	// The catalog entry that the module is created from, and the values of its parameters, specified from
+	// --from-catalog and --param flags.
+	catalogName   string
+	catalogParams map[string]string
 )
 
 func init() {
@@ -131,6 +111,89 @@ func init() {
 	createCmd.Flags().StringVarP(&wasmFileTextPath, "wasm-code-path", "c",
 		wasmFileTextPath, "The path of the WASM text file.")
 	createCmd.MarkFlagsMutuallyExclusive("wasm-bin-path", "wasm-code-path")
+	createCmd.Flags().StringVar(&signingKeyPath, "signing-key", signingKeyPath,
+		"The path of the PEM ed25519 private key that signs the module, needs --wasm-bin-path.")
+	createCmd.MarkFlagsMutuallyExclusive("signing-key", "wasm-code-path")
+	createCmd.Flags().StringVar(&tenantName, "tenant", tenantName,
+		"The tenant of the module, the tenant of --token if empty.")
+	createCmd.Flags().StringVar(&createName, "name", createName,
+		"The name of the module, the name in --module or the catalog entry's name if empty.")
+	createCmd.Flags().StringVar(&catalogName, "from-catalog", catalogName,
+		"The name of the catalog entry to create the module from, instead of the module files.")
+	createCmd.Flags().StringToStringVar(&catalogParams, "param", catalogParams,
+		"The values of the parameters of --from-catalog as NAME=VALUE, can be repeated.")
+	for _, flag := range []string{"module", "bcc", "wasm-bin-path", "wasm-code-path", "signing-key"} {
+		createCmd.MarkFlagsMutuallyExclusive("from-catalog", flag)
+	}
+}
+
+// checkModuleFiles exits if the files specified by the flags are of wrong types.
+func checkModuleFiles() {
+	if wasmFileBinPath != "" {
+		if !file.IsWasmELF(wasmFileBinPath) {
+			log.Fatalf("Failed to read --wasm-bin-path='%s', error: it is not wasm elf", wasmFileBinPath)
+		}
+	}
+	if wasmFileTextPath != "" {
+		fileType := file.GetFileType(wasmFileTextPath)
+		switch fileType {
+		case file.C:
+			wasmFileTextLanguage = int(common.Lang_C)
+		case file.WAT:
+			wasmFileTextLanguage = int(common.Lang_WAT)
+		default:
+			log.Fatalf("Failed to read --wasm-text-path='%s', error: suffix is not .c or .wat", wasmFileTextPath)
+		}
+	}
+	if bccFilePath != "" {
+		fileType := file.GetFileType(bccFilePath)
+		if fileType != file.C {
+			log.Fatalf("Failed to read --bcc-file-path='%s', error: suffix is not '%s'", bccFilePath, file.C)
+		}
+	}
+}
+
+// readModuleFiles returns the module described by the files specified by the flags.
+func readModuleFiles() *apiserver.CreateModuleReq {
+	bccStr, err := file.Read(bccFilePath)
+	if err != nil {
+		log.Fatalf("Failed to read --bcc-file-path='%s', error: %v", bccFilePath, err)
+	}
+
+	moduleReq, err := parseModuleJsonFile(moduleFilePath)
+	if err != nil {
+		log.Fatalf("Failed to read --module-json-path='%s', error: %v", moduleFilePath, err)
+	}
+
+	if wasmFileBinPath != "" {
+		wasmBytes, err := file.ReadBin(wasmFileBinPath)
+		if err != nil {
+			log.Fatalf("Failed to read --wasm-bin-path='%s', error: %v", wasmFileBinPath, err)
+		}
+		moduleReq.Wasm.Code = wasmBytes
+		moduleReq.Wasm.Fmt = common.Format_BINARY
+		moduleReq.Wasm.Lang = common.Lang(wasmFileTextLanguage)
+	} else {
+		wasmBytes, err := file.ReadBin(wasmFileTextPath)
+		if err != nil {
+			log.Fatalf("Failed to read --wasm-code-path='%s', error: %v", wasmFileBinPath, err)
+		}
+		moduleReq.Wasm.Code = wasmBytes
+		moduleReq.Wasm.Fmt = common.Format_TEXT
+		moduleReq.Wasm.Lang = common.Lang(wasmFileTextLanguage)
+	}
+
+	// override bcc code contet by bcc file
+	moduleReq.Ebpf.Code = bccStr
+
+	if signingKeyPath != "" {
+		key, err := signing.LoadPrivateKey(signingKeyPath)
+		if err != nil {
+			log.Fatalf("Failed to read --signing-key='%s', error: %v", signingKeyPath, err)
+		}
+		moduleReq.Signature = signing.Sign(&modulepb.Module{Ebpf: moduleReq.Ebpf, Wasm: moduleReq.Wasm}, key)
+	}
+	return moduleReq
 }
 
 func parseModuleJsonFile(moduleJsonFilePath string) (*apiserver.CreateModuleReq, error) {
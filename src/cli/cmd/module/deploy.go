@@ -31,8 +31,8 @@ var deployCmd = &cobra.Command{
 	Long: "Deploy a previously-created eBPF+WASM module. For example:\n" +
 		"$ starship-cli module deploy --api-server=<address> --id=ce8a4fbe_45db_49bb_9568_6688dd84480b",
 	Run: func(cmd *cobra.Command, args []string) {
-		client := client.NewClient(apiServerAddress)
-		resp, err := client.DeployModule(moduleId)
+		client := client.NewClientWithToken(apiServerAddress, token)
+		resp, err := client.DeployModule(moduleId, forceMigration)
 		if err != nil {
 			log.Error(err)
 			return
@@ -55,4 +55,6 @@ var deployCmd = &cobra.Command{
 func init() {
 	deployCmd.Flags().StringVarP(&moduleId, "id", "i", moduleId, "the ID of a previously-created eBPF+WASM module.")
 	_ = deployCmd.MarkFlagRequired("id")
+	deployCmd.Flags().BoolVar(&forceMigration, "force", forceMigration,
+		"Apply the changes that might lose data when migrating the module's data table, like dropping columns.")
 }
@@ -0,0 +1,86 @@
+// Copyright (C) 2023  Tricorder Observability
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package module
+
+import (
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/tricorder/src/api-server/http/client"
+	"github.com/tricorder/src/api-server/http/dao"
+	pb "github.com/tricorder/src/api-server/pb"
+	"github.com/tricorder/src/cli/pkg/model"
+	"github.com/tricorder/src/cli/pkg/output"
+	"github.com/tricorder/src/utils/log"
+)
+
+var describeCmd = &cobra.Command{
+	Use:   "describe <id>",
+	Short: "Describe the per-agent deployment states of an eBPF+WASM module",
+	Long: "Describe the per-agent deployment states of an eBPF+WASM module. For example:\n" +
+		"$ starship-cli module describe ce8a4fbe_45db_49bb_9568_6688dd84480b --api-server=<address>",
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		client := client.NewClientWithToken(apiServerAddress, token)
+		resp, err := client.ListModuleInstances(args[0])
+		if err != nil {
+			log.Error(err)
+			return
+		}
+
+		describeResp := model.Response{
+			Code:    resp.Code,
+			Message: resp.Message,
+			Data:    make([]map[string]interface{}, 0, len(resp.Data)),
+		}
+		for i := range resp.Data {
+			describeResp.Data = append(describeResp.Data, describeModuleInstance(&resp.Data[i]))
+		}
+
+		respByte, err := json.Marshal(describeResp)
+		if err != nil {
+			log.Error(err)
+			return
+		}
+		if err := output.Print(outputFormat, respByte); err != nil {
+			log.Fatalf("Failed to write output, error: %v", err)
+		}
+	},
+}
+
+// describeModuleInstance returns a human-readable form of the module instance, with the states rendered as names.
+func describeModuleInstance(inst *dao.ModuleInstanceGORM) map[string]interface{} {
+	return map[string]interface{}{
+		"node_name":        inst.NodeName,
+		"agent_id":         inst.AgentID,
+		"state":            pb.ModuleInstanceState(inst.State).String(),
+		"desire_state":     pb.ModuleState(inst.DesireState).String(),
+		"version":          strconv.Itoa(inst.Version),
+		"state_desc":       inst.StateDesc,
+		"create_time":      formatTime(inst.CreateTime),
+		"last_update_time": formatTime(inst.LastUpdateTime),
+	}
+}
+
+func formatTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
@@ -0,0 +1,58 @@
+// Copyright (C) 2023  Tricorder Observability
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package module
+
+import (
+	"github.com/spf13/cobra"
+
+	"github.com/tricorder/src/api-server/http/client"
+	"github.com/tricorder/src/utils/file"
+	"github.com/tricorder/src/utils/log"
+)
+
+// The path of the module's bundle, specified from --file flag.
+var bundleFilePath string
+
+var exportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Export an eBPF+WASM module as a bundle file",
+	Long: "Export an eBPF+WASM module as a *.tar.gz bundle file, which has the module's manifest, BCC code, WASM " +
+		"binary code, and the WASM source code if the module was created with it. The bundle can be versioned in " +
+		"git, and imported into other clusters with 'starship-cli module import'. For example:\n" +
+		"$ starship-cli module export --api-server=<address> --id <module_id> -f <bundle_file>",
+	Run: func(cmd *cobra.Command, args []string) {
+		client := client.NewClientWithToken(apiServerAddress, token)
+		bundle, err := client.ExportModule(moduleId)
+		if err != nil {
+			log.Fatalf("Failed to export module '%s', error: %v", moduleId, err)
+		}
+		path := bundleFilePath
+		if path == "" {
+			path = moduleId + ".tar.gz"
+		}
+		if err := file.Write(path, string(bundle)); err != nil {
+			log.Fatalf("Failed to write --file='%s', error: %v", path, err)
+		}
+		log.Infof("Exported module '%s' to %s", moduleId, path)
+	},
+}
+
+func init() {
+	exportCmd.Flags().StringVarP(&moduleId, "id", "i", moduleId, "the ID of the module.")
+	_ = exportCmd.MarkFlagRequired("id")
+	exportCmd.Flags().StringVarP(&bundleFilePath, "file", "f", bundleFilePath,
+		"The path of the written bundle file, <module_id>.tar.gz if empty.")
+}
@@ -0,0 +1,69 @@
+// Copyright (C) 2023  Tricorder Observability
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package module
+
+import (
+	"encoding/json"
+
+	"github.com/spf13/cobra"
+
+	"github.com/tricorder/src/api-server/http/client"
+	"github.com/tricorder/src/cli/pkg/output"
+	"github.com/tricorder/src/utils/file"
+	"github.com/tricorder/src/utils/log"
+)
+
+// The name of the imported module, specified from --name flag.
+var importName string
+
+var importCmd = &cobra.Command{
+	Use:   "import",
+	Short: "Create an eBPF+WASM module from a bundle file",
+	Long: "Create an eBPF+WASM module from a *.tar.gz bundle file, like the ones written by " +
+		"'starship-cli module export'. The WASM source code in the bundle, if any, is compiled again by API Server. " +
+		"For example:\n" +
+		"$ starship-cli module import --api-server=<address> -f <bundle_file> --name <module_name>",
+	Run: func(cmd *cobra.Command, args []string) {
+		bundle, err := file.ReadBin(bundleFilePath)
+		if err != nil {
+			log.Fatalf("Failed to read --file='%s', error: %v", bundleFilePath, err)
+		}
+		client := client.NewClientWithToken(apiServerAddress, token)
+		resp, err := client.ImportModule(bundle, importName, tenantName)
+		if err != nil {
+			log.Error(err)
+			return
+		}
+
+		respByte, err := json.Marshal(resp)
+		if err != nil {
+			log.Error(err)
+			return
+		}
+		if err := output.Print(outputFormat, respByte); err != nil {
+			log.Fatalf("Failed to write output, error: %v", err)
+		}
+	},
+}
+
+func init() {
+	importCmd.Flags().StringVarP(&bundleFilePath, "file", "f", bundleFilePath, "The path of the bundle file.")
+	_ = importCmd.MarkFlagRequired("file")
+	importCmd.Flags().StringVar(&importName, "name", importName,
+		"The name of the module, the name in the bundle if empty.")
+	importCmd.Flags().StringVar(&tenantName, "tenant", tenantName,
+		"The tenant of the module, the tenant of --token if empty.")
+}
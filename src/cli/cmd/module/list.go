@@ -18,6 +18,7 @@ package module
 import (
 	"encoding/json"
 
+	apiserver "github.com/tricorder/src/api-server/http"
 	"github.com/tricorder/src/api-server/http/client"
 	"github.com/tricorder/src/cli/pkg/output"
 	"github.com/tricorder/src/utils/log"
@@ -31,8 +32,8 @@ var listCmd = &cobra.Command{
 	Long: "List eBPF+WASM modules. For example:\n" +
 		"$ starship-cli module list --api-server=<address>",
 	Run: func(cmd *cobra.Command, args []string) {
-		client := client.NewClient(apiServerAddress)
-		resp, err := client.ListModules(nil)
+		client := client.NewClientWithToken(apiServerAddress, token)
+		resp, err := client.ListModules(&apiserver.ListModuleReq{Tenant: listTenant})
 		if err != nil {
 			log.Error(err)
 			return
@@ -53,3 +54,11 @@ var listCmd = &cobra.Command{
 		}
 	},
 }
+
+// Only the modules of this tenant are listed if not empty, specified from --tenant flag.
+var listTenant string
+
+func init() {
+	listCmd.Flags().StringVar(&listTenant, "tenant", listTenant,
+		"Only list the modules of the tenant, ignored for the tokens of a tenant.")
+}
@@ -18,6 +18,7 @@ package module
 import (
 	"github.com/spf13/cobra"
 
+	"github.com/tricorder/src/cli/pkg/config"
 	"github.com/tricorder/src/cli/pkg/kubernetes"
 	"github.com/tricorder/src/utils/log"
 )
@@ -26,17 +27,23 @@ var (
 	// Address of API Server, specified from --api-server flag.
 	apiServerAddress string
 
+	// API token or JWT used to authenticate to API Server, specified from --token flag.
+	token string
+
 	// ID of a previousely-created eBPF+WASM module.
 	moduleId string
 
 	// The format of the output.
 	outputFormat string
+
+	// Whether to apply the destructive changes when migrating a module's data table, specified from --force flag.
+	forceMigration bool
 )
 
 var ModuleCmd = &cobra.Command{
 	Use:   "module",
 	Short: "Manage eBPF+WASM modules",
-	Long:  "Create, deploy, undeploy, delete, list eBPF+WASM modules",
+	Long:  "Create, deploy, undeploy, upgrade, delete, list, describe eBPF+WASM modules",
 	PersistentPreRun: func(cmd *cobra.Command, args []string) {
 		// If Starship apiServerAddress is not set, try to get it from kubernetes
 		if apiServerAddress == "" {
@@ -47,12 +54,19 @@ var ModuleCmd = &cobra.Command{
 			}
 			apiServerAddress = newApiAddress
 		}
+		resolved, err := config.ResolveToken(token)
+		if err != nil {
+			log.Fatalf("Failed to read the API token, error: %v", err)
+		}
+		token = resolved
 	},
 }
 
 func init() {
 	// Here you will define your flags and configuration settings.
 	ModuleCmd.PersistentFlags().StringVar(&apiServerAddress, "api-server", "", "address of the Starship API Server.")
+	ModuleCmd.PersistentFlags().StringVar(&token, "token", "",
+		"API token or JWT of the Starship API Server, defaults to $"+config.TokenEnv+" or the config file.")
 	ModuleCmd.PersistentFlags().StringVarP(&outputFormat, "output", "o", "yaml", "format (yaml,json,table) of output.")
 
 	ModuleCmd.AddCommand(listCmd)
@@ -60,4 +74,11 @@ func init() {
 	ModuleCmd.AddCommand(deployCmd)
 	ModuleCmd.AddCommand(deleteCmd)
 	ModuleCmd.AddCommand(undeployCmd)
+	ModuleCmd.AddCommand(describeCmd)
+	ModuleCmd.AddCommand(upgradeCmd)
+	ModuleCmd.AddCommand(updateCmd)
+	ModuleCmd.AddCommand(validateCmd)
+	ModuleCmd.AddCommand(exportCmd)
+	ModuleCmd.AddCommand(importCmd)
+	ModuleCmd.AddCommand(catalogCmd)
 }
@@ -31,7 +31,7 @@ var undeployCmd = &cobra.Command{
 	Long: "Undeploy an previously-deployed eBPF+WASM module. For example:\n" +
 		"$ starship-cli module undeploy --api-server=<address> --id ce8a4fbe_45db_49bb_9568_6688dd84480b",
 	Run: func(cmd *cobra.Command, args []string) {
-		client := client.NewClient(apiServerAddress)
+		client := client.NewClientWithToken(apiServerAddress, token)
 		resp, err := client.UndeployModule(moduleId)
 		if err != nil {
 			log.Error(err)
@@ -0,0 +1,130 @@
+// Copyright (C) 2023  Tricorder Observability
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package module
+
+import (
+	"encoding/json"
+
+	"github.com/spf13/cobra"
+
+	apiserver "github.com/tricorder/src/api-server/http"
+	"github.com/tricorder/src/api-server/http/client"
+	"github.com/tricorder/src/cli/pkg/output"
+	"github.com/tricorder/src/pb/module/common"
+	"github.com/tricorder/src/pb/module/ebpf"
+	"github.com/tricorder/src/pb/module/wasm"
+	"github.com/tricorder/src/utils/file"
+	"github.com/tricorder/src/utils/log"
+)
+
+// The new name of the updated module, specified from --name flag.
+var updateName string
+
+var updateCmd = &cobra.Command{
+	Use:   "update",
+	Short: "Update an eBPF+WASM module that is not deployed",
+	Long: "Update the name, probes, code, output schema, or function name of an eBPF+WASM module that is not " +
+		"deployed. Only the specified parts are updated, the module JSON file updates its name, perf buffer name, " +
+		"probes, function name, output schema and sink, and its empty fields are kept. For example:\n" +
+		"$ starship-cli module update --api-server=<address> --id <module_id> -m <module_json_file> " +
+		"-w <wasm_binary_file>\n" +
+		"$ starship-cli module update --api-server=<address> --id <module_id> --name <new_name>",
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		if updateName == "" && moduleFilePath == "" && bccFilePath == "" && wasmFileBinPath == "" &&
+			wasmFileTextPath == "" {
+			log.Fatalf("Nothing to update, specify --name, --module, --bcc, --wasm-bin-path or --wasm-code-path")
+		}
+		checkModuleFiles()
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		moduleReq := readUpdateFiles()
+		client := client.NewClientWithToken(apiServerAddress, token)
+		resp, err := client.UpdateModule(moduleId, moduleReq)
+		if err != nil {
+			log.Error(err)
+			return
+		}
+
+		respByte, err := json.Marshal(resp)
+		if err != nil {
+			log.Error(err)
+			return
+		}
+		if err := output.Print(outputFormat, respByte); err != nil {
+			log.Fatalf("Failed to write output, error: %v", err)
+		}
+	},
+}
+
+func init() {
+	updateCmd.Flags().StringVarP(&moduleId, "id", "i", moduleId, "the ID of the module.")
+	_ = updateCmd.MarkFlagRequired("id")
+	updateCmd.Flags().StringVar(&updateName, "name", updateName, "The new name of the module.")
+	updateCmd.Flags().StringVarP(&moduleFilePath, "module", "m",
+		moduleFilePath, "The path of the JSON file that describes an eBPF+WASM module.")
+	updateCmd.Flags().StringVarP(&bccFilePath, "bcc", "b", bccFilePath, "The path of the BCC source file.")
+	updateCmd.Flags().StringVarP(&wasmFileBinPath, "wasm-bin-path", "w",
+		wasmFileBinPath, "The path of the WASM binary file.")
+	updateCmd.Flags().StringVarP(&wasmFileTextPath, "wasm-code-path", "c",
+		wasmFileTextPath, "The path of the WASM text file.")
+	updateCmd.MarkFlagsMutuallyExclusive("wasm-bin-path", "wasm-code-path")
+}
+
+// readUpdateFiles returns the update of the module described by the flags.
+func readUpdateFiles() *apiserver.UpdateModuleReq {
+	moduleReq := &apiserver.UpdateModuleReq{Ebpf: &ebpf.Program{}, Wasm: &wasm.Program{}}
+	if moduleFilePath != "" {
+		module, err := parseModuleJsonFile(moduleFilePath)
+		if err != nil {
+			log.Fatalf("Failed to read --module='%s', error: %v", moduleFilePath, err)
+		}
+		moduleReq.Name = module.Name
+		if module.Ebpf != nil {
+			moduleReq.Ebpf = module.Ebpf
+		}
+		if module.Wasm != nil {
+			moduleReq.Wasm = module.Wasm
+		}
+	}
+	if updateName != "" {
+		moduleReq.Name = updateName
+	}
+
+	if bccFilePath != "" {
+		bccStr, err := file.Read(bccFilePath)
+		if err != nil {
+			log.Fatalf("Failed to read --bcc='%s', error: %v", bccFilePath, err)
+		}
+		moduleReq.Ebpf.Code = bccStr
+	}
+	if wasmFileBinPath != "" {
+		wasmBytes, err := file.ReadBin(wasmFileBinPath)
+		if err != nil {
+			log.Fatalf("Failed to read --wasm-bin-path='%s', error: %v", wasmFileBinPath, err)
+		}
+		moduleReq.Wasm.Code = wasmBytes
+		moduleReq.Wasm.Fmt = common.Format_BINARY
+	} else if wasmFileTextPath != "" {
+		wasmBytes, err := file.ReadBin(wasmFileTextPath)
+		if err != nil {
+			log.Fatalf("Failed to read --wasm-code-path='%s', error: %v", wasmFileTextPath, err)
+		}
+		moduleReq.Wasm.Code = wasmBytes
+		moduleReq.Wasm.Fmt = common.Format_TEXT
+		moduleReq.Wasm.Lang = common.Lang(wasmFileTextLanguage)
+	}
+	return moduleReq
+}
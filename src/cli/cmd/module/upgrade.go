@@ -0,0 +1,107 @@
+// Copyright (C) 2023  Tricorder Observability
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package module
+
+import (
+	"encoding/json"
+
+	"github.com/spf13/cobra"
+
+	"github.com/tricorder/src/api-server/http/client"
+	"github.com/tricorder/src/cli/pkg/output"
+	"github.com/tricorder/src/utils/log"
+)
+
+// The version to upgrade the module to, specified from --version flag.
+var upgradeVersion int
+
+// Whether to upgrade the module even if some of its agents are not connected, specified from --skip-disconnected flag.
+var skipDisconnected bool
+
+var upgradeCmd = &cobra.Command{
+	Use:   "upgrade",
+	Short: "Upgrade an eBPF+WASM module to a new version",
+	Long: "Upgrade an eBPF+WASM module to a new version, keeping its name, data table, and dashboard.\n" +
+		"A deployed module is upgraded agent by agent, and is rolled back if any agent fails to deploy the new " +
+		"version. For example, create a new version from files and upgrade to it:\n" +
+		"$ starship-cli module upgrade --api-server=<address> --id <module_id> -m <module_json_file> " +
+		"-b <bcc_source_file> -w <wasm_binary_file>\n" +
+		"Or upgrade (or roll back) to an existing version:\n" +
+		"$ starship-cli module upgrade --api-server=<address> --id <module_id> --version 1",
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		if upgradeVersion == 0 && moduleFilePath == "" {
+			log.Fatalf("Either --version or --module needs to be specified")
+		}
+		if upgradeVersion != 0 && moduleFilePath != "" {
+			log.Fatalf("--version and --module cannot be specified at the same time")
+		}
+		checkModuleFiles()
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		client := client.NewClientWithToken(apiServerAddress, token)
+
+		if upgradeVersion == 0 {
+			moduleReq := readModuleFiles()
+			resp, err := client.CreateModuleVersion(moduleId, moduleReq)
+			if err != nil {
+				log.Error(err)
+				return
+			}
+			if resp.Code != 200 {
+				log.Errorf("Failed to create a new version of module '%s', error: %s", moduleId, resp.Message)
+				return
+			}
+			upgradeVersion = resp.Version
+		}
+
+		resp, err := client.UpgradeModule(moduleId, upgradeVersion, forceMigration, skipDisconnected)
+		if err != nil {
+			log.Error(err)
+			return
+		}
+
+		respByte, err := json.Marshal(resp)
+		if err != nil {
+			log.Error(err)
+			return
+		}
+		if err := output.Print(outputFormat, respByte); err != nil {
+			log.Fatalf("Failed to write output, error: %v", err)
+		}
+	},
+}
+
+func init() {
+	upgradeCmd.Flags().StringVarP(&moduleId, "id", "i", moduleId, "the ID of a previously-created eBPF+WASM module.")
+	_ = upgradeCmd.MarkFlagRequired("id")
+	upgradeCmd.Flags().IntVar(&upgradeVersion, "version", upgradeVersion,
+		"The existing version to upgrade to, instead of creating a new version.")
+	upgradeCmd.Flags().StringVarP(&moduleFilePath, "module", "m",
+		moduleFilePath, "The path of the JSON file that describes the new version of the eBPF+WASM module.")
+	upgradeCmd.Flags().StringVarP(&bccFilePath, "bcc", "b", bccFilePath, "The path of the BCC source file.")
+	upgradeCmd.Flags().StringVarP(&wasmFileBinPath, "wasm-bin-path", "w",
+		wasmFileBinPath, "The path of the WASM binary file.")
+	upgradeCmd.Flags().StringVarP(&wasmFileTextPath, "wasm-code-path", "c",
+		wasmFileTextPath, "The path of the WASM text file.")
+	upgradeCmd.MarkFlagsMutuallyExclusive("wasm-bin-path", "wasm-code-path")
+	upgradeCmd.Flags().StringVar(&signingKeyPath, "signing-key", signingKeyPath,
+		"The path of the PEM ed25519 private key that signs the new version, needs --wasm-bin-path.")
+	upgradeCmd.MarkFlagsMutuallyExclusive("signing-key", "wasm-code-path")
+	upgradeCmd.Flags().BoolVar(&forceMigration, "force", forceMigration,
+		"Apply the changes that might lose data when migrating the module's data table, like narrowing column types.")
+	upgradeCmd.Flags().BoolVar(&skipDisconnected, "skip-disconnected", skipDisconnected,
+		"Upgrade the module even if some of its agents are not connected, they are upgraded when they connect.")
+}
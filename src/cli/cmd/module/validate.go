@@ -0,0 +1,76 @@
+// Copyright (C) 2023  Tricorder Observability
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package module
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/tricorder/src/api-server/http/client"
+	"github.com/tricorder/src/cli/pkg/output"
+	"github.com/tricorder/src/utils/log"
+)
+
+var validateCmd = &cobra.Command{
+	Use:   "validate",
+	Short: "Validate an eBPF+WASM module without creating it",
+	Long: "Validate an eBPF+WASM module like creating and deploying it, without creating it. API Server compiles " +
+		"the WASM text code, checks the WASM code exports the function and the io.h functions, and the probes " +
+		"attach the functions defined in the BCC code. Exits with 1 if the module is invalid. For example:\n" +
+		"$ starship-cli module validate --api-server=<address> -m <module_json_file> -b <bcc_source_file> " +
+		"-w <wasm_binary_file>",
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		checkModuleFiles()
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		moduleReq := readModuleFiles()
+		if tenantName != "" {
+			moduleReq.Tenant = tenantName
+		}
+		client := client.NewClientWithToken(apiServerAddress, token)
+		resp, err := client.ValidateModule(moduleReq)
+		if err != nil {
+			log.Fatalf("Failed to validate module, error: %v", err)
+		}
+
+		respByte, err := json.Marshal(resp)
+		if err != nil {
+			log.Fatalf("Failed to encode response, error: %v", err)
+		}
+		if err := output.Print(outputFormat, respByte); err != nil {
+			log.Fatalf("Failed to write output, error: %v", err)
+		}
+		if resp.Code != http.StatusOK {
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	validateCmd.Flags().StringVarP(&moduleFilePath, "module", "m",
+		moduleFilePath, "The path of the JSON file that describes an eBPF+WASM module.")
+	validateCmd.Flags().StringVarP(&bccFilePath, "bcc", "b", bccFilePath, "The path of the BCC source file.")
+	validateCmd.Flags().StringVarP(&wasmFileBinPath, "wasm-bin-path", "w",
+		wasmFileBinPath, "The path of the WASM binary file.")
+	validateCmd.Flags().StringVarP(&wasmFileTextPath, "wasm-code-path", "c",
+		wasmFileTextPath, "The path of the WASM text file.")
+	validateCmd.MarkFlagsMutuallyExclusive("wasm-bin-path", "wasm-code-path")
+	validateCmd.Flags().StringVar(&tenantName, "tenant", tenantName,
+		"The tenant of the module, the tenant of --token if empty.")
+}
@@ -22,6 +22,7 @@ import (
 	"github.com/spf13/viper"
 
 	"github.com/tricorder/src/cli/cmd/agent"
+	"github.com/tricorder/src/cli/cmd/audit"
 	"github.com/tricorder/src/cli/cmd/module"
 	"github.com/tricorder/src/utils/log"
 )
@@ -50,6 +51,7 @@ func init() {
 	const apiServerFlagName = "api-server"
 	rootCmd.AddCommand(module.ModuleCmd)
 	rootCmd.AddCommand(agent.AgentCmd)
+	rootCmd.AddCommand(audit.AuditCmd)
 	rootCmd.PersistentFlags().StringVar(&apiServerAddress, apiServerFlagName,
 		"localhost:8080", "address of Starship API Server.")
 	err := viper.BindPFlag(apiServerFlagName, rootCmd.PersistentFlags().Lookup(apiServerFlagName))
@@ -0,0 +1,93 @@
+// Copyright (C) 2023  Tricorder Observability
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+// Package config reads the settings of Starship CLI from the flags, the environment variables and the config file.
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	// The environment variable of the API token that authenticates the CLI to API Server.
+	TokenEnv = "STARSHIP_API_TOKEN"
+
+	// The environment variable of the path to the config file, DefaultPath() if not set.
+	PathEnv = "STARSHIP_CONFIG"
+)
+
+// Config is the content of the config file, for example:
+//
+//	token: <API token>
+type Config struct {
+	// The API token that authenticates the CLI to API Server.
+	Token string `yaml:"token"`
+}
+
+// DefaultPath returns the default path to the config file, ~/.starship/config.yaml.
+func DefaultPath() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("while getting the default path to config file, failed to get home dir, error: %v", err)
+	}
+	return filepath.Join(home, ".starship", "config.yaml"), nil
+}
+
+// Load returns the config in the file at path, or an empty config if the file does not exist.
+func Load(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return &Config{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("while loading config, failed to read '%s', error: %v", path, err)
+	}
+	cfg := &Config{}
+	err = yaml.UnmarshalStrict(data, cfg)
+	if err != nil {
+		return nil, fmt.Errorf("while loading config, failed to parse '%s', error: %v", path, err)
+	}
+	return cfg, nil
+}
+
+// ResolveToken returns the API token from the --token flag's value, the STARSHIP_API_TOKEN environment variable, or
+// the config file, in this order. Returns an empty token if none is set.
+func ResolveToken(flagValue string) (string, error) {
+	if len(flagValue) > 0 {
+		return flagValue, nil
+	}
+	if token := os.Getenv(TokenEnv); len(token) > 0 {
+		return token, nil
+	}
+	path := os.Getenv(PathEnv)
+	if len(path) == 0 {
+		var err error
+		path, err = DefaultPath()
+		if err != nil {
+			return "", err
+		}
+	}
+	cfg, err := Load(path)
+	if err != nil {
+		return "", err
+	}
+	return cfg.Token, nil
+}
@@ -0,0 +1,57 @@
+// Copyright (C) 2023  Tricorder Observability
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Tests that the token is resolved from the flag, the environment variable and the config file, in this order.
+func TestResolveToken(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	t.Setenv(PathEnv, path)
+	t.Setenv(TokenEnv, "")
+
+	token, err := ResolveToken("")
+	require.Nil(err)
+	assert.Empty(token)
+
+	require.Nil(os.WriteFile(path, []byte("token: file-token\n"), 0o600))
+	token, err = ResolveToken("")
+	require.Nil(err)
+	assert.Equal("file-token", token)
+
+	t.Setenv(TokenEnv, "env-token")
+	token, err = ResolveToken("")
+	require.Nil(err)
+	assert.Equal("env-token", token)
+
+	token, err = ResolveToken("flag-token")
+	require.Nil(err)
+	assert.Equal("flag-token", token)
+
+	t.Setenv(TokenEnv, "")
+	require.Nil(os.WriteFile(path, []byte("tokn: typo\n"), 0o600))
+	_, err = ResolveToken("")
+	assert.ErrorContains(err, "failed to parse")
+}
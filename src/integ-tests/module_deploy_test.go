@@ -23,8 +23,8 @@ import (
 
 	"github.com/tricorder/src/api-server/http"
 	"github.com/tricorder/src/api-server/http/dao"
+	"github.com/tricorder/src/api-server/utils/channel"
 	"github.com/tricorder/src/api-server/wasm"
-	"github.com/tricorder/src/utils/cond"
 	"github.com/tricorder/src/utils/lock"
 	"github.com/tricorder/src/utils/sys"
 
@@ -67,7 +67,8 @@ func TestGetDeployReqForModule(t *testing.T) {
 		Module:          dao.Module,
 		NodeAgent:       dao.NodeAgent,
 		ModuleInstance:  dao.ModuleInstance,
-		WaitCond:        cond.NewCond(),
+		ModuleVersion:   dao.ModuleVersion,
+		Dispatcher:      channel.NewDispatcher(),
 		GLock:           lock.NewLock(),
 	}
 
@@ -115,34 +115,49 @@ func (Lang) EnumDescriptor() ([]byte, []int) {
 type DataField_Type int32
 
 const (
-	DataField_BOOL    DataField_Type = 0
-	DataField_DATE    DataField_Type = 1
-	DataField_INT     DataField_Type = 2
-	DataField_INTEGER DataField_Type = 3
-	DataField_JSON    DataField_Type = 4
-	DataField_JSONB   DataField_Type = 5
-	DataField_TEXT    DataField_Type = 6
+	DataField_BOOL        DataField_Type = 0
+	DataField_DATE        DataField_Type = 1
+	DataField_INT         DataField_Type = 2
+	DataField_INTEGER     DataField_Type = 3
+	DataField_JSON        DataField_Type = 4
+	DataField_JSONB       DataField_Type = 5
+	DataField_TEXT        DataField_Type = 6
+	DataField_TIMESTAMPTZ DataField_Type = 7
+	DataField_BIGINT      DataField_Type = 8
+	DataField_DOUBLE      DataField_Type = 9
+	DataField_BYTEA       DataField_Type = 10
+	DataField_INET        DataField_Type = 11
 )
 
 // Enum value maps for DataField_Type.
 var (
 	DataField_Type_name = map[int32]string{
-		0: "BOOL",
-		1: "DATE",
-		2: "INT",
-		3: "INTEGER",
-		4: "JSON",
-		5: "JSONB",
-		6: "TEXT",
+		0:  "BOOL",
+		1:  "DATE",
+		2:  "INT",
+		3:  "INTEGER",
+		4:  "JSON",
+		5:  "JSONB",
+		6:  "TEXT",
+		7:  "TIMESTAMPTZ",
+		8:  "BIGINT",
+		9:  "DOUBLE",
+		10: "BYTEA",
+		11: "INET",
 	}
 	DataField_Type_value = map[string]int32{
-		"BOOL":    0,
-		"DATE":    1,
-		"INT":     2,
-		"INTEGER": 3,
-		"JSON":    4,
-		"JSONB":   5,
-		"TEXT":    6,
+		"BOOL":        0,
+		"DATE":        1,
+		"INT":         2,
+		"INTEGER":     3,
+		"JSON":        4,
+		"JSONB":       5,
+		"TEXT":        6,
+		"TIMESTAMPTZ": 7,
+		"BIGINT":      8,
+		"DOUBLE":      9,
+		"BYTEA":       10,
+		"INET":        11,
 	}
 )
 
@@ -173,13 +188,131 @@ func (DataField_Type) EnumDescriptor() ([]byte, []int) {
 	return file_src_pb_module_common_common_proto_rawDescGZIP(), []int{0, 0}
 }
 
+type DataField_Metric int32
+
+const (
+	DataField_NOT_METRIC DataField_Metric = 0
+	DataField_LABEL      DataField_Metric = 1
+	DataField_COUNTER    DataField_Metric = 2
+	DataField_GAUGE      DataField_Metric = 3
+	DataField_HISTOGRAM  DataField_Metric = 4
+)
+
+// Enum value maps for DataField_Metric.
+var (
+	DataField_Metric_name = map[int32]string{
+		0: "NOT_METRIC",
+		1: "LABEL",
+		2: "COUNTER",
+		3: "GAUGE",
+		4: "HISTOGRAM",
+	}
+	DataField_Metric_value = map[string]int32{
+		"NOT_METRIC": 0,
+		"LABEL":      1,
+		"COUNTER":    2,
+		"GAUGE":      3,
+		"HISTOGRAM":  4,
+	}
+)
+
+func (x DataField_Metric) Enum() *DataField_Metric {
+	p := new(DataField_Metric)
+	*p = x
+	return p
+}
+
+func (x DataField_Metric) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (DataField_Metric) Descriptor() protoreflect.EnumDescriptor {
+	return file_src_pb_module_common_common_proto_enumTypes[3].Descriptor()
+}
+
+func (DataField_Metric) Type() protoreflect.EnumType {
+	return &file_src_pb_module_common_common_proto_enumTypes[3]
+}
+
+func (x DataField_Metric) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use DataField_Metric.Descriptor instead.
+func (DataField_Metric) EnumDescriptor() ([]byte, []int) {
+	return file_src_pb_module_common_common_proto_rawDescGZIP(), []int{0, 1}
+}
+
+type Sink_Type int32
+
+const (
+	Sink_POSTGRES     Sink_Type = 0
+	Sink_FILE         Sink_Type = 1
+	Sink_STDOUT       Sink_Type = 2
+	Sink_OTLP         Sink_Type = 3
+	Sink_PROMETHEUS   Sink_Type = 4
+	Sink_OTLP_METRICS Sink_Type = 5
+)
+
+// Enum value maps for Sink_Type.
+var (
+	Sink_Type_name = map[int32]string{
+		0: "POSTGRES",
+		1: "FILE",
+		2: "STDOUT",
+		3: "OTLP",
+		4: "PROMETHEUS",
+		5: "OTLP_METRICS",
+	}
+	Sink_Type_value = map[string]int32{
+		"POSTGRES":     0,
+		"FILE":         1,
+		"STDOUT":       2,
+		"OTLP":         3,
+		"PROMETHEUS":   4,
+		"OTLP_METRICS": 5,
+	}
+)
+
+func (x Sink_Type) Enum() *Sink_Type {
+	p := new(Sink_Type)
+	*p = x
+	return p
+}
+
+func (x Sink_Type) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (Sink_Type) Descriptor() protoreflect.EnumDescriptor {
+	return file_src_pb_module_common_common_proto_enumTypes[4].Descriptor()
+}
+
+func (Sink_Type) Type() protoreflect.EnumType {
+	return &file_src_pb_module_common_common_proto_enumTypes[4]
+}
+
+func (x Sink_Type) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use Sink_Type.Descriptor instead.
+func (Sink_Type) EnumDescriptor() ([]byte, []int) {
+	return file_src_pb_module_common_common_proto_rawDescGZIP(), []int{4, 0}
+}
+
 type DataField struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
 	unknownFields protoimpl.UnknownFields
 
-	Name string         `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
-	Type DataField_Type `protobuf:"varint,2,opt,name=type,proto3,enum=tricorder.pb.module.common.DataField_Type" json:"type,omitempty"`
+	Name    string           `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Type    DataField_Type   `protobuf:"varint,2,opt,name=type,proto3,enum=tricorder.pb.module.common.DataField_Type" json:"type,omitempty"`
+	Array   bool             `protobuf:"varint,3,opt,name=array,proto3" json:"array,omitempty"`
+	NotNull bool             `protobuf:"varint,4,opt,name=not_null,json=notNull,proto3" json:"not_null,omitempty"`
+	Default string           `protobuf:"bytes,5,opt,name=default,proto3" json:"default,omitempty"`
+	Metric  DataField_Metric `protobuf:"varint,6,opt,name=metric,proto3,enum=tricorder.pb.module.common.DataField_Metric" json:"metric,omitempty"`
+	Buckets []float64        `protobuf:"fixed64,7,rep,packed,name=buckets,proto3" json:"buckets,omitempty"`
 }
 
 func (x *DataField) Reset() {
@@ -228,19 +361,128 @@ func (x *DataField) GetType() DataField_Type {
 	return DataField_BOOL
 }
 
+func (x *DataField) GetArray() bool {
+	if x != nil {
+		return x.Array
+	}
+	return false
+}
+
+func (x *DataField) GetNotNull() bool {
+	if x != nil {
+		return x.NotNull
+	}
+	return false
+}
+
+func (x *DataField) GetDefault() string {
+	if x != nil {
+		return x.Default
+	}
+	return ""
+}
+
+func (x *DataField) GetMetric() DataField_Metric {
+	if x != nil {
+		return x.Metric
+	}
+	return DataField_NOT_METRIC
+}
+
+func (x *DataField) GetBuckets() []float64 {
+	if x != nil {
+		return x.Buckets
+	}
+	return nil
+}
+
+type Index struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Name    string   `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Columns []string `protobuf:"bytes,2,rep,name=columns,proto3" json:"columns,omitempty"`
+	Unique  bool     `protobuf:"varint,3,opt,name=unique,proto3" json:"unique,omitempty"`
+	Method  string   `protobuf:"bytes,4,opt,name=method,proto3" json:"method,omitempty"`
+}
+
+func (x *Index) Reset() {
+	*x = Index{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_src_pb_module_common_common_proto_msgTypes[1]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Index) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Index) ProtoMessage() {}
+
+func (x *Index) ProtoReflect() protoreflect.Message {
+	mi := &file_src_pb_module_common_common_proto_msgTypes[1]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Index.ProtoReflect.Descriptor instead.
+func (*Index) Descriptor() ([]byte, []int) {
+	return file_src_pb_module_common_common_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *Index) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *Index) GetColumns() []string {
+	if x != nil {
+		return x.Columns
+	}
+	return nil
+}
+
+func (x *Index) GetUnique() bool {
+	if x != nil {
+		return x.Unique
+	}
+	return false
+}
+
+func (x *Index) GetMethod() string {
+	if x != nil {
+		return x.Method
+	}
+	return ""
+}
+
 type Schema struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
 	unknownFields protoimpl.UnknownFields
 
-	Name   string       `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
-	Fields []*DataField `protobuf:"bytes,2,rep,name=fields,proto3" json:"fields,omitempty"`
+	Name       string       `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Fields     []*DataField `protobuf:"bytes,2,rep,name=fields,proto3" json:"fields,omitempty"`
+	Hypertable *Hypertable  `protobuf:"bytes,3,opt,name=hypertable,proto3" json:"hypertable,omitempty"`
+	PrimaryKey []string     `protobuf:"bytes,4,rep,name=primary_key,json=primaryKey,proto3" json:"primary_key,omitempty"`
+	Indexes    []*Index     `protobuf:"bytes,5,rep,name=indexes,proto3" json:"indexes,omitempty"`
 }
 
 func (x *Schema) Reset() {
 	*x = Schema{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_src_pb_module_common_common_proto_msgTypes[1]
+		mi := &file_src_pb_module_common_common_proto_msgTypes[2]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -253,7 +495,7 @@ func (x *Schema) String() string {
 func (*Schema) ProtoMessage() {}
 
 func (x *Schema) ProtoReflect() protoreflect.Message {
-	mi := &file_src_pb_module_common_common_proto_msgTypes[1]
+	mi := &file_src_pb_module_common_common_proto_msgTypes[2]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -266,7 +508,7 @@ func (x *Schema) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use Schema.ProtoReflect.Descriptor instead.
 func (*Schema) Descriptor() ([]byte, []int) {
-	return file_src_pb_module_common_common_proto_rawDescGZIP(), []int{1}
+	return file_src_pb_module_common_common_proto_rawDescGZIP(), []int{2}
 }
 
 func (x *Schema) GetName() string {
@@ -283,6 +525,185 @@ func (x *Schema) GetFields() []*DataField {
 	return nil
 }
 
+func (x *Schema) GetHypertable() *Hypertable {
+	if x != nil {
+		return x.Hypertable
+	}
+	return nil
+}
+
+func (x *Schema) GetPrimaryKey() []string {
+	if x != nil {
+		return x.PrimaryKey
+	}
+	return nil
+}
+
+func (x *Schema) GetIndexes() []*Index {
+	if x != nil {
+		return x.Indexes
+	}
+	return nil
+}
+
+type Hypertable struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	TimeColumn    string `protobuf:"bytes,1,opt,name=time_column,json=timeColumn,proto3" json:"time_column,omitempty"`
+	Retention     string `protobuf:"bytes,2,opt,name=retention,proto3" json:"retention,omitempty"`
+	CompressAfter string `protobuf:"bytes,3,opt,name=compress_after,json=compressAfter,proto3" json:"compress_after,omitempty"`
+}
+
+func (x *Hypertable) Reset() {
+	*x = Hypertable{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_src_pb_module_common_common_proto_msgTypes[3]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Hypertable) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Hypertable) ProtoMessage() {}
+
+func (x *Hypertable) ProtoReflect() protoreflect.Message {
+	mi := &file_src_pb_module_common_common_proto_msgTypes[3]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Hypertable.ProtoReflect.Descriptor instead.
+func (*Hypertable) Descriptor() ([]byte, []int) {
+	return file_src_pb_module_common_common_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *Hypertable) GetTimeColumn() string {
+	if x != nil {
+		return x.TimeColumn
+	}
+	return ""
+}
+
+func (x *Hypertable) GetRetention() string {
+	if x != nil {
+		return x.Retention
+	}
+	return ""
+}
+
+func (x *Hypertable) GetCompressAfter() string {
+	if x != nil {
+		return x.CompressAfter
+	}
+	return ""
+}
+
+type Sink struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Type                Sink_Type         `protobuf:"varint,1,opt,name=type,proto3,enum=tricorder.pb.module.common.Sink_Type" json:"type,omitempty"`
+	Path                string            `protobuf:"bytes,2,opt,name=path,proto3" json:"path,omitempty"`
+	MaxBytes            int64             `protobuf:"varint,3,opt,name=max_bytes,json=maxBytes,proto3" json:"max_bytes,omitempty"`
+	MaxFiles            int32             `protobuf:"varint,4,opt,name=max_files,json=maxFiles,proto3" json:"max_files,omitempty"`
+	Endpoint            string            `protobuf:"bytes,5,opt,name=endpoint,proto3" json:"endpoint,omitempty"`
+	Headers             map[string]string `protobuf:"bytes,6,rep,name=headers,proto3" json:"headers,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+	PushIntervalSeconds int32             `protobuf:"varint,7,opt,name=push_interval_seconds,json=pushIntervalSeconds,proto3" json:"push_interval_seconds,omitempty"`
+}
+
+func (x *Sink) Reset() {
+	*x = Sink{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_src_pb_module_common_common_proto_msgTypes[4]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Sink) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Sink) ProtoMessage() {}
+
+func (x *Sink) ProtoReflect() protoreflect.Message {
+	mi := &file_src_pb_module_common_common_proto_msgTypes[4]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Sink.ProtoReflect.Descriptor instead.
+func (*Sink) Descriptor() ([]byte, []int) {
+	return file_src_pb_module_common_common_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *Sink) GetType() Sink_Type {
+	if x != nil {
+		return x.Type
+	}
+	return Sink_POSTGRES
+}
+
+func (x *Sink) GetPath() string {
+	if x != nil {
+		return x.Path
+	}
+	return ""
+}
+
+func (x *Sink) GetMaxBytes() int64 {
+	if x != nil {
+		return x.MaxBytes
+	}
+	return 0
+}
+
+func (x *Sink) GetMaxFiles() int32 {
+	if x != nil {
+		return x.MaxFiles
+	}
+	return 0
+}
+
+func (x *Sink) GetEndpoint() string {
+	if x != nil {
+		return x.Endpoint
+	}
+	return ""
+}
+
+func (x *Sink) GetHeaders() map[string]string {
+	if x != nil {
+		return x.Headers
+	}
+	return nil
+}
+
+func (x *Sink) GetPushIntervalSeconds() int32 {
+	if x != nil {
+		return x.PushIntervalSeconds
+	}
+	return 0
+}
+
 var File_src_pb_module_common_common_proto protoreflect.FileDescriptor
 
 var file_src_pb_module_common_common_proto_rawDesc = []byte{
@@ -290,28 +711,99 @@ var file_src_pb_module_common_common_proto_rawDesc = []byte{
 	0x63, 0x6f, 0x6d, 0x6d, 0x6f, 0x6e, 0x2f, 0x63, 0x6f, 0x6d, 0x6d, 0x6f, 0x6e, 0x2e, 0x70, 0x72,
 	0x6f, 0x74, 0x6f, 0x12, 0x1a, 0x74, 0x72, 0x69, 0x63, 0x6f, 0x72, 0x64, 0x65, 0x72, 0x2e, 0x70,
 	0x62, 0x2e, 0x6d, 0x6f, 0x64, 0x75, 0x6c, 0x65, 0x2e, 0x63, 0x6f, 0x6d, 0x6d, 0x6f, 0x6e, 0x22,
-	0xb0, 0x01, 0x0a, 0x09, 0x44, 0x61, 0x74, 0x61, 0x46, 0x69, 0x65, 0x6c, 0x64, 0x12, 0x12, 0x0a,
+	0xe6, 0x03, 0x0a, 0x09, 0x44, 0x61, 0x74, 0x61, 0x46, 0x69, 0x65, 0x6c, 0x64, 0x12, 0x12, 0x0a,
 	0x04, 0x6e, 0x61, 0x6d, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x6e, 0x61, 0x6d,
 	0x65, 0x12, 0x3e, 0x0a, 0x04, 0x74, 0x79, 0x70, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0e, 0x32,
 	0x2a, 0x2e, 0x74, 0x72, 0x69, 0x63, 0x6f, 0x72, 0x64, 0x65, 0x72, 0x2e, 0x70, 0x62, 0x2e, 0x6d,
 	0x6f, 0x64, 0x75, 0x6c, 0x65, 0x2e, 0x63, 0x6f, 0x6d, 0x6d, 0x6f, 0x6e, 0x2e, 0x44, 0x61, 0x74,
 	0x61, 0x46, 0x69, 0x65, 0x6c, 0x64, 0x2e, 0x54, 0x79, 0x70, 0x65, 0x52, 0x04, 0x74, 0x79, 0x70,
-	0x65, 0x22, 0x4f, 0x0a, 0x04, 0x54, 0x79, 0x70, 0x65, 0x12, 0x08, 0x0a, 0x04, 0x42, 0x4f, 0x4f,
-	0x4c, 0x10, 0x00, 0x12, 0x08, 0x0a, 0x04, 0x44, 0x41, 0x54, 0x45, 0x10, 0x01, 0x12, 0x07, 0x0a,
-	0x03, 0x49, 0x4e, 0x54, 0x10, 0x02, 0x12, 0x0b, 0x0a, 0x07, 0x49, 0x4e, 0x54, 0x45, 0x47, 0x45,
-	0x52, 0x10, 0x03, 0x12, 0x08, 0x0a, 0x04, 0x4a, 0x53, 0x4f, 0x4e, 0x10, 0x04, 0x12, 0x09, 0x0a,
-	0x05, 0x4a, 0x53, 0x4f, 0x4e, 0x42, 0x10, 0x05, 0x12, 0x08, 0x0a, 0x04, 0x54, 0x45, 0x58, 0x54,
-	0x10, 0x06, 0x22, 0x5b, 0x0a, 0x06, 0x53, 0x63, 0x68, 0x65, 0x6d, 0x61, 0x12, 0x12, 0x0a, 0x04,
-	0x6e, 0x61, 0x6d, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x6e, 0x61, 0x6d, 0x65,
-	0x12, 0x3d, 0x0a, 0x06, 0x66, 0x69, 0x65, 0x6c, 0x64, 0x73, 0x18, 0x02, 0x20, 0x03, 0x28, 0x0b,
-	0x32, 0x25, 0x2e, 0x74, 0x72, 0x69, 0x63, 0x6f, 0x72, 0x64, 0x65, 0x72, 0x2e, 0x70, 0x62, 0x2e,
-	0x6d, 0x6f, 0x64, 0x75, 0x6c, 0x65, 0x2e, 0x63, 0x6f, 0x6d, 0x6d, 0x6f, 0x6e, 0x2e, 0x44, 0x61,
-	0x74, 0x61, 0x46, 0x69, 0x65, 0x6c, 0x64, 0x52, 0x06, 0x66, 0x69, 0x65, 0x6c, 0x64, 0x73, 0x2a,
-	0x1e, 0x0a, 0x06, 0x46, 0x6f, 0x72, 0x6d, 0x61, 0x74, 0x12, 0x08, 0x0a, 0x04, 0x54, 0x45, 0x58,
-	0x54, 0x10, 0x00, 0x12, 0x0a, 0x0a, 0x06, 0x42, 0x49, 0x4e, 0x41, 0x52, 0x59, 0x10, 0x01, 0x2a,
-	0x16, 0x0a, 0x04, 0x4c, 0x61, 0x6e, 0x67, 0x12, 0x05, 0x0a, 0x01, 0x43, 0x10, 0x00, 0x12, 0x07,
-	0x0a, 0x03, 0x57, 0x41, 0x54, 0x10, 0x01, 0x42, 0x08, 0x5a, 0x06, 0x63, 0x6f, 0x6d, 0x6d, 0x6f,
-	0x6e, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
+	0x65, 0x12, 0x14, 0x0a, 0x05, 0x61, 0x72, 0x72, 0x61, 0x79, 0x18, 0x03, 0x20, 0x01, 0x28, 0x08,
+	0x52, 0x05, 0x61, 0x72, 0x72, 0x61, 0x79, 0x12, 0x19, 0x0a, 0x08, 0x6e, 0x6f, 0x74, 0x5f, 0x6e,
+	0x75, 0x6c, 0x6c, 0x18, 0x04, 0x20, 0x01, 0x28, 0x08, 0x52, 0x07, 0x6e, 0x6f, 0x74, 0x4e, 0x75,
+	0x6c, 0x6c, 0x12, 0x18, 0x0a, 0x07, 0x64, 0x65, 0x66, 0x61, 0x75, 0x6c, 0x74, 0x18, 0x05, 0x20,
+	0x01, 0x28, 0x09, 0x52, 0x07, 0x64, 0x65, 0x66, 0x61, 0x75, 0x6c, 0x74, 0x12, 0x44, 0x0a, 0x06,
+	0x6d, 0x65, 0x74, 0x72, 0x69, 0x63, 0x18, 0x06, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x2c, 0x2e, 0x74,
+	0x72, 0x69, 0x63, 0x6f, 0x72, 0x64, 0x65, 0x72, 0x2e, 0x70, 0x62, 0x2e, 0x6d, 0x6f, 0x64, 0x75,
+	0x6c, 0x65, 0x2e, 0x63, 0x6f, 0x6d, 0x6d, 0x6f, 0x6e, 0x2e, 0x44, 0x61, 0x74, 0x61, 0x46, 0x69,
+	0x65, 0x6c, 0x64, 0x2e, 0x4d, 0x65, 0x74, 0x72, 0x69, 0x63, 0x52, 0x06, 0x6d, 0x65, 0x74, 0x72,
+	0x69, 0x63, 0x12, 0x18, 0x0a, 0x07, 0x62, 0x75, 0x63, 0x6b, 0x65, 0x74, 0x73, 0x18, 0x07, 0x20,
+	0x03, 0x28, 0x01, 0x52, 0x07, 0x62, 0x75, 0x63, 0x6b, 0x65, 0x74, 0x73, 0x22, 0x8d, 0x01, 0x0a,
+	0x04, 0x54, 0x79, 0x70, 0x65, 0x12, 0x08, 0x0a, 0x04, 0x42, 0x4f, 0x4f, 0x4c, 0x10, 0x00, 0x12,
+	0x08, 0x0a, 0x04, 0x44, 0x41, 0x54, 0x45, 0x10, 0x01, 0x12, 0x07, 0x0a, 0x03, 0x49, 0x4e, 0x54,
+	0x10, 0x02, 0x12, 0x0b, 0x0a, 0x07, 0x49, 0x4e, 0x54, 0x45, 0x47, 0x45, 0x52, 0x10, 0x03, 0x12,
+	0x08, 0x0a, 0x04, 0x4a, 0x53, 0x4f, 0x4e, 0x10, 0x04, 0x12, 0x09, 0x0a, 0x05, 0x4a, 0x53, 0x4f,
+	0x4e, 0x42, 0x10, 0x05, 0x12, 0x08, 0x0a, 0x04, 0x54, 0x45, 0x58, 0x54, 0x10, 0x06, 0x12, 0x0f,
+	0x0a, 0x0b, 0x54, 0x49, 0x4d, 0x45, 0x53, 0x54, 0x41, 0x4d, 0x50, 0x54, 0x5a, 0x10, 0x07, 0x12,
+	0x0a, 0x0a, 0x06, 0x42, 0x49, 0x47, 0x49, 0x4e, 0x54, 0x10, 0x08, 0x12, 0x0a, 0x0a, 0x06, 0x44,
+	0x4f, 0x55, 0x42, 0x4c, 0x45, 0x10, 0x09, 0x12, 0x09, 0x0a, 0x05, 0x42, 0x59, 0x54, 0x45, 0x41,
+	0x10, 0x0a, 0x12, 0x08, 0x0a, 0x04, 0x49, 0x4e, 0x45, 0x54, 0x10, 0x0b, 0x22, 0x4a, 0x0a, 0x06,
+	0x4d, 0x65, 0x74, 0x72, 0x69, 0x63, 0x12, 0x0e, 0x0a, 0x0a, 0x4e, 0x4f, 0x54, 0x5f, 0x4d, 0x45,
+	0x54, 0x52, 0x49, 0x43, 0x10, 0x00, 0x12, 0x09, 0x0a, 0x05, 0x4c, 0x41, 0x42, 0x45, 0x4c, 0x10,
+	0x01, 0x12, 0x0b, 0x0a, 0x07, 0x43, 0x4f, 0x55, 0x4e, 0x54, 0x45, 0x52, 0x10, 0x02, 0x12, 0x09,
+	0x0a, 0x05, 0x47, 0x41, 0x55, 0x47, 0x45, 0x10, 0x03, 0x12, 0x0d, 0x0a, 0x09, 0x48, 0x49, 0x53,
+	0x54, 0x4f, 0x47, 0x52, 0x41, 0x4d, 0x10, 0x04, 0x22, 0x65, 0x0a, 0x05, 0x49, 0x6e, 0x64, 0x65,
+	0x78, 0x12, 0x12, 0x0a, 0x04, 0x6e, 0x61, 0x6d, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52,
+	0x04, 0x6e, 0x61, 0x6d, 0x65, 0x12, 0x18, 0x0a, 0x07, 0x63, 0x6f, 0x6c, 0x75, 0x6d, 0x6e, 0x73,
+	0x18, 0x02, 0x20, 0x03, 0x28, 0x09, 0x52, 0x07, 0x63, 0x6f, 0x6c, 0x75, 0x6d, 0x6e, 0x73, 0x12,
+	0x16, 0x0a, 0x06, 0x75, 0x6e, 0x69, 0x71, 0x75, 0x65, 0x18, 0x03, 0x20, 0x01, 0x28, 0x08, 0x52,
+	0x06, 0x75, 0x6e, 0x69, 0x71, 0x75, 0x65, 0x12, 0x16, 0x0a, 0x06, 0x6d, 0x65, 0x74, 0x68, 0x6f,
+	0x64, 0x18, 0x04, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x6d, 0x65, 0x74, 0x68, 0x6f, 0x64, 0x22,
+	0x81, 0x02, 0x0a, 0x06, 0x53, 0x63, 0x68, 0x65, 0x6d, 0x61, 0x12, 0x12, 0x0a, 0x04, 0x6e, 0x61,
+	0x6d, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x6e, 0x61, 0x6d, 0x65, 0x12, 0x3d,
+	0x0a, 0x06, 0x66, 0x69, 0x65, 0x6c, 0x64, 0x73, 0x18, 0x02, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x25,
+	0x2e, 0x74, 0x72, 0x69, 0x63, 0x6f, 0x72, 0x64, 0x65, 0x72, 0x2e, 0x70, 0x62, 0x2e, 0x6d, 0x6f,
+	0x64, 0x75, 0x6c, 0x65, 0x2e, 0x63, 0x6f, 0x6d, 0x6d, 0x6f, 0x6e, 0x2e, 0x44, 0x61, 0x74, 0x61,
+	0x46, 0x69, 0x65, 0x6c, 0x64, 0x52, 0x06, 0x66, 0x69, 0x65, 0x6c, 0x64, 0x73, 0x12, 0x46, 0x0a,
+	0x0a, 0x68, 0x79, 0x70, 0x65, 0x72, 0x74, 0x61, 0x62, 0x6c, 0x65, 0x18, 0x03, 0x20, 0x01, 0x28,
+	0x0b, 0x32, 0x26, 0x2e, 0x74, 0x72, 0x69, 0x63, 0x6f, 0x72, 0x64, 0x65, 0x72, 0x2e, 0x70, 0x62,
+	0x2e, 0x6d, 0x6f, 0x64, 0x75, 0x6c, 0x65, 0x2e, 0x63, 0x6f, 0x6d, 0x6d, 0x6f, 0x6e, 0x2e, 0x48,
+	0x79, 0x70, 0x65, 0x72, 0x74, 0x61, 0x62, 0x6c, 0x65, 0x52, 0x0a, 0x68, 0x79, 0x70, 0x65, 0x72,
+	0x74, 0x61, 0x62, 0x6c, 0x65, 0x12, 0x1f, 0x0a, 0x0b, 0x70, 0x72, 0x69, 0x6d, 0x61, 0x72, 0x79,
+	0x5f, 0x6b, 0x65, 0x79, 0x18, 0x04, 0x20, 0x03, 0x28, 0x09, 0x52, 0x0a, 0x70, 0x72, 0x69, 0x6d,
+	0x61, 0x72, 0x79, 0x4b, 0x65, 0x79, 0x12, 0x3b, 0x0a, 0x07, 0x69, 0x6e, 0x64, 0x65, 0x78, 0x65,
+	0x73, 0x18, 0x05, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x21, 0x2e, 0x74, 0x72, 0x69, 0x63, 0x6f, 0x72,
+	0x64, 0x65, 0x72, 0x2e, 0x70, 0x62, 0x2e, 0x6d, 0x6f, 0x64, 0x75, 0x6c, 0x65, 0x2e, 0x63, 0x6f,
+	0x6d, 0x6d, 0x6f, 0x6e, 0x2e, 0x49, 0x6e, 0x64, 0x65, 0x78, 0x52, 0x07, 0x69, 0x6e, 0x64, 0x65,
+	0x78, 0x65, 0x73, 0x22, 0x72, 0x0a, 0x0a, 0x48, 0x79, 0x70, 0x65, 0x72, 0x74, 0x61, 0x62, 0x6c,
+	0x65, 0x12, 0x1f, 0x0a, 0x0b, 0x74, 0x69, 0x6d, 0x65, 0x5f, 0x63, 0x6f, 0x6c, 0x75, 0x6d, 0x6e,
+	0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0a, 0x74, 0x69, 0x6d, 0x65, 0x43, 0x6f, 0x6c, 0x75,
+	0x6d, 0x6e, 0x12, 0x1c, 0x0a, 0x09, 0x72, 0x65, 0x74, 0x65, 0x6e, 0x74, 0x69, 0x6f, 0x6e, 0x18,
+	0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x09, 0x72, 0x65, 0x74, 0x65, 0x6e, 0x74, 0x69, 0x6f, 0x6e,
+	0x12, 0x25, 0x0a, 0x0e, 0x63, 0x6f, 0x6d, 0x70, 0x72, 0x65, 0x73, 0x73, 0x5f, 0x61, 0x66, 0x74,
+	0x65, 0x72, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0d, 0x63, 0x6f, 0x6d, 0x70, 0x72, 0x65,
+	0x73, 0x73, 0x41, 0x66, 0x74, 0x65, 0x72, 0x22, 0xbc, 0x03, 0x0a, 0x04, 0x53, 0x69, 0x6e, 0x6b,
+	0x12, 0x39, 0x0a, 0x04, 0x74, 0x79, 0x70, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x25,
+	0x2e, 0x74, 0x72, 0x69, 0x63, 0x6f, 0x72, 0x64, 0x65, 0x72, 0x2e, 0x70, 0x62, 0x2e, 0x6d, 0x6f,
+	0x64, 0x75, 0x6c, 0x65, 0x2e, 0x63, 0x6f, 0x6d, 0x6d, 0x6f, 0x6e, 0x2e, 0x53, 0x69, 0x6e, 0x6b,
+	0x2e, 0x54, 0x79, 0x70, 0x65, 0x52, 0x04, 0x74, 0x79, 0x70, 0x65, 0x12, 0x12, 0x0a, 0x04, 0x70,
+	0x61, 0x74, 0x68, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x70, 0x61, 0x74, 0x68, 0x12,
+	0x1b, 0x0a, 0x09, 0x6d, 0x61, 0x78, 0x5f, 0x62, 0x79, 0x74, 0x65, 0x73, 0x18, 0x03, 0x20, 0x01,
+	0x28, 0x03, 0x52, 0x08, 0x6d, 0x61, 0x78, 0x42, 0x79, 0x74, 0x65, 0x73, 0x12, 0x1b, 0x0a, 0x09,
+	0x6d, 0x61, 0x78, 0x5f, 0x66, 0x69, 0x6c, 0x65, 0x73, 0x18, 0x04, 0x20, 0x01, 0x28, 0x05, 0x52,
+	0x08, 0x6d, 0x61, 0x78, 0x46, 0x69, 0x6c, 0x65, 0x73, 0x12, 0x1a, 0x0a, 0x08, 0x65, 0x6e, 0x64,
+	0x70, 0x6f, 0x69, 0x6e, 0x74, 0x18, 0x05, 0x20, 0x01, 0x28, 0x09, 0x52, 0x08, 0x65, 0x6e, 0x64,
+	0x70, 0x6f, 0x69, 0x6e, 0x74, 0x12, 0x47, 0x0a, 0x07, 0x68, 0x65, 0x61, 0x64, 0x65, 0x72, 0x73,
+	0x18, 0x06, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x2d, 0x2e, 0x74, 0x72, 0x69, 0x63, 0x6f, 0x72, 0x64,
+	0x65, 0x72, 0x2e, 0x70, 0x62, 0x2e, 0x6d, 0x6f, 0x64, 0x75, 0x6c, 0x65, 0x2e, 0x63, 0x6f, 0x6d,
+	0x6d, 0x6f, 0x6e, 0x2e, 0x53, 0x69, 0x6e, 0x6b, 0x2e, 0x48, 0x65, 0x61, 0x64, 0x65, 0x72, 0x73,
+	0x45, 0x6e, 0x74, 0x72, 0x79, 0x52, 0x07, 0x68, 0x65, 0x61, 0x64, 0x65, 0x72, 0x73, 0x12, 0x32,
+	0x0a, 0x15, 0x70, 0x75, 0x73, 0x68, 0x5f, 0x69, 0x6e, 0x74, 0x65, 0x72, 0x76, 0x61, 0x6c, 0x5f,
+	0x73, 0x65, 0x63, 0x6f, 0x6e, 0x64, 0x73, 0x18, 0x07, 0x20, 0x01, 0x28, 0x05, 0x52, 0x13, 0x70,
+	0x75, 0x73, 0x68, 0x49, 0x6e, 0x74, 0x65, 0x72, 0x76, 0x61, 0x6c, 0x53, 0x65, 0x63, 0x6f, 0x6e,
+	0x64, 0x73, 0x1a, 0x3a, 0x0a, 0x0c, 0x48, 0x65, 0x61, 0x64, 0x65, 0x72, 0x73, 0x45, 0x6e, 0x74,
+	0x72, 0x79, 0x12, 0x10, 0x0a, 0x03, 0x6b, 0x65, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52,
+	0x03, 0x6b, 0x65, 0x79, 0x12, 0x14, 0x0a, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18, 0x02, 0x20,
+	0x01, 0x28, 0x09, 0x52, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x3a, 0x02, 0x38, 0x01, 0x22, 0x56,
+	0x0a, 0x04, 0x54, 0x79, 0x70, 0x65, 0x12, 0x0c, 0x0a, 0x08, 0x50, 0x4f, 0x53, 0x54, 0x47, 0x52,
+	0x45, 0x53, 0x10, 0x00, 0x12, 0x08, 0x0a, 0x04, 0x46, 0x49, 0x4c, 0x45, 0x10, 0x01, 0x12, 0x0a,
+	0x0a, 0x06, 0x53, 0x54, 0x44, 0x4f, 0x55, 0x54, 0x10, 0x02, 0x12, 0x08, 0x0a, 0x04, 0x4f, 0x54,
+	0x4c, 0x50, 0x10, 0x03, 0x12, 0x0e, 0x0a, 0x0a, 0x50, 0x52, 0x4f, 0x4d, 0x45, 0x54, 0x48, 0x45,
+	0x55, 0x53, 0x10, 0x04, 0x12, 0x10, 0x0a, 0x0c, 0x4f, 0x54, 0x4c, 0x50, 0x5f, 0x4d, 0x45, 0x54,
+	0x52, 0x49, 0x43, 0x53, 0x10, 0x05, 0x2a, 0x1e, 0x0a, 0x06, 0x46, 0x6f, 0x72, 0x6d, 0x61, 0x74,
+	0x12, 0x08, 0x0a, 0x04, 0x54, 0x45, 0x58, 0x54, 0x10, 0x00, 0x12, 0x0a, 0x0a, 0x06, 0x42, 0x49,
+	0x4e, 0x41, 0x52, 0x59, 0x10, 0x01, 0x2a, 0x16, 0x0a, 0x04, 0x4c, 0x61, 0x6e, 0x67, 0x12, 0x05,
+	0x0a, 0x01, 0x43, 0x10, 0x00, 0x12, 0x07, 0x0a, 0x03, 0x57, 0x41, 0x54, 0x10, 0x01, 0x42, 0x08,
+	0x5a, 0x06, 0x63, 0x6f, 0x6d, 0x6d, 0x6f, 0x6e, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
 }
 
 var (
@@ -326,23 +818,34 @@ func file_src_pb_module_common_common_proto_rawDescGZIP() []byte {
 	return file_src_pb_module_common_common_proto_rawDescData
 }
 
-var file_src_pb_module_common_common_proto_enumTypes = make([]protoimpl.EnumInfo, 3)
-var file_src_pb_module_common_common_proto_msgTypes = make([]protoimpl.MessageInfo, 2)
+var file_src_pb_module_common_common_proto_enumTypes = make([]protoimpl.EnumInfo, 5)
+var file_src_pb_module_common_common_proto_msgTypes = make([]protoimpl.MessageInfo, 6)
 var file_src_pb_module_common_common_proto_goTypes = []interface{}{
-	(Format)(0),         // 0: tricorder.pb.module.common.Format
-	(Lang)(0),           // 1: tricorder.pb.module.common.Lang
-	(DataField_Type)(0), // 2: tricorder.pb.module.common.DataField.Type
-	(*DataField)(nil),   // 3: tricorder.pb.module.common.DataField
-	(*Schema)(nil),      // 4: tricorder.pb.module.common.Schema
+	(Format)(0),           // 0: tricorder.pb.module.common.Format
+	(Lang)(0),             // 1: tricorder.pb.module.common.Lang
+	(DataField_Type)(0),   // 2: tricorder.pb.module.common.DataField.Type
+	(DataField_Metric)(0), // 3: tricorder.pb.module.common.DataField.Metric
+	(Sink_Type)(0),        // 4: tricorder.pb.module.common.Sink.Type
+	(*DataField)(nil),     // 5: tricorder.pb.module.common.DataField
+	(*Index)(nil),         // 6: tricorder.pb.module.common.Index
+	(*Schema)(nil),        // 7: tricorder.pb.module.common.Schema
+	(*Hypertable)(nil),    // 8: tricorder.pb.module.common.Hypertable
+	(*Sink)(nil),          // 9: tricorder.pb.module.common.Sink
+	nil,                   // 10: tricorder.pb.module.common.Sink.HeadersEntry
 }
 var file_src_pb_module_common_common_proto_depIdxs = []int32{
-	2, // 0: tricorder.pb.module.common.DataField.type:type_name -> tricorder.pb.module.common.DataField.Type
-	3, // 1: tricorder.pb.module.common.Schema.fields:type_name -> tricorder.pb.module.common.DataField
-	2, // [2:2] is the sub-list for method output_type
-	2, // [2:2] is the sub-list for method input_type
-	2, // [2:2] is the sub-list for extension type_name
-	2, // [2:2] is the sub-list for extension extendee
-	0, // [0:2] is the sub-list for field type_name
+	2,  // 0: tricorder.pb.module.common.DataField.type:type_name -> tricorder.pb.module.common.DataField.Type
+	3,  // 1: tricorder.pb.module.common.DataField.metric:type_name -> tricorder.pb.module.common.DataField.Metric
+	5,  // 2: tricorder.pb.module.common.Schema.fields:type_name -> tricorder.pb.module.common.DataField
+	8,  // 3: tricorder.pb.module.common.Schema.hypertable:type_name -> tricorder.pb.module.common.Hypertable
+	6,  // 4: tricorder.pb.module.common.Schema.indexes:type_name -> tricorder.pb.module.common.Index
+	4,  // 5: tricorder.pb.module.common.Sink.type:type_name -> tricorder.pb.module.common.Sink.Type
+	10, // 6: tricorder.pb.module.common.Sink.headers:type_name -> tricorder.pb.module.common.Sink.HeadersEntry
+	7,  // [7:7] is the sub-list for method output_type
+	7,  // [7:7] is the sub-list for method input_type
+	7,  // [7:7] is the sub-list for extension type_name
+	7,  // [7:7] is the sub-list for extension extendee
+	0,  // [0:7] is the sub-list for field type_name
 }
 
 func init() { file_src_pb_module_common_common_proto_init() }
@@ -364,6 +867,18 @@ func file_src_pb_module_common_common_proto_init() {
 			}
 		}
 		file_src_pb_module_common_common_proto_msgTypes[1].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*Index); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_src_pb_module_common_common_proto_msgTypes[2].Exporter = func(v interface{}, i int) interface{} {
 			switch v := v.(*Schema); i {
 			case 0:
 				return &v.state
@@ -375,14 +890,38 @@ func file_src_pb_module_common_common_proto_init() {
 				return nil
 			}
 		}
+		file_src_pb_module_common_common_proto_msgTypes[3].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*Hypertable); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_src_pb_module_common_common_proto_msgTypes[4].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*Sink); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
 	}
 	type x struct{}
 	out := protoimpl.TypeBuilder{
 		File: protoimpl.DescBuilder{
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: file_src_pb_module_common_common_proto_rawDesc,
-			NumEnums:      3,
-			NumMessages:   2,
+			NumEnums:      5,
+			NumMessages:   6,
 			NumExtensions: 0,
 			NumServices:   0,
 		},
@@ -123,6 +123,7 @@ type Module struct {
 	Ebpf               *ebpf.Program           `protobuf:"bytes,2,opt,name=ebpf,proto3" json:"ebpf,omitempty"`
 	Wasm               *wasm.Program           `protobuf:"bytes,3,opt,name=wasm,proto3" json:"wasm,omitempty"`
 	WasmOutputEncoding Module_EncodingParadigm `protobuf:"varint,4,opt,name=wasm_output_encoding,json=wasmOutputEncoding,proto3,enum=tricorder.pb.module.Module_EncodingParadigm" json:"wasm_output_encoding,omitempty"`
+	Signature          *Signature              `protobuf:"bytes,5,opt,name=signature,proto3" json:"signature,omitempty"`
 }
 
 func (x *Module) Reset() {
@@ -185,6 +186,68 @@ func (x *Module) GetWasmOutputEncoding() Module_EncodingParadigm {
 	return Module_NONE
 }
 
+func (x *Module) GetSignature() *Signature {
+	if x != nil {
+		return x.Signature
+	}
+	return nil
+}
+
+type Signature struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	KeyId string `protobuf:"bytes,1,opt,name=key_id,json=keyId,proto3" json:"key_id,omitempty"`
+	Value []byte `protobuf:"bytes,2,opt,name=value,proto3" json:"value,omitempty"`
+}
+
+func (x *Signature) Reset() {
+	*x = Signature{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_src_pb_module_module_proto_msgTypes[1]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Signature) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Signature) ProtoMessage() {}
+
+func (x *Signature) ProtoReflect() protoreflect.Message {
+	mi := &file_src_pb_module_module_proto_msgTypes[1]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Signature.ProtoReflect.Descriptor instead.
+func (*Signature) Descriptor() ([]byte, []int) {
+	return file_src_pb_module_module_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *Signature) GetKeyId() string {
+	if x != nil {
+		return x.KeyId
+	}
+	return ""
+}
+
+func (x *Signature) GetValue() []byte {
+	if x != nil {
+		return x.Value
+	}
+	return nil
+}
+
 var File_src_pb_module_module_proto protoreflect.FileDescriptor
 
 var file_src_pb_module_module_proto_rawDesc = []byte{
@@ -195,7 +258,7 @@ var file_src_pb_module_module_proto_rawDesc = []byte{
 	0x2f, 0x65, 0x62, 0x70, 0x66, 0x2f, 0x65, 0x62, 0x70, 0x66, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f,
 	0x1a, 0x1d, 0x73, 0x72, 0x63, 0x2f, 0x70, 0x62, 0x2f, 0x6d, 0x6f, 0x64, 0x75, 0x6c, 0x65, 0x2f,
 	0x77, 0x61, 0x73, 0x6d, 0x2f, 0x77, 0x61, 0x73, 0x6d, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x22,
-	0xc2, 0x02, 0x0a, 0x06, 0x4d, 0x6f, 0x64, 0x75, 0x6c, 0x65, 0x12, 0x12, 0x0a, 0x04, 0x6e, 0x61,
+	0x80, 0x03, 0x0a, 0x06, 0x4d, 0x6f, 0x64, 0x75, 0x6c, 0x65, 0x12, 0x12, 0x0a, 0x04, 0x6e, 0x61,
 	0x6d, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x6e, 0x61, 0x6d, 0x65, 0x12, 0x35,
 	0x0a, 0x04, 0x65, 0x62, 0x70, 0x66, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x21, 0x2e, 0x74,
 	0x72, 0x69, 0x63, 0x6f, 0x72, 0x64, 0x65, 0x72, 0x2e, 0x70, 0x62, 0x2e, 0x6d, 0x6f, 0x64, 0x75,
@@ -209,14 +272,21 @@ var file_src_pb_module_module_proto_rawDesc = []byte{
 	0x63, 0x6f, 0x72, 0x64, 0x65, 0x72, 0x2e, 0x70, 0x62, 0x2e, 0x6d, 0x6f, 0x64, 0x75, 0x6c, 0x65,
 	0x2e, 0x4d, 0x6f, 0x64, 0x75, 0x6c, 0x65, 0x2e, 0x45, 0x6e, 0x63, 0x6f, 0x64, 0x69, 0x6e, 0x67,
 	0x50, 0x61, 0x72, 0x61, 0x64, 0x69, 0x67, 0x6d, 0x52, 0x12, 0x77, 0x61, 0x73, 0x6d, 0x4f, 0x75,
-	0x74, 0x70, 0x75, 0x74, 0x45, 0x6e, 0x63, 0x6f, 0x64, 0x69, 0x6e, 0x67, 0x22, 0x25, 0x0a, 0x14,
-	0x54, 0x72, 0x61, 0x6e, 0x73, 0x6d, 0x69, 0x73, 0x73, 0x69, 0x6f, 0x6e, 0x50, 0x61, 0x72, 0x61,
-	0x64, 0x69, 0x67, 0x6d, 0x12, 0x0d, 0x0a, 0x09, 0x50, 0x45, 0x52, 0x5f, 0x45, 0x56, 0x45, 0x4e,
-	0x54, 0x10, 0x00, 0x22, 0x2f, 0x0a, 0x10, 0x45, 0x6e, 0x63, 0x6f, 0x64, 0x69, 0x6e, 0x67, 0x50,
-	0x61, 0x72, 0x61, 0x64, 0x69, 0x67, 0x6d, 0x12, 0x08, 0x0a, 0x04, 0x4e, 0x4f, 0x4e, 0x45, 0x10,
-	0x00, 0x12, 0x07, 0x0a, 0x03, 0x54, 0x4c, 0x56, 0x10, 0x01, 0x12, 0x08, 0x0a, 0x04, 0x4a, 0x53,
-	0x4f, 0x4e, 0x10, 0x02, 0x42, 0x08, 0x5a, 0x06, 0x6d, 0x6f, 0x64, 0x75, 0x6c, 0x65, 0x62, 0x06,
-	0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
+	0x74, 0x70, 0x75, 0x74, 0x45, 0x6e, 0x63, 0x6f, 0x64, 0x69, 0x6e, 0x67, 0x12, 0x3c, 0x0a, 0x09,
+	0x73, 0x69, 0x67, 0x6e, 0x61, 0x74, 0x75, 0x72, 0x65, 0x18, 0x05, 0x20, 0x01, 0x28, 0x0b, 0x32,
+	0x1e, 0x2e, 0x74, 0x72, 0x69, 0x63, 0x6f, 0x72, 0x64, 0x65, 0x72, 0x2e, 0x70, 0x62, 0x2e, 0x6d,
+	0x6f, 0x64, 0x75, 0x6c, 0x65, 0x2e, 0x53, 0x69, 0x67, 0x6e, 0x61, 0x74, 0x75, 0x72, 0x65, 0x52,
+	0x09, 0x73, 0x69, 0x67, 0x6e, 0x61, 0x74, 0x75, 0x72, 0x65, 0x22, 0x25, 0x0a, 0x14, 0x54, 0x72,
+	0x61, 0x6e, 0x73, 0x6d, 0x69, 0x73, 0x73, 0x69, 0x6f, 0x6e, 0x50, 0x61, 0x72, 0x61, 0x64, 0x69,
+	0x67, 0x6d, 0x12, 0x0d, 0x0a, 0x09, 0x50, 0x45, 0x52, 0x5f, 0x45, 0x56, 0x45, 0x4e, 0x54, 0x10,
+	0x00, 0x22, 0x2f, 0x0a, 0x10, 0x45, 0x6e, 0x63, 0x6f, 0x64, 0x69, 0x6e, 0x67, 0x50, 0x61, 0x72,
+	0x61, 0x64, 0x69, 0x67, 0x6d, 0x12, 0x08, 0x0a, 0x04, 0x4e, 0x4f, 0x4e, 0x45, 0x10, 0x00, 0x12,
+	0x07, 0x0a, 0x03, 0x54, 0x4c, 0x56, 0x10, 0x01, 0x12, 0x08, 0x0a, 0x04, 0x4a, 0x53, 0x4f, 0x4e,
+	0x10, 0x02, 0x22, 0x38, 0x0a, 0x09, 0x53, 0x69, 0x67, 0x6e, 0x61, 0x74, 0x75, 0x72, 0x65, 0x12,
+	0x15, 0x0a, 0x06, 0x6b, 0x65, 0x79, 0x5f, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52,
+	0x05, 0x6b, 0x65, 0x79, 0x49, 0x64, 0x12, 0x14, 0x0a, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18,
+	0x02, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x42, 0x08, 0x5a, 0x06,
+	0x6d, 0x6f, 0x64, 0x75, 0x6c, 0x65, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
 }
 
 var (
@@ -232,23 +302,25 @@ func file_src_pb_module_module_proto_rawDescGZIP() []byte {
 }
 
 var file_src_pb_module_module_proto_enumTypes = make([]protoimpl.EnumInfo, 2)
-var file_src_pb_module_module_proto_msgTypes = make([]protoimpl.MessageInfo, 1)
+var file_src_pb_module_module_proto_msgTypes = make([]protoimpl.MessageInfo, 2)
 var file_src_pb_module_module_proto_goTypes = []interface{}{
 	(Module_TransmissionParadigm)(0), // 0: tricorder.pb.module.Module.TransmissionParadigm
 	(Module_EncodingParadigm)(0),     // 1: tricorder.pb.module.Module.EncodingParadigm
 	(*Module)(nil),                   // 2: tricorder.pb.module.Module
-	(*ebpf.Program)(nil),             // 3: tricorder.pb.module.ebpf.Program
-	(*wasm.Program)(nil),             // 4: tricorder.pb.module.wasm.Program
+	(*Signature)(nil),                // 3: tricorder.pb.module.Signature
+	(*ebpf.Program)(nil),             // 4: tricorder.pb.module.ebpf.Program
+	(*wasm.Program)(nil),             // 5: tricorder.pb.module.wasm.Program
 }
 var file_src_pb_module_module_proto_depIdxs = []int32{
-	3, // 0: tricorder.pb.module.Module.ebpf:type_name -> tricorder.pb.module.ebpf.Program
-	4, // 1: tricorder.pb.module.Module.wasm:type_name -> tricorder.pb.module.wasm.Program
+	4, // 0: tricorder.pb.module.Module.ebpf:type_name -> tricorder.pb.module.ebpf.Program
+	5, // 1: tricorder.pb.module.Module.wasm:type_name -> tricorder.pb.module.wasm.Program
 	1, // 2: tricorder.pb.module.Module.wasm_output_encoding:type_name -> tricorder.pb.module.Module.EncodingParadigm
-	3, // [3:3] is the sub-list for method output_type
-	3, // [3:3] is the sub-list for method input_type
-	3, // [3:3] is the sub-list for extension type_name
-	3, // [3:3] is the sub-list for extension extendee
-	0, // [0:3] is the sub-list for field type_name
+	3, // 3: tricorder.pb.module.Module.signature:type_name -> tricorder.pb.module.Signature
+	4, // [4:4] is the sub-list for method output_type
+	4, // [4:4] is the sub-list for method input_type
+	4, // [4:4] is the sub-list for extension type_name
+	4, // [4:4] is the sub-list for extension extendee
+	0, // [0:4] is the sub-list for field type_name
 }
 
 func init() { file_src_pb_module_module_proto_init() }
@@ -269,6 +341,18 @@ func file_src_pb_module_module_proto_init() {
 				return nil
 			}
 		}
+		file_src_pb_module_module_proto_msgTypes[1].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*Signature); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
 	}
 	type x struct{}
 	out := protoimpl.TypeBuilder{
@@ -276,7 +360,7 @@ func file_src_pb_module_module_proto_init() {
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: file_src_pb_module_module_proto_rawDesc,
 			NumEnums:      2,
-			NumMessages:   1,
+			NumMessages:   2,
 			NumExtensions: 0,
 			NumServices:   0,
 		},
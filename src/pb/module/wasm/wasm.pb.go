@@ -31,6 +31,7 @@ type Program struct {
 	Code         []byte         `protobuf:"bytes,3,opt,name=code,proto3" json:"code,omitempty"`
 	FnName       string         `protobuf:"bytes,4,opt,name=fn_name,json=fnName,proto3" json:"fn_name,omitempty"`
 	OutputSchema *common.Schema `protobuf:"bytes,5,opt,name=output_schema,json=outputSchema,proto3" json:"output_schema,omitempty"`
+	Sink         *common.Sink   `protobuf:"bytes,6,opt,name=sink,proto3" json:"sink,omitempty"`
 }
 
 func (x *Program) Reset() {
@@ -100,6 +101,13 @@ func (x *Program) GetOutputSchema() *common.Schema {
 	return nil
 }
 
+func (x *Program) GetSink() *common.Sink {
+	if x != nil {
+		return x.Sink
+	}
+	return nil
+}
+
 var File_src_pb_module_wasm_wasm_proto protoreflect.FileDescriptor
 
 var file_src_pb_module_wasm_wasm_proto_rawDesc = []byte{
@@ -108,7 +116,7 @@ var file_src_pb_module_wasm_wasm_proto_rawDesc = []byte{
 	0x18, 0x74, 0x72, 0x69, 0x63, 0x6f, 0x72, 0x64, 0x65, 0x72, 0x2e, 0x70, 0x62, 0x2e, 0x6d, 0x6f,
 	0x64, 0x75, 0x6c, 0x65, 0x2e, 0x77, 0x61, 0x73, 0x6d, 0x1a, 0x21, 0x73, 0x72, 0x63, 0x2f, 0x70,
 	0x62, 0x2f, 0x6d, 0x6f, 0x64, 0x75, 0x6c, 0x65, 0x2f, 0x63, 0x6f, 0x6d, 0x6d, 0x6f, 0x6e, 0x2f,
-	0x63, 0x6f, 0x6d, 0x6d, 0x6f, 0x6e, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x22, 0xeb, 0x01, 0x0a,
+	0x63, 0x6f, 0x6d, 0x6d, 0x6f, 0x6e, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x22, 0xa1, 0x02, 0x0a,
 	0x07, 0x50, 0x72, 0x6f, 0x67, 0x72, 0x61, 0x6d, 0x12, 0x34, 0x0a, 0x03, 0x66, 0x6d, 0x74, 0x18,
 	0x01, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x22, 0x2e, 0x74, 0x72, 0x69, 0x63, 0x6f, 0x72, 0x64, 0x65,
 	0x72, 0x2e, 0x70, 0x62, 0x2e, 0x6d, 0x6f, 0x64, 0x75, 0x6c, 0x65, 0x2e, 0x63, 0x6f, 0x6d, 0x6d,
@@ -123,8 +131,11 @@ var file_src_pb_module_wasm_wasm_proto_rawDesc = []byte{
 	0x6d, 0x61, 0x18, 0x05, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x22, 0x2e, 0x74, 0x72, 0x69, 0x63, 0x6f,
 	0x72, 0x64, 0x65, 0x72, 0x2e, 0x70, 0x62, 0x2e, 0x6d, 0x6f, 0x64, 0x75, 0x6c, 0x65, 0x2e, 0x63,
 	0x6f, 0x6d, 0x6d, 0x6f, 0x6e, 0x2e, 0x53, 0x63, 0x68, 0x65, 0x6d, 0x61, 0x52, 0x0c, 0x6f, 0x75,
-	0x74, 0x70, 0x75, 0x74, 0x53, 0x63, 0x68, 0x65, 0x6d, 0x61, 0x42, 0x06, 0x5a, 0x04, 0x77, 0x61,
-	0x73, 0x6d, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
+	0x74, 0x70, 0x75, 0x74, 0x53, 0x63, 0x68, 0x65, 0x6d, 0x61, 0x12, 0x34, 0x0a, 0x04, 0x73, 0x69,
+	0x6e, 0x6b, 0x18, 0x06, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x20, 0x2e, 0x74, 0x72, 0x69, 0x63, 0x6f,
+	0x72, 0x64, 0x65, 0x72, 0x2e, 0x70, 0x62, 0x2e, 0x6d, 0x6f, 0x64, 0x75, 0x6c, 0x65, 0x2e, 0x63,
+	0x6f, 0x6d, 0x6d, 0x6f, 0x6e, 0x2e, 0x53, 0x69, 0x6e, 0x6b, 0x52, 0x04, 0x73, 0x69, 0x6e, 0x6b,
+	0x42, 0x06, 0x5a, 0x04, 0x77, 0x61, 0x73, 0x6d, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
 }
 
 var (
@@ -145,16 +156,18 @@ var file_src_pb_module_wasm_wasm_proto_goTypes = []interface{}{
 	(common.Format)(0),    // 1: tricorder.pb.module.common.Format
 	(common.Lang)(0),      // 2: tricorder.pb.module.common.Lang
 	(*common.Schema)(nil), // 3: tricorder.pb.module.common.Schema
+	(*common.Sink)(nil),   // 4: tricorder.pb.module.common.Sink
 }
 var file_src_pb_module_wasm_wasm_proto_depIdxs = []int32{
 	1, // 0: tricorder.pb.module.wasm.Program.fmt:type_name -> tricorder.pb.module.common.Format
 	2, // 1: tricorder.pb.module.wasm.Program.lang:type_name -> tricorder.pb.module.common.Lang
 	3, // 2: tricorder.pb.module.wasm.Program.output_schema:type_name -> tricorder.pb.module.common.Schema
-	3, // [3:3] is the sub-list for method output_type
-	3, // [3:3] is the sub-list for method input_type
-	3, // [3:3] is the sub-list for extension type_name
-	3, // [3:3] is the sub-list for extension extendee
-	0, // [0:3] is the sub-list for field type_name
+	4, // 3: tricorder.pb.module.wasm.Program.sink:type_name -> tricorder.pb.module.common.Sink
+	4, // [4:4] is the sub-list for method output_type
+	4, // [4:4] is the sub-list for method input_type
+	4, // [4:4] is the sub-list for extension type_name
+	4, // [4:4] is the sub-list for extension extendee
+	0, // [0:4] is the sub-list for field type_name
 }
 
 func init() { file_src_pb_module_wasm_wasm_proto_init() }
@@ -0,0 +1,139 @@
+// Copyright (C) 2023  Tricorder Observability
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+// Package certs issues the TLS certificates of self-signed CAs in tests.
+package certs
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// CA is a self-signed certificate authority.
+type CA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+
+	// The PEM of the CA's certificate.
+	CertPEM []byte
+}
+
+// Pair is a PEM certificate and its private key.
+type Pair struct {
+	CertPEM []byte
+	KeyPEM  []byte
+}
+
+// NewCA returns a new self-signed CA with the common name.
+func NewCA(name string) (*CA, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("while creating CA, failed to generate key, error: %v", err)
+	}
+	template := newTemplate(name)
+	template.IsCA = true
+	template.BasicConstraintsValid = true
+	template.KeyUsage = x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return nil, fmt.Errorf("while creating CA, failed to create certificate, error: %v", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, fmt.Errorf("while creating CA, failed to parse certificate, error: %v", err)
+	}
+	return &CA{cert: cert, key: key, CertPEM: encodePEM("CERTIFICATE", der)}, nil
+}
+
+// Issue returns a new certificate with the common name, for both servers and clients. The hosts are the DNS names or
+// IP addresses of the servers that use the certificate.
+func (ca *CA) Issue(name string, hosts ...string) (*Pair, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("while issuing certificate, failed to generate key, error: %v", err)
+	}
+	template := newTemplate(name)
+	template.KeyUsage = x509.KeyUsageDigitalSignature
+	template.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth}
+	for _, host := range hosts {
+		if ip := net.ParseIP(host); ip != nil {
+			template.IPAddresses = append(template.IPAddresses, ip)
+		} else {
+			template.DNSNames = append(template.DNSNames, host)
+		}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		return nil, fmt.Errorf("while issuing certificate, failed to create certificate, error: %v", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, fmt.Errorf("while issuing certificate, failed to marshal key, error: %v", err)
+	}
+	return &Pair{CertPEM: encodePEM("CERTIFICATE", der), KeyPEM: encodePEM("EC PRIVATE KEY", keyDER)}, nil
+}
+
+// Files are the paths to the files written by WriteFiles.
+type Files struct {
+	CertFile string
+	KeyFile  string
+	CAFile   string
+}
+
+// WriteFiles writes the certificate, key and the CA's certificate to tls.crt, tls.key and ca.crt in dir, the layout
+// of kubernetes.io/tls Secrets.
+func (p *Pair) WriteFiles(dir string, ca *CA) (Files, error) {
+	files := Files{
+		CertFile: filepath.Join(dir, "tls.crt"),
+		KeyFile:  filepath.Join(dir, "tls.key"),
+		CAFile:   filepath.Join(dir, "ca.crt"),
+	}
+	contents := map[string][]byte{
+		files.CertFile: p.CertPEM,
+		files.KeyFile:  p.KeyPEM,
+		files.CAFile:   ca.CertPEM,
+	}
+	for path, data := range contents {
+		if err := os.WriteFile(path, data, 0o600); err != nil {
+			return Files{}, fmt.Errorf("while writing certificate files, failed to write %s, error: %v", path, err)
+		}
+	}
+	return files, nil
+}
+
+func newTemplate(name string) *x509.Certificate {
+	// Random serial numbers tell the certificates apart.
+	serial, _ := rand.Int(rand.Reader, big.NewInt(1<<62))
+	return &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(24 * time.Hour),
+	}
+}
+
+func encodePEM(blockType string, der []byte) []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der})
+}
@@ -0,0 +1,86 @@
+// Copyright (C) 2023  Tricorder Observability
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+// Package wasm encodes the WASM binary modules in tests.
+package wasm
+
+import (
+	"encoding/binary"
+)
+
+// IOFuncs are the functions of modules/common/io.h that agents call.
+var IOFuncs = []string{
+	"malloc_input_buf",
+	"free_input_buf",
+	"free_output_buf",
+	"get_input_buf",
+	"get_input_buf_cap",
+	"set_input_buf_len",
+	"get_output_buf",
+	"get_output_buf_len",
+}
+
+// NewModule returns a WASM binary module that exports a memory, and the functions, which do nothing.
+func NewModule(fns ...string) []byte {
+	code := []byte{0x00, 'a', 's', 'm', 0x01, 0x00, 0x00, 0x00}
+	// Type section: a single type () -> ().
+	code = appendSection(code, 1, []byte{1, 0x60, 0, 0})
+	// Function section: every function has the type 0.
+	funcs := appendU32(nil, uint32(len(fns)))
+	for range fns {
+		funcs = append(funcs, 0)
+	}
+	code = appendSection(code, 3, funcs)
+	// Memory section: a single memory of 1 page at least.
+	code = appendSection(code, 5, []byte{1, 0, 1})
+	// Export section: the functions and the memory.
+	exports := appendU32(nil, uint32(len(fns)+1))
+	for i, fn := range fns {
+		exports = appendName(exports, fn)
+		exports = append(exports, 0)
+		exports = appendU32(exports, uint32(i))
+	}
+	exports = appendName(exports, "memory")
+	exports = append(exports, 2, 0)
+	code = appendSection(code, 7, exports)
+	// Code section: every function body has no locals, and ends immediately.
+	bodies := appendU32(nil, uint32(len(fns)))
+	for range fns {
+		bodies = append(bodies, 2, 0, 0x0b)
+	}
+	return appendSection(code, 10, bodies)
+}
+
+// NewIOModule returns a WASM binary module that exports the function fn and the functions of io.h, see NewModule().
+func NewIOModule(fn string) []byte {
+	return NewModule(append([]string{fn}, IOFuncs...)...)
+}
+
+func appendSection(code []byte, id byte, content []byte) []byte {
+	code = append(code, id)
+	code = appendU32(code, uint32(len(content)))
+	return append(code, content...)
+}
+
+func appendName(buf []byte, name string) []byte {
+	buf = appendU32(buf, uint32(len(name)))
+	return append(buf, name...)
+}
+
+func appendU32(buf []byte, v uint32) []byte {
+	var b [binary.MaxVarintLen32]byte
+	n := binary.PutUvarint(b[:], uint64(v))
+	return append(buf, b[:n]...)
+}
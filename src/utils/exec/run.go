@@ -24,7 +24,8 @@ import (
 func Run(argv []string) (string, string, error) {
 	cmd := NewCommand(argv)
 	err := cmd.Start()
-	msg := fmt.Sprintf("command=%v stdout=%s stderr=%s error: %v", argv, cmd.Stdout(), cmd.Stderr(), err)
+	// The output is not read until Wait() returns, as the command is still writing it.
+	msg := fmt.Sprintf("command=%v error: %v", argv, err)
 	log.Infof(msg)
 	if err != nil {
 		return "", "", fmt.Errorf("start failed, message=%s", msg)
@@ -1,6 +1,8 @@
 package grpc
 
 import (
+	"context"
+
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials/insecure"
 
@@ -9,9 +11,34 @@ import (
 
 // DialInsecure returns a gRPC connection and error if failed.
 func DialInsecure(addr string) (*grpc.ClientConn, error) {
-	conn, err := grpc.Dial(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	return Dial(addr)
+}
+
+// Dial returns a gRPC connection and error if failed. The options configure the connection, like WithBearerToken().
+// The connection is insecure, unless the options have the transport credentials, like the ones of NewClientTLS().
+func Dial(addr string, opts ...grpc.DialOption) (*grpc.ClientConn, error) {
+	// The later transport credentials override the earlier ones.
+	opts = append([]grpc.DialOption{grpc.WithTransportCredentials(insecure.NewCredentials())}, opts...)
+	conn, err := grpc.Dial(addr, opts...)
 	if err != nil {
-		return nil, errors.Wrap("dialing server at "+addr, "dial without credentials", err)
+		return nil, errors.Wrap("dialing server at "+addr, "dial", err)
 	}
 	return conn, nil
 }
+
+// bearerToken sends the token in the Authorization metadata of each call.
+type bearerToken string
+
+func (t bearerToken) GetRequestMetadata(ctx context.Context, uri ...string) (map[string]string, error) {
+	return map[string]string{"authorization": "Bearer " + string(t)}, nil
+}
+
+// The token is also sent over the insecure connections, to API Server that does not serve TLS.
+func (t bearerToken) RequireTransportSecurity() bool {
+	return false
+}
+
+// WithBearerToken returns a DialOption that sends the token in the Authorization metadata of each call.
+func WithBearerToken(token string) grpc.DialOption {
+	return grpc.WithPerRPCCredentials(bearerToken(token))
+}
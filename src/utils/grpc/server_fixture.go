@@ -23,14 +23,15 @@ type ServerFixture struct {
 }
 
 // Returns a new ServerFixture that listens at the specified port of the localhost.
-func NewServerFixture(port int) (*ServerFixture, error) {
+// The options configure the server, like its interceptors.
+func NewServerFixture(port int, opts ...grpc.ServerOption) (*ServerFixture, error) {
 	listener, addr, err := sys.ListenTCP(port)
 	if err != nil {
 		return nil, errors.Wrap("newing ServerFixture", "listen to local port", err)
 	}
 	return &ServerFixture{
 		listener: listener,
-		Server:   grpc.NewServer(),
+		Server:   grpc.NewServer(opts...),
 		Addr:     addr,
 	}, nil
 }
@@ -0,0 +1,206 @@
+// Copyright (C) 2023  Tricorder Observability
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package grpc
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/credentials"
+
+	"github.com/tricorder/src/utils/errors"
+	"github.com/tricorder/src/utils/log"
+)
+
+// TLSFiles are the paths to the PEM files of a certificate, its private key, and the CA certificates that verify the
+// peer's certificates. All of them are required for mutual TLS.
+type TLSFiles struct {
+	CertFile string
+	KeyFile  string
+	CAFile   string
+}
+
+// Enabled returns true if any of the files is set.
+func (f TLSFiles) Enabled() bool {
+	return len(f.CertFile) > 0 || len(f.KeyFile) > 0 || len(f.CAFile) > 0
+}
+
+func (f TLSFiles) paths() []string {
+	return []string{f.CertFile, f.KeyFile, f.CAFile}
+}
+
+// tlsReloader loads the certificate and CA certificates from TLSFiles, and reloads them after the files are modified,
+// for example, after cert-manager rotates the certificates in the mounted Secret.
+type tlsReloader struct {
+	files TLSFiles
+
+	mu sync.Mutex
+	// The modification time of each file when it was loaded.
+	modTimes []time.Time
+	cert     tls.Certificate
+	pool     *x509.CertPool
+}
+
+func newTLSReloader(files TLSFiles) (*tlsReloader, error) {
+	for _, path := range files.paths() {
+		if len(path) == 0 {
+			return nil, errors.New("loading TLS files", "get the paths of all of the certificate, key and CA files")
+		}
+	}
+	r := &tlsReloader{files: files}
+	modTimes, err := r.stat()
+	if err != nil {
+		return nil, errors.Wrap("loading TLS files", "stat", err)
+	}
+	err = r.load(modTimes)
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *tlsReloader) stat() ([]time.Time, error) {
+	var modTimes []time.Time
+	for _, path := range r.files.paths() {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		modTimes = append(modTimes, info.ModTime())
+	}
+	return modTimes, nil
+}
+
+func (r *tlsReloader) load(modTimes []time.Time) error {
+	cert, err := tls.LoadX509KeyPair(r.files.CertFile, r.files.KeyFile)
+	if err != nil {
+		return errors.Wrap("loading TLS files", "load certificate and key", err)
+	}
+	caPEM, err := os.ReadFile(r.files.CAFile)
+	if err != nil {
+		return errors.Wrap("loading TLS files", "read CA file", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(caPEM) {
+		return errors.New("loading TLS files", "find any CA certificate in "+r.files.CAFile)
+	}
+	r.cert = cert
+	r.pool = pool
+	r.modTimes = modTimes
+	return nil
+}
+
+// get returns the certificate and CA certificates, which are reloaded first if any of the files was modified. Returns
+// the previously loaded ones if the reloading fails, because the files might be half-way through the rotation, and
+// the reloading is retried next time.
+func (r *tlsReloader) get() (tls.Certificate, *x509.CertPool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	modTimes, err := r.stat()
+	if err != nil {
+		log.Warnf("While reloading TLS files, failed to stat, keep the loaded certificates, error: %v", err)
+		return r.cert, r.pool
+	}
+	for i := range modTimes {
+		if modTimes[i].Equal(r.modTimes[i]) {
+			continue
+		}
+		err := r.load(modTimes)
+		if err != nil {
+			log.Warnf("%v, keep the loaded certificates", err)
+		} else {
+			log.Infof("Reloaded TLS certificate %s", r.files.CertFile)
+		}
+		break
+	}
+	return r.cert, r.pool
+}
+
+// reloadingTLS is the TLS transport credentials that use the latest certificates of tlsReloader in each handshake.
+type reloadingTLS struct {
+	reloader *tlsReloader
+	isServer bool
+	// The name to verify the server's certificate, the host of the dialed address if empty.
+	serverName string
+}
+
+// NewServerTLS returns the transport credentials of a gRPC server, which serves TLS with the certificate, and only
+// accepts the clients presenting certificates issued by the CA. The files are reloaded after they are modified.
+func NewServerTLS(files TLSFiles) (credentials.TransportCredentials, error) {
+	reloader, err := newTLSReloader(files)
+	if err != nil {
+		return nil, err
+	}
+	return &reloadingTLS{reloader: reloader, isServer: true}, nil
+}
+
+// NewClientTLS returns the transport credentials of a gRPC client, which presents the certificate, and verifies the
+// server's certificate with the CA. serverName overrides the host of the dialed address when verifying the server's
+// certificate. The files are reloaded after they are modified.
+func NewClientTLS(files TLSFiles, serverName string) (credentials.TransportCredentials, error) {
+	reloader, err := newTLSReloader(files)
+	if err != nil {
+		return nil, err
+	}
+	return &reloadingTLS{reloader: reloader, serverName: serverName}, nil
+}
+
+func (c *reloadingTLS) creds() credentials.TransportCredentials {
+	cert, pool := c.reloader.get()
+	config := &tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		Certificates: []tls.Certificate{cert},
+	}
+	if c.isServer {
+		config.ClientCAs = pool
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+	} else {
+		config.RootCAs = pool
+		config.ServerName = c.serverName
+	}
+	return credentials.NewTLS(config)
+}
+
+func (c *reloadingTLS) ClientHandshake(ctx context.Context, authority string, conn net.Conn) (
+	net.Conn, credentials.AuthInfo, error,
+) {
+	return c.creds().ClientHandshake(ctx, authority, conn)
+}
+
+func (c *reloadingTLS) ServerHandshake(conn net.Conn) (net.Conn, credentials.AuthInfo, error) {
+	return c.creds().ServerHandshake(conn)
+}
+
+// Info returns the same protocol info as credentials.NewTLS().
+func (c *reloadingTLS) Info() credentials.ProtocolInfo {
+	return credentials.ProtocolInfo{SecurityProtocol: "tls", SecurityVersion: "1.2"}
+}
+
+func (c *reloadingTLS) Clone() credentials.TransportCredentials {
+	clone := *c
+	return &clone
+}
+
+func (c *reloadingTLS) OverrideServerName(serverName string) error {
+	c.serverName = serverName
+	return nil
+}
@@ -0,0 +1,193 @@
+// Copyright (C) 2023  Tricorder Observability
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package grpc
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/peer"
+
+	"github.com/tricorder/src/testing/certs"
+)
+
+// startTLSServer starts a gRPC server with the health service, which serves mutual TLS with the files.
+func startTLSServer(t *testing.T, files certs.Files) string {
+	creds, err := NewServerTLS(TLSFiles(files))
+	require.NoError(t, err)
+	f, err := NewServerFixture(0, grpc.Creds(creds))
+	require.NoError(t, err)
+	healthpb.RegisterHealthServer(f.Server, health.NewServer())
+	go func() { _ = f.Serve() }()
+	t.Cleanup(f.Server.Stop)
+	return fmt.Sprintf("localhost:%d", f.Addr.(*net.TCPAddr).Port)
+}
+
+// checkHealth calls the health service at addr with the credentials, and returns the serial number of the server's
+// certificate.
+func checkHealth(addr string, creds credentials.TransportCredentials) (*big.Int, error) {
+	conn, err := Dial(addr, grpc.WithTransportCredentials(creds))
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	var p peer.Peer
+	_, err = healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{}, grpc.Peer(&p))
+	if err != nil {
+		return nil, err
+	}
+	return p.AuthInfo.(credentials.TLSInfo).State.PeerCertificates[0].SerialNumber, nil
+}
+
+func serialNumber(t *testing.T, pair *certs.Pair) *big.Int {
+	block, _ := pem.Decode(pair.CertPEM)
+	cert, err := x509.ParseCertificate(block.Bytes)
+	require.NoError(t, err)
+	return cert.SerialNumber
+}
+
+// Tests that the server only accepts the clients presenting the certificates issued by its CA, and the clients only
+// accept the server presenting the certificate issued by their CA.
+func TestTLS(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	ca, err := certs.NewCA("ca")
+	require.NoError(err)
+	otherCA, err := certs.NewCA("other-ca")
+	require.NoError(err)
+
+	serverPair, err := ca.Issue("api-server", "localhost")
+	require.NoError(err)
+	serverFiles, err := serverPair.WriteFiles(t.TempDir(), ca)
+	require.NoError(err)
+	addr := startTLSServer(t, serverFiles)
+
+	agentPair, err := ca.Issue("agent")
+	require.NoError(err)
+	agentFiles, err := agentPair.WriteFiles(t.TempDir(), ca)
+	require.NoError(err)
+	creds, err := NewClientTLS(TLSFiles(agentFiles), "")
+	require.NoError(err)
+	_, err = checkHealth(addr, creds)
+	assert.NoError(err)
+
+	// The client's certificate is issued by another CA.
+	roguePair, err := otherCA.Issue("agent")
+	require.NoError(err)
+	rogueFiles, err := roguePair.WriteFiles(t.TempDir(), ca)
+	require.NoError(err)
+	creds, err = NewClientTLS(TLSFiles(rogueFiles), "")
+	require.NoError(err)
+	_, err = checkHealth(addr, creds)
+	assert.Error(err)
+
+	// The client presents no certificate.
+	pool := x509.NewCertPool()
+	pool.AppendCertsFromPEM(ca.CertPEM)
+	_, err = checkHealth(addr, credentials.NewTLS(&tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12}))
+	assert.Error(err)
+
+	_, err = checkHealth(addr, insecure.NewCredentials())
+	assert.Error(err)
+
+	// The server's certificate is not issued by the client's CA.
+	untrustingFiles, err := agentPair.WriteFiles(t.TempDir(), otherCA)
+	require.NoError(err)
+	creds, err = NewClientTLS(TLSFiles(untrustingFiles), "")
+	require.NoError(err)
+	_, err = checkHealth(addr, creds)
+	assert.Error(err)
+
+	// The server's certificate is not for the server name.
+	creds, err = NewClientTLS(TLSFiles(agentFiles), "api-server.example.com")
+	require.NoError(err)
+	_, err = checkHealth(addr, creds)
+	assert.Error(err)
+
+	_, err = NewServerTLS(TLSFiles{CertFile: serverFiles.CertFile, KeyFile: serverFiles.KeyFile})
+	assert.ErrorContains(err, "get the paths of all of the certificate, key and CA files")
+}
+
+// Tests that the server uses the rotated certificate in the new connections without restarting, and keeps using the
+// loaded certificate if the files are invalid.
+func TestTLSReload(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	ca, err := certs.NewCA("ca")
+	require.NoError(err)
+	serverPair, err := ca.Issue("api-server", "localhost")
+	require.NoError(err)
+	serverDir := t.TempDir()
+	serverFiles, err := serverPair.WriteFiles(serverDir, ca)
+	require.NoError(err)
+	addr := startTLSServer(t, serverFiles)
+
+	agentPair, err := ca.Issue("agent")
+	require.NoError(err)
+	agentFiles, err := agentPair.WriteFiles(t.TempDir(), ca)
+	require.NoError(err)
+	creds, err := NewClientTLS(TLSFiles(agentFiles), "")
+	require.NoError(err)
+
+	serial, err := checkHealth(addr, creds)
+	require.NoError(err)
+	assert.Equal(serialNumber(t, serverPair), serial)
+
+	// Moves the modification time forward, in case the files are rewritten within the file system's time resolution.
+	touch := func(path string) {
+		later := time.Now().Add(time.Minute)
+		require.NoError(os.Chtimes(path, later, later))
+	}
+
+	rotatedPair, err := ca.Issue("api-server", "localhost")
+	require.NoError(err)
+	_, err = rotatedPair.WriteFiles(serverDir, ca)
+	require.NoError(err)
+	touch(serverFiles.CertFile)
+	touch(serverFiles.KeyFile)
+
+	serial, err = checkHealth(addr, creds)
+	require.NoError(err)
+	assert.Equal(serialNumber(t, rotatedPair), serial)
+
+	require.NoError(os.WriteFile(serverFiles.CertFile, []byte("not a certificate"), 0o600))
+	touch(serverFiles.CertFile)
+	touch(serverFiles.KeyFile)
+
+	serial, err = checkHealth(addr, creds)
+	require.NoError(err)
+	assert.Equal(serialNumber(t, rotatedPair), serial)
+}
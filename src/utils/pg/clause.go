@@ -0,0 +1,103 @@
+// Copyright (C) 2023  Tricorder Observability
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package pg
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type clauseKind int
+
+const (
+	whereClause clauseKind = iota
+	orderByClause
+	limitClause
+)
+
+// Clause is a part of the queries of Json.Get() and Json.List() after the table name, like a WHERE condition.
+// Its values, including the JSON paths, are passed as query arguments, instead of being formatted into the SQL.
+type Clause struct {
+	kind  clauseKind
+	path  []string
+	value interface{}
+	desc  bool
+}
+
+// Where returns a clause that selects the objects whose value at the JSON path, as text, equals the value,
+// e.g. Where([]string{"metadata", "name"}, "default"). Multiple Where clauses are joined by AND.
+func Where(path []string, value string) Clause {
+	return Clause{kind: whereClause, path: path, value: value}
+}
+
+// OrderBy returns a clause that sorts the objects by their values at the JSON path, as text.
+// Multiple OrderBy clauses sort by the paths in order.
+func OrderBy(path []string, desc bool) Clause {
+	return Clause{kind: orderByClause, path: path, desc: desc}
+}
+
+// Limit returns a clause that returns at most n objects.
+func Limit(n int) Clause {
+	return Clause{kind: limitClause, value: n}
+}
+
+// buildClauses returns the SQL of the clauses and the arguments, which are appended to args, as the placeholders
+// are numbered after the existing args.
+func buildClauses(clauses []Clause, args []interface{}) (string, []interface{}, error) {
+	placeholder := func(value interface{}) string {
+		args = append(args, value)
+		return "$" + strconv.Itoa(len(args))
+	}
+
+	var conds, orders []string
+	var limit string
+	for _, c := range clauses {
+		switch c.kind {
+		case whereClause:
+			if len(c.path) == 0 {
+				return "", nil, fmt.Errorf("the path of WHERE clause is empty")
+			}
+			conds = append(conds, fmt.Sprintf("data #>> %s = %s", placeholder(c.path), placeholder(c.value)))
+		case orderByClause:
+			if len(c.path) == 0 {
+				return "", nil, fmt.Errorf("the path of ORDER BY clause is empty")
+			}
+			order := fmt.Sprintf("data #>> %s", placeholder(c.path))
+			if c.desc {
+				order += " DESC"
+			}
+			orders = append(orders, order)
+		case limitClause:
+			if len(limit) > 0 {
+				return "", nil, fmt.Errorf("LIMIT clause is duplicated")
+			}
+			limit = "LIMIT " + placeholder(c.value)
+		}
+	}
+
+	var parts []string
+	if len(conds) > 0 {
+		parts = append(parts, "WHERE "+strings.Join(conds, " AND "))
+	}
+	if len(orders) > 0 {
+		parts = append(parts, "ORDER BY "+strings.Join(orders, ", "))
+	}
+	if len(limit) > 0 {
+		parts = append(parts, limit)
+	}
+	return strings.Join(parts, " "), args, nil
+}
@@ -0,0 +1,66 @@
+// Copyright (C) 2023  Tricorder Observability
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package pg
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Tests that buildSelectSQL passes the values of the clauses as arguments.
+func TestBuildSelectSQL(t *testing.T) {
+	assert := assert.New(t)
+
+	sql, args, err := buildSelectSQL("pods", nil)
+	assert.Nil(err)
+	assert.Equal(`SELECT data FROM "pods"`, sql)
+	assert.Empty(args)
+
+	hostile := "x' OR '1'='1"
+	sql, args, err = buildSelectSQL("pods", []Clause{
+		Where([]string{"metadata", "name"}, hostile),
+		Where([]string{"metadata", "namespace"}, "default"),
+		OrderBy([]string{"metadata", "creationTimestamp"}, true),
+		Limit(10),
+	})
+	assert.Nil(err)
+	assert.Equal(`SELECT data FROM "pods" WHERE data #>> $1 = $2 AND data #>> $3 = $4 `+
+		`ORDER BY data #>> $5 DESC LIMIT $6`, sql)
+	assert.Equal([]interface{}{
+		[]string{"metadata", "name"}, hostile,
+		[]string{"metadata", "namespace"}, "default",
+		[]string{"metadata", "creationTimestamp"},
+		10,
+	}, args)
+}
+
+// Tests that buildClauses numbers the placeholders after the existing arguments, and rejects invalid clauses.
+func TestBuildClauses(t *testing.T) {
+	assert := assert.New(t)
+
+	sql, args, err := buildClauses([]Clause{Where([]string{"uid"}, "1")}, []interface{}{"data"})
+	assert.Nil(err)
+	assert.Equal("WHERE data #>> $2 = $3", sql)
+	assert.Equal([]interface{}{"data", []string{"uid"}, "1"}, args)
+
+	_, _, err = buildClauses([]Clause{Where(nil, "1")}, nil)
+	assert.ErrorContains(err, "the path of WHERE clause is empty")
+	_, _, err = buildClauses([]Clause{OrderBy(nil, false)}, nil)
+	assert.ErrorContains(err, "the path of ORDER BY clause is empty")
+	_, _, err = buildClauses([]Clause{Limit(1), Limit(2)}, nil)
+	assert.ErrorContains(err, "LIMIT clause is duplicated")
+}
@@ -65,6 +65,9 @@ func buildCreateTableSQL(schema *Schema) (string, error) {
 	if len(schema.Name) == 0 {
 		return "", fmt.Errorf("while building SQL for creating table, table name is empty")
 	}
+	if err := ValidateSchema(schema); err != nil {
+		return "", fmt.Errorf("while building SQL for creating table, %v", err)
+	}
 	cols := make([]string, 0, len(schema.Columns))
 	for _, col := range schema.Columns {
 		colDef, err := DefineColumn(col)
@@ -76,14 +79,27 @@ func buildCreateTableSQL(schema *Schema) (string, error) {
 		}
 		cols = append(cols, colDef)
 	}
+	if len(schema.PrimaryKey) > 0 {
+		cols = append(cols, fmt.Sprintf("%s (%s)", PRIMARY_KEY, quoteIdentifiers(schema.PrimaryKey)))
+	}
 	sql := fmt.Sprintf(
 		`CREATE TABLE IF NOT EXISTS %s ( %s );`,
-		schema.Name,
+		QuoteTableName(schema.Name),
 		strings.Join(cols, ","),
 	)
 	return sql, nil
 }
 
+// buildCreateSchemaSQL returns the statement that creates the postgres schema of the table, if it is qualified and
+// the schema does not exist, or an empty string if the table is not qualified.
+func buildCreateSchemaSQL(table string) string {
+	schema, _ := SplitTableName(table)
+	if len(schema) == 0 {
+		return ""
+	}
+	return fmt.Sprintf("CREATE SCHEMA IF NOT EXISTS %s", QuoteIdentifier(schema))
+}
+
 func (c *Client) CreateTable(schema *Schema) error {
 	sql, err := buildCreateTableSQL(schema)
 	if err != nil {
@@ -93,6 +109,12 @@ func (c *Client) CreateTable(schema *Schema) error {
 			err,
 		)
 	}
+	if schemaSQL := buildCreateSchemaSQL(schema.Name); len(schemaSQL) > 0 {
+		_, err = c.pool.Exec(context.Background(), schemaSQL)
+		if err != nil {
+			return fmt.Errorf("while creating table '%s', failed to create schema, error: %v", schema.Name, err)
+		}
+	}
 	_, err = c.pool.Exec(context.Background(), sql)
 	if err != nil {
 		return fmt.Errorf(
@@ -124,7 +146,10 @@ func (c *Client) JSON() *Json {
 }
 
 func (c *Client) Clean(table string) error {
-	_, err := c.pool.Exec(context.Background(), fmt.Sprintf("TRUNCATE TABLE %s", table))
+	if err := ValidateIdentifier(table); err != nil {
+		return fmt.Errorf("while cleaning table, %v", err)
+	}
+	_, err := c.pool.Exec(context.Background(), fmt.Sprintf("TRUNCATE TABLE %s", QuoteIdentifier(table)))
 	return err
 }
 
@@ -166,13 +191,22 @@ func (c *Client) Close() {
 	c.pool.Close()
 }
 
-// Returns a string in the form of '$1, $2, ... ${count}'.
-func placeHolder(count int) string {
-	res := make([]string, 0, count)
-	for i := 1; i <= count; i = i + 1 {
-		res = append(res, "$"+strconv.Itoa(i))
+// Returns a string in the form of '$1, $2, ... ${count}', one placeholder per column of the schema.
+// The placeholder of a column with a default value falls back to the default, if the value is NULL.
+func placeHolder(schema *Schema) (string, error) {
+	res := make([]string, 0, len(schema.Columns))
+	for i, col := range schema.Columns {
+		p := "$" + strconv.Itoa(i+1)
+		if len(col.Default) > 0 {
+			typeName, err := TypeName(col)
+			if err != nil {
+				return "", fmt.Errorf("column '%s' %v", col.Name, err)
+			}
+			p = fmt.Sprintf("COALESCE(%s, %s::%s)", p, QuoteLiteral(col.Default), typeName)
+		}
+		res = append(res, p)
 	}
-	return strings.Join(res, ", ")
+	return strings.Join(res, ", "), nil
 }
 
 func colNames(schema *Schema) string {
@@ -180,7 +214,7 @@ func colNames(schema *Schema) string {
 	for _, col := range schema.Columns {
 		colNames = append(colNames, col.Name)
 	}
-	return strings.Join(colNames, ", ")
+	return quoteIdentifiers(colNames)
 }
 
 // WriteRecord writes a slice of values in string format, according to the table schema.
@@ -193,20 +227,86 @@ func (c *Client) WriteRecord(record []interface{}, schema *Schema) error {
 			len(schema.Columns),
 		)
 	}
+	if err := ValidateSchema(schema); err != nil {
+		return fmt.Errorf("while writing record, %v", err)
+	}
+	sql, err := buildInsertSQL(schema)
+	if err != nil {
+		return fmt.Errorf("while writing record, %v", err)
+	}
+	_, err = c.pool.Exec(context.Background(), sql, record...)
+	return err
+}
+
+// WriteRecords writes the records in one round trip and one transaction, either all or none of them are written.
+func (c *Client) WriteRecords(records [][]interface{}, schema *Schema) error {
+	return c.WriteRecordsContext(context.Background(), records, schema)
+}
+
+// WriteRecordsContext is WriteRecords, which gives up and writes none of the records once ctx is done.
+func (c *Client) WriteRecordsContext(ctx context.Context, records [][]interface{}, schema *Schema) error {
+	if len(records) == 0 {
+		return nil
+	}
+	for _, record := range records {
+		if len(record) != len(schema.Columns) {
+			return fmt.Errorf("while writing records, the record's field count differs from the schema's column "+
+				"count, %d vs %d", len(record), len(schema.Columns))
+		}
+	}
+	if err := ValidateSchema(schema); err != nil {
+		return fmt.Errorf("while writing records, %v", err)
+	}
+	sql, err := buildInsertSQL(schema)
+	if err != nil {
+		return fmt.Errorf("while writing records, %v", err)
+	}
+	batch := &pgx.Batch{}
+	for _, record := range records {
+		batch.Queue(sql, record...)
+	}
+
+	tx, err := c.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("while writing records to table '%s', failed to begin transaction, error: %v",
+			schema.Name, err)
+	}
+	defer func() {
+		_ = tx.Rollback(ctx)
+	}()
+	if err := tx.SendBatch(ctx, batch).Close(); err != nil {
+		return fmt.Errorf("while writing records to table '%s', failed to execute batch, error: %v", schema.Name, err)
+	}
+	return tx.Commit(ctx)
+}
+
+// buildInsertSQL returns the SQL statement that inserts a record of the schema, with the values as parameters.
+func buildInsertSQL(schema *Schema) (string, error) {
+	values, err := placeHolder(schema)
+	if err != nil {
+		return "", err
+	}
 	const writeRecordSQLTmpl = `INSERT INTO %s (%s) VALUES (%s)`
-	sql := fmt.Sprintf(
+	return fmt.Sprintf(
 		writeRecordSQLTmpl,
-		schema.Name,
+		QuoteTableName(schema.Name),
 		colNames(schema),
-		placeHolder(len(schema.Columns)),
-	)
-	_, err := c.pool.Exec(context.Background(), sql, record...)
-	return err
+		values,
+	), nil
+}
+
+// Ping returns an error if the database cannot be reached.
+func (c *Client) Ping() error {
+	if c.pool == nil {
+		return fmt.Errorf("while pinging database, the client is not connected")
+	}
+	return c.pool.Ping(context.Background())
 }
 
 // Query returns the value of the sql query statement, or error if failed.
-func (c *Client) Query(sql string) ([][]interface{}, error) {
-	rows, err := c.pool.Query(context.Background(), sql)
+// The values are passed as args and referred as $1, $2 ... in the statement, instead of being formatted into it.
+func (c *Client) Query(sql string, args ...interface{}) ([][]interface{}, error) {
+	rows, err := c.pool.Query(context.Background(), sql, args...)
 	if err != nil {
 		return nil, fmt.Errorf(
 			"while querying '%s', failed to execute the statement, error: %v",
@@ -241,21 +341,28 @@ type Json struct {
 	pool *pgxpool.Pool
 }
 
-// Get returns an object with the given clause
+// Get returns an object selected by the clauses, see Where() for example.
 // object MUST be a pointer.
-func (j *Json) Get(table, object interface{}, clause ...string) error {
-	row := j.pool.QueryRow(context.Background(), fmt.Sprintf("SELECT data FROM %s %s", table, strings.Join(clause, " ")))
+func (j *Json) Get(table string, object interface{}, clauses ...Clause) error {
+	sql, args, err := buildSelectSQL(table, clauses)
+	if err != nil {
+		return fmt.Errorf("while getting object on table '%s', %v", table, err)
+	}
+	row := j.pool.QueryRow(context.Background(), sql, args...)
 	if err := row.Scan(object); err != nil {
 		return err
 	}
 	return nil
 }
 
-// List returns objects into result
+// List returns objects selected by the clauses into result
 // result MUST be []*T pointer, e.g. &([]*T).
-func (j *Json) List(table string, result interface{}, clause ...string) error {
-	sql := fmt.Sprintf("SELECT data FROM %s %s", table, strings.Join(clause, " "))
-	rows, err := j.pool.Query(context.Background(), sql)
+func (j *Json) List(table string, result interface{}, clauses ...Clause) error {
+	sql, args, err := buildSelectSQL(table, clauses)
+	if err != nil {
+		return fmt.Errorf("while listing objects on table '%s', %v", table, err)
+	}
+	rows, err := j.pool.Query(context.Background(), sql, args...)
 	if err != nil {
 		return fmt.Errorf("while listing objects on table '%s', failed to query with sql '%s', error: %v", table, sql, err)
 	}
@@ -273,32 +380,175 @@ func (j *Json) List(table string, result interface{}, clause ...string) error {
 	return nil
 }
 
-// Upsert = insert if not exist, otherwise update it, idPath is optional, [->'metadata'->>'uid'] by default.
-func (j *Json) Upsert(table, uid string, data []byte, idPath ...string) error {
-	var (
-		result = ""
-		ctx    = context.Background()
-	)
+// buildSelectSQL returns the statement that selects the objects of the table with the clauses, and its arguments.
+func buildSelectSQL(table string, clauses []Clause) (string, []interface{}, error) {
+	if err := ValidateIdentifier(table); err != nil {
+		return "", nil, err
+	}
+	clauseSQL, args, err := buildClauses(clauses, nil)
+	if err != nil {
+		return "", nil, err
+	}
+	sql := "SELECT data FROM " + QuoteIdentifier(table)
+	if len(clauseSQL) > 0 {
+		sql += " " + clauseSQL
+	}
+	return sql, args, nil
+}
 
-	row := j.pool.QueryRow(ctx, fmt.Sprintf("SELECT data FROM %s WHERE %s=$1", table, pgPath(idPath)), uid)
-	if err := row.Scan(&result); err == pgx.ErrNoRows {
-		_, err := j.pool.Exec(ctx, fmt.Sprintf("INSERT INTO %s (data) VALUES ($1)", table), data)
-		return err
+// buildUpsertSQL returns the statement that inserts an object into the table of GetJSONBObjectTableSchema(),
+// or replaces the object with the same ID.
+func buildUpsertSQL(table string) (string, error) {
+	if err := ValidateIdentifier(table); err != nil {
+		return "", err
 	}
+	return fmt.Sprintf("INSERT INTO %s (%s) VALUES ($1) ON CONFLICT (%s) DO UPDATE SET %s = EXCLUDED.%s",
+		QuoteIdentifier(table), QuoteIdentifier(JSONDataColumn), QuoteIdentifier(JSONIDColumn),
+		QuoteIdentifier(JSONDataColumn), QuoteIdentifier(JSONDataColumn)), nil
+}
 
-	_, err := j.pool.Exec(ctx, fmt.Sprintf("UPDATE %s SET data=$1 WHERE %s=$2", table, pgPath(idPath)), data, uid)
+// Upsert = insert if not exist, otherwise update it, in a single statement.
+// The table must be created by CreateJSONBObjectTable(), which identifies the objects by the ID at its idPath.
+func (j *Json) Upsert(table string, data []byte) error {
+	sql, err := buildUpsertSQL(table)
+	if err != nil {
+		return fmt.Errorf("while upserting object on table, %v", err)
+	}
+	_, err = j.pool.Exec(context.Background(), sql, data)
 	return err
 }
 
-func (j *Json) Delete(table, uid string, idPath ...string) error {
-	_, err := j.pool.Exec(context.Background(), fmt.Sprintf("DELETE FROM %s WHERE %s=$1", table, pgPath(idPath)), uid)
+// UpsertBatch upserts the objects in one round trip and one transaction, either all or none of them are written.
+func (j *Json) UpsertBatch(table string, objects [][]byte) error {
+	if len(objects) == 0 {
+		return nil
+	}
+	sql, err := buildUpsertSQL(table)
+	if err != nil {
+		return fmt.Errorf("while upserting objects on table, %v", err)
+	}
+	batch := &pgx.Batch{}
+	for _, data := range objects {
+		batch.Queue(sql, data)
+	}
+
+	ctx := context.Background()
+	tx, err := j.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("while upserting objects on table '%s', failed to begin transaction, error: %v", table, err)
+	}
+	defer func() {
+		_ = tx.Rollback(ctx)
+	}()
+	if err := tx.SendBatch(ctx, batch).Close(); err != nil {
+		return fmt.Errorf("while upserting objects on table '%s', failed to execute batch, error: %v", table, err)
+	}
+	return tx.Commit(ctx)
+}
+
+// Delete deletes the object with the ID from the table created by CreateJSONBObjectTable().
+func (j *Json) Delete(table, uid string) error {
+	return j.DeleteBatch(table, []string{uid})
+}
+
+// DeleteBatch deletes the objects with the IDs in a single statement.
+func (j *Json) DeleteBatch(table string, uids []string) error {
+	if len(uids) == 0 {
+		return nil
+	}
+	if err := ValidateIdentifier(table); err != nil {
+		return fmt.Errorf("while deleting objects on table, %v", err)
+	}
+	_, err := j.pool.Exec(context.Background(),
+		fmt.Sprintf("DELETE FROM %s WHERE %s = ANY($1)", QuoteIdentifier(table), QuoteIdentifier(JSONIDColumn)), uids)
 	return err
 }
 
+// ListIDs returns the IDs of all objects of the table created by CreateJSONBObjectTable().
+func (j *Json) ListIDs(table string) ([]string, error) {
+	if err := ValidateIdentifier(table); err != nil {
+		return nil, fmt.Errorf("while listing IDs on table, %v", err)
+	}
+	rows, err := j.pool.Query(context.Background(),
+		fmt.Sprintf("SELECT %s FROM %s", QuoteIdentifier(JSONIDColumn), QuoteIdentifier(table)))
+	if err != nil {
+		return nil, fmt.Errorf("while listing IDs on table '%s', failed to query, error: %v", table, err)
+	}
+	defer rows.Close()
+	ids := make([]string, 0)
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("while listing IDs on table '%s', failed to scan row, error: %v", table, err)
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// CreateJSONBObjectTable creates the table of GetJSONBObjectTableSchema(), or migrates an existing table of
+// GetJSONBTableSchema() to it. Before the unique index is created, the objects without ID are removed, and so are all
+// but the row with the highest ctid of the duplicated objects, which were written by racing upserts. The legacy table
+// has no column that orders the writes; the highest ctid is the last row appended, unless the rows were updated or
+// the table was vacuumed, and the kept row is overwritten anyway when the object is written again, like on the next
+// resync of the resource watcher.
+// The table is locked against writes while it's migrated, so that no duplicates are written before the unique index
+// is created.
+func (c *Client) CreateJSONBObjectTable(table string, idPath ...string) error {
+	schema := GetJSONBObjectTableSchema(table, idPath...)
+	existing, err := c.GetTableColumns(table)
+	if err != nil {
+		return fmt.Errorf("while creating object table '%s', error: %v", table, err)
+	}
+	hasID := false
+	for _, col := range existing {
+		hasID = hasID || col.Name == JSONIDColumn
+	}
+	var prepare func(ctx context.Context, tx pgx.Tx) error
+	if len(existing) > 0 && !hasID {
+		prepare = func(ctx context.Context, tx pgx.Tx) error {
+			return dedupJSONBObjects(ctx, tx, table, pgPath(idPath))
+		}
+	}
+	if _, err := c.migrateTable(schema, MigrateOptions{}, prepare); err != nil {
+		return fmt.Errorf("while creating object table '%s', %v", table, err)
+	}
+	return nil
+}
+
+// dedupJSONBObjects locks the table against writes until the end of the transaction, and removes the objects without
+// ID, and all but the row with the highest ctid of the objects with the same ID.
+func dedupJSONBObjects(ctx context.Context, tx pgx.Tx, table string, idPath []string) error {
+	if err := ValidateIdentifier(table); err != nil {
+		return err
+	}
+	quotedTable := QuoteIdentifier(table)
+	// SHARE ROW EXCLUSIVE conflicts with the ROW EXCLUSIVE lock of INSERT, UPDATE and DELETE, but not with reads.
+	lockSQL := fmt.Sprintf("LOCK TABLE %s IN SHARE ROW EXCLUSIVE MODE", quotedTable)
+	if _, err := tx.Exec(ctx, lockSQL); err != nil {
+		return fmt.Errorf("failed to lock table, error: %v", err)
+	}
+	data := QuoteIdentifier(JSONDataColumn)
+	stmts := []string{
+		fmt.Sprintf("DELETE FROM %s WHERE %s #>> $1 IS NULL", quotedTable, data),
+		fmt.Sprintf("DELETE FROM %s a USING %s b WHERE a.%s #>> $1 = b.%s #>> $1 AND a.ctid < b.ctid",
+			quotedTable, quotedTable, data, data),
+	}
+	for _, stmt := range stmts {
+		if _, err := tx.Exec(ctx, stmt, idPath); err != nil {
+			return fmt.Errorf("failed to remove duplicated objects, error: %v", err)
+		}
+	}
+	return nil
+}
+
 func (c *Client) CheckTableExist(tableName string) error {
+	if err := ValidateTableName(tableName); err != nil {
+		return fmt.Errorf("while check table '%s' exist, %v", tableName, err)
+	}
 	sql := fmt.Sprintf(
 		`select count(*) as c from %s ;`,
-		tableName,
+		QuoteTableName(tableName),
 	)
 	_, err := c.pool.Exec(context.Background(), sql)
 	if err != nil {
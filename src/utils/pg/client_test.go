@@ -145,14 +145,14 @@ func TestPGUpsert(t *testing.T) {
 		pgClient.Close()
 	}()
 
-	err = pgClient.CreateTable(GetJSONBTableSchema(tableName))
+	err = pgClient.CreateJSONBObjectTable(tableName)
 	assert.Nil(err)
 
 	obj := Object{}
 	obj.Name = "@#$%^&*()_+|"
 	obj.UID = types.UID("uid1")
 	value, _ := json.Marshal(obj)
-	err = pgClient.JSON().Upsert(tableName, string(obj.UID), value)
+	err = pgClient.JSON().Upsert(tableName, value)
 	assert.Nil(err)
 
 	result1 := []*Object{}
@@ -176,14 +176,14 @@ func TestPGDelete(t *testing.T) {
 		assert.Nil(pgRunner.Stop())
 	}()
 
-	err = pgClient.CreateTable(GetJSONBTableSchema(tableName))
+	err = pgClient.CreateJSONBObjectTable(tableName)
 	assert.Nil(err)
 
 	obj := Object{}
 	obj.Name = "obj1"
 	obj.UID = types.UID("uid1")
 	value, _ := json.Marshal(obj)
-	err = pgClient.JSON().Upsert(tableName, string(obj.UID), value)
+	err = pgClient.JSON().Upsert(tableName, value)
 	assert.Nil(err)
 
 	result1 := []*Object{}
@@ -214,14 +214,14 @@ func TestPGClean(t *testing.T) {
 		assert.Nil(pgRunner.Stop())
 	}()
 
-	err = pgClient.CreateTable(GetJSONBTableSchema(tableName))
+	err = pgClient.CreateJSONBObjectTable(tableName)
 	assert.Nil(err)
 
 	obj := Object{}
 	obj.Name = "obj1"
 	obj.UID = types.UID("uid1")
 	value, _ := json.Marshal(obj)
-	err = pgClient.JSON().Upsert(tableName, string(obj.UID), value)
+	err = pgClient.JSON().Upsert(tableName, value)
 	assert.Nil(err)
 
 	if err = pgClient.Clean(tableName); err != nil {
@@ -248,18 +248,18 @@ func TestJSONGet(t *testing.T) {
 	}()
 
 	tableName := "test1"
-	err = pgClient.CreateTable(GetJSONBTableSchema(tableName))
+	err = pgClient.CreateJSONBObjectTable(tableName)
 	assert.Nil(err)
 	// Insert the first object
 	obj1 := Object{}
 	obj1.Name = "obj1"
 	obj1.UID = types.UID("uid1")
 	value1, _ := json.Marshal(obj1)
-	err = pgClient.JSON().Upsert(tableName, string(obj1.UID), value1)
+	err = pgClient.JSON().Upsert(tableName, value1)
 	assert.Nil(err)
 
 	target := Object{}
-	err = pgClient.JSON().Get(tableName, &target, fmt.Sprintf("WHERE data#>>'{metadata,uid}'='%s'", string(obj1.UID)))
+	err = pgClient.JSON().Get(tableName, &target, Where([]string{"metadata", "uid"}, string(obj1.UID)))
 	assert.Nil(err)
 	assert.Equal(obj1.Name, target.Name)
 }
@@ -275,14 +275,14 @@ func TestPGListObjects(t *testing.T) {
 	}()
 
 	tableName := "test1"
-	err = pgClient.CreateTable(GetJSONBTableSchema(tableName))
+	err = pgClient.CreateJSONBObjectTable(tableName)
 	assert.Nil(err)
 	// insert the first object
 	obj1 := Object{}
 	obj1.Name = "obj1"
 	obj1.UID = types.UID("uid1")
 	value1, _ := json.Marshal(obj1)
-	err = pgClient.JSON().Upsert(tableName, string(obj1.UID), value1)
+	err = pgClient.JSON().Upsert(tableName, value1)
 	assert.Nil(err)
 
 	result1 := []*Object{}
@@ -296,7 +296,7 @@ func TestPGListObjects(t *testing.T) {
 	obj2.Name = "obj2"
 	obj2.UID = types.UID("uid2")
 	value2, _ := json.Marshal(obj2)
-	err = pgClient.JSON().Upsert(tableName, string(obj2.UID), value2)
+	err = pgClient.JSON().Upsert(tableName, value2)
 	assert.Nil(err)
 
 	result2 := []*Object{}
@@ -305,6 +305,92 @@ func TestPGListObjects(t *testing.T) {
 	assert.Equal(2, len(result2))
 }
 
+// Tests that Upsert() replaces the object with the same ID, and the batched writes.
+func TestPGUpsertBatch(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	pgRunner, pgClient, err := createPGTestFixutre()
+	require.Nil(err)
+
+	defer func() {
+		pgClient.Close()
+		assert.Nil(pgRunner.Stop())
+	}()
+
+	require.Nil(pgClient.CreateJSONBObjectTable(tableName))
+
+	objects := make([][]byte, 0)
+	for _, uid := range []string{"uid1", "uid2", "uid3"} {
+		obj := Object{}
+		obj.Name = "obj-" + uid
+		obj.UID = types.UID(uid)
+		value, _ := json.Marshal(obj)
+		objects = append(objects, value)
+	}
+	require.Nil(pgClient.JSON().UpsertBatch(tableName, objects))
+
+	obj := Object{}
+	obj.Name = "updated"
+	obj.UID = types.UID("uid1")
+	value, _ := json.Marshal(obj)
+	require.Nil(pgClient.JSON().Upsert(tableName, value))
+
+	ids, err := pgClient.JSON().ListIDs(tableName)
+	require.Nil(err)
+	assert.ElementsMatch([]string{"uid1", "uid2", "uid3"}, ids)
+
+	target := Object{}
+	require.Nil(pgClient.JSON().Get(tableName, &target, Where([]string{"metadata", "uid"}, "uid1")))
+	assert.Equal("updated", target.Name)
+
+	// Objects without ID are rejected, and fail the whole batch.
+	assert.NotNil(pgClient.JSON().UpsertBatch(tableName, [][]byte{value, []byte(`{"metadata":{}}`)}))
+
+	require.Nil(pgClient.JSON().DeleteBatch(tableName, []string{"uid1", "uid3", "uid4"}))
+	ids, err = pgClient.JSON().ListIDs(tableName)
+	require.Nil(err)
+	assert.Equal([]string{"uid2"}, ids)
+}
+
+// Tests that CreateJSONBObjectTable() migrates an existing table with duplicated objects.
+func TestCreateJSONBObjectTableDedup(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	pgRunner, pgClient, err := createPGTestFixutre()
+	require.Nil(err)
+
+	defer func() {
+		pgClient.Close()
+		assert.Nil(pgRunner.Stop())
+	}()
+
+	schema := GetJSONBTableSchema(tableName)
+	require.Nil(pgClient.CreateTable(schema))
+	for _, record := range []string{
+		`{"metadata":{"uid":"uid1","name":"old"}}`,
+		`{"metadata":{"uid":"uid1","name":"new"}}`,
+		`{"metadata":{"uid":"uid2","name":"obj2"}}`,
+		`{"metadata":{}}`,
+	} {
+		require.Nil(pgClient.WriteRecord([]interface{}{record}, schema))
+	}
+
+	require.Nil(pgClient.CreateJSONBObjectTable(tableName))
+	ids, err := pgClient.JSON().ListIDs(tableName)
+	require.Nil(err)
+	assert.ElementsMatch([]string{"uid1", "uid2"}, ids)
+
+	target := Object{}
+	require.Nil(pgClient.JSON().Get(tableName, &target, Where([]string{"metadata", "uid"}, "uid1")))
+	// The row with the highest ctid is kept, which is the last one appended.
+	assert.Equal("new", target.Name)
+
+	// Creating again is a no-op.
+	require.Nil(pgClient.CreateJSONBObjectTable(tableName))
+}
+
 // Tests that WriteRecord can write a text record into the data base.
 func TestWriteRecord(t *testing.T) {
 	assert := assert.New(t)
@@ -333,6 +419,39 @@ func TestWriteRecord(t *testing.T) {
 	assert.Equal([][]interface{}{{"1234"}}, records)
 }
 
+// Tests that WriteRecords writes all records in one transaction, or none of them if one fails.
+func TestWriteRecords(t *testing.T) {
+	assert := assert.New(t)
+
+	pgRunner, pgClient, err := createPGTestFixutre()
+	assert.Nil(err)
+
+	defer func() {
+		assert.Nil(pgRunner.Stop())
+		pgClient.Close()
+	}()
+
+	schema := &Schema{
+		Name: "test_table",
+		Columns: []Column{
+			{
+				Name:    "id",
+				Type:    INTEGER,
+				NotNull: true,
+			},
+		},
+	}
+	assert.Nil(pgClient.CreateTable(schema))
+	assert.Nil(pgClient.WriteRecords([][]interface{}{{1}, {2}}, schema))
+	assert.NotNil(pgClient.WriteRecords([][]interface{}{{3}, {nil}}, schema))
+	records, err := pgClient.Query("select * from test_table order by id")
+	assert.Nil(err)
+	assert.Equal([][]interface{}{{int32(1)}, {int32(2)}}, records)
+
+	assert.ErrorContains(pgClient.WriteRecords([][]interface{}{{1, 2}}, schema),
+		"field count differs from the schema's column count")
+}
+
 // Tests that WriteRecord return error when input value count and schema column count are not equal.
 func TestWriteRecordFailUnequalCount(t *testing.T) {
 	assert := assert.New(t)
@@ -349,3 +468,27 @@ func TestWriteRecordFailUnequalCount(t *testing.T) {
 	err := pgClient.WriteRecord([]interface{}{}, schema)
 	assert.ErrorContains(err, "field count differs from the schema's column count")
 }
+
+// Tests that buildCreateTableSQL defines the primary key.
+func TestBuildCreateTableSQLPrimaryKey(t *testing.T) {
+	assert := assert.New(t)
+
+	sql, err := buildCreateTableSQL(&Schema{
+		Name:       "t",
+		Columns:    []Column{{Name: "a", Type: TEXT}, {Name: "b", Type: INT}},
+		PrimaryKey: []string{"a", "b"},
+	})
+	assert.Nil(err)
+	assert.Equal(`CREATE TABLE IF NOT EXISTS "t" ( "a" TEXT,"b" INT,PRIMARY KEY ("a", "b") );`, sql)
+}
+
+// Tests that placeHolder falls back to the column defaults.
+func TestPlaceHolder(t *testing.T) {
+	assert := assert.New(t)
+
+	values, err := placeHolder(&Schema{
+		Columns: []Column{{Name: "a", Type: TEXT}, {Name: "b", Type: DOUBLE, Default: "0.5"}},
+	})
+	assert.Nil(err)
+	assert.Equal("$1, COALESCE($2, '0.5'::DOUBLE PRECISION)", values)
+}
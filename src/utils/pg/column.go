@@ -52,13 +52,52 @@ const (
 	JSON    = commonpb.DataField_JSON
 	JSONB   = commonpb.DataField_JSONB
 	TEXT    = commonpb.DataField_TEXT
+
+	TIMESTAMPTZ = commonpb.DataField_TIMESTAMPTZ
+	BIGINT      = commonpb.DataField_BIGINT
+	DOUBLE      = commonpb.DataField_DOUBLE
+	BYTEA       = commonpb.DataField_BYTEA
+	INET        = commonpb.DataField_INET
 )
 
+// The postgres type names of the data types whose enum names are not postgres type names.
+var typeNames = map[commonpb.DataField_Type]string{
+	DOUBLE: "DOUBLE PRECISION",
+}
+
 // Column describes a column of a data table in a database.
 type Column struct {
 	Name       string
 	Type       commonpb.DataField_Type
 	Constraint string
+
+	// If true, the column is an array of Type.
+	Array bool
+
+	// If true, the column is defined NOT NULL.
+	NotNull bool
+
+	// If not empty, the column's default value, a constant in the input syntax of Type.
+	Default string
+
+	// If not empty, the column is a stored generated column computed by this SQL expression of the other columns.
+	// It is never set from user input, see GetJSONBObjectTableSchema() for example.
+	Generated string
+}
+
+// TypeName returns the postgres type name of the column, like 'INTEGER' and 'TEXT[]'.
+func TypeName(c Column) (string, error) {
+	typeName, ok := typeNames[c.Type]
+	if !ok {
+		typeName, ok = commonpb.DataField_Type_name[int32(c.Type)]
+	}
+	if !ok {
+		return "", fmt.Errorf("data type '%s' is not supported", c.Type)
+	}
+	if c.Array {
+		typeName += "[]"
+	}
+	return typeName, nil
 }
 
 // Returns a string that defines this column in a SQL expression.
@@ -66,12 +105,33 @@ func DefineColumn(c Column) (string, error) {
 	if _, ok := DataTypeConstraints[c.Constraint]; len(c.Constraint) != 0 && !ok {
 		return "", fmt.Errorf("while defining column '%s', constraint '%s' is not supported", c.Name, c.Constraint)
 	}
-	typeName, ok := commonpb.DataField_Type_name[int32(c.Type)]
-	if !ok {
-		return "", fmt.Errorf("while defining column '%s', data type '%s' is not supported", c.Name, c.Type)
+	typeName, err := TypeName(c)
+	if err != nil {
+		return "", fmt.Errorf("while defining column '%s', %v", c.Name, err)
+	}
+	if err := ValidateIdentifier(c.Name); err != nil {
+		return "", fmt.Errorf("while defining column, %v", err)
 	}
-	if len(c.Constraint) == 0 {
-		return strings.Join([]string{c.Name, typeName}, " "), nil
+	parts := []string{QuoteIdentifier(c.Name), typeName}
+	if len(c.Generated) != 0 {
+		if len(c.Default) != 0 {
+			return "", fmt.Errorf("while defining column '%s', a generated column cannot have a default", c.Name)
+		}
+		parts = append(parts, fmt.Sprintf("GENERATED ALWAYS AS (%s) STORED", c.Generated))
 	}
-	return strings.Join([]string{c.Name, typeName, c.Constraint}, " "), nil
+	if c.NotNull {
+		parts = append(parts, NOT_NULL)
+	}
+	if len(c.Default) != 0 {
+		parts = append(parts, DEFAULT, QuoteLiteral(c.Default))
+	}
+	if len(c.Constraint) != 0 {
+		parts = append(parts, c.Constraint)
+	}
+	return strings.Join(parts, " "), nil
+}
+
+// QuoteLiteral returns the string as a SQL string literal, which postgres converts to the type of its context.
+func QuoteLiteral(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
 }
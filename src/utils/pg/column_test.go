@@ -34,7 +34,7 @@ func TestDefineColumn(t *testing.T) {
 				Name: "test",
 				Type: INTEGER,
 			},
-			"test INTEGER",
+			`"test" INTEGER`,
 		},
 		{
 			Column{
@@ -42,7 +42,39 @@ func TestDefineColumn(t *testing.T) {
 				Type:       INTEGER,
 				Constraint: PRIMARY_KEY,
 			},
-			"test INTEGER PRIMARY KEY",
+			`"test" INTEGER PRIMARY KEY`,
+		},
+		{
+			Column{
+				Name: "test",
+				Type: DOUBLE,
+			},
+			`"test" DOUBLE PRECISION`,
+		},
+		{
+			Column{
+				Name:  "test",
+				Type:  INET,
+				Array: true,
+			},
+			`"test" INET[]`,
+		},
+		{
+			Column{
+				Name:    "test",
+				Type:    TEXT,
+				NotNull: true,
+				Default: "it's",
+			},
+			`"test" TEXT NOT NULL DEFAULT 'it''s'`,
+		},
+		{
+			Column{
+				Name:      "test",
+				Type:      TEXT,
+				Generated: `"data" #>> ARRAY['id']`,
+			},
+			`"test" TEXT GENERATED ALWAYS AS ("data" #>> ARRAY['id']) STORED`,
 		},
 	}
 
@@ -76,6 +108,15 @@ func TestDefineColumnErrors(t *testing.T) {
 			},
 			result: "constraint 'test' is not supported",
 		},
+		{
+			c: Column{
+				Name:      "test",
+				Type:      TEXT,
+				Default:   "abc",
+				Generated: `"data" #>> ARRAY['id']`,
+			},
+			result: "a generated column cannot have a default",
+		},
 	}
 
 	for _, c := range cases {
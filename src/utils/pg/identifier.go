@@ -0,0 +1,141 @@
+// Copyright (C) 2023  Tricorder Observability
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package pg
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Postgres truncates the identifiers longer than this many bytes.
+const maxIdentifierLen = 63
+
+// The identifiers of tables, columns and indexes are limited to the letters, digits and underscores, and cannot start
+// with a digit, so that they mean the same quoted or not.
+var identifierRegexp = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// ValidateIdentifier returns an error if the name cannot be used as the name of a table, column or index.
+func ValidateIdentifier(name string) error {
+	if len(name) == 0 {
+		return fmt.Errorf("identifier is empty")
+	}
+	if len(name) > maxIdentifierLen {
+		return fmt.Errorf("identifier '%s' is longer than %d bytes", name, maxIdentifierLen)
+	}
+	if !identifierRegexp.MatchString(name) {
+		return fmt.Errorf("identifier '%s' has characters other than letters, digits and underscores, "+
+			"or starts with a digit", name)
+	}
+	return nil
+}
+
+// NormalizeIdentifier returns the name of the table, column or index as postgres stores it. Postgres folds the unquoted
+// identifiers to lower case, so the names that differ only in case refer to the same table, column or index, and are
+// compared after being normalized.
+func NormalizeIdentifier(name string) string {
+	return strings.ToLower(name)
+}
+
+// QuoteIdentifier returns the name quoted as a SQL identifier. The name is normalized first, see
+// NormalizeIdentifier(), so that it refers to the same table or column as before it was quoted.
+// Quoting makes names like 'user' and 'order', which are SQL keywords, usable as well.
+func QuoteIdentifier(name string) string {
+	return pgx.Identifier{NormalizeIdentifier(name)}.Sanitize()
+}
+
+// QualifyTableName returns the name of the table in the postgres schema, like 'schema.table', or the table itself if
+// the schema is empty, which refers to the table in the current schema, 'public' by default.
+func QualifyTableName(schema, table string) string {
+	if len(schema) == 0 {
+		return table
+	}
+	return schema + "." + table
+}
+
+// SplitTableName returns the postgres schema and the table of the name, the schema is empty if the name is not
+// qualified, see QualifyTableName().
+func SplitTableName(name string) (string, string) {
+	i := strings.Index(name, ".")
+	if i < 0 {
+		return "", name
+	}
+	return name[:i], name[i+1:]
+}
+
+// ValidateTableName returns an error if the name, optionally qualified by a postgres schema, cannot be used as the
+// name of a table, see ValidateIdentifier().
+func ValidateTableName(name string) error {
+	schema, table := SplitTableName(name)
+	if strings.Contains(name, ".") {
+		if err := ValidateIdentifier(schema); err != nil {
+			return fmt.Errorf("invalid schema of table, %v", err)
+		}
+	}
+	return ValidateIdentifier(table)
+}
+
+// QuoteTableName returns the name of the table, optionally qualified by a postgres schema, quoted as a SQL
+// identifier, like "schema"."table", see QuoteIdentifier().
+func QuoteTableName(name string) string {
+	schema, table := SplitTableName(name)
+	if len(schema) == 0 {
+		return QuoteIdentifier(table)
+	}
+	return QuoteIdentifier(schema) + "." + QuoteIdentifier(table)
+}
+
+// quoteIdentifiers returns the names quoted by QuoteIdentifier() and separated by commas.
+func quoteIdentifiers(names []string) string {
+	quoted := make([]string, 0, len(names))
+	for _, name := range names {
+		quoted = append(quoted, QuoteIdentifier(name))
+	}
+	return strings.Join(quoted, ", ")
+}
+
+// ValidateSchema returns an error if any of the names of the table, the columns and the indexes is not valid, see
+// ValidateIdentifier(). The table can be qualified by a postgres schema, see ValidateTableName().
+func ValidateSchema(schema *Schema) error {
+	if err := ValidateTableName(schema.Name); err != nil {
+		return fmt.Errorf("invalid table name, %v", err)
+	}
+	for _, col := range schema.Columns {
+		if err := ValidateIdentifier(col.Name); err != nil {
+			return fmt.Errorf("invalid name of column in table '%s', %v", schema.Name, err)
+		}
+	}
+	for _, col := range schema.PrimaryKey {
+		if err := ValidateIdentifier(col); err != nil {
+			return fmt.Errorf("invalid primary key of table '%s', %v", schema.Name, err)
+		}
+	}
+	for _, index := range schema.Indexes {
+		if len(index.Name) > 0 {
+			if err := ValidateIdentifier(index.Name); err != nil {
+				return fmt.Errorf("invalid name of index in table '%s', %v", schema.Name, err)
+			}
+		}
+		for _, col := range index.Columns {
+			if err := ValidateIdentifier(col); err != nil {
+				return fmt.Errorf("invalid index column in table '%s', %v", schema.Name, err)
+			}
+		}
+	}
+	return nil
+}
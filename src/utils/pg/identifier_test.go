@@ -0,0 +1,141 @@
+// Copyright (C) 2023  Tricorder Observability
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package pg
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Hostile names that must not be accepted as identifiers.
+var hostileNames = []string{
+	"",
+	"t; DROP TABLE module; --",
+	`a"b`,
+	"a'b",
+	"a b",
+	"a-b",
+	"a.b",
+	"1abc",
+	"naïve",
+	"a\x00b",
+	strings.Repeat("a", 64),
+}
+
+// Tests that ValidateIdentifier accepts only letters, digits and underscores.
+func TestValidateIdentifier(t *testing.T) {
+	assert := assert.New(t)
+
+	for _, name := range []string{"a", "_ingest_time", "Foo1", "user", strings.Repeat("a", 63)} {
+		assert.Nil(ValidateIdentifier(name), name)
+	}
+	for _, name := range hostileNames {
+		assert.NotNil(ValidateIdentifier(name), name)
+	}
+}
+
+// Tests that QuoteIdentifier folds the name to lower case and escapes the quotes.
+func TestQuoteIdentifier(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal(`"newcol"`, QuoteIdentifier("NewCol"))
+	assert.Equal(`"user"`, QuoteIdentifier("user"))
+	assert.Equal(`"a""; drop table t; --"`, QuoteIdentifier(`a"; DROP TABLE t; --`))
+	assert.Equal(`"a", "b"`, quoteIdentifiers([]string{"a", "B"}))
+}
+
+// Tests that the table names can be qualified by postgres schemas, which are validated and quoted separately.
+func TestQualifiedTableName(t *testing.T) {
+	assert := assert.New(t)
+
+	name := QualifyTableName("tenant_a", "T")
+	assert.Equal("tenant_a.T", name)
+	assert.Equal("t", QualifyTableName("", "t"))
+	schema, table := SplitTableName(name)
+	assert.Equal("tenant_a", schema)
+	assert.Equal("T", table)
+	schema, table = SplitTableName("t")
+	assert.Empty(schema)
+	assert.Equal("t", table)
+
+	assert.Nil(ValidateTableName(name))
+	assert.Nil(ValidateTableName("t"))
+	for _, name := range []string{"a.b.c", ".t", "t.", `a"."b`, "a;.b"} {
+		assert.NotNil(ValidateTableName(name), name)
+	}
+	for _, name := range hostileNames {
+		assert.NotNil(ValidateTableName("s."+name), name)
+	}
+
+	assert.Equal(`"tenant_a"."t"`, QuoteTableName(name))
+	assert.Equal(`"t"`, QuoteTableName("t"))
+
+	sql, err := buildCreateTableSQL(&Schema{Name: name, Columns: []Column{{Name: "a", Type: TEXT}}})
+	assert.Nil(err)
+	assert.Contains(sql, `CREATE TABLE IF NOT EXISTS "tenant_a"."t"`)
+	assert.Equal(`CREATE SCHEMA IF NOT EXISTS "tenant_a"`, buildCreateSchemaSQL(name))
+	assert.Empty(buildCreateSchemaSQL("t"))
+
+	// The index is in the table's schema, its name is not qualified.
+	index := Index{Columns: []string{"a"}}
+	assert.Equal(IndexName("t", index), IndexName(name, index))
+	sql, err = buildCreateIndexSQL(name, index)
+	assert.Nil(err)
+	assert.Contains(sql, `ON "tenant_a"."t" USING btree`)
+}
+
+// Tests that QuoteLiteral escapes the quotes.
+func TestQuoteLiteral(t *testing.T) {
+	assert.Equal(t, `'x''); DROP TABLE t; --'`, QuoteLiteral(`x'); DROP TABLE t; --`))
+}
+
+// Tests that the SQL builders reject the schemas with hostile names.
+func TestHostileSchemas(t *testing.T) {
+	assert := assert.New(t)
+
+	for _, name := range hostileNames {
+		_, err := buildCreateTableSQL(&Schema{Name: "t", Columns: []Column{{Name: name, Type: TEXT}}})
+		assert.NotNil(err, name)
+
+		_, err = DefineColumn(Column{Name: name, Type: TEXT})
+		assert.NotNil(err, name)
+
+		// 'a.b' is a valid table name, the table 'b' in the postgres schema 'a'.
+		if name != "a.b" {
+			_, err = PlanMigration(&Schema{Name: name, Columns: []Column{{Name: "a", Type: TEXT}}}, nil, MigrateOptions{})
+			assert.NotNil(err, name)
+		}
+		_, err = PlanMigration(&Schema{Name: "s." + name, Columns: []Column{{Name: "a", Type: TEXT}}}, nil,
+			MigrateOptions{})
+		assert.NotNil(err, name)
+
+		_, err = PlanKeyMigration(&Schema{Name: "t", PrimaryKey: []string{name}}, &TableKeys{})
+		assert.NotNil(err, name)
+
+		_, err = PlanKeyMigration(&Schema{Name: "t", Indexes: []Index{{Columns: []string{name}}}}, &TableKeys{})
+		assert.NotNil(err, name)
+
+		_, _, err = buildSelectSQL(name, nil)
+		assert.NotNil(err, name)
+	}
+
+	// A non-empty hostile name is rejected, instead of being defaulted.
+	_, err := PlanKeyMigration(&Schema{Name: "t", Indexes: []Index{{Name: "i; --", Columns: []string{"a"}}}},
+		&TableKeys{})
+	assert.ErrorContains(err, "invalid name of index in table 't'")
+}
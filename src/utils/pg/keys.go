@@ -0,0 +1,189 @@
+// Copyright (C) 2023  Tricorder Observability
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package pg
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// The supported index methods, see https://www.postgresql.org/docs/current/indexes-types.html.
+var IndexMethods = map[string]bool{
+	"btree": true,
+	"hash":  true,
+	"gin":   true,
+	"gist":  true,
+	"brin":  true,
+}
+
+// Index describes a secondary index of a table.
+type Index struct {
+	// If empty, the index is named by IndexName().
+	Name    string
+	Columns []string
+	Unique  bool
+
+	// One of IndexMethods, empty means btree.
+	Method string
+}
+
+// TableKeys describes the primary key and the secondary indexes of an existing table.
+type TableKeys struct {
+	// The name of the primary key constraint, empty if the table has no primary key.
+	PrimaryKeyName string
+	PrimaryKey     []string
+
+	// The names of the indexes, except the primary key's.
+	Indexes []string
+}
+
+// IndexName returns the name of the index of the table. An unnamed index is named by the table and a hash of its
+// definition, which keeps the name within the 63 bytes limit of postgres identifiers for the module data tables.
+// The name is not qualified by the table's postgres schema, as an index is always in the schema of its table.
+func IndexName(table string, index Index) string {
+	if len(index.Name) > 0 {
+		return NormalizeIdentifier(index.Name)
+	}
+	_, table = SplitTableName(table)
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(fmt.Sprintf("%s %t %s", strings.Join(index.Columns, ","), index.Unique, index.Method)))
+	return fmt.Sprintf("%s_%08x", NormalizeIdentifier(table), h.Sum32())
+}
+
+// buildCreateIndexSQL returns the statement that creates the index on the table, if it does not exist.
+func buildCreateIndexSQL(table string, index Index) (string, error) {
+	if len(index.Columns) == 0 {
+		return "", fmt.Errorf("while building SQL for creating index on table '%s', columns are empty", table)
+	}
+	method := "btree"
+	if len(index.Method) > 0 {
+		method = strings.ToLower(index.Method)
+	}
+	if !IndexMethods[method] {
+		return "", fmt.Errorf("while building SQL for creating index on table '%s', method '%s' is not supported",
+			table, index.Method)
+	}
+	unique := ""
+	if index.Unique {
+		unique = "UNIQUE "
+	}
+	return fmt.Sprintf("CREATE %sINDEX IF NOT EXISTS %s ON %s USING %s (%s)",
+		unique, QuoteIdentifier(IndexName(table, index)), QuoteTableName(table), method,
+		quoteIdentifiers(index.Columns)), nil
+}
+
+// buildCreateIndexesSQL returns the statements that create the indexes of the schema.
+func buildCreateIndexesSQL(schema *Schema) ([]string, error) {
+	stmts := make([]string, 0, len(schema.Indexes))
+	for _, index := range schema.Indexes {
+		stmt, err := buildCreateIndexSQL(schema.Name, index)
+		if err != nil {
+			return nil, err
+		}
+		stmts = append(stmts, stmt)
+	}
+	return stmts, nil
+}
+
+// PlanKeyMigration returns the statements that change the table with the existing keys to have the primary key and
+// indexes of the schema. The primary key is replaced if it differs from the schema's, the missing indexes are
+// created. An existing primary key is kept if the schema declares none, and the existing indexes that are not in the
+// schema are kept, as they might be created by others, like TimescaleDB.
+func PlanKeyMigration(schema *Schema, keys *TableKeys) ([]string, error) {
+	if err := ValidateSchema(schema); err != nil {
+		return nil, fmt.Errorf("while planning migration, %v", err)
+	}
+	var stmts []string
+	table := QuoteTableName(schema.Name)
+
+	primaryKey := make([]string, 0, len(schema.PrimaryKey))
+	for _, col := range schema.PrimaryKey {
+		primaryKey = append(primaryKey, NormalizeIdentifier(col))
+	}
+	if len(primaryKey) > 0 && strings.Join(primaryKey, ",") != strings.Join(keys.PrimaryKey, ",") {
+		if len(keys.PrimaryKeyName) > 0 {
+			// The constraint is dropped with the column, if the migration drops one of the primary key columns.
+			// The name is as it is in the database, it is quoted without being folded to lower case.
+			stmts = append(stmts, fmt.Sprintf("ALTER TABLE %s DROP CONSTRAINT IF EXISTS %s",
+				table, pgx.Identifier{keys.PrimaryKeyName}.Sanitize()))
+		}
+		stmts = append(stmts, fmt.Sprintf("ALTER TABLE %s ADD %s (%s)",
+			table, PRIMARY_KEY, quoteIdentifiers(schema.PrimaryKey)))
+	}
+
+	existing := make(map[string]bool, len(keys.Indexes))
+	for _, name := range keys.Indexes {
+		existing[name] = true
+	}
+	for _, index := range schema.Indexes {
+		if existing[IndexName(schema.Name, index)] {
+			continue
+		}
+		stmt, err := buildCreateIndexSQL(schema.Name, index)
+		if err != nil {
+			return nil, fmt.Errorf("while planning migration of table '%s', error: %v", schema.Name, err)
+		}
+		stmts = append(stmts, stmt)
+	}
+	return stmts, nil
+}
+
+// GetTableKeys returns the primary key and the secondary indexes of the table.
+func (c *Client) GetTableKeys(table string) (*TableKeys, error) {
+	ctx := context.Background()
+	keys := &TableKeys{}
+
+	const primaryKeySQL = `SELECT con.conname, array_agg(att.attname ORDER BY key.ord)::text[] FROM pg_constraint con ` +
+		`CROSS JOIN LATERAL unnest(con.conkey) WITH ORDINALITY AS key(attnum, ord) ` +
+		`JOIN pg_attribute att ON att.attrelid = con.conrelid AND att.attnum = key.attnum ` +
+		`WHERE con.conrelid = to_regclass($1::text) AND con.contype = 'p' GROUP BY con.conname`
+	rows, err := c.pool.Query(ctx, primaryKeySQL, NormalizeIdentifier(table))
+	if err != nil {
+		return nil, fmt.Errorf("while getting keys of table '%s', failed to query primary key, error: %v", table, err)
+	}
+	for rows.Next() {
+		err := rows.Scan(&keys.PrimaryKeyName, &keys.PrimaryKey)
+		if err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("while getting keys of table '%s', failed to scan primary key, error: %v", table, err)
+		}
+	}
+	rows.Close()
+	if rows.Err() != nil {
+		return nil, fmt.Errorf("while getting keys of table '%s', error: %v", table, rows.Err())
+	}
+
+	const indexesSQL = `SELECT cls.relname FROM pg_index idx JOIN pg_class cls ON cls.oid = idx.indexrelid ` +
+		`WHERE idx.indrelid = to_regclass($1::text) AND NOT idx.indisprimary ORDER BY cls.relname`
+	rows, err = c.pool.Query(ctx, indexesSQL, NormalizeIdentifier(table))
+	if err != nil {
+		return nil, fmt.Errorf("while getting keys of table '%s', failed to query indexes, error: %v", table, err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var name string
+		err := rows.Scan(&name)
+		if err != nil {
+			return nil, fmt.Errorf("while getting keys of table '%s', failed to scan index, error: %v", table, err)
+		}
+		keys.Indexes = append(keys.Indexes, name)
+	}
+	return keys, rows.Err()
+}
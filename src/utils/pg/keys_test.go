@@ -0,0 +1,90 @@
+// Copyright (C) 2023  Tricorder Observability
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package pg
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Tests that IndexName returns the index's name, or a name derived from the definition.
+func TestIndexName(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal("by_node", IndexName("t", Index{Name: "By_Node", Columns: []string{"node"}}))
+
+	name := IndexName("T", Index{Columns: []string{"node"}})
+	assert.Regexp("^t_[0-9a-f]{8}$", name)
+	assert.NotEqual(name, IndexName("t", Index{Columns: []string{"node"}, Unique: true}))
+	assert.NotEqual(name, IndexName("t", Index{Columns: []string{"node", "pid"}}))
+}
+
+// Tests that buildCreateIndexSQL returns the statement that creates the index.
+func TestBuildCreateIndexSQL(t *testing.T) {
+	assert := assert.New(t)
+
+	sql, err := buildCreateIndexSQL("t", Index{Name: "i", Columns: []string{"a", "b"}, Unique: true})
+	assert.Nil(err)
+	assert.Equal(`CREATE UNIQUE INDEX IF NOT EXISTS "i" ON "t" USING btree ("a", "b")`, sql)
+
+	sql, err = buildCreateIndexSQL("t", Index{Name: "i", Columns: []string{"tags"}, Method: "GIN"})
+	assert.Nil(err)
+	assert.Equal(`CREATE INDEX IF NOT EXISTS "i" ON "t" USING gin ("tags")`, sql)
+
+	_, err = buildCreateIndexSQL("t", Index{Name: "i"})
+	assert.ErrorContains(err, "columns are empty")
+	_, err = buildCreateIndexSQL("t", Index{Name: "i", Columns: []string{"a"}, Method: "rtree"})
+	assert.ErrorContains(err, "method 'rtree' is not supported")
+}
+
+// Tests that PlanKeyMigration replaces the primary key and creates the missing indexes.
+func TestPlanKeyMigration(t *testing.T) {
+	assert := assert.New(t)
+
+	schema := &Schema{
+		Name:       "t",
+		PrimaryKey: []string{"ID", "node"},
+		Indexes: []Index{
+			{Name: "by_node", Columns: []string{"node"}},
+			{Name: "by_pid", Columns: []string{"pid"}},
+		},
+	}
+	stmts, err := PlanKeyMigration(schema, &TableKeys{
+		PrimaryKeyName: "t_pkey",
+		PrimaryKey:     []string{"id"},
+		Indexes:        []string{"by_node", "t_time_idx"},
+	})
+	assert.Nil(err)
+	assert.Equal([]string{
+		`ALTER TABLE "t" DROP CONSTRAINT IF EXISTS "t_pkey"`,
+		`ALTER TABLE "t" ADD PRIMARY KEY ("id", "node")`,
+		`CREATE INDEX IF NOT EXISTS "by_pid" ON "t" USING btree ("pid")`,
+	}, stmts)
+
+	stmts, err = PlanKeyMigration(schema, &TableKeys{
+		PrimaryKeyName: "t_pkey",
+		PrimaryKey:     []string{"id", "node"},
+		Indexes:        []string{"by_node", "by_pid"},
+	})
+	assert.Nil(err)
+	assert.Empty(stmts)
+
+	// The primary key is kept, if the schema declares none.
+	stmts, err = PlanKeyMigration(&Schema{Name: "t"}, &TableKeys{PrimaryKeyName: "t_pkey", PrimaryKey: []string{"id"}})
+	assert.Nil(err)
+	assert.Empty(stmts)
+}
@@ -0,0 +1,399 @@
+// Copyright (C) 2023  Tricorder Observability
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package pg
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	commonpb "github.com/tricorder/src/pb/module/common"
+)
+
+// The table that records the migrations applied by MigrateTable().
+const SchemaMigrationsTable = "tricorder_schema_migrations"
+
+// The canonical names of the supported column types, as returned by postgres' format_type(), which are the same as
+// the data_type values in information_schema.columns, except that arrays are named like 'integer[]'.
+var columnDataTypes = map[commonpb.DataField_Type]string{
+	BOOL:    "boolean",
+	DATE:    "date",
+	INT:     "integer",
+	INTEGER: "integer",
+	JSON:    "json",
+	JSONB:   "jsonb",
+	TEXT:    "text",
+
+	TIMESTAMPTZ: "timestamp with time zone",
+	BIGINT:      "bigint",
+	DOUBLE:      "double precision",
+	BYTEA:       "bytea",
+	INET:        "inet",
+}
+
+// Key is a data_type, value lists the data_types it can be changed to without losing data.
+// The same applies to the arrays of the data_types.
+var wideningDataTypes = map[string][]string{
+	"bigint":  {"text"},
+	"boolean": {"text"},
+	"date":    {"text", "timestamp with time zone"},
+	"integer": {"bigint", "double precision", "text"},
+	"inet":    {"text"},
+	"json":    {"jsonb", "text"},
+	"jsonb":   {"text"},
+
+	"double precision":         {"text"},
+	"timestamp with time zone": {"text"},
+}
+
+const arraySuffix = "[]"
+
+// TableColumn describes a column of an existing table.
+type TableColumn struct {
+	Name     string
+	DataType string
+	NotNull  bool
+
+	// The default expression as returned by postgres' pg_get_expr(), like 0 and 'abc'::text, empty if none.
+	Default string
+}
+
+// MigrateOptions controls the changes that MigrateTable() is allowed to make.
+type MigrateOptions struct {
+	// Applies the destructive changes, like dropping columns and narrowing column types.
+	Force bool
+
+	// Keeps the columns that are in the table but not in the schema, instead of dropping them.
+	KeepExtraColumns bool
+}
+
+// Migration describes the changes that make an existing table match a schema.
+type Migration struct {
+	Table string
+
+	// The SQL statements to be executed in order.
+	Statements []string
+
+	// Describes the changes that might lose data. Their statements are included in Statements only if forced.
+	Destructive []string
+}
+
+// AppliedMigration is a migration recorded in SchemaMigrationsTable.
+type AppliedMigration struct {
+	Table      string
+	Statements []string
+	Forced     bool
+	AppliedAt  time.Time
+}
+
+// ColumnDataType returns the canonical name of the column's type, see columnDataTypes.
+func ColumnDataType(c Column) (string, error) {
+	dataType, ok := columnDataTypes[c.Type]
+	if !ok {
+		return "", fmt.Errorf("data type '%s' is not supported", c.Type)
+	}
+	if c.Array {
+		dataType += arraySuffix
+	}
+	return dataType, nil
+}
+
+// IsWideningType returns true if the type of column from can be changed to the type of column to without losing data.
+// Identical types are widening.
+func IsWideningType(from, to Column) bool {
+	fromDataType, err := ColumnDataType(from)
+	if err != nil {
+		return false
+	}
+	toDataType, err := ColumnDataType(to)
+	if err != nil {
+		return false
+	}
+	return isWideningDataType(fromDataType, toDataType)
+}
+
+func isWideningDataType(from, to string) bool {
+	if from == to {
+		return true
+	}
+	fromIsArray := strings.HasSuffix(from, arraySuffix)
+	toIsArray := strings.HasSuffix(to, arraySuffix)
+	if fromIsArray != toIsArray {
+		return false
+	}
+	fromElem := strings.TrimSuffix(from, arraySuffix)
+	toElem := strings.TrimSuffix(to, arraySuffix)
+	for _, t := range wideningDataTypes[fromElem] {
+		if t == toElem {
+			return true
+		}
+	}
+	return false
+}
+
+// PlanMigration returns the migration that changes the table with the existing columns to match the schema.
+// The declared NOT NULL and default of the columns are added to the existing columns, but are not removed if they
+// are no longer declared. See PlanKeyMigration() for the primary key and indexes.
+func PlanMigration(schema *Schema, existing []TableColumn, opts MigrateOptions) (*Migration, error) {
+	if err := ValidateSchema(schema); err != nil {
+		return nil, fmt.Errorf("while planning migration, %v", err)
+	}
+	migration := &Migration{Table: schema.Name}
+	table := QuoteTableName(schema.Name)
+
+	existingColumns := make(map[string]TableColumn, len(existing))
+	for _, col := range existing {
+		existingColumns[col.Name] = col
+	}
+
+	desired := make(map[string]bool, len(schema.Columns))
+	for _, col := range schema.Columns {
+		name := NormalizeIdentifier(col.Name)
+		desired[name] = true
+		quotedName := QuoteIdentifier(name)
+
+		dataType, err := ColumnDataType(col)
+		if err != nil {
+			return nil, fmt.Errorf("while planning migration of table '%s', column '%s', error: %v",
+				schema.Name, col.Name, err)
+		}
+
+		existingCol, found := existingColumns[name]
+		if !found {
+			colDef, err := DefineColumn(col)
+			if err != nil {
+				return nil, fmt.Errorf("while planning migration of table '%s', failed to define column, error: %v",
+					schema.Name, err)
+			}
+			migration.Statements = append(migration.Statements,
+				fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s", table, colDef))
+			continue
+		}
+		if existingCol.DataType != dataType {
+			stmt := fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s TYPE %s USING %s::%s",
+				table, quotedName, dataType, quotedName, dataType)
+			if !isWideningDataType(existingCol.DataType, dataType) {
+				migration.Destructive = append(migration.Destructive,
+					fmt.Sprintf("change column '%s' type from %s to %s", name, existingCol.DataType, dataType))
+				if !opts.Force {
+					continue
+				}
+			}
+			migration.Statements = append(migration.Statements, stmt)
+		}
+
+		// Only the declared constraints are enforced, the undeclared ones are kept, for example, the NOT NULL of
+		// a hypertable's time column, or the default of '_ingest_time'.
+		if col.NotNull && !existingCol.NotNull {
+			migration.Statements = append(migration.Statements,
+				fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s SET %s", table, quotedName, NOT_NULL))
+		}
+		if len(col.Default) > 0 && !isSameDefault(existingCol.Default, col.Default) {
+			migration.Statements = append(migration.Statements,
+				fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s SET %s %s",
+					table, quotedName, DEFAULT, QuoteLiteral(col.Default)))
+		}
+	}
+
+	if opts.KeepExtraColumns {
+		return migration, nil
+	}
+	for _, col := range existing {
+		if desired[col.Name] {
+			continue
+		}
+		migration.Destructive = append(migration.Destructive, fmt.Sprintf("drop column '%s'", col.Name))
+		if opts.Force {
+			// The name is as it is in the database, it is quoted without being folded to lower case.
+			migration.Statements = append(migration.Statements,
+				fmt.Sprintf("ALTER TABLE %s DROP COLUMN %s", table, pgx.Identifier{col.Name}.Sanitize()))
+		}
+	}
+	return migration, nil
+}
+
+// isSameDefault returns true if the default expression of a column, as returned by pg_get_expr(), is the constant.
+// Postgres keeps the numbers and booleans as they are, and casts the other constants, like 'abc'::text.
+func isSameDefault(expr, constant string) bool {
+	literal := QuoteLiteral(constant)
+	return expr == constant || expr == literal || strings.HasPrefix(expr, literal+"::")
+}
+
+// GetTableColumns returns the columns of the table, or an empty slice if the table does not exist.
+// The column types are named by format_type(), instead of information_schema.columns, which names all arrays 'ARRAY'.
+func (c *Client) GetTableColumns(table string) ([]TableColumn, error) {
+	const sql = `SELECT attname, format_type(atttypid, atttypmod), attnotnull, ` +
+		`coalesce(pg_get_expr(adbin, adrelid), '') FROM pg_attribute ` +
+		`LEFT JOIN pg_attrdef ON adrelid = attrelid AND adnum = attnum ` +
+		`WHERE attrelid = to_regclass($1::text) AND attnum > 0 AND NOT attisdropped ORDER BY attnum`
+	rows, err := c.pool.Query(context.Background(), sql, NormalizeIdentifier(table))
+	if err != nil {
+		return nil, fmt.Errorf("while getting columns of table '%s', failed to query, error: %v", table, err)
+	}
+	defer rows.Close()
+
+	columns := make([]TableColumn, 0)
+	for rows.Next() {
+		var col TableColumn
+		err := rows.Scan(&col.Name, &col.DataType, &col.NotNull, &col.Default)
+		if err != nil {
+			return nil, fmt.Errorf("while getting columns of table '%s', failed to scan row, error: %v", table, err)
+		}
+		columns = append(columns, col)
+	}
+	return columns, rows.Err()
+}
+
+// MigrateTable creates the table of the schema if it does not exist, otherwise changes the table to match the
+// schema. Returns error without changing the table if destructive changes are needed but not forced.
+// The executed statements are recorded in SchemaMigrationsTable.
+func (c *Client) MigrateTable(schema *Schema, opts MigrateOptions) (*Migration, error) {
+	return c.migrateTable(schema, opts, nil)
+}
+
+// migrateTable is MigrateTable(), which also runs prepare, if not nil, at the start of the migration's transaction.
+func (c *Client) migrateTable(schema *Schema, opts MigrateOptions,
+	prepare func(ctx context.Context, tx pgx.Tx) error,
+) (*Migration, error) {
+	existing, err := c.GetTableColumns(schema.Name)
+	if err != nil {
+		return nil, fmt.Errorf("while migrating table '%s', error: %v", schema.Name, err)
+	}
+
+	var migration *Migration
+	if len(existing) == 0 {
+		sql, err := buildCreateTableSQL(schema)
+		if err != nil {
+			return nil, fmt.Errorf("while migrating table '%s', failed to build SQL, error: %v", schema.Name, err)
+		}
+		indexesSQL, err := buildCreateIndexesSQL(schema)
+		if err != nil {
+			return nil, fmt.Errorf("while migrating table '%s', failed to build SQL, error: %v", schema.Name, err)
+		}
+		migration = &Migration{Table: schema.Name}
+		if schemaSQL := buildCreateSchemaSQL(schema.Name); len(schemaSQL) > 0 {
+			migration.Statements = append(migration.Statements, schemaSQL)
+		}
+		migration.Statements = append(migration.Statements, sql)
+		migration.Statements = append(migration.Statements, indexesSQL...)
+	} else {
+		migration, err = PlanMigration(schema, existing, opts)
+		if err != nil {
+			return nil, err
+		}
+		if len(migration.Destructive) > 0 && !opts.Force {
+			return nil, fmt.Errorf("while migrating table '%s', refused to apply destructive changes without force: %s",
+				schema.Name, strings.Join(migration.Destructive, ", "))
+		}
+		keys, err := c.GetTableKeys(schema.Name)
+		if err != nil {
+			return nil, fmt.Errorf("while migrating table '%s', error: %v", schema.Name, err)
+		}
+		keyStmts, err := PlanKeyMigration(schema, keys)
+		if err != nil {
+			return nil, err
+		}
+		migration.Statements = append(migration.Statements, keyStmts...)
+	}
+	if len(migration.Statements) == 0 && prepare == nil {
+		return migration, nil
+	}
+
+	err = c.applyMigration(migration, opts.Force, prepare)
+	if err != nil {
+		return nil, fmt.Errorf("while migrating table '%s', error: %v", schema.Name, err)
+	}
+	return migration, nil
+}
+
+// applyMigration executes the statements of the migration and records it, in one transaction, which starts with
+// prepare if it's not nil.
+func (c *Client) applyMigration(migration *Migration, forced bool,
+	prepare func(ctx context.Context, tx pgx.Tx) error,
+) error {
+	ctx := context.Background()
+	tx, err := c.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction, error: %v", err)
+	}
+	// No-op if the transaction is committed.
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	if prepare != nil {
+		if err := prepare(ctx, tx); err != nil {
+			return err
+		}
+	}
+	for _, stmt := range migration.Statements {
+		_, err := tx.Exec(ctx, stmt)
+		if err != nil {
+			return fmt.Errorf("failed to execute '%s', error: %v", stmt, err)
+		}
+	}
+	if len(migration.Statements) > 0 {
+		err = recordMigration(ctx, tx, migration, forced)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit(ctx)
+}
+
+func recordMigration(ctx context.Context, tx pgx.Tx, migration *Migration, forced bool) error {
+	createSQL := fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+	id BIGSERIAL PRIMARY KEY,
+	table_name TEXT NOT NULL,
+	statements TEXT[] NOT NULL,
+	forced BOOLEAN NOT NULL,
+	applied_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now()
+	);`, SchemaMigrationsTable)
+	_, err := tx.Exec(ctx, createSQL)
+	if err != nil {
+		return fmt.Errorf("failed to create table '%s', error: %v", SchemaMigrationsTable, err)
+	}
+	insertSQL := fmt.Sprintf(`INSERT INTO %s (table_name, statements, forced) VALUES ($1, $2, $3)`,
+		SchemaMigrationsTable)
+	_, err = tx.Exec(ctx, insertSQL, migration.Table, migration.Statements, forced)
+	if err != nil {
+		return fmt.Errorf("failed to record migration, error: %v", err)
+	}
+	return nil
+}
+
+// ListMigrations returns the migrations applied to the table, the earliest first.
+func (c *Client) ListMigrations(table string) ([]AppliedMigration, error) {
+	sql := fmt.Sprintf(`SELECT table_name, statements, forced, applied_at FROM %s WHERE table_name = $1 ORDER BY id`,
+		SchemaMigrationsTable)
+	rows, err := c.pool.Query(context.Background(), sql, table)
+	if err != nil {
+		return nil, fmt.Errorf("while listing migrations of table '%s', failed to query, error: %v", table, err)
+	}
+	defer rows.Close()
+
+	migrations := make([]AppliedMigration, 0)
+	for rows.Next() {
+		var m AppliedMigration
+		err := rows.Scan(&m.Table, &m.Statements, &m.Forced, &m.AppliedAt)
+		if err != nil {
+			return nil, fmt.Errorf("while listing migrations of table '%s', failed to scan row, error: %v", table, err)
+		}
+		migrations = append(migrations, m)
+	}
+	return migrations, rows.Err()
+}
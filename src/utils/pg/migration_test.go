@@ -0,0 +1,272 @@
+// Copyright (C) 2023  Tricorder Observability
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package pg
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	commonpb "github.com/tricorder/src/pb/module/common"
+)
+
+// Tests that PlanMigration adds missing columns, widens column types, and leaves out destructive changes unless
+// forced.
+func TestPlanMigration(t *testing.T) {
+	assert := assert.New(t)
+
+	schema := &Schema{
+		Name: "test_table",
+		Columns: []Column{
+			{Name: "id", Type: TEXT},
+			{Name: "count", Type: TEXT},
+			{Name: "data", Type: JSONB},
+			{Name: "ok", Type: BOOL},
+			{Name: "NewCol", Type: INT},
+		},
+	}
+	existing := []TableColumn{
+		{Name: "id", DataType: "text"},
+		{Name: "count", DataType: "integer"},
+		{Name: "data", DataType: "json"},
+		{Name: "ok", DataType: "text"},
+		{Name: "old", DataType: "date"},
+	}
+
+	migration, err := PlanMigration(schema, existing, MigrateOptions{})
+	assert.Nil(err)
+	assert.Equal([]string{
+		`ALTER TABLE "test_table" ALTER COLUMN "count" TYPE text USING "count"::text`,
+		`ALTER TABLE "test_table" ALTER COLUMN "data" TYPE jsonb USING "data"::jsonb`,
+		`ALTER TABLE "test_table" ADD COLUMN "newcol" INT`,
+	}, migration.Statements)
+	assert.Equal([]string{
+		"change column 'ok' type from text to boolean",
+		"drop column 'old'",
+	}, migration.Destructive)
+
+	migration, err = PlanMigration(schema, existing, MigrateOptions{Force: true})
+	assert.Nil(err)
+	assert.Equal([]string{
+		`ALTER TABLE "test_table" ALTER COLUMN "count" TYPE text USING "count"::text`,
+		`ALTER TABLE "test_table" ALTER COLUMN "data" TYPE jsonb USING "data"::jsonb`,
+		`ALTER TABLE "test_table" ALTER COLUMN "ok" TYPE boolean USING "ok"::boolean`,
+		`ALTER TABLE "test_table" ADD COLUMN "newcol" INT`,
+		`ALTER TABLE "test_table" DROP COLUMN "old"`,
+	}, migration.Statements)
+	assert.Len(migration.Destructive, 2)
+
+	migration, err = PlanMigration(schema, existing, MigrateOptions{KeepExtraColumns: true})
+	assert.Nil(err)
+	assert.Equal([]string{"change column 'ok' type from text to boolean"}, migration.Destructive)
+
+	migration, err = PlanMigration(schema, []TableColumn{
+		{Name: "id", DataType: "text"},
+		{Name: "count", DataType: "text"},
+		{Name: "data", DataType: "jsonb"},
+		{Name: "ok", DataType: "boolean"},
+		{Name: "newcol", DataType: "integer"},
+	}, MigrateOptions{})
+	assert.Nil(err)
+	assert.Empty(migration.Statements)
+	assert.Empty(migration.Destructive)
+
+	_, err = PlanMigration(&Schema{Name: "test_table", Columns: []Column{{Name: "a", Type: 100}}},
+		existing, MigrateOptions{})
+	assert.ErrorContains(err, "data type '100' is not supported")
+}
+
+// Tests that PlanMigration adds the declared NOT NULL and defaults to the existing columns.
+func TestPlanMigrationConstraints(t *testing.T) {
+	assert := assert.New(t)
+
+	schema := &Schema{
+		Name: "test_table",
+		Columns: []Column{
+			{Name: "id", Type: TEXT, NotNull: true},
+			{Name: "count", Type: INT, Default: "0"},
+			{Name: "comm", Type: TEXT, Default: "unknown"},
+			{Name: "ok", Type: BOOL},
+			{Name: "day", Type: DATE, NotNull: true, Default: "2023-01-01"},
+		},
+	}
+	existing := []TableColumn{
+		{Name: "id", DataType: "text"},
+		{Name: "count", DataType: "integer", Default: "0"},
+		{Name: "comm", DataType: "text", Default: "'none'::text"},
+		{Name: "ok", DataType: "boolean", NotNull: true, Default: "true"},
+	}
+	migration, err := PlanMigration(schema, existing, MigrateOptions{})
+	assert.Nil(err)
+	assert.Equal([]string{
+		`ALTER TABLE "test_table" ALTER COLUMN "id" SET NOT NULL`,
+		`ALTER TABLE "test_table" ALTER COLUMN "comm" SET DEFAULT 'unknown'`,
+		`ALTER TABLE "test_table" ADD COLUMN "day" DATE NOT NULL DEFAULT '2023-01-01'`,
+	}, migration.Statements)
+
+	existing[0].NotNull = true
+	existing[2].Default = "'unknown'::text"
+	existing = append(existing, TableColumn{Name: "day", DataType: "date", NotNull: true, Default: "'2023-01-01'::date"})
+	migration, err = PlanMigration(schema, existing, MigrateOptions{})
+	assert.Nil(err)
+	assert.Empty(migration.Statements)
+}
+
+// Tests that IsWideningType returns true only for the types that can be changed without losing data.
+func TestIsWideningType(t *testing.T) {
+	assert := assert.New(t)
+
+	col := func(t commonpb.DataField_Type) Column { return Column{Type: t} }
+	array := func(t commonpb.DataField_Type) Column { return Column{Type: t, Array: true} }
+
+	assert.True(IsWideningType(col(TEXT), col(TEXT)))
+	assert.True(IsWideningType(col(INT), col(INTEGER)))
+	assert.True(IsWideningType(col(INT), col(TEXT)))
+	assert.True(IsWideningType(col(INT), col(BIGINT)))
+	assert.True(IsWideningType(col(INTEGER), col(DOUBLE)))
+	assert.True(IsWideningType(col(JSON), col(JSONB)))
+	assert.True(IsWideningType(array(INT), array(BIGINT)))
+	assert.False(IsWideningType(col(TEXT), col(INT)))
+	assert.False(IsWideningType(col(JSONB), col(JSON)))
+	assert.False(IsWideningType(col(BOOL), col(DATE)))
+	assert.False(IsWideningType(col(BIGINT), col(INT)))
+	assert.False(IsWideningType(col(BIGINT), col(DOUBLE)))
+	assert.False(IsWideningType(col(INT), array(INT)))
+	assert.False(IsWideningType(array(TEXT), col(TEXT)))
+}
+
+// Tests that MigrateTable creates the table, applies the non-destructive changes and records them, and refuses
+// destructive changes unless forced.
+func TestMigrateTable(t *testing.T) {
+	assert := assert.New(t)
+
+	pgRunner, pgClient, err := createPGTestFixutre()
+	assert.Nil(err)
+
+	defer func() {
+		assert.Nil(pgRunner.Stop())
+		pgClient.Close()
+	}()
+
+	schema := &Schema{
+		Name:    "test_table",
+		Columns: []Column{{Name: "id", Type: TEXT}, {Name: "count", Type: INT}},
+	}
+	_, err = pgClient.MigrateTable(schema, MigrateOptions{})
+	assert.Nil(err)
+	assert.Nil(pgClient.WriteRecord([]interface{}{"1234", 1}, schema))
+
+	schema.Columns = []Column{
+		{Name: "id", Type: TEXT},
+		{Name: "count", Type: TEXT},
+		{Name: "data", Type: JSONB},
+		{Name: "tags", Type: TEXT, Array: true},
+	}
+	migration, err := pgClient.MigrateTable(schema, MigrateOptions{})
+	assert.Nil(err)
+	assert.Len(migration.Statements, 3)
+
+	columns, err := pgClient.GetTableColumns("test_table")
+	assert.Nil(err)
+	assert.Equal([]TableColumn{
+		{Name: "id", DataType: "text"},
+		{Name: "count", DataType: "text"},
+		{Name: "data", DataType: "jsonb"},
+		{Name: "tags", DataType: "text[]"},
+	}, columns)
+
+	// Migrating again is a no-op.
+	migration, err = pgClient.MigrateTable(schema, MigrateOptions{})
+	assert.Nil(err)
+	assert.Empty(migration.Statements)
+
+	records, err := pgClient.Query("select count from test_table")
+	assert.Nil(err)
+	assert.Equal([][]interface{}{{"1"}}, records)
+
+	schema.Columns = []Column{{Name: "id", Type: TEXT}}
+	_, err = pgClient.MigrateTable(schema, MigrateOptions{})
+	assert.ErrorContains(err, "refused to apply destructive changes without force")
+	_, err = pgClient.MigrateTable(schema, MigrateOptions{Force: true})
+	assert.Nil(err)
+
+	columns, err = pgClient.GetTableColumns("test_table")
+	assert.Nil(err)
+	assert.Equal([]TableColumn{{Name: "id", DataType: "text"}}, columns)
+
+	migrations, err := pgClient.ListMigrations("test_table")
+	assert.Nil(err)
+	assert.Len(migrations, 3)
+	assert.False(migrations[1].Forced)
+	assert.True(migrations[2].Forced)
+}
+
+// Tests that MigrateTable creates and changes the primary key and indexes, and applies the column constraints.
+func TestMigrateTableKeys(t *testing.T) {
+	assert := assert.New(t)
+
+	pgRunner, pgClient, err := createPGTestFixutre()
+	assert.Nil(err)
+
+	defer func() {
+		assert.Nil(pgRunner.Stop())
+		pgClient.Close()
+	}()
+
+	schema := &Schema{
+		Name: "test_table",
+		Columns: []Column{
+			{Name: "id", Type: TEXT},
+			{Name: "node", Type: TEXT, NotNull: true},
+			{Name: "count", Type: INT, Default: "1"},
+		},
+		PrimaryKey: []string{"id"},
+		Indexes:    []Index{{Columns: []string{"node", "count"}}},
+	}
+	_, err = pgClient.MigrateTable(schema, MigrateOptions{})
+	assert.Nil(err)
+
+	_, err = pgClient.pool.Exec(context.Background(), "INSERT INTO test_table (id, node) VALUES ('a', 'n1')")
+	assert.Nil(err)
+	records, err := pgClient.Query("SELECT count FROM test_table")
+	assert.Nil(err)
+	assert.Equal([][]interface{}{{int32(1)}}, records)
+	_, err = pgClient.pool.Exec(context.Background(), "INSERT INTO test_table (id) VALUES ('b')")
+	assert.ErrorContains(err, "violates not-null constraint")
+
+	keys, err := pgClient.GetTableKeys("test_table")
+	assert.Nil(err)
+	assert.Equal("test_table_pkey", keys.PrimaryKeyName)
+	assert.Equal([]string{"id"}, keys.PrimaryKey)
+	assert.Equal([]string{IndexName("test_table", schema.Indexes[0])}, keys.Indexes)
+
+	// Migrating again is a no-op.
+	migration, err := pgClient.MigrateTable(schema, MigrateOptions{})
+	assert.Nil(err)
+	assert.Empty(migration.Statements)
+
+	schema.PrimaryKey = []string{"id", "node"}
+	schema.Indexes = append(schema.Indexes, Index{Name: "test_table_count", Columns: []string{"count"}, Unique: true})
+	migration, err = pgClient.MigrateTable(schema, MigrateOptions{})
+	assert.Nil(err)
+	assert.Len(migration.Statements, 3)
+
+	keys, err = pgClient.GetTableKeys("test_table")
+	assert.Nil(err)
+	assert.Equal([]string{"id", "node"}, keys.PrimaryKey)
+	assert.Contains(keys.Indexes, "test_table_count")
+}
@@ -0,0 +1,68 @@
+// Copyright (C) 2023  Tricorder Observability
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package pg
+
+import "strings"
+
+// The names of the reserved columns, which are added to the data table of every module.
+// Agents fill them to enrich the records produced by modules, so that the records can be correlated with time,
+// node, and pod. They are prefixed with '_' to avoid conflicting with the fields declared by modules.
+const (
+	// The time when the agent received the record.
+	IngestTimeColumn = "_ingest_time"
+
+	// The name of the node that runs the agent.
+	NodeNameColumn = "_node_name"
+
+	// The ID of the agent that produced the record.
+	AgentIDColumn = "_agent_id"
+
+	// The name of the pod and the container that runs the process of the record's 'pid' field.
+	PodColumn       = "_pod"
+	ContainerColumn = "_container"
+)
+
+// ReservedColumns lists the reserved columns in the order they are appended to the data tables.
+var ReservedColumns = []Column{
+	{Name: IngestTimeColumn, Type: TIMESTAMPTZ},
+	{Name: NodeNameColumn, Type: TEXT},
+	{Name: AgentIDColumn, Type: TEXT},
+	{Name: PodColumn, Type: TEXT},
+	{Name: ContainerColumn, Type: TEXT},
+}
+
+// IsReservedColumn returns true if the name is one of the reserved columns, ignoring case.
+func IsReservedColumn(name string) bool {
+	for _, col := range ReservedColumns {
+		if strings.EqualFold(col.Name, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// WithReservedColumns returns a copy of the schema, with the reserved columns appended.
+func WithReservedColumns(schema *Schema) *Schema {
+	columns := make([]Column, 0, len(schema.Columns)+len(ReservedColumns))
+	columns = append(columns, schema.Columns...)
+	columns = append(columns, ReservedColumns...)
+	return &Schema{
+		Name:       schema.Name,
+		Columns:    columns,
+		PrimaryKey: schema.PrimaryKey,
+		Indexes:    schema.Indexes,
+	}
+}
@@ -0,0 +1,47 @@
+// Copyright (C) 2023  Tricorder Observability
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package pg
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Tests that IsReservedColumn() matches the reserved column names ignoring case.
+func TestIsReservedColumn(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.True(IsReservedColumn("_ingest_time"))
+	assert.True(IsReservedColumn("_Node_Name"))
+	assert.False(IsReservedColumn("node_name"))
+	assert.False(IsReservedColumn("data"))
+}
+
+// Tests that WithReservedColumns() appends the reserved columns without changing the input schema.
+func TestWithReservedColumns(t *testing.T) {
+	assert := assert.New(t)
+
+	schema := GetJSONBTableSchema("test_table")
+	enriched := WithReservedColumns(schema)
+	assert.Equal("test_table", enriched.Name)
+	assert.Equal(append([]Column{{Name: "data", Type: JSONB}}, ReservedColumns...), enriched.Columns)
+	assert.Len(schema.Columns, 1)
+
+	colDef, err := DefineColumn(enriched.Columns[1])
+	assert.Nil(err)
+	assert.Equal(`"_ingest_time" TIMESTAMPTZ`, colDef)
+}
@@ -24,6 +24,12 @@ type Schema struct {
 
 	// All fields
 	Columns []Column
+
+	// The columns that make up the primary key, empty if the table has no primary key.
+	PrimaryKey []string
+
+	// The secondary indexes.
+	Indexes []Index
 }
 
 // jsonbTableSchemaTmpl defines the data table columns, only requires to define name.
@@ -43,14 +49,74 @@ func GetJSONBTableSchema(tableName string) *Schema {
 	return &schema
 }
 
+// The columns of the tables of JSONB objects, see GetJSONBObjectTableSchema().
+const (
+	JSONDataColumn = "data"
+	JSONIDColumn   = "id"
+)
+
+// GetJSONBObjectTableSchema returns the schema of a table that stores JSONB objects, each identified by the text at
+// idPath of the object, [metadata, uid] by default. The 'id' column is generated from the object and is uniquely
+// indexed, so that Json.Upsert() writes an object in a single statement.
+func GetJSONBObjectTableSchema(tableName string, idPath ...string) *Schema {
+	return &Schema{
+		Name: tableName,
+		Columns: []Column{
+			{
+				Name: JSONDataColumn,
+				Type: JSONB,
+			},
+			{
+				Name:      JSONIDColumn,
+				Type:      TEXT,
+				NotNull:   true,
+				Generated: jsonPathExpr(JSONDataColumn, pgPath(idPath)),
+			},
+		},
+		Indexes: []Index{
+			{
+				Columns: []string{JSONIDColumn},
+				Unique:  true,
+			},
+		},
+	}
+}
+
 // Returns a Schema from a protobuf with the same semantics.
 func SchemaFromPB(pbSchema *common.Schema) *Schema {
 	columns := make([]Column, 0, len(pbSchema.Fields))
 	for _, field := range pbSchema.Fields {
-		columns = append(columns, Column{Name: field.Name, Type: field.Type})
+		columns = append(columns, ColumnFromPB(field))
+	}
+	indexes := make([]Index, 0, len(pbSchema.Indexes))
+	for _, index := range pbSchema.Indexes {
+		indexes = append(indexes, IndexFromPB(index))
 	}
 	return &Schema{
-		Name:    pbSchema.Name,
-		Columns: columns,
+		Name:       pbSchema.Name,
+		Columns:    columns,
+		PrimaryKey: pbSchema.PrimaryKey,
+		Indexes:    indexes,
+	}
+}
+
+// Returns a Column from a protobuf with the same semantics.
+func ColumnFromPB(field *common.DataField) Column {
+	return Column{
+		Name:    field.Name,
+		Type:    field.Type,
+		Array:   field.Array,
+		NotNull: field.NotNull,
+		Default: field.Default,
+	}
+}
+
+// Returns an Index from a protobuf with the same semantics.
+func IndexFromPB(index *common.Index) Index {
+	return Index{
+		Name:    index.Name,
+		Columns: index.Columns,
+		Unique:  index.Unique,
+		Method:  index.Method,
 	}
 }
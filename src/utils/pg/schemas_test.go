@@ -35,3 +35,20 @@ func TestGetTableSchema(t *testing.T) {
 	},
 		GetJSONBTableSchema("test_table"))
 }
+
+// Tests that GetJSONBObjectTableSchema() generates the uniquely indexed ID column from the ID path.
+func TestGetJSONBObjectTableSchema(t *testing.T) {
+	assert := assert.New(t)
+
+	schema := GetJSONBObjectTableSchema("test_table")
+	assert.Equal([]Index{{Columns: []string{"id"}, Unique: true}}, schema.Indexes)
+	assert.Equal(`"data" #>> ARRAY['metadata', 'uid']`, schema.Columns[1].Generated)
+
+	schema = GetJSONBObjectTableSchema("test_table", "container", "it's")
+	assert.Equal(`"data" #>> ARRAY['container', 'it''s']`, schema.Columns[1].Generated)
+
+	sql, err := buildCreateTableSQL(schema)
+	assert.Nil(err)
+	assert.Equal(`CREATE TABLE IF NOT EXISTS "test_table" ( "data" JSONB,`+
+		`"id" TEXT GENERATED ALWAYS AS ("data" #>> ARRAY['container', 'it''s']) STORED NOT NULL );`, sql)
+}
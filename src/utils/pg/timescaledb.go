@@ -0,0 +1,140 @@
+// Copyright (C) 2023  Tricorder Observability
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package pg
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+
+	commonpb "github.com/tricorder/src/pb/module/common"
+)
+
+// GetHypertableTimeColumn returns the time column of the hypertable, or an empty string if the table is not a
+// hypertable. The table is in the current schema unless it is qualified, see QualifyTableName().
+func (c *Client) GetHypertableTimeColumn(table string) (string, error) {
+	const sql = `SELECT column_name FROM timescaledb_information.dimensions ` +
+		`WHERE hypertable_schema = coalesce(nullif($1, ''), current_schema()) AND hypertable_name = $2 ` +
+		`AND dimension_number = 1`
+	schema, name := SplitTableName(NormalizeIdentifier(table))
+	var timeColumn string
+	err := c.pool.QueryRow(context.Background(), sql, schema, name).Scan(&timeColumn)
+	if err == pgx.ErrNoRows {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("while getting time column of hypertable '%s', failed to query, error: %v", table, err)
+	}
+	return timeColumn, nil
+}
+
+// ApplyHypertable turns the table into a hypertable partitioned by the spec's time column, if it is not yet, and
+// replaces its retention and compression policies with the ones of the spec.
+// The time column of an existing hypertable cannot be changed.
+func (c *Client) ApplyHypertable(table string, spec *commonpb.Hypertable) error {
+	if err := ValidateTableName(table); err != nil {
+		return fmt.Errorf("while applying hypertable spec to table, %v", err)
+	}
+	if err := ValidateIdentifier(spec.TimeColumn); err != nil {
+		return fmt.Errorf("while applying hypertable spec to table '%s', invalid time column, %v", table, err)
+	}
+	timeColumn, err := c.GetHypertableTimeColumn(table)
+	if err != nil {
+		return fmt.Errorf("while applying hypertable spec to table '%s', error: %v", table, err)
+	}
+	if len(timeColumn) > 0 && timeColumn != NormalizeIdentifier(spec.TimeColumn) {
+		return fmt.Errorf("while applying hypertable spec to table '%s', cannot change time column from '%s' to '%s'",
+			table, timeColumn, spec.TimeColumn)
+	}
+
+	ctx := context.Background()
+	tx, err := c.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("while applying hypertable spec to table '%s', failed to begin transaction, error: %v",
+			table, err)
+	}
+	// No-op if the transaction is committed.
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	stmts := buildHypertableStmts(table, spec, len(timeColumn) == 0)
+	for _, stmt := range stmts {
+		_, err := tx.Exec(ctx, stmt.sql, stmt.args...)
+		if err != nil {
+			return fmt.Errorf("while applying hypertable spec to table '%s', failed to execute '%s', error: %v",
+				table, stmt.sql, err)
+		}
+	}
+	return tx.Commit(ctx)
+}
+
+type stmtWithArgs struct {
+	sql  string
+	args []interface{}
+}
+
+// buildHypertableStmts returns the statements that apply the hypertable spec to the table. The table is converted
+// to a hypertable if create is true.
+func buildHypertableStmts(table string, spec *commonpb.Hypertable, create bool) []stmtWithArgs {
+	var stmts []stmtWithArgs
+	quotedTable := QuoteTableName(table)
+	ingestTime := QuoteIdentifier(IngestTimeColumn)
+	if create {
+		if spec.TimeColumn == IngestTimeColumn {
+			// The records written by the agents that do not enrich records still need a time, as the time column of
+			// a hypertable cannot be null.
+			stmts = append(stmts,
+				stmtWithArgs{
+					sql: fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s SET DEFAULT now()", quotedTable, ingestTime),
+				},
+				stmtWithArgs{
+					sql: fmt.Sprintf("UPDATE %s SET %s = now() WHERE %s IS NULL", quotedTable, ingestTime, ingestTime),
+				},
+			)
+		}
+		stmts = append(stmts, stmtWithArgs{
+			sql: `SELECT create_hypertable($1::text::regclass, $2::text::name, ` +
+				`if_not_exists => TRUE, migrate_data => TRUE)`,
+			args: []interface{}{NormalizeIdentifier(table), NormalizeIdentifier(spec.TimeColumn)},
+		})
+	}
+
+	stmts = append(stmts, stmtWithArgs{
+		sql:  `SELECT remove_retention_policy($1::text::regclass, if_exists => TRUE)`,
+		args: []interface{}{table},
+	})
+	if len(spec.Retention) > 0 {
+		stmts = append(stmts, stmtWithArgs{
+			sql:  `SELECT add_retention_policy($1::text::regclass, $2::text::interval)`,
+			args: []interface{}{table, spec.Retention},
+		})
+	}
+
+	stmts = append(stmts, stmtWithArgs{
+		sql:  `SELECT remove_compression_policy($1::text::regclass, if_exists => TRUE)`,
+		args: []interface{}{table},
+	})
+	if len(spec.CompressAfter) > 0 {
+		stmts = append(stmts,
+			stmtWithArgs{sql: fmt.Sprintf("ALTER TABLE %s SET (timescaledb.compress)", quotedTable)},
+			stmtWithArgs{
+				sql:  `SELECT add_compression_policy($1::text::regclass, $2::text::interval)`,
+				args: []interface{}{table, spec.CompressAfter},
+			},
+		)
+	}
+	return stmts
+}
@@ -0,0 +1,76 @@
+// Copyright (C) 2023  Tricorder Observability
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package pg_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	commonpb "github.com/tricorder/src/pb/module/common"
+	timescale "github.com/tricorder/src/testing/timescaledb"
+	"github.com/tricorder/src/utils/pg"
+)
+
+// Tests that ApplyHypertable creates a hypertable with retention and compression policies, and updates the policies.
+// This test is in package pg_test, because src/testing/timescaledb depends on this package.
+func TestApplyHypertable(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	cleanerFn, pgClient, err := timescale.LaunchContainer()
+	require.Nil(err)
+	defer func() { assert.Nil(cleanerFn()) }()
+
+	schema := pg.WithReservedColumns(pg.GetJSONBTableSchema("test_table"))
+	require.Nil(pgClient.CreateTable(schema))
+	require.Nil(pgClient.WriteRecord([]interface{}{`{"a":1}`}, pg.GetJSONBTableSchema("test_table")))
+
+	timeColumn, err := pgClient.GetHypertableTimeColumn("test_table")
+	assert.Nil(err)
+	assert.Equal("", timeColumn)
+
+	spec := &commonpb.Hypertable{TimeColumn: pg.IngestTimeColumn, Retention: "7 days", CompressAfter: "1 day"}
+	require.Nil(pgClient.ApplyHypertable("test_table", spec))
+	timeColumn, err = pgClient.GetHypertableTimeColumn("test_table")
+	assert.Nil(err)
+	assert.Equal(pg.IngestTimeColumn, timeColumn)
+
+	// Records without time are written at the insertion time.
+	assert.Nil(pgClient.WriteRecord([]interface{}{`{"a":2}`}, pg.GetJSONBTableSchema("test_table")))
+
+	const policiesSQL = `SELECT proc_name, config->>'drop_after', config->>'compress_after' ` +
+		`FROM timescaledb_information.jobs WHERE hypertable_name = 'test_table' ORDER BY proc_name`
+	policies, err := pgClient.Query(policiesSQL)
+	assert.Nil(err)
+	assert.Equal([][]interface{}{
+		{"policy_compression", nil, "1 day"},
+		{"policy_retention", "7 days", nil},
+	}, policies)
+
+	// Applying again replaces the policies.
+	require.Nil(pgClient.ApplyHypertable("test_table", &commonpb.Hypertable{
+		TimeColumn: pg.IngestTimeColumn,
+		Retention:  "30 days",
+	}))
+	policies, err = pgClient.Query(policiesSQL)
+	assert.Nil(err)
+	assert.Equal([][]interface{}{{"policy_retention", "30 days", nil}}, policies)
+
+	err = pgClient.ApplyHypertable("test_table", &commonpb.Hypertable{TimeColumn: "other_time"})
+	assert.ErrorContains(err, "cannot change time column")
+}
@@ -15,20 +15,29 @@
 
 package pg
 
-import "strings"
+import (
+	"fmt"
+	"strings"
+)
 
-// pgPath read string slice, return string with postgres support format, e.g. ->'key'->>'subKey'.
-func pgPath(paths []string) string {
+// The default path of the ID in the JSON objects written by Json.Upsert().
+var defaultIDPath = []string{"metadata", "uid"}
+
+// pgPath returns the path of the ID in the JSON objects, which is the default path if paths is empty.
+// The path is passed as a text[] argument of the #>> operator, e.g. data #>> '{metadata,uid}'.
+func pgPath(paths []string) []string {
 	if len(paths) == 0 {
-		return "data->'metadata'->>'uid'"
+		return defaultIDPath
 	}
-	sb := strings.Builder{}
-	for i := range paths {
-		if i != len(paths)-1 {
-			sb.WriteString("->'" + paths[i] + "'")
-		} else {
-			sb.WriteString("->>'" + paths[i] + "'")
-		}
+	return paths
+}
+
+// jsonPathExpr returns the SQL expression that extracts the text at the path of the JSONB column,
+// e.g. "data" #>> ARRAY['metadata', 'uid'].
+func jsonPathExpr(column string, path []string) string {
+	elems := make([]string, 0, len(path))
+	for _, elem := range path {
+		elems = append(elems, QuoteLiteral(elem))
 	}
-	return "data" + sb.String()
+	return fmt.Sprintf("%s #>> ARRAY[%s]", QuoteIdentifier(column), strings.Join(elems, ", "))
 }
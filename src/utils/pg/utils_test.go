@@ -21,15 +21,15 @@ import (
 	"github.com/stretchr/testify/assert"
 )
 
-// Tests that the pgPath() returns the correct JSON path for querying JSON object.
+// Tests that the pgPath() returns the JSON path of the ID, or the default path.
 func TestPGPath(t *testing.T) {
 	assert := assert.New(t)
 	idPath := []string{}
-	assert.Equal("data->'metadata'->>'uid'", pgPath(idPath))
+	assert.Equal([]string{"metadata", "uid"}, pgPath(idPath))
 
 	idPath = []string{"uid"}
-	assert.Equal("data->>'uid'", pgPath(idPath))
+	assert.Equal([]string{"uid"}, pgPath(idPath))
 
 	idPath = []string{"metadata", "uid", "id"}
-	assert.Equal("data->'metadata'->'uid'->>'id'", pgPath(idPath))
+	assert.Equal([]string{"metadata", "uid", "id"}, pgPath(idPath))
 }
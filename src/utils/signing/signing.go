@@ -0,0 +1,174 @@
+// Copyright (C) 2023  Tricorder Observability
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+// Package signing signs eBPF+WASM modules with ed25519 keys, and verifies the signatures before the agents load the
+// modules' code.
+package signing
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+
+	modulepb "github.com/tricorder/src/pb/module"
+)
+
+// ErrRejected is wrapped by the errors of Verifier.Verify(), the module must not be loaded.
+var ErrRejected = errors.New("module is rejected")
+
+// Prefixes the signed content, so that the signatures of modules cannot be reused for other content.
+const digestDomain = "starship-module-v1"
+
+// Digest returns the SHA-256 digest of the module's content that is signed, which is the code loaded by the agents,
+// and how it's loaded: the eBPF code, perf buffer name and probes, and the WASM binary code and function name.
+// The name, output schema and sink are not signed, they do not change the code running on the agents.
+func Digest(m *modulepb.Module) []byte {
+	h := sha256.New()
+	writeField := func(data []byte) {
+		var size [8]byte
+		binary.BigEndian.PutUint64(size[:], uint64(len(data)))
+		h.Write(size[:])
+		h.Write(data)
+	}
+	writeInt := func(i int64) {
+		var b [8]byte
+		binary.BigEndian.PutUint64(b[:], uint64(i))
+		writeField(b[:])
+	}
+
+	writeField([]byte(digestDomain))
+	ebpf := m.GetEbpf()
+	writeField([]byte(ebpf.GetCode()))
+	writeField([]byte(ebpf.GetPerfBufferName()))
+	writeInt(int64(len(ebpf.GetProbes())))
+	for _, probe := range ebpf.GetProbes() {
+		writeInt(int64(probe.GetType()))
+		writeField([]byte(probe.GetTarget()))
+		writeField([]byte(probe.GetEntry()))
+		writeField([]byte(probe.GetReturn()))
+		writeInt(probe.GetSamplePeriodNanos())
+		writeField([]byte(probe.GetBinaryPath()))
+	}
+	wasm := m.GetWasm()
+	writeField(wasm.GetCode())
+	writeField([]byte(wasm.GetFnName()))
+	return h.Sum(nil)
+}
+
+// KeyID returns the ID of the public key, the hex of the first 8 bytes of its SHA-256 digest.
+func KeyID(key ed25519.PublicKey) string {
+	sum := sha256.Sum256(key)
+	return hex.EncodeToString(sum[:8])
+}
+
+// Sign returns the signature of the module by the key.
+func Sign(m *modulepb.Module, key ed25519.PrivateKey) *modulepb.Signature {
+	return &modulepb.Signature{
+		KeyId: KeyID(key.Public().(ed25519.PublicKey)),
+		Value: ed25519.Sign(key, Digest(m)),
+	}
+}
+
+// LoadPrivateKey returns the ed25519 private key in the PKCS #8 PEM file at path, which can be created by:
+// openssl genpkey -algorithm ed25519 -out key.pem.
+func LoadPrivateKey(path string) (ed25519.PrivateKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("while loading signing key, failed to read '%s', error: %v", path, err)
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("while loading signing key, failed to find PEM block in '%s'", path)
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("while loading signing key, failed to parse '%s', error: %v", path, err)
+	}
+	privateKey, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("while loading signing key, failed to parse '%s', error: not an ed25519 key", path)
+	}
+	return privateKey, nil
+}
+
+// Verifier verifies the modules' signatures with the trusted public keys.
+type Verifier struct {
+	// Key is the key ID.
+	keys map[string]ed25519.PublicKey
+}
+
+// NewVerifier returns a Verifier that trusts the keys.
+func NewVerifier(keys ...ed25519.PublicKey) *Verifier {
+	v := &Verifier{keys: make(map[string]ed25519.PublicKey, len(keys))}
+	for _, key := range keys {
+		v.keys[KeyID(key)] = key
+	}
+	return v
+}
+
+// LoadVerifier returns a Verifier that trusts the ed25519 public keys in the PEM file at path, which has one or more
+// PKIX public keys, like the output of: openssl pkey -in key.pem -pubout.
+func LoadVerifier(path string) (*Verifier, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("while loading trusted keys, failed to read '%s', error: %v", path, err)
+	}
+	var keys []ed25519.PublicKey
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		key, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("while loading trusted keys, failed to parse key #%d in '%s', error: %v",
+				len(keys), path, err)
+		}
+		publicKey, ok := key.(ed25519.PublicKey)
+		if !ok {
+			return nil, fmt.Errorf("while loading trusted keys, failed to parse key #%d in '%s', error: "+
+				"not an ed25519 key", len(keys), path)
+		}
+		keys = append(keys, publicKey)
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("while loading trusted keys, failed to find any PEM block in '%s'", path)
+	}
+	return NewVerifier(keys...), nil
+}
+
+// Verify returns an error wrapping ErrRejected if the module is not signed, or not signed by any trusted key, or its
+// content does not match the signature.
+func (v *Verifier) Verify(m *modulepb.Module) error {
+	signature := m.GetSignature()
+	if len(signature.GetValue()) == 0 {
+		return fmt.Errorf("%w, it is not signed", ErrRejected)
+	}
+	key, ok := v.keys[signature.KeyId]
+	if !ok {
+		return fmt.Errorf("%w, it is signed by untrusted key '%s'", ErrRejected, signature.KeyId)
+	}
+	if !ed25519.Verify(key, Digest(m), signature.Value) {
+		return fmt.Errorf("%w, its signature by key '%s' does not match its content", ErrRejected, signature.KeyId)
+	}
+	return nil
+}
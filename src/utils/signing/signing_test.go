@@ -0,0 +1,146 @@
+// Copyright (C) 2023  Tricorder Observability
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package signing
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+
+	modulepb "github.com/tricorder/src/pb/module"
+	"github.com/tricorder/src/pb/module/common"
+	ebpfpb "github.com/tricorder/src/pb/module/ebpf"
+	wasmpb "github.com/tricorder/src/pb/module/wasm"
+)
+
+func newModule() *modulepb.Module {
+	return &modulepb.Module{
+		Name: "module",
+		Ebpf: &ebpfpb.Program{
+			Code:           "int probe() { return 0; }",
+			PerfBufferName: "events",
+			Probes: []*ebpfpb.ProbeSpec{
+				{Type: ebpfpb.ProbeSpec_KPROBE, Target: "tcp_sendmsg", Entry: "probe"},
+			},
+		},
+		Wasm: &wasmpb.Program{
+			Code:         []byte{0x00, 0x61, 0x73, 0x6d},
+			FnName:       "copy_input_to_output",
+			OutputSchema: &common.Schema{Name: "output"},
+		},
+	}
+}
+
+// Tests that Verify() accepts the modules signed by the trusted keys, and rejects the unsigned modules, the modules
+// signed by other keys, and the modules whose code is changed after signing.
+func TestSignAndVerify(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(err)
+	_, otherKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(err)
+	verifier := NewVerifier(publicKey)
+
+	m := newModule()
+	m.Signature = Sign(m, privateKey)
+	assert.Equal(KeyID(publicKey), m.Signature.KeyId)
+	assert.NoError(verifier.Verify(m))
+
+	// The name and output schema are not signed.
+	renamed := proto.Clone(m).(*modulepb.Module)
+	renamed.Name = "renamed"
+	renamed.Wasm.OutputSchema.Name = "renamed"
+	assert.NoError(verifier.Verify(renamed))
+
+	unsigned := newModule()
+	err = verifier.Verify(unsigned)
+	assert.ErrorIs(err, ErrRejected)
+	assert.ErrorContains(err, "not signed")
+
+	untrusted := newModule()
+	untrusted.Signature = Sign(untrusted, otherKey)
+	err = verifier.Verify(untrusted)
+	assert.ErrorIs(err, ErrRejected)
+	assert.ErrorContains(err, "untrusted key")
+
+	tamperings := map[string]func(m *modulepb.Module){
+		"ebpf code":   func(m *modulepb.Module) { m.Ebpf.Code += " " },
+		"perf buffer": func(m *modulepb.Module) { m.Ebpf.PerfBufferName = "other" },
+		"probe":       func(m *modulepb.Module) { m.Ebpf.Probes[0].Target = "tcp_recvmsg" },
+		"new probe":   func(m *modulepb.Module) { m.Ebpf.Probes = append(m.Ebpf.Probes, &ebpfpb.ProbeSpec{}) },
+		"wasm code":   func(m *modulepb.Module) { m.Wasm.Code = append(m.Wasm.Code, 0x01) },
+		"wasm fn":     func(m *modulepb.Module) { m.Wasm.FnName = "other" },
+	}
+	for name, tamper := range tamperings {
+		tampered := proto.Clone(m).(*modulepb.Module)
+		tamper(tampered)
+		err = verifier.Verify(tampered)
+		assert.ErrorIs(err, ErrRejected, name)
+		assert.ErrorContains(err, "does not match", name)
+	}
+}
+
+// Tests that the keys are loaded from the PEM files written by openssl.
+func TestLoadKeys(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	dir := t.TempDir()
+	var publicPEM []byte
+	var privateKeys []ed25519.PrivateKey
+	for i := 0; i < 2; i++ {
+		publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+		require.NoError(err)
+		der, err := x509.MarshalPKIXPublicKey(publicKey)
+		require.NoError(err)
+		publicPEM = append(publicPEM, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})...)
+		privateKeys = append(privateKeys, privateKey)
+	}
+	publicPath := filepath.Join(dir, "trusted.pem")
+	require.NoError(os.WriteFile(publicPath, publicPEM, 0o600))
+
+	der, err := x509.MarshalPKCS8PrivateKey(privateKeys[1])
+	require.NoError(err)
+	privatePath := filepath.Join(dir, "key.pem")
+	require.NoError(os.WriteFile(privatePath, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0o600))
+
+	privateKey, err := LoadPrivateKey(privatePath)
+	require.NoError(err)
+	assert.True(privateKeys[1].Equal(privateKey))
+
+	verifier, err := LoadVerifier(publicPath)
+	require.NoError(err)
+	for _, key := range privateKeys {
+		m := newModule()
+		m.Signature = Sign(m, key)
+		assert.NoError(verifier.Verify(m))
+	}
+
+	_, err = LoadVerifier(privatePath)
+	assert.ErrorContains(err, "failed to parse key #0")
+	_, err = LoadPrivateKey(publicPath)
+	assert.ErrorContains(err, "failed to parse")
+}
@@ -21,6 +21,7 @@ import (
 	"fmt"
 	"io"
 	"path"
+	"time"
 
 	"github.com/tricorder/src/utils/file"
 )
@@ -78,3 +79,72 @@ func GZExtract(tarFilePath string, destDir string) error {
 
 	return nil
 }
+
+// File is a regular file in a tar archive.
+type File struct {
+	Name    string
+	Content []byte
+}
+
+// GZWrite writes the files to w as a *.tar.gz archive.
+func GZWrite(w io.Writer, files []File) error {
+	gzipWriter := gzip.NewWriter(w)
+	tarWriter := tar.NewWriter(gzipWriter)
+	now := time.Now()
+	for _, f := range files {
+		header := &tar.Header{
+			Typeflag: tar.TypeReg,
+			Name:     f.Name,
+			Size:     int64(len(f.Content)),
+			Mode:     0o644,
+			ModTime:  now,
+		}
+		if err := tarWriter.WriteHeader(header); err != nil {
+			return fmt.Errorf("could not write tar header of %s error: %v", f.Name, err)
+		}
+		if _, err := tarWriter.Write(f.Content); err != nil {
+			return fmt.Errorf("could not write %s to tar error: %v", f.Name, err)
+		}
+	}
+	if err := tarWriter.Close(); err != nil {
+		return fmt.Errorf("could not close tar writer error: %v", err)
+	}
+	if err := gzipWriter.Close(); err != nil {
+		return fmt.Errorf("could not close gzip writer error: %v", err)
+	}
+	return nil
+}
+
+// GZRead reads the regular files of the *.tar.gz archive from r, skipping the directories and other entries.
+// Returns an error if the files have more than maxSize bytes in total.
+func GZRead(r io.Reader, maxSize int64) ([]File, error) {
+	uncompressedStream, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("could not create gzip reader error: %v", err)
+	}
+	tarReader := tar.NewReader(uncompressedStream)
+	var files []File
+	var size int64
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("iterate tar error: %v", err)
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		size += header.Size
+		if header.Size < 0 || size > maxSize {
+			return nil, fmt.Errorf("files are larger than %d bytes", maxSize)
+		}
+		content := make([]byte, header.Size)
+		if _, err := io.ReadFull(tarReader, content); err != nil {
+			return nil, fmt.Errorf("could not read %s from tar error: %v", header.Name, err)
+		}
+		files = append(files, File{Name: header.Name, Content: content})
+	}
+	return files, nil
+}
@@ -16,10 +16,12 @@
 package tar
 
 import (
+	"bytes"
 	"path"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
 	testuitls "github.com/tricorder/src/testing/bazel"
 
@@ -37,3 +39,22 @@ func TestGZExtract(t *testing.T) {
 	tmpDir = testuitls.CreateTmpDir()
 	assert.NotNil(GZExtract("testdata/wrong_file_format.tar.gz", tmpDir))
 }
+
+// Tests that GZRead() reads the files written by GZWrite(), and limits the total size of the files.
+func TestGZWriteRead(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	files := []File{{Name: "manifest.json", Content: []byte("{}")}, {Name: "dir/empty"}}
+	var buf bytes.Buffer
+	require.Nil(GZWrite(&buf, files))
+
+	read, err := GZRead(bytes.NewReader(buf.Bytes()), 2)
+	require.Nil(err)
+	assert.Equal([]File{{Name: "manifest.json", Content: []byte("{}")}, {Name: "dir/empty", Content: []byte{}}}, read)
+
+	_, err = GZRead(bytes.NewReader(buf.Bytes()), 1)
+	assert.ErrorContains(err, "larger than 1 bytes")
+	_, err = GZRead(bytes.NewReader([]byte("not gzip")), 1)
+	assert.NotNil(err)
+}